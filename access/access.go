@@ -0,0 +1,418 @@
+/*
+Package access controls a door from badge, PIN and fingerprint readers,
+driving its electric strike through a relay and keeping an audit log of
+every attempt.
+
+	log, err := access.OpenLog("/var/lib/door/audit.log")
+	if err != nil {
+		panic(err)
+	}
+	d := access.New(strike)
+	d.FailSafe = true
+	d.Log = log
+	d.Grant(access.Card(12, 3456), "alice")
+	d.Grant(access.PIN("2580"), "bob")
+	d.Grant(access.Finger(7), "carol")
+	if err := d.Lock(); err != nil {
+		panic(err)
+	}
+	d.BindDisplay(lcd.Region(0, 0, 16))
+	if err := d.BindWiegand(wiegand.New(d0, d1)); err != nil {
+		panic(err)
+	}
+	d.BindFingerprint(r30x.New(port))
+	defer d.Close()
+
+	for e := range d.Events() {
+		fmt.Println(e)
+	}
+
+A fail-safe strike is powered to stay locked and unlocks when the power
+fails, as fire codes require for exits; a fail-secure strike is powered to
+unlock. The door is an interlock.Actuator: registered with an interlock
+controller, like one bound to a fire alarm, a trip holds a fail-safe door
+unlocked and a fail-secure one locked until Lock is called.
+*/
+package access
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+)
+
+const (
+	// DefaultUnlockTime is how long the door stays unlocked by default.
+	DefaultUnlockTime = 5 * time.Second
+
+	// DefaultMaxAttempts is the default number of denied attempts in a row
+	// locking the readers out.
+	DefaultMaxAttempts = 5
+
+	// DefaultLockout is how long the readers are locked out by default.
+	DefaultLockout = time.Minute
+
+	// DefaultIdle is the default prompt shown while the door is locked.
+	DefaultIdle = "Present badge"
+
+	// promptTime is how long an answer stays on the display.
+	promptTime = 3 * time.Second
+
+	eventBuffer = 16
+)
+
+// Credential is what a user presents to a reader.
+type Credential struct {
+	Kind string
+	ID   string
+}
+
+// Card returns the credential of a badge.
+func Card(facility, card uint32) Credential {
+	return Credential{Kind: "card", ID: fmt.Sprintf("%v:%v", facility, card)}
+}
+
+// PIN returns the credential of a PIN typed on a keypad.
+func PIN(pin string) Credential {
+	return Credential{Kind: "pin", ID: pin}
+}
+
+// Finger returns the credential of a fingerprint enrolled at id.
+func Finger(id uint16) Credential {
+	return Credential{Kind: "finger", ID: fmt.Sprint(id)}
+}
+
+// String returns the credential as logged. PINs are masked.
+func (c Credential) String() string {
+	if c.Kind == "pin" {
+		return "pin " + strings.Repeat("*", len(c.ID))
+	}
+	return c.Kind + " " + c.ID
+}
+
+// Door controls the strike of a door.
+type Door struct {
+	Strike embd.DigitalPin
+	// FailSafe is set for strikes locked while powered.
+	FailSafe bool
+
+	// UnlockTime is how long the door stays unlocked once access is
+	// granted.
+	UnlockTime time.Duration
+
+	// After MaxAttempts denied attempts in a row, every attempt is denied
+	// for Lockout.
+	MaxAttempts int
+	Lockout     time.Duration
+
+	// Log, if set, records every attempt and every change of the strike.
+	Log *Log
+
+	// Idle is the prompt shown on the display while the door is locked.
+	Idle string
+
+	clock clock.Clock
+
+	mu         sync.Mutex
+	users      map[Credential]string
+	unlocked   bool
+	held       bool
+	relock     clock.Timer
+	unlocks    int // counts the unlocks, for a stopped relock to be told apart
+	idle       clock.Timer
+	failures   int
+	lockoutEnd time.Time
+	bindings   []func(msg string)
+	closers    []func() error
+
+	events chan Entry
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New creates a new Door driving the strike through a pin.
+func New(strike embd.DigitalPin) *Door {
+	return NewWithClock(strike, clock.Real)
+}
+
+// NewWithClock creates a new Door timing the unlocks and the lockouts with
+// c, like a clock.Virtual in tests.
+func NewWithClock(strike embd.DigitalPin, c clock.Clock) *Door {
+	return &Door{
+		Strike:      strike,
+		UnlockTime:  DefaultUnlockTime,
+		MaxAttempts: DefaultMaxAttempts,
+		Lockout:     DefaultLockout,
+		Idle:        DefaultIdle,
+		clock:       clock.Or(c),
+		users:       map[Credential]string{},
+		events:      make(chan Entry, eventBuffer),
+		quit:        make(chan struct{}),
+	}
+}
+
+// Grant grants access to a user presenting a credential.
+func (d *Door) Grant(c Credential, user string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.users[c] = user
+}
+
+// Revoke revokes the access of a credential.
+func (d *Door) Revoke(c Credential) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.users, c)
+}
+
+// User returns the user a credential grants access to.
+func (d *Door) User(c Credential) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	user, ok := d.users[c]
+	return user, ok
+}
+
+// Events returns the channel the entries of the audit log are reported on,
+// whether or not the door has a Log. Entries are dropped when the channel
+// is full.
+func (d *Door) Events() <-chan Entry {
+	return d.events
+}
+
+// record logs and reports an entry.
+func (d *Door) record(e Entry) {
+	e.Time = d.clock.Now()
+	glog.V(1).Infof("access: %v", e)
+	if d.Log != nil {
+		if err := d.Log.Append(e); err != nil {
+			glog.Errorf("access: writing audit log: %v", err)
+		}
+	}
+	select {
+	case d.events <- e:
+	default:
+		glog.Warningf("access: event channel full, dropping %v", e)
+	}
+}
+
+// level returns the level of the strike pin locking or unlocking the door.
+func (d *Door) level(unlocked bool) int {
+	if unlocked != d.FailSafe {
+		return embd.High
+	}
+	return embd.Low
+}
+
+// Present presents a credential, unlocking the door for UnlockTime when it
+// grants access, and returns whether it did.
+func (d *Door) Present(c Credential) (bool, error) {
+	now := d.clock.Now()
+	d.mu.Lock()
+	user, ok := d.users[c]
+	lockedOut := now.Before(d.lockoutEnd)
+	switch {
+	case lockedOut:
+		ok = false
+	case ok:
+		d.failures = 0
+	default:
+		d.failures++
+		if d.MaxAttempts > 0 && d.failures >= d.MaxAttempts {
+			d.failures = 0
+			d.lockoutEnd = now.Add(d.Lockout)
+			glog.Warningf("access: %v denied attempts, locking out for %v", d.MaxAttempts, d.Lockout)
+		}
+	}
+	d.mu.Unlock()
+
+	switch {
+	case lockedOut:
+		d.record(Entry{Action: "denied", Credential: c.String(), Detail: "locked out"})
+		d.show("Locked out", true)
+		return false, nil
+	case !ok:
+		d.record(Entry{Action: "denied", Credential: c.String(), Detail: "unknown credential"})
+		d.show("Access denied", true)
+		return false, nil
+	}
+	d.record(Entry{Action: "granted", Credential: c.String(), User: user})
+	d.show("Welcome "+user, false)
+	return true, d.Unlock(d.UnlockTime, user)
+}
+
+// Unlock unlocks the door for dur, like for an exit button or a remote
+// opening, then locks it again. The reason is logged.
+func (d *Door) Unlock(dur time.Duration, reason string) error {
+	d.mu.Lock()
+	if d.held {
+		d.mu.Unlock()
+		return nil
+	}
+	if err := d.Strike.Write(d.level(true)); err != nil {
+		d.mu.Unlock()
+		return err
+	}
+	first := !d.unlocked
+	d.unlocked = true
+	if d.relock != nil {
+		d.relock.Stop()
+	}
+	d.unlocks++
+	unlock := d.unlocks
+	d.relock = d.clock.AfterFunc(dur, func() {
+		d.mu.Lock()
+		stale := unlock != d.unlocks
+		d.mu.Unlock()
+		if stale {
+			return
+		}
+		if err := d.lock("timeout"); err != nil {
+			glog.Errorf("access: locking the door: %v", err)
+		}
+	})
+	d.mu.Unlock()
+
+	if first {
+		d.record(Entry{Action: "unlocked", Detail: reason})
+	}
+	return nil
+}
+
+// lock locks the door, unless it is held by SafeState.
+func (d *Door) lock(reason string) error {
+	d.mu.Lock()
+	if d.held {
+		d.mu.Unlock()
+		return nil
+	}
+	if err := d.Strike.Write(d.level(false)); err != nil {
+		d.mu.Unlock()
+		return err
+	}
+	was := d.unlocked
+	d.unlocked = false
+	if d.relock != nil {
+		d.relock.Stop()
+		d.relock = nil
+	}
+	d.mu.Unlock()
+
+	if was {
+		d.record(Entry{Action: "locked", Detail: reason})
+		d.show(d.Idle, false)
+	}
+	return nil
+}
+
+// Lock locks the door at once, releasing it when it is held by SafeState.
+func (d *Door) Lock() error {
+	d.mu.Lock()
+	d.held = false
+	d.mu.Unlock()
+	return d.lock("manual")
+}
+
+// Unlocked returns whether the door is unlocked.
+func (d *Door) Unlocked() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.unlocked
+}
+
+// SafeState implements interlock.Actuator: it holds a fail-safe door
+// unlocked and a fail-secure one locked, until Lock is called.
+func (d *Door) SafeState() error {
+	d.mu.Lock()
+	if err := d.Strike.Write(d.level(d.FailSafe)); err != nil {
+		d.mu.Unlock()
+		return err
+	}
+	first := !d.held
+	d.held, d.unlocked = true, d.FailSafe
+	if d.relock != nil {
+		d.relock.Stop()
+		d.relock = nil
+	}
+	d.mu.Unlock()
+
+	if first {
+		state := "locked"
+		if d.FailSafe {
+			state = "unlocked"
+		}
+		d.record(Entry{Action: "held", Detail: state})
+	}
+	return nil
+}
+
+// BindDisplay shows the prompts and the answers to the attempts on a
+// display region.
+func (d *Door) BindDisplay(r *characterdisplay.Region) {
+	d.mu.Lock()
+	d.bindings = append(d.bindings, func(msg string) {
+		if err := r.Write(msg); err != nil {
+			glog.Errorf("access: updating display region: %v", err)
+		}
+	})
+	d.mu.Unlock()
+	d.show(d.Idle, false)
+}
+
+// show shows a message on the bound display regions, going back to the
+// idle prompt after a while when temporary.
+func (d *Door) show(msg string, temporary bool) {
+	d.mu.Lock()
+	bindings := append([]func(string){}, d.bindings...)
+	if d.idle != nil {
+		d.idle.Stop()
+		d.idle = nil
+	}
+	if temporary && len(bindings) > 0 {
+		d.idle = d.clock.AfterFunc(promptTime, func() {
+			d.show(d.Idle, false)
+		})
+	}
+	d.mu.Unlock()
+
+	for _, b := range bindings {
+		b(msg)
+	}
+}
+
+// Close stops the bound readers and locks the door, unless it is held by
+// SafeState.
+func (d *Door) Close() error {
+	d.mu.Lock()
+	select {
+	case <-d.quit:
+		d.mu.Unlock()
+		return nil
+	default:
+	}
+	close(d.quit)
+	closers := d.closers
+	d.closers = nil
+	if d.idle != nil {
+		d.idle.Stop()
+	}
+	d.mu.Unlock()
+
+	for _, c := range closers {
+		if err := c(); err != nil {
+			glog.Errorf("access: closing reader: %v", err)
+		}
+	}
+	d.wg.Wait()
+	return d.lock("closed")
+}
@@ -0,0 +1,147 @@
+package access
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/interface/keypad/wiegand"
+)
+
+type fakePin struct {
+	embd.DigitalPin
+
+	val int
+}
+
+func (p *fakePin) Write(val int) error {
+	p.val = val
+	return nil
+}
+
+func newDoor(failSafe bool) (*Door, *fakePin, *clock.Virtual) {
+	strike := &fakePin{}
+	c := clock.NewVirtual(time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC))
+	d := NewWithClock(strike, c)
+	d.FailSafe = failSafe
+	d.Grant(Card(12, 3456), "alice")
+	d.Grant(PIN("2580"), "bob")
+	return d, strike, c
+}
+
+func TestPresent(t *testing.T) {
+	for _, failSafe := range []bool{false, true} {
+		d, strike, c := newDoor(failSafe)
+		unlocked, locked := embd.High, embd.Low
+		if failSafe {
+			unlocked, locked = locked, unlocked
+		}
+		if err := d.Lock(); err != nil || strike.val != locked {
+			t.Fatalf("fail-safe %v: not locked: %v", failSafe, err)
+		}
+
+		if ok, err := d.Present(Card(12, 3456)); !ok || err != nil {
+			t.Fatalf("fail-safe %v: badge denied: %v", failSafe, err)
+		}
+		if strike.val != unlocked || !d.Unlocked() {
+			t.Errorf("fail-safe %v: strike not unlocked", failSafe)
+		}
+		c.Advance(DefaultUnlockTime)
+		if strike.val != locked || d.Unlocked() {
+			t.Errorf("fail-safe %v: strike not locked again", failSafe)
+		}
+
+		if ok, _ := d.Present(Card(12, 1)); ok {
+			t.Errorf("fail-safe %v: unknown badge granted", failSafe)
+		}
+		if strike.val != locked {
+			t.Errorf("fail-safe %v: strike unlocked for an unknown badge", failSafe)
+		}
+	}
+}
+
+func TestLockout(t *testing.T) {
+	d, _, c := newDoor(false)
+	for i := 0; i < DefaultMaxAttempts; i++ {
+		d.Present(PIN("0000"))
+	}
+	if ok, _ := d.Present(PIN("2580")); ok {
+		t.Fatal("granted while locked out")
+	}
+	c.Advance(DefaultLockout)
+	if ok, _ := d.Present(PIN("2580")); !ok {
+		t.Error("denied after the lockout")
+	}
+}
+
+func TestSafeState(t *testing.T) {
+	d, strike, c := newDoor(true)
+	if err := d.SafeState(); err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(time.Hour)
+	if !d.Unlocked() || strike.val != embd.Low {
+		t.Fatal("fail-safe door not held unlocked")
+	}
+	if err := d.Lock(); err != nil || d.Unlocked() || strike.val != embd.High {
+		t.Errorf("door not locked after Lock: %v", err)
+	}
+}
+
+func TestPINEntry(t *testing.T) {
+	d, _, _ := newDoor(false)
+	var p pinEntry
+	for _, k := range "19*2580" {
+		if _, ok := d.code(&p, wiegand.Code{Bits: 4, Key: byte(k)}); ok {
+			t.Fatalf("credential completed at %c", k)
+		}
+	}
+	cred, ok := d.code(&p, wiegand.Code{Bits: 4, Key: '#'})
+	if !ok || cred != PIN("2580") {
+		t.Fatalf("got %v, %v, want the PIN", cred, ok)
+	}
+	if cred.String() != "pin ****" {
+		t.Errorf("PIN logged as %q", cred)
+	}
+	if cred, ok := d.code(&p, wiegand.Code{Bits: 26, Facility: 12, Card: 3456}); !ok || cred != Card(12, 3456) {
+		t.Errorf("got %v, %v, want the badge", cred, ok)
+	}
+}
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := OpenLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _, c := newDoor(false)
+	d.Log = log
+	d.Present(Card(12, 3456))
+	c.Advance(DefaultUnlockTime)
+	d.Present(PIN("1111"))
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var actions []string
+	log.Each(time.Time{}, time.Time{}, func(e Entry) error {
+		actions = append(actions, e.Action)
+		return nil
+	})
+	if got, want := strings.Join(actions, ","), "granted,unlocked,locked,denied"; got != want {
+		t.Errorf("logged %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := log.ExportCSV(&buf, c.Now(), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	want := "time,action,credential,user,detail\n2024-05-01T08:00:05Z,locked,,,timeout\n2024-05-01T08:00:05Z,denied,pin ****,,unknown credential\n"
+	if buf.String() != want {
+		t.Errorf("exported\n%v\nwant\n%v", buf.String(), want)
+	}
+}
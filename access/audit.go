@@ -0,0 +1,132 @@
+// Audit log.
+
+package access
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Entry is an entry of the audit log: an attempt to open the door, or the
+// door being unlocked or locked.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Action is "granted", "denied", "unlocked", "locked" or "held".
+	Action     string `json:"action"`
+	Credential string `json:"credential,omitempty"`
+	User       string `json:"user,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+func (e Entry) String() string {
+	s := e.Action
+	if e.Credential != "" {
+		s += " " + e.Credential
+	}
+	if e.User != "" {
+		s += " (" + e.User + ")"
+	}
+	if e.Detail != "" {
+		s += ": " + e.Detail
+	}
+	return s
+}
+
+// Log is an append-only audit log, one JSON entry per line. Every entry is
+// synced to the disk before Append returns, so that a power cut loses at
+// most the entry being written.
+type Log struct {
+	Path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenLog opens the audit log at path, creating it if needed.
+func OpenLog(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{Path: path, f: f}, nil
+}
+
+// Append appends an entry to the log.
+func (l *Log) Append(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return os.ErrClosed
+	}
+	if _, err := l.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return l.f.Sync()
+}
+
+// Each calls fn with the entries logged between from and to, in order. A
+// zero from or to leaves the range open. A line torn by a power cut is
+// skipped.
+func (l *Log) Each(from, to time.Time, fn func(Entry) error) error {
+	f, err := os.Open(l.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var e Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			glog.Warningf("access: skipping invalid audit log line %q", s.Text())
+			continue
+		}
+		if (!from.IsZero() && e.Time.Before(from)) || (!to.IsZero() && !e.Time.Before(to)) {
+			continue
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+// ExportCSV writes the entries logged between from and to as CSV, with a
+// header line.
+func (l *Log) ExportCSV(w io.Writer, from, to time.Time) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "action", "credential", "user", "detail"})
+	err := l.Each(from, to, func(e Entry) error {
+		return cw.Write([]string{e.Time.Format(time.RFC3339), e.Action, e.Credential, e.User, e.Detail})
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Close closes the log.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
@@ -0,0 +1,108 @@
+// Readers.
+
+package access
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/interface/keypad/wiegand"
+	"github.com/kidoman/embd/sensor/r30x"
+)
+
+// retryDelay is how long a failed fingerprint module is left alone.
+const retryDelay = time.Second
+
+// pinEntry collects the keys of a PIN typed on a Wiegand keypad: '#' ends
+// the PIN and '*' clears it.
+type pinEntry struct {
+	keys []byte
+}
+
+// code handles a code of the reader, and returns the credential it
+// completes, if any.
+func (d *Door) code(p *pinEntry, c wiegand.Code) (Credential, bool) {
+	switch {
+	case c.Key == 0:
+		p.keys = nil
+		return Card(c.Facility, c.Card), true
+	case c.Key == '#':
+		pin := string(p.keys)
+		p.keys = nil
+		return PIN(pin), pin != ""
+	case c.Key == '*':
+		p.keys = nil
+		d.show(d.Idle, false)
+	default:
+		p.keys = append(p.keys, c.Key)
+		d.show("PIN: "+strings.Repeat("*", len(p.keys)), true)
+	}
+	return Credential{}, false
+}
+
+// BindWiegand runs a Wiegand reader, presenting its badges and the PINs
+// typed on its keypad. The reader is closed with the door.
+func (d *Door) BindWiegand(w *wiegand.Wiegand) error {
+	if err := w.Run(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.closers = append(d.closers, w.Close)
+	d.mu.Unlock()
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		var p pinEntry
+		for c := range w.Codes() {
+			cred, ok := d.code(&p, c)
+			if !ok {
+				continue
+			}
+			if _, err := d.Present(cred); err != nil {
+				glog.Errorf("access: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// BindFingerprint keeps identifying the fingers placed on a fingerprint
+// module, presenting the enrolled ones as their Finger credential. An
+// unknown finger is denied.
+func (d *Door) BindFingerprint(f *r30x.R30X) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		for {
+			select {
+			case <-d.quit:
+				return
+			default:
+			}
+			m, err := f.Identify()
+			switch {
+			case err == nil:
+				_, err = d.Present(Finger(m.ID))
+			case errors.Is(err, r30x.ErrNotFound):
+				_, err = d.Present(Credential{Kind: "finger", ID: "unknown"})
+			case errors.Is(err, r30x.ErrTimeout):
+				err = nil
+			default:
+				glog.Errorf("access: identifying finger: %v", err)
+				select {
+				case <-time.After(retryDelay):
+				case <-d.quit:
+				}
+				err = nil
+			}
+			if err != nil {
+				glog.Errorf("access: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,174 @@
+/*
+Package async runs slow device operations, like the writes of a character
+display or the moves of an actuator, in the background from a bounded
+queue.
+
+Producers submitting faster than the device keeps up get backpressure:
+Submit blocks until the queue has room or its context is done, and
+TrySubmit fails at once with ErrFull, for producers which would rather drop
+an update than wait:
+
+	q := async.NewQueue(16)
+	defer q.Close()
+
+	f, err := q.TrySubmit(func() error { return s.SetAngle(90) })
+	if err == async.ErrFull {
+		// Skip this update, the servo is behind.
+	}
+	...
+	if err := f.Wait(ctx); err != nil {
+		log.Print(err)
+	}
+*/
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/kidoman/embd"
+)
+
+// ErrFull is returned by TrySubmit when the queue is full.
+var ErrFull = errors.New("async: queue full")
+
+// Future is the completion of a submitted operation.
+type Future struct {
+	done chan struct{}
+	err  error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) complete(err error) {
+	f.err = err
+	close(f.done)
+}
+
+// Done returns a channel closed once the operation completed.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Err returns the error of the operation once it completed, nil before.
+func (f *Future) Err() error {
+	select {
+	case <-f.done:
+		return f.err
+	default:
+		return nil
+	}
+}
+
+// Wait waits for the operation to complete and returns its error, or the
+// error of ctx once it is done.
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Then calls fn with the error of the operation once it completed, in a
+// goroutine of its own.
+func (f *Future) Then(fn func(err error)) {
+	go func() {
+		<-f.done
+		fn(f.err)
+	}()
+}
+
+type op struct {
+	fn func() error
+	f  *Future
+}
+
+// Queue runs operations one at a time, in the order they were submitted.
+type Queue struct {
+	ops  chan op
+	done chan struct{}
+
+	// mu is held for reading while submitting, so that Close does not
+	// close the channel under a submitter.
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewQueue creates a new queue holding up to size operations waiting to
+// run, and starts running them.
+func NewQueue(size int) *Queue {
+	q := &Queue{
+		ops:  make(chan op, size),
+		done: make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+
+	for o := range q.ops {
+		o.f.complete(o.fn())
+	}
+}
+
+// Submit queues an operation, waiting for room in the queue until ctx is
+// done. It returns embd.ErrClosed once the queue is closed.
+func (q *Queue) Submit(ctx context.Context, fn func() error) (*Future, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return nil, embd.ErrClosed
+	}
+	f := newFuture()
+	select {
+	case q.ops <- op{fn, f}:
+		return f, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// TrySubmit queues an operation, or returns ErrFull at once when the queue
+// is full.
+func (q *Queue) TrySubmit(fn func() error) (*Future, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return nil, embd.ErrClosed
+	}
+	f := newFuture()
+	select {
+	case q.ops <- op{fn, f}:
+		return f, nil
+	default:
+		return nil, ErrFull
+	}
+}
+
+// Len returns the number of operations waiting to run.
+func (q *Queue) Len() int {
+	return len(q.ops)
+}
+
+// Close stops accepting operations, and waits for the queued ones to run.
+// Closing it again does nothing.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.ops)
+	}
+	q.mu.Unlock()
+
+	<-q.done
+	return nil
+}
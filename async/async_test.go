@@ -0,0 +1,89 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+)
+
+func TestQueue(t *testing.T) {
+	q := NewQueue(2)
+	release := make(chan struct{})
+	var order []int
+
+	// The first operation blocks the queue, the next two fill it.
+	first, err := q.Submit(context.Background(), func() error {
+		<-release
+		order = append(order, 0)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the worker to take the first operation.
+	for q.Len() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	var futures []*Future
+	for i := 1; i <= 2; i++ {
+		i := i
+		f, err := q.TrySubmit(func() error {
+			order = append(order, i)
+			if i == 2 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("submitting %v: %v", i, err)
+		}
+		futures = append(futures, f)
+	}
+	if _, err := q.TrySubmit(func() error { return nil }); err != ErrFull {
+		t.Errorf("submitting to a full queue: got %v, want ErrFull", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.Submit(ctx, func() error { return nil }); err != context.DeadlineExceeded {
+		t.Errorf("submitting to a full queue: got %v, want the context error", err)
+	}
+
+	called := make(chan error, 1)
+	futures[1].Then(func(err error) { called <- err })
+	close(release)
+
+	if err := first.Wait(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if err := futures[0].Wait(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if err := <-called; err == nil || err.Error() != "failed" {
+		t.Errorf("callback got %v, want the error of the operation", err)
+	}
+	if len(order) != 3 || order[0] != 0 || order[1] != 1 || order[2] != 2 {
+		t.Errorf("ran %v, want the submission order", order)
+	}
+
+	q.Close()
+	if _, err := q.TrySubmit(func() error { return nil }); err != embd.ErrClosed {
+		t.Errorf("submitting after Close: got %v, want embd.ErrClosed", err)
+	}
+}
+
+func TestQueue_closeDrains(t *testing.T) {
+	q := NewQueue(4)
+	ran := 0
+	for i := 0; i < 4; i++ {
+		if _, err := q.TrySubmit(func() error { ran++; return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.Close()
+	if ran != 4 {
+		t.Errorf("ran %v operations before Close returned, want 4", ran)
+	}
+}
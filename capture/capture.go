@@ -0,0 +1,281 @@
+/*
+Package capture grabs frames from Video4Linux2 cameras, like USB webcams or the
+Raspberry Pi camera (through the bcm2835-v4l2 driver or libcamera's V4L2
+compatibility layer).
+
+Frames are standard image.Image values, so they can be saved with image/png or
+shown on a display through the graphics pipeline:
+
+	cam, err := capture.Open("/dev/video0", 320, 240, capture.YUYV)
+	...
+	defer cam.Close()
+
+	frame, err := cam.Capture()
+	...
+	mono := graphics.Prepare(frame, panel.Bounds(), graphics.FloydSteinberg)
+*/
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/golang/glog"
+)
+
+// PixelFormat is a V4L2 pixel format code.
+type PixelFormat uint32
+
+func fourcc(s string) PixelFormat {
+	return PixelFormat(uint32(s[0]) | uint32(s[1])<<8 | uint32(s[2])<<16 | uint32(s[3])<<24)
+}
+
+// Supported pixel formats.
+var (
+	// YUYV is packed YUV 4:2:2, supported by nearly all webcams.
+	YUYV = fourcc("YUYV")
+
+	// Grey is 8 bit luminance only.
+	Grey = fourcc("GREY")
+
+	// MJPEG is motion JPEG, which webcams offer for higher resolutions and
+	// frame rates at the cost of decoding.
+	MJPEG = fourcc("MJPG")
+)
+
+func (f PixelFormat) String() string {
+	return string([]byte{byte(f), byte(f >> 8), byte(f >> 16), byte(f >> 24)})
+}
+
+// DefaultBuffers is the number of frame buffers shared with the driver.
+const DefaultBuffers = 4
+
+// Camera is an open V4L2 capture device.
+type Camera struct {
+	Device string
+
+	width, height int
+	stride        int
+	format        PixelFormat
+
+	mu      sync.Mutex
+	file    *os.File
+	buffers [][]byte
+
+	frames chan image.Image
+	quit   chan struct{}
+}
+
+// Open opens a capture device and starts streaming. The driver may adjust
+// the requested size to the nearest one it supports; use Bounds to find the
+// actual size.
+func Open(device string, width, height int, format PixelFormat) (*Camera, error) {
+	file, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	c := &Camera{Device: device, file: file}
+	if err := c.init(width, height, format); err != nil {
+		c.release()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Camera) init(width, height int, format PixelFormat) error {
+	fd := c.file.Fd()
+
+	var caps v4l2Capability
+	if err := ioctl(fd, vidiocQueryCap, unsafe.Pointer(&caps)); err != nil {
+		return fmt.Errorf("capture: %v is not a v4l2 device: %v", c.Device, err)
+	}
+	if caps.capabilities&capVideoCapture == 0 || caps.capabilities&capStreaming == 0 {
+		return fmt.Errorf("capture: %v does not support streaming capture", c.Device)
+	}
+
+	var f v4l2Format
+	f.typ = bufTypeVideoCapture
+	f.fmt.pix.width = uint32(width)
+	f.fmt.pix.height = uint32(height)
+	f.fmt.pix.pixelformat = uint32(format)
+	f.fmt.pix.field = fieldNone
+	if err := ioctl(fd, vidiocSFmt, unsafe.Pointer(&f)); err != nil {
+		return fmt.Errorf("capture: setting format of %v: %v", c.Device, err)
+	}
+	if PixelFormat(f.fmt.pix.pixelformat) != format {
+		return fmt.Errorf("capture: %v does not support format %v", c.Device, format)
+	}
+	c.width, c.height = int(f.fmt.pix.width), int(f.fmt.pix.height)
+	c.stride = int(f.fmt.pix.bytesperline)
+	c.format = format
+	if c.stride == 0 {
+		// Some drivers leave the stride of packed formats to us.
+		c.stride = c.width
+		if format == YUYV {
+			c.stride *= 2
+		}
+	}
+	glog.V(1).Infof("capture: %v streaming %vx%v %v", c.Device, c.width, c.height, c.format)
+
+	req := v4l2RequestBuffers{count: DefaultBuffers, typ: bufTypeVideoCapture, memory: memoryMMAP}
+	if err := ioctl(fd, vidiocReqBufs, unsafe.Pointer(&req)); err != nil {
+		return fmt.Errorf("capture: requesting buffers from %v: %v", c.Device, err)
+	}
+	for i := uint32(0); i < req.count; i++ {
+		buf := v4l2Buffer{index: i, typ: bufTypeVideoCapture, memory: memoryMMAP}
+		if err := ioctl(fd, vidiocQueryBuf, unsafe.Pointer(&buf)); err != nil {
+			return err
+		}
+		data, err := syscall.Mmap(int(fd), int64(buf.offset), int(buf.length), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+		if err != nil {
+			return err
+		}
+		c.buffers = append(c.buffers, data)
+		if err := ioctl(fd, vidiocQBuf, unsafe.Pointer(&buf)); err != nil {
+			return err
+		}
+	}
+
+	typ := uint32(bufTypeVideoCapture)
+	return ioctl(fd, vidiocStreamOn, unsafe.Pointer(&typ))
+}
+
+// Bounds returns the size of the captured frames.
+func (c *Camera) Bounds() image.Rectangle {
+	return image.Rect(0, 0, c.width, c.height)
+}
+
+// Format returns the pixel format of the captured frames.
+func (c *Camera) Format() PixelFormat {
+	return c.format
+}
+
+// Capture waits for the next frame and returns it.
+func (c *Camera) Capture() (image.Image, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil, fmt.Errorf("capture: %v is closed", c.Device)
+	}
+	fd := c.file.Fd()
+
+	buf := v4l2Buffer{typ: bufTypeVideoCapture, memory: memoryMMAP}
+	if err := ioctl(fd, vidiocDQBuf, unsafe.Pointer(&buf)); err != nil {
+		return nil, err
+	}
+	img, err := c.decode(c.buffers[buf.index][:buf.bytesused])
+	if qerr := ioctl(fd, vidiocQBuf, unsafe.Pointer(&buf)); qerr != nil && err == nil {
+		err = qerr
+	}
+	return img, err
+}
+
+// decode converts a frame to an image. The frame data is only valid until
+// the buffer is queued again, so it is always copied.
+func (c *Camera) decode(data []byte) (image.Image, error) {
+	switch c.format {
+	case YUYV:
+		return yuyvToImage(data, c.width, c.height, c.stride), nil
+	case Grey:
+		img := image.NewGray(c.Bounds())
+		for y := 0; y < c.height && (y+1)*c.stride <= len(data); y++ {
+			copy(img.Pix[y*img.Stride:], data[y*c.stride:y*c.stride+c.width])
+		}
+		return img, nil
+	case MJPEG:
+		return jpeg.Decode(bytes.NewReader(data))
+	}
+	return nil, fmt.Errorf("capture: unsupported format %v", c.format)
+}
+
+// yuyvToImage converts packed YUV 4:2:2 (Y0 U Y1 V) to planar YCbCr.
+func yuyvToImage(data []byte, width, height, stride int) *image.YCbCr {
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio422)
+	for y := 0; y < height && (y+1)*stride <= len(data); y++ {
+		row := data[y*stride:]
+		for x := 0; x+1 < width; x += 2 {
+			p := row[x*2:]
+			img.Y[y*img.YStride+x] = p[0]
+			img.Y[y*img.YStride+x+1] = p[2]
+			img.Cb[y*img.CStride+x/2] = p[1]
+			img.Cr[y*img.CStride+x/2] = p[3]
+		}
+	}
+	return img
+}
+
+// Frames returns a channel receiving captured frames, once Run was called.
+func (c *Camera) Frames() <-chan image.Image {
+	return c.frames
+}
+
+// Run starts capturing frames in the background. Frames which are not
+// received before the next one is captured are dropped.
+func (c *Camera) Run() {
+	c.frames = make(chan image.Image)
+	c.quit = make(chan struct{})
+	frames, quit := c.frames, c.quit
+
+	go func() {
+		for {
+			img, err := c.Capture()
+			if err != nil {
+				glog.Errorf("capture: reading from %v: %v", c.Device, err)
+			}
+
+			select {
+			case <-quit:
+				return
+			default:
+			}
+			if err != nil {
+				continue
+			}
+
+			select {
+			case frames <- img:
+			case <-quit:
+				return
+			default:
+			}
+		}
+	}()
+}
+
+// Close stops streaming and releases the device.
+func (c *Camera) Close() error {
+	if c.quit != nil {
+		close(c.quit)
+		c.quit = nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	typ := uint32(bufTypeVideoCapture)
+	err := ioctl(c.file.Fd(), vidiocStreamOff, unsafe.Pointer(&typ))
+	if rerr := c.release(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+func (c *Camera) release() error {
+	for _, b := range c.buffers {
+		syscall.Munmap(b)
+	}
+	c.buffers = nil
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
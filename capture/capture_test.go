@@ -0,0 +1,59 @@
+package capture
+
+import (
+	"image/color"
+	"testing"
+	"unsafe"
+)
+
+func TestStructSizes(t *testing.T) {
+	// Sizes of the kernel structures, which are encoded in the ioctl numbers.
+	buffer, format := uintptr(68), uintptr(204)
+	if unsafe.Sizeof(uintptr(0)) == 8 {
+		buffer, format = 88, 208
+	}
+	if s := unsafe.Sizeof(v4l2Buffer{}); s != buffer {
+		t.Errorf("v4l2_buffer: got %v bytes, want %v", s, buffer)
+	}
+	if s := unsafe.Sizeof(v4l2Format{}); s != format {
+		t.Errorf("v4l2_format: got %v bytes, want %v", s, format)
+	}
+	if s := unsafe.Sizeof(v4l2Capability{}); s != 104 {
+		t.Errorf("v4l2_capability: got %v bytes, want 104", s)
+	}
+	if s := unsafe.Sizeof(v4l2RequestBuffers{}); s != 20 {
+		t.Errorf("v4l2_requestbuffers: got %v bytes, want 20", s)
+	}
+}
+
+func TestPixelFormat(t *testing.T) {
+	if YUYV != 0x56595559 {
+		t.Errorf("YUYV: got %#x, want 0x56595559", uint32(YUYV))
+	}
+	if s := MJPEG.String(); s != "MJPG" {
+		t.Errorf("MJPEG: got %q, want MJPG", s)
+	}
+}
+
+func TestYUYVToImage(t *testing.T) {
+	// Two rows of two pixels, with 2 bytes of padding per row.
+	data := []byte{
+		10, 128, 20, 128, 0, 0,
+		235, 90, 16, 240, 0, 0,
+	}
+	img := yuyvToImage(data, 2, 2, 6)
+	var tests = []struct {
+		x, y int
+		c    color.YCbCr
+	}{
+		{0, 0, color.YCbCr{10, 128, 128}},
+		{1, 0, color.YCbCr{20, 128, 128}},
+		{0, 1, color.YCbCr{235, 90, 240}},
+		{1, 1, color.YCbCr{16, 90, 240}},
+	}
+	for _, test := range tests {
+		if c := img.YCbCrAt(test.x, test.y); c != test.c {
+			t.Errorf("Pixel (%v, %v): got %v, want %v", test.x, test.y, c, test.c)
+		}
+	}
+}
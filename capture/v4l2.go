@@ -0,0 +1,121 @@
+// V4L2 ioctl interface.
+
+package capture
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	bufTypeVideoCapture = 1
+	memoryMMAP          = 1
+	fieldNone           = 1
+
+	capVideoCapture = 0x00000001
+	capStreaming    = 0x04000000
+)
+
+type v4l2Capability struct {
+	driver       [16]byte
+	card         [32]byte
+	busInfo      [32]byte
+	version      uint32
+	capabilities uint32
+	deviceCaps   uint32
+	reserved     [3]uint32
+}
+
+type v4l2PixFormat struct {
+	width        uint32
+	height       uint32
+	pixelformat  uint32
+	field        uint32
+	bytesperline uint32
+	sizeimage    uint32
+	colorspace   uint32
+	priv         uint32
+	flags        uint32
+	ycbcrEnc     uint32
+	quantization uint32
+	xferFunc     uint32
+}
+
+// v4l2Format mirrors struct v4l2_format. The kernel union holds pointers, so
+// it is aligned like one.
+type v4l2Format struct {
+	typ uint32
+	fmt struct {
+		_   [0]uintptr
+		pix v4l2PixFormat
+		_   [200 - unsafe.Sizeof(v4l2PixFormat{})]byte
+	}
+}
+
+type v4l2RequestBuffers struct {
+	count        uint32
+	typ          uint32
+	memory       uint32
+	capabilities uint32
+	flags        uint8
+	reserved     [3]uint8
+}
+
+type v4l2Timecode struct {
+	typ      uint32
+	flags    uint32
+	frames   uint8
+	seconds  uint8
+	minutes  uint8
+	hours    uint8
+	userbits [4]uint8
+}
+
+type v4l2Buffer struct {
+	index     uint32
+	typ       uint32
+	bytesused uint32
+	flags     uint32
+	field     uint32
+	timestamp syscall.Timeval
+	timecode  v4l2Timecode
+	sequence  uint32
+	memory    uint32
+	offset    uintptr // union m; unsigned long sized
+	length    uint32
+	reserved2 uint32
+	requestFD uint32
+}
+
+const (
+	iocWrite = 1
+	iocRead  = 2
+)
+
+func ioc(dir, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | 'V'<<8 | nr
+}
+
+var (
+	vidiocQueryCap  = ioc(iocRead, 0, unsafe.Sizeof(v4l2Capability{}))
+	vidiocSFmt      = ioc(iocRead|iocWrite, 5, unsafe.Sizeof(v4l2Format{}))
+	vidiocReqBufs   = ioc(iocRead|iocWrite, 8, unsafe.Sizeof(v4l2RequestBuffers{}))
+	vidiocQueryBuf  = ioc(iocRead|iocWrite, 9, unsafe.Sizeof(v4l2Buffer{}))
+	vidiocQBuf      = ioc(iocRead|iocWrite, 15, unsafe.Sizeof(v4l2Buffer{}))
+	vidiocDQBuf     = ioc(iocRead|iocWrite, 17, unsafe.Sizeof(v4l2Buffer{}))
+	vidiocStreamOn  = ioc(iocWrite, 18, 4)
+	vidiocStreamOff = ioc(iocWrite, 19, 4)
+)
+
+func ioctl(fd, cmd uintptr, arg unsafe.Pointer) error {
+	for {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, cmd, uintptr(arg))
+		if errno == syscall.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return syscall.Errno(errno)
+		}
+		return nil
+	}
+}
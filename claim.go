@@ -0,0 +1,103 @@
+// Pin and bus claims.
+
+package embd
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ClaimError is returned when claiming a resource which is already claimed.
+type ClaimError struct {
+	Resource string
+	Owner    string
+}
+
+func (e *ClaimError) Error() string {
+	return fmt.Sprintf("embd: %v already claimed by %v", e.Resource, e.Owner)
+}
+
+type claim struct {
+	name, owner string
+}
+
+var claims = struct {
+	sync.Mutex
+	m map[interface{}]claim
+}{m: map[interface{}]claim{}}
+
+// resourceName describes a resource in the errors.
+func resourceName(resource interface{}) string {
+	switch r := resource.(type) {
+	case DigitalPin:
+		return fmt.Sprintf("pin %v", r.N())
+	case AnalogPin:
+		return fmt.Sprintf("analog pin %v", r.N())
+	case PWMPin:
+		return fmt.Sprintf("pwm pin %v", r.N())
+	}
+	return fmt.Sprint(resource)
+}
+
+// Claim records that owner, usually the name of a driver and of the role of
+// the resource in it like "hd44780 EN", uses a resource, so that two drivers
+// do not silently fight over the same line. The resource is a pin, or any
+// comparable value naming a bus or device, like "i2c-1 0x27". Claiming a
+// resource which is already claimed, even by another instance of the same
+// driver, returns a *ClaimError.
+//
+// Pins are unclaimed when they are closed.
+func Claim(resource interface{}, owner string) error {
+	claims.Lock()
+	defer claims.Unlock()
+
+	if c, ok := claims.m[resource]; ok {
+		return &ClaimError{Resource: c.name, Owner: c.owner}
+	}
+	claims.m[resource] = claim{name: resourceName(resource), owner: owner}
+	return nil
+}
+
+// Unclaim releases the claim on a resource.
+func Unclaim(resource interface{}) {
+	claims.Lock()
+	defer claims.Unlock()
+
+	delete(claims.m, resource)
+}
+
+// Owner returns the owner of a claimed resource.
+func Owner(resource interface{}) (string, bool) {
+	claims.Lock()
+	defer claims.Unlock()
+
+	c, ok := claims.m[resource]
+	return c.owner, ok
+}
+
+// Claims returns the owners of the claimed resources, by resource, as
+// described in the errors.
+func Claims() map[string]string {
+	claims.Lock()
+	defer claims.Unlock()
+
+	out := make(map[string]string, len(claims.m))
+	for _, c := range claims.m {
+		out[c.name] = c.owner
+	}
+	return out
+}
+
+type i2cDevice struct {
+	bus  I2CBus
+	addr byte
+}
+
+func (d i2cDevice) String() string {
+	return fmt.Sprintf("i2c device %#02x", d.addr)
+}
+
+// I2CDevice returns the resource to claim for the device at addr on bus.
+func I2CDevice(bus I2CBus, addr byte) interface{} {
+	return i2cDevice{bus, addr}
+}
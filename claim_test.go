@@ -0,0 +1,48 @@
+package embd
+
+import "testing"
+
+func TestClaim(t *testing.T) {
+	pinMap := PinMap{
+		&PinDesc{ID: "P1_11", Aliases: []string{"17"}, Caps: CapDigital, DigitalLogical: 17},
+	}
+	driver := NewGPIODriver(pinMap, newFakeDigitalPin, nil, nil)
+	pin, err := driver.DigitalPin(17)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Claim(pin, "hd44780 EN"); err != nil {
+		t.Fatal(err)
+	}
+	same, _ := driver.DigitalPin(17)
+	err = Claim(same, "wiegand D0")
+	if err == nil || err.Error() != "embd: pin 17 already claimed by hd44780 EN" {
+		t.Errorf("claiming again: %v", err)
+	}
+	if owner, _ := Owner(pin); owner != "hd44780 EN" {
+		t.Errorf("Owner() = %q", owner)
+	}
+	if claims := Claims(); claims["pin 17"] != "hd44780 EN" {
+		t.Errorf("Claims() = %v", claims)
+	}
+
+	// Closing the pin releases the claim.
+	pin.Close()
+	if _, ok := Owner(pin); ok {
+		t.Error("pin still claimed after Close")
+	}
+	if err := Claim(pin, "wiegand D0"); err != nil {
+		t.Error(err)
+	}
+	Unclaim(pin)
+
+	dev := I2CDevice(nil, 0x27)
+	if err := Claim(dev, "hd44780"); err != nil {
+		t.Fatal(err)
+	}
+	defer Unclaim(dev)
+	if err := Claim(I2CDevice(nil, 0x27), "pcf8574"); err == nil || err.Error() != "embd: i2c device 0x27 already claimed by hd44780" {
+		t.Errorf("claiming the i2c device again: %v", err)
+	}
+}
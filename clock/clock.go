@@ -0,0 +1,89 @@
+/*
+Package clock abstracts the passing of time, so that the timing of drivers
+can be tested without waiting for it.
+
+Drivers take a Clock, Real by default, instead of calling the time package:
+
+	c := clock.NewVirtual(time.Time{})
+	b := hotkey.NewButton(pin)
+	b.Clock = c
+	...
+	c.Advance(time.Second) // the long press fires at once
+
+The Virtual clock only moves when it is advanced, firing the timers, tickers
+and sleeps due in the meantime in order.
+*/
+package clock
+
+import "time"
+
+// Clock tells the time and waits for it to pass.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+	// AfterFunc calls f once d has passed.
+	AfterFunc(d time.Duration, f func()) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a single event, like a time.Timer.
+type Timer interface {
+	// C returns the channel the time is sent on when the timer fires. It is
+	// nil for the timers of AfterFunc.
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is a periodic event, like a time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the clock of the time package.
+var Real Clock = realClock{}
+
+// Or returns c, or Real when c is nil, for drivers with an optional clock.
+func Or(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
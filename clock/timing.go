@@ -0,0 +1,79 @@
+// Timing strategies.
+
+package clock
+
+import "time"
+
+// DefaultSpinThreshold is the spin threshold of Precise by default, above
+// how late the scheduler usually wakes a sleeping goroutine on Linux.
+const DefaultSpinThreshold = 100 * time.Microsecond
+
+// Precise is the real clock, sleeping precisely for the short delays of
+// bit-banged buses: it spins for the delays below SpinThreshold, which
+// time.Sleep would round up to tens of microseconds, and sleeps for the
+// longer ones, spinning the last SpinThreshold. Spinning burns the CPU it
+// runs on, so Precise suits drivers waiting microseconds, like the HD44780
+// between pulses:
+//
+//	hd44780.Clock = clock.Precise{}
+type Precise struct {
+	realClock
+
+	// SpinThreshold is DefaultSpinThreshold when zero.
+	SpinThreshold time.Duration
+}
+
+// Sleep waits for d, spinning the end of it.
+func (p Precise) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	end := time.Now().Add(d)
+	threshold := p.SpinThreshold
+	if threshold <= 0 {
+		threshold = DefaultSpinThreshold
+	}
+	if d > threshold {
+		time.Sleep(d - threshold)
+	}
+	for time.Now().Before(end) {
+	}
+}
+
+// Pacer batches the delays a device needs between operations: Delay only
+// adds them up, and Wait sleeps what is left of them before the next
+// operation. The time spent in between counts against the delays; called
+// as an operation starts, Delay lets a pin write slower than the delay
+// following it go without any sleep:
+//
+//	for _, v := range levels {
+//		p.Wait()
+//		p.Delay(pulseDelay)
+//		if err := pin.Write(v); err != nil {
+//			return err
+//		}
+//	}
+type Pacer struct {
+	clock Clock
+	ready time.Time
+}
+
+// NewPacer creates a new Pacer timed by c, or Real when c is nil.
+func NewPacer(c Clock) *Pacer {
+	return &Pacer{clock: Or(c)}
+}
+
+// Delay adds d to the delays to wait before the next operation.
+func (p *Pacer) Delay(d time.Duration) {
+	if now := p.clock.Now(); p.ready.Before(now) {
+		p.ready = now
+	}
+	p.ready = p.ready.Add(d)
+}
+
+// Wait sleeps what is left of the delays.
+func (p *Pacer) Wait() {
+	if d := p.ready.Sub(p.clock.Now()); d > 0 {
+		p.clock.Sleep(d)
+	}
+}
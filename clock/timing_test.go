@@ -0,0 +1,54 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPrecise(t *testing.T) {
+	p := Precise{SpinThreshold: 200 * time.Microsecond}
+	for _, d := range []time.Duration{5 * time.Microsecond, time.Millisecond} {
+		start := time.Now()
+		p.Sleep(d)
+		if elapsed := time.Since(start); elapsed < d {
+			t.Errorf("Sleep(%v) returned after %v", d, elapsed)
+		}
+	}
+}
+
+func TestPacer(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	v := NewVirtual(start)
+	v.AutoAdvance = true
+	p := NewPacer(v)
+
+	// The delays add up, and are waited at once.
+	p.Delay(time.Microsecond)
+	p.Delay(2 * time.Microsecond)
+	p.Wait()
+	if got := v.Now().Sub(start); got != 3*time.Microsecond {
+		t.Errorf("waited %v, want 3µs", got)
+	}
+	p.Wait()
+	if got := v.Now().Sub(start); got != 3*time.Microsecond {
+		t.Errorf("waited %v again, want nothing", got-3*time.Microsecond)
+	}
+
+	// Time spent in between counts against the delay.
+	p.Delay(5 * time.Microsecond)
+	v.Advance(2 * time.Microsecond)
+	p.Wait()
+	if got := v.Now().Sub(start); got != 8*time.Microsecond {
+		t.Errorf("waited until %v, want 8µs", got)
+	}
+
+	// A delay already elapsed is not waited.
+	p.Delay(time.Microsecond)
+	v.Advance(10 * time.Microsecond)
+	p.Wait()
+	p.Delay(time.Microsecond)
+	p.Wait()
+	if got := v.Now().Sub(start); got != 19*time.Microsecond {
+		t.Errorf("waited until %v, want 19µs", got)
+	}
+}
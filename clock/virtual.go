@@ -0,0 +1,243 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Virtual is a clock moving only when it is advanced, for tests.
+type Virtual struct {
+	// AutoAdvance makes Sleep advance the clock itself rather than wait for
+	// it, for drivers sleeping in the goroutine of the test, like the
+	// HD44780 between writes.
+	AutoAdvance bool
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	seq     int
+	waiters []*waiter
+}
+
+// NewVirtual creates a new virtual clock set to start.
+func NewVirtual(start time.Time) *Virtual {
+	v := &Virtual{now: start}
+	v.cond = sync.NewCond(&v.mu)
+	return v
+}
+
+// waiter is a pending timer, ticker or sleep.
+type waiter struct {
+	v      *Virtual
+	at     time.Time
+	seq    int
+	period time.Duration // zero for timers
+	c      chan time.Time
+	f      func()
+}
+
+// Now returns the virtual time.
+func (v *Virtual) Now() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.now
+}
+
+// Sleep blocks until the clock is advanced by d, or advances it by d with
+// AutoAdvance.
+func (v *Virtual) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	if v.AutoAdvance {
+		v.Advance(d)
+		return
+	}
+	<-v.After(d)
+}
+
+// After returns a channel receiving the time once the clock is advanced by
+// d.
+func (v *Virtual) After(d time.Duration) <-chan time.Time {
+	return v.NewTimer(d).C()
+}
+
+// NewTimer creates a new timer firing once the clock is advanced by d.
+func (v *Virtual) NewTimer(d time.Duration) Timer {
+	w := &waiter{v: v, c: make(chan time.Time, 1)}
+	v.schedule(w, d)
+	return virtualTimer{w}
+}
+
+// AfterFunc calls f once the clock is advanced by d. f is called by Advance,
+// so that its effects are visible once Advance returns.
+func (v *Virtual) AfterFunc(d time.Duration, f func()) Timer {
+	w := &waiter{v: v, f: f}
+	v.schedule(w, d)
+	return virtualTimer{w}
+}
+
+// NewTicker creates a new ticker firing every time the clock is advanced by
+// d.
+func (v *Virtual) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	w := &waiter{v: v, period: d, c: make(chan time.Time, 1)}
+	v.schedule(w, d)
+	return virtualTicker{w}
+}
+
+// schedule adds or moves a waiter, and returns whether it was pending. Like
+// with the time package, timers due already fire at once; the functions of
+// AfterFunc are still only called by Advance, as the caller may hold locks
+// they take.
+func (v *Virtual) schedule(w *waiter, d time.Duration) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	pending := v.remove(w)
+	if d <= 0 && w.period == 0 && w.f == nil {
+		select {
+		case w.c <- v.now:
+		default:
+		}
+		return pending
+	}
+	v.seq++
+	w.at, w.seq = v.now.Add(d), v.seq
+	v.waiters = append(v.waiters, w)
+	v.cond.Broadcast()
+	return pending
+}
+
+// remove removes a waiter, and returns whether it was pending. It must be
+// called with the lock held.
+func (v *Virtual) remove(w *waiter) bool {
+	for i, other := range v.waiters {
+		if other == w {
+			v.waiters = append(v.waiters[:i], v.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Virtual) stop(w *waiter) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.remove(w)
+}
+
+// due returns the first waiter due by end, in the order they are due and
+// then were scheduled. It must be called with the lock held.
+func (v *Virtual) due(end time.Time) *waiter {
+	var first *waiter
+	for _, w := range v.waiters {
+		if w.at.After(end) {
+			continue
+		}
+		if first == nil || w.at.Before(first.at) || w.at.Equal(first.at) && w.seq < first.seq {
+			first = w
+		}
+	}
+	return first
+}
+
+// Advance moves the clock forward by d, firing the timers, tickers and
+// sleeps due in the meantime in order. The time is sent on channels without
+// blocking: like with the time package, the ticks of a ticker not being
+// read are dropped.
+func (v *Virtual) Advance(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	v.mu.Lock()
+	end := v.now.Add(d)
+	for {
+		w := v.due(end)
+		if w == nil {
+			break
+		}
+		v.now = w.at
+		if w.period > 0 {
+			v.seq++
+			w.at, w.seq = w.at.Add(w.period), v.seq
+		} else {
+			v.remove(w)
+		}
+		now := v.now
+		v.mu.Unlock()
+
+		if w.f != nil {
+			w.f()
+		} else {
+			select {
+			case w.c <- now:
+			default:
+			}
+		}
+
+		v.mu.Lock()
+	}
+	if end.After(v.now) {
+		v.now = end
+	}
+	v.mu.Unlock()
+}
+
+// Set advances the clock to t, if it is later than the current time.
+func (v *Virtual) Set(t time.Time) {
+	v.Advance(t.Sub(v.Now()))
+}
+
+// Pending returns the number of timers, tickers and sleeps waiting for the
+// clock.
+func (v *Virtual) Pending() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return len(v.waiters)
+}
+
+// BlockUntil blocks until at least n timers, tickers and sleeps wait for the
+// clock, so that a test can advance it once the goroutines under test are
+// waiting.
+func (v *Virtual) BlockUntil(n int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for len(v.waiters) < n {
+		v.cond.Wait()
+	}
+}
+
+type virtualTimer struct {
+	*waiter
+}
+
+func (t virtualTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t virtualTimer) Stop() bool {
+	return t.v.stop(t.waiter)
+}
+
+func (t virtualTimer) Reset(d time.Duration) bool {
+	return t.v.schedule(t.waiter, d)
+}
+
+type virtualTicker struct {
+	*waiter
+}
+
+func (t virtualTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t virtualTicker) Stop() {
+	t.v.stop(t.waiter)
+}
@@ -0,0 +1,104 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVirtual_order(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	v := NewVirtual(start)
+
+	var fired []string
+	v.AfterFunc(30*time.Millisecond, func() { fired = append(fired, "c") })
+	v.AfterFunc(10*time.Millisecond, func() { fired = append(fired, "a") })
+	v.AfterFunc(20*time.Millisecond, func() {
+		fired = append(fired, "b")
+		// Timers scheduled while advancing fire in the same advance.
+		v.AfterFunc(5*time.Millisecond, func() { fired = append(fired, "b2") })
+	})
+	stopped := v.AfterFunc(15*time.Millisecond, func() { fired = append(fired, "stopped") })
+	if !stopped.Stop() {
+		t.Error("Stop of a pending timer returned false")
+	}
+
+	v.Advance(25 * time.Millisecond)
+	if got := v.Now(); !got.Equal(start.Add(25 * time.Millisecond)) {
+		t.Errorf("Now: got %v, want %v", got, start.Add(25*time.Millisecond))
+	}
+	want := []string{"a", "b", "b2"}
+	if len(fired) != len(want) {
+		t.Fatalf("fired %v, want %v", fired, want)
+	}
+	for i := range want {
+		if fired[i] != want[i] {
+			t.Fatalf("fired %v, want %v", fired, want)
+		}
+	}
+	if n := v.Pending(); n != 1 {
+		t.Errorf("Pending: got %v, want 1", n)
+	}
+}
+
+func TestVirtual_ticker(t *testing.T) {
+	v := NewVirtual(time.Time{})
+	tk := v.NewTicker(time.Second)
+	defer tk.Stop()
+
+	v.Advance(time.Second)
+	if got := <-tk.C(); !got.Equal(time.Time{}.Add(time.Second)) {
+		t.Errorf("tick at %v, want 1s", got)
+	}
+	// Ticks not read are dropped.
+	v.Advance(3 * time.Second)
+	if got := <-tk.C(); !got.Equal(time.Time{}.Add(2 * time.Second)) {
+		t.Errorf("tick at %v, want 2s", got)
+	}
+	select {
+	case got := <-tk.C():
+		t.Errorf("unexpected tick at %v", got)
+	default:
+	}
+}
+
+func TestVirtual_sleep(t *testing.T) {
+	v := NewVirtual(time.Time{})
+	done := make(chan time.Time)
+	go func() {
+		v.Sleep(time.Minute)
+		done <- v.Now()
+	}()
+
+	v.BlockUntil(1)
+	v.Advance(time.Minute)
+	if got := <-done; !got.Equal(time.Time{}.Add(time.Minute)) {
+		t.Errorf("woke at %v, want 1m", got)
+	}
+
+	v.AutoAdvance = true
+	v.Sleep(time.Hour)
+	if got := v.Now(); !got.Equal(time.Time{}.Add(time.Hour + time.Minute)) {
+		t.Errorf("auto advance: got %v, want 1h1m", got)
+	}
+}
+
+func TestVirtual_timerReset(t *testing.T) {
+	v := NewVirtual(time.Time{})
+	tm := v.NewTimer(time.Second)
+	v.Advance(500 * time.Millisecond)
+	if !tm.Reset(time.Second) {
+		t.Error("Reset of a pending timer returned false")
+	}
+	v.Advance(time.Second - time.Millisecond)
+	select {
+	case <-tm.C():
+		t.Fatal("timer fired before its reset deadline")
+	default:
+	}
+	v.Advance(time.Millisecond)
+	<-tm.C()
+
+	if tm.Reset(0); len(tm.C()) != 1 {
+		t.Error("timer reset to zero did not fire at once")
+	}
+}
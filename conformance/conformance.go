@@ -0,0 +1,94 @@
+/*
+Package conformance tests the driver providers registered with
+embd.RegisterProvider, so that out-of-tree drivers can check they behave
+like the built-in ones. A driver package runs it from one of its tests:
+
+	import (
+		"testing"
+
+		"github.com/kidoman/embd/conformance"
+		_ "example.com/embd-pinecone"
+	)
+
+	func TestConformance(t *testing.T) {
+		conformance.Run(t)
+	}
+*/
+package conformance
+
+import (
+	"io"
+	"testing"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+	"github.com/kidoman/embd/interface/display/graphics"
+)
+
+// Run tests all the registered providers.
+func Run(t *testing.T) {
+	for _, kind := range []embd.ProviderKind{embd.HostProviders, embd.BusProviders, embd.DisplayProviders} {
+		for _, name := range embd.Providers(kind) {
+			p, _ := embd.LookupProvider(kind, name)
+			Provider(t, p)
+		}
+	}
+}
+
+// Provider tests a provider. Bus and display providers are only opened when
+// they are a Sampler, as the others need hardware.
+func Provider(t *testing.T, p embd.Provider) {
+	name, kind := p.Name(), p.Kind()
+	if name == "" {
+		t.Errorf("%v provider without a name", kind)
+	}
+	switch kind {
+	case embd.HostProviders:
+		hp, ok := p.(embd.HostProvider)
+		if !ok {
+			t.Errorf("host provider %v is not a HostProvider", name)
+			return
+		}
+		rev, _ := hp.Detect()
+		if hp.Describe(rev) == nil {
+			t.Errorf("host provider %v: no descriptor for rev %v", name, rev)
+		}
+	case embd.BusProviders, embd.DisplayProviders:
+		op, ok := p.(embd.OpenProvider)
+		if !ok {
+			t.Errorf("%v provider %v is not an OpenProvider", kind, name)
+			return
+		}
+		s, ok := p.(embd.Sampler)
+		if !ok {
+			t.Logf("%v provider %v is not a Sampler, not opening it", kind, name)
+			return
+		}
+		dev, err := op.Open(s.SampleParams())
+		if err != nil {
+			t.Errorf("%v provider %v: opening: %v", kind, name, err)
+			return
+		}
+		if !implements(kind, dev) {
+			t.Errorf("%v provider %v: opened a %T", kind, name, dev)
+		}
+		if c, ok := dev.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				t.Errorf("%v provider %v: closing: %v", kind, name, err)
+			}
+		}
+	default:
+		t.Errorf("provider %v of unknown kind %q", name, kind)
+	}
+}
+
+// implements returns whether dev is one of the devices of the provider kind.
+func implements(kind embd.ProviderKind, dev interface{}) bool {
+	switch dev.(type) {
+	case embd.I2CBus, embd.SPIBus, embd.DigitalBus, io.ReadWriter:
+		return kind == embd.BusProviders
+	case characterdisplay.Controller, graphics.Display:
+		return kind == embd.DisplayProviders
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package conformance
+
+import (
+	"testing"
+
+	"github.com/kidoman/embd"
+	_ "github.com/kidoman/embd/controller/hd44780"
+)
+
+type loopback struct{ buf []byte }
+
+func (l *loopback) Read(p []byte) (int, error) {
+	n := copy(p, l.buf)
+	l.buf = l.buf[n:]
+	return n, nil
+}
+
+func (l *loopback) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	return len(p), nil
+}
+
+type loopbackProvider struct{}
+
+func (loopbackProvider) Name() string                                 { return "loopback" }
+func (loopbackProvider) Kind() embd.ProviderKind                      { return embd.BusProviders }
+func (loopbackProvider) Open(params embd.Params) (interface{}, error) { return &loopback{}, nil }
+func (loopbackProvider) SampleParams() embd.Params                    { return nil }
+
+func init() {
+	if err := embd.RegisterProvider(loopbackProvider{}); err != nil {
+		panic(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	Run(t)
+}
@@ -0,0 +1,195 @@
+// Context support.
+
+package embd
+
+import (
+	"context"
+	"time"
+)
+
+// I2CTimeoutSetter is implemented by the I²C buses which can bound the
+// transfers of the kernel, like the buses of the generic host.
+type I2CTimeoutSetter interface {
+	// SetTimeout sets how long the kernel waits for a transfer, for all
+	// the users of the bus.
+	SetTimeout(d time.Duration) error
+}
+
+// PulseTimerContext is implemented by the pins which can stop timing a
+// pulse once a context is done, like the pins of the generic host.
+type PulseTimerContext interface {
+	TimePulseContext(ctx context.Context, state int) (time.Duration, error)
+}
+
+// TimePulseContext measures the duration of a pulse on the pin, and gives
+// up with the error of ctx once it is done. Pins which are not a
+// PulseTimerContext are read until the pulse ends, checking ctx between the
+// reads, so that nothing keeps reading the pin once it has given up.
+func TimePulseContext(ctx context.Context, pin DigitalPin, state int) (time.Duration, error) {
+	if p, ok := pin.(PulseTimerContext); ok {
+		return p.TimePulseContext(ctx, state)
+	}
+
+	around := Low
+	if state == Low {
+		around = High
+	}
+
+	// wait reads the pin until it is at the level v.
+	wait := func(v int) error {
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			cur, err := pin.Read()
+			if err != nil {
+				return err
+			}
+			if cur == v {
+				return nil
+			}
+		}
+	}
+
+	// The pulse is timed from its start, not from the middle of a pulse
+	// already going on.
+	if err := wait(around); err != nil {
+		return 0, err
+	}
+	if err := wait(state); err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	if err := wait(around); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+type contextI2CBus struct {
+	bus I2CBus
+	ctx context.Context
+}
+
+// I2CBusContext returns a view of the bus bounded by ctx: its transactions
+// fail with the error of ctx once it is done. The context is only checked
+// before each transaction: a transaction already in flight is neither
+// cancelled nor given the deadline of ctx, and runs to its end, bounded only
+// by the timeout of the bus (see I2CTimeoutSetter), which is shared by all
+// its users and left alone. Closing the view does not close the bus.
+func I2CBusContext(ctx context.Context, bus I2CBus) I2CBus {
+	return &contextI2CBus{bus: bus, ctx: ctx}
+}
+
+func (b *contextI2CBus) ReadByte(addr byte) (byte, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return b.bus.ReadByte(addr)
+}
+
+func (b *contextI2CBus) WriteByte(addr, value byte) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	return b.bus.WriteByte(addr, value)
+}
+
+func (b *contextI2CBus) WriteBytes(addr byte, value []byte) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	return b.bus.WriteBytes(addr, value)
+}
+
+func (b *contextI2CBus) ReadFromReg(addr, reg byte, value []byte) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	return b.bus.ReadFromReg(addr, reg, value)
+}
+
+func (b *contextI2CBus) ReadByteFromReg(addr, reg byte) (byte, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return b.bus.ReadByteFromReg(addr, reg)
+}
+
+func (b *contextI2CBus) ReadWordFromReg(addr, reg byte) (uint16, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return b.bus.ReadWordFromReg(addr, reg)
+}
+
+func (b *contextI2CBus) WriteToReg(addr, reg byte, value []byte) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	return b.bus.WriteToReg(addr, reg, value)
+}
+
+func (b *contextI2CBus) WriteByteToReg(addr, reg, value byte) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	return b.bus.WriteByteToReg(addr, reg, value)
+}
+
+func (b *contextI2CBus) WriteWordToReg(addr, reg byte, value uint16) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	return b.bus.WriteWordToReg(addr, reg, value)
+}
+
+func (b *contextI2CBus) Close() error {
+	return nil
+}
+
+type contextSPIBus struct {
+	bus SPIBus
+	ctx context.Context
+}
+
+// SPIBusContext returns a view of the bus whose transfers fail with the
+// error of ctx once it is done. The context is only checked before each
+// transfer: a transfer already in flight is neither cancelled nor given the
+// deadline of ctx, as the kernel transfers of SPI cannot be. Closing the
+// view does not close the bus.
+func SPIBusContext(ctx context.Context, bus SPIBus) SPIBus {
+	return &contextSPIBus{bus: bus, ctx: ctx}
+}
+
+func (b *contextSPIBus) TransferAndRecieveData(dataBuffer []uint8) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+	return b.bus.TransferAndRecieveData(dataBuffer)
+}
+
+func (b *contextSPIBus) ReceiveData(len int) ([]uint8, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return b.bus.ReceiveData(len)
+}
+
+func (b *contextSPIBus) TransferAndReceiveByte(data byte) (byte, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return b.bus.TransferAndReceiveByte(data)
+}
+
+func (b *contextSPIBus) ReceiveByte() (byte, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return b.bus.ReceiveByte()
+}
+
+func (b *contextSPIBus) Close() error {
+	return nil
+}
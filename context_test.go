@@ -0,0 +1,87 @@
+package embd
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type timeoutBus struct {
+	I2CBus
+
+	timeout time.Duration
+	writes  int
+}
+
+func (b *timeoutBus) SetTimeout(d time.Duration) error {
+	b.timeout = d
+	return nil
+}
+
+func (b *timeoutBus) WriteByte(addr, value byte) error {
+	b.writes++
+	return nil
+}
+
+func TestI2CBusContext(t *testing.T) {
+	b := &timeoutBus{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	bus := I2CBusContext(ctx, b)
+
+	if err := bus.WriteByte(0x20, 1); err != nil {
+		t.Fatal(err)
+	}
+	if b.writes != 1 {
+		t.Errorf("got %v writes, want 1", b.writes)
+	}
+	// The timeout of the bus is shared with its other users.
+	if b.timeout != 0 {
+		t.Errorf("view set the timeout of the bus to %v", b.timeout)
+	}
+
+	cancel()
+	if err := bus.WriteByte(0x20, 1); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if b.writes != 1 {
+		t.Errorf("wrote %v times after cancelling, want 1", b.writes)
+	}
+}
+
+type stuckPin struct {
+	DigitalPin
+
+	mu    sync.Mutex
+	reads int
+}
+
+func (p *stuckPin) Read() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reads++
+	return Low, nil
+}
+
+func (p *stuckPin) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reads
+}
+
+func TestTimePulseContext(t *testing.T) {
+	p := &stuckPin{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := TimePulseContext(ctx, p, High); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	// Nothing keeps reading the pin once it has given up.
+	reads := p.count()
+	time.Sleep(10 * time.Millisecond)
+	if n := p.count(); n != reads {
+		t.Errorf("pin read %v times after giving up", n-reads)
+	}
+}
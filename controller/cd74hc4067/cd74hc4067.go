@@ -0,0 +1,226 @@
+/*
+Package cd74hc4067 allows interfacing with the CD74HC4067 16 channel analog
+multiplexer, and its 8 channel siblings like the CD74HC4051, to read many
+sensors with one ADC input.
+
+The channels are pins of their own, selecting the channel before each use:
+
+	mux := cd74hc4067.New([]embd.DigitalPin{s0, s1, s2, s3}, nil)
+	adc, _ := embd.NewAnalogPin("AIN0")
+	for ch := 0; ch < mux.Channels(); ch++ {
+		v, _ := mux.AnalogChannel(adc, ch).Read()
+		...
+	}
+
+The channels of a multiplexer can be used from several goroutines, the
+multiplexer stays on a channel for the whole of a read or write.
+*/
+package cd74hc4067
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// DefaultSettle is the default time given to the common signal to settle
+// after switching channels, covering the switching time of the chip and the
+// charge of a typical sample and hold capacitor through the channel
+// resistance.
+const DefaultSettle = 10 * time.Microsecond
+
+// Mux represents a multiplexer.
+type Mux struct {
+	// Select are the select pins, S0 first.
+	Select []embd.DigitalPin
+	// Enable is the active low enable pin, nil if it is tied low.
+	Enable embd.DigitalPin
+	// Settle is the time to wait after switching channels.
+	Settle time.Duration
+
+	mu      sync.Mutex
+	bus     embd.DigitalBus
+	channel int
+
+	initialized bool
+}
+
+// New creates a new multiplexer switched by the select pins, S0 first, and
+// the optional enable pin.
+func New(sel []embd.DigitalPin, enable embd.DigitalPin) *Mux {
+	return &Mux{Select: sel, Enable: enable, Settle: DefaultSettle, channel: -1}
+}
+
+// Channels returns the number of channels of the multiplexer.
+func (m *Mux) Channels() int {
+	return 1 << uint(len(m.Select))
+}
+
+func (m *Mux) setup() error {
+	if m.initialized {
+		return nil
+	}
+
+	for _, pin := range m.Select {
+		if err := pin.SetDirection(embd.Out); err != nil {
+			return err
+		}
+	}
+	if m.Enable != nil {
+		if err := m.Enable.SetDirection(embd.Out); err != nil {
+			return err
+		}
+		if err := m.Enable.Write(embd.Low); err != nil {
+			return err
+		}
+	}
+	m.bus = embd.NewDigitalBus(m.Select...)
+
+	m.initialized = true
+
+	return nil
+}
+
+// choose switches to the channel if needed. It must be called with the lock
+// held.
+func (m *Mux) choose(ch int) error {
+	if ch < 0 || ch >= m.Channels() {
+		return fmt.Errorf("cd74hc4067: channel %v out of range [0, %v)", ch, m.Channels())
+	}
+	if err := m.setup(); err != nil {
+		return err
+	}
+	if ch == m.channel {
+		return nil
+	}
+
+	glog.V(3).Infof("cd74hc4067: switching to channel %v", ch)
+
+	if err := m.bus.Write(uint32(ch)); err != nil {
+		// The select lines may be in any state.
+		m.channel = -1
+		return err
+	}
+	m.channel = ch
+	time.Sleep(m.Settle)
+	return nil
+}
+
+// SetChannel switches to the channel, waiting for the signal to settle.
+func (m *Mux) SetChannel(ch int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.choose(ch)
+}
+
+// AnalogChannel returns the channel ch of the multiplexer, read through the
+// ADC pin its common signal is wired to.
+func (m *Mux) AnalogChannel(pin embd.AnalogPin, ch int) embd.AnalogPin {
+	return &analogChannel{m: m, pin: pin, ch: ch}
+}
+
+// DigitalChannel returns the channel ch of the multiplexer, driven or read
+// through the GPIO pin its common signal is wired to. The direction and
+// pulls of the pin apply to all the channels, and the channels cannot be
+// watched.
+func (m *Mux) DigitalChannel(pin embd.DigitalPin, ch int) embd.DigitalPin {
+	return &digitalChannel{DigitalPin: pin, m: m, ch: ch}
+}
+
+// Close disables the multiplexer, isolating the channels from the common
+// signal when it has an enable pin.
+func (m *Mux) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.channel = -1
+	if m.Enable == nil || !m.initialized {
+		return nil
+	}
+	m.initialized = false
+	return m.Enable.Write(embd.High)
+}
+
+type analogChannel struct {
+	m   *Mux
+	pin embd.AnalogPin
+	ch  int
+}
+
+func (c *analogChannel) N() int {
+	return c.ch
+}
+
+func (c *analogChannel) Read() (int, error) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+
+	if err := c.m.choose(c.ch); err != nil {
+		return 0, err
+	}
+	return c.pin.Read()
+}
+
+// Close leaves the common pin open, as it is shared by the other channels.
+func (c *analogChannel) Close() error {
+	return nil
+}
+
+type digitalChannel struct {
+	embd.DigitalPin
+
+	m  *Mux
+	ch int
+}
+
+func (c *digitalChannel) N() int {
+	return c.ch
+}
+
+func (c *digitalChannel) Read() (int, error) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+
+	if err := c.m.choose(c.ch); err != nil {
+		return 0, err
+	}
+	return c.DigitalPin.Read()
+}
+
+func (c *digitalChannel) Write(val int) error {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+
+	if err := c.m.choose(c.ch); err != nil {
+		return err
+	}
+	return c.DigitalPin.Write(val)
+}
+
+func (c *digitalChannel) TimePulse(state int) (time.Duration, error) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+
+	if err := c.m.choose(c.ch); err != nil {
+		return 0, err
+	}
+	return c.DigitalPin.TimePulse(state)
+}
+
+// Watch is not supported, the common pin only sees the selected channel.
+func (c *digitalChannel) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
+	return embd.ErrFeatureNotSupported
+}
+
+func (c *digitalChannel) StopWatching() error {
+	return nil
+}
+
+// Close leaves the common pin open, as it is shared by the other channels.
+func (c *digitalChannel) Close() error {
+	return nil
+}
@@ -0,0 +1,87 @@
+package cd74hc4067
+
+import (
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+type fakePin struct {
+	embd.DigitalPin
+
+	val    int
+	writes int
+}
+
+func (p *fakePin) SetDirection(embd.Direction) error { return nil }
+
+func (p *fakePin) Write(val int) error {
+	p.val = val
+	p.writes++
+	return nil
+}
+
+// fakeADC reads the voltage of the selected channel.
+type fakeADC struct {
+	sel    []*fakePin
+	values [16]int
+}
+
+func (a *fakeADC) channel() int {
+	ch := 0
+	for i, pin := range a.sel {
+		ch |= pin.val << uint(i)
+	}
+	return ch
+}
+
+func (a *fakeADC) N() int             { return 0 }
+func (a *fakeADC) Read() (int, error) { return a.values[a.channel()], nil }
+func (a *fakeADC) Close() error       { return nil }
+
+func TestAnalogChannels(t *testing.T) {
+	sel := []*fakePin{{}, {}, {}, {}}
+	enable := &fakePin{val: embd.High}
+	m := New([]embd.DigitalPin{sel[0], sel[1], sel[2], sel[3]}, enable)
+	m.Settle = 0
+	if m.Channels() != 16 {
+		t.Errorf("channels: got %v, want 16", m.Channels())
+	}
+
+	adc := &fakeADC{sel: sel}
+	for ch := range adc.values {
+		adc.values[ch] = 100 * ch
+	}
+	for ch := 15; ch >= 0; ch-- {
+		v, err := m.AnalogChannel(adc, ch).Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != 100*ch {
+			t.Errorf("channel %v: got %v, want %v", ch, v, 100*ch)
+		}
+	}
+	if enable.val != embd.Low {
+		t.Error("multiplexer not enabled")
+	}
+
+	// Reading the same channel again does not switch.
+	writes := sel[0].writes
+	c := m.AnalogChannel(adc, 0)
+	c.Read()
+	c.Read()
+	if sel[0].writes != writes {
+		t.Errorf("select pins written %v times reading the selected channel", sel[0].writes-writes)
+	}
+
+	if _, err := m.AnalogChannel(adc, 16).Read(); err == nil {
+		t.Error("no error for a channel out of range")
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if enable.val != embd.High {
+		t.Error("multiplexer not disabled")
+	}
+}
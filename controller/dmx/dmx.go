@@ -0,0 +1,183 @@
+/*
+Package dmx transmits a DMX512 universe, to control stage and architectural
+lighting.
+
+The universe is sent over an RS-485 transceiver wired to a serial port,
+which DMX drives at 250000 baud with 2 stop bits:
+
+	port, err := dmx.OpenPort("/dev/ttyAMA0")
+	if err != nil {
+		panic(err)
+	}
+	d := dmx.New(port)
+	d.Run()
+	defer d.Close()
+
+	d.Set(1, 255) // dimmer of the first fixture
+	d.SetSlots(2, 255, 128, 0) // its RGB color
+
+The whole universe is sent continuously, as the fixtures expect.
+*/
+package dmx
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/clock"
+)
+
+const (
+	// Slots is the number of slots of a universe.
+	Slots = 512
+
+	// The break and mark after break are longer than the 92µs and 12µs
+	// minimums, for the receivers which need more.
+	breakTime = 176 * time.Microsecond
+	markTime  = 16 * time.Microsecond
+
+	// A full universe takes 23ms to send, so it is refreshed at about
+	// 40Hz.
+	refreshDelay = 25 * time.Millisecond
+
+	startCode = 0x00
+)
+
+// A Port sends the frames of the universe.
+type Port interface {
+	io.Writer
+
+	// Break holds the line low for d, once the previous writes are sent.
+	Break(d time.Duration) error
+}
+
+// DMX represents a DMX512 transmitter.
+type DMX struct {
+	Port Port
+
+	// Refresh is the delay between the frames sent by Run.
+	Refresh time.Duration
+	// Clock times the mark after break and the frames sent by Run,
+	// clock.Real by default.
+	Clock clock.Clock
+
+	mu       sync.Mutex
+	universe [Slots]byte
+	frame    []byte
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// New creates a new DMX transmitter on the port.
+func New(port Port) *DMX {
+	return &DMX{Port: port, Refresh: refreshDelay, frame: make([]byte, Slots+1)}
+}
+
+func checkSlot(slot int) error {
+	if slot < 1 || slot > Slots {
+		return fmt.Errorf("dmx: slot %v is out of range [1, %v]", slot, Slots)
+	}
+	return nil
+}
+
+// Set sets the value of a slot, from 1 to 512.
+func (d *DMX) Set(slot int, value byte) error {
+	return d.SetSlots(slot, value)
+}
+
+// SetSlots sets the values of consecutive slots from start.
+func (d *DMX) SetSlots(start int, values ...byte) error {
+	if err := checkSlot(start); err != nil {
+		return err
+	}
+	if len(values) > 0 {
+		if err := checkSlot(start + len(values) - 1); err != nil {
+			return err
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	copy(d.universe[start-1:], values)
+	return nil
+}
+
+// Slot returns the value of a slot.
+func (d *DMX) Slot(slot int) (byte, error) {
+	if err := checkSlot(slot); err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.universe[slot-1], nil
+}
+
+// Blackout sets all the slots to zero.
+func (d *DMX) Blackout() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.universe = [Slots]byte{}
+}
+
+// Send sends the universe once: a break, a mark after break, and the start
+// code followed by the slots.
+func (d *DMX) Send() error {
+	d.mu.Lock()
+	d.frame[0] = startCode
+	copy(d.frame[1:], d.universe[:])
+	d.mu.Unlock()
+
+	if err := d.Port.Break(breakTime); err != nil {
+		return err
+	}
+	// The line idles high between the break and the start code.
+	clock.Or(d.Clock).Sleep(markTime)
+	_, err := d.Port.Write(d.frame)
+	return err
+}
+
+// Run starts sending the universe in the background.
+func (d *DMX) Run() {
+	d.quit = make(chan struct{})
+	d.done = make(chan struct{})
+
+	go func() {
+		defer close(d.done)
+
+		ticker := clock.Or(d.Clock).NewTicker(d.Refresh)
+		defer ticker.Stop()
+
+		for {
+			if err := d.Send(); err != nil {
+				glog.Errorf("dmx: %v", err)
+			}
+			select {
+			case <-ticker.C():
+			case <-d.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops sending the universe, and closes the port when it is an
+// io.Closer. The fixtures keep the last values they received.
+func (d *DMX) Close() error {
+	if d.quit != nil {
+		close(d.quit)
+		<-d.done
+		d.quit = nil
+	}
+	if c, ok := d.Port.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package dmx
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type fakePort struct {
+	calls  []string
+	frames [][]byte
+}
+
+func (p *fakePort) Write(data []byte) (int, error) {
+	p.calls = append(p.calls, "write")
+	p.frames = append(p.frames, append([]byte(nil), data...))
+	return len(data), nil
+}
+
+func (p *fakePort) Break(d time.Duration) error {
+	if d < 92*time.Microsecond {
+		p.calls = append(p.calls, "short break")
+		return nil
+	}
+	p.calls = append(p.calls, "break")
+	return nil
+}
+
+func TestSend(t *testing.T) {
+	port := &fakePort{}
+	d := New(port)
+
+	if err := d.Set(1, 255); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetSlots(510, 1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetSlots(511, 1, 2, 3); err == nil {
+		t.Error("no error setting slots past the universe")
+	}
+	if err := d.Set(0, 1); err == nil {
+		t.Error("no error setting slot 0")
+	}
+	if v, err := d.Slot(511); err != nil || v != 2 {
+		t.Errorf("Slot(511) = %v, %v; want 2", v, err)
+	}
+
+	if err := d.Send(); err != nil {
+		t.Fatal(err)
+	}
+	if len(port.calls) != 2 || port.calls[0] != "break" || port.calls[1] != "write" {
+		t.Fatalf("port calls: got %v, want a break and a write", port.calls)
+	}
+	frame := port.frames[0]
+	if len(frame) != Slots+1 {
+		t.Fatalf("frame length: got %v, want %v", len(frame), Slots+1)
+	}
+	if frame[0] != startCode || frame[1] != 255 || !bytes.Equal(frame[510:], []byte{1, 2, 3}) {
+		t.Errorf("frame: got start code %#x, slot 1 %v, slots 510-512 %v", frame[0], frame[1], frame[510:])
+	}
+
+	d.Blackout()
+	if err := d.Send(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(port.frames[1], make([]byte, Slots+1)) {
+		t.Error("frame after blackout: got non zero slots")
+	}
+}
@@ -0,0 +1,99 @@
+// Serial port support.
+
+package dmx
+
+import (
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	baudRate = 250000
+
+	// Linux serial ioctls, as on ARM and x86.
+	tcsbrk   = 0x5409
+	tiocsbrk = 0x5427
+	tioccbrk = 0x5428
+	tcgets2  = 0x802c542a
+	tcsets2  = 0x402c542b
+
+	cbaud   = 0010017
+	bother  = 0010000
+	csize   = 0000060
+	cs8     = 0000060
+	cstopb  = 0000100
+	cread   = 0000200
+	parenb  = 0000400
+	clocal  = 0004000
+	crtscts = 020000000000
+)
+
+type termios2 struct {
+	iflag, oflag, cflag, lflag uint32
+	line                       uint8
+	cc                         [19]uint8
+	ispeed, ospeed             uint32
+}
+
+// SerialPort is a serial port set up for DMX.
+type SerialPort struct {
+	file *os.File
+}
+
+func ioctl(f *os.File, req, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// OpenPort opens a serial port and sets it up for DMX: 250000 baud, 8 data
+// bits, no parity and 2 stop bits, in raw mode.
+func OpenPort(path string) (*SerialPort, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var t termios2
+	if err := ioctl(file, tcgets2, uintptr(unsafe.Pointer(&t))); err != nil {
+		file.Close()
+		return nil, err
+	}
+	t.iflag, t.oflag, t.lflag = 0, 0, 0
+	t.cflag &^= cbaud | csize | parenb | crtscts
+	t.cflag |= bother | cs8 | cstopb | cread | clocal
+	t.ispeed, t.ospeed = baudRate, baudRate
+	if err := ioctl(file, tcsets2, uintptr(unsafe.Pointer(&t))); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &SerialPort{file: file}, nil
+}
+
+// Write writes the data to the port.
+func (p *SerialPort) Write(data []byte) (int, error) {
+	return p.file.Write(data)
+}
+
+// Break waits for the previous writes to be sent, and holds the line low
+// for d.
+func (p *SerialPort) Break(d time.Duration) error {
+	// TCSBRK with a non zero argument waits for the output to drain.
+	if err := ioctl(p.file, tcsbrk, 1); err != nil {
+		return err
+	}
+	if err := ioctl(p.file, tiocsbrk, 0); err != nil {
+		return err
+	}
+	time.Sleep(d)
+	return ioctl(p.file, tioccbrk, 0)
+}
+
+// Close closes the port.
+func (p *SerialPort) Close() error {
+	return p.file.Close()
+}
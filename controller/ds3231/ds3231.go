@@ -0,0 +1,138 @@
+// Package ds3231 allows interfacing with the DS3231 real time clock.
+package ds3231
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/units"
+)
+
+// Addr is the I²C address of the DS3231.
+const Addr = 0x68
+
+const (
+	regSeconds = 0x00
+	regStatus  = 0x0F
+	regTemp    = 0x11
+
+	timeRegs = 7
+
+	hour12     = 0x40
+	hourPM     = 0x20
+	century    = 0x80
+	oscStopped = 0x80
+)
+
+// ErrStopped is returned when reading the time after the oscillator
+// stopped, from a flat backup battery for instance, until the time is set
+// again.
+var ErrStopped = errors.New("ds3231: oscillator stopped, time lost")
+
+// DS3231 represents a DS3231 real time clock. The time is kept in UTC.
+type DS3231 struct {
+	// Bus to communicate over.
+	Bus embd.I2CBus
+	// Addr of the clock.
+	Addr byte
+
+	mu sync.Mutex
+}
+
+// New creates a new DS3231 real time clock.
+func New(bus embd.I2CBus) *DS3231 {
+	return &DS3231{Bus: bus, Addr: Addr}
+}
+
+func bcd(v int) byte {
+	return byte(v/10<<4 | v%10)
+}
+
+func fromBCD(b byte) int {
+	return int(b>>4)*10 + int(b&0x0f)
+}
+
+// Time returns the time of the clock.
+func (d *DS3231) Time() (time.Time, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	status, err := d.Bus.ReadByteFromReg(d.Addr, regStatus)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if status&oscStopped != 0 {
+		return time.Time{}, ErrStopped
+	}
+
+	regs := make([]byte, timeRegs)
+	if err := d.Bus.ReadFromReg(d.Addr, regSeconds, regs); err != nil {
+		return time.Time{}, err
+	}
+	hour := fromBCD(regs[2] & 0x3f)
+	if regs[2]&hour12 != 0 {
+		hour = fromBCD(regs[2]&0x1f) % 12
+		if regs[2]&hourPM != 0 {
+			hour += 12
+		}
+	}
+	year := 2000 + fromBCD(regs[6])
+	if regs[5]&century != 0 {
+		year += 100
+	}
+	t := time.Date(year, time.Month(fromBCD(regs[5]&0x1f)), fromBCD(regs[4]&0x3f),
+		hour, fromBCD(regs[1]&0x7f), fromBCD(regs[0]&0x7f), 0, time.UTC)
+
+	glog.V(2).Infof("ds3231: read time %v", t)
+	return t, nil
+}
+
+// SetTime sets the clock to t, truncated to the second, and clears the
+// oscillator stop flag.
+func (d *DS3231) SetTime(t time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t = t.UTC()
+	glog.V(1).Infof("ds3231: setting time to %v", t)
+
+	month := bcd(int(t.Month()))
+	year := t.Year() - 2000
+	if year >= 100 {
+		year -= 100
+		month |= century
+	}
+	regs := []byte{
+		bcd(t.Second()),
+		bcd(t.Minute()),
+		bcd(t.Hour()),
+		byte(t.Weekday()) + 1,
+		bcd(t.Day()),
+		month,
+		bcd(year),
+	}
+	if err := d.Bus.WriteToReg(d.Addr, regSeconds, regs); err != nil {
+		return err
+	}
+	status, err := d.Bus.ReadByteFromReg(d.Addr, regStatus)
+	if err != nil {
+		return err
+	}
+	return d.Bus.WriteByteToReg(d.Addr, regStatus, status&^oscStopped)
+}
+
+// Temperature returns the temperature of the die, measured every 64 seconds
+// with a resolution of 0.25°C.
+func (d *DS3231) Temperature() (units.Temperature, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	regs := make([]byte, 2)
+	if err := d.Bus.ReadFromReg(d.Addr, regTemp, regs); err != nil {
+		return 0, err
+	}
+	return units.Temperature(int8(regs[0])) + units.Temperature(regs[1]>>6)*0.25, nil
+}
@@ -0,0 +1,80 @@
+package ds3231
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+)
+
+type fakeBus struct {
+	embd.I2CBus
+
+	regs [0x13]byte
+}
+
+func (b *fakeBus) ReadFromReg(addr, reg byte, value []byte) error {
+	copy(value, b.regs[reg:])
+	return nil
+}
+
+func (b *fakeBus) ReadByteFromReg(addr, reg byte) (byte, error) {
+	return b.regs[reg], nil
+}
+
+func (b *fakeBus) WriteToReg(addr, reg byte, value []byte) error {
+	copy(b.regs[reg:], value)
+	return nil
+}
+
+func (b *fakeBus) WriteByteToReg(addr, reg, value byte) error {
+	b.regs[reg] = value
+	return nil
+}
+
+func TestTime(t *testing.T) {
+	bus := &fakeBus{}
+	bus.regs[regStatus] = oscStopped
+	d := New(bus)
+
+	if _, err := d.Time(); err != ErrStopped {
+		t.Fatalf("Time() after power loss = %v; want ErrStopped", err)
+	}
+	want := time.Date(2026, time.February, 28, 23, 59, 58, 0, time.UTC)
+	if err := d.SetTime(want.Add(500 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := d.Time()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("Time() = %v; want %v", got, want)
+	}
+	if wd := bus.regs[3]; wd != byte(time.Saturday)+1 {
+		t.Errorf("day of the week = %v; want %v", wd, byte(time.Saturday)+1)
+	}
+
+	// 12 hour mode, 11 PM.
+	bus.regs[2] = hour12 | hourPM | 0x11
+	if got, _ := d.Time(); got.Hour() != 23 {
+		t.Errorf("Time() in 12 hour mode = %v; want 23h", got)
+	}
+}
+
+func TestTemperature(t *testing.T) {
+	bus := &fakeBus{}
+	d := New(bus)
+	for _, c := range []struct {
+		msb, lsb byte
+		want     float64
+	}{
+		{0x19, 0x40, 25.25},
+		{0xff, 0xc0, -0.25},
+	} {
+		bus.regs[regTemp], bus.regs[regTemp+1] = c.msb, c.lsb
+		if got, err := d.Temperature(); err != nil || got.Celsius() != c.want {
+			t.Errorf("Temperature() of %#02x %#02x = %v, %v; want %v", c.msb, c.lsb, got, err, c.want)
+		}
+	}
+}
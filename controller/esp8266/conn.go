@@ -0,0 +1,294 @@
+// Connections.
+
+package esp8266
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kidoman/embd"
+)
+
+const (
+	connectTimeout = 15 * time.Second
+
+	maxConns = 5
+
+	// The module sends and receives at most this many bytes at once.
+	maxChunk = 2048
+)
+
+// ErrClosed is returned when using a closed connection.
+var ErrClosed = embd.NewError(embd.ErrClosed, "esp8266: connection closed")
+
+// ErrNoConns is returned when all the connections of the module are in use.
+var ErrNoConns = errors.New("esp8266: too many connections")
+
+type addr struct {
+	network, address string
+}
+
+func (a addr) Network() string { return a.network }
+func (a addr) String() string  { return a.address }
+
+// Conn is a connection through the module. It implements net.Conn, but only
+// read deadlines are honored.
+type Conn struct {
+	d      *ESP8266
+	id     int
+	remote addr
+
+	mu       sync.Mutex
+	closed   bool
+	deadline time.Time
+	data     chan struct{}
+}
+
+// Dial connects to address ("host:port") on network "tcp", "udp" or "tls".
+func (d *ESP8266) Dial(network, address string) (*Conn, error) {
+	if err := d.setup(); err != nil {
+		return nil, err
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	var typ string
+	switch network {
+	case "tcp":
+		typ = "TCP"
+	case "udp":
+		typ = "UDP"
+	case "tls":
+		typ = "SSL"
+	default:
+		return nil, fmt.Errorf("esp8266: unsupported network %q", network)
+	}
+
+	c := &Conn{d: d, id: -1, remote: addr{network, address}, data: make(chan struct{}, 1)}
+	d.connMu.Lock()
+	for i := range d.conns {
+		if d.conns[i] == nil {
+			c.id = i
+			d.conns[i] = c
+			break
+		}
+	}
+	d.connMu.Unlock()
+	if c.id < 0 {
+		return nil, ErrNoConns
+	}
+
+	if _, err := d.command(fmt.Sprintf("+CIPSTART=%v,%q,%q,%v", c.id, typ, host, port), connectTimeout); err != nil {
+		c.release()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Conn) release() {
+	c.d.connMu.Lock()
+	defer c.d.connMu.Unlock()
+
+	if c.d.conns[c.id] == c {
+		c.d.conns[c.id] = nil
+	}
+}
+
+func (c *Conn) signal() {
+	select {
+	case c.data <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Conn) remoteClosed() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.signal()
+}
+
+func (c *Conn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed
+}
+
+// Read reads the data received on the connection, waiting for some when
+// there is none. It returns io.EOF once the remote end closed the
+// connection and all the data was read.
+func (c *Conn) Read(p []byte) (int, error) {
+	if len(p) > maxChunk {
+		p = p[:maxChunk]
+	}
+	for {
+		lines, err := c.d.command(fmt.Sprintf("+CIPRECVDATA=%v,%v", c.id, len(p)), c.d.Timeout)
+		if err != nil && !c.isClosed() {
+			return 0, err
+		}
+		for _, line := range lines {
+			if strings.HasPrefix(line, recvPrefix+":") {
+				if n := copy(p, line[len(recvPrefix)+1:]); n > 0 {
+					return n, nil
+				}
+			}
+		}
+
+		if c.isClosed() {
+			return 0, io.EOF
+		}
+		c.mu.Lock()
+		deadline := c.deadline
+		c.mu.Unlock()
+		if err := c.wait(deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// wait waits for data to be signaled, until deadline if it is set.
+func (c *Conn) wait(deadline time.Time) error {
+	if deadline.IsZero() {
+		<-c.data
+		return nil
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-c.data:
+		return nil
+	case <-timer.C:
+		return ErrTimeout
+	}
+}
+
+// Write sends data on the connection.
+func (c *Conn) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		if c.isClosed() {
+			return n, ErrClosed
+		}
+		chunk := p
+		if len(chunk) > maxChunk {
+			chunk = chunk[:maxChunk]
+		}
+		if _, err := c.d.exchange(fmt.Sprintf("AT+CIPSEND=%v,%v\r\n", c.id, len(chunk)), chunk, c.d.Timeout, okFinal); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	defer c.release()
+
+	if c.isClosed() {
+		return nil
+	}
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	_, err := c.d.command("+CIPCLOSE="+strconv.Itoa(c.id), c.d.Timeout)
+	return err
+}
+
+// LocalAddr implements net.Conn.
+func (c *Conn) LocalAddr() net.Addr {
+	return addr{c.remote.network, ""}
+}
+
+// RemoteAddr implements net.Conn.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline implements net.Conn.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline implements net.Conn.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deadline = t
+	return nil
+}
+
+// SetWriteDeadline implements net.Conn. Writes always wait for the module
+// for up to Timeout.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+type body struct {
+	io.ReadCloser
+	conn *Conn
+}
+
+func (b body) Close() error {
+	b.ReadCloser.Close()
+	return b.conn.Close()
+}
+
+// Get sends an HTTP (or HTTPS, without certificate verification) GET
+// request for rawurl. The connection is closed with the body of the
+// response.
+func (d *ESP8266) Get(rawurl string) (*http.Response, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	network, port := "tcp", "80"
+	switch u.Scheme {
+	case "http":
+	case "https":
+		network, port = "tls", "443"
+	default:
+		return nil, fmt.Errorf("esp8266: unsupported scheme %q", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Close = true
+
+	conn, err := d.Dial(network, host)
+	if err != nil {
+		return nil, err
+	}
+	// HTTP/1.0 keeps the response free of chunked encoding.
+	if _, err := fmt.Fprintf(conn, "GET %v HTTP/1.0\r\nHost: %v\r\nConnection: close\r\n\r\n", u.RequestURI(), u.Host); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body = body{resp.Body, conn}
+	return resp, nil
+}
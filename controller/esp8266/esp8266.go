@@ -0,0 +1,378 @@
+/*
+Package esp8266 allows using an ESP8266 or ESP32 module running the AT
+firmware as a Wi-Fi co-processor, for boards without networking.
+
+The module talks at 115200 baud, 8N1. The serial port is opened by the caller
+(any io.ReadWriter will do). Connections implement net.Conn, so they can be
+handed to HTTP and MQTT clients:
+
+	esp := esp8266.New(port)
+	if err := esp.Join("home", "secret"); err != nil {
+		panic(err)
+	}
+	conn, err := esp.Dial("tcp", "broker.local:1883")
+
+TLS connections ("tls" network) are handled by the module, which does not
+verify the server certificate.
+*/
+package esp8266
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+const (
+	commandTimeout = 5 * time.Second
+	joinTimeout    = 20 * time.Second
+
+	eventsBuffer = 16
+	linesBuffer  = 64
+
+	// The module asks for the data to send with this prompt, which is not
+	// followed by a line break.
+	prompt = ">"
+
+	// recvPrefix starts the answer to AT+CIPRECVDATA, which holds raw data.
+	recvPrefix = "+CIPRECVDATA"
+)
+
+// ErrError is returned when the module answers a command with ERROR or
+// FAIL.
+var ErrError = errors.New("esp8266: command failed")
+
+// ErrTimeout is returned when the module does not answer in time.
+var ErrTimeout = embd.NewError(embd.ErrTimeout, "esp8266: timed out waiting for the module")
+
+// EventType is the type of an unsolicited event from the module.
+type EventType int
+
+// The event types.
+const (
+	EventConnected EventType = iota
+	EventGotIP
+	EventDisconnected
+)
+
+// Event is an unsolicited event from the module.
+type Event struct {
+	Type EventType
+
+	// Line is the raw message.
+	Line string
+}
+
+// ESP8266 represents an ESP8266 or ESP32 module.
+type ESP8266 struct {
+	Port io.ReadWriter
+
+	// Timeout is the default time to wait for the answer to a command.
+	Timeout time.Duration
+
+	initialized bool
+	initMu      sync.RWMutex
+
+	// mu serializes the commands.
+	mu    sync.Mutex
+	lines chan string
+
+	events chan Event
+
+	connMu sync.Mutex
+	conns  [maxConns]*Conn
+
+	done    chan struct{}
+	readErr error
+}
+
+// New creates a new module interface. The port variable is the serial port
+// used to communicate with the device.
+func New(port io.ReadWriter) *ESP8266 {
+	return &ESP8266{
+		Port:    port,
+		Timeout: commandTimeout,
+		lines:   make(chan string, linesBuffer),
+		events:  make(chan Event, eventsBuffer),
+	}
+}
+
+// Events returns the channel receiving the Wi-Fi events of the module.
+// Events are dropped when they are not read.
+func (d *ESP8266) Events() <-chan Event {
+	return d.events
+}
+
+func (d *ESP8266) setup() error {
+	d.initMu.RLock()
+	if d.initialized {
+		d.initMu.RUnlock()
+		return nil
+	}
+	d.initMu.RUnlock()
+
+	d.initMu.Lock()
+	defer d.initMu.Unlock()
+
+	if d.done == nil {
+		d.done = make(chan struct{})
+		go d.read()
+	}
+
+	for _, cmd := range []string{
+		"E0",             // no echo
+		"+CWMODE=1",      // station
+		"+CIPMUX=1",      // multiple connections
+		"+CIPRECVMODE=1", // received data is read with AT+CIPRECVDATA
+	} {
+		if _, err := d.command(cmd, d.Timeout); err != nil {
+			return err
+		}
+	}
+
+	d.initialized = true
+
+	return nil
+}
+
+// readData reads the raw data following a "+CIPRECVDATA:<n>," or
+// "+CIPRECVDATA,<n>:" header.
+func readData(r *bufio.Reader, header string) (string, bool, error) {
+	if !strings.HasPrefix(header, recvPrefix) || len(header) < len(recvPrefix)+3 {
+		return "", false, nil
+	}
+	last := header[len(header)-1]
+	if last != ',' && last != ':' {
+		return "", false, nil
+	}
+	n, err := strconv.Atoi(header[len(recvPrefix)+1 : len(header)-1])
+	if err != nil {
+		return "", false, nil
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+func (d *ESP8266) read() {
+	defer close(d.done)
+
+	r := bufio.NewReader(d.Port)
+	var line []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			glog.Errorf("esp8266: reading: %v", err)
+			d.readErr = err
+			return
+		}
+		if b == '\n' {
+			if s := strings.TrimSpace(string(line)); s != "" {
+				d.dispatch(s)
+			}
+			line = line[:0]
+			continue
+		}
+		line = append(line, b)
+
+		if s := strings.TrimSpace(string(line)); s == prompt {
+			d.dispatch(prompt)
+			line = line[:0]
+			continue
+		}
+		data, ok, err := readData(r, string(line))
+		if err != nil {
+			glog.Errorf("esp8266: reading: %v", err)
+			d.readErr = err
+			return
+		}
+		if ok {
+			d.dispatch(recvPrefix + ":" + data)
+			line = line[:0]
+		}
+	}
+}
+
+func (d *ESP8266) dispatch(line string) {
+	glog.V(2).Infof("esp8266: received %q", line)
+
+	if d.handleEvent(line) {
+		return
+	}
+	select {
+	case d.lines <- line:
+	default:
+		glog.Warningf("esp8266: dropping %q", line)
+	}
+}
+
+func (d *ESP8266) handleEvent(line string) bool {
+	// +IPD,<id>,<len>
+	if strings.HasPrefix(line, "+IPD,") {
+		v := strings.Split(line[len("+IPD,"):], ",")
+		if id, err := strconv.Atoi(v[0]); err == nil {
+			d.withConn(id, (*Conn).signal)
+		}
+		return true
+	}
+	// <id>,CLOSED
+	if strings.HasSuffix(line, ",CLOSED") {
+		if id, err := strconv.Atoi(strings.TrimSuffix(line, ",CLOSED")); err == nil {
+			d.withConn(id, (*Conn).remoteClosed)
+			return true
+		}
+	}
+
+	e := Event{Line: line}
+	switch line {
+	case "WIFI CONNECTED":
+		e.Type = EventConnected
+	case "WIFI GOT IP":
+		e.Type = EventGotIP
+	case "WIFI DISCONNECT":
+		e.Type = EventDisconnected
+	default:
+		return false
+	}
+	select {
+	case d.events <- e:
+	default:
+	}
+	return true
+}
+
+func (d *ESP8266) withConn(id int, f func(*Conn)) {
+	if id < 0 || id >= maxConns {
+		return
+	}
+	d.connMu.Lock()
+	defer d.connMu.Unlock()
+
+	if c := d.conns[id]; c != nil {
+		f(c)
+	}
+}
+
+// final decides whether a line ends the answer to a command.
+type final func(line string) (done bool, err error)
+
+func okFinal(line string) (bool, error) {
+	switch line {
+	case "OK", "SEND OK":
+		return true, nil
+	case "ERROR", "FAIL", "SEND FAIL":
+		return true, ErrError
+	}
+	return false, nil
+}
+
+// exchange sends a request and collects the lines of the answer until
+// isFinal accepts one. If payload is not nil, it is sent when the module
+// prompts for it.
+func (d *ESP8266) exchange(request string, payload []byte, timeout time.Duration, isFinal final) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Drop the leftovers of a previous command which timed out.
+	for len(d.lines) > 0 {
+		<-d.lines
+	}
+
+	glog.V(2).Infof("esp8266: sending %q", request)
+	if _, err := io.WriteString(d.Port, request); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var lines []string
+	for {
+		select {
+		case line := <-d.lines:
+			if line == prompt {
+				if payload != nil {
+					if _, err := d.Port.Write(payload); err != nil {
+						return nil, err
+					}
+					payload = nil
+				}
+				continue
+			}
+			// The OK preceding the prompt does not end the answer.
+			if line == "OK" && payload != nil {
+				continue
+			}
+			if done, err := isFinal(line); done {
+				if err != nil {
+					err = fmt.Errorf("%v: %v", request[:len(request)-2], err)
+				}
+				return lines, err
+			}
+			lines = append(lines, line)
+		case <-timer.C:
+			return lines, ErrTimeout
+		case <-d.done:
+			return lines, d.readErr
+		}
+	}
+}
+
+func (d *ESP8266) command(cmd string, timeout time.Duration) ([]string, error) {
+	return d.exchange("AT"+cmd+"\r\n", nil, timeout, okFinal)
+}
+
+// Command sends the AT command "AT"+cmd and returns the lines of the
+// answer, without the final OK.
+func (d *ESP8266) Command(cmd string) ([]string, error) {
+	if err := d.setup(); err != nil {
+		return nil, err
+	}
+	return d.command(cmd, d.Timeout)
+}
+
+// quote quotes a string for an AT command, escaping the special characters.
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `,`, `\,`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// Join joins the Wi-Fi access point ssid.
+func (d *ESP8266) Join(ssid, password string) error {
+	if err := d.setup(); err != nil {
+		return err
+	}
+	_, err := d.command("+CWJAP="+quote(ssid)+","+quote(password), joinTimeout)
+	return err
+}
+
+// Leave leaves the access point.
+func (d *ESP8266) Leave() error {
+	_, err := d.Command("+CWQAP")
+	return err
+}
+
+// IP returns the IP address of the module on the access point.
+func (d *ESP8266) IP() (string, error) {
+	lines, err := d.Command("+CIFSR")
+	if err != nil {
+		return "", err
+	}
+	// +CIFSR:STAIP,"192.168.1.5"
+	for _, line := range lines {
+		if strings.HasPrefix(line, "+CIFSR:STAIP,") {
+			return strings.Trim(line[len("+CIFSR:STAIP,"):], `"`), nil
+		}
+	}
+	return "", fmt.Errorf("esp8266: no station address")
+}
@@ -0,0 +1,122 @@
+package esp8266
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var _ net.Conn = &Conn{}
+
+type port struct {
+	io.Reader
+	io.Writer
+}
+
+// fakeModule serves a single HTTP response on connection 0, in passive
+// receive mode.
+func fakeModule(response string) (*ESP8266, chan string) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	requests := make(chan string, 1)
+
+	go func() {
+		r := bufio.NewReader(inR)
+		pending := response
+		for {
+			cmd, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd = strings.TrimSpace(cmd)
+			switch {
+			case strings.HasPrefix(cmd, "AT+CIPSTART=0,"):
+				io.WriteString(outW, "0,CONNECT\r\n\r\nOK\r\n")
+			case strings.HasPrefix(cmd, "AT+CIPSEND=0,"):
+				n, _ := strconv.Atoi(cmd[len("AT+CIPSEND=0,"):])
+				io.WriteString(outW, "\r\nOK\r\n> ")
+				data := make([]byte, n)
+				io.ReadFull(r, data)
+				requests <- string(data)
+				fmt.Fprintf(outW, "\r\nRecv %v bytes\r\n\r\nSEND OK\r\n", n)
+				fmt.Fprintf(outW, "\r\n+IPD,0,%v\r\n", len(pending))
+			case strings.HasPrefix(cmd, "AT+CIPRECVDATA=0,"):
+				n, _ := strconv.Atoi(cmd[len("AT+CIPRECVDATA=0,"):])
+				if n > len(pending) {
+					n = len(pending)
+				}
+				if n > 0 {
+					fmt.Fprintf(outW, "+CIPRECVDATA:%v,%v\r\n", n, pending[:n])
+					pending = pending[n:]
+				}
+				io.WriteString(outW, "\r\nOK\r\n")
+				if pending == "" {
+					io.WriteString(outW, "0,CLOSED\r\n")
+				}
+			case strings.HasPrefix(cmd, "AT+CWJAP="):
+				io.WriteString(outW, "WIFI CONNECTED\r\nWIFI GOT IP\r\n\r\nOK\r\n")
+			default:
+				io.WriteString(outW, "\r\nOK\r\n")
+			}
+		}
+	}()
+
+	d := New(port{outR, inW})
+	d.Timeout = time.Second
+	return d, requests
+}
+
+func TestJoin(t *testing.T) {
+	d, _ := fakeModule("")
+	if err := d.Join("home", `a"b,c`); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []EventType{EventConnected, EventGotIP} {
+		if e := <-d.Events(); e.Type != want {
+			t.Errorf("event = %+v; want %v", e, want)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	d, requests := fakeModule("HTTP/1.0 200 OK\r\nContent-Type: text/plain\r\n\r\nline one\r\nline two\n")
+	resp, err := d.Get("http://example.com/status?x=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req := <-requests; !strings.HasPrefix(req, "GET /status?x=1 HTTP/1.0\r\nHost: example.com\r\n") {
+		t.Errorf("request = %q", req)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "line one\r\nline two\n" {
+		t.Errorf("body = %q", body)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d.conns[0] != nil {
+		t.Error("connection not released")
+	}
+}
+
+func TestReadData(t *testing.T) {
+	for _, header := range []string{"+CIPRECVDATA:5,", "+CIPRECVDATA,5:"} {
+		r := bufio.NewReader(strings.NewReader("a\r\nb\nc"))
+		data, ok, err := readData(r, header)
+		if err != nil || !ok || data != "a\r\nb\n" {
+			t.Errorf("readData(%q) = %q, %v, %v", header, data, ok, err)
+		}
+	}
+	if _, ok, _ := readData(nil, "+CIPRECVDATA:5"); ok {
+		t.Error("header without a separator accepted")
+	}
+}
@@ -0,0 +1,220 @@
+// Batched updates.
+
+package hd44780
+
+type batchKind int
+
+const (
+	opChars batchKind = iota
+	opCursor
+	opModes
+	opHome
+	opClear
+)
+
+type batchOp struct {
+	kind  batchKind
+	addr  byte
+	data  []byte
+	modes []ModeSetter
+}
+
+// Batch records operations on a display, to commit them together in a
+// single locked bus session. Redundant instructions are left out when
+// committing: moving the cursor where it already is, and mode changes
+// undone before the end of the batch. The mode changes take effect with
+// their final value, the display mode once the characters are written.
+type Batch struct {
+	hd  *HD44780
+	ops []batchOp
+}
+
+// Batch returns a new empty batch of operations on the display.
+func (hd *HD44780) Batch() *Batch {
+	return &Batch{hd: hd}
+}
+
+// Len returns the number of operations recorded.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// SetCursor records moving the cursor to the given position.
+func (b *Batch) SetCursor(col, row int) *Batch {
+	return b.SetDDRamAddr(byte(col) + b.hd.lcdRowOffset(row))
+}
+
+// SetDDRamAddr records moving the cursor to the given address. Consecutive
+// moves are merged.
+func (b *Batch) SetDDRamAddr(addr byte) *Batch {
+	if n := len(b.ops); n > 0 && b.ops[n-1].kind == opCursor {
+		b.ops[n-1].addr = addr
+		return b
+	}
+	b.ops = append(b.ops, batchOp{kind: opCursor, addr: addr})
+	return b
+}
+
+// WriteChar records writing a character.
+func (b *Batch) WriteChar(c byte) *Batch {
+	return b.Write([]byte{c})
+}
+
+// WriteString records writing characters.
+func (b *Batch) WriteString(s string) *Batch {
+	return b.Write([]byte(s))
+}
+
+// Write records writing characters. Consecutive writes are sent as one, in
+// a single bus transaction when the connection is a BatchWriter and its bus
+// can, and one character at a time otherwise.
+func (b *Batch) Write(data []byte) *Batch {
+	if n := len(b.ops); n > 0 && b.ops[n-1].kind == opChars {
+		b.ops[n-1].data = append(b.ops[n-1].data, data...)
+		return b
+	}
+	b.ops = append(b.ops, batchOp{kind: opChars, data: append([]byte(nil), data...)})
+	return b
+}
+
+// SetMode records changing the modes.
+func (b *Batch) SetMode(modes ...ModeSetter) *Batch {
+	b.ops = append(b.ops, batchOp{kind: opModes, modes: modes})
+	return b
+}
+
+// DisplayOff records setting the display mode to off.
+func (b *Batch) DisplayOff() *Batch { return b.SetMode(DisplayOff) }
+
+// DisplayOn records setting the display mode to on.
+func (b *Batch) DisplayOn() *Batch { return b.SetMode(DisplayOn) }
+
+// CursorOff records turning the cursor off.
+func (b *Batch) CursorOff() *Batch { return b.SetMode(CursorOff) }
+
+// CursorOn records turning the cursor on.
+func (b *Batch) CursorOn() *Batch { return b.SetMode(CursorOn) }
+
+// BlinkOff records setting the cursor blink mode off.
+func (b *Batch) BlinkOff() *Batch { return b.SetMode(BlinkOff) }
+
+// BlinkOn records setting the cursor blink mode on.
+func (b *Batch) BlinkOn() *Batch { return b.SetMode(BlinkOn) }
+
+// Home records moving the cursor and all characters to the home position.
+func (b *Batch) Home() *Batch {
+	b.ops = append(b.ops, batchOp{kind: opHome})
+	return b
+}
+
+// Clear records clearing the display. The characters written and the
+// cursor moves recorded before are dropped, as they would be cleared.
+func (b *Batch) Clear() *Batch {
+	ops := b.ops[:0]
+	for _, op := range b.ops {
+		if op.kind == opModes {
+			ops = append(ops, op)
+		}
+	}
+	b.ops = append(ops, batchOp{kind: opClear})
+	return b
+}
+
+// session is the state of a batch being committed: the modes last written
+// to the controller, and the pending cursor move.
+type session struct {
+	hd           *HD44780
+	eMode        entryMode
+	dMode        displayMode
+	entryUnknown bool
+	cursor       byte
+	moved        bool
+}
+
+func (s *session) moveCursor() error {
+	if !s.moved {
+		return nil
+	}
+	s.moved = false
+	if !s.hd.shadow.cg && s.hd.shadow.ac == s.cursor {
+		return nil
+	}
+	return s.hd.instruction(lcdSetDDRamAddr | s.cursor)
+}
+
+func (s *session) setEntryMode() error {
+	if !s.entryUnknown && s.hd.eMode == s.eMode {
+		return nil
+	}
+	s.eMode, s.entryUnknown = s.hd.eMode, false
+	return s.hd.instruction(byte(lcdSetEntryMode | s.hd.eMode))
+}
+
+func (s *session) apply(op batchOp) error {
+	hd := s.hd
+	switch op.kind {
+	case opModes:
+		fMode := hd.fMode
+		for _, m := range op.modes {
+			m(hd)
+		}
+		// The function mode changes the addressing, so it is written at
+		// once.
+		if hd.fMode != fMode {
+			if err := hd.instruction(byte(lcdSetFunctionMode | hd.fMode)); err != nil {
+				return err
+			}
+			return hd.writeHeightFormat()
+		}
+	case opCursor:
+		s.cursor, s.moved = op.addr, true
+	case opChars:
+		if err := s.moveCursor(); err != nil {
+			return err
+		}
+		if err := s.setEntryMode(); err != nil {
+			return err
+		}
+		return hd.writeChars(op.data)
+	case opHome, opClear:
+		ins := lcdReturnHome
+		if op.kind == opClear {
+			// Clearing the display also resets the entry mode.
+			ins, s.entryUnknown = lcdClearDisplay, true
+		}
+		s.moved = false
+		if err := hd.instruction(ins); err != nil {
+			return err
+		}
+		hd.homeWait()
+	}
+	return nil
+}
+
+// Commit writes the recorded operations to the display, holding its lock
+// for the whole batch. The batch is left empty, to be reused.
+func (b *Batch) Commit() error {
+	hd := b.hd
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	ops := b.ops
+	b.ops = nil
+
+	s := &session{hd: hd, eMode: hd.eMode, dMode: hd.dMode}
+	for _, op := range ops {
+		if err := s.apply(op); err != nil {
+			return err
+		}
+	}
+	if err := s.moveCursor(); err != nil {
+		return err
+	}
+	if err := s.setEntryMode(); err != nil {
+		return err
+	}
+	if hd.dMode != s.dMode {
+		return hd.instruction(byte(lcdSetDisplayMode | hd.dMode))
+	}
+	return nil
+}
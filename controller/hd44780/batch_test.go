@@ -0,0 +1,126 @@
+package hd44780
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/clock"
+)
+
+// recordingLCD counts the instructions written to a fakeLCD.
+type recordingLCD struct {
+	fakeLCD
+	instructions []byte
+}
+
+func (lcd *recordingLCD) Write(rs bool, data byte) error {
+	if !rs {
+		lcd.instructions = append(lcd.instructions, data)
+	}
+	return lcd.fakeLCD.Write(rs, data)
+}
+
+func newRecordingLCD(t *testing.T) (*HD44780, *recordingLCD) {
+	lcd := &recordingLCD{}
+	lcd.ram.clear()
+	hd, err := New(lcd, RowAddress20Col, TwoLine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lcd.instructions = nil
+	return hd, lcd
+}
+
+func TestBatch(t *testing.T) {
+	hd, lcd := newRecordingLCD(t)
+
+	err := hd.Batch().
+		DisplayOff().
+		SetCursor(0, 0).
+		WriteString("ab").
+		SetCursor(2, 0).
+		WriteChar('c').
+		SetCursor(0, 1).
+		WriteString("de").
+		DisplayOn().
+		CursorOn().
+		Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(lcd.ram.ddram[:3]); got != "abc" {
+		t.Errorf("first line: got %q, want %q", got, "abc")
+	}
+	if got := string(lcd.ram.ddram[secondLine : secondLine+2]); got != "de" {
+		t.Errorf("second line: got %q, want %q", got, "de")
+	}
+	// The cursor is already at the start and after "ab", and the display
+	// ends up on: only the move to the second line and the cursor change
+	// are written.
+	want := []byte{lcdSetDDRamAddr | secondLine, byte(lcdSetDisplayMode | hd.dMode)}
+	if string(lcd.instructions) != string(want) {
+		t.Errorf("instructions: got %#v, want %#v", lcd.instructions, want)
+	}
+	if !hd.CursorEnabled() || !hd.DisplayEnabled() {
+		t.Error("cursor or display not enabled after the batch")
+	}
+}
+
+func TestBatch_clear(t *testing.T) {
+	hd, lcd := newRecordingLCD(t)
+
+	b := hd.Batch().WriteString("dropped").SetMode(EntryDecrement).Clear().SetCursor(4, 0).WriteString("x")
+	if n := b.Len(); n != 4 {
+		t.Errorf("Len: got %v, want 4", n)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 0 {
+		t.Error("batch not emptied by Commit")
+	}
+
+	if got := string(lcd.ram.ddram[:8]); got != "    x   " {
+		t.Errorf("got %q, want %q", got, "    x   ")
+	}
+	// The entry mode is written again after the clear.
+	want := []byte{lcdClearDisplay, lcdSetDDRamAddr | 4, byte(lcdSetEntryMode | hd.eMode)}
+	if string(lcd.instructions) != string(want) {
+		t.Errorf("instructions: got %#v, want %#v", lcd.instructions, want)
+	}
+}
+
+func TestBatch_i2c(t *testing.T) {
+	defer func(saved clock.Clock) { Clock = saved }(Clock)
+	c := clock.NewVirtual(time.Time{})
+	c.AutoAdvance = true
+	Clock = c
+
+	// On a bus without single transfers, a batch costs what the single
+	// writes do.
+	var elapsed [2]time.Duration
+	for i, batch := range []bool{false, true} {
+		hd, err := New(NewI2CConnection(&costI2C{clock: c}, 0x27, PCF8574PinMap), RowAddress20Col, TwoLine)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := c.Now()
+		if batch {
+			err = hd.Batch().WriteString("batch").Commit()
+		} else {
+			for _, v := range []byte("batch") {
+				if err = hd.WriteChar(v); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		elapsed[i] = c.Now().Sub(start)
+	}
+	if elapsed[1] > elapsed[0] {
+		t.Errorf("batch took %v, want at most the %v of the single writes", elapsed[1], elapsed[0])
+	}
+}
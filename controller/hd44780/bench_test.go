@@ -0,0 +1,248 @@
+// The benchmarks of this file model the backends on a virtual clock: the
+// modelled-chars/s they report are computed from the modelled costs of the
+// bus operations and the delays of the controller, not measured on
+// hardware. The ns/op is only the time spent simulating.
+
+package hd44780
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+)
+
+// The costs of the bus operations of the modelled backends. They are rough
+// orders of magnitude, for comparing the backends and catching regressions
+// in the number of operations per character, not measurements.
+const (
+	// A write to the value file of a sysfs GPIO.
+	sysfsWriteCost = 10 * time.Microsecond
+	// A write to the memory mapped registers of the GPIO controller.
+	mmapWriteCost = 100 * time.Nanosecond
+	// A bit on a 100kHz I²C bus, and the wait of the generic bus after each
+	// byte of WriteBytes.
+	i2cBitCost        = 10 * time.Microsecond
+	i2cWriteBytesWait = 20 * time.Millisecond
+	// The system call of a SPI transfer, and a bit on a 1MHz SPI bus.
+	spiTransferCost = 5 * time.Microsecond
+	spiBitCost      = time.Microsecond
+)
+
+// costPin is a digital pin advancing the clock by the cost of each write.
+type costPin struct {
+	embd.DigitalPin
+
+	clock *clock.Virtual
+	cost  time.Duration
+}
+
+func (p *costPin) Write(int) error {
+	p.clock.Advance(p.cost)
+	return nil
+}
+
+// costBus writes the data lines in a single register write.
+type costBus costPin
+
+func (b *costBus) Write(uint32) error {
+	b.clock.Advance(b.cost)
+	return nil
+}
+
+// costI2C is an I²C bus advancing the clock by the bits of each transaction:
+// the start, the address, the data and the stop, with their acks. Like the
+// generic bus, WriteBytes writes a transaction per byte and waits after
+// each.
+type costI2C struct {
+	embd.I2CBus
+
+	clock   *clock.Virtual
+	written []byte
+}
+
+func (b *costI2C) transaction(value []byte) {
+	b.clock.Advance(time.Duration(2+9+9*len(value)) * i2cBitCost)
+	b.written = append(b.written, value...)
+}
+
+func (b *costI2C) WriteByte(addr, value byte) error {
+	b.transaction([]byte{value})
+	return nil
+}
+
+func (b *costI2C) WriteBytes(addr byte, value []byte) error {
+	for _, v := range value {
+		b.transaction([]byte{v})
+		b.clock.Advance(i2cWriteBytesWait)
+	}
+	return nil
+}
+
+// costI2CTransfer is a costI2C which can also write several bytes in a
+// single transaction, like the generic bus through I2C_RDWR.
+type costI2CTransfer struct {
+	costI2C
+}
+
+func (b *costI2CTransfer) WriteTransfer(addr byte, value []byte) error {
+	b.transaction(value)
+	return nil
+}
+
+type costSPI struct {
+	embd.SPIBus
+
+	clock *clock.Virtual
+}
+
+func (b *costSPI) TransferAndReceiveByte(data byte) (byte, error) {
+	b.clock.Advance(spiTransferCost + 8*spiBitCost)
+	return 0, nil
+}
+
+type backend struct {
+	name    string
+	connect func(c *clock.Virtual) Connection
+}
+
+func gpioBackend(name string, cost time.Duration, bus bool) backend {
+	return backend{name, func(c *clock.Virtual) Connection {
+		pin := func() embd.DigitalPin { return &costPin{clock: c, cost: cost} }
+		conn := NewGPIOConnection(pin(), pin(), pin(), pin(), pin(), pin(), pin(), Positive)
+		if bus {
+			conn.data = &costBus{clock: c, cost: cost}
+		}
+		return conn
+	}}
+}
+
+var backends = []backend{
+	gpioBackend("GPIO sysfs", sysfsWriteCost, false),
+	gpioBackend("GPIO mmap", mmapWriteCost, true),
+	{"I2C PCF8574", func(c *clock.Virtual) Connection {
+		return NewI2CConnection(&costI2C{clock: c}, 0x27, PCF8574PinMap)
+	}},
+	{"I2C PCF8574 RDWR", func(c *clock.Virtual) Connection {
+		return NewI2CConnection(&costI2CTransfer{costI2C{clock: c}}, 0x27, PCF8574PinMap)
+	}},
+	{"SPI 74HC595", func(c *clock.Virtual) Connection {
+		return NewSPIConnection(&costSPI{clock: c}, PCF8574PinMap)
+	}},
+	{"GPIO 74HC595", func(c *clock.Virtual) Connection {
+		pin := func() embd.DigitalPin { return &costPin{clock: c, cost: sysfsWriteCost} }
+		return NewShiftRegisterConnection(pin(), pin(), pin(), PCF8574PinMap)
+	}},
+}
+
+// benchmarkWrites writes a line of a 20x4 display at each iteration, and
+// reports the characters per second of the modelled bus, including the
+// delays of the controller.
+func benchmarkWrites(b *testing.B, be backend, batch bool) {
+	c := clock.NewVirtual(time.Time{})
+	c.AutoAdvance = true
+	defer func(saved clock.Clock) { Clock = saved }(Clock)
+	Clock = c
+
+	hd, err := New(be.connect(c), RowAddress20Col, TwoLine)
+	if err != nil {
+		b.Fatal(err)
+	}
+	line := []byte("The quick brown fox ")
+
+	b.ResetTimer()
+	start := c.Now()
+	for i := 0; i < b.N; i++ {
+		if err := hd.SetDDRamAddr(0); err != nil {
+			b.Fatal(err)
+		}
+		if batch {
+			err = hd.WriteChars(line)
+		} else {
+			for _, v := range line {
+				if err = hd.WriteChar(v); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	elapsed := c.Now().Sub(start)
+	b.ReportMetric(float64(b.N*len(line))/elapsed.Seconds(), "modelled-chars/s")
+}
+
+func BenchmarkModelledWrites(b *testing.B) {
+	for _, be := range backends {
+		be := be
+		b.Run(be.name+"/per-write", func(b *testing.B) { benchmarkWrites(b, be, false) })
+		b.Run(be.name+"/batch", func(b *testing.B) { benchmarkWrites(b, be, true) })
+	}
+}
+
+func TestWriteChars_batch(t *testing.T) {
+	defer func(saved clock.Clock) { Clock = saved }(Clock)
+	c := clock.NewVirtual(time.Time{})
+	c.AutoAdvance = true
+	Clock = c
+
+	var written [2][]byte
+	for i, batch := range []bool{false, true} {
+		bus := &costI2CTransfer{costI2C{clock: c}}
+		hd, err := New(NewI2CConnection(bus, 0x27, PCF8574PinMap), RowAddress20Col, TwoLine)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bus.written = nil
+		if batch {
+			err = hd.WriteChars([]byte("batch"))
+		} else {
+			for _, v := range []byte("batch") {
+				if err = hd.WriteChar(v); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(hd.shadow.ddram[:5]); got != "batch" {
+			t.Errorf("batch %v: shadow holds %q, want %q", batch, got, "batch")
+		}
+		written[i] = bus.written
+	}
+	if !bytes.Equal(written[0], written[1]) {
+		t.Errorf("batched writes sent %x, want %x", written[1], written[0])
+	}
+}
+
+func TestWriteChars_noTransfer(t *testing.T) {
+	defer func(saved clock.Clock) { Clock = saved }(Clock)
+	c := clock.NewVirtual(time.Time{})
+	c.AutoAdvance = true
+	Clock = c
+
+	// Without single transfers, the batch is written like single writes,
+	// rather than through the waits of WriteBytes.
+	var elapsed [2]time.Duration
+	for i, bus := range []embd.I2CBus{&costI2C{clock: c}, &costI2CTransfer{costI2C{clock: c}}} {
+		hd, err := New(NewI2CConnection(bus, 0x27, PCF8574PinMap), RowAddress20Col, TwoLine)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := c.Now()
+		if err := hd.WriteChars([]byte("batch")); err != nil {
+			t.Fatal(err)
+		}
+		elapsed[i] = c.Now().Sub(start)
+	}
+	if elapsed[0] >= 5*i2cWriteBytesWait {
+		t.Errorf("batch of 5 took %v without single transfers, want no WriteBytes waits", elapsed[0])
+	}
+	if elapsed[1] >= elapsed[0] {
+		t.Errorf("batch took %v in a single transfer, want less than the %v of single writes", elapsed[1], elapsed[0])
+	}
+}
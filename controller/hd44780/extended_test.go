@@ -0,0 +1,59 @@
+package hd44780
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowOrder(t *testing.T) {
+	hd, _ := newRecordingLCD(t)
+	if got := hd.RowOrder(4); !reflect.DeepEqual(got, []int{0, 1, 2, 3}) {
+		t.Errorf("single height: got %v", got)
+	}
+
+	for format, want := range map[HeightFormat][]int{
+		DoubleHeightTop:    {0, 2, 3, 1},
+		DoubleHeightMiddle: {0, 1, 3, 2},
+		DoubleHeightBoth:   {0, 2, 1, 3},
+		DoubleHeightBottom: {0, 1, 2, 3},
+	} {
+		if err := hd.SetMode(ExtendedInstructions(format), DoubleHeight); err != nil {
+			t.Fatal(err)
+		}
+		if got := hd.RowOrder(4); !reflect.DeepEqual(got, want) {
+			t.Errorf("format %v: got %v, want %v", format, got, want)
+		}
+	}
+	if got := hd.RowOrder(2); !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Errorf("two lines: got %v", got)
+	}
+}
+
+func TestExtendedInstructions(t *testing.T) {
+	hd, lcd := newRecordingLCD(t)
+	hd.WriteChar('a')
+	ac := hd.shadow.ac
+
+	if err := hd.SetMode(ExtendedInstructions(DoubleHeightMiddle), DoubleHeight); err != nil {
+		t.Fatal(err)
+	}
+	fn := byte(lcdSetFunctionMode | hd.fMode)
+	want := []byte{
+		byte(lcdSetEntryMode | hd.eMode),
+		byte(lcdSetDisplayMode | hd.dMode),
+		fn,
+		byte(lcdSetFunctionMode|lcd2Line) | lcdExtendedRE,
+		lcdSetHeightFormat | byte(DoubleHeightMiddle)<<2,
+		fn,
+	}
+	if !reflect.DeepEqual(lcd.instructions, want) {
+		t.Errorf("instructions: got %#v, want %#v", lcd.instructions, want)
+	}
+	if fn&byte(lcdDoubleHeight) == 0 || !hd.DoubleHeightEnabled() {
+		t.Error("double height not enabled")
+	}
+	// The extended instructions are not cursor shifts.
+	if hd.shadow.ac != ac {
+		t.Errorf("address counter moved from %v to %v", ac, hd.shadow.ac)
+	}
+}
@@ -1,7 +1,17 @@
 /*
 Package hd44780 allows controlling an HD44780-compatible character LCD
-controller. Currently the library is write-only and does not support
-reading from the display controller.
+controller. The library keeps a copy of the display contents, so that the
+display can be refreshed when electrical noise corrupts it (see
+AutoRefresh). Reading from the display controller is supported on
+connections implementing Reader.
+
+With the RW line wired, the BusyFlag mode polls the busy flag of the
+controller instead of waiting fixed delays long enough for the slowest
+controllers. On a GPIO bus, the RW pin is set on the connection:
+
+	conn := hd44780.NewGPIOConnection(rs, en, d4, d5, d6, d7, backlight, hd44780.Positive)
+	conn.RW = rw
+	hd, err := hd44780.New(conn, hd44780.RowAddress16Col, hd44780.TwoLine, hd44780.BusyFlag)
 
 Resources
 
@@ -13,10 +23,17 @@ This library is based three other HD44780 libraries:
 package hd44780
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/controller/hd44780/protocol"
+	"github.com/kidoman/embd/health"
 )
 
 type entryMode byte
@@ -48,6 +65,11 @@ const (
 	pulseDelay = 1 * time.Microsecond
 	clearDelay = 1520 * time.Microsecond
 
+	// busyTimeout is how long the busy flag may stay set before polling it
+	// falls back to the fixed delays, well over the longest instruction.
+	busyTimeout = 10 * clearDelay
+	busyFlag    = 0x80
+
 	// Initialize display
 	lcdInit     byte = 0x33 // 00110011
 	lcdInit4bit byte = 0x32 // 00110010
@@ -94,22 +116,84 @@ const (
 // HD44780 represents an HD44780-compatible character LCD controller.
 type HD44780 struct {
 	Connection
+
+	// HealthName is the name AutoRefresh reports the display under to the
+	// health registry, "hd44780" when empty. Give each display its own.
+	HealthName string
+
 	eMode   entryMode
 	dMode   displayMode
 	fMode   functionMode
 	rowAddr RowAddress
+
+	// extended and heightFormat are set by ExtendedInstructions.
+	extended     bool
+	heightFormat HeightFormat
+
+	// borrowed is set by Borrowed.
+	borrowed bool
+	// busyFlag is set by BusyFlag.
+	busyFlag bool
+
+	mu     sync.Mutex
+	shadow shadow
+
+	// refreshMu guards the refresh goroutine and closed, apart from mu which
+	// the goroutine takes to refresh.
+	refreshMu  sync.Mutex
+	quit, done chan struct{}
+	closed     bool
 }
 
-// NewGPIO creates a new HD44780 connected by a 4-bit GPIO bus.
+// Supply is the logic level of the displays driven by NewGPIO, in
+// millivolts, checked against the level of the pins (see embd.CheckLevel).
+// Set it to embd.Level3V3 for 3.3V modules.
+var Supply = embd.Level5V
+
+// Clock times the delays the controller needs between writes. Tests set it
+// to a clock.Virtual with AutoAdvance to run without the delays, and
+// clock.Precise spins the microsecond delays instead of sleeping longer.
+var Clock = clock.Real
+
+// pinRoles name the pins of the GPIO bus in their claims.
+var pinRoles = [7]string{"RS", "EN", "D4", "D5", "D6", "D7", "backlight"}
+
+// NewGPIO creates a new HD44780 connected by a 4-bit GPIO bus. The pins are
+// claimed (see embd.Claim) until the display is closed.
 func NewGPIO(
 	rs, en, d4, d5, d6, d7, backlight interface{},
 	blPolarity BacklightPolarity,
 	rowAddr RowAddress,
 	modes ...ModeSetter,
 ) (*HD44780, error) {
-	pinKeys := []interface{}{rs, en, d4, d5, d6, d7, backlight}
-	pins := [7]embd.DigitalPin{}
-	for idx, key := range pinKeys {
+	pins, err := openPins([]interface{}{rs, en, d4, d5, d6, d7, backlight}, pinRoles[:])
+	if err != nil {
+		return nil, err
+	}
+	hd, err := New(
+		NewGPIOConnection(
+			pins[0],
+			pins[1],
+			pins[2],
+			pins[3],
+			pins[4],
+			pins[5],
+			pins[6],
+			blPolarity),
+		rowAddr,
+		modes...,
+	)
+	if err != nil {
+		unclaim(pins)
+	}
+	return hd, err
+}
+
+// openPins opens the pins of a GPIO bus by their keys, skipping the nil ones,
+// then claims them with their role and turns them into outputs.
+func openPins(keys []interface{}, roles []string) ([]embd.DigitalPin, error) {
+	pins := make([]embd.DigitalPin, len(keys))
+	for idx, key := range keys {
 		if key == nil {
 			continue
 		}
@@ -126,6 +210,24 @@ func NewGPIO(
 		}
 		pins[idx] = digitalPin
 	}
+	for idx, pin := range pins {
+		if pin == nil {
+			continue
+		}
+		if err := embd.Claim(pin, "hd44780 "+roles[idx]); err != nil {
+			unclaim(pins[:idx])
+			return nil, err
+		}
+	}
+	for idx, pin := range pins {
+		if pin == nil {
+			continue
+		}
+		if err := embd.CheckLevel(pin, Supply, "hd44780 "+roles[idx]); err != nil {
+			unclaim(pins)
+			return nil, err
+		}
+	}
 	for _, pin := range pins {
 		if pin == nil {
 			continue
@@ -133,25 +235,24 @@ func NewGPIO(
 		err := pin.SetDirection(embd.Out)
 		if err != nil {
 			glog.Errorf("hd44780: error setting pin %+v to out direction: %s", pin, err)
+			unclaim(pins)
 			return nil, err
 		}
 	}
-	return New(
-		NewGPIOConnection(
-			pins[0],
-			pins[1],
-			pins[2],
-			pins[3],
-			pins[4],
-			pins[5],
-			pins[6],
-			blPolarity),
-		rowAddr,
-		modes...,
-	)
+	return pins, nil
 }
 
-// NewI2C creates a new HD44780 connected by an I²C bus.
+func unclaim(pins []embd.DigitalPin) {
+	for _, pin := range pins {
+		if pin != nil {
+			embd.Unclaim(pin)
+		}
+	}
+}
+
+// NewI2C creates a new HD44780 connected by an I²C bus, on the backpack at
+// addr: 0x27 or 0x3f for most PCF8574 and PCF8574A backpacks (see
+// ScanI2C).
 func NewI2C(
 	i2c embd.I2CBus,
 	addr byte,
@@ -159,7 +260,18 @@ func NewI2C(
 	rowAddr RowAddress,
 	modes ...ModeSetter,
 ) (*HD44780, error) {
-	return New(NewI2CConnection(i2c, addr, pinMap), rowAddr, modes...)
+	if err := ValidAddr(addr); err != nil {
+		return nil, err
+	}
+	device := embd.I2CDevice(i2c, addr)
+	if err := embd.Claim(device, "hd44780"); err != nil {
+		return nil, err
+	}
+	hd, err := New(NewI2CConnection(i2c, addr, pinMap), rowAddr, modes...)
+	if err != nil {
+		embd.Unclaim(device)
+	}
+	return hd, err
 }
 
 // New creates a new HD44780 connected by a Connection bus.
@@ -171,6 +283,7 @@ func New(bus Connection, rowAddr RowAddress, modes ...ModeSetter) (*HD44780, err
 		fMode:      0x00,
 		rowAddr:    rowAddr,
 	}
+	controller.shadow.clear()
 	err := controller.lcdInit()
 	if err != nil {
 		return nil, err
@@ -257,6 +370,25 @@ func Dots5x8(hd *HD44780) { hd.fMode &= ^lcd5x10Dots }
 // Dots5x10 is a ModeSetter that sets the HD44780 to 5x10-pixel character mode.
 func Dots5x10(hd *HD44780) { hd.fMode |= lcd5x10Dots }
 
+// Borrowed is a ModeSetter declaring that the pins or the bus of the
+// connection are borrowed, like an I²C bus shared with other devices: closing
+// the display releases them without closing them.
+func Borrowed(hd *HD44780) { hd.borrowed = true }
+
+// Owned is a ModeSetter declaring that the display owns the pins or the bus
+// of the connection, and closes them when it is closed. It is the default.
+func Owned(hd *HD44780) { hd.borrowed = false }
+
+// BusyFlag is a ModeSetter polling the busy flag of the controller after
+// every instruction, returning as soon as it is ready rather than after a
+// fixed delay. It needs a BusyPoller connection with the RW line wired; the
+// fixed delays are used otherwise.
+func BusyFlag(hd *HD44780) { hd.busyFlag = true }
+
+// FixedDelays is a ModeSetter waiting a fixed delay after every instruction,
+// long enough for the slowest controllers. It is the default.
+func FixedDelays(hd *HD44780) { hd.busyFlag = false }
+
 // EntryIncrementEnabled returns true if entry increment mode is enabled.
 func (hd *HD44780) EntryIncrementEnabled() bool { return hd.eMode&lcdEntryIncrement > 0 }
 
@@ -286,13 +418,22 @@ func (hd *HD44780) Dots5x10Enabled() bool { return hd.fMode&lcd5x8Dots > 0 }
 // SetModes modifies the entry mode, display mode, and function mode with the
 // given mode setter functions.
 func (hd *HD44780) SetMode(modes ...ModeSetter) error {
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	busyFlag := hd.busyFlag
 	for _, m := range modes {
 		m(hd)
 	}
+	if hd.busyFlag != busyFlag {
+		if err := hd.setBusyPolling(hd.busyFlag); err != nil {
+			return err
+		}
+	}
 	functions := []func() error{
-		func() error { return hd.setEntryMode() },
-		func() error { return hd.setDisplayMode() },
-		func() error { return hd.setFunctionMode() },
+		hd.setEntryMode,
+		hd.setDisplayMode,
+		hd.setFunctionMode,
 	}
 	for _, f := range functions {
 		err := f()
@@ -303,52 +444,82 @@ func (hd *HD44780) SetMode(modes ...ModeSetter) error {
 	return nil
 }
 
+// setEntryMode writes the entry mode. It must be called with the lock held.
 func (hd *HD44780) setEntryMode() error {
-	return hd.WriteInstruction(byte(lcdSetEntryMode | hd.eMode))
+	return hd.instruction(byte(lcdSetEntryMode | hd.eMode))
 }
 
+// setDisplayMode writes the display mode. It must be called with the lock
+// held.
 func (hd *HD44780) setDisplayMode() error {
-	return hd.WriteInstruction(byte(lcdSetDisplayMode | hd.dMode))
+	return hd.instruction(byte(lcdSetDisplayMode | hd.dMode))
 }
 
+// setFunctionMode writes the function mode. It must be called with the lock
+// held.
 func (hd *HD44780) setFunctionMode() error {
-	return hd.WriteInstruction(byte(lcdSetFunctionMode | hd.fMode))
+	if err := hd.instruction(byte(lcdSetFunctionMode | hd.fMode)); err != nil {
+		return err
+	}
+	return hd.writeHeightFormat()
+}
+
+// changeDisplayMode modifies the display mode and writes it, under the lock
+// the refresh takes to write it back.
+func (hd *HD44780) changeDisplayMode(m ModeSetter) error {
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	m(hd)
+	return hd.setDisplayMode()
 }
 
 // DisplayOff sets the display mode to off.
 func (hd *HD44780) DisplayOff() error {
-	DisplayOff(hd)
-	return hd.setDisplayMode()
+	return hd.changeDisplayMode(DisplayOff)
 }
 
 // DisplayOn sets the display mode to on.
 func (hd *HD44780) DisplayOn() error {
-	DisplayOn(hd)
-	return hd.setDisplayMode()
+	return hd.changeDisplayMode(DisplayOn)
 }
 
 // CursorOff turns the cursor off.
 func (hd *HD44780) CursorOff() error {
-	CursorOff(hd)
-	return hd.setDisplayMode()
+	return hd.changeDisplayMode(CursorOff)
 }
 
 // CursorOn turns the cursor on.
 func (hd *HD44780) CursorOn() error {
-	CursorOn(hd)
-	return hd.setDisplayMode()
+	return hd.changeDisplayMode(CursorOn)
 }
 
 // BlinkOff sets cursor blink mode off.
 func (hd *HD44780) BlinkOff() error {
-	BlinkOff(hd)
-	return hd.setDisplayMode()
+	return hd.changeDisplayMode(BlinkOff)
 }
 
 // BlinkOn sets cursor blink mode on.
 func (hd *HD44780) BlinkOn() error {
-	BlinkOn(hd)
-	return hd.setDisplayMode()
+	return hd.changeDisplayMode(BlinkOn)
+}
+
+// BacklightOff turns the optional backlight off. It takes the lock of the
+// writes, as the expander connections send the backlight with the data.
+func (hd *HD44780) BacklightOff() error {
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	return hd.Connection.BacklightOff()
+}
+
+// BacklightOn turns the optional backlight on. It takes the lock of the
+// writes, as the expander connections send the backlight with the data.
+func (hd *HD44780) BacklightOn() error {
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	return hd.Connection.BacklightOn()
 }
 
 // ShiftLeft shifts the cursor and all characters to the left.
@@ -361,10 +532,37 @@ func (hd *HD44780) ShiftRight() error {
 	return hd.WriteInstruction(lcdCursorShift | lcdDisplayMove | lcdMoveRight)
 }
 
+// setBusyPolling turns polling the busy flag of the connection on or off,
+// leaving the fixed delays when the connection cannot poll it.
+func (hd *HD44780) setBusyPolling(on bool) error {
+	bp, ok := hd.Connection.(BusyPoller)
+	if !ok {
+		if on {
+			glog.V(1).Info("hd44780: connection cannot poll the busy flag, using fixed delays")
+		}
+		return nil
+	}
+	err := bp.SetBusyPolling(on)
+	if err == embd.ErrFeatureNotSupported {
+		glog.V(1).Info("hd44780: RW line not wired, using fixed delays")
+		return nil
+	}
+	return err
+}
+
+// homeWait waits for the controller to return home or to clear the display,
+// unless the connection already waited for it polling the busy flag.
+func (hd *HD44780) homeWait() {
+	if bp, ok := hd.Connection.(BusyPoller); ok && bp.BusyPolling() {
+		return
+	}
+	Clock.Sleep(clearDelay)
+}
+
 // Home moves the cursor and all characters to the home position.
 func (hd *HD44780) Home() error {
 	err := hd.WriteInstruction(lcdReturnHome)
-	time.Sleep(clearDelay)
+	hd.homeWait()
 	return err
 }
 
@@ -374,11 +572,51 @@ func (hd *HD44780) Clear() error {
 	if err != nil {
 		return err
 	}
-	time.Sleep(clearDelay)
+	hd.homeWait()
 	// have to set mode here because clear also clears some mode settings
 	return hd.SetMode()
 }
 
+// SetRightToLeft sets the direction the text is written in, with the cursor
+// moving left after each character when rtl is set.
+func (hd *HD44780) SetRightToLeft(rtl bool) error {
+	if rtl {
+		return hd.SetMode(EntryDecrement)
+	}
+	return hd.SetMode(EntryIncrement)
+}
+
+// SetAutoScroll turns shifting the display as characters are written on or
+// off, so that the cursor stays in place and the text scrolls.
+func (hd *HD44780) SetAutoScroll(on bool) error {
+	if on {
+		return hd.SetMode(EntryShiftOn)
+	}
+	return hd.SetMode(EntryShiftOff)
+}
+
+// CreateChar defines the custom character of code slot (0 to 7) from the
+// rows of its 5x8 pixels, the top row first and the leftmost pixel in bit 4.
+// The cursor position is kept.
+func (hd *HD44780) CreateChar(slot byte, rows [8]byte) error {
+	hd.mu.Lock()
+	ac, cg := hd.shadow.ac, hd.shadow.cg
+	hd.mu.Unlock()
+
+	if err := hd.WriteInstruction(lcdSetCGRamAddr | (slot&0x07)<<3); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := hd.WriteChar(row); err != nil {
+			return err
+		}
+	}
+	if cg {
+		return hd.WriteInstruction(lcdSetCGRamAddr | ac)
+	}
+	return hd.SetDDRamAddr(ac)
+}
+
 // SetCursor sets the input cursor to the given position.
 func (hd *HD44780) SetCursor(col, row int) error {
 	return hd.SetDDRamAddr(byte(col) + hd.lcdRowOffset(row))
@@ -397,18 +635,84 @@ func (hd *HD44780) SetDDRamAddr(value byte) error {
 	return hd.WriteInstruction(lcdSetDDRamAddr | value)
 }
 
-// WriteInstruction writes a byte to the bus with register select in data mode.
+// WriteChar writes a byte to the bus with register select in data mode.
 func (hd *HD44780) WriteChar(value byte) error {
-	return hd.Write(true, value)
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	if err := hd.Write(true, value); err != nil {
+		return err
+	}
+	hd.shadow.writeChar(value, hd.eMode, hd.TwoLineEnabled())
+	return nil
 }
 
 // WriteInstruction writes a byte to the bus with register select in command mode.
 func (hd *HD44780) WriteInstruction(value byte) error {
-	return hd.Write(false, value)
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	return hd.instruction(value)
 }
 
-// Close closes the underlying Connection.
+// instruction writes an instruction. It must be called with the lock held.
+func (hd *HD44780) instruction(value byte) error {
+	if err := hd.Write(false, value); err != nil {
+		return err
+	}
+	hd.shadow.instruction(value, hd.TwoLineEnabled())
+	return nil
+}
+
+// WriteChars writes characters like WriteChar, in a single bus transaction
+// when the connection is a BatchWriter.
+func (hd *HD44780) WriteChars(data []byte) error {
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	return hd.writeChars(data)
+}
+
+// writeChars writes characters. It must be called with the lock held.
+func (hd *HD44780) writeChars(data []byte) error {
+	if bw, ok := hd.Connection.(BatchWriter); ok {
+		if err := bw.WriteBatch(true, data); err != nil {
+			return err
+		}
+		for _, v := range data {
+			hd.shadow.writeChar(v, hd.eMode, hd.TwoLineEnabled())
+		}
+		return nil
+	}
+	for _, v := range data {
+		if err := hd.Write(true, v); err != nil {
+			return err
+		}
+		hd.shadow.writeChar(v, hd.eMode, hd.TwoLineEnabled())
+	}
+	return nil
+}
+
+// Close stops refreshing the display, removes it from the health registry,
+// and closes the underlying Connection, or only releases it when the
+// display is Borrowed. Closing it again does nothing.
 func (hd *HD44780) Close() error {
+	hd.refreshMu.Lock()
+	closed := hd.closed
+	hd.closed = true
+	hd.stopRefresh()
+	hd.refreshMu.Unlock()
+	if closed {
+		return nil
+	}
+	health.Default.Remove(hd.healthName())
+
+	if hd.borrowed {
+		if r, ok := hd.Connection.(Releaser); ok {
+			return r.Release()
+		}
+		return nil
+	}
 	return hd.Connection.Close()
 }
 
@@ -424,16 +728,95 @@ type Connection interface {
 	// BacklightOn turns the optional backlight on.
 	BacklightOn() error
 
-	// Close closes all open resources.
+	// Close closes all open resources, even when closing one of them
+	// fails, and returns the errors joined. Closing it again does nothing.
 	Close() error
 }
 
+// Releaser is implemented by connections which can release the pins or the
+// bus they use without closing them.
+type Releaser interface {
+	// Release releases the claims of the connection. The connection cannot
+	// be used anymore.
+	Release() error
+}
+
+// BatchWriter is implemented by connections which can write several bytes
+// in a single bus transaction, saving the overhead of one transaction per
+// byte. The connections fall back to a transaction per byte when their bus
+// cannot.
+type BatchWriter interface {
+	// WriteBatch writes bytes with a register select flag, like calling
+	// Write for each of them.
+	WriteBatch(rs bool, data []byte) error
+}
+
+// BusyPoller is implemented by connections which can poll the busy flag of
+// the controller through its RW line, to return from a write as soon as the
+// controller is ready.
+type BusyPoller interface {
+	// SetBusyPolling turns polling the busy flag on or off. It returns
+	// embd.ErrFeatureNotSupported when the RW line is not wired.
+	SetBusyPolling(on bool) error
+
+	// BusyPolling returns whether the busy flag is polled. It turns false
+	// when polling falls back to the fixed delays.
+	BusyPolling() bool
+}
+
+// busyPoll waits for the controller after writes, polling its busy flag
+// when on.
+type busyPoll struct {
+	on bool
+}
+
+// wait waits for the controller to be ready, polling its busy flag with read
+// when on, or sleeping the fixed delay otherwise. A failed read or a flag
+// still set after busyTimeout, like with the RW line tied to ground, turns
+// polling off for good.
+func (p *busyPoll) wait(read func(rs bool) (byte, error)) {
+	if !p.on {
+		Clock.Sleep(writeDelay)
+		return
+	}
+	deadline := Clock.Now().Add(busyTimeout)
+	for {
+		v, err := read(false)
+		if err != nil {
+			glog.Warningf("hd44780: reading busy flag: %v, falling back to fixed delays", err)
+			break
+		}
+		if v&busyFlag == 0 {
+			return
+		}
+		if Clock.Now().After(deadline) {
+			glog.Warning("hd44780: busy flag stuck, falling back to fixed delays")
+			break
+		}
+	}
+	p.on = false
+	// The instruction is unknown, wait for the slowest.
+	Clock.Sleep(clearDelay)
+}
+
 // GPIOConnection implements Connection using a 4-bit GPIO bus.
 type GPIOConnection struct {
 	RS, EN         embd.DigitalPin
 	D4, D5, D6, D7 embd.DigitalPin
 	Backlight      embd.DigitalPin
 	BLPolarity     BacklightPolarity
+
+	// RW is the optional read/write line, needed to read from the
+	// controller and to poll its busy flag. Leave it nil when the line is
+	// tied to ground. It is not claimed by NewGPIO, but closed with the
+	// connection.
+	RW embd.DigitalPin
+
+	data   embd.DigitalBus
+	rwSet  bool
+	busy   busyPoll
+	pace   *clock.Pacer
+	closed bool
 }
 
 // NewGPIOConnection returns a new Connection based on a 4-bit GPIO bus.
@@ -479,22 +862,27 @@ func (conn *GPIOConnection) backlightSignal(state bool) int {
 
 // Write writes a register select flag and byte to the 4-bit GPIO connection.
 func (conn *GPIOConnection) Write(rs bool, data byte) error {
+	if conn.closed {
+		return embd.ErrClosed
+	}
 	glog.V(3).Infof("hd44780: writing to GPIO RS: %t, data: %#x", rs, data)
 	rsInt := embd.Low
 	if rs {
 		rsInt = embd.High
 	}
+	// The data lines are written together where the GPIO driver is an
+	// embd.DigitalBusDriver, and one by one otherwise.
+	if conn.data == nil {
+		conn.data = embd.NewDigitalBus(conn.D4, conn.D5, conn.D6, conn.D7)
+	}
+	if err := conn.setRW(); err != nil {
+		return err
+	}
 	functions := []func() error{
 		func() error { return conn.RS.Write(rsInt) },
-		func() error { return conn.D4.Write(int((data >> 4) & 0x01)) },
-		func() error { return conn.D5.Write(int((data >> 5) & 0x01)) },
-		func() error { return conn.D6.Write(int((data >> 6) & 0x01)) },
-		func() error { return conn.D7.Write(int((data >> 7) & 0x01)) },
+		func() error { return conn.data.Write(uint32(data >> 4)) },
 		func() error { return conn.pulseEnable() },
-		func() error { return conn.D4.Write(int(data & 0x01)) },
-		func() error { return conn.D5.Write(int((data >> 1) & 0x01)) },
-		func() error { return conn.D6.Write(int((data >> 2) & 0x01)) },
-		func() error { return conn.D7.Write(int((data >> 3) & 0x01)) },
+		func() error { return conn.data.Write(uint32(data & 0x0f)) },
 		func() error { return conn.pulseEnable() },
 	}
 	for _, f := range functions {
@@ -503,14 +891,132 @@ func (conn *GPIOConnection) Write(rs bool, data byte) error {
 			return err
 		}
 	}
-	time.Sleep(writeDelay)
+	conn.busy.wait(conn.read)
+	return nil
+}
+
+// setRW drives the optional RW line low for writing, the first time.
+func (conn *GPIOConnection) setRW() error {
+	if conn.RW == nil || conn.rwSet {
+		return nil
+	}
+	if err := conn.RW.SetDirection(embd.Out); err != nil {
+		return err
+	}
+	if err := conn.RW.Write(embd.Low); err != nil {
+		return err
+	}
+	conn.rwSet = true
+	return nil
+}
+
+// dataPins returns the data lines, from D4 to D7.
+func (conn *GPIOConnection) dataPins() []embd.DigitalPin {
+	return []embd.DigitalPin{conn.D4, conn.D5, conn.D6, conn.D7}
+}
+
+// read reads a byte through the RW line, turning the data lines into inputs
+// while the controller drives them.
+func (conn *GPIOConnection) read(rs bool) (byte, error) {
+	if conn.closed {
+		return 0, embd.ErrClosed
+	}
+	if conn.RW == nil {
+		return 0, embd.ErrFeatureNotSupported
+	}
+	if err := conn.setRW(); err != nil {
+		return 0, err
+	}
+	data, err := conn.readNibbles(rs)
+	// The lines are turned back for writing even when reading failed.
+	if werr := conn.RW.Write(embd.Low); err == nil {
+		err = werr
+	}
+	for _, pin := range conn.dataPins() {
+		if derr := pin.SetDirection(embd.Out); err == nil {
+			err = derr
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	glog.V(3).Infof("hd44780: read from GPIO RS: %t, data: %#x", rs, data)
+	return data, nil
+}
+
+func (conn *GPIOConnection) readNibbles(rs bool) (byte, error) {
+	for _, pin := range conn.dataPins() {
+		if err := pin.SetDirection(embd.In); err != nil {
+			return 0, err
+		}
+	}
+	rsInt := embd.Low
+	if rs {
+		rsInt = embd.High
+	}
+	if err := conn.RS.Write(rsInt); err != nil {
+		return 0, err
+	}
+	if err := conn.RW.Write(embd.High); err != nil {
+		return 0, err
+	}
+	var data byte
+	for _, shift := range []uint{4, 0} {
+		Clock.Sleep(pulseDelay)
+		if err := conn.EN.Write(embd.High); err != nil {
+			return 0, err
+		}
+		Clock.Sleep(pulseDelay)
+		for i, pin := range conn.dataPins() {
+			v, err := pin.Read()
+			if err != nil {
+				return 0, err
+			}
+			if v == embd.High {
+				data |= 1 << (shift + uint(i))
+			}
+		}
+		if err := conn.EN.Write(embd.Low); err != nil {
+			return 0, err
+		}
+	}
+	return data, nil
+}
+
+// Read reads a register select flag and byte from the 4-bit GPIO
+// connection. It returns embd.ErrFeatureNotSupported without the RW line.
+func (conn *GPIOConnection) Read(rs bool) (byte, error) {
+	data, err := conn.read(rs)
+	if err != nil {
+		return 0, err
+	}
+	conn.busy.wait(conn.read)
+	return data, nil
+}
+
+// SetBusyPolling turns polling the busy flag on or off. It returns
+// embd.ErrFeatureNotSupported without the RW line.
+func (conn *GPIOConnection) SetBusyPolling(on bool) error {
+	if on && conn.RW == nil {
+		return embd.ErrFeatureNotSupported
+	}
+	conn.busy.on = on
 	return nil
 }
 
+// BusyPolling returns whether the busy flag is polled.
+func (conn *GPIOConnection) BusyPolling() bool { return conn.busy.on }
+
+// pulseEnable pulses the EN line, pacing the pulse delays so that pin
+// writes slower than them are not slowed down further.
 func (conn *GPIOConnection) pulseEnable() error {
+	if conn.pace == nil {
+		conn.pace = clock.NewPacer(Clock)
+	}
 	values := []int{embd.Low, embd.High, embd.Low}
 	for _, v := range values {
-		time.Sleep(pulseDelay)
+		conn.pace.Wait()
+		conn.pace.Delay(pulseDelay)
 		err := conn.EN.Write(v)
 		if err != nil {
 			return err
@@ -519,10 +1025,8 @@ func (conn *GPIOConnection) pulseEnable() error {
 	return nil
 }
 
-// Close closes all open DigitalPins.
-func (conn *GPIOConnection) Close() error {
-	glog.V(2).Info("hd44780: closing all GPIO pins")
-	pins := []embd.DigitalPin{
+func (conn *GPIOConnection) pins() []embd.DigitalPin {
+	return []embd.DigitalPin{
 		conn.RS,
 		conn.EN,
 		conn.D4,
@@ -530,24 +1034,87 @@ func (conn *GPIOConnection) Close() error {
 		conn.D6,
 		conn.D7,
 		conn.Backlight,
+		conn.RW,
 	}
+}
 
-	for _, pin := range pins {
-		err := pin.Close()
-		if err != nil {
+// Release releases the claims of the pins without closing them. The
+// connection cannot be used anymore.
+func (conn *GPIOConnection) Release() error {
+	if !conn.closed {
+		conn.closed = true
+		unclaim(conn.pins())
+	}
+	return nil
+}
+
+// Close closes all open DigitalPins, skipping the optional backlight and RW
+// line when they are not set.
+func (conn *GPIOConnection) Close() error {
+	if conn.closed {
+		return nil
+	}
+	conn.Release()
+
+	glog.V(2).Info("hd44780: closing all GPIO pins")
+	var errs []error
+	if c, ok := conn.data.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			glog.Errorf("hd44780: error closing data bus: %s", err)
+			errs = append(errs, err)
+		}
+	}
+	for _, pin := range conn.pins() {
+		if pin == nil {
+			continue
+		}
+		if err := pin.Close(); err != nil {
 			glog.Errorf("hd44780: error closing pin %+v: %s", pin, err)
-			return err
+			errs = append(errs, err)
 		}
 	}
+	return errors.Join(errs...)
+}
+
+// backpackAddrs are the addresses of the PCF8574 (0x20 to 0x27) and
+// PCF8574A (0x38 to 0x3f) expanders, set by the jumpers of the backpacks.
+var backpackAddrs = [][2]byte{{0x20, 0x27}, {0x38, 0x3f}}
+
+// ValidAddr returns an error when addr is not a 7-bit I²C address a device
+// can have, outside of the reserved ones 0x00 to 0x07 and 0x78 to 0x7f.
+func ValidAddr(addr byte) error {
+	if addr < 0x08 || addr > 0x77 {
+		return fmt.Errorf("hd44780: invalid I2C address %#x", addr)
+	}
 	return nil
 }
 
+// ScanI2C returns the addresses of the PCF8574 and PCF8574A expanders
+// answering on the bus, where backpacks usually are. Reading an expander
+// leaves its outputs alone.
+func ScanI2C(i2c embd.I2CBus) []byte {
+	var found []byte
+	for _, r := range backpackAddrs {
+		for addr := r[0]; addr <= r[1]; addr++ {
+			if _, err := i2c.ReadByte(addr); err != nil {
+				continue
+			}
+			glog.V(1).Infof("hd44780: found an I2C expander at %#x", addr)
+			found = append(found, addr)
+		}
+	}
+	return found
+}
+
 // I2CConnection implements Connection using an I²C bus.
 type I2CConnection struct {
 	I2C       embd.I2CBus
 	Addr      byte
 	PinMap    I2CPinMap
 	Backlight bool
+
+	busy   busyPoll
+	closed bool
 }
 
 // I2CPinMap represents a mapping between the pins on an I²C port expander and
@@ -597,42 +1164,65 @@ func (conn *I2CConnection) BacklightOn() error {
 	return conn.Write(false, 0x00)
 }
 
+// protocol returns the encoding of the writes through the pin map.
+func (m I2CPinMap) protocol() protocol.PinMap {
+	return protocol.PinMap{
+		RS: m.RS, RW: m.RW, EN: m.EN,
+		D4: m.D4, D5: m.D5, D6: m.D6, D7: m.D7,
+		Backlight:     m.Backlight,
+		BacklightHigh: bool(m.BLPolarity),
+	}
+}
+
 // Write writes a register select flag and byte to the I²C connection.
 func (conn *I2CConnection) Write(rs bool, data byte) error {
-	var instructionHigh byte = 0x00
-	instructionHigh |= ((data >> 4) & 0x01) << conn.PinMap.D4
-	instructionHigh |= ((data >> 5) & 0x01) << conn.PinMap.D5
-	instructionHigh |= ((data >> 6) & 0x01) << conn.PinMap.D6
-	instructionHigh |= ((data >> 7) & 0x01) << conn.PinMap.D7
-
-	var instructionLow byte = 0x00
-	instructionLow |= (data & 0x01) << conn.PinMap.D4
-	instructionLow |= ((data >> 1) & 0x01) << conn.PinMap.D5
-	instructionLow |= ((data >> 2) & 0x01) << conn.PinMap.D6
-	instructionLow |= ((data >> 3) & 0x01) << conn.PinMap.D7
-
-	instructions := []byte{instructionHigh, instructionLow}
-	for _, ins := range instructions {
-		if rs {
-			ins |= 0x01 << conn.PinMap.RS
-		}
-		if conn.Backlight == bool(conn.PinMap.BLPolarity) {
-			ins |= 0x01 << conn.PinMap.Backlight
-		}
+	if conn.closed {
+		return embd.ErrClosed
+	}
+	for _, ins := range conn.PinMap.protocol().Nibbles(rs, conn.Backlight, data) {
 		glog.V(3).Infof("hd44780: writing to I2C: %#x", ins)
 		err := conn.pulseEnable(ins)
 		if err != nil {
 			return err
 		}
 	}
-	time.Sleep(writeDelay)
+	conn.busy.wait(conn.read)
+	return nil
+}
+
+// WriteBatch writes bytes with a register select flag in a single I²C
+// transfer when the bus is an embd.I2CTransferWriter, and like calling Write
+// for each of them otherwise: WriteBytes of the generic bus waits between
+// the bytes, which would make the batch slower than the single writes. The
+// expander latches each byte of the transfer, and sending a byte takes
+// longer than the controller needs between them, even at 400kHz.
+func (conn *I2CConnection) WriteBatch(rs bool, data []byte) error {
+	if conn.closed {
+		return embd.ErrClosed
+	}
+	if tw, ok := conn.I2C.(embd.I2CTransferWriter); ok {
+		glog.V(3).Infof("hd44780: writing %v bytes to I2C RS: %t", len(data), rs)
+		states := conn.PinMap.protocol().Encode(rs, conn.Backlight, data)
+		err := tw.WriteTransfer(conn.Addr, states)
+		if err == nil {
+			conn.busy.wait(conn.read)
+			return nil
+		}
+		if err != embd.ErrFeatureNotSupported {
+			return err
+		}
+	}
+	for _, v := range data {
+		if err := conn.Write(rs, v); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (conn *I2CConnection) pulseEnable(data byte) error {
-	bytes := []byte{data, data | (0x01 << conn.PinMap.EN), data}
-	for _, b := range bytes {
-		time.Sleep(pulseDelay)
+	for _, b := range conn.PinMap.protocol().Pulses(data) {
+		Clock.Sleep(pulseDelay)
 		err := conn.I2C.WriteByte(conn.Addr, b)
 		if err != nil {
 			return err
@@ -641,8 +1231,72 @@ func (conn *I2CConnection) pulseEnable(data byte) error {
 	return nil
 }
 
+// Read reads a register select flag and byte from the I²C connection,
+// through the RW line of the backpack. The display must run at the voltage
+// of the I²C bus, as it drives the data lines while being read.
+func (conn *I2CConnection) Read(rs bool) (byte, error) {
+	data, err := conn.read(rs)
+	if err != nil {
+		return 0, err
+	}
+	conn.busy.wait(conn.read)
+	return data, nil
+}
+
+// SetBusyPolling turns polling the busy flag on or off.
+func (conn *I2CConnection) SetBusyPolling(on bool) error {
+	conn.busy.on = on
+	return nil
+}
+
+// BusyPolling returns whether the busy flag is polled.
+func (conn *I2CConnection) BusyPolling() bool { return conn.busy.on }
+
+func (conn *I2CConnection) read(rs bool) (byte, error) {
+	if conn.closed {
+		return 0, embd.ErrClosed
+	}
+	m := conn.PinMap.protocol()
+	ins := m.ReadState(rs, conn.Backlight)
+
+	var data byte
+	for _, shift := range []uint{4, 0} {
+		for _, b := range []byte{ins, ins | (0x01 << conn.PinMap.EN)} {
+			Clock.Sleep(pulseDelay)
+			if err := conn.I2C.WriteByte(conn.Addr, b); err != nil {
+				return 0, err
+			}
+		}
+		v, err := conn.I2C.ReadByte(conn.Addr)
+		if err != nil {
+			return 0, err
+		}
+		if err := conn.I2C.WriteByte(conn.Addr, ins); err != nil {
+			return 0, err
+		}
+		data |= m.Decode(v) << shift
+	}
+	glog.V(3).Infof("hd44780: read from I2C RS: %t, data: %#x", rs, data)
+	return data, nil
+}
+
+// Release releases the claim of the device address without closing the
+// bus. The connection cannot be used anymore.
+func (conn *I2CConnection) Release() error {
+	if !conn.closed {
+		conn.closed = true
+		embd.Unclaim(embd.I2CDevice(conn.I2C, conn.Addr))
+	}
+	return nil
+}
+
 // Close closes the I²C connection.
 func (conn *I2CConnection) Close() error {
+	if conn.closed {
+		return nil
+	}
+	conn.Release()
+
 	glog.V(2).Info("hd44780: closing I2C bus")
 	return conn.I2C.Close()
 }
@@ -1,12 +1,14 @@
 package hd44780
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
 )
 
 const (
@@ -38,6 +40,7 @@ func (pin *mockDigitalPin) TimePulse(state int) (time.Duration, error)
 func (pin *mockDigitalPin) ActiveLow(b bool) error                                    { return nil }
 func (pin *mockDigitalPin) PullUp() error                                             { return nil }
 func (pin *mockDigitalPin) PullDown() error                                           { return nil }
+func (pin *mockDigitalPin) SetDebounce(d time.Duration) error                         { return nil }
 
 func (pin *mockDigitalPin) Write(val int) error {
 	pin.values <- val
@@ -206,6 +209,34 @@ func TestGPIOConnectionClose(t *testing.T) {
 	}
 }
 
+type failingPin struct {
+	*mockDigitalPin
+}
+
+func (pin failingPin) Close() error {
+	pin.closed = true
+	return errors.New("close failed")
+}
+
+func TestGPIOConnectionClose_partialFailure(t *testing.T) {
+	mock := newMockGPIOConnection()
+	conn := NewGPIOConnection(mock.rs, failingPin{mock.en}, mock.d4, mock.d5, mock.d6, mock.d7, nil, Negative)
+	if err := conn.Close(); err == nil {
+		t.Error("closing a failing pin succeeded")
+	}
+	for idx, pin := range mock.pins()[:6] {
+		if !pin.closed {
+			t.Errorf("Pin %d was not closed", idx)
+		}
+	}
+	if err := conn.Close(); err != nil {
+		t.Errorf("closing again: %v", err)
+	}
+	if err := conn.Write(true, 'a'); !errors.Is(err, embd.ErrClosed) {
+		t.Errorf("writing after closing: got %v, want embd.ErrClosed", err)
+	}
+}
+
 func TestI2CConnectionPinMap(t *testing.T) {
 	cases := []map[string]interface{}{
 		map[string]interface{}{
@@ -263,6 +294,29 @@ func TestI2CConnectionClose(t *testing.T) {
 	if !i2c.closed {
 		t.Error("I2C bus was not closed")
 	}
+	i2c.closed = false
+	if err := conn.Close(); err != nil || i2c.closed {
+		t.Errorf("closing again: closed the bus again with error %v", err)
+	}
+}
+
+func TestBorrowed(t *testing.T) {
+	i2c := newMockI2CBus()
+	hd, err := NewI2C(i2c, testAddr, PCF8574PinMap, testRowAddr, Borrowed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if i2c.closed {
+		t.Error("borrowed I2C bus was closed")
+	}
+	device := embd.I2CDevice(i2c, testAddr)
+	if err := embd.Claim(device, "test"); err != nil {
+		t.Errorf("device still claimed after Close: %v", err)
+	}
+	embd.Unclaim(device)
 }
 
 func TestNewGPIO_initPins(t *testing.T) {
@@ -316,3 +370,169 @@ func TestDefaultModes(t *testing.T) {
 		t.Error("Expected display to be initialized in blink off mode")
 	}
 }
+
+func TestNewGPIO_claimed(t *testing.T) {
+	mock := newMockGPIOConnection()
+	hd, err := NewGPIO(mock.rs, mock.en, mock.d4, mock.d5, mock.d6, mock.d7, mock.backlight, Negative, testRowAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := newMockGPIOConnection()
+	_, err = NewGPIO(other.rs, mock.en, other.d4, other.d5, other.d6, other.d7, nil, Negative, testRowAddr)
+	if _, ok := err.(*embd.ClaimError); !ok {
+		t.Fatalf("sharing the EN pin: got %v, want a claim error", err)
+	}
+	if _, ok := embd.Owner(other.rs); ok {
+		t.Error("RS pin still claimed after the failure")
+	}
+
+	hd.Close()
+	if _, err := NewGPIO(other.rs, mock.en, other.d4, other.d5, other.d6, other.d7, nil, Negative, testRowAddr); err != nil {
+		t.Errorf("EN pin still claimed after Close: %v", err)
+	}
+}
+
+// busyI2CBus reports the busy flag set for the first busy reads, through D7
+// of the PCF8574 pin map.
+type busyI2CBus struct {
+	*mockI2CBus
+	busy int
+}
+
+func (bus *busyI2CBus) ReadByte(addr byte) (byte, error) {
+	if bus.busy != 0 {
+		bus.busy--
+		return 0x80, nil
+	}
+	return 0x00, nil
+}
+
+func virtualClock() func() {
+	c := clock.NewVirtual(time.Time{})
+	c.AutoAdvance = true
+	saved := Clock
+	Clock = c
+	return func() { Clock = saved }
+}
+
+func TestBusyFlag(t *testing.T) {
+	defer virtualClock()()
+
+	conn := NewI2CConnection(&busyI2CBus{mockI2CBus: newMockI2CBus(), busy: 4}, testAddr, PCF8574PinMap)
+	hd, err := New(conn, testRowAddr, BusyFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !conn.BusyPolling() {
+		t.Fatal("busy flag not polled")
+	}
+	start := Clock.Now()
+	if err := hd.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if d := Clock.Now().Sub(start); d >= clearDelay {
+		t.Errorf("clearing took %v, want less than the fixed %v", d, clearDelay)
+	}
+
+	if err := hd.SetMode(FixedDelays); err != nil {
+		t.Fatal(err)
+	}
+	if conn.BusyPolling() {
+		t.Error("busy flag still polled with FixedDelays")
+	}
+}
+
+func TestBusyFlag_stuck(t *testing.T) {
+	defer virtualClock()()
+
+	conn := NewI2CConnection(&busyI2CBus{mockI2CBus: newMockI2CBus(), busy: -1}, testAddr, PCF8574PinMap)
+	hd, err := New(conn, testRowAddr, BusyFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.BusyPolling() {
+		t.Error("still polling a stuck busy flag")
+	}
+	if err := hd.Clear(); err != nil {
+		t.Errorf("clearing with the fixed delays: %v", err)
+	}
+}
+
+func TestBusyFlag_noRW(t *testing.T) {
+	mock := newMockGPIOConnection()
+	conn := NewGPIOConnection(mock.rs, mock.en, mock.d4, mock.d5, mock.d6, mock.d7, nil, Negative)
+	if _, err := New(conn, testRowAddr, BusyFlag); err != nil {
+		t.Fatalf("falling back to the fixed delays: %v", err)
+	}
+	if conn.BusyPolling() {
+		t.Error("busy flag polled without the RW line")
+	}
+	if _, err := conn.Read(false); err != embd.ErrFeatureNotSupported {
+		t.Errorf("reading without the RW line: got %v, want embd.ErrFeatureNotSupported", err)
+	}
+}
+
+// highPin is a digital pin reading high.
+type highPin struct {
+	*mockDigitalPin
+}
+
+func (pin highPin) Read() (int, error) { return embd.High, nil }
+
+func TestGPIOConnectionRead(t *testing.T) {
+	defer virtualClock()()
+
+	rw, d7 := newMockDigitalPin(), newMockDigitalPin()
+	d := []*mockDigitalPin{newMockDigitalPin(), newMockDigitalPin(), newMockDigitalPin(), d7}
+	conn := NewGPIOConnection(newMockDigitalPin(), newMockDigitalPin(), d[0], d[1], d[2], highPin{d7}, nil, Negative)
+	conn.RW = rw
+
+	v, err := conn.read(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 0x88 {
+		t.Errorf("read %#x, want 0x88", v)
+	}
+	for i, pin := range d {
+		if pin.direction != embd.Out {
+			t.Errorf("D%d not set back to direction Out", i+4)
+		}
+	}
+	if got := []int{<-rw.values, <-rw.values, <-rw.values}; !reflect.DeepEqual(got, []int{embd.Low, embd.High, embd.Low}) {
+		t.Errorf("RW driven %v, want low, high and low", got)
+	}
+}
+
+// scanBus is an I2C bus with a device at addr only.
+type scanBus struct {
+	*mockI2CBus
+	addr byte
+}
+
+func (bus *scanBus) ReadByte(addr byte) (byte, error) {
+	if addr != bus.addr {
+		return 0, embd.ErrNAK
+	}
+	return 0xff, nil
+}
+
+func TestScanI2C(t *testing.T) {
+	for _, addr := range []byte{0x27, 0x3f} {
+		if got := ScanI2C(&scanBus{newMockI2CBus(), addr}); !reflect.DeepEqual(got, []byte{addr}) {
+			t.Errorf("found %#x, want %#x", got, addr)
+		}
+	}
+	if got := ScanI2C(&scanBus{newMockI2CBus(), 0x50}); len(got) != 0 {
+		t.Errorf("found %#x on a bus without expanders", got)
+	}
+}
+
+func TestNewI2C_invalidAddr(t *testing.T) {
+	for _, addr := range []byte{0x00, 0x07, 0x78} {
+		if _, err := NewI2C(newMockI2CBus(), addr, PCF8574PinMap, testRowAddr); err == nil {
+			t.Errorf("created a display at %#x", addr)
+		}
+	}
+}
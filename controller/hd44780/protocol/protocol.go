@@ -0,0 +1,86 @@
+/*
+Package protocol encodes the writes to an HD44780 controller through the
+8-bit port expander of an I²C backpack or a shift register. It depends on
+nothing but the language, so that it builds with TinyGo for
+microcontrollers as well as for the hosts of embd.
+
+Each byte is written as two nibbles, and each nibble latched by pulsing the
+enable line, so a byte takes six states of the expander outputs. With
+TinyGo, the states are written to the expander in one transaction:
+
+	m := protocol.PinMap{RS: 0, RW: 1, EN: 2, D4: 4, D5: 5, D6: 6, D7: 7, Backlight: 3, BacklightHigh: true}
+	machine.I2C0.Tx(0x27, m.Encode(true, true, []byte("hello")), nil)
+*/
+package protocol
+
+// PinMap maps the outputs of the expander, numbered 0 to 7, to the pins of
+// the controller.
+type PinMap struct {
+	RS, RW, EN     byte
+	D4, D5, D6, D7 byte
+	Backlight      byte
+	// BacklightHigh is set when the backlight is on with its output high.
+	BacklightHigh bool
+}
+
+// base returns the state of the outputs with the register select flag and
+// the backlight, and the other lines low.
+func (m PinMap) base(rs, backlight bool) byte {
+	var ins byte
+	if rs {
+		ins |= 0x01 << m.RS
+	}
+	if backlight == m.BacklightHigh {
+		ins |= 0x01 << m.Backlight
+	}
+	return ins
+}
+
+// nibble returns the state of the data lines writing the low nibble of v.
+func (m PinMap) nibble(v byte) byte {
+	return (v&0x01)<<m.D4 |
+		(v>>1&0x01)<<m.D5 |
+		(v>>2&0x01)<<m.D6 |
+		(v>>3&0x01)<<m.D7
+}
+
+// Nibbles returns the two states of the outputs writing the high and then
+// the low nibble of data, with the enable line low.
+func (m PinMap) Nibbles(rs, backlight bool, data byte) [2]byte {
+	base := m.base(rs, backlight)
+	return [2]byte{base | m.nibble(data>>4), base | m.nibble(data&0x0f)}
+}
+
+// Pulses returns the states of the outputs pulsing the enable line with
+// the other outputs in state ins.
+func (m PinMap) Pulses(ins byte) []byte {
+	return []byte{ins, ins | (0x01 << m.EN), ins}
+}
+
+// Encode returns the states of the outputs writing data with a register
+// select flag: six states per byte.
+func (m PinMap) Encode(rs, backlight bool, data []byte) []byte {
+	states := make([]byte, 0, len(data)*6)
+	for _, v := range data {
+		for _, ins := range m.Nibbles(rs, backlight, v) {
+			states = append(states, m.Pulses(ins)...)
+		}
+	}
+	return states
+}
+
+// ReadState returns the state of the outputs reading from the controller:
+// the RW line high, and the data lines released high so that the
+// controller can drive them.
+func (m PinMap) ReadState(rs, backlight bool) byte {
+	return m.base(rs, backlight) | 0x01<<m.RW | m.nibble(0x0f)
+}
+
+// Decode returns the nibble on the data lines in the state v of the
+// expander pins.
+func (m PinMap) Decode(v byte) byte {
+	return (v>>m.D4)&0x01 |
+		(v>>m.D5)&0x01<<1 |
+		(v>>m.D6)&0x01<<2 |
+		(v>>m.D7)&0x01<<3
+}
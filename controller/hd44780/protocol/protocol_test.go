@@ -0,0 +1,28 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+var pcf8574 = PinMap{RS: 0, RW: 1, EN: 2, D4: 4, D5: 5, D6: 6, D7: 7, Backlight: 3, BacklightHigh: true}
+
+func TestEncode(t *testing.T) {
+	got := pcf8574.Encode(true, true, []byte("A"))
+	want := []byte{0x49, 0x4d, 0x49, 0x19, 0x1d, 0x19}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %#x, want %#x", got, want)
+	}
+	if got := pcf8574.Nibbles(false, false, 0x33); got != [2]byte{0x30, 0x30} {
+		t.Errorf("instruction: got %#x, want [0x30 0x30]", got)
+	}
+}
+
+func TestRead(t *testing.T) {
+	if got := pcf8574.ReadState(false, true); got != 0xfa {
+		t.Errorf("read state: got %#x, want 0xfa", got)
+	}
+	if got := pcf8574.Decode(0xa9); got != 0x0a {
+		t.Errorf("decode: got %#x, want 0xa", got)
+	}
+}
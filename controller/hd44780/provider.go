@@ -0,0 +1,73 @@
+// Display provider.
+
+package hd44780
+
+import (
+	"fmt"
+
+	"github.com/kidoman/embd"
+)
+
+type provider struct{}
+
+func (provider) Name() string            { return "hd44780" }
+func (provider) Kind() embd.ProviderKind { return embd.DisplayProviders }
+
+// Open opens a display on an I²C backpack, with the parameters bus (1),
+// addr (0x27, or 0 to scan for the backpack), pinmap (pcf8574 or mjkdz),
+// cols (16 or 20) and rows.
+func (provider) Open(params embd.Params) (interface{}, error) {
+	bus, err := params.Int("bus", 1)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := params.Int("addr", 0x27)
+	if err != nil {
+		return nil, err
+	}
+	var pinMap I2CPinMap
+	switch m := params.String("pinmap", "pcf8574"); m {
+	case "pcf8574":
+		pinMap = PCF8574PinMap
+	case "mjkdz":
+		pinMap = MJKDZPinMap
+	default:
+		return nil, fmt.Errorf("hd44780: unknown pin map %q", m)
+	}
+	cols, err := params.Int("cols", 20)
+	if err != nil {
+		return nil, err
+	}
+	rowAddr := RowAddress20Col
+	if cols == 16 {
+		rowAddr = RowAddress16Col
+	}
+	rows, err := params.Int("rows", 4)
+	if err != nil {
+		return nil, err
+	}
+	lines := OneLine
+	if rows > 1 {
+		lines = TwoLine
+	}
+
+	if err := embd.InitI2C(); err != nil {
+		return nil, err
+	}
+	// The buses of embd are shared, and closed by embd.CloseI2C.
+	i2c := embd.NewI2CBus(byte(bus))
+	if addr == 0 {
+		found := ScanI2C(i2c)
+		if len(found) != 1 {
+			return nil, fmt.Errorf("hd44780: found %v I2C expanders on bus %v, set the address", len(found), bus)
+		}
+		addr = int(found[0])
+	}
+	return NewI2C(i2c, byte(addr), pinMap, rowAddr, lines, Borrowed)
+}
+
+func init() {
+	if err := embd.RegisterProvider(provider{}); err != nil {
+		panic(err)
+	}
+}
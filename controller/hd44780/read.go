@@ -0,0 +1,101 @@
+// Reading back.
+
+package hd44780
+
+import (
+	"github.com/kidoman/embd"
+)
+
+// reader returns the connection as a Reader, or embd.ErrFeatureNotSupported.
+func (hd *HD44780) reader() (Reader, error) {
+	r, ok := hd.Connection.(Reader)
+	if !ok {
+		return nil, embd.ErrFeatureNotSupported
+	}
+	return r, nil
+}
+
+// ReadInstruction reads the busy flag and the address counter of the
+// controller, addressing the DDRAM or the CGRAM depending on the last
+// address set. It returns embd.ErrFeatureNotSupported when the connection
+// is not a Reader.
+func (hd *HD44780) ReadInstruction() (busy bool, addr byte, err error) {
+	r, err := hd.reader()
+	if err != nil {
+		return false, 0, err
+	}
+
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	v, err := r.Read(false)
+	if err != nil {
+		return false, 0, err
+	}
+	return v&busyFlag != 0, v &^ busyFlag, nil
+}
+
+// ReadChar reads the RAM at the address counter, and advances the counter
+// as writing does, without shifting the display. The controller only reads
+// the right RAM after the address was set or the cursor moved, so call
+// SetCursor, SetDDRamAddr or ShiftLeft and ShiftRight first. It returns
+// embd.ErrFeatureNotSupported when the connection is not a Reader.
+func (hd *HD44780) ReadChar() (byte, error) {
+	r, err := hd.reader()
+	if err != nil {
+		return 0, err
+	}
+
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	v, err := r.Read(true)
+	if err != nil {
+		return 0, err
+	}
+	hd.shadow.advance(hd.EntryIncrementEnabled(), hd.TwoLineEnabled())
+	return v, nil
+}
+
+// ReadDDRam reads n characters of the display RAM from an address, including
+// the ones off-screen, following the lines like writing does. The address
+// counter and the entry mode are left as they were. It returns
+// embd.ErrFeatureNotSupported when the connection is not a Reader.
+func (hd *HD44780) ReadDDRam(addr byte, n int) ([]byte, error) {
+	r, err := hd.reader()
+	if err != nil {
+		return nil, err
+	}
+
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	err = hd.writeSequence(false, byte(lcdSetEntryMode|lcdEntryIncrement), lcdSetDDRamAddr|addr&(ddramSize-1))
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, n)
+	for i := range data {
+		if data[i], err = r.Read(true); err != nil {
+			break
+		}
+	}
+	if rerr := hd.restoreCursor(); err == nil {
+		err = rerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// restoreCursor writes the entry mode and the address counter of the
+// shadow back, after reading moved the counter. It must be called with the
+// lock held.
+func (hd *HD44780) restoreCursor() error {
+	addr := lcdSetDDRamAddr | hd.shadow.ac
+	if hd.shadow.cg {
+		addr = lcdSetCGRamAddr | hd.shadow.ac
+	}
+	return hd.writeSequence(false, byte(lcdSetEntryMode|hd.eMode), addr)
+}
@@ -0,0 +1,57 @@
+package hd44780
+
+import (
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+func TestRead(t *testing.T) {
+	lcd := &fakeLCD{}
+	lcd.ram.clear()
+	hd, err := New(lcd, RowAddress20Col, TwoLine)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Text running off the screen, written right to left.
+	hd.SetCursor(24, 0)
+	hd.SetRightToLeft(true)
+	hd.WriteChars([]byte("olleh"))
+
+	busy, addr, err := hd.ReadInstruction()
+	if err != nil || busy || addr != 19 {
+		t.Fatalf("ReadInstruction: got %v, %#02x, %v, want false, 0x13", busy, addr, err)
+	}
+	data, err := hd.ReadDDRam(20, 5)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("ReadDDRam: got %q, %v, want \"hello\"", data, err)
+	}
+	if lcd.ram.ac != 19 || lcd.eMode&lcdEntryIncrement != 0 {
+		t.Errorf("cursor not kept: got address %#02x, entry mode %#02x", lcd.ram.ac, lcd.eMode)
+	}
+
+	hd.SetCursor(24, 0)
+	for _, want := range []byte("ol") {
+		c, err := hd.ReadChar()
+		if err != nil || c != want {
+			t.Fatalf("ReadChar: got %q, %v, want %q", c, err, want)
+		}
+	}
+	if hd.shadow.ac != lcd.ram.ac {
+		t.Errorf("shadow address: got %#02x, want %#02x", hd.shadow.ac, lcd.ram.ac)
+	}
+}
+
+func TestRead_notSupported(t *testing.T) {
+	hd, err := New(&mockGPIOConnection{}, RowAddress16Col)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hd.ReadChar(); err != embd.ErrFeatureNotSupported {
+		t.Errorf("ReadChar: got %v, want embd.ErrFeatureNotSupported", err)
+	}
+	if _, err := hd.ReadDDRam(0, 16); err != embd.ErrFeatureNotSupported {
+		t.Errorf("ReadDDRam: got %v, want embd.ErrFeatureNotSupported", err)
+	}
+}
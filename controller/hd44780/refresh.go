@@ -0,0 +1,333 @@
+// Display refresh.
+
+package hd44780
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/health"
+)
+
+// Reader is implemented by connections which can read from the HD44780
+// controller, with the RW line wired.
+type Reader interface {
+	// Read reads a byte from the HD44780 controller with the register
+	// select flag either on or off. With the flag on, it reads the RAM at
+	// the address counter and advances the counter.
+	Read(rs bool) (byte, error)
+}
+
+const (
+	ddramSize = 0x80
+	cgramSize = 0x40
+
+	// The DDRAM lines and their width, in characters.
+	lineSize    = 0x28
+	oneLineSize = 0x50
+	secondLine  = 0x40
+
+	// Only the 5 low bits of a CGRAM byte are pixels of the characters.
+	cgramMask = 0x1f
+)
+
+// shadow is a copy of the state of the controller, kept up to date by
+// decoding the instructions and characters written to it.
+type shadow struct {
+	ddram [ddramSize]byte
+	cgram [cgramSize]byte
+
+	// ac is the address counter, addressing the CGRAM when cg is set.
+	ac byte
+	cg bool
+	// shift is the display shift, positive to the right.
+	shift int
+}
+
+func (s *shadow) clear() {
+	for i := range s.ddram {
+		s.ddram[i] = ' '
+	}
+	s.ac, s.cg, s.shift = 0, false, 0
+}
+
+// advance moves the address counter as the controller does.
+func (s *shadow) advance(inc, twoLine bool) {
+	switch {
+	case s.cg && inc:
+		s.ac = (s.ac + 1) % cgramSize
+	case s.cg:
+		s.ac = (s.ac + cgramSize - 1) % cgramSize
+	case twoLine && inc:
+		switch s.ac {
+		case lineSize - 1:
+			s.ac = secondLine
+		case secondLine + lineSize - 1:
+			s.ac = 0
+		default:
+			s.ac++
+		}
+	case twoLine:
+		switch s.ac {
+		case secondLine:
+			s.ac = lineSize - 1
+		case 0:
+			s.ac = secondLine + lineSize - 1
+		default:
+			s.ac--
+		}
+	case inc:
+		s.ac = (s.ac + 1) % oneLineSize
+	default:
+		s.ac = (s.ac + oneLineSize - 1) % oneLineSize
+	}
+}
+
+func (s *shadow) instruction(v byte, twoLine bool) {
+	switch {
+	case v&lcdSetDDRamAddr != 0:
+		s.ac, s.cg = v&(ddramSize-1), false
+	case v&lcdSetCGRamAddr != 0:
+		s.ac, s.cg = v&(cgramSize-1), true
+	case v&byte(lcdSetFunctionMode) != 0:
+	case v&lcdCursorShift != 0:
+		right := v&lcdMoveRight != 0
+		if v&lcdDisplayMove == 0 {
+			s.advance(right, twoLine)
+		} else if right {
+			s.shift++
+		} else {
+			s.shift--
+		}
+	case v&byte(lcdSetDisplayMode) != 0, v&byte(lcdSetEntryMode) != 0:
+	case v&lcdReturnHome != 0:
+		s.ac, s.cg, s.shift = 0, false, 0
+	case v&lcdClearDisplay != 0:
+		s.clear()
+	}
+}
+
+func (s *shadow) writeChar(v byte, mode entryMode, twoLine bool) {
+	inc := mode&lcdEntryIncrement != 0
+	if s.cg {
+		s.cgram[s.ac] = v
+		s.advance(inc, twoLine)
+		return
+	}
+	s.ddram[s.ac] = v
+	s.advance(inc, twoLine)
+	if mode&lcdEntryShiftOn == 0 {
+		return
+	}
+	if inc {
+		s.shift--
+	} else {
+		s.shift++
+	}
+}
+
+// lines returns the DDRAM address ranges of the lines.
+func (hd *HD44780) lines() [][2]byte {
+	if hd.TwoLineEnabled() {
+		return [][2]byte{{0, lineSize}, {secondLine, secondLine + lineSize}}
+	}
+	return [][2]byte{{0, oneLineSize}}
+}
+
+// writeSequence writes instructions and characters without updating the
+// shadow, stopping at the first error.
+func (hd *HD44780) writeSequence(rs bool, values ...byte) error {
+	for _, v := range values {
+		if err := hd.Write(rs, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore restores the display shift, the entry mode and the address
+// counter of the shadow, after the display was homed.
+func (hd *HD44780) restore() error {
+	width := oneLineSize
+	if hd.TwoLineEnabled() {
+		width = lineSize
+	}
+	shift := (hd.shadow.shift%width + width) % width
+	move := lcdCursorShift | lcdDisplayMove | lcdMoveRight
+	if shift > width/2 {
+		shift, move = width-shift, lcdCursorShift|lcdDisplayMove|lcdMoveLeft
+	}
+	for i := 0; i < shift; i++ {
+		if err := hd.Write(false, move); err != nil {
+			return err
+		}
+	}
+
+	return hd.restoreCursor()
+}
+
+// Refresh initializes the controller again and rewrites the display
+// contents, the custom characters and the cursor position, recovering from
+// corrupted RAM or a lost 4-bit synchronization.
+func (hd *HD44780) Refresh() error {
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	glog.V(2).Info("hd44780: refreshing display")
+
+	// The busy flag cannot be trusted before the controller is initialized
+	// again.
+	if hd.busyFlag {
+		if err := hd.setBusyPolling(false); err != nil {
+			return err
+		}
+		defer hd.setBusyPolling(true)
+	}
+
+	// The modes are written with the entry mode advancing the address
+	// counter, and without shifting the display.
+	err := hd.writeSequence(false,
+		lcdInit,
+		lcdInit4bit,
+		byte(lcdSetFunctionMode|hd.fMode),
+		byte(lcdSetDisplayMode|hd.dMode),
+		byte(lcdSetEntryMode|lcdEntryIncrement),
+		lcdSetCGRamAddr)
+	if err != nil {
+		return err
+	}
+	if err := hd.writeHeightFormat(); err != nil {
+		return err
+	}
+	if err := hd.writeSequence(true, hd.shadow.cgram[:]...); err != nil {
+		return err
+	}
+	for _, line := range hd.lines() {
+		if err := hd.Write(false, lcdSetDDRamAddr|line[0]); err != nil {
+			return err
+		}
+		if err := hd.writeSequence(true, hd.shadow.ddram[line[0]:line[1]]...); err != nil {
+			return err
+		}
+	}
+	if err := hd.Write(false, lcdReturnHome); err != nil {
+		return err
+	}
+	hd.homeWait()
+	return hd.restore()
+}
+
+// Verify reads the display contents and the custom characters back, and
+// returns whether they match what was written. It returns
+// embd.ErrFeatureNotSupported when the connection is not a Reader.
+func (hd *HD44780) Verify() (bool, error) {
+	r, err := hd.reader()
+	if err != nil {
+		return false, err
+	}
+
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	ranges := append([][2]byte{{0, cgramSize}}, hd.lines()...)
+	match := true
+	for i, rng := range ranges {
+		addr, ram, mask := lcdSetDDRamAddr|rng[0], hd.shadow.ddram[:], byte(0xff)
+		if i == 0 {
+			addr, ram, mask = lcdSetCGRamAddr, hd.shadow.cgram[:], cgramMask
+		}
+		if err := hd.writeSequence(false, byte(lcdSetEntryMode|lcdEntryIncrement), addr); err != nil {
+			return false, err
+		}
+		for a := rng[0]; a < rng[1]; a++ {
+			v, err := r.Read(true)
+			if err != nil {
+				return false, err
+			}
+			if v&mask != ram[a]&mask {
+				glog.V(1).Infof("hd44780: read %#02x at %#02x, wrote %#02x", v, a, ram[a])
+				match = false
+			}
+		}
+	}
+	// Reading does not shift the display.
+	return match, hd.restoreCursor()
+}
+
+// AutoRefresh refreshes the display at the interval, to recover from the
+// corruption electrical noise can cause. When the connection is a Reader,
+// the display is only refreshed when reading it back does not match what
+// was written. A zero interval stops refreshing, and so does closing the
+// display.
+func (hd *HD44780) AutoRefresh(interval time.Duration) {
+	hd.refreshMu.Lock()
+	defer hd.refreshMu.Unlock()
+
+	hd.stopRefresh()
+	if interval <= 0 || hd.closed {
+		return
+	}
+
+	hd.quit = make(chan struct{})
+	hd.done = make(chan struct{})
+
+	go func(quit, done chan struct{}) {
+		defer close(done)
+
+		ticker := Clock.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				hd.check()
+			case <-quit:
+				return
+			}
+		}
+	}(hd.quit, hd.done)
+}
+
+// stopRefresh stops the refresh goroutine and waits for it to return. It
+// must be called with refreshMu held.
+func (hd *HD44780) stopRefresh() {
+	if hd.quit == nil {
+		return
+	}
+	close(hd.quit)
+	<-hd.done
+	hd.quit = nil
+}
+
+// healthName returns the name of the display in the health registry.
+func (hd *HD44780) healthName() string {
+	if hd.HealthName == "" {
+		return "hd44780"
+	}
+	return hd.HealthName
+}
+
+// check refreshes the display if it is corrupted, or cannot be read back,
+// and reports the outcome to the health registry. A GPIO connection without
+// the RW line is refreshed without reading it back.
+func (hd *HD44780) check() {
+	if _, ok := hd.Connection.(Reader); ok {
+		match, err := hd.Verify()
+		switch {
+		case err == embd.ErrFeatureNotSupported:
+		case err != nil:
+			glog.Errorf("hd44780: reading display back: %v", err)
+		case match:
+			health.Observe(hd.healthName(), nil)
+			return
+		default:
+			glog.Warningf("hd44780: display contents corrupted, refreshing")
+		}
+	}
+	err := hd.Refresh()
+	if err != nil {
+		glog.Errorf("hd44780: refreshing display: %v", err)
+	}
+	health.Observe(hd.healthName(), err)
+}
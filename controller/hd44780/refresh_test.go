@@ -0,0 +1,228 @@
+package hd44780
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/health"
+)
+
+// fakeLCD emulates the RAM of an HD44780 controller with the RW line wired.
+type fakeLCD struct {
+	ram     shadow
+	eMode   entryMode
+	twoLine bool
+}
+
+func (lcd *fakeLCD) Write(rs bool, data byte) error {
+	if rs {
+		lcd.ram.writeChar(data, lcd.eMode, lcd.twoLine)
+		return nil
+	}
+	switch {
+	case data&0xe0 == byte(lcdSetFunctionMode):
+		lcd.twoLine = data&byte(lcd2Line) != 0
+	case data&0xfc == byte(lcdSetEntryMode):
+		lcd.eMode = entryMode(data) & (lcdEntryIncrement | lcdEntryShiftOn)
+	}
+	lcd.ram.instruction(data, lcd.twoLine)
+	return nil
+}
+
+func (lcd *fakeLCD) Read(rs bool) (byte, error) {
+	if !rs {
+		return lcd.ram.ac, nil
+	}
+	v := lcd.ram.ddram[lcd.ram.ac]
+	if lcd.ram.cg {
+		v = lcd.ram.cgram[lcd.ram.ac]
+	}
+	lcd.ram.advance(lcd.eMode&lcdEntryIncrement != 0, lcd.twoLine)
+	return v, nil
+}
+
+func (lcd *fakeLCD) BacklightOff() error { return nil }
+func (lcd *fakeLCD) BacklightOn() error  { return nil }
+func (lcd *fakeLCD) Close() error        { return nil }
+
+func TestRefresh(t *testing.T) {
+	lcd := &fakeLCD{}
+	lcd.ram.clear()
+	hd, err := New(lcd, RowAddress20Col, TwoLine)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A custom character, and text on both lines.
+	if err := hd.WriteInstruction(lcdSetCGRamAddr | 8); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range []byte{0x04, 0x0e, 0x1f, 0x0e, 0x04, 0x00, 0x00, 0x00} {
+		hd.WriteChar(b)
+	}
+	hd.SetDDRamAddr(lineSize - 2)
+	for _, b := range []byte("wrap") {
+		hd.WriteChar(b)
+	}
+	hd.SetCursor(3, 1)
+	hd.WriteChar(0x01)
+	hd.ShiftLeft()
+
+	if hd.shadow.ddram[lineSize-2] != 'w' || hd.shadow.ddram[secondLine] != 'a' || hd.shadow.ddram[secondLine+3] != 0x01 {
+		t.Error("shadow does not track the characters written")
+	}
+	if hd.shadow.ac != secondLine+4 || hd.shadow.shift != -1 {
+		t.Errorf("shadow address, shift: got %#02x, %v, want 0x44, -1", hd.shadow.ac, hd.shadow.shift)
+	}
+	if hd.shadow != lcd.ram {
+		t.Fatal("shadow does not match the display")
+	}
+	if ok, err := hd.Verify(); err != nil || !ok {
+		t.Fatalf("Verify: got %v, %v, want true", ok, err)
+	}
+
+	// Noise corrupting the display.
+	lcd.ram.ddram[secondLine+3] = 'x'
+	lcd.ram.cgram[10] = 0x00
+	lcd.ram.shift = 0
+	lcd.ram.ac = 0
+	if ok, err := hd.Verify(); err != nil || ok {
+		t.Fatalf("Verify of corrupted display: got %v, %v, want false", ok, err)
+	}
+
+	if err := hd.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if hd.shadow != lcd.ram {
+		t.Error("refreshed display does not match the shadow")
+	}
+	if ok, err := hd.Verify(); err != nil || !ok {
+		t.Errorf("Verify after refresh: got %v, %v, want true", ok, err)
+	}
+}
+
+func TestCreateChar(t *testing.T) {
+	lcd := &fakeLCD{}
+	hd, err := New(lcd, RowAddress16Col)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hd.SetCursor(5, 0)
+	rows := [8]byte{0x1f, 0x11, 0x11, 0x11, 0x11, 0x11, 0x1f, 0x00}
+	if err := hd.CreateChar(3, rows); err != nil {
+		t.Fatal(err)
+	}
+	var got [8]byte
+	copy(got[:], lcd.ram.cgram[3*8:])
+	if got != rows {
+		t.Errorf("glyph: got % x, want % x", got, rows)
+	}
+	if lcd.ram.cg || lcd.ram.ac != 5 {
+		t.Errorf("cursor not kept: got address %#02x", lcd.ram.ac)
+	}
+}
+
+func TestCheck_noRW(t *testing.T) {
+	c := clock.NewVirtual(time.Time{})
+	c.AutoAdvance = true
+	defer func(saved clock.Clock) { Clock = saved }(Clock)
+	Clock = c
+
+	pin := func() embd.DigitalPin { return &costPin{clock: c, cost: time.Microsecond} }
+	hd, err := New(NewGPIOConnection(pin(), pin(), pin(), pin(), pin(), pin(), nil, Positive), RowAddress16Col)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hd.HealthName = "lcd/front"
+	defer health.Default.Remove("lcd/front")
+
+	// Without the RW line, the display is refreshed without reading it.
+	hd.check()
+	found := false
+	for _, r := range health.Default.Reports() {
+		if r.Name == "hd44780" {
+			t.Error("reported under the default name")
+		}
+		if r.Name == "lcd/front" {
+			found = true
+			if r.Status != health.OK {
+				t.Errorf("status %v, %v, want OK", r.Status, r.Error)
+			}
+		}
+	}
+	if !found {
+		t.Error("not reported under its health name")
+	}
+}
+
+func TestAutoRefresh_close(t *testing.T) {
+	lcd := &fakeLCD{}
+	lcd.ram.clear()
+	hd, err := New(lcd, RowAddress20Col, TwoLine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hd.HealthName = "lcd/closed"
+	hd.AutoRefresh(time.Hour)
+	hd.check()
+
+	// Closing from two goroutines stops the refresh once.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := hd.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, r := range health.Default.Reports() {
+		if r.Name == "lcd/closed" {
+			t.Error("closed display still in the health registry")
+		}
+	}
+
+	hd.AutoRefresh(time.Hour)
+	if hd.quit != nil {
+		t.Error("AutoRefresh restarted refreshing a closed display")
+	}
+}
+
+func TestModes_duringRefresh(t *testing.T) {
+	lcd := &fakeLCD{}
+	lcd.ram.clear()
+	hd, err := New(lcd, RowAddress20Col, TwoLine)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The refresh writes the modes back while they change.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if err := hd.Refresh(); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		hd.DisplayOff()
+		hd.CursorOn()
+		hd.BacklightOff()
+		hd.SetMode(EntryDecrement)
+		hd.DisplayOn()
+		hd.BacklightOn()
+		hd.SetMode(EntryIncrement)
+	}
+	<-done
+
+	if ok, err := hd.Verify(); err != nil || !ok {
+		t.Errorf("Verify: got %v, %v, want true", ok, err)
+	}
+}
@@ -0,0 +1,156 @@
+// GPIO shift register connection.
+
+package hd44780
+
+import (
+	"errors"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+)
+
+// shiftRoles name the pins of the shift register in their claims.
+var shiftRoles = []string{"data", "clock", "latch"}
+
+// ShiftRegisterConnection implements Connection using a 74HC595 shift
+// register driven by three GPIO pins: the serial data (DS), the shift clock
+// (SH_CP) and the latch (ST_CP). The outputs of the shift register are
+// mapped to the pins of the controller like the ones of an I²C expander;
+// the RW line is not used. On a SPI bus, use SPIConnection instead.
+type ShiftRegisterConnection struct {
+	Data, Shift, Latch embd.DigitalPin
+	PinMap             I2CPinMap
+	Backlight          bool
+
+	pace   *clock.Pacer
+	closed bool
+}
+
+// NewShiftRegisterConnection returns a new Connection based on a shift
+// register driven by GPIO pins.
+func NewShiftRegisterConnection(data, shift, latch embd.DigitalPin, pinMap I2CPinMap) *ShiftRegisterConnection {
+	return &ShiftRegisterConnection{
+		Data:   data,
+		Shift:  shift,
+		Latch:  latch,
+		PinMap: pinMap,
+	}
+}
+
+// NewShiftRegister creates a new HD44780 connected by a 74HC595 shift
+// register driven by three GPIO pins. The pins are claimed (see embd.Claim)
+// until the display is closed.
+func NewShiftRegister(
+	data, shift, latch interface{},
+	pinMap I2CPinMap,
+	rowAddr RowAddress,
+	modes ...ModeSetter,
+) (*HD44780, error) {
+	pins, err := openPins([]interface{}{data, shift, latch}, shiftRoles)
+	if err != nil {
+		return nil, err
+	}
+	for idx, pin := range pins {
+		if pin == nil {
+			unclaim(pins)
+			return nil, errors.New("hd44780: missing shift register " + shiftRoles[idx] + " pin")
+		}
+	}
+	hd, err := New(NewShiftRegisterConnection(pins[0], pins[1], pins[2], pinMap), rowAddr, modes...)
+	if err != nil {
+		unclaim(pins)
+	}
+	return hd, err
+}
+
+// BacklightOff turns the optional backlight off.
+func (conn *ShiftRegisterConnection) BacklightOff() error {
+	conn.Backlight = false
+	return conn.Write(false, 0x00)
+}
+
+// BacklightOn turns the optional backlight on.
+func (conn *ShiftRegisterConnection) BacklightOn() error {
+	conn.Backlight = true
+	return conn.Write(false, 0x00)
+}
+
+// shiftOut shifts a byte in, most significant bit first, and latches it to
+// the outputs.
+func (conn *ShiftRegisterConnection) shiftOut(v byte) error {
+	for i := 7; i >= 0; i-- {
+		bit := embd.Low
+		if v&(1<<uint(i)) != 0 {
+			bit = embd.High
+		}
+		if err := conn.Data.Write(bit); err != nil {
+			return err
+		}
+		if err := conn.Shift.Write(embd.High); err != nil {
+			return err
+		}
+		if err := conn.Shift.Write(embd.Low); err != nil {
+			return err
+		}
+	}
+	if err := conn.Latch.Write(embd.High); err != nil {
+		return err
+	}
+	return conn.Latch.Write(embd.Low)
+}
+
+// Write writes a register select flag and byte to the shift register.
+func (conn *ShiftRegisterConnection) Write(rs bool, data byte) error {
+	if conn.closed {
+		return embd.ErrClosed
+	}
+	if conn.pace == nil {
+		conn.pace = clock.NewPacer(Clock)
+	}
+	m := conn.PinMap.protocol()
+	for _, ins := range m.Nibbles(rs, conn.Backlight, data) {
+		glog.V(3).Infof("hd44780: writing to shift register: %#x", ins)
+		for _, b := range m.Pulses(ins) {
+			conn.pace.Wait()
+			conn.pace.Delay(pulseDelay)
+			if err := conn.shiftOut(b); err != nil {
+				return err
+			}
+		}
+	}
+	Clock.Sleep(writeDelay)
+	return nil
+}
+
+func (conn *ShiftRegisterConnection) pins() []embd.DigitalPin {
+	return []embd.DigitalPin{conn.Data, conn.Shift, conn.Latch}
+}
+
+// Release releases the claims of the pins without closing them. The
+// connection cannot be used anymore.
+func (conn *ShiftRegisterConnection) Release() error {
+	if !conn.closed {
+		conn.closed = true
+		unclaim(conn.pins())
+	}
+	return nil
+}
+
+// Close closes the pins.
+func (conn *ShiftRegisterConnection) Close() error {
+	if conn.closed {
+		return nil
+	}
+	conn.Release()
+
+	glog.V(2).Info("hd44780: closing shift register pins")
+	var errs []error
+	for _, pin := range conn.pins() {
+		if err := pin.Close(); err != nil {
+			glog.Errorf("hd44780: error closing pin %+v: %s", pin, err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,64 @@
+package hd44780
+
+import (
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+// fake595 emulates a 74HC595, recording the bytes latched to its outputs.
+type fake595 struct {
+	data    int
+	reg     byte
+	latched []byte
+}
+
+type fake595Pin struct {
+	embd.DigitalPin
+
+	sr    *fake595
+	role  int
+	level int
+}
+
+func (p *fake595Pin) Write(v int) error {
+	rising := p.level == embd.Low && v == embd.High
+	p.level = v
+	switch {
+	case p.role == 0:
+		p.sr.data = v
+	case p.role == 1 && rising:
+		p.sr.reg = p.sr.reg<<1 | byte(p.sr.data)
+	case p.role == 2 && rising:
+		p.sr.latched = append(p.sr.latched, p.sr.reg)
+	}
+	return nil
+}
+
+func (p *fake595Pin) Close() error { return nil }
+
+func TestShiftRegisterConnection(t *testing.T) {
+	defer virtualClock()()
+
+	sr := &fake595{}
+	pin := func(role int) *fake595Pin { return &fake595Pin{sr: sr, role: role} }
+	conn := NewShiftRegisterConnection(pin(0), pin(1), pin(2), PCF8574PinMap)
+	conn.Backlight = true
+	if err := conn.Write(true, 'A'); err != nil {
+		t.Fatal(err)
+	}
+
+	m := PCF8574PinMap.protocol()
+	var want []byte
+	for _, ins := range m.Nibbles(true, true, 'A') {
+		want = append(want, m.Pulses(ins)...)
+	}
+	if string(sr.latched) != string(want) {
+		t.Errorf("latched % x, want % x", sr.latched, want)
+	}
+
+	conn.Close()
+	if err := conn.Write(true, 'A'); err != embd.ErrClosed {
+		t.Errorf("Write after Close: got %v, want embd.ErrClosed", err)
+	}
+}
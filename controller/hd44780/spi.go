@@ -0,0 +1,93 @@
+// SPI shift register connection.
+
+package hd44780
+
+import (
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+)
+
+// SPIConnection implements Connection using a 74HC595 shift register on a
+// SPI bus, latching each byte sent when the chip select is released. The
+// outputs of the shift register are mapped to the pins of the controller
+// like the ones of an I²C expander; the RW line is not used.
+type SPIConnection struct {
+	SPI       embd.SPIBus
+	PinMap    I2CPinMap
+	Backlight bool
+
+	pace   *clock.Pacer
+	closed bool
+}
+
+// NewSPIConnection returns a new Connection based on a shift register on a
+// SPI bus.
+func NewSPIConnection(spi embd.SPIBus, pinMap I2CPinMap) *SPIConnection {
+	return &SPIConnection{
+		SPI:    spi,
+		PinMap: pinMap,
+	}
+}
+
+// NewSPI creates a new HD44780 connected by a shift register on a SPI bus.
+func NewSPI(
+	spi embd.SPIBus,
+	pinMap I2CPinMap,
+	rowAddr RowAddress,
+	modes ...ModeSetter,
+) (*HD44780, error) {
+	return New(NewSPIConnection(spi, pinMap), rowAddr, modes...)
+}
+
+// BacklightOff turns the optional backlight off.
+func (conn *SPIConnection) BacklightOff() error {
+	conn.Backlight = false
+	return conn.Write(false, 0x00)
+}
+
+// BacklightOn turns the optional backlight on.
+func (conn *SPIConnection) BacklightOn() error {
+	conn.Backlight = true
+	return conn.Write(false, 0x00)
+}
+
+// Write writes a register select flag and byte to the shift register.
+func (conn *SPIConnection) Write(rs bool, data byte) error {
+	if conn.closed {
+		return embd.ErrClosed
+	}
+	if conn.pace == nil {
+		conn.pace = clock.NewPacer(Clock)
+	}
+	m := conn.PinMap.protocol()
+	for _, ins := range m.Nibbles(rs, conn.Backlight, data) {
+		glog.V(3).Infof("hd44780: writing to SPI: %#x", ins)
+		for _, b := range m.Pulses(ins) {
+			conn.pace.Wait()
+			conn.pace.Delay(pulseDelay)
+			if _, err := conn.SPI.TransferAndReceiveByte(b); err != nil {
+				return err
+			}
+		}
+	}
+	Clock.Sleep(writeDelay)
+	return nil
+}
+
+// Release leaves the SPI bus open. The connection cannot be used anymore.
+func (conn *SPIConnection) Release() error {
+	conn.closed = true
+	return nil
+}
+
+// Close closes the SPI bus.
+func (conn *SPIConnection) Close() error {
+	if conn.closed {
+		return nil
+	}
+	conn.closed = true
+
+	glog.V(2).Info("hd44780: closing SPI bus")
+	return conn.SPI.Close()
+}
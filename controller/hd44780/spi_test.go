@@ -0,0 +1,56 @@
+package hd44780
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+// recordingSPI records the bytes shifted out on the bus.
+type recordingSPI struct {
+	embd.SPIBus
+
+	sent []byte
+}
+
+func (b *recordingSPI) TransferAndReceiveByte(v byte) (byte, error) {
+	b.sent = append(b.sent, v)
+	return 0, nil
+}
+
+func (b *recordingSPI) Close() error { return nil }
+
+func TestSPIConnection(t *testing.T) {
+	defer virtualClock()()
+
+	bus := &recordingSPI{}
+	conn := NewSPIConnection(bus, PCF8574PinMap)
+	if err := conn.BacklightOn(); err != nil {
+		t.Fatal(err)
+	}
+	bus.sent = nil
+	if err := conn.Write(true, 'A'); err != nil {
+		t.Fatal(err)
+	}
+	// RS (bit 0) and the backlight (bit 3) stay set, each nibble on D4 to
+	// D7 (bits 4 to 7) is latched by pulsing EN (bit 2).
+	want := []byte{0x49, 0x4d, 0x49, 0x19, 0x1d, 0x19}
+	if !bytes.Equal(bus.sent, want) {
+		t.Errorf("sent % x, want % x", bus.sent, want)
+	}
+
+	bus.sent = nil
+	if err := conn.BacklightOff(); err != nil {
+		t.Fatal(err)
+	}
+	want = []byte{0x00, 0x04, 0x00, 0x00, 0x04, 0x00}
+	if !bytes.Equal(bus.sent, want) {
+		t.Errorf("backlight off: sent % x, want % x", bus.sent, want)
+	}
+
+	conn.Close()
+	if err := conn.Write(true, 'A'); err != embd.ErrClosed {
+		t.Errorf("Write after Close: got %v, want embd.ErrClosed", err)
+	}
+}
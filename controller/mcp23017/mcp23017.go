@@ -0,0 +1,330 @@
+/*
+Package mcp23017 allows interfacing with the MCP23017 16 bit I²C GPIO
+expander, whose pins are DigitalPins usable by the other drivers.
+
+Several expanders are chained on the bus with different addresses, their
+open drain interrupt outputs wired together to a single host pin, and their
+pins named in the embd pin namespace:
+
+	bus := embd.NewI2CBus(1)
+	chain := mcp23017.NewChain(intPin, mcp23017.New(bus, 0x20), mcp23017.New(bus, 0x21))
+	if err := chain.Register("exp"); err != nil {
+		panic(err)
+	}
+	if err := chain.Run(); err != nil {
+		panic(err)
+	}
+	defer chain.Close()
+
+	relay, _ := embd.NewDigitalPin("exp1.3")
+
+The pins 0 to 7 are the port A of the chip, and 8 to 15 the port B.
+*/
+package mcp23017
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// Pins is the number of pins of an expander.
+const Pins = 16
+
+// The registers of the A port, with IOCON.BANK = 0: the B port register
+// follows each.
+const (
+	regIODIR   = 0x00
+	regGPINTEN = 0x04
+	regINTCON  = 0x08
+	regIOCON   = 0x0a
+	regGPPU    = 0x0c
+	regINTF    = 0x0e
+	regINTCAP  = 0x10
+	regGPIO    = 0x12
+	regOLAT    = 0x14
+
+	// Mirror the interrupts of both ports on each INT output, and make
+	// them open drain so the outputs of several chips can be wired
+	// together.
+	iocon = 0x40 | 0x04
+)
+
+// MCP23017 represents a MCP23017 expander.
+type MCP23017 struct {
+	Bus  embd.I2CBus
+	Addr byte
+
+	mu       sync.Mutex
+	iodir    uint16
+	gppu     uint16
+	gpinten  uint16
+	olat     uint16
+	pins     [Pins]*pin
+	handlers [Pins]func()
+
+	initialized bool
+}
+
+// New creates a new MCP23017 at the address (0x20 to 0x27) on the bus.
+func New(bus embd.I2CBus, addr byte) *MCP23017 {
+	return &MCP23017{Bus: bus, Addr: addr}
+}
+
+func (d *MCP23017) readReg(reg byte) (uint16, error) {
+	buf := make([]byte, 2)
+	if err := d.Bus.ReadFromReg(d.Addr, reg, buf); err != nil {
+		return 0, err
+	}
+	return uint16(buf[0]) | uint16(buf[1])<<8, nil
+}
+
+func (d *MCP23017) writeReg(reg byte, v uint16) error {
+	return d.Bus.WriteToReg(d.Addr, reg, []byte{byte(v), byte(v >> 8)})
+}
+
+func (d *MCP23017) setup() error {
+	if d.initialized {
+		return nil
+	}
+
+	if err := embd.Claim(embd.I2CDevice(d.Bus, d.Addr), "mcp23017"); err != nil {
+		return err
+	}
+
+	// Keep the state the pins may have been left in.
+	if err := d.Bus.WriteByteToReg(d.Addr, regIOCON, iocon); err != nil {
+		return err
+	}
+	var err error
+	if d.iodir, err = d.readReg(regIODIR); err != nil {
+		return err
+	}
+	if d.gppu, err = d.readReg(regGPPU); err != nil {
+		return err
+	}
+	if d.olat, err = d.readReg(regOLAT); err != nil {
+		return err
+	}
+	// Interrupt on any change of the watched pins.
+	if err := d.writeReg(regINTCON, 0); err != nil {
+		return err
+	}
+	if err := d.writeReg(regGPINTEN, 0); err != nil {
+		return err
+	}
+	d.gpinten = 0
+
+	d.initialized = true
+
+	return nil
+}
+
+// DigitalPin returns the pin n of the expander, implementing embd.Expander.
+func (d *MCP23017) DigitalPin(n int) (embd.DigitalPin, error) {
+	if n < 0 || n >= Pins {
+		return nil, fmt.Errorf("mcp23017: pin %v out of range [0, %v)", n, Pins)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.setup(); err != nil {
+		return nil, err
+	}
+	if d.pins[n] == nil {
+		d.pins[n] = &pin{d: d, n: n}
+	}
+	return d.pins[n], nil
+}
+
+// setBit sets or clears the bit of pin n in a register cache, and writes the
+// register if it changed. It must be called with the lock held.
+func (d *MCP23017) setBit(reg byte, cache *uint16, n int, on bool) error {
+	v := *cache &^ (1 << uint(n))
+	if on {
+		v |= 1 << uint(n)
+	}
+	if v == *cache {
+		return nil
+	}
+	if err := d.writeReg(reg, v); err != nil {
+		return err
+	}
+	*cache = v
+	return nil
+}
+
+// Service handles an interrupt of the expander, calling the handlers of the
+// watched pins which changed. It returns whether the expander had an
+// interrupt pending.
+func (d *MCP23017) Service() (bool, error) {
+	d.mu.Lock()
+	if !d.initialized || d.gpinten == 0 {
+		d.mu.Unlock()
+		return false, nil
+	}
+	flags, err := d.readReg(regINTF)
+	if err != nil {
+		d.mu.Unlock()
+		return false, err
+	}
+	if flags == 0 {
+		d.mu.Unlock()
+		return false, nil
+	}
+	// Reading the captured values clears the interrupt.
+	captured, err := d.readReg(regINTCAP)
+	if err != nil {
+		d.mu.Unlock()
+		return true, err
+	}
+	var handlers []func()
+	for n := 0; n < Pins; n++ {
+		p := d.pins[n]
+		if flags&(1<<uint(n)) == 0 || p == nil || d.handlers[n] == nil {
+			continue
+		}
+		if p.matches(int(captured>>uint(n)) & 0x01) {
+			handlers = append(handlers, d.handlers[n])
+		}
+	}
+	d.mu.Unlock()
+
+	for _, h := range handlers {
+		h()
+	}
+	return true, nil
+}
+
+// Close disables the interrupts of the expander, leaving its pins in their
+// state, and releases it.
+func (d *MCP23017) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.initialized {
+		return nil
+	}
+	d.initialized = false
+	embd.Unclaim(embd.I2CDevice(d.Bus, d.Addr))
+
+	d.handlers = [Pins]func(){}
+	d.pins = [Pins]*pin{}
+	d.gpinten = 0
+	return d.writeReg(regGPINTEN, 0)
+}
+
+// Chain is a set of expanders with their interrupt outputs wired to a single
+// host pin.
+type Chain struct {
+	INT   embd.DigitalPin
+	Chips []*MCP23017
+
+	mu       sync.Mutex
+	names    []string
+	watching bool
+}
+
+// NewChain creates a new chain of expanders sharing the interrupt pin, which
+// may be nil when no pin is watched.
+func NewChain(intPin embd.DigitalPin, chips ...*MCP23017) *Chain {
+	return &Chain{INT: intPin, Chips: chips}
+}
+
+// Register registers the expanders in the embd pin namespace, as prefix0,
+// prefix1 and so on.
+func (c *Chain) Register(prefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, chip := range c.Chips {
+		name := fmt.Sprintf("%v%v", prefix, i)
+		if err := embd.RegisterExpander(name, chip); err != nil {
+			return err
+		}
+		c.names = append(c.names, name)
+	}
+	return nil
+}
+
+// Run starts watching the interrupt pin, dispatching the interrupts to the
+// expanders.
+func (c *Chain) Run() error {
+	if c.INT == nil {
+		return nil
+	}
+	if err := c.INT.SetDirection(embd.In); err != nil {
+		return err
+	}
+	// The open drain outputs need a pull up, the internal one if the host
+	// supports it.
+	if err := c.INT.PullUp(); err != nil {
+		glog.V(1).Infof("mcp23017: no pull up on the interrupt pin: %v", err)
+	}
+	if err := c.INT.Watch(embd.EdgeFalling, func(embd.DigitalPin) {
+		c.Service()
+	}); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.watching = true
+	c.mu.Unlock()
+
+	// Clear the interrupts raised before.
+	c.Service()
+	return nil
+}
+
+// maxRounds bounds the servicing of an interrupt line stuck low.
+const maxRounds = 8
+
+// Service services the interrupts of all the expanders. As the line stays
+// low while any chip has an interrupt pending, the chips are serviced until
+// it is released.
+func (c *Chain) Service() {
+	for round := 0; round < maxRounds; round++ {
+		for _, chip := range c.Chips {
+			if _, err := chip.Service(); err != nil {
+				glog.Errorf("mcp23017: servicing interrupt of %#02x: %v", chip.Addr, err)
+			}
+		}
+		if c.INT == nil {
+			return
+		}
+		if v, err := c.INT.Read(); err != nil || v == embd.High {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	glog.Warningf("mcp23017: interrupt line stuck low")
+}
+
+// Close stops watching the interrupt pin, unregisters the expanders and
+// closes them.
+func (c *Chain) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, name := range c.names {
+		embd.UnregisterExpander(name)
+	}
+	c.names = nil
+
+	if c.watching {
+		c.watching = false
+		if err := c.INT.StopWatching(); err != nil {
+			return err
+		}
+	}
+	for _, chip := range c.Chips {
+		if err := chip.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package mcp23017
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+// fakeBus emulates MCP23017s on an I²C bus.
+type fakeBus struct {
+	embd.I2CBus
+
+	regs map[byte]*[0x16]byte
+}
+
+func newFakeBus(addrs ...byte) *fakeBus {
+	b := &fakeBus{regs: map[byte]*[0x16]byte{}}
+	for _, addr := range addrs {
+		regs := &[0x16]byte{}
+		// All the pins are inputs at power on.
+		regs[regIODIR], regs[regIODIR+1] = 0xff, 0xff
+		b.regs[addr] = regs
+	}
+	return b
+}
+
+func (b *fakeBus) ReadFromReg(addr, reg byte, value []byte) error {
+	regs := b.regs[addr]
+	for i := range value {
+		value[i] = regs[int(reg)+i]
+		// Reading the captured values clears the interrupts.
+		if r := int(reg) + i; r == regINTCAP || r == regINTCAP+1 {
+			regs[regINTF+r-regINTCAP] = 0
+		}
+	}
+	return nil
+}
+
+func (b *fakeBus) ReadByteFromReg(addr, reg byte) (byte, error) {
+	v := make([]byte, 1)
+	err := b.ReadFromReg(addr, reg, v)
+	return v[0], err
+}
+
+func (b *fakeBus) WriteToReg(addr, reg byte, value []byte) error {
+	regs := b.regs[addr]
+	for i, v := range value {
+		regs[int(reg)+i] = v
+		// The output latches drive the output pins.
+		if r := int(reg) + i; r == regOLAT || r == regOLAT+1 {
+			port := r - regOLAT
+			regs[regGPIO+port] = regs[regGPIO+port]&regs[regIODIR+port] | v&^regs[regIODIR+port]
+		}
+	}
+	return nil
+}
+
+func (b *fakeBus) WriteByteToReg(addr, reg, value byte) error {
+	return b.WriteToReg(addr, reg, []byte{value})
+}
+
+// change sets an input of an expander, raising an interrupt if it is
+// watched.
+func (b *fakeBus) change(addr byte, n int, v int) {
+	regs := b.regs[addr]
+	port, bit := n/8, byte(1)<<uint(n%8)
+	old := regs[regGPIO+port]
+	regs[regGPIO+port] &^= bit
+	if v == embd.High {
+		regs[regGPIO+port] |= bit
+	}
+	if regs[regGPIO+port] == old {
+		return
+	}
+	if regs[regGPINTEN+port]&bit != 0 {
+		regs[regINTF+port] |= bit
+		regs[regINTCAP], regs[regINTCAP+1] = regs[regGPIO], regs[regGPIO+1]
+	}
+}
+
+func (b *fakeBus) pending() bool {
+	for _, regs := range b.regs {
+		if regs[regINTF] != 0 || regs[regINTF+1] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeINT is the shared interrupt line, low while an expander has an
+// interrupt pending.
+type fakeINT struct {
+	embd.DigitalPin
+
+	bus     *fakeBus
+	handler func(embd.DigitalPin)
+}
+
+func (p *fakeINT) SetDirection(embd.Direction) error { return nil }
+func (p *fakeINT) PullUp() error                     { return nil }
+
+func (p *fakeINT) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
+	p.handler = handler
+	return nil
+}
+
+func (p *fakeINT) StopWatching() error {
+	p.handler = nil
+	return nil
+}
+
+func (p *fakeINT) Read() (int, error) {
+	if p.bus.pending() {
+		return embd.Low, nil
+	}
+	return embd.High, nil
+}
+
+func TestPins(t *testing.T) {
+	bus := newFakeBus(0x20)
+	d := New(bus, 0x20)
+	defer d.Close()
+
+	led, err := d.DigitalPin(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := led.SetDirection(embd.Out); err != nil {
+		t.Fatal(err)
+	}
+	if err := led.Write(embd.High); err != nil {
+		t.Fatal(err)
+	}
+	regs := bus.regs[0x20]
+	if regs[regIODIR+1] != 0xfb || regs[regOLAT+1] != 0x04 {
+		t.Errorf("IODIRB, OLATB: got %#02x, %#02x, want 0xfb, 0x04", regs[regIODIR+1], regs[regOLAT+1])
+	}
+	if v, err := led.Read(); err != nil || v != embd.High {
+		t.Errorf("Read: got %v, %v, want high", v, err)
+	}
+
+	button, err := d.DigitalPin(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := button.PullUp(); err != nil {
+		t.Fatal(err)
+	}
+	if err := button.ActiveLow(true); err != nil {
+		t.Fatal(err)
+	}
+	if regs[regGPPU] != 0x04 {
+		t.Errorf("GPPUA: got %#02x, want 0x04", regs[regGPPU])
+	}
+	bus.change(0x20, 2, embd.Low)
+	if v, err := button.Read(); err != nil || v != embd.High {
+		t.Errorf("Read of active low pin: got %v, %v, want high", v, err)
+	}
+	if err := button.PullDown(); err != embd.ErrFeatureNotSupported {
+		t.Errorf("PullDown: got %v, want ErrFeatureNotSupported", err)
+	}
+
+	if _, err := d.DigitalPin(16); err == nil {
+		t.Error("no error for a pin out of range")
+	}
+}
+
+func TestChain(t *testing.T) {
+	bus := newFakeBus(0x20, 0x21)
+	intPin := &fakeINT{bus: bus}
+	chain := NewChain(intPin, New(bus, 0x20), New(bus, 0x21))
+	if err := chain.Register("exp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var pressed []string
+	for _, key := range []string{"exp0.5", "exp1.3", "exp1.9"} {
+		pin, err := embd.NewDigitalPin(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := key
+		if err := pin.Watch(embd.EdgeFalling, func(embd.DigitalPin) {
+			pressed = append(pressed, key)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bus.regs[0x21][regGPINTEN] != 0x08 || bus.regs[0x21][regGPINTEN+1] != 0x02 {
+		t.Errorf("GPINTEN: got % x, want 08 02", bus.regs[0x21][regGPINTEN:regGPINTEN+2])
+	}
+
+	bus.change(0x21, 9, embd.High)
+	intPin.handler(intPin)
+	bus.change(0x21, 9, embd.Low)
+	intPin.handler(intPin)
+	bus.change(0x20, 5, embd.High)
+	bus.change(0x21, 3, embd.High)
+	intPin.handler(intPin)
+	// Both chips pulling the shared line low.
+	bus.change(0x20, 5, embd.Low)
+	bus.change(0x21, 3, embd.Low)
+	intPin.handler(intPin)
+
+	want := []string{"exp1.9", "exp0.5", "exp1.3"}
+	if !reflect.DeepEqual(pressed, want) {
+		t.Errorf("falling edges: got %v, want %v", pressed, want)
+	}
+	if bus.pending() {
+		t.Error("interrupts still pending")
+	}
+
+	if err := chain.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(embd.Expanders()) != 0 {
+		t.Errorf("expanders still registered: %v", embd.Expanders())
+	}
+	if intPin.handler != nil {
+		t.Error("interrupt pin still watched")
+	}
+}
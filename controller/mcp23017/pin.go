@@ -0,0 +1,202 @@
+// Expander pins.
+
+package mcp23017
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kidoman/embd"
+)
+
+var errClosed = embd.NewError(embd.ErrClosed, "mcp23017: expander closed")
+
+type pin struct {
+	d *MCP23017
+	n int
+
+	activeLow bool
+	edge      embd.Edge
+}
+
+// check, matches and read must be called with the lock of the expander held.
+
+func (p *pin) check() error {
+	if !p.d.initialized || p.d.pins[p.n] != p {
+		return errClosed
+	}
+	return nil
+}
+
+func (p *pin) logical(v int) int {
+	if p.activeLow {
+		return v ^ 0x01
+	}
+	return v
+}
+
+// matches returns whether the physical value v captured at an interrupt
+// matches the watched edge.
+func (p *pin) matches(v int) bool {
+	switch p.edge {
+	case embd.EdgeRising:
+		return p.logical(v) == embd.High
+	case embd.EdgeFalling:
+		return p.logical(v) == embd.Low
+	case embd.EdgeBoth:
+		return true
+	}
+	return false
+}
+
+func (p *pin) N() int {
+	return p.n
+}
+
+func (p *pin) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+
+	if err := p.check(); err != nil {
+		return err
+	}
+	if p.d.handlers[p.n] != nil {
+		return errors.New("mcp23017: pin already watched")
+	}
+	if err := p.d.setBit(regGPINTEN, &p.d.gpinten, p.n, edge != embd.EdgeNone); err != nil {
+		return err
+	}
+	p.edge = edge
+	p.d.handlers[p.n] = func() { handler(p) }
+	return nil
+}
+
+func (p *pin) StopWatching() error {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+
+	if err := p.check(); err != nil {
+		return err
+	}
+	if p.d.handlers[p.n] == nil {
+		return nil
+	}
+	if err := p.d.setBit(regGPINTEN, &p.d.gpinten, p.n, false); err != nil {
+		return err
+	}
+	p.edge = embd.EdgeNone
+	p.d.handlers[p.n] = nil
+	return nil
+}
+
+func (p *pin) Write(val int) error {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+
+	if err := p.check(); err != nil {
+		return err
+	}
+	return p.d.setBit(regOLAT, &p.d.olat, p.n, p.logical(val) == embd.High)
+}
+
+func (p *pin) Read() (int, error) {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+
+	if err := p.check(); err != nil {
+		return 0, err
+	}
+	return p.read()
+}
+
+func (p *pin) read() (int, error) {
+	v, err := p.d.Bus.ReadByteFromReg(p.d.Addr, regGPIO+byte(p.n/8))
+	if err != nil {
+		return 0, err
+	}
+	return p.logical(int(v>>uint(p.n%8)) & 0x01), nil
+}
+
+func (p *pin) TimePulse(state int) (time.Duration, error) {
+	aroundState := embd.Low
+	if state == embd.Low {
+		aroundState = embd.High
+	}
+
+	// Each read is an I²C transfer, which bounds the resolution.
+	wait := func(want int) error {
+		for {
+			v, err := p.Read()
+			if err != nil {
+				return err
+			}
+			if v == want {
+				return nil
+			}
+		}
+	}
+
+	if err := wait(aroundState); err != nil {
+		return 0, err
+	}
+	if err := wait(state); err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	if err := wait(aroundState); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+func (p *pin) SetDirection(dir embd.Direction) error {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+
+	if err := p.check(); err != nil {
+		return err
+	}
+	return p.d.setBit(regIODIR, &p.d.iodir, p.n, dir == embd.In)
+}
+
+func (p *pin) ActiveLow(b bool) error {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+
+	if err := p.check(); err != nil {
+		return err
+	}
+	p.activeLow = b
+	return nil
+}
+
+func (p *pin) PullUp() error {
+	p.d.mu.Lock()
+	defer p.d.mu.Unlock()
+
+	if err := p.check(); err != nil {
+		return err
+	}
+	return p.d.setBit(regGPPU, &p.d.gppu, p.n, true)
+}
+
+// PullDown is not supported, the MCP23017 only has pull ups.
+func (p *pin) PullDown() error {
+	return embd.ErrFeatureNotSupported
+}
+
+// SetDebounce is not supported, the interrupts of the expander are not
+// filtered.
+func (p *pin) SetDebounce(d time.Duration) error {
+	if d == 0 {
+		return nil
+	}
+	return embd.ErrFeatureNotSupported
+}
+
+func (p *pin) Close() error {
+	if err := p.StopWatching(); err != nil && err != errClosed {
+		return err
+	}
+	return nil
+}
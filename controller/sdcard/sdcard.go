@@ -0,0 +1,588 @@
+/*
+Package sdcard allows using SD and MMC cards in SPI mode, as block devices
+for data loggers on boards whose SD slot holds the system.
+
+The card keeps its chip select asserted across the transfers of a command,
+which spidev does not do, so the chip select is a GPIO pin and the bus is
+opened on a chip enable line left unconnected:
+
+	bus := embd.NewSPIBus(embd.SPIMode0, 1, 4000000, 8, 0)
+	cs, _ := embd.NewDigitalPin(25)
+	card := sdcard.New(bus, cs)
+	if err := card.Init(); err != nil {
+		panic(err)
+	}
+	card.WriteAt(record, offset)
+
+The card implements io.ReaderAt and io.WriterAt, which may be used to write
+a filesystem image or a simple log of fixed size records.
+*/
+package sdcard
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// BlockSize is the size of the blocks read and written.
+const BlockSize = 512
+
+const (
+	cmdGoIdle         = 0
+	cmdSendOpCond     = 1
+	cmdSendIfCond     = 8
+	cmdSendCSD        = 9
+	cmdStop           = 12
+	cmdSetBlockLen    = 16
+	cmdReadBlock      = 17
+	cmdReadMultiple   = 18
+	cmdWriteBlock     = 24
+	cmdWriteMultiple  = 25
+	cmdAppCmd         = 55
+	cmdReadOCR        = 58
+	cmdCRCOnOff       = 59
+	acmdSendOpCond    = 41
+	r1Idle            = 0x01
+	r1IllegalCommand  = 0x04
+	tokenStart        = 0xfe
+	tokenStartMulti   = 0xfc
+	tokenStopMulti    = 0xfd
+	dataAccepted      = 0x05
+	ocrCCS            = 1 << 30
+	responseAttempts  = 10
+	initTimeout       = time.Second
+	transferTimeout   = 500 * time.Millisecond
+	initialClockBytes = 10
+)
+
+// ErrTimeout is returned when the card does not answer in time.
+var ErrTimeout = embd.NewError(embd.ErrTimeout, "sdcard: timeout waiting for the card")
+
+// CommandError is returned when the card reports an error for a command.
+type CommandError struct {
+	Cmd byte
+	R1  byte
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("sdcard: command %v failed with status %#02x", e.Cmd, e.R1)
+}
+
+// SDCard represents an SD or MMC card on a SPI bus.
+type SDCard struct {
+	Bus embd.SPIBus
+	CS  embd.DigitalPin
+
+	mu sync.Mutex
+
+	// blockAddressing is set for the high capacity cards, which are
+	// addressed by block rather than by byte.
+	blockAddressing bool
+	blocks          int64
+
+	initialized bool
+}
+
+// New creates a new SDCard on the bus, with the chip select on the pin.
+func New(bus embd.SPIBus, cs embd.DigitalPin) *SDCard {
+	return &SDCard{Bus: bus, CS: cs}
+}
+
+func (c *SDCard) selectCard(on bool) error {
+	if on {
+		return c.CS.Write(embd.Low)
+	}
+	if err := c.CS.Write(embd.High); err != nil {
+		return err
+	}
+	// The card releases its data out line on the next clock.
+	_, err := c.Bus.TransferAndReceiveByte(0xff)
+	return err
+}
+
+// crc7 computes the CRC of a command.
+func crc7(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if (b^crc)&0x80 != 0 {
+				crc ^= 0x09
+			}
+			b <<= 1
+		}
+	}
+	return crc & 0x7f
+}
+
+// crc16 computes the CRC-16/XMODEM of a data block.
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// command sends a command and returns its R1 response, leaving the card
+// selected.
+func (c *SDCard) command(cmd byte, arg uint32) (byte, error) {
+	buf := []byte{0x40 | cmd, byte(arg >> 24), byte(arg >> 16), byte(arg >> 8), byte(arg), 0}
+	buf[5] = crc7(buf[:5])<<1 | 1
+	if err := c.Bus.TransferAndRecieveData(buf); err != nil {
+		return 0, err
+	}
+	if cmd == cmdStop {
+		// Skip the stuff byte following the stop command.
+		if _, err := c.Bus.ReceiveByte(); err != nil {
+			return 0, err
+		}
+	}
+	for i := 0; i < responseAttempts; i++ {
+		r1, err := c.Bus.TransferAndReceiveByte(0xff)
+		if err != nil {
+			return 0, err
+		}
+		if r1&0x80 == 0 {
+			return r1, nil
+		}
+	}
+	return 0, ErrTimeout
+}
+
+// appCommand sends an application specific command.
+func (c *SDCard) appCommand(cmd byte, arg uint32) (byte, error) {
+	if r1, err := c.command(cmdAppCmd, 0); err != nil || r1&^r1Idle != 0 {
+		return r1, err
+	}
+	return c.command(cmd, arg)
+}
+
+// readBytes reads n bytes following a command response.
+func (c *SDCard) readBytes(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = 0xff
+	}
+	err := c.Bus.TransferAndRecieveData(buf)
+	return buf, err
+}
+
+// waitToken waits for the card to send a token other than 0xff.
+func (c *SDCard) waitToken() (byte, error) {
+	deadline := time.Now().Add(transferTimeout)
+	for {
+		b, err := c.Bus.TransferAndReceiveByte(0xff)
+		if err != nil {
+			return 0, err
+		}
+		if b != 0xff {
+			return b, nil
+		}
+		if time.Now().After(deadline) {
+			return 0, ErrTimeout
+		}
+	}
+}
+
+// waitReady waits for the card to finish programming.
+func (c *SDCard) waitReady() error {
+	deadline := time.Now().Add(transferTimeout)
+	for {
+		b, err := c.Bus.TransferAndReceiveByte(0xff)
+		if err != nil {
+			return err
+		}
+		if b == 0xff {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrTimeout
+		}
+	}
+}
+
+// Init initializes the card. It is called by the first read or write.
+func (c *SDCard) Init() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.setup()
+}
+
+func (c *SDCard) setup() error {
+	if c.initialized {
+		return nil
+	}
+
+	if err := c.CS.SetDirection(embd.Out); err != nil {
+		return err
+	}
+	// The card enters SPI mode after 74 clocks with chip select high, then
+	// the reset command with chip select low.
+	if err := c.selectCard(false); err != nil {
+		return err
+	}
+	if _, err := c.readBytes(initialClockBytes); err != nil {
+		return err
+	}
+	if err := c.selectCard(true); err != nil {
+		return err
+	}
+	defer c.selectCard(false)
+
+	r1, err := c.command(cmdGoIdle, 0)
+	if err != nil {
+		return err
+	}
+	if r1 != r1Idle {
+		return fmt.Errorf("sdcard: no card found, reset returned %#02x", r1)
+	}
+
+	// Version 2 cards echo the check pattern of the interface condition.
+	version2 := false
+	if r1, err = c.command(cmdSendIfCond, 0x1aa); err != nil {
+		return err
+	}
+	if r1&r1IllegalCommand == 0 {
+		r7, err := c.readBytes(4)
+		if err != nil {
+			return err
+		}
+		if r7[3] != 0xaa {
+			return fmt.Errorf("sdcard: unsupported card, interface condition % x", r7)
+		}
+		version2 = true
+	}
+
+	// Wait for the card to leave the idle state, falling back to the MMC
+	// initialization for the cards not knowing the SD one.
+	var arg uint32
+	if version2 {
+		arg = ocrCCS
+	}
+	mmc := false
+	deadline := time.Now().Add(initTimeout)
+	for {
+		if mmc {
+			r1, err = c.command(cmdSendOpCond, 0)
+		} else {
+			r1, err = c.appCommand(acmdSendOpCond, arg)
+		}
+		if err != nil {
+			return err
+		}
+		if r1&r1IllegalCommand != 0 && !mmc && !version2 {
+			mmc = true
+			continue
+		}
+		if r1 == 0 {
+			break
+		}
+		if r1 != r1Idle {
+			return &CommandError{acmdSendOpCond, r1}
+		}
+		if time.Now().After(deadline) {
+			return ErrTimeout
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c.blockAddressing = false
+	if version2 {
+		if r1, err = c.command(cmdReadOCR, 0); err != nil {
+			return err
+		}
+		if r1 != 0 {
+			return &CommandError{cmdReadOCR, r1}
+		}
+		ocr, err := c.readBytes(4)
+		if err != nil {
+			return err
+		}
+		c.blockAddressing = uint32(ocr[0])<<24&ocrCCS != 0
+	}
+	if !c.blockAddressing {
+		if r1, err = c.command(cmdSetBlockLen, BlockSize); err != nil || r1 != 0 {
+			return c.commandError(cmdSetBlockLen, r1, err)
+		}
+	}
+	if r1, err = c.command(cmdCRCOnOff, 1); err != nil || r1 != 0 {
+		return c.commandError(cmdCRCOnOff, r1, err)
+	}
+
+	if c.blocks, err = c.readCapacity(); err != nil {
+		return err
+	}
+	glog.V(1).Infof("sdcard: found card of %v blocks, block addressing %v", c.blocks, c.blockAddressing)
+
+	c.initialized = true
+
+	return nil
+}
+
+func (c *SDCard) commandError(cmd, r1 byte, err error) error {
+	if err != nil {
+		return err
+	}
+	return &CommandError{cmd, r1}
+}
+
+// readCapacity reads the number of blocks of the card from its CSD.
+func (c *SDCard) readCapacity() (int64, error) {
+	csd := make([]byte, 16)
+	if err := c.readData(cmdSendCSD, 0, csd); err != nil {
+		return 0, err
+	}
+	switch csd[0] >> 6 {
+	case 1:
+		// Version 2: C_SIZE in 512 KiB units.
+		size := int64(csd[7]&0x3f)<<16 | int64(csd[8])<<8 | int64(csd[9])
+		return (size + 1) * 1024, nil
+	default:
+		// Version 1: (C_SIZE+1) * 2^(C_SIZE_MULT+2) blocks of 2^READ_BL_LEN.
+		readBlLen := uint(csd[5] & 0x0f)
+		size := int64(csd[6]&0x03)<<10 | int64(csd[7])<<2 | int64(csd[8]>>6)
+		mult := uint(csd[9]&0x03)<<1 | uint(csd[10]>>7)
+		return (size + 1) << (mult + 2) << readBlLen / BlockSize, nil
+	}
+}
+
+// Blocks returns the number of blocks of the card.
+func (c *SDCard) Blocks() (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.setup(); err != nil {
+		return 0, err
+	}
+	return c.blocks, nil
+}
+
+// Size returns the size of the card in bytes.
+func (c *SDCard) Size() (int64, error) {
+	blocks, err := c.Blocks()
+	return blocks * BlockSize, err
+}
+
+func (c *SDCard) address(block int64) uint32 {
+	if c.blockAddressing {
+		return uint32(block)
+	}
+	return uint32(block * BlockSize)
+}
+
+// receiveBlock receives a data block following its start token.
+func (c *SDCard) receiveBlock(p []byte) error {
+	token, err := c.waitToken()
+	if err != nil {
+		return err
+	}
+	if token != tokenStart {
+		return fmt.Errorf("sdcard: read error token %#02x", token)
+	}
+	data, err := c.readBytes(len(p) + 2)
+	if err != nil {
+		return err
+	}
+	if crc := uint16(data[len(p)])<<8 | uint16(data[len(p)+1]); crc != crc16(data[:len(p)]) {
+		return fmt.Errorf("sdcard: read CRC error")
+	}
+	copy(p, data)
+	return nil
+}
+
+// readData sends a command reading a single data block.
+func (c *SDCard) readData(cmd byte, arg uint32, p []byte) error {
+	if err := c.selectCard(true); err != nil {
+		return err
+	}
+	defer c.selectCard(false)
+
+	r1, err := c.command(cmd, arg)
+	if err != nil || r1 != 0 {
+		return c.commandError(cmd, r1, err)
+	}
+	return c.receiveBlock(p)
+}
+
+// ReadBlocks reads consecutive blocks from block into p, whose length is a
+// multiple of the block size.
+func (c *SDCard) ReadBlocks(block int64, p []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.setup(); err != nil {
+		return err
+	}
+	return c.readBlocks(block, p)
+}
+
+func (c *SDCard) checkBlocks(block int64, n int) error {
+	if n%BlockSize != 0 {
+		return fmt.Errorf("sdcard: %v bytes is not a multiple of the block size", n)
+	}
+	if block < 0 || block+int64(n/BlockSize) > c.blocks {
+		return fmt.Errorf("sdcard: blocks [%v, %v) are outside of the card", block, block+int64(n/BlockSize))
+	}
+	return nil
+}
+
+func (c *SDCard) readBlocks(block int64, p []byte) error {
+	if err := c.checkBlocks(block, len(p)); err != nil {
+		return err
+	}
+	if len(p) == BlockSize {
+		return c.readData(cmdReadBlock, c.address(block), p)
+	}
+
+	if err := c.selectCard(true); err != nil {
+		return err
+	}
+	defer c.selectCard(false)
+
+	r1, err := c.command(cmdReadMultiple, c.address(block))
+	if err != nil || r1 != 0 {
+		return c.commandError(cmdReadMultiple, r1, err)
+	}
+	for off := 0; off < len(p); off += BlockSize {
+		if err := c.receiveBlock(p[off : off+BlockSize]); err != nil {
+			c.command(cmdStop, 0)
+			return err
+		}
+	}
+	if r1, err = c.command(cmdStop, 0); err != nil || r1 != 0 {
+		return c.commandError(cmdStop, r1, err)
+	}
+	return c.waitReady()
+}
+
+// sendBlock sends a data block with its token, and waits for it to be
+// written.
+func (c *SDCard) sendBlock(token byte, p []byte) error {
+	crc := crc16(p)
+	buf := make([]byte, 0, len(p)+4)
+	buf = append(buf, 0xff, token)
+	buf = append(buf, p...)
+	buf = append(buf, byte(crc>>8), byte(crc))
+	if err := c.Bus.TransferAndRecieveData(buf); err != nil {
+		return err
+	}
+	resp, err := c.waitToken()
+	if err != nil {
+		return err
+	}
+	if resp&0x1f != dataAccepted {
+		return fmt.Errorf("sdcard: write rejected with response %#02x", resp)
+	}
+	return c.waitReady()
+}
+
+// WriteBlocks writes p to consecutive blocks from block. The length of p is a
+// multiple of the block size.
+func (c *SDCard) WriteBlocks(block int64, p []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.setup(); err != nil {
+		return err
+	}
+	return c.writeBlocks(block, p)
+}
+
+func (c *SDCard) writeBlocks(block int64, p []byte) error {
+	if err := c.checkBlocks(block, len(p)); err != nil {
+		return err
+	}
+
+	if err := c.selectCard(true); err != nil {
+		return err
+	}
+	defer c.selectCard(false)
+
+	if len(p) == BlockSize {
+		r1, err := c.command(cmdWriteBlock, c.address(block))
+		if err != nil || r1 != 0 {
+			return c.commandError(cmdWriteBlock, r1, err)
+		}
+		return c.sendBlock(tokenStart, p)
+	}
+
+	r1, err := c.command(cmdWriteMultiple, c.address(block))
+	if err != nil || r1 != 0 {
+		return c.commandError(cmdWriteMultiple, r1, err)
+	}
+	for off := 0; off < len(p); off += BlockSize {
+		if err := c.sendBlock(tokenStartMulti, p[off:off+BlockSize]); err != nil {
+			return err
+		}
+	}
+	if err := c.Bus.TransferAndRecieveData([]byte{tokenStopMulti, 0xff}); err != nil {
+		return err
+	}
+	return c.waitReady()
+}
+
+// ReadAt reads len(p) bytes from offset off, implementing io.ReaderAt.
+func (c *SDCard) ReadAt(p []byte, off int64) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.setup(); err != nil {
+		return 0, err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	first, last := off/BlockSize, (off+int64(len(p))+BlockSize-1)/BlockSize
+	buf := make([]byte, (last-first)*BlockSize)
+	if err := c.readBlocks(first, buf); err != nil {
+		return 0, err
+	}
+	return copy(p, buf[off-first*BlockSize:]), nil
+}
+
+// WriteAt writes p at offset off, implementing io.WriterAt. The partially
+// written blocks at the ends are read first.
+func (c *SDCard) WriteAt(p []byte, off int64) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.setup(); err != nil {
+		return 0, err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	first, last := off/BlockSize, (off+int64(len(p))+BlockSize-1)/BlockSize
+	buf := make([]byte, (last-first)*BlockSize)
+	start := off - first*BlockSize
+	if start != 0 {
+		if err := c.readBlocks(first, buf[:BlockSize]); err != nil {
+			return 0, err
+		}
+	}
+	if end := start + int64(len(p)); end%BlockSize != 0 && (last-first > 1 || start == 0) {
+		if err := c.readBlocks(last-1, buf[len(buf)-BlockSize:]); err != nil {
+			return 0, err
+		}
+	}
+	copy(buf[start:], p)
+	if err := c.writeBlocks(first, buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,239 @@
+package sdcard
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+const (
+	stateCommand = iota
+	stateToken
+	stateData
+)
+
+// fakeCard emulates a high capacity SD card of 1024 blocks in SPI mode.
+type fakeCard struct {
+	embd.SPIBus
+
+	mem []byte
+	out []byte
+	cmd []byte
+
+	app, ready bool
+	opConds    int
+
+	state     int
+	multi     bool
+	block     int64
+	data      []byte
+	readMulti bool
+	readBlock int64
+}
+
+func newFakeCard() *fakeCard {
+	return &fakeCard{mem: make([]byte, 1024*BlockSize)}
+}
+
+func (c *fakeCard) exchange(in byte) byte {
+	if len(c.out) == 0 && c.readMulti {
+		c.queueBlock(c.mem[c.readBlock*BlockSize : (c.readBlock+1)*BlockSize])
+		c.readBlock++
+	}
+	out := byte(0xff)
+	if len(c.out) > 0 {
+		out, c.out = c.out[0], c.out[1:]
+	}
+	c.receive(in)
+	return out
+}
+
+func (c *fakeCard) TransferAndRecieveData(buf []byte) error {
+	for i := range buf {
+		buf[i] = c.exchange(buf[i])
+	}
+	return nil
+}
+
+func (c *fakeCard) TransferAndReceiveByte(b byte) (byte, error) {
+	return c.exchange(b), nil
+}
+
+func (c *fakeCard) ReceiveByte() (byte, error) {
+	return c.exchange(0xff), nil
+}
+
+func (c *fakeCard) queueBlock(data []byte) {
+	crc := crc16(data)
+	c.out = append(c.out, 0xff, tokenStart)
+	c.out = append(c.out, data...)
+	c.out = append(c.out, byte(crc>>8), byte(crc))
+}
+
+func (c *fakeCard) receive(in byte) {
+	switch c.state {
+	case stateToken:
+		switch in {
+		case tokenStart, tokenStartMulti:
+			c.state, c.data = stateData, nil
+		case tokenStopMulti:
+			c.out = append(c.out, 0xff, 0x00, 0x00)
+			c.state = stateCommand
+		}
+		return
+	case stateData:
+		c.data = append(c.data, in)
+		if len(c.data) < BlockSize+2 {
+			return
+		}
+		resp := byte(0xe0 | dataAccepted)
+		if crc16(c.data[:BlockSize]) != uint16(c.data[BlockSize])<<8|uint16(c.data[BlockSize+1]) {
+			resp = 0xeb
+		} else {
+			copy(c.mem[c.block*BlockSize:], c.data[:BlockSize])
+			c.block++
+		}
+		c.out = append(c.out, resp, 0x00, 0x00)
+		c.state = stateCommand
+		if c.multi {
+			c.state = stateToken
+		}
+		return
+	}
+
+	if len(c.cmd) == 0 && in&0xc0 != 0x40 {
+		return
+	}
+	c.cmd = append(c.cmd, in)
+	if len(c.cmd) < 6 {
+		return
+	}
+	cmd, arg := c.cmd[0]&0x3f, uint32(c.cmd[1])<<24|uint32(c.cmd[2])<<16|uint32(c.cmd[3])<<8|uint32(c.cmd[4])
+	valid := crc7(c.cmd[:5])<<1|1 == c.cmd[5]
+	c.cmd = nil
+	if !valid {
+		c.out = append(c.out, 0xff, 0x08)
+		return
+	}
+
+	idle := byte(r1Idle)
+	if c.ready {
+		idle = 0
+	}
+	app := c.app
+	c.app = false
+	switch {
+	case cmd == cmdGoIdle:
+		c.out = append(c.out, 0xff, r1Idle)
+	case cmd == cmdSendIfCond:
+		c.out = append(c.out, 0xff, idle, 0, 0, 0x01, byte(arg))
+	case cmd == cmdAppCmd:
+		c.app = true
+		c.out = append(c.out, 0xff, idle)
+	case app && cmd == acmdSendOpCond:
+		c.opConds++
+		c.ready = c.opConds > 2
+		if c.ready {
+			idle = 0
+		}
+		c.out = append(c.out, 0xff, idle)
+	case cmd == cmdReadOCR:
+		c.out = append(c.out, 0xff, idle, 0xc0, 0xff, 0x80, 0x00)
+	case cmd == cmdCRCOnOff:
+		c.out = append(c.out, 0xff, idle)
+	case cmd == cmdSendCSD:
+		csd := make([]byte, 16)
+		csd[0] = 0x40
+		c.out = append(c.out, 0xff, idle)
+		c.queueBlock(csd)
+	case cmd == cmdReadBlock:
+		c.out = append(c.out, 0xff, idle)
+		c.queueBlock(c.mem[int64(arg)*BlockSize : int64(arg+1)*BlockSize])
+	case cmd == cmdReadMultiple:
+		c.out = append(c.out, 0xff, idle)
+		c.readMulti, c.readBlock = true, int64(arg)
+	case cmd == cmdStop:
+		c.readMulti = false
+		c.out = []byte{0xff, 0x00}
+	case cmd == cmdWriteBlock, cmd == cmdWriteMultiple:
+		c.out = append(c.out, 0xff, idle)
+		c.state, c.multi, c.block = stateToken, cmd == cmdWriteMultiple, int64(arg)
+	default:
+		c.out = append(c.out, 0xff, idle|r1IllegalCommand)
+	}
+}
+
+type fakePin struct {
+	embd.DigitalPin
+}
+
+func (fakePin) SetDirection(embd.Direction) error { return nil }
+func (fakePin) Write(int) error                   { return nil }
+
+func TestCRC(t *testing.T) {
+	// The fixed CRCs of the reset and interface condition commands.
+	if crc := crc7([]byte{0x40, 0, 0, 0, 0})<<1 | 1; crc != 0x95 {
+		t.Errorf("CMD0 CRC: got %#02x, want 0x95", crc)
+	}
+	if crc := crc7([]byte{0x48, 0, 0, 0x01, 0xaa})<<1 | 1; crc != 0x87 {
+		t.Errorf("CMD8 CRC: got %#02x, want 0x87", crc)
+	}
+	if crc := crc16(bytes.Repeat([]byte{0xff}, BlockSize)); crc != 0x7fa1 {
+		t.Errorf("CRC16 of an erased block: got %#04x, want 0x7fa1", crc)
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	card := newFakeCard()
+	d := New(card, fakePin{})
+
+	blocks, err := d.Blocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocks != 1024 {
+		t.Errorf("blocks: got %v, want 1024", blocks)
+	}
+	if !d.blockAddressing {
+		t.Error("high capacity card not using block addressing")
+	}
+
+	data := make([]byte, 3*BlockSize)
+	rand.New(rand.NewSource(1)).Read(data)
+	if err := d.WriteBlocks(4, data); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(card.mem[4*BlockSize:7*BlockSize], data) {
+		t.Error("multiple block write: card content does not match")
+	}
+	got := make([]byte, 3*BlockSize)
+	if err := d.ReadBlocks(4, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("multiple block read: data does not match")
+	}
+
+	// An unaligned write keeps the rest of its blocks.
+	record := bytes.Repeat([]byte{0x42}, 600)
+	if n, err := d.WriteAt(record, 4*BlockSize+100); err != nil || n != len(record) {
+		t.Fatalf("WriteAt: got %v, %v", n, err)
+	}
+	copy(data[100:], record)
+	if !bytes.Equal(card.mem[4*BlockSize:7*BlockSize], data) {
+		t.Error("unaligned write: card content does not match")
+	}
+	got = make([]byte, 10)
+	if _, err := d.ReadAt(got, 4*BlockSize+95); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data[95:105]) {
+		t.Errorf("unaligned read: got % x, want % x", got, data[95:105])
+	}
+
+	if err := d.ReadBlocks(1023, make([]byte, 2*BlockSize)); err == nil {
+		t.Error("no error reading past the end of the card")
+	}
+}
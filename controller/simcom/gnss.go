@@ -0,0 +1,69 @@
+// GNSS, on the SIM7000.
+
+package simcom
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/kidoman/embd/units"
+)
+
+// ErrNoFix is returned when the GNSS receiver has no position fix.
+var ErrNoFix = errors.New("simcom: no gnss fix")
+
+// Fix is a GNSS position fix.
+type Fix struct {
+	Time      time.Time
+	Latitude  float64
+	Longitude float64
+	Altitude  units.Distance
+
+	// Speed is over ground, in km/h.
+	Speed float64
+
+	// Course is over ground, in degrees.
+	Course float64
+}
+
+// EnableGNSS powers the GNSS receiver of the SIM7000 on or off.
+func (d *Modem) EnableGNSS(on bool) error {
+	cmd := "+CGNSPWR=0"
+	if on {
+		cmd = "+CGNSPWR=1"
+	}
+	_, err := d.Command(cmd)
+	return err
+}
+
+// GNSS returns the current position fix.
+func (d *Modem) GNSS() (*Fix, error) {
+	// +CGNSINF: 1,1,20240131120000.000,52.370216,4.895168,12.300,0.00,0.0,1,,...
+	v, err := d.query("+CGNSINF", "+CGNSINF")
+	if err != nil {
+		return nil, err
+	}
+	if len(v) < 8 || v[1] != "1" {
+		return nil, ErrNoFix
+	}
+
+	t, err := time.Parse("20060102150405.000", v[2])
+	if err != nil {
+		return nil, err
+	}
+	var num [5]float64
+	for i := range num {
+		if num[i], err = strconv.ParseFloat(v[3+i], 64); err != nil {
+			return nil, err
+		}
+	}
+	return &Fix{
+		Time:      t,
+		Latitude:  num[0],
+		Longitude: num[1],
+		Altitude:  units.Distance(num[2]),
+		Speed:     num[3],
+		Course:    num[4],
+	}, nil
+}
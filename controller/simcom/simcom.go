@@ -0,0 +1,490 @@
+/*
+Package simcom allows controlling SIMCom cellular modems (SIM800, SIM7000)
+through their AT command interface: network registration, SMS, TCP
+connections and, on the SIM7000, GNSS.
+
+The modems talk at 9600 or 115200 baud, 8N1. The serial port is opened by the
+caller (any io.ReadWriter will do):
+
+	m := simcom.New(port)
+	if err := m.WaitRegistered(time.Minute); err != nil {
+		panic(err)
+	}
+	m.SendSMS("+15551234567", "node 3: battery low")
+
+	for e := range m.Events() {
+		if e.Type == simcom.EventSMS {
+			sms, _ := m.ReadSMS(e.Index)
+			display.Message(sms.Text)
+		}
+	}
+*/
+package simcom
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+const (
+	commandTimeout = 5 * time.Second
+
+	eventsBuffer = 16
+	linesBuffer  = 64
+
+	// The modem asks for the payload of some commands with this prompt,
+	// which is not followed by a line break.
+	prompt = "> "
+)
+
+// ErrError is returned when the modem answers a command with ERROR.
+var ErrError = errors.New("simcom: command failed")
+
+// ErrTimeout is returned when the modem does not answer in time.
+var ErrTimeout = embd.NewError(embd.ErrTimeout, "simcom: timed out waiting for the modem")
+
+// CommandError is returned when the modem answers with an extended error
+// (+CME ERROR or +CMS ERROR).
+type CommandError struct {
+	Command string
+	Result  string
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("simcom: AT%v: %v", e.Command, e.Result)
+}
+
+// EventType is the type of an unsolicited event from the modem.
+type EventType int
+
+// The event types.
+const (
+	// EventSMS is a new SMS, stored at Index.
+	EventSMS EventType = iota
+
+	// EventRing is an incoming call.
+	EventRing
+
+	// EventClosed is the remote end closing the TCP connection.
+	EventClosed
+
+	// EventPowerDown is the modem powering down.
+	EventPowerDown
+
+	// eventData is data waiting on the TCP connection, handled internally.
+	eventData EventType = -1
+)
+
+// Event is an unsolicited event from the modem.
+type Event struct {
+	Type  EventType
+	Index int
+
+	// Line is the raw result code.
+	Line string
+}
+
+// Modem represents a SIMCom modem.
+type Modem struct {
+	Port io.ReadWriter
+
+	// Timeout is the default time to wait for the answer to a command.
+	Timeout time.Duration
+
+	initialized bool
+	initMu      sync.RWMutex
+
+	// mu serializes the commands.
+	mu    sync.Mutex
+	lines chan string
+
+	events chan Event
+
+	connMu sync.Mutex
+	conn   *Conn
+
+	done    chan struct{}
+	readErr error
+}
+
+// New creates a new modem interface. The port variable is the serial port
+// used to communicate with the device.
+func New(port io.ReadWriter) *Modem {
+	return &Modem{
+		Port:    port,
+		Timeout: commandTimeout,
+		lines:   make(chan string, linesBuffer),
+		events:  make(chan Event, eventsBuffer),
+	}
+}
+
+// Events returns the channel receiving the unsolicited events of the modem.
+// Events are dropped when they are not read.
+func (d *Modem) Events() <-chan Event {
+	return d.events
+}
+
+func (d *Modem) setup() error {
+	d.initMu.RLock()
+	if d.initialized {
+		d.initMu.RUnlock()
+		return nil
+	}
+	d.initMu.RUnlock()
+
+	d.initMu.Lock()
+	defer d.initMu.Unlock()
+
+	if d.done == nil {
+		d.done = make(chan struct{})
+		go d.read()
+	}
+
+	for _, cmd := range []string{
+		"E0",              // no echo
+		"+CMEE=1",         // numeric error codes
+		"+CMGF=1",         // text mode SMS
+		"+CNMI=2,1,0,0,0", // new SMS indications
+	} {
+		if _, err := d.command(cmd, d.Timeout); err != nil {
+			return err
+		}
+	}
+
+	d.initialized = true
+
+	return nil
+}
+
+func (d *Modem) read() {
+	defer close(d.done)
+
+	r := bufio.NewReader(d.Port)
+	var line []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			glog.Errorf("simcom: reading: %v", err)
+			d.readErr = err
+			return
+		}
+		switch {
+		case b == '\n':
+			if s := strings.TrimSpace(string(line)); s != "" {
+				d.dispatch(s)
+			}
+			line = line[:0]
+		default:
+			line = append(line, b)
+			if string(line) == prompt {
+				d.dispatch(prompt)
+				line = line[:0]
+			}
+		}
+	}
+}
+
+func (d *Modem) dispatch(line string) {
+	glog.V(2).Infof("simcom: received %q", line)
+
+	if e, ok := parseEvent(line); ok {
+		d.handleEvent(e)
+		return
+	}
+	select {
+	case d.lines <- line:
+	default:
+		glog.Warningf("simcom: dropping %q", line)
+	}
+}
+
+func parseEvent(line string) (Event, bool) {
+	e := Event{Line: line}
+	switch {
+	case strings.HasPrefix(line, "+CMTI:"):
+		// +CMTI: "SM",3
+		e.Type = EventSMS
+		if i := strings.LastIndex(line, ","); i >= 0 {
+			e.Index, _ = strconv.Atoi(line[i+1:])
+		}
+	case line == "RING":
+		e.Type = EventRing
+	case line == "CLOSED":
+		e.Type = EventClosed
+	case line == "NORMAL POWER DOWN", strings.HasPrefix(line, "UNDER-VOLTAGE POWER DOWN"):
+		e.Type = EventPowerDown
+	case line == "+CIPRXGET: 1":
+		e.Type = eventData
+	default:
+		return e, false
+	}
+	return e, true
+}
+
+func (d *Modem) handleEvent(e Event) {
+	switch e.Type {
+	case EventClosed:
+		d.connMu.Lock()
+		if d.conn != nil {
+			d.conn.remoteClosed()
+		}
+		d.connMu.Unlock()
+	case eventData:
+		d.connMu.Lock()
+		if d.conn != nil {
+			d.conn.signal()
+		}
+		d.connMu.Unlock()
+		return
+	}
+
+	select {
+	case d.events <- e:
+	default:
+		glog.Warningf("simcom: dropping event %q", e.Line)
+	}
+}
+
+// final decides whether a line ends the answer to a command.
+type final func(line string) (done bool, err error)
+
+func okFinal(line string) (bool, error) {
+	switch {
+	case line == "OK":
+		return true, nil
+	case line == "ERROR":
+		return true, ErrError
+	case strings.HasPrefix(line, "+CME ERROR:"), strings.HasPrefix(line, "+CMS ERROR:"):
+		return true, &CommandError{Result: line}
+	}
+	return false, nil
+}
+
+// exchange sends a request and collects the lines of the answer until
+// isFinal accepts one. If payload is not nil, it is sent when the modem
+// prompts for it.
+func (d *Modem) exchange(request string, payload []byte, timeout time.Duration, isFinal final) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Drop the leftovers of a previous command which timed out.
+	for len(d.lines) > 0 {
+		<-d.lines
+	}
+
+	glog.V(2).Infof("simcom: sending %q", request)
+	if _, err := io.WriteString(d.Port, request); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var lines []string
+	for {
+		select {
+		case line := <-d.lines:
+			if line == prompt {
+				if payload == nil {
+					continue
+				}
+				if _, err := d.Port.Write(payload); err != nil {
+					return nil, err
+				}
+				payload = nil
+				continue
+			}
+			done, err := isFinal(line)
+			if done {
+				if e, ok := err.(*CommandError); ok {
+					e.Command = strings.TrimSuffix(strings.TrimPrefix(request, "AT"), "\r")
+				}
+				return lines, err
+			}
+			lines = append(lines, line)
+		case <-timer.C:
+			return lines, ErrTimeout
+		case <-d.done:
+			return lines, d.readErr
+		}
+	}
+}
+
+func (d *Modem) command(cmd string, timeout time.Duration) ([]string, error) {
+	return d.exchange("AT"+cmd+"\r", nil, timeout, okFinal)
+}
+
+// Command sends the AT command "AT"+cmd and returns the lines of the
+// answer, without the final OK.
+func (d *Modem) Command(cmd string) ([]string, error) {
+	if err := d.setup(); err != nil {
+		return nil, err
+	}
+	return d.command(cmd, d.Timeout)
+}
+
+// query sends a command and returns the values of its "prefix: values"
+// answer line.
+func (d *Modem) query(cmd, prefix string) ([]string, error) {
+	lines, err := d.Command(cmd)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix+":") {
+			return fields(strings.TrimSpace(line[len(prefix)+1:])), nil
+		}
+	}
+	return nil, fmt.Errorf("simcom: no %v in answer to AT%v", prefix, cmd)
+}
+
+// fields splits comma separated values, unquoting them. Commas inside quotes
+// do not split.
+func fields(s string) []string {
+	var out []string
+	var cur []byte
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			out = append(out, string(cur))
+			cur = cur[:0]
+		default:
+			cur = append(cur, c)
+		}
+	}
+	return append(out, string(cur))
+}
+
+// Registration is the network registration status.
+type Registration int
+
+// The registration statuses.
+const (
+	NotRegistered Registration = iota
+	RegisteredHome
+	Searching
+	Denied
+	Unknown
+	RegisteredRoaming
+)
+
+func (r Registration) String() string {
+	switch r {
+	case NotRegistered:
+		return "not registered"
+	case RegisteredHome:
+		return "home"
+	case Searching:
+		return "searching"
+	case Denied:
+		return "denied"
+	case RegisteredRoaming:
+		return "roaming"
+	default:
+		return "unknown"
+	}
+}
+
+// Registered reports whether the modem is registered on a network.
+func (r Registration) Registered() bool {
+	return r == RegisteredHome || r == RegisteredRoaming
+}
+
+// Registration returns the network registration status.
+func (d *Modem) Registration() (Registration, error) {
+	// +CREG: 0,1
+	v, err := d.query("+CREG?", "+CREG")
+	if err != nil {
+		return Unknown, err
+	}
+	if len(v) < 2 {
+		return Unknown, fmt.Errorf("simcom: bad registration %q", v)
+	}
+	stat, err := strconv.Atoi(v[1])
+	if err != nil {
+		return Unknown, err
+	}
+	return Registration(stat), nil
+}
+
+// WaitRegistered waits for the modem to register on a network.
+func (d *Modem) WaitRegistered(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		r, err := d.Registration()
+		if err != nil {
+			return err
+		}
+		if r.Registered() {
+			return nil
+		}
+		if r == Denied {
+			return fmt.Errorf("simcom: registration denied")
+		}
+		if time.Now().After(deadline) {
+			return ErrTimeout
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// ErrNoSignal is returned when the signal strength is unknown.
+var ErrNoSignal = errors.New("simcom: no signal")
+
+// Signal returns the signal strength in dBm.
+func (d *Modem) Signal() (int, error) {
+	// +CSQ: 17,0
+	v, err := d.query("+CSQ", "+CSQ")
+	if err != nil {
+		return 0, err
+	}
+	rssi, err := strconv.Atoi(v[0])
+	if err != nil {
+		return 0, err
+	}
+	if rssi == 99 {
+		return 0, ErrNoSignal
+	}
+	return -113 + 2*rssi, nil
+}
+
+// Status is a summary of the modem state, short enough for a character
+// display.
+type Status struct {
+	Registration Registration
+
+	// Signal is in dBm, 0 when unknown.
+	Signal int
+}
+
+func (s Status) String() string {
+	if s.Signal == 0 {
+		return s.Registration.String()
+	}
+	return fmt.Sprintf("%v %vdBm", s.Registration, s.Signal)
+}
+
+// Status returns the registration status and signal strength.
+func (d *Modem) Status() (Status, error) {
+	r, err := d.Registration()
+	if err != nil {
+		return Status{}, err
+	}
+	s := Status{Registration: r}
+	if s.Signal, err = d.Signal(); err != nil && err != ErrNoSignal {
+		return s, err
+	}
+	return s, nil
+}
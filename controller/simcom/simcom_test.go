@@ -0,0 +1,150 @@
+package simcom
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type port struct {
+	io.Reader
+	io.Writer
+}
+
+// fakeModem answers the commands of the driver from replies, with OK by
+// default.
+type fakeModem struct {
+	out     *io.PipeWriter
+	replies map[string]string
+	sent    chan string
+}
+
+func newFakeModem(t *testing.T, replies map[string]string) (*Modem, *fakeModem) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	f := &fakeModem{out: outW, replies: replies, sent: make(chan string, 16)}
+
+	go func() {
+		r := bufio.NewReader(inR)
+		for {
+			cmd, err := r.ReadString('\r')
+			if err != nil {
+				return
+			}
+			cmd = strings.TrimSpace(cmd)
+			if strings.HasPrefix(cmd, "AT+CMGS=") {
+				io.WriteString(outW, "\r\n> ")
+				text, err := r.ReadString('\x1a')
+				if err != nil {
+					return
+				}
+				f.sent <- strings.TrimSuffix(text, "\x1a")
+				io.WriteString(outW, "\r\n+CMGS: 5\r\n\r\nOK\r\n")
+				continue
+			}
+			reply, ok := replies[cmd]
+			if !ok {
+				reply = "OK"
+			}
+			io.WriteString(outW, "\r\n"+reply+"\r\n")
+		}
+	}()
+
+	m := New(port{outR, inW})
+	m.Timeout = time.Second
+	return m, f
+}
+
+func TestStatus(t *testing.T) {
+	m, _ := newFakeModem(t, map[string]string{
+		"AT+CREG?": "+CREG: 0,5\r\n\r\nOK",
+		"AT+CSQ":   "+CSQ: 20,0\r\n\r\nOK",
+	})
+	s, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Registration != RegisteredRoaming || s.Signal != -73 {
+		t.Errorf("status = %+v; want roaming at -73 dBm", s)
+	}
+	if got := s.String(); got != "roaming -73dBm" {
+		t.Errorf("status = %q", got)
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	m, _ := newFakeModem(t, map[string]string{"AT+CMGR=9": "+CMS ERROR: 321"})
+	_, err := m.ReadSMS(9)
+	if e, ok := err.(*CommandError); !ok || e.Command != "+CMGR=9" || e.Result != "+CMS ERROR: 321" {
+		t.Errorf("err = %#v; want a +CMS error for +CMGR=9", err)
+	}
+}
+
+func TestSMS(t *testing.T) {
+	m, f := newFakeModem(t, map[string]string{
+		"AT+CMGR=3": `+CMGR: "REC UNREAD","+15551234567","","24/01/31,12:30:00+04"` + "\r\nPump on,\r\nplease\r\n\r\nOK",
+	})
+
+	if err := m.SendSMS("+15551234567", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if text := <-f.sent; text != "hello" {
+		t.Errorf("sent %q; want hello", text)
+	}
+
+	io.WriteString(f.out, "\r\n+CMTI: \"SM\",3\r\n")
+	e := <-m.Events()
+	if e.Type != EventSMS || e.Index != 3 {
+		t.Fatalf("event = %+v; want a new SMS at 3", e)
+	}
+
+	sms, err := m.ReadSMS(e.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sms.Sender != "+15551234567" || sms.Text != "Pump on,\nplease" || sms.Status != "REC UNREAD" {
+		t.Errorf("sms = %+v", sms)
+	}
+	if want := time.Date(2024, 1, 31, 11, 30, 0, 0, time.UTC); !sms.Time.Equal(want) {
+		t.Errorf("time = %v; want %v", sms.Time, want)
+	}
+}
+
+func TestGNSS(t *testing.T) {
+	m, _ := newFakeModem(t, map[string]string{
+		"AT+CGNSINF": "+CGNSINF: 1,1,20240131120000.000,52.370216,4.895168,12.300,3.50,90.0,1,,1.1,1.4,0.9,,10,6,,,40,,\r\n\r\nOK",
+	})
+	fix, err := m.GNSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fix.Latitude != 52.370216 || fix.Longitude != 4.895168 || fix.Altitude != 12.3 || fix.Speed != 3.5 || fix.Course != 90 {
+		t.Errorf("fix = %+v", fix)
+	}
+
+	m, _ = newFakeModem(t, map[string]string{"AT+CGNSINF": "+CGNSINF: 1,0,,,,,,,0,,,,,,,,,,,,\r\n\r\nOK"})
+	if _, err := m.GNSS(); err != ErrNoFix {
+		t.Errorf("err = %v; want %v", err, ErrNoFix)
+	}
+}
+
+func TestConnRead(t *testing.T) {
+	m, _ := newFakeModem(t, map[string]string{
+		"AT+CIPSTART=\"TCP\",\"example.com\",\"80\"": "OK\r\n\r\nCONNECT OK",
+		"AT+CIPRXGET=3,16":                           "+CIPRXGET: 3,5,0\r\n68656c6c6f\r\n\r\nOK",
+	})
+	c, err := m.Dial("tcp", "example.com:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "hello" {
+		t.Errorf("read %q; want hello", got)
+	}
+}
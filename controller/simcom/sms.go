@@ -0,0 +1,127 @@
+// SMS.
+
+package simcom
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const smsTimeout = 60 * time.Second
+
+// SMS is a text message.
+type SMS struct {
+	Index int
+
+	// Status is "REC UNREAD", "REC READ", "STO UNSENT" or "STO SENT".
+	Status string
+	Sender string
+	Time   time.Time
+	Text   string
+}
+
+// SendSMS sends a text message to number.
+func (d *Modem) SendSMS(number, text string) error {
+	if err := d.setup(); err != nil {
+		return err
+	}
+	// The text is ended by Ctrl-Z.
+	_, err := d.exchange(fmt.Sprintf("AT+CMGS=%q\r", number), []byte(text+"\x1a"), smsTimeout, okFinal)
+	return err
+}
+
+// parseTime parses the "yy/MM/dd,hh:mm:ss+zz" timestamps of the modem,
+// where zz is the offset from UTC in quarters of an hour.
+func parseTime(s string) (time.Time, error) {
+	const layout = "06/01/02,15:04:05"
+	if len(s) < len(layout)+2 {
+		return time.Time{}, fmt.Errorf("simcom: bad time %q", s)
+	}
+	quarters, err := strconv.Atoi(s[len(layout):])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("simcom: bad time %q", s)
+	}
+	zone := time.FixedZone("", quarters*15*60)
+	return time.ParseInLocation(layout, s[:len(layout)], zone)
+}
+
+// parseSMS parses the header of a message, without the index for +CMGR:
+// "REC UNREAD","+15551234567","","24/01/31,12:00:00+04"
+func parseSMS(v []string) (*SMS, error) {
+	if len(v) < 2 {
+		return nil, fmt.Errorf("simcom: bad message header %q", v)
+	}
+	sms := &SMS{Status: v[0], Sender: v[1]}
+	// Stored unsent messages have no time.
+	if len(v) >= 4 && v[3] != "" {
+		t, err := parseTime(v[3])
+		if err != nil {
+			return nil, err
+		}
+		sms.Time = t
+	}
+	return sms, nil
+}
+
+// ReadSMS reads the message stored at index.
+func (d *Modem) ReadSMS(index int) (*SMS, error) {
+	lines, err := d.Command(fmt.Sprintf("+CMGR=%v", index))
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "+CMGR:") {
+		return nil, fmt.Errorf("simcom: no message at %v", index)
+	}
+	sms, err := parseSMS(fields(strings.TrimSpace(lines[0][len("+CMGR:"):])))
+	if err != nil {
+		return nil, err
+	}
+	sms.Index = index
+	sms.Text = strings.Join(lines[1:], "\n")
+	return sms, nil
+}
+
+// ListSMS lists the stored messages with the given status, or all of them
+// for "ALL".
+func (d *Modem) ListSMS(status string) ([]*SMS, error) {
+	lines, err := d.Command(fmt.Sprintf("+CMGL=%q", status))
+	if err != nil {
+		return nil, err
+	}
+	var list []*SMS
+	var text []string
+	flush := func() {
+		if len(list) > 0 {
+			list[len(list)-1].Text = strings.Join(text, "\n")
+		}
+		text = nil
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "+CMGL:") {
+			text = append(text, line)
+			continue
+		}
+		flush()
+		v := fields(strings.TrimSpace(line[len("+CMGL:"):]))
+		index, err := strconv.Atoi(v[0])
+		if err != nil {
+			return nil, err
+		}
+		sms, err := parseSMS(v[1:])
+		if err != nil {
+			return nil, err
+		}
+		sms.Index = index
+		list = append(list, sms)
+	}
+	flush()
+	return list, nil
+}
+
+// DeleteSMS deletes the message stored at index.
+func (d *Modem) DeleteSMS(index int) error {
+	_, err := d.Command(fmt.Sprintf("+CMGD=%v", index))
+	return err
+}
@@ -0,0 +1,287 @@
+// TCP connections.
+
+package simcom
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kidoman/embd"
+)
+
+const (
+	attachTimeout  = 85 * time.Second
+	connectTimeout = 75 * time.Second
+	sendTimeout    = 30 * time.Second
+
+	// The modem sends and receives at most this many bytes at once.
+	maxChunk = 1460
+)
+
+// ErrClosed is returned when using a closed connection.
+var ErrClosed = embd.NewError(embd.ErrClosed, "simcom: connection closed")
+
+// AttachGPRS brings up the data connection through the access point apn,
+// and returns the IP address of the modem.
+func (d *Modem) AttachGPRS(apn, user, password string) (string, error) {
+	if err := d.setup(); err != nil {
+		return "", err
+	}
+
+	if _, err := d.exchange("AT+CIPSHUT\r", nil, d.Timeout, func(line string) (bool, error) {
+		if line == "SHUT OK" {
+			return true, nil
+		}
+		return okFinal(line)
+	}); err != nil {
+		return "", err
+	}
+	for _, cmd := range []string{
+		"+CIPMUX=0",   // single connection
+		"+CIPRXGET=1", // received data is read with AT+CIPRXGET
+		fmt.Sprintf("+CSTT=%q,%q,%q", apn, user, password),
+	} {
+		if _, err := d.command(cmd, d.Timeout); err != nil {
+			return "", err
+		}
+	}
+	if _, err := d.command("+CIICR", attachTimeout); err != nil {
+		return "", err
+	}
+
+	// AT+CIFSR answers with the address alone, without OK.
+	var ip string
+	if _, err := d.exchange("AT+CIFSR\r", nil, d.Timeout, func(line string) (bool, error) {
+		if net.ParseIP(line) != nil {
+			ip = line
+			return true, nil
+		}
+		return okFinal(line)
+	}); err != nil {
+		return "", err
+	}
+	return ip, nil
+}
+
+// Conn is a TCP or UDP connection through the modem. The modem handles a
+// single connection at a time.
+type Conn struct {
+	d *Modem
+
+	// ReadTimeout is the time Read waits for data.
+	ReadTimeout time.Duration
+
+	mu     sync.Mutex
+	closed bool
+	data   chan struct{}
+}
+
+// Dial connects to address ("host:port") on network "tcp" or "udp".
+func (d *Modem) Dial(network, address string) (*Conn, error) {
+	if err := d.setup(); err != nil {
+		return nil, err
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	network = strings.ToUpper(network)
+	if network != "TCP" && network != "UDP" {
+		return nil, fmt.Errorf("simcom: unsupported network %q", network)
+	}
+
+	c := &Conn{d: d, ReadTimeout: connectTimeout, data: make(chan struct{}, 1)}
+	d.connMu.Lock()
+	d.conn = c
+	d.connMu.Unlock()
+
+	if _, err := d.exchange(fmt.Sprintf("AT+CIPSTART=%q,%q,%q\r", network, host, port), nil, connectTimeout, func(line string) (bool, error) {
+		switch line {
+		case "OK":
+			// The connection result follows.
+			return false, nil
+		case "CONNECT OK", "ALREADY CONNECT":
+			return true, nil
+		case "CONNECT FAIL":
+			return true, fmt.Errorf("simcom: connecting to %v failed", address)
+		}
+		return okFinal(line)
+	}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Conn) signal() {
+	select {
+	case c.data <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Conn) remoteClosed() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.signal()
+}
+
+func (c *Conn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed
+}
+
+// Read reads the data received on the connection, waiting for some when
+// there is none. It returns io.EOF once the remote end closed the
+// connection and all the data was read.
+func (c *Conn) Read(p []byte) (int, error) {
+	if len(p) > maxChunk {
+		p = p[:maxChunk]
+	}
+	for {
+		// +CIPRXGET: 3,<read>,<remaining>
+		// <hex data>
+		lines, err := c.d.command(fmt.Sprintf("+CIPRXGET=3,%v", len(p)), c.d.Timeout)
+		if err != nil {
+			if c.isClosed() {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		for i, line := range lines {
+			if !strings.HasPrefix(line, "+CIPRXGET: 3,") || i+1 >= len(lines) {
+				continue
+			}
+			v := fields(line[len("+CIPRXGET: "):])
+			n, _ := strconv.Atoi(v[1])
+			if n == 0 {
+				break
+			}
+			data, err := hex.DecodeString(lines[i+1])
+			if err != nil {
+				return 0, err
+			}
+			return copy(p, data), nil
+		}
+
+		if c.isClosed() {
+			return 0, io.EOF
+		}
+		select {
+		case <-c.data:
+		case <-time.After(c.ReadTimeout):
+			return 0, ErrTimeout
+		}
+	}
+}
+
+// Write sends data on the connection.
+func (c *Conn) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		if c.isClosed() {
+			return n, ErrClosed
+		}
+		chunk := p
+		if len(chunk) > maxChunk {
+			chunk = chunk[:maxChunk]
+		}
+		if _, err := c.d.exchange(fmt.Sprintf("AT+CIPSEND=%v\r", len(chunk)), chunk, sendTimeout, func(line string) (bool, error) {
+			switch line {
+			case "SEND OK":
+				return true, nil
+			case "SEND FAIL":
+				return true, fmt.Errorf("simcom: send failed")
+			}
+			return okFinal(line)
+		}); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	c.d.connMu.Lock()
+	if c.d.conn == c {
+		c.d.conn = nil
+	}
+	c.d.connMu.Unlock()
+
+	if c.isClosed() {
+		return nil
+	}
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	_, err := c.d.exchange("AT+CIPCLOSE\r", nil, c.d.Timeout, func(line string) (bool, error) {
+		if line == "CLOSE OK" {
+			return true, nil
+		}
+		return okFinal(line)
+	})
+	return err
+}
+
+type body struct {
+	io.ReadCloser
+	conn *Conn
+}
+
+func (b body) Close() error {
+	b.ReadCloser.Close()
+	return b.conn.Close()
+}
+
+// Get sends a plain HTTP GET request for rawurl. The connection is closed
+// with the body of the response.
+func (d *Modem) Get(rawurl string) (*http.Response, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("simcom: unsupported scheme %q", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+	}
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Close = true
+
+	conn, err := d.Dial("tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	// HTTP/1.0 keeps the response free of chunked encoding.
+	if _, err := fmt.Fprintf(conn, "GET %v HTTP/1.0\r\nHost: %v\r\nConnection: close\r\n\r\n", u.RequestURI(), u.Host); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body = body{resp.Body, conn}
+	return resp, nil
+}
@@ -0,0 +1,260 @@
+// Key-value store.
+
+package w25q
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Flash is the flash memory a Store is kept in, like a W25Q.
+type Flash interface {
+	io.ReaderAt
+
+	// Program programs erased bytes.
+	Program(off int64, p []byte) error
+
+	// EraseSector erases the sector containing offset off.
+	EraseSector(off int64) error
+}
+
+// The sectors of a store start with a header, written once the sector is
+// complete, and are followed by records:
+//
+//	header: magic (2 bytes), generation (4 bytes)
+//	record: key length (1 byte), flags (1 byte), value length (2 bytes),
+//	        CRC-32 of the key and value (4 bytes), key, value
+//
+// The erased bytes (0xff) end the records of a sector.
+const (
+	headerLen = 6
+	recordLen = 8
+
+	flagDeleted = 0x01
+)
+
+var storeMagic = [2]byte{'K', 'V'}
+
+// ErrStoreFull is returned when the live records do not fit in a sector.
+var ErrStoreFull = errors.New("w25q: store is full")
+
+// Store is a small key-value store, for calibration data or configuration.
+// The records are appended to a sector, and the live ones are copied to the
+// next sector when it is full, so the erases are spread over the sectors of
+// the store and an interrupted write loses at most the record being
+// written.
+type Store struct {
+	flash   Flash
+	start   int64
+	sectors int
+
+	mu         sync.Mutex
+	values     map[string][]byte
+	sector     int
+	generation uint32
+	off        int64
+}
+
+// NewStore opens the store kept in the sectors of flash from offset start.
+// It needs at least two sectors.
+func NewStore(flash Flash, start int64, sectors int) (*Store, error) {
+	if sectors < 2 {
+		return nil, fmt.Errorf("w25q: store needs at least 2 sectors, got %v", sectors)
+	}
+	if start%SectorSize != 0 {
+		return nil, fmt.Errorf("w25q: store offset %v is not at a sector boundary", start)
+	}
+	s := &Store{flash: flash, start: start, sectors: sectors, values: map[string][]byte{}}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) sectorOffset(i int) int64 {
+	return s.start + int64(i)*SectorSize
+}
+
+// load finds the sector with the latest generation and reads its records.
+func (s *Store) load() error {
+	found := false
+	for i := 0; i < s.sectors; i++ {
+		var h [headerLen]byte
+		if _, err := s.flash.ReadAt(h[:], s.sectorOffset(i)); err != nil {
+			return err
+		}
+		if h[0] != storeMagic[0] || h[1] != storeMagic[1] {
+			continue
+		}
+		gen := binary.LittleEndian.Uint32(h[2:])
+		if !found || gen > s.generation {
+			found, s.sector, s.generation = true, i, gen
+		}
+	}
+	if !found {
+		// A new store: start from a clean first sector.
+		return s.compact(nil, 0)
+	}
+
+	data := make([]byte, SectorSize)
+	if _, err := s.flash.ReadAt(data, s.sectorOffset(s.sector)); err != nil {
+		return err
+	}
+	off := headerLen
+	for off+recordLen <= SectorSize && data[off] != 0xff {
+		keyLen := int(data[off])
+		flags := data[off+1]
+		valueLen := int(binary.LittleEndian.Uint16(data[off+2:]))
+		sum := binary.LittleEndian.Uint32(data[off+4:])
+		end := off + recordLen + keyLen + valueLen
+		if end > SectorSize {
+			// A record torn at the end of the sector.
+			off = SectorSize
+			break
+		}
+		payload := data[off+recordLen : end]
+		if crc32.ChecksumIEEE(payload) == sum {
+			key := string(payload[:keyLen])
+			if flags&flagDeleted != 0 {
+				delete(s.values, key)
+			} else {
+				s.values[key] = append([]byte(nil), payload[keyLen:]...)
+			}
+		}
+		off = end
+	}
+	s.off = int64(off)
+	return nil
+}
+
+func record(key string, value []byte, flags byte) []byte {
+	r := make([]byte, recordLen, recordLen+len(key)+len(value))
+	r[0], r[1] = byte(len(key)), flags
+	binary.LittleEndian.PutUint16(r[2:], uint16(len(value)))
+	r = append(append(r, key...), value...)
+	binary.LittleEndian.PutUint32(r[4:], crc32.ChecksumIEEE(r[recordLen:]))
+	return r
+}
+
+// compact writes the records of values to the sector next, and makes it the
+// current one.
+func (s *Store) compact(values map[string][]byte, next int) error {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	data := make([]byte, headerLen, SectorSize)
+	for _, k := range keys {
+		data = append(data, record(k, values[k], 0)...)
+		if len(data) > SectorSize {
+			return ErrStoreFull
+		}
+	}
+
+	off := s.sectorOffset(next)
+	if err := s.flash.EraseSector(off); err != nil {
+		return err
+	}
+	if err := s.flash.Program(off+headerLen, data[headerLen:]); err != nil {
+		return err
+	}
+	// The header makes the sector current once the records are written.
+	copy(data, storeMagic[:])
+	binary.LittleEndian.PutUint32(data[2:], s.generation+1)
+	if err := s.flash.Program(off, data[:headerLen]); err != nil {
+		return err
+	}
+
+	s.sector, s.generation, s.off = next, s.generation+1, int64(len(data))
+	return nil
+}
+
+// append writes a record to the current sector, compacting the store into
+// the next sector when it is full.
+func (s *Store) append(key string, value []byte, flags byte) error {
+	if len(key) == 0 || len(key) >= 0xff {
+		return fmt.Errorf("w25q: invalid key length %v", len(key))
+	}
+	r := record(key, value, flags)
+	if headerLen+len(r) > SectorSize {
+		return ErrStoreFull
+	}
+
+	if s.off+int64(len(r)) <= SectorSize {
+		if err := s.flash.Program(s.sectorOffset(s.sector)+s.off, r); err != nil {
+			return err
+		}
+		s.off += int64(len(r))
+		return nil
+	}
+
+	values := make(map[string][]byte, len(s.values)+1)
+	for k, v := range s.values {
+		values[k] = v
+	}
+	if flags&flagDeleted != 0 {
+		delete(values, key)
+	} else {
+		values[key] = value
+	}
+	return s.compact(values, (s.sector+1)%s.sectors)
+}
+
+// Get returns the value of the key.
+func (s *Store) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.values[key]
+	return append([]byte(nil), v...), ok
+}
+
+// Set sets the value of the key.
+func (s *Store) Set(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.values[key]; ok && string(old) == string(value) {
+		return nil
+	}
+	if err := s.append(key, value, 0); err != nil {
+		return err
+	}
+	s.values[key] = append([]byte(nil), value...)
+	return nil
+}
+
+// Delete deletes the key.
+func (s *Store) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.values[key]; !ok {
+		return nil
+	}
+	if err := s.append(key, nil, flagDeleted); err != nil {
+		return err
+	}
+	delete(s.values, key)
+	return nil
+}
+
+// Keys returns the sorted keys of the store.
+func (s *Store) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,272 @@
+// Package w25q allows interfacing with the W25Qxx SPI NOR flash chips, and the
+// compatible chips of other manufacturers.
+package w25q
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+const (
+	// PageSize is the most data programmed at once.
+	PageSize = 256
+
+	// SectorSize is the smallest area erased at once.
+	SectorSize = 4096
+
+	cmdWriteEnable      = 0x06
+	cmdReadStatus       = 0x05
+	cmdJEDECID          = 0x9f
+	cmdFastRead         = 0x0b
+	cmdFastRead4        = 0x0c
+	cmdPageProgram      = 0x02
+	cmdPageProgram4     = 0x12
+	cmdSectorErase      = 0x20
+	cmdSectorErase4     = 0x21
+	cmdChipErase        = 0xc7
+	cmdPowerDown        = 0xb9
+	cmdReleasePowerDown = 0xab
+
+	statusBusy = 0x01
+
+	// Keep the transfers within the default 4096 bytes buffer of spidev.
+	maxRead = 2048
+
+	programTimeout = 10 * time.Millisecond
+	eraseTimeout   = time.Second
+	chipTimeout    = 400 * time.Second
+)
+
+// ErrTimeout is returned when the chip stays busy longer than expected.
+var ErrTimeout = embd.NewError(embd.ErrTimeout, "w25q: timeout waiting for the chip")
+
+// JEDECID identifies a flash chip.
+type JEDECID struct {
+	Manufacturer, Type, Capacity byte
+}
+
+// Size returns the size of the chip in bytes.
+func (id JEDECID) Size() int64 {
+	return 1 << id.Capacity
+}
+
+func (id JEDECID) String() string {
+	return fmt.Sprintf("%02x %02x %02x (%v KiB)", id.Manufacturer, id.Type, id.Capacity, id.Size()/1024)
+}
+
+// W25Q represents a W25Qxx flash chip.
+type W25Q struct {
+	Bus embd.SPIBus
+
+	mu          sync.Mutex
+	id          JEDECID
+	initialized bool
+}
+
+// New creates a new W25Q interface on the SPI bus.
+func New(bus embd.SPIBus) *W25Q {
+	return &W25Q{Bus: bus}
+}
+
+func (d *W25Q) setup() error {
+	if d.initialized {
+		return nil
+	}
+
+	// The chip ignores the other commands while powered down.
+	if err := d.Bus.TransferAndRecieveData([]byte{cmdReleasePowerDown}); err != nil {
+		return err
+	}
+	time.Sleep(5 * time.Microsecond)
+
+	buf := []byte{cmdJEDECID, 0, 0, 0}
+	if err := d.Bus.TransferAndRecieveData(buf); err != nil {
+		return err
+	}
+	id := JEDECID{buf[1], buf[2], buf[3]}
+	if id.Manufacturer == 0x00 || id.Manufacturer == 0xff || id.Capacity < 16 || id.Capacity > 31 {
+		return fmt.Errorf("w25q: no flash chip found, read JEDEC ID %v", id)
+	}
+	glog.V(1).Infof("w25q: found flash chip %v", id)
+
+	d.id = id
+	d.initialized = true
+
+	return nil
+}
+
+// ID returns the JEDEC ID of the chip.
+func (d *W25Q) ID() (JEDECID, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.setup(); err != nil {
+		return JEDECID{}, err
+	}
+	return d.id, nil
+}
+
+// Size returns the size of the chip in bytes.
+func (d *W25Q) Size() (int64, error) {
+	id, err := d.ID()
+	return id.Size(), err
+}
+
+// command builds a command at an address, using the 4 byte address variant
+// of the command for chips larger than 16 MiB.
+func (d *W25Q) command(cmd, cmd4 byte, addr int64, extra int) []byte {
+	if d.id.Size() > 1<<24 {
+		return append([]byte{cmd4, byte(addr >> 24), byte(addr >> 16), byte(addr >> 8), byte(addr)}, make([]byte, extra)...)
+	}
+	return append([]byte{cmd, byte(addr >> 16), byte(addr >> 8), byte(addr)}, make([]byte, extra)...)
+}
+
+func (d *W25Q) checkRange(off int64, n int) error {
+	if off < 0 || off+int64(n) > d.id.Size() {
+		return fmt.Errorf("w25q: range [%v, %v) is outside of the %v bytes chip", off, off+int64(n), d.id.Size())
+	}
+	return nil
+}
+
+// ReadAt reads len(p) bytes from offset off, implementing io.ReaderAt.
+func (d *W25Q) ReadAt(p []byte, off int64) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.setup(); err != nil {
+		return 0, err
+	}
+	if err := d.checkRange(off, len(p)); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for n < len(p) {
+		chunk := len(p) - n
+		if chunk > maxRead {
+			chunk = maxRead
+		}
+		// The fast read takes a dummy byte after the address.
+		buf := d.command(cmdFastRead, cmdFastRead4, off+int64(n), 1+chunk)
+		if err := d.Bus.TransferAndRecieveData(buf); err != nil {
+			return n, err
+		}
+		n += copy(p[n:], buf[len(buf)-chunk:])
+	}
+	return n, nil
+}
+
+func (d *W25Q) writeEnable() error {
+	return d.Bus.TransferAndRecieveData([]byte{cmdWriteEnable})
+}
+
+// wait waits for the chip to finish programming or erasing.
+func (d *W25Q) wait(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		buf := []byte{cmdReadStatus, 0}
+		if err := d.Bus.TransferAndRecieveData(buf); err != nil {
+			return err
+		}
+		if buf[1]&statusBusy == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrTimeout
+		}
+		time.Sleep(timeout / 100)
+	}
+}
+
+// Program programs p at offset off. Programming can only clear bits, so the
+// area must have been erased before.
+func (d *W25Q) Program(off int64, p []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.setup(); err != nil {
+		return err
+	}
+	if err := d.checkRange(off, len(p)); err != nil {
+		return err
+	}
+
+	for len(p) > 0 {
+		// A program wraps around within its page.
+		chunk := PageSize - int(off%PageSize)
+		if chunk > len(p) {
+			chunk = len(p)
+		}
+		if err := d.writeEnable(); err != nil {
+			return err
+		}
+		buf := d.command(cmdPageProgram, cmdPageProgram4, off, 0)
+		buf = append(buf, p[:chunk]...)
+		if err := d.Bus.TransferAndRecieveData(buf); err != nil {
+			return err
+		}
+		if err := d.wait(programTimeout); err != nil {
+			return err
+		}
+		off += int64(chunk)
+		p = p[chunk:]
+	}
+	return nil
+}
+
+// EraseSector erases the sector containing offset off, setting all its bytes
+// to 0xff.
+func (d *W25Q) EraseSector(off int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.setup(); err != nil {
+		return err
+	}
+	if err := d.checkRange(off, 1); err != nil {
+		return err
+	}
+
+	if err := d.writeEnable(); err != nil {
+		return err
+	}
+	off -= off % SectorSize
+	if err := d.Bus.TransferAndRecieveData(d.command(cmdSectorErase, cmdSectorErase4, off, 0)); err != nil {
+		return err
+	}
+	return d.wait(eraseTimeout)
+}
+
+// EraseChip erases the whole chip, which takes up to a few minutes.
+func (d *W25Q) EraseChip() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.setup(); err != nil {
+		return err
+	}
+
+	if err := d.writeEnable(); err != nil {
+		return err
+	}
+	if err := d.Bus.TransferAndRecieveData([]byte{cmdChipErase}); err != nil {
+		return err
+	}
+	return d.wait(chipTimeout)
+}
+
+// Close puts the chip in power down.
+func (d *W25Q) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.initialized {
+		return nil
+	}
+	d.initialized = false
+	return d.Bus.TransferAndRecieveData([]byte{cmdPowerDown})
+}
@@ -0,0 +1,146 @@
+package w25q
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+// fakeChip emulates the commands of a 1 MiB chip on a SPI bus.
+type fakeChip struct {
+	embd.SPIBus
+	mem     []byte
+	enabled bool
+	erases  map[int64]int
+}
+
+func newFakeChip() *fakeChip {
+	mem := bytes.Repeat([]byte{0xff}, 1<<20)
+	return &fakeChip{mem: mem, erases: map[int64]int{}}
+}
+
+func (c *fakeChip) TransferAndRecieveData(buf []byte) error {
+	addr := func() int64 {
+		return int64(buf[1])<<16 | int64(buf[2])<<8 | int64(buf[3])
+	}
+	switch buf[0] {
+	case cmdReleasePowerDown, cmdPowerDown:
+	case cmdJEDECID:
+		copy(buf[1:], []byte{0xef, 0x40, 0x14})
+	case cmdReadStatus:
+		buf[1] = 0
+	case cmdWriteEnable:
+		c.enabled = true
+	case cmdFastRead:
+		copy(buf[5:], c.mem[addr():])
+	case cmdPageProgram:
+		if !c.enabled {
+			return fmt.Errorf("program without write enable")
+		}
+		a := addr()
+		page := a - a%PageSize
+		for i, b := range buf[4:] {
+			c.mem[page+(a+int64(i))%PageSize] &= b
+		}
+		c.enabled = false
+	case cmdSectorErase:
+		if !c.enabled {
+			return fmt.Errorf("erase without write enable")
+		}
+		a := addr()
+		copy(c.mem[a:a+SectorSize], bytes.Repeat([]byte{0xff}, SectorSize))
+		c.erases[a]++
+		c.enabled = false
+	default:
+		return fmt.Errorf("unexpected command %#x", buf[0])
+	}
+	return nil
+}
+
+func TestReadProgramErase(t *testing.T) {
+	chip := newFakeChip()
+	d := New(chip)
+
+	id, err := d.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Size() != 1<<20 {
+		t.Errorf("size: got %v, want 1 MiB", id.Size())
+	}
+
+	// A program across a page boundary.
+	data := bytes.Repeat([]byte{0x5a}, 300)
+	if err := d.Program(200, data); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 3000)
+	if _, err := d.ReadAt(got, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got[200:500], data) || got[199] != 0xff || got[500] != 0xff {
+		t.Error("read back data does not match the programmed data")
+	}
+
+	if err := d.EraseSector(1000); err != nil {
+		t.Fatal(err)
+	}
+	if chip.erases[0] != 1 || chip.mem[300] != 0xff {
+		t.Error("sector containing offset 1000 was not erased")
+	}
+
+	if err := d.Program(1<<20-10, data); err == nil {
+		t.Error("no error programming past the end of the chip")
+	}
+}
+
+func TestStore(t *testing.T) {
+	chip := newFakeChip()
+	d := New(chip)
+
+	s, err := NewStore(d, SectorSize, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("cal.offset", []byte{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set("name", []byte("greenhouse")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("name"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Enough writes to go around the sectors a few times.
+	for i := 0; i < 2000; i++ {
+		if err := s.Set("counter", []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err = NewStore(d, SectorSize, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"cal.offset", "counter"}; !reflect.DeepEqual(s.Keys(), want) {
+		t.Errorf("keys: got %v, want %v", s.Keys(), want)
+	}
+	if v, ok := s.Get("counter"); !ok || string(v) != "1999" {
+		t.Errorf("counter: got %q, %v, want 1999", v, ok)
+	}
+	if v, ok := s.Get("cal.offset"); !ok || !bytes.Equal(v, []byte{1, 2}) {
+		t.Errorf("cal.offset: got %v, %v", v, ok)
+	}
+	for i := 1; i <= 3; i++ {
+		if n := chip.erases[int64(i)*SectorSize]; n < 2 {
+			t.Errorf("sector %v: erased %v times, want the erases spread over the sectors", i, n)
+		}
+	}
+	if chip.erases[0] != 0 {
+		t.Error("erased a sector outside of the store")
+	}
+}
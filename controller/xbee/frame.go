@@ -0,0 +1,118 @@
+// API frames.
+
+package xbee
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+const (
+	startDelimiter = 0x7E
+	escape         = 0x7D
+	xon            = 0x11
+	xoff           = 0x13
+)
+
+// ErrChecksum is returned for corrupted frames.
+var ErrChecksum = errors.New("xbee: bad frame checksum")
+
+// ErrEmptyFrame is returned for frames of length zero, like the noise of a
+// line glitch following a start delimiter.
+var ErrEmptyFrame = errors.New("xbee: empty frame")
+
+func needsEscape(b byte) bool {
+	return b == startDelimiter || b == escape || b == xon || b == xoff
+}
+
+// encode builds an API frame around data, escaping it for API mode 2.
+func encode(data []byte, escaped bool) []byte {
+	n := len(data)
+	raw := append([]byte{byte(n >> 8), byte(n)}, data...)
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	raw = append(raw, 0xFF-sum)
+
+	frame := []byte{startDelimiter}
+	for _, b := range raw {
+		if escaped && needsEscape(b) {
+			frame = append(frame, escape, b^0x20)
+			continue
+		}
+		frame = append(frame, b)
+	}
+	return frame
+}
+
+type frameReader struct {
+	r       *bufio.Reader
+	escaped bool
+}
+
+func (f *frameReader) readByte() (byte, error) {
+	b, err := f.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if f.escaped && b == escape {
+		b, err = f.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		b ^= 0x20
+	}
+	return b, nil
+}
+
+// read returns the data of the next frame, skipping the bytes preceding
+// its start delimiter.
+func (f *frameReader) read() ([]byte, error) {
+	for {
+		b, err := f.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == startDelimiter {
+			break
+		}
+	}
+
+	var head [2]byte
+	for i := range head {
+		b, err := f.readByte()
+		if err != nil {
+			return nil, err
+		}
+		head[i] = b
+	}
+	n := int(head[0])<<8 | int(head[1])
+	if n == 0 {
+		return nil, ErrEmptyFrame
+	}
+
+	data := make([]byte, n)
+	var sum byte
+	for i := range data {
+		b, err := f.readByte()
+		if err != nil {
+			return nil, err
+		}
+		data[i] = b
+		sum += b
+	}
+	checksum, err := f.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if sum+checksum != 0xFF {
+		return nil, ErrChecksum
+	}
+	return data, nil
+}
+
+func newFrameReader(r io.Reader, escaped bool) *frameReader {
+	return &frameReader{r: bufio.NewReader(r), escaped: escaped}
+}
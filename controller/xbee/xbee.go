@@ -0,0 +1,436 @@
+/*
+Package xbee allows sending and receiving datagrams with Zigbee XBee modules
+(S2C, XBee 3) in API mode, and configuring local and remote modules with AT
+commands.
+
+The module must be configured for API mode (AP=1, or AP=2 with Escaped set)
+and talks at 9600 baud, 8N1 by default. The serial port is opened by the
+caller (any io.ReadWriter will do). XBee implements radio.Radio.
+*/
+package xbee
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/health"
+	"github.com/kidoman/embd/interface/radio"
+)
+
+const (
+	// Broadcast is the address reaching all the modules of the network.
+	Broadcast radio.Addr = 0xFFFF
+
+	// Coordinator is the address of the network coordinator.
+	Coordinator radio.Addr = 0
+
+	atCommand        = 0x08
+	atResponse       = 0x88
+	remoteATCommand  = 0x17
+	remoteATResponse = 0x97
+	transmitRequest  = 0x10
+	transmitStatus   = 0x8B
+	receivePacket    = 0x90
+	modemStatus      = 0x8A
+
+	unknown16 = 0xFFFE
+
+	// applyChanges makes remote AT commands take effect immediately.
+	applyChanges = 0x02
+
+	// maxPayload is the largest unfragmented Zigbee payload without
+	// encryption or source routing.
+	maxPayload = 84
+
+	responseTimeout = 5 * time.Second
+	datagramsBuffer = 16
+)
+
+// ErrTimeout is returned when the module does not answer in time.
+var ErrTimeout = embd.NewError(embd.ErrTimeout, "xbee: timed out waiting for the module")
+
+// ErrClosed is returned when the module is closed.
+var ErrClosed = embd.NewError(embd.ErrClosed, "xbee: closed")
+
+// ATError is returned when an AT command fails.
+type ATError struct {
+	Command string
+	Status  byte
+}
+
+func (e *ATError) Error() string {
+	var reason string
+	switch e.Status {
+	case 1:
+		reason = "error"
+	case 2:
+		reason = "invalid command"
+	case 3:
+		reason = "invalid parameter"
+	case 4:
+		reason = "transmission failure"
+	default:
+		reason = fmt.Sprintf("status %#02x", e.Status)
+	}
+	return fmt.Sprintf("xbee: AT%v: %v", e.Command, reason)
+}
+
+// DeliveryError is returned when a datagram could not be delivered.
+type DeliveryError struct {
+	To     radio.Addr
+	Status byte
+}
+
+func (e *DeliveryError) Error() string {
+	var reason string
+	switch e.Status {
+	case 0x01:
+		reason = "MAC ACK failure"
+	case 0x21:
+		reason = "network ACK failure"
+	case 0x22:
+		reason = "not joined to network"
+	case 0x24:
+		reason = "address not found"
+	case 0x25:
+		reason = "route not found"
+	case 0x74:
+		reason = "payload too large"
+	default:
+		reason = fmt.Sprintf("status %#02x", e.Status)
+	}
+	return fmt.Sprintf("xbee: delivery to %v failed: %v", e.To, reason)
+}
+
+// XBee represents an XBee module.
+type XBee struct {
+	Port io.ReadWriter
+
+	// Escaped is set for modules in escaped API mode (AP=2).
+	Escaped bool
+
+	// Timeout is the time to wait for the answer to a frame.
+	Timeout time.Duration
+
+	// HealthName is the name the reading of the module is reported under to
+	// the health registry, "xbee" when empty. Give each module its own.
+	HealthName string
+
+	initialized bool
+	initMu      sync.RWMutex
+
+	mu      sync.Mutex
+	frameID byte
+	pending map[byte]chan []byte
+	closed  bool
+
+	datagrams chan *radio.Datagram
+	done      chan struct{}
+	readErr   error
+}
+
+// New creates a new XBee interface. The port variable is the serial port
+// used to communicate with the device.
+func New(port io.ReadWriter) *XBee {
+	return &XBee{
+		Port:      port,
+		Timeout:   responseTimeout,
+		pending:   make(map[byte]chan []byte),
+		datagrams: make(chan *radio.Datagram, datagramsBuffer),
+	}
+}
+
+func (d *XBee) setup() {
+	d.initMu.RLock()
+	if d.initialized {
+		d.initMu.RUnlock()
+		return
+	}
+	d.initMu.RUnlock()
+
+	d.initMu.Lock()
+	defer d.initMu.Unlock()
+
+	if d.initialized {
+		return
+	}
+	d.done = make(chan struct{})
+	go d.read(newFrameReader(d.Port, d.Escaped))
+
+	d.initialized = true
+}
+
+func (d *XBee) read(r *frameReader) {
+	defer close(d.done)
+	defer close(d.datagrams)
+
+	for {
+		data, err := r.read()
+		// Corrupted frames are skipped, reading on from the next start
+		// delimiter; only failing to read the port stops the radio.
+		if err == ErrChecksum || err == ErrEmptyFrame {
+			glog.Warningf("xbee: %v", err)
+			health.Observe(d.healthName(), err)
+			continue
+		}
+		if err != nil {
+			glog.Errorf("xbee: reading: %v", err)
+			d.readErr = err
+			if !d.isClosed() {
+				health.Default.Fail(d.healthName(), err)
+			}
+			return
+		}
+		health.Observe(d.healthName(), nil)
+		glog.V(2).Infof("xbee: received % x", data)
+		d.dispatch(data)
+	}
+}
+
+// healthName returns the name of the module in the health registry.
+func (d *XBee) healthName() string {
+	if d.HealthName == "" {
+		return "xbee"
+	}
+	return d.HealthName
+}
+
+func (d *XBee) isClosed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.closed
+}
+
+func (d *XBee) dispatch(data []byte) {
+	switch data[0] {
+	case atResponse, remoteATResponse, transmitStatus:
+		if len(data) < 2 {
+			return
+		}
+		d.mu.Lock()
+		c, ok := d.pending[data[1]]
+		delete(d.pending, data[1])
+		d.mu.Unlock()
+		if ok {
+			c <- data
+		}
+	case receivePacket:
+		// 0x90, source 64, source 16, options, data
+		if len(data) < 12 {
+			return
+		}
+		dg := &radio.Datagram{
+			From:    radio.Addr(binary.BigEndian.Uint64(data[1:9])),
+			Payload: data[12:],
+		}
+		select {
+		case d.datagrams <- dg:
+		default:
+			glog.Warningf("xbee: dropping datagram from %v, datagrams are not being received", dg.From)
+		}
+	case modemStatus:
+		if len(data) >= 2 {
+			glog.V(1).Infof("xbee: modem status %#02x", data[1])
+		}
+	}
+}
+
+// request sends a frame built by build with a new frame id, and waits for
+// the answer.
+func (d *XBee) request(build func(id byte) []byte) ([]byte, error) {
+	d.setup()
+
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil, ErrClosed
+	}
+	d.frameID++
+	if d.frameID == 0 {
+		// Frame id 0 disables the answer.
+		d.frameID = 1
+	}
+	id := d.frameID
+	c := make(chan []byte, 1)
+	d.pending[id] = c
+	frame := encode(build(id), d.Escaped)
+	glog.V(2).Infof("xbee: sending % x", frame)
+	_, err := d.Port.Write(frame)
+	d.mu.Unlock()
+
+	if err == nil {
+		timer := time.NewTimer(d.Timeout)
+		defer timer.Stop()
+
+		select {
+		case data := <-c:
+			return data, nil
+		case <-timer.C:
+			err = ErrTimeout
+		case <-d.done:
+			err = ErrClosed
+		}
+	}
+
+	d.mu.Lock()
+	delete(d.pending, id)
+	d.mu.Unlock()
+	return nil, err
+}
+
+func checkCommand(cmd string) error {
+	if len(cmd) != 2 {
+		return fmt.Errorf("xbee: invalid AT command %q", cmd)
+	}
+	return nil
+}
+
+// AT sends the AT command cmd ("ID", "DB"...) to the local module, with an
+// optional parameter, and returns its value.
+func (d *XBee) AT(cmd string, param []byte) ([]byte, error) {
+	if err := checkCommand(cmd); err != nil {
+		return nil, err
+	}
+	// 0x88, frame id, command, status, value
+	resp, err := d.request(func(id byte) []byte {
+		return append([]byte{atCommand, id, cmd[0], cmd[1]}, param...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 5 {
+		return nil, fmt.Errorf("xbee: short AT response")
+	}
+	if resp[4] != 0 {
+		return nil, &ATError{Command: cmd, Status: resp[4]}
+	}
+	return resp[5:], nil
+}
+
+// RemoteAT sends the AT command cmd to the module at address to, with an
+// optional parameter which is applied immediately, and returns its value.
+func (d *XBee) RemoteAT(to radio.Addr, cmd string, param []byte) ([]byte, error) {
+	if err := checkCommand(cmd); err != nil {
+		return nil, err
+	}
+	// 0x97, frame id, source 64, source 16, command, status, value
+	resp, err := d.request(func(id byte) []byte {
+		f := []byte{remoteATCommand, id}
+		f = append(f, addr64(to)...)
+		f = append(f, unknown16>>8, unknown16&0xFF, applyChanges, cmd[0], cmd[1])
+		return append(f, param...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 15 {
+		return nil, fmt.Errorf("xbee: short remote AT response")
+	}
+	if resp[14] != 0 {
+		return nil, &ATError{Command: cmd, Status: resp[14]}
+	}
+	return resp[15:], nil
+}
+
+func addr64(a radio.Addr) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(a))
+	return b
+}
+
+// Send implements radio.Radio. It waits for the delivery status, and
+// returns a *DeliveryError when the datagram was not delivered.
+func (d *XBee) Send(to radio.Addr, payload []byte) error {
+	if len(payload) > maxPayload {
+		return fmt.Errorf("xbee: payload of %v bytes is larger than %v", len(payload), maxPayload)
+	}
+	// 0x8B, frame id, destination 16, retries, delivery status, discovery status
+	resp, err := d.request(func(id byte) []byte {
+		f := []byte{transmitRequest, id}
+		f = append(f, addr64(to)...)
+		f = append(f, unknown16>>8, unknown16&0xFF, 0, 0)
+		return append(f, payload...)
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp) < 6 {
+		return fmt.Errorf("xbee: short transmit status")
+	}
+	if resp[5] != 0 {
+		return &DeliveryError{To: to, Status: resp[5]}
+	}
+	return nil
+}
+
+// Receive implements radio.Radio. The RSSI of the datagram is read from the
+// module right after it is received.
+func (d *XBee) Receive() (*radio.Datagram, error) {
+	d.setup()
+
+	dg, ok := <-d.datagrams
+	if !ok {
+		if d.readErr != nil && d.readErr != io.EOF {
+			return nil, d.readErr
+		}
+		return nil, io.EOF
+	}
+	if rssi, err := d.RSSI(); err == nil {
+		dg.RSSI = rssi
+	}
+	return dg, nil
+}
+
+// MaxPayload implements radio.Radio.
+func (d *XBee) MaxPayload() int {
+	return maxPayload
+}
+
+// Addr returns the 64 bit address of the local module.
+func (d *XBee) Addr() (radio.Addr, error) {
+	var a uint64
+	for _, cmd := range []string{"SH", "SL"} {
+		v, err := d.AT(cmd, nil)
+		if err != nil {
+			return 0, err
+		}
+		var half uint64
+		for _, b := range v {
+			half = half<<8 | uint64(b)
+		}
+		a = a<<32 | half
+	}
+	return radio.Addr(a), nil
+}
+
+// RSSI returns the signal strength of the last received packet in dBm.
+func (d *XBee) RSSI() (int, error) {
+	v, err := d.AT("DB", nil)
+	if err != nil {
+		return 0, err
+	}
+	if len(v) == 0 {
+		return 0, fmt.Errorf("xbee: empty DB response")
+	}
+	return -int(v[len(v)-1]), nil
+}
+
+// Close implements radio.Radio. Pending requests fail, and the serial port
+// is closed if it implements io.Closer.
+func (d *XBee) Close() error {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+	health.Default.Remove(d.healthName())
+
+	if c, ok := d.Port.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
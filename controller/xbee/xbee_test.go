@@ -0,0 +1,135 @@
+package xbee
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/kidoman/embd/health"
+	"github.com/kidoman/embd/interface/radio"
+)
+
+var _ radio.Radio = &XBee{}
+
+func TestEncode(t *testing.T) {
+	// The AT command example from the manual: AT NJ, frame id 0x52.
+	got := encode([]byte{0x08, 0x52, 'N', 'J'}, false)
+	want := []byte{0x7E, 0x00, 0x04, 0x08, 0x52, 0x4E, 0x4A, 0x0D}
+	if !bytes.Equal(got, want) {
+		t.Errorf("frame = % x; want % x", got, want)
+	}
+
+	got = encode([]byte{0x23, 0x11}, true)
+	want = []byte{0x7E, 0x00, 0x02, 0x23, 0x7D, 0x31, 0xCB}
+	if !bytes.Equal(got, want) {
+		t.Errorf("escaped frame = % x; want % x", got, want)
+	}
+
+	for _, escaped := range []bool{false, true} {
+		data := []byte{0x90, 0x7E, 0x7D, 0x11, 0x13, 0x00}
+		r := newFrameReader(bytes.NewReader(append([]byte{0x00, 0x13}, encode(data, escaped)...)), escaped)
+		got, err := r.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("decoded % x; want % x", got, data)
+		}
+	}
+}
+
+type port struct {
+	io.Reader
+	io.Writer
+}
+
+// fakeModule answers AT DB with -40 dBm, and delivers transmit requests to
+// anything but address 0xBAD.
+func fakeModule() (*XBee, *io.PipeWriter) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	go func() {
+		r := newFrameReader(inR, true)
+		for {
+			data, err := r.read()
+			if err != nil {
+				return
+			}
+			var resp []byte
+			switch data[0] {
+			case atCommand:
+				resp = []byte{atResponse, data[1], data[2], data[3], 0, 40}
+			case transmitRequest:
+				status := byte(0)
+				if binary.BigEndian.Uint64(data[2:10]) == 0xBAD {
+					status = 0x24
+				}
+				resp = []byte{transmitStatus, data[1], 0xFF, 0xFE, 0, status, 0}
+			}
+			outW.Write(encode(resp, true))
+		}
+	}()
+
+	d := New(port{outR, inW})
+	d.Escaped = true
+	return d, outW
+}
+
+func TestSend(t *testing.T) {
+	d, _ := fakeModule()
+	if err := d.Send(0x0013A20040A1B2C3, []byte("t=21.5")); err != nil {
+		t.Fatal(err)
+	}
+	err := d.Send(0xBAD, []byte("t=21.5"))
+	if e, ok := err.(*DeliveryError); !ok || e.Status != 0x24 {
+		t.Errorf("err = %v; want address not found", err)
+	}
+	if err := d.Send(Broadcast, make([]byte, maxPayload+1)); err == nil {
+		t.Error("no error for a payload too large")
+	}
+}
+
+func TestReceive(t *testing.T) {
+	d, module := fakeModule()
+	d.setup()
+
+	// Line noise looking like an empty frame is skipped.
+	module.Write([]byte{startDelimiter, 0x00, 0x00})
+	frame := []byte{receivePacket, 0x00, 0x13, 0xA2, 0x00, 0x40, 0xA1, 0xB2, 0xC3, 0x12, 0x34, 0x01}
+	module.Write(encode(append(frame, "hello"...), true))
+
+	dg, err := d.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dg.From != 0x0013A20040A1B2C3 || string(dg.Payload) != "hello" || dg.RSSI != -40 {
+		t.Errorf("datagram = %+v", dg)
+	}
+	if s := status(d.healthName()); s != health.OK {
+		t.Errorf("status = %v after a frame; want ok", s)
+	}
+
+	module.Close()
+	if _, err := d.Receive(); err != io.EOF {
+		t.Errorf("err = %v; want EOF", err)
+	}
+	if s := status(d.healthName()); s != health.Failed {
+		t.Errorf("status = %v after losing the port; want failed", s)
+	}
+	d.Close()
+	if s := status(d.healthName()); s != -1 {
+		t.Errorf("status = %v after Close; want the module removed", s)
+	}
+}
+
+// status returns the status of a driver in the health registry, or -1.
+func status(name string) health.Status {
+	for _, rep := range health.Default.Reports() {
+		if rep.Name == name {
+			return rep.Status
+		}
+	}
+	return -1
+}
@@ -0,0 +1,378 @@
+/*
+Package cooling drives a fan from a temperature, typically keeping the SoC
+of a Raspberry Pi 4 cool by reading its thermal zone.
+
+The fan speed follows a curve, with hysteresis so that it does not hunt
+around the points of the curve. A PWM fan follows the curve smoothly, while
+a fan switched by a relay or transistor is either on or off:
+
+	c := cooling.New(thermalzone.New(0), cooling.PWMFan(pwm))
+	c.Curve = cooling.Curve{{50, 0}, {55, 0.3}, {70, 1}}
+	c.Indicator = indicator.NewRegion(lcd.Region(0, 1, 16))
+	c.Run()
+	defer c.Close()
+
+With the tachometer output of the fan connected, the controller also
+reports the actual speed and detects stalled fans:
+
+	c.Tachometer = tachometer.NewFan(tach)
+	for s := range c.Alerts() {
+		if s.Stalled {
+			mail("the fan stopped: " + s.String())
+		}
+	}
+*/
+package cooling
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/interface/indicator"
+	"github.com/kidoman/embd/sensor"
+	"github.com/kidoman/embd/units"
+)
+
+const (
+	pollDelay = 5 * time.Second
+
+	defaultHysteresis = 3
+	defaultCritical   = 80
+
+	defaultStallRPM  = 200
+	defaultStallTime = 15 * time.Second
+
+	alertBuffer = 4
+)
+
+// A Fan is driven at a speed from 0 (stopped) to 1 (full speed).
+type Fan interface {
+	SetSpeed(speed float64) error
+}
+
+// FanFunc adapts a function to the Fan interface.
+type FanFunc func(speed float64) error
+
+// SetSpeed implements Fan.
+func (f FanFunc) SetSpeed(speed float64) error {
+	return f(speed)
+}
+
+// PWMFan returns a fan driven by the duty cycle of a PWM pin, through a
+// transistor or the PWM input of a 4 wire fan.
+func PWMFan(pin embd.PWMPin) Fan {
+	return FanFunc(func(speed float64) error {
+		return pin.SetAnalog(byte(speed*255 + 0.5))
+	})
+}
+
+// RelayFan returns a fan switched on and off by a digital pin, running for
+// any speed above zero.
+func RelayFan(pin embd.DigitalPin) Fan {
+	return FanFunc(func(speed float64) error {
+		if speed > 0 {
+			return pin.Write(embd.High)
+		}
+		return pin.Write(embd.Low)
+	})
+}
+
+// Point is a point of a fan curve.
+type Point struct {
+	Temperature units.Temperature
+	Speed       float64
+}
+
+// Curve maps temperatures to fan speeds. The speed is interpolated linearly
+// between the points, which are sorted by temperature, and is that of the
+// first and last points outside of them. Two points at the same temperature
+// make a step, like the single step of a fan switched by a relay:
+//
+//	cooling.Curve{{60, 0}, {60, 1}}
+type Curve []Point
+
+// DefaultCurve starts the fan slowly at 50°C and runs it at full speed from
+// 70°C, which keeps a Raspberry Pi 4 well below its 80°C throttling point.
+var DefaultCurve = Curve{{50, 0}, {50, 0.3}, {70, 1}}
+
+// Speed returns the fan speed for a temperature.
+func (c Curve) Speed(t units.Temperature) float64 {
+	if len(c) == 0 {
+		return 0
+	}
+	if t < c[0].Temperature {
+		return c[0].Speed
+	}
+	for i := 1; i < len(c); i++ {
+		a, b := c[i-1], c[i]
+		if t >= b.Temperature {
+			continue
+		}
+		return a.Speed + (b.Speed-a.Speed)*float64((t-a.Temperature)/(b.Temperature-a.Temperature))
+	}
+	return c[len(c)-1].Speed
+}
+
+// ParseCurve parses a curve written as comma separated temperature:speed
+// points, with the temperatures in degrees Celsius and the speeds in
+// percents, like "50:0,50:30,70:100".
+func ParseCurve(s string) (Curve, error) {
+	var c Curve
+	for _, field := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(field), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("cooling: invalid curve point %q", field)
+		}
+		t, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("cooling: invalid curve point %q", field)
+		}
+		speed, err := strconv.ParseFloat(strings.TrimSuffix(parts[1], "%"), 64)
+		if err != nil || speed < 0 || speed > 100 {
+			return nil, fmt.Errorf("cooling: invalid curve point %q", field)
+		}
+		c = append(c, Point{units.Temperature(t), speed / 100})
+	}
+	sort.SliceStable(c, func(i, j int) bool {
+		return c[i].Temperature < c[j].Temperature
+	})
+	return c, nil
+}
+
+// State is the state of the cooling.
+type State struct {
+	Time        time.Time
+	Temperature units.Temperature
+	Speed       float64
+
+	// RPM is the measured speed of the fan, when it has a tachometer.
+	RPM float64
+
+	// Stalled is set when the fan does not turn while it should.
+	Stalled bool
+}
+
+func (s State) String() string {
+	if s.Stalled {
+		return fmt.Sprintf("%.1f°C fan stalled", float64(s.Temperature))
+	}
+	return fmt.Sprintf("%.1f°C fan %.0f%%", float64(s.Temperature), s.Speed*100)
+}
+
+// ErrNoReading is returned by State before the first reading.
+var ErrNoReading = errors.New("cooling: no reading yet")
+
+// Controller drives a fan from a temperature.
+type Controller struct {
+	Sensor sensor.Thermometer
+	Fan    Fan
+	Curve  Curve
+
+	// Hysteresis is how far the temperature must fall below the point a
+	// speed was reached at before the fan slows down again.
+	Hysteresis units.Temperature
+
+	// MinSpeed is the speed below which the fan stalls. Lower speeds other
+	// than zero are raised to it.
+	MinSpeed float64
+
+	// Indicator, if set, shows the state at each reading: as info, as a
+	// warning when the fan runs at full speed, and as an error from the
+	// Critical temperature.
+	Indicator indicator.Indicator
+	Critical  units.Temperature
+
+	// Tachometer, if set, measures the actual speed of the fan in RPM,
+	// like a tachometer.Tachometer. The fan is stalled when it turns slower
+	// than StallRPM for StallTime while it should be running.
+	Tachometer sensor.Counter
+	StallRPM   float64
+	StallTime  time.Duration
+
+	Poll time.Duration
+
+	mu       sync.Mutex
+	state    State
+	read     bool
+	lowSince time.Time
+
+	alerts chan State
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// New creates a new Controller following the DefaultCurve.
+func New(s sensor.Thermometer, fan Fan) *Controller {
+	return &Controller{
+		Sensor:     s,
+		Fan:        fan,
+		Curve:      DefaultCurve,
+		Hysteresis: defaultHysteresis,
+		Critical:   defaultCritical,
+		StallRPM:   defaultStallRPM,
+		StallTime:  defaultStallTime,
+		Poll:       pollDelay,
+		alerts:     make(chan State, alertBuffer),
+	}
+}
+
+// speed returns the speed for temperature t when the fan runs at current.
+// The speed follows the curve as the temperature rises, and the curve
+// shifted by the hysteresis as it falls.
+func (c *Controller) speed(t units.Temperature, current float64) float64 {
+	speed := c.Curve.Speed(t)
+	if speed < current {
+		speed = current
+		if lower := c.Curve.Speed(t + c.Hysteresis); lower < current {
+			speed = lower
+		}
+	}
+	if speed > 0 && speed < c.MinSpeed {
+		speed = c.MinSpeed
+	}
+	return speed
+}
+
+// Alerts returns the channel receiving the state when the fan stalls, and
+// when it turns again.
+func (c *Controller) Alerts() <-chan State {
+	return c.alerts
+}
+
+// stalled updates the stall detection with the speed measured at now while
+// the fan was driven at speed, and returns whether the fan is stalled. It
+// must be called with the lock held.
+func (c *Controller) stalled(now time.Time, speed, rpm float64) bool {
+	if speed == 0 || rpm >= c.StallRPM {
+		c.lowSince = time.Time{}
+		return false
+	}
+	if c.lowSince.IsZero() {
+		c.lowSince = now
+	}
+	return now.Sub(c.lowSince) >= c.StallTime
+}
+
+// Update reads the temperature and adjusts the fan speed.
+func (c *Controller) Update() (State, error) {
+	return c.update(time.Now())
+}
+
+func (c *Controller) update(now time.Time) (State, error) {
+	t, err := c.Sensor.Temperature()
+	if err != nil {
+		return State{}, err
+	}
+	var rpm float64
+	if c.Tachometer != nil {
+		if rpm, err = c.Tachometer.Rate(); err != nil {
+			return State{}, err
+		}
+	}
+
+	c.mu.Lock()
+	prev := c.state
+	current := prev.Speed
+	if !c.read {
+		current = 0
+	}
+	speed := c.speed(t, current)
+	changed := !c.read || speed != current
+	stalled := c.Tachometer != nil && c.stalled(now, current, rpm)
+	c.mu.Unlock()
+
+	if changed {
+		glog.V(1).Infof("cooling: %v, setting fan speed to %.2f", t, speed)
+		if err := c.Fan.SetSpeed(speed); err != nil {
+			return State{}, err
+		}
+	}
+
+	s := State{Time: now, Temperature: t, Speed: speed, RPM: rpm, Stalled: stalled}
+	c.mu.Lock()
+	c.state, c.read = s, true
+	c.mu.Unlock()
+
+	if stalled != prev.Stalled {
+		if stalled {
+			glog.Errorf("cooling: fan stalled, %v rpm at speed %.2f", rpm, current)
+		} else {
+			glog.Infof("cooling: fan turning again, %v rpm", rpm)
+		}
+		select {
+		case c.alerts <- s:
+		default:
+			glog.Warningf("cooling: dropping alert, alerts are not being read")
+		}
+	}
+
+	if c.Indicator != nil {
+		level := indicator.Info
+		switch {
+		case stalled || t >= c.Critical:
+			level = indicator.Error
+		case speed >= 1:
+			level = indicator.Warn
+		}
+		if err := indicator.Show(c.Indicator, level, s.String()); err != nil {
+			glog.Errorf("cooling: showing state: %v", err)
+		}
+	}
+
+	return s, nil
+}
+
+// State returns the state at the last reading.
+func (c *Controller) State() (State, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.read {
+		return State{}, ErrNoReading
+	}
+	return c.state, nil
+}
+
+// Run starts reading the temperature and adjusting the fan speed in the
+// background.
+func (c *Controller) Run() {
+	c.quit = make(chan struct{})
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+
+		ticker := time.NewTicker(c.Poll)
+		defer ticker.Stop()
+
+		for {
+			if _, err := c.Update(); err != nil {
+				glog.Errorf("cooling: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-c.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops adjusting the fan speed, and runs the fan at full speed so
+// the temperature stays safe without the controller.
+func (c *Controller) Close() error {
+	if c.quit != nil {
+		close(c.quit)
+		<-c.done
+		c.quit = nil
+	}
+	return c.Fan.SetSpeed(1)
+}
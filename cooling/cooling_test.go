@@ -0,0 +1,151 @@
+package cooling
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/interface/indicator"
+	"github.com/kidoman/embd/units"
+)
+
+type fakeThermometer struct {
+	t units.Temperature
+}
+
+func (f *fakeThermometer) Temperature() (units.Temperature, error) {
+	return f.t, nil
+}
+
+func TestCurve(t *testing.T) {
+	c, err := ParseCurve("70:100, 50:0,50:30%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		t     units.Temperature
+		speed float64
+	}{
+		{20, 0}, {49.9, 0}, {50, 0.3}, {60, 0.65}, {70, 1}, {90, 1},
+	}
+	for _, test := range tests {
+		if speed := c.Speed(test.t); math.Abs(speed-test.speed) > 1e-9 {
+			t.Errorf("Speed(%v) = %v; want %v", test.t, speed, test.speed)
+		}
+	}
+
+	for _, s := range []string{"", "50", "50:x", "50:120"} {
+		if _, err := ParseCurve(s); err == nil {
+			t.Errorf("no error parsing %q", s)
+		}
+	}
+}
+
+func TestController(t *testing.T) {
+	therm := &fakeThermometer{}
+	var speeds []float64
+	fan := FanFunc(func(speed float64) error {
+		speeds = append(speeds, speed)
+		return nil
+	})
+	var levels []indicator.Level
+	ind := indicator.Func(func(level indicator.Level, msg string) error {
+		levels = append(levels, level)
+		return nil
+	})
+
+	c := New(therm, fan)
+	c.Curve = Curve{{60, 0}, {60, 1}}
+	c.Indicator = ind
+
+	if _, err := c.State(); err != ErrNoReading {
+		t.Errorf("State() error = %v; want ErrNoReading", err)
+	}
+
+	// The fan starts at 60°C and only stops once it is 3°C cooler.
+	for _, temp := range []units.Temperature{50, 59, 61, 58, 57.5, 56, 59} {
+		therm.t = temp
+		if _, err := c.Update(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(speeds) != 3 || speeds[0] != 0 || speeds[1] != 1 || speeds[2] != 0 {
+		t.Errorf("fan speeds = %v; want [0 1 0]", speeds)
+	}
+	if levels[1] != indicator.Info || levels[2] != indicator.Warn {
+		t.Errorf("levels = %v", levels)
+	}
+
+	therm.t = 85
+	s, err := c.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Speed != 1 || levels[len(levels)-1] != indicator.Error {
+		t.Errorf("critical state %v shown as %v", s, levels[len(levels)-1])
+	}
+}
+
+func TestMinSpeed(t *testing.T) {
+	c := New(&fakeThermometer{}, nil)
+	c.Curve = Curve{{40, 0}, {60, 1}}
+	c.MinSpeed = 0.25
+	if speed := c.speed(42, 0); speed != 0.25 {
+		t.Errorf("speed = %v; want 0.25", speed)
+	}
+	if speed := c.speed(39, 0); speed != 0 {
+		t.Errorf("speed = %v; want 0", speed)
+	}
+}
+
+type fakeTachometer struct {
+	rpm float64
+}
+
+func (f *fakeTachometer) Rate() (float64, error)  { return f.rpm, nil }
+func (f *fakeTachometer) Total() (float64, error) { return 0, nil }
+
+func TestStall(t *testing.T) {
+	therm := &fakeThermometer{t: 65}
+	tach := &fakeTachometer{}
+	c := New(therm, FanFunc(func(float64) error { return nil }))
+	c.Tachometer = tach
+
+	now := time.Now()
+	update := func(d time.Duration) State {
+		s, err := c.update(now.Add(d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	// The fan is not turning during the first interval, but was stopped.
+	update(0)
+	tach.rpm = 1500
+	if s := update(5 * time.Second); s.Stalled || s.RPM != 1500 {
+		t.Errorf("state %+v; want turning at 1500 rpm", s)
+	}
+
+	tach.rpm = 0
+	if s := update(10 * time.Second); s.Stalled {
+		t.Error("stalled before StallTime")
+	}
+	select {
+	case s := <-c.Alerts():
+		t.Fatalf("unexpected alert %v", s)
+	default:
+	}
+	if s := update(25 * time.Second); !s.Stalled {
+		t.Error("not stalled after StallTime")
+	}
+	if s := <-c.Alerts(); !s.Stalled || s.String() != "65.0°C fan stalled" {
+		t.Errorf("alert %v", s)
+	}
+
+	tach.rpm = 1400
+	update(30 * time.Second)
+	if s := <-c.Alerts(); s.Stalled {
+		t.Errorf("alert %v; want turning", s)
+	}
+}
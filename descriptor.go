@@ -5,6 +5,8 @@ package embd
 import (
 	"errors"
 	"fmt"
+	"os"
+	"strconv"
 
 	"github.com/golang/glog"
 )
@@ -15,6 +17,9 @@ type Descriptor struct {
 	I2CDriver  func() I2CDriver
 	LEDDriver  func() LEDDriver
 	SPIDriver  func() SPIDriver
+
+	// Ports are the connector ports of the host, like its Grove ports.
+	Ports []Port
 }
 
 // The Describer type is a Descriptor provider.
@@ -50,13 +55,40 @@ func SetHost(host Host, rev int) {
 	hostOverriden = true
 }
 
+// DryRunEnv is the environment variable which enables dry run mode when set
+// to a true value (like 1 or true).
+const DryRunEnv = "EMBD_DRY_RUN"
+
+var dryRun bool
+
+// SetDryRun enables or disables dry run mode. In dry run mode the HostDryRun
+// host (registered by the host/dryrun package) is used regardless of the
+// actual host: bus and pin writes are logged but not executed, and reads
+// return zeros. This allows running programs on machines without the
+// hardware. It must be called before initializing any driver.
+func SetDryRun(b bool) {
+	dryRun = b
+}
+
+// DryRun returns true if dry run mode is enabled, either by SetDryRun or by
+// the DryRunEnv environment variable.
+func DryRun() bool {
+	if dryRun {
+		return true
+	}
+	b, _ := strconv.ParseBool(os.Getenv(DryRunEnv))
+	return b
+}
+
 // DescribeHost returns the detected host descriptor.
-// Can be overriden by calling SetHost though.
+// Can be overriden by calling SetHost or SetDryRun though.
 func DescribeHost() (*Descriptor, error) {
 	var host Host
 	var rev int
 
-	if hostOverriden {
+	if DryRun() {
+		host = HostDryRun
+	} else if hostOverriden {
 		host, rev = hostOverride, hostRevOverride
 	} else {
 		var err error
@@ -68,7 +100,7 @@ func DescribeHost() (*Descriptor, error) {
 
 	describer, ok := describers[host]
 	if !ok {
-		return nil, fmt.Errorf("host: invalid host %q", host)
+		return nil, fmt.Errorf("host: invalid host %q. %v", host, supportedHostsMessage())
 	}
 
 	return describer(rev), nil
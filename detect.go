@@ -3,9 +3,13 @@
 package embd
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"os/exec"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -31,6 +35,10 @@ const (
 
 	// HostRadxa represents the Radxa board.
 	HostRadxa = "Radxa"
+
+	// HostDryRun represents a host without hardware, where bus writes are
+	// only logged. See SetDryRun.
+	HostDryRun = "Dry Run"
 )
 
 func execOutput(name string, arg ...string) (output string, err error) {
@@ -77,7 +85,70 @@ func kernelVersion() (major, minor, patch int, err error) {
 	return parseVersion(output)
 }
 
+// deviceTreePath is where the kernel exposes the device tree of the board.
+var deviceTreePath = "/proc/device-tree"
+
+// readDeviceTree reads a property of the device tree, made of NUL terminated
+// strings.
+func readDeviceTree(name string) ([]string, error) {
+	data, err := ioutil.ReadFile(path.Join(deviceTreePath, name))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(data), "\x00"), "\x00"), nil
+}
+
+// compatibleHosts maps the prefixes of the device tree compatible strings to
+// the hosts.
+var compatibleHosts = []struct {
+	prefix string
+	host   Host
+}{
+	{"raspberrypi,", HostRPi},
+	{"brcm,bcm2835", HostRPi},
+	{"brcm,bcm2836", HostRPi},
+	{"brcm,bcm2837", HostRPi},
+	{"brcm,bcm2711", HostRPi},
+	{"brcm,bcm2712", HostRPi},
+	{"ti,am335x-bone", HostBBB},
+}
+
+// matchDeviceTree returns the host described by the model and compatible
+// strings of the device tree.
+func matchDeviceTree(model string, compatible []string) (Host, bool) {
+	for _, c := range compatible {
+		for _, ch := range compatibleHosts {
+			if strings.HasPrefix(c, ch.prefix) {
+				return ch.host, true
+			}
+		}
+	}
+	switch {
+	case strings.HasPrefix(model, "Raspberry Pi"):
+		return HostRPi, true
+	case strings.Contains(model, "BeagleBone"):
+		return HostBBB, true
+	}
+	return HostNull, false
+}
+
+// parsePiRevision parses a Raspberry Pi revision code. The old style codes
+// of the first boards are small numbers (below 4 for the model B rev 1),
+// while the new style codes of the later boards have bit 23 set, so they
+// compare as more recent than all the old style ones.
+func parsePiRevision(s string) (int, error) {
+	rev, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 32)
+	if err != nil {
+		return 0, err
+	}
+	// Bit 24 flags overvolted boards.
+	return int(rev &^ (1 << 24)), nil
+}
+
 func getPiRevision() (int, error) {
+	if data, err := ioutil.ReadFile(path.Join(deviceTreePath, "system/linux,revision")); err == nil && len(data) == 4 {
+		return int(binary.BigEndian.Uint32(data) &^ (1 << 24)), nil
+	}
 	//default return code of a rev2 board
 	cpuinfo, err := ioutil.ReadFile("/proc/cpuinfo")
 	if err != nil {
@@ -85,16 +156,78 @@ func getPiRevision() (int, error) {
 	}
 	for _, line := range strings.Split(string(cpuinfo), "\n") {
 		fields := strings.Fields(line)
-		if len(fields) > 0 && fields[0] == "Revision" {
-			rev, err := strconv.ParseInt(fields[2], 16, 8)
-			return int(rev), err
+		if len(fields) > 2 && fields[0] == "Revision" {
+			return parsePiRevision(fields[2])
 		}
 	}
 	return 4, nil
 }
 
-// DetectHost returns the detected host and its revision number.
+// HostEnv is the environment variable overriding the detected host, with
+// the name of the host optionally followed by a colon and its revision:
+// "rpi", "bbb", "Raspberry Pi:2".
+const HostEnv = "EMBD_HOST"
+
+var hostAliases = map[string]Host{
+	"rpi":    HostRPi,
+	"bbb":    HostBBB,
+	"dryrun": HostDryRun,
+}
+
+// parseHost parses the value of HostEnv.
+func parseHost(s string) (Host, int, error) {
+	name, rev := s, 0
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		var err error
+		if rev, err = strconv.Atoi(s[i+1:]); err != nil {
+			return HostNull, 0, fmt.Errorf("embd: invalid revision in %v=%q", HostEnv, s)
+		}
+		name = s[:i]
+	}
+	if host, ok := hostAliases[strings.ToLower(name)]; ok {
+		return host, rev, nil
+	}
+	for _, host := range supportedHosts() {
+		if strings.EqualFold(name, string(host)) {
+			return host, rev, nil
+		}
+	}
+	return HostNull, 0, fmt.Errorf("embd: unknown host %v=%q. %v", HostEnv, s, supportedHostsMessage())
+}
+
+// supportedHosts returns the registered hosts, sorted.
+func supportedHosts() []Host {
+	var hosts []Host
+	for host := range describers {
+		if host != HostDryRun {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i] < hosts[j] })
+	return hosts
+}
+
+func supportedHostsMessage() string {
+	hosts := supportedHosts()
+	if len(hosts) == 0 {
+		return "no hosts are registered, import github.com/kidoman/embd/host/all"
+	}
+	names := make([]string, len(hosts))
+	for i, host := range hosts {
+		names[i] = string(host)
+	}
+	return "supported hosts are: " + strings.Join(names, ", ")
+}
+
+// DetectHost returns the detected host and its revision number. The host is
+// identified from the device tree, then by the registered host providers,
+// falling back on the node name for kernels without a device tree. The
+// detection can be overridden with the HostEnv environment variable.
 func DetectHost() (Host, int, error) {
+	if env := os.Getenv(HostEnv); env != "" {
+		return parseHost(env)
+	}
+
 	major, minor, patch, err := kernelVersion()
 	if err != nil {
 		return HostNull, 0, err
@@ -104,22 +237,37 @@ func DetectHost() (Host, int, error) {
 		return HostNull, 0, fmt.Errorf("embd: linux kernel versions lower than 3.8 are not supported. you have %v.%v.%v", major, minor, patch)
 	}
 
-	node, err := nodeName()
-	if err != nil {
-		return HostNull, 0, err
+	var model string
+	if m, err := readDeviceTree("model"); err == nil {
+		model = m[0]
 	}
+	compatible, _ := readDeviceTree("compatible")
 
-	var host Host
-	var rev int
+	host, ok := matchDeviceTree(model, compatible)
+	if !ok {
+		if host, rev, ok := detectProvidedHost(); ok {
+			return host, rev, nil
+		}
+		node, err := nodeName()
+		if err != nil {
+			return HostNull, 0, err
+		}
+		switch node {
+		case "raspberrypi":
+			host = HostRPi
+		case "beaglebone":
+			host = HostBBB
+		default:
+			if model == "" {
+				model = node
+			}
+			return HostNull, 0, fmt.Errorf("embd: your host %q is not supported at this moment. %v, or set %v to override the detection. please request support at https://github.com/kidoman/embd/issues", model, supportedHostsMessage(), HostEnv)
+		}
+	}
 
-	switch node {
-	case "raspberrypi":
-		host = HostRPi
+	var rev int
+	if host == HostRPi {
 		rev, _ = getPiRevision()
-	case "beaglebone":
-		host = HostBBB
-	default:
-		return HostNull, 0, fmt.Errorf("embd: your host %q is not supported at this moment. please request support at https://github.com/kidoman/embd/issues", node)
 	}
 
 	return host, rev, nil
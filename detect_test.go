@@ -1,6 +1,9 @@
 package embd
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestKernelVersionParse(t *testing.T) {
 	var tests = []struct {
@@ -27,3 +30,78 @@ func TestKernelVersionParse(t *testing.T) {
 		}
 	}
 }
+
+func TestMatchDeviceTree(t *testing.T) {
+	var tests = []struct {
+		model      string
+		compatible []string
+		host       Host
+		ok         bool
+	}{
+		{"Raspberry Pi 4 Model B Rev 1.4", []string{"raspberrypi,4-model-b", "brcm,bcm2711"}, HostRPi, true},
+		{"Raspberry Pi Model B Rev 2", []string{"brcm,bcm2835"}, HostRPi, true},
+		{"TI AM335x BeagleBone Black", []string{"ti,am335x-bone-black", "ti,am335x-bone", "ti,am33xx"}, HostBBB, true},
+		{"Raspberry Pi 5 Model B Rev 1.0", nil, HostRPi, true},
+		{"Hardkernel ODROID-C2", []string{"hardkernel,odroid-c2", "amlogic,meson-gxbb"}, HostNull, false},
+	}
+	for _, test := range tests {
+		host, ok := matchDeviceTree(test.model, test.compatible)
+		if host != test.host || ok != test.ok {
+			t.Errorf("matchDeviceTree(%q, %q) = %q, %v; want %q, %v", test.model, test.compatible, host, ok, test.host, test.ok)
+		}
+	}
+}
+
+func TestParsePiRevision(t *testing.T) {
+	var tests = []struct {
+		code  string
+		rev   int
+		rev1B bool
+	}{
+		{"0002", 2, true},
+		{"1000003", 3, true},
+		{"000e", 0xe, false},
+		{"c03114", 0xc03114, false},
+	}
+	for _, test := range tests {
+		rev, err := parsePiRevision(test.code)
+		if err != nil {
+			t.Errorf("parsePiRevision(%q): %v", test.code, err)
+			continue
+		}
+		if rev != test.rev || (rev < 4) != test.rev1B {
+			t.Errorf("parsePiRevision(%q) = %#x; want %#x", test.code, rev, test.rev)
+		}
+	}
+}
+
+func TestHostEnv(t *testing.T) {
+	Register("Test Board", func(rev int) *Descriptor { return &Descriptor{} })
+	defer delete(describers, "Test Board")
+
+	var tests = []struct {
+		env  string
+		host Host
+		rev  int
+	}{
+		{"rpi", HostRPi, 0},
+		{"RPI:2", HostRPi, 2},
+		{"test board", "Test Board", 0},
+		{"Test Board:5", "Test Board", 5},
+	}
+	for _, test := range tests {
+		host, rev, err := parseHost(test.env)
+		if err != nil {
+			t.Errorf("parseHost(%q): %v", test.env, err)
+			continue
+		}
+		if host != test.host || rev != test.rev {
+			t.Errorf("parseHost(%q) = %q, %v; want %q, %v", test.env, host, rev, test.host, test.rev)
+		}
+	}
+
+	_, _, err := parseHost("odroid")
+	if err == nil || !strings.Contains(err.Error(), "supported hosts are: Test Board") {
+		t.Errorf("parseHost(odroid) error = %v", err)
+	}
+}
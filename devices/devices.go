@@ -0,0 +1,292 @@
+/*
+Package devices opens the buses and displays of a configuration file with
+the providers registered with embd.RegisterProvider, and reloads the file
+while running, for long running gateways:
+
+	devices:
+	  - name: lcd
+	    kind: display
+	    provider: hd44780
+	    params:
+	      bus: 1
+	      addr: 0x27
+	      cols: 16
+
+A reload compares the devices with the running ones by name: the removed and
+changed devices are closed, and the added and changed ones opened, leaving
+the others running. The users of a device bind to its name, to be handed
+the device again whenever it is reopened:
+
+	r := devices.New()
+	r.Bind("lcd", func(dev interface{}) {
+		c, _ := dev.(characterdisplay.Controller)
+		layout.SetController(c) // nil when the device was removed
+	})
+	if _, err := r.Reload("/etc/embd/devices.yaml"); err != nil {
+		glog.Error(err)
+	}
+	stop := r.ReloadOnSignal("/etc/embd/devices.yaml")
+	defer stop()
+	defer r.Close()
+*/
+package devices
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the device graph of a configuration file.
+type Config struct {
+	Devices []DeviceConfig `yaml:"devices"`
+}
+
+// DeviceConfig is a device opened with a provider.
+type DeviceConfig struct {
+	Name     string            `yaml:"name"`
+	Kind     embd.ProviderKind `yaml:"kind"`
+	Provider string            `yaml:"provider"`
+	Params   embd.Params       `yaml:"params"`
+}
+
+// LoadConfig reads a YAML configuration file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig parses a YAML configuration.
+func ParseConfig(data []byte) (*Config, error) {
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("devices: %v", err)
+	}
+	names := map[string]bool{}
+	for _, dc := range c.Devices {
+		if dc.Name == "" {
+			return nil, fmt.Errorf("devices: %v device without a name", dc.Provider)
+		}
+		if names[dc.Name] {
+			return nil, fmt.Errorf("devices: device %v configured twice", dc.Name)
+		}
+		names[dc.Name] = true
+	}
+	return c, nil
+}
+
+// Changes are the names of the devices changed by a reload.
+type Changes struct {
+	Added, Removed, Changed []string
+}
+
+type device struct {
+	config DeviceConfig
+	dev    interface{}
+}
+
+// Registry keeps the devices of a configuration open.
+type Registry struct {
+	// Open opens the devices, embd.Open by default.
+	Open func(kind embd.ProviderKind, provider string, params embd.Params) (interface{}, error)
+
+	mu      sync.Mutex
+	devices map[string]*device
+	binds   map[string][]func(dev interface{})
+}
+
+// New creates a new empty registry.
+func New() *Registry {
+	return &Registry{
+		Open:    embd.Open,
+		devices: map[string]*device{},
+		binds:   map[string][]func(dev interface{}){},
+	}
+}
+
+// Get returns the device opened under name.
+func (r *Registry) Get(name string) (interface{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.devices[name]
+	if !ok {
+		return nil, false
+	}
+	return d.dev, true
+}
+
+// Names returns the sorted names of the open devices.
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.devices))
+	for name := range r.devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Bind calls fn with the device opened under name, now if it is open and
+// whenever it is opened again. fn is called with nil before the device is
+// closed.
+func (r *Registry) Bind(name string, fn func(dev interface{})) {
+	r.mu.Lock()
+	r.binds[name] = append(r.binds[name], fn)
+	d, ok := r.devices[name]
+	r.mu.Unlock()
+
+	if ok {
+		fn(d.dev)
+	}
+}
+
+func (r *Registry) notify(name string, dev interface{}) {
+	r.mu.Lock()
+	binds := r.binds[name]
+	r.mu.Unlock()
+
+	for _, fn := range binds {
+		fn(dev)
+	}
+}
+
+func (r *Registry) close(name string, d *device) error {
+	r.notify(name, nil)
+	glog.V(1).Infof("devices: closing %v", name)
+	if c, ok := d.dev.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			return fmt.Errorf("devices: closing %v: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Load opens the devices of the configuration, and closes the running ones
+// which were removed from it or changed. The devices which fail to open
+// are left out, and the errors joined.
+func (r *Registry) Load(c *Config) (Changes, error) {
+	var changes Changes
+	wanted := map[string]DeviceConfig{}
+	for _, dc := range c.Devices {
+		wanted[dc.Name] = dc
+	}
+
+	r.mu.Lock()
+	var closing []string
+	for name, d := range r.devices {
+		dc, ok := wanted[name]
+		switch {
+		case !ok:
+			changes.Removed = append(changes.Removed, name)
+		case !reflect.DeepEqual(dc, d.config):
+			changes.Changed = append(changes.Changed, name)
+		default:
+			continue
+		}
+		closing = append(closing, name)
+	}
+	var opening []DeviceConfig
+	for _, dc := range c.Devices {
+		d, ok := r.devices[dc.Name]
+		if !ok {
+			changes.Added = append(changes.Added, dc.Name)
+		}
+		if !ok || !reflect.DeepEqual(dc, d.config) {
+			opening = append(opening, dc)
+		}
+	}
+	old := map[string]*device{}
+	for _, name := range closing {
+		old[name] = r.devices[name]
+		delete(r.devices, name)
+	}
+	r.mu.Unlock()
+
+	var errs []error
+	sort.Strings(closing)
+	for _, name := range closing {
+		if err := r.close(name, old[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, dc := range opening {
+		glog.V(1).Infof("devices: opening %v with %v provider %v", dc.Name, dc.Kind, dc.Provider)
+		dev, err := r.Open(dc.Kind, dc.Provider, dc.Params)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("devices: opening %v: %v", dc.Name, err))
+			continue
+		}
+		r.mu.Lock()
+		r.devices[dc.Name] = &device{config: dc, dev: dev}
+		r.mu.Unlock()
+		r.notify(dc.Name, dev)
+	}
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Changed)
+	return changes, errors.Join(errs...)
+}
+
+// Reload loads the configuration file.
+func (r *Registry) Reload(path string) (Changes, error) {
+	c, err := LoadConfig(path)
+	if err != nil {
+		return Changes{}, err
+	}
+	return r.Load(c)
+}
+
+// ReloadOnSignal reloads the configuration file whenever the process
+// receives one of the signals, SIGHUP by default, until stop is called.
+func (r *Registry) ReloadOnSignal(path string, sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	quit := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ch:
+				changes, err := r.Reload(path)
+				if err != nil {
+					glog.Errorf("devices: reloading %v: %v", path, err)
+				}
+				glog.Infof("devices: reloaded %v: %+v", path, changes)
+			case <-quit:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(quit)
+		<-done
+	}
+}
+
+// Close closes all the devices.
+func (r *Registry) Close() error {
+	_, err := r.Load(&Config{})
+	return err
+}
@@ -0,0 +1,95 @@
+package devices
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+type fakeDevice struct {
+	params embd.Params
+	closed bool
+}
+
+func (d *fakeDevice) Close() error {
+	d.closed = true
+	return nil
+}
+
+func parse(t *testing.T, s string) *Config {
+	c, err := ParseConfig([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestParseConfig(t *testing.T) {
+	c := parse(t, `
+devices:
+  - name: lcd
+    kind: display
+    provider: hd44780
+    params:
+      bus: 1
+      addr: 0x27
+`)
+	want := DeviceConfig{Name: "lcd", Kind: embd.DisplayProviders, Provider: "hd44780", Params: embd.Params{"bus": "1", "addr": "0x27"}}
+	if len(c.Devices) != 1 || !reflect.DeepEqual(c.Devices[0], want) {
+		t.Errorf("got %+v, want %+v", c.Devices, want)
+	}
+	if _, err := ParseConfig([]byte("devices: [{name: a}, {name: a}]")); err == nil {
+		t.Error("parsed a device configured twice")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	r := New()
+	r.Open = func(kind embd.ProviderKind, provider string, params embd.Params) (interface{}, error) {
+		return &fakeDevice{params: params}, nil
+	}
+	var bound []interface{}
+	r.Bind("lcd", func(dev interface{}) { bound = append(bound, dev) })
+
+	_, err := r.Load(parse(t, `
+devices:
+  - {name: lcd, kind: display, provider: hd44780, params: {addr: 0x27}}
+  - {name: bus, kind: bus, provider: loopback}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lcd, _ := r.Get("lcd")
+	bus, _ := r.Get("bus")
+
+	changes, err := r.Load(parse(t, `
+devices:
+  - {name: lcd, kind: display, provider: hd44780, params: {addr: 0x3f}}
+  - {name: temp, kind: bus, provider: loopback}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Changes{Added: []string{"temp"}, Removed: []string{"bus"}, Changed: []string{"lcd"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got changes %+v, want %+v", changes, want)
+	}
+	if !lcd.(*fakeDevice).closed || !bus.(*fakeDevice).closed {
+		t.Error("changed and removed devices not closed")
+	}
+	if got := r.Names(); !reflect.DeepEqual(got, []string{"lcd", "temp"}) {
+		t.Errorf("got devices %v, want [lcd temp]", got)
+	}
+	newLCD, _ := r.Get("lcd")
+	if !reflect.DeepEqual(bound, []interface{}{lcd, nil, newLCD}) {
+		t.Errorf("got bindings %v, want the lcd, nil and the new lcd", bound)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !newLCD.(*fakeDevice).closed || len(r.Names()) != 0 {
+		t.Error("devices not closed")
+	}
+}
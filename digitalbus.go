@@ -0,0 +1,68 @@
+// Digital bus support.
+
+package embd
+
+import "fmt"
+
+// A DigitalBus writes a group of digital pins together, like the data lines
+// of a parallel bus.
+type DigitalBus interface {
+	// Write sets each pin of the bus to the matching bit of bits, the
+	// first pin to the least significant bit.
+	Write(bits uint32) error
+}
+
+// A DigitalBusDriver is a GPIO driver which can write several of its pins in
+// a single operation, without skew between them. It is the extension point
+// of the drivers with such an operation, like a gpiod line request or a
+// register of a memory mapped backend. Of the hosts in this tree, only
+// host/dryrun implements it, logging the pins as one write: the generic
+// sysfs driver of the Raspberry Pi and the BeagleBone writes a file per pin,
+// so their buses write the pins one by one.
+type DigitalBusDriver interface {
+	// DigitalBus returns a bus writing the pins at once, or an error when
+	// the pins cannot be written together.
+	DigitalBus(pins []DigitalPin) (DigitalBus, error)
+}
+
+// NewDigitalBus returns a bus writing the pins. The pins are written in a
+// single operation when the GPIO driver of the host is a DigitalBusDriver,
+// and one by one, with skew between them, otherwise.
+func NewDigitalBus(pins ...DigitalPin) DigitalBus {
+	if drv, ok := gpioDriverInstance.(DigitalBusDriver); ok && gpioDriverInitialized {
+		if bus, err := drv.DigitalBus(pins); err == nil {
+			return bus
+		}
+	}
+	return pinBus(pins)
+}
+
+// pinBus writes the pins one by one.
+type pinBus []DigitalPin
+
+func (b pinBus) Write(bits uint32) error {
+	if len(b) > 32 {
+		return fmt.Errorf("embd: digital bus of %v pins is wider than 32 bits", len(b))
+	}
+	for i, pin := range b {
+		if err := pin.Write(int(bits>>uint(i)) & 0x01); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDigitalPins writes the values to the pins, in a single operation when
+// the GPIO driver of the host is a DigitalBusDriver.
+func WriteDigitalPins(pins []DigitalPin, vals []int) error {
+	if len(pins) != len(vals) {
+		return fmt.Errorf("embd: writing %v values to %v pins", len(vals), len(pins))
+	}
+	var bits uint32
+	for i, v := range vals {
+		if v == High {
+			bits |= 1 << uint(i)
+		}
+	}
+	return NewDigitalBus(pins...).Write(bits)
+}
@@ -0,0 +1,37 @@
+package embd
+
+import (
+	"reflect"
+	"testing"
+)
+
+type recordingPin struct {
+	fakeDigitalPin
+	vals []int
+}
+
+func (p *recordingPin) Write(val int) error {
+	p.vals = append(p.vals, val)
+	return nil
+}
+
+func TestWriteDigitalPins(t *testing.T) {
+	pins := []*recordingPin{{}, {}, {}}
+	dps := []DigitalPin{pins[0], pins[1], pins[2]}
+
+	if err := WriteDigitalPins(dps, []int{High, Low, High}); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewDigitalBus(dps...).Write(0x06); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range [][]int{{High, Low}, {Low, High}, {High, High}} {
+		if !reflect.DeepEqual(pins[i].vals, want) {
+			t.Errorf("pin %v: got writes %v, want %v", i, pins[i].vals, want)
+		}
+	}
+
+	if err := WriteDigitalPins(dps, []int{High}); err == nil {
+		t.Error("WriteDigitalPins with fewer values than pins: got no error")
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/codegangsta/cli"
 	"github.com/kidoman/embd"
@@ -11,8 +10,7 @@ import (
 func detect(c *cli.Context) {
 	host, rev, err := embd.DetectHost()
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fail(err)
 	}
 	fmt.Printf("detected host %v (rev %v)\n", host, rev)
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/kidoman/embd/health"
+)
+
+func showHealth(c *cli.Context) {
+	resp, err := http.Get(c.String("url"))
+	if err != nil {
+		fail(err)
+	}
+	defer resp.Body.Close()
+
+	var reports []health.Report
+	if err := json.NewDecoder(resp.Body).Decode(&reports); err != nil {
+		fail(err)
+	}
+	worst := health.OK
+	for _, rep := range reports {
+		fmt.Printf("%-20v %-8v", rep.Name, rep.Status)
+		if rep.Error != "" {
+			fmt.Printf(" %v (%v)", rep.Error, rep.ErrorTime.Format("2006-01-02 15:04:05"))
+		}
+		fmt.Println()
+		if rep.Status > worst {
+			worst = rep.Status
+		}
+	}
+	if worst == health.Failed {
+		os.Exit(1)
+	}
+}
+
+var healthCmd = cli.Command{
+	Name:  "health",
+	Usage: "show the status of the drivers of a running program",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "url, u",
+			Value: "http://localhost:8080/health",
+			Usage: "health endpoint of the program",
+		},
+	},
+	Action: showHealth,
+}
+
+func init() {
+	registerCommand(healthCmd)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/codegangsta/cli"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/interface/hotkey"
+)
+
+// startHotkey loads the mappings in a new daemon, and runs it.
+func startHotkey(cfg *hotkey.Config) (*hotkey.Daemon, error) {
+	d := hotkey.New()
+	if err := d.Load(cfg); err != nil {
+		d.Close()
+		return nil, err
+	}
+	if err := d.Run(); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+func runHotkey(c *cli.Context) {
+	path := c.String("config")
+	cfg, err := hotkey.LoadConfig(path)
+	if err != nil {
+		fail(err)
+	}
+
+	if err := embd.InitGPIO(); err != nil {
+		fail(err)
+	}
+	defer embd.CloseGPIO()
+
+	d, err := startHotkey(cfg)
+	if err != nil {
+		fail(err)
+	}
+	defer func() { d.Close() }()
+
+	// SIGHUP reloads the mappings, keeping the previous ones when the new
+	// ones cannot be loaded.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigs {
+		if sig != syscall.SIGHUP {
+			return
+		}
+		next, err := hotkey.LoadConfig(path)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		d.Close()
+		if d, err = startHotkey(next); err != nil {
+			fmt.Println(err)
+			if d, err = startHotkey(cfg); err != nil {
+				fail(err)
+			}
+			continue
+		}
+		cfg = next
+		fmt.Printf("reloaded %v\n", path)
+	}
+}
+
+var hotkeyCmd = cli.Command{
+	Name:  "hotkey",
+	Usage: "run shell commands on button gestures",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "config, c",
+			Value: "/etc/embd/hotkey.yaml",
+			Usage: "configuration file mapping the buttons to commands, reloaded on SIGHUP",
+		},
+	},
+	Action: runHotkey,
+}
+
+func init() {
+	registerCommand(hotkeyCmd)
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/codegangsta/cli"
+	"github.com/kidoman/embd"
 	_ "github.com/kidoman/embd/host/all"
 )
 
@@ -15,6 +17,16 @@ func registerCommand(cmd cli.Command) {
 	commands = append(commands, cmd)
 }
 
+// fail prints the error, with a hint at its cause when it is known, and
+// exits.
+func fail(err error) {
+	fmt.Println(err)
+	if hint := embd.Hint(err); hint != "" {
+		fmt.Println("hint:", hint)
+	}
+	os.Exit(1)
+}
+
 func main() {
 	app := cli.NewApp()
 	app.Name = "embd"
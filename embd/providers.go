@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/codegangsta/cli"
+	"github.com/kidoman/embd"
+	_ "github.com/kidoman/embd/controller/hd44780"
+)
+
+func providers(c *cli.Context) {
+	for _, kind := range []embd.ProviderKind{embd.HostProviders, embd.BusProviders, embd.DisplayProviders} {
+		for _, name := range embd.Providers(kind) {
+			fmt.Printf("%v\t%v\n", kind, name)
+		}
+	}
+}
+
+var providersCmd = cli.Command{
+	Name:   "providers",
+	Usage:  "list the registered driver providers",
+	Action: providers,
+}
+
+func init() {
+	registerCommand(providersCmd)
+}
@@ -0,0 +1,157 @@
+// Error kinds.
+
+package embd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// The kinds of errors of the buses and the drivers. The errors of the
+// backends and the drivers match them with errors.Is, so that callers can
+// handle them the same way whatever the hardware:
+//
+//	if errors.Is(err, embd.ErrNAK) {
+//		// The device did not answer: check the wiring.
+//	}
+var (
+	// ErrTimeout is returned when a device does not answer in time.
+	ErrTimeout = errors.New("embd: timeout")
+	// ErrNAK is returned when a device does not acknowledge a transfer.
+	ErrNAK = errors.New("embd: not acknowledged")
+	// ErrBusBusy is returned when a bus is used by another master or
+	// program, or stuck.
+	ErrBusBusy = errors.New("embd: bus busy")
+	// ErrNotSupported is returned when the host or the device does not
+	// support an operation. It is ErrFeatureNotSupported.
+	ErrNotSupported = ErrFeatureNotSupported
+	// ErrClosed is returned when using a closed bus or device.
+	ErrClosed = errors.New("embd: closed")
+)
+
+type kindError struct {
+	kind error
+	msg  string
+}
+
+// NewError returns an error with the message msg, which matches kind with
+// errors.Is, for the errors of the drivers:
+//
+//	var ErrTimeout = embd.NewError(embd.ErrTimeout, "sdcard: timeout waiting for the card")
+func NewError(kind error, msg string) error {
+	return &kindError{kind: kind, msg: msg}
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+// BusError is an error of a bus transfer, of one of the kinds of errors.
+type BusError struct {
+	// Bus names the bus, like "i2c-1".
+	Bus string
+	// Op is the failed operation.
+	Op string
+	// Kind is the kind of the error, or nil when it is not known.
+	Kind error
+	// Err is the error of the backend, like a syscall.Errno.
+	Err error
+}
+
+// NewBusError returns err as a BusError, with its kind found from the error
+// numbers of the kernel. It returns nil when err is nil.
+func NewBusError(bus, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &BusError{Bus: bus, Op: op, Kind: kindOf(err), Err: err}
+}
+
+func (e *BusError) Error() string {
+	return fmt.Sprintf("%v: %v: %v", e.Bus, e.Op, e.Err)
+}
+
+// Unwrap returns the error of the backend.
+func (e *BusError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the kind of the error.
+func (e *BusError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
+
+// kindOf returns the kind of an error of the kernel.
+func kindOf(err error) error {
+	for _, kind := range []error{ErrTimeout, ErrNAK, ErrBusBusy, ErrNotSupported, ErrClosed} {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	if errors.Is(err, os.ErrClosed) {
+		return ErrClosed
+	}
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return nil
+	}
+	switch errno {
+	case syscall.ETIMEDOUT:
+		return ErrTimeout
+	case syscall.ENXIO, syscall.EREMOTEIO:
+		return ErrNAK
+	case syscall.EBUSY, syscall.EAGAIN:
+		return ErrBusBusy
+	case syscall.EOPNOTSUPP, syscall.ENOTTY:
+		return ErrNotSupported
+	case syscall.EBADF:
+		return ErrClosed
+	}
+	return nil
+}
+
+// Temporary returns whether an operation failing with err may succeed when
+// retried: timeouts, NAKs and busy buses.
+func Temporary(err error) bool {
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrNAK) || errors.Is(err, ErrBusBusy)
+}
+
+// Retry calls op until it succeeds, up to attempts times, sleeping delay
+// between the calls. It stops at the first error which is not Temporary.
+func Retry(attempts int, delay time.Duration, op func() error) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(delay)
+		}
+		if err = op(); err == nil || !Temporary(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// Hint returns advice on fixing the error, for the messages shown to the
+// users, or "" when there is none.
+func Hint(err error) string {
+	switch {
+	case errors.Is(err, ErrNAK):
+		return "the device did not answer: check its wiring and address"
+	case errors.Is(err, ErrTimeout):
+		return "the device did not answer in time: check its power and wiring"
+	case errors.Is(err, ErrBusBusy):
+		return "the bus is in use by another program, or stuck"
+	case errors.Is(err, ErrNotSupported):
+		return "the host or the device does not support the operation"
+	case errors.Is(err, ErrClosed):
+		return "the bus or device was closed"
+	}
+	return ""
+}
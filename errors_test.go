@@ -0,0 +1,59 @@
+package embd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestBusErrorKind(t *testing.T) {
+	var tests = []struct {
+		err  error
+		kind error
+	}{
+		{syscall.EREMOTEIO, ErrNAK},
+		{&os.PathError{Op: "write", Path: "/dev/i2c-1", Err: syscall.ENXIO}, ErrNAK},
+		{syscall.ETIMEDOUT, ErrTimeout},
+		{syscall.EBUSY, ErrBusBusy},
+		{syscall.ENOTTY, ErrNotSupported},
+		{os.ErrClosed, ErrClosed},
+		{NewError(ErrTimeout, "sensor: timed out"), ErrTimeout},
+	}
+	for _, test := range tests {
+		err := NewBusError("i2c-1", "WriteByte", test.err)
+		if !errors.Is(err, test.kind) {
+			t.Errorf("%v: not %v", err, test.kind)
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("%v: does not wrap %v", err, test.err)
+		}
+	}
+	if err := NewBusError("i2c-1", "WriteByte", syscall.EPERM); errors.Is(err, ErrNAK) || Temporary(err) {
+		t.Errorf("%v: has a kind", err)
+	}
+	if NewBusError("i2c-1", "WriteByte", nil) != nil {
+		t.Error("wrapped a nil error")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	calls := 0
+	err := Retry(3, 0, func() error {
+		calls++
+		return NewBusError("i2c-1", "ReadByte", syscall.EREMOTEIO)
+	})
+	if !errors.Is(err, ErrNAK) || calls != 3 {
+		t.Errorf("got %v after %v calls, want a NAK after 3", err, calls)
+	}
+
+	calls = 0
+	err = Retry(3, 0, func() error {
+		calls++
+		return fmt.Errorf("driver: %w", ErrClosed)
+	})
+	if !errors.Is(err, ErrClosed) || calls != 1 {
+		t.Errorf("got %v after %v calls, want ErrClosed after 1", err, calls)
+	}
+}
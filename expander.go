@@ -0,0 +1,89 @@
+// GPIO expander support.
+
+package embd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// An Expander provides digital pins beyond those of the host, like a GPIO
+// expander chip.
+type Expander interface {
+	// DigitalPin returns the pin n of the expander.
+	DigitalPin(n int) (DigitalPin, error)
+}
+
+var expanders = struct {
+	sync.RWMutex
+	m map[string]Expander
+}{m: map[string]Expander{}}
+
+// RegisterExpander makes the pins of an expander available under a name, as
+// "name.n" keys: after RegisterExpander("exp0", e), NewDigitalPin("exp0.12")
+// returns the pin 12 of e. The keys can also be the target of pin aliases.
+func RegisterExpander(name string, e Expander) error {
+	if name == "" || strings.Contains(name, ".") {
+		return fmt.Errorf("embd: invalid expander name %q", name)
+	}
+
+	expanders.Lock()
+	defer expanders.Unlock()
+
+	if _, ok := expanders.m[name]; ok {
+		return fmt.Errorf("embd: expander %v already registered", name)
+	}
+	expanders.m[name] = e
+	return nil
+}
+
+// UnregisterExpander removes the expander registered under name.
+func UnregisterExpander(name string) {
+	expanders.Lock()
+	defer expanders.Unlock()
+
+	delete(expanders.m, name)
+}
+
+// Expanders returns the sorted names of the registered expanders.
+func Expanders() []string {
+	expanders.RLock()
+	defer expanders.RUnlock()
+
+	names := make([]string, 0, len(expanders.m))
+	for name := range expanders.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expanderPin returns the expander pin of key, and whether key is one.
+func expanderPin(key interface{}) (DigitalPin, bool, error) {
+	ks, ok := key.(string)
+	if !ok {
+		return nil, false, nil
+	}
+	ks = resolvePinAlias(ks)
+	i := strings.LastIndex(ks, ".")
+	if i < 0 {
+		return nil, false, nil
+	}
+
+	expanders.RLock()
+	e, ok := expanders.m[ks[:i]]
+	expanders.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+
+	n, err := strconv.Atoi(ks[i+1:])
+	if err != nil {
+		return nil, true, fmt.Errorf("embd: invalid expander pin %q", ks)
+	}
+	pin, err := e.DigitalPin(n)
+	return pin, true, err
+}
@@ -0,0 +1,68 @@
+package embd
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type fakeExpander struct {
+	pins map[int]DigitalPin
+}
+
+func (e *fakeExpander) DigitalPin(n int) (DigitalPin, error) {
+	if n < 0 || n >= 16 {
+		return nil, fmt.Errorf("pin %v out of range", n)
+	}
+	if e.pins[n] == nil {
+		e.pins[n] = &fakeDigitalPin{n: n}
+	}
+	return e.pins[n], nil
+}
+
+func TestExpanderPins(t *testing.T) {
+	e0, e1 := &fakeExpander{pins: map[int]DigitalPin{}}, &fakeExpander{pins: map[int]DigitalPin{}}
+	if err := RegisterExpander("exp0", e0); err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterExpander("exp0")
+	if err := RegisterExpander("exp1", e1); err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterExpander("exp1")
+
+	if err := RegisterExpander("exp0", e1); err == nil {
+		t.Error("no error registering an expander twice")
+	}
+	if err := RegisterExpander("exp.2", e1); err == nil {
+		t.Error("no error registering an expander name with a dot")
+	}
+	if got, want := Expanders(), []string{"exp0", "exp1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expanders: got %v, want %v", got, want)
+	}
+
+	pin, err := NewDigitalPin("exp1.12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin != e1.pins[12] {
+		t.Error("exp1.12 is not the pin 12 of exp1")
+	}
+
+	SetPinAlias("RELAY", "exp0.3")
+	defer delete(pinAliases.m, "RELAY")
+	pin, err = NewDigitalPin("RELAY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin != e0.pins[3] {
+		t.Error("alias RELAY is not the pin 3 of exp0")
+	}
+
+	if _, err := NewDigitalPin("exp0.16"); err == nil {
+		t.Error("no error for a pin out of range")
+	}
+	if _, err := NewDigitalPin("exp0.x"); err == nil {
+		t.Error("no error for an invalid pin")
+	}
+}
@@ -0,0 +1,300 @@
+/*
+Package filter provides filters for smoothing and fusing sensor readings.
+
+Filters are composable stages which can be chained and applied to a stream
+of readings before handing them to consumers:
+
+	f := filter.Chain(
+		filter.NewOutlier(9, 3),
+		filter.NewMedian(5),
+		filter.NewKalman(0.01, 0.5),
+	)
+	smooth := filter.Apply(f, readings)
+*/
+package filter
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// A Filter processes a stream of samples, returning the filtered value for
+// each new sample.
+type Filter interface {
+	Update(x float64) float64
+
+	// Reset forgets the previous samples.
+	Reset()
+}
+
+// Func adapts a stateless function to the Filter interface.
+type Func func(x float64) float64
+
+// Update implements Filter.
+func (f Func) Update(x float64) float64 {
+	return f(x)
+}
+
+// Reset implements Filter.
+func (f Func) Reset() {}
+
+type chain []Filter
+
+// Chain returns a filter passing the samples through fs in order.
+func Chain(fs ...Filter) Filter {
+	return chain(fs)
+}
+
+func (c chain) Update(x float64) float64 {
+	for _, f := range c {
+		x = f.Update(x)
+	}
+	return x
+}
+
+func (c chain) Reset() {
+	for _, f := range c {
+		f.Reset()
+	}
+}
+
+// Apply filters the samples received on in, and closes the returned channel
+// once in is closed.
+func Apply(f Filter, in <-chan float64) <-chan float64 {
+	out := make(chan float64)
+	go func() {
+		defer close(out)
+		for x := range in {
+			out <- f.Update(x)
+		}
+	}()
+	return out
+}
+
+// Kalman is a one dimensional Kalman filter for a value which is expected to
+// stay constant between samples.
+type Kalman struct {
+	// Q is the process noise: how much the value can actually change
+	// between samples.
+	Q float64
+
+	// R is the measurement noise: the variance of the readings.
+	R float64
+
+	x, p float64
+	init bool
+}
+
+// NewKalman creates a new Kalman filter with process noise q and measurement
+// noise r. The ratio of q to r sets the trade-off between smoothing and
+// responsiveness.
+func NewKalman(q, r float64) *Kalman {
+	return &Kalman{Q: q, R: r}
+}
+
+// Update implements Filter.
+func (k *Kalman) Update(z float64) float64 {
+	if !k.init {
+		k.x, k.p, k.init = z, k.R, true
+		return k.x
+	}
+	k.p += k.Q
+	gain := k.p / (k.p + k.R)
+	k.x += gain * (z - k.x)
+	k.p *= 1 - gain
+	return k.x
+}
+
+// Reset implements Filter.
+func (k *Kalman) Reset() {
+	k.init = false
+}
+
+// Complementary fuses an angle integrated from a rate sensor (like a
+// gyroscope), which is precise in the short term but drifts, with an angle
+// measured directly (like the tilt from an accelerometer), which is noisy but
+// does not drift.
+type Complementary struct {
+	// Alpha is the weight of the integrated rate, usually around 0.98.
+	Alpha float64
+
+	angle float64
+	init  bool
+}
+
+// NewComplementary creates a new complementary filter.
+func NewComplementary(alpha float64) *Complementary {
+	return &Complementary{Alpha: alpha}
+}
+
+// Update fuses a rate and a measured angle sampled dt after the previous
+// ones, and returns the estimated angle.
+func (c *Complementary) Update(rate, angle float64, dt time.Duration) float64 {
+	if !c.init {
+		c.angle, c.init = angle, true
+		return c.angle
+	}
+	c.angle = c.Alpha*(c.angle+rate*dt.Seconds()) + (1-c.Alpha)*angle
+	return c.angle
+}
+
+// Angle returns the current estimate.
+func (c *Complementary) Angle() float64 {
+	return c.angle
+}
+
+// Reset forgets the current estimate.
+func (c *Complementary) Reset() {
+	c.init = false
+}
+
+// window holds the last n samples.
+type window struct {
+	buf  []float64
+	next int
+	full bool
+}
+
+func newWindow(n int) window {
+	if n < 1 {
+		n = 1
+	}
+	return window{buf: make([]float64, n)}
+}
+
+func (w *window) add(x float64) {
+	w.buf[w.next] = x
+	w.next++
+	if w.next == len(w.buf) {
+		w.next = 0
+		w.full = true
+	}
+}
+
+func (w *window) samples() []float64 {
+	if w.full {
+		return w.buf
+	}
+	return w.buf[:w.next]
+}
+
+func (w *window) reset() {
+	w.next = 0
+	w.full = false
+}
+
+func median(xs []float64) float64 {
+	sorted := append([]float64(nil), xs...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// MovingAverage averages the last n samples.
+type MovingAverage struct {
+	w   window
+	sum float64
+}
+
+// NewMovingAverage creates a new moving average over n samples.
+func NewMovingAverage(n int) *MovingAverage {
+	return &MovingAverage{w: newWindow(n)}
+}
+
+// Update implements Filter.
+func (m *MovingAverage) Update(x float64) float64 {
+	if m.w.full {
+		m.sum -= m.w.buf[m.w.next]
+	}
+	m.w.add(x)
+	m.sum += x
+	return m.sum / float64(len(m.w.samples()))
+}
+
+// Reset implements Filter.
+func (m *MovingAverage) Reset() {
+	m.w.reset()
+	m.sum = 0
+}
+
+// Median returns the median of the last n samples, removing spikes without
+// smoothing edges.
+type Median struct {
+	w window
+}
+
+// NewMedian creates a new median filter over n samples.
+func NewMedian(n int) *Median {
+	return &Median{w: newWindow(n)}
+}
+
+// Update implements Filter.
+func (m *Median) Update(x float64) float64 {
+	m.w.add(x)
+	return median(m.w.samples())
+}
+
+// Reset implements Filter.
+func (m *Median) Reset() {
+	m.w.reset()
+}
+
+// Outlier rejects samples which are more than K median absolute deviations
+// (or mean deviations, when most samples are equal) away from the median of the last n samples, returning that median instead.
+// Rejected samples still enter the window, so a lasting step change is
+// accepted once it makes up most of it.
+type Outlier struct {
+	K float64
+
+	w        window
+	rejected int
+}
+
+// NewOutlier creates a new outlier rejection filter over n samples.
+func NewOutlier(n int, k float64) *Outlier {
+	return &Outlier{K: k, w: newWindow(n)}
+}
+
+// Update implements Filter.
+func (o *Outlier) Update(x float64) float64 {
+	xs := o.w.samples()
+	// Wait for a few samples before judging.
+	if len(xs) < 3 {
+		o.w.add(x)
+		return x
+	}
+	med := median(xs)
+	devs := make([]float64, len(xs))
+	for i, v := range xs {
+		devs[i] = math.Abs(v - med)
+	}
+	spread := median(devs)
+	if spread == 0 {
+		// Most of the samples are equal, fall back to the mean deviation.
+		for _, d := range devs {
+			spread += d
+		}
+		spread /= float64(len(devs))
+	}
+	o.w.add(x)
+	if math.Abs(x-med) > o.K*spread {
+		o.rejected++
+		return med
+	}
+	return x
+}
+
+// Rejected returns the number of rejected samples.
+func (o *Outlier) Rejected() int {
+	return o.rejected
+}
+
+// Reset implements Filter.
+func (o *Outlier) Reset() {
+	o.w.reset()
+	o.rejected = 0
+}
@@ -0,0 +1,123 @@
+package filter
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func near(a, b, eps float64) bool {
+	return math.Abs(a-b) < eps
+}
+
+func TestMovingAverage(t *testing.T) {
+	f := NewMovingAverage(3)
+	var tests = []struct {
+		x, want float64
+	}{
+		{3, 3},
+		{6, 4.5},
+		{9, 6},
+		{12, 9},
+	}
+	for _, test := range tests {
+		if got := f.Update(test.x); got != test.want {
+			t.Errorf("Update(%v) = %v; want %v", test.x, got, test.want)
+		}
+	}
+	f.Reset()
+	if got := f.Update(1); got != 1 {
+		t.Errorf("Update(1) after Reset = %v; want 1", got)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	f := NewMedian(3)
+	var got []float64
+	for _, x := range []float64{1, 100, 2, 3, 4} {
+		got = append(got, f.Update(x))
+	}
+	want := []float64{1, 50.5, 2, 3, 3}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Update results = %v; want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestKalman(t *testing.T) {
+	f := NewKalman(0.001, 1)
+	var x float64
+	for i := 0; i < 200; i++ {
+		// Alternating noise around 10.
+		noise := 1.0
+		if i%2 == 1 {
+			noise = -1
+		}
+		x = f.Update(10 + noise)
+	}
+	if !near(x, 10, 0.2) {
+		t.Errorf("estimate = %v; want about 10", x)
+	}
+}
+
+func TestComplementary(t *testing.T) {
+	f := NewComplementary(0.98)
+	f.Update(0, 0, 0)
+
+	// A steady rotation of 10°/s with a drifting gyroscope reading 11°/s:
+	// the measured angle keeps the estimate close.
+	dt := 10 * time.Millisecond
+	var angle float64
+	for i := 1; i <= 1000; i++ {
+		angle = f.Update(11, 10*float64(i)*dt.Seconds(), dt)
+	}
+	if !near(angle, 100, 1) {
+		t.Errorf("angle = %v; want about 100", angle)
+	}
+}
+
+func TestOutlier(t *testing.T) {
+	f := NewOutlier(5, 3)
+	for _, x := range []float64{10, 11, 10, 9, 10} {
+		if got := f.Update(x); got != x {
+			t.Errorf("Update(%v) = %v; want it accepted", x, got)
+		}
+	}
+	if got := f.Update(1000); got == 1000 {
+		t.Error("spike was accepted")
+	}
+	if f.Rejected() != 1 {
+		t.Errorf("Rejected() = %v; want 1", f.Rejected())
+	}
+
+	// A lasting step is accepted eventually.
+	var got float64
+	for i := 0; i < 5; i++ {
+		got = f.Update(50)
+	}
+	if got != 50 {
+		t.Errorf("step not accepted, got %v", got)
+	}
+}
+
+func TestChainApply(t *testing.T) {
+	double := Func(func(x float64) float64 { return 2 * x })
+	f := Chain(double, NewMovingAverage(2))
+
+	in := make(chan float64)
+	out := Apply(f, in)
+	go func() {
+		in <- 1
+		in <- 2
+		close(in)
+	}()
+	var got []float64
+	for x := range out {
+		got = append(got, x)
+	}
+	if len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Errorf("got %v; want [2 3]", got)
+	}
+}
@@ -2,7 +2,10 @@
 
 package embd
 
-import "time"
+import (
+	"os"
+	"time"
+)
 
 // The Direction type indicates the direction of a GPIO pin.
 type Direction int
@@ -72,10 +75,58 @@ type DigitalPin interface {
 	// PullDown pulls the pin down.
 	PullDown() error
 
+	// SetDebounce sets how long the pin must be stable for a change to be
+	// reported to the Watch handler, filtering out the bounces of switches
+	// and relays. Zero disables the debouncing.
+	SetDebounce(d time.Duration) error
+
 	// Close releases the resources associated with the pin.
 	Close() error
 }
 
+// The OutputMode type indicates how an output pin drives its line.
+type OutputMode int
+
+const (
+	// PushPull drives the line both high and low (default).
+	PushPull OutputMode = iota
+
+	// OpenDrain only drives the line low, and releases it to be pulled up
+	// otherwise. Several open drain outputs can share a line, like the
+	// interrupt line of a group of I²C devices.
+	OpenDrain
+
+	// OpenSource only drives the line high, and releases it to be pulled
+	// down otherwise.
+	OpenSource
+)
+
+func (m OutputMode) String() string {
+	switch m {
+	case OpenDrain:
+		return "open-drain"
+	case OpenSource:
+		return "open-source"
+	default:
+		return "push-pull"
+	}
+}
+
+// DrivePin implements the output drive configuration of a digital pin, on
+// hosts which support it. Configurations the host does not support return
+// ErrFeatureNotSupported.
+type DrivePin interface {
+	// SetOutputMode sets how the pin drives its line when it is an output.
+	SetOutputMode(mode OutputMode) error
+
+	// SetDriveStrength sets the current the pin can source and sink, in mA.
+	SetDriveStrength(mA int) error
+
+	// SetSlewRate limits the slew rate of the pin when slow is set, which
+	// reduces ringing and interference on long cables.
+	SetSlewRate(slow bool) error
+}
+
 // AnalogPin implements access to a analog IO capable GPIO pin.
 type AnalogPin interface {
 	// N returns the logical GPIO number.
@@ -159,6 +210,13 @@ func InitGPIO() error {
 		return ErrFeatureNotSupported
 	}
 
+	if path := os.Getenv(PinAliasesEnv); path != "" {
+		if err := LoadPinAliases(path); err != nil {
+			return err
+		}
+	}
+	loadLevelShifters()
+
 	gpioDriverInstance = desc.GPIODriver()
 	gpioDriverInitialized = true
 
@@ -171,8 +229,13 @@ func CloseGPIO() error {
 }
 
 // NewDigitalPin returns a DigitalPin interface which allows control over
-// the digital GPIO pin.
+// the digital GPIO pin. Keys like "exp0.12" return the pins of the
+// registered expanders (see RegisterExpander).
 func NewDigitalPin(key interface{}) (DigitalPin, error) {
+	if pin, ok, err := expanderPin(key); ok {
+		return pin, err
+	}
+
 	if err := InitGPIO(); err != nil {
 		return nil, err
 	}
@@ -241,6 +304,60 @@ func PullDown(key interface{}) error {
 	return pin.PullDown()
 }
 
+// SetDebounce sets how long the pin must be stable for a change to be
+// reported to the Watch handler.
+func SetDebounce(key interface{}, d time.Duration) error {
+	pin, err := NewDigitalPin(key)
+	if err != nil {
+		return err
+	}
+
+	return pin.SetDebounce(d)
+}
+
+func drivePin(key interface{}) (DrivePin, error) {
+	pin, err := NewDigitalPin(key)
+	if err != nil {
+		return nil, err
+	}
+
+	dp, ok := pin.(DrivePin)
+	if !ok {
+		return nil, ErrFeatureNotSupported
+	}
+	return dp, nil
+}
+
+// SetOutputMode sets how the pin drives its line when it is an output.
+func SetOutputMode(key interface{}, mode OutputMode) error {
+	pin, err := drivePin(key)
+	if err != nil {
+		return err
+	}
+
+	return pin.SetOutputMode(mode)
+}
+
+// SetDriveStrength sets the current the pin can source and sink, in mA.
+func SetDriveStrength(key interface{}, mA int) error {
+	pin, err := drivePin(key)
+	if err != nil {
+		return err
+	}
+
+	return pin.SetDriveStrength(mA)
+}
+
+// SetSlewRate limits the slew rate of the pin when slow is set.
+func SetSlewRate(key interface{}, slow bool) error {
+	pin, err := drivePin(key)
+	if err != nil {
+		return err
+	}
+
+	return pin.SetSlewRate(slow)
+}
+
 // NewAnalogPin returns a AnalogPin interface which allows control over
 // the analog GPIO pin.
 func NewAnalogPin(key interface{}) (AnalogPin, error) {
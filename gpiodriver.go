@@ -39,10 +39,12 @@ func NewGPIODriver(pinMap PinMap, dpf digitalPinFactory, apf analogPinFactory, p
 }
 
 func (io *gpioDriver) Unregister(id string) error {
-	if _, ok := io.initializedPins[id]; !ok {
+	p, ok := io.initializedPins[id]
+	if !ok {
 		return fmt.Errorf("gpio: pin %v is not registered yet, cannot unregister", id)
 	}
 
+	Unclaim(p)
 	delete(io.initializedPins, id)
 	return nil
 }
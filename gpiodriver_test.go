@@ -44,6 +44,10 @@ func (*fakeDigitalPin) PullDown() error {
 	return nil
 }
 
+func (*fakeDigitalPin) SetDebounce(d time.Duration) error {
+	return nil
+}
+
 func (p *fakeDigitalPin) Close() error {
 	return p.drv.Unregister(p.id)
 }
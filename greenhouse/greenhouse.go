@@ -0,0 +1,521 @@
+/*
+Package greenhouse waters a greenhouse or a garden bed from the moisture of
+its soil, through a pump or a valve switched by a relay, and shows the
+climate and the watering on a character display with buttons to override
+it.
+
+The pump starts when the soil gets drier than Dry and stops once it is
+wetter than Wet, and the garden can also be watered on a schedule:
+
+	g := greenhouse.New(greenhouse.AnalogProbe(soil, 2800, 1200), relay)
+	g.Air = bmp180.New(bus)
+	g.Dry, g.Wet = 0.35, 0.6
+	g.Schedule(lighting.Daily(6, 30), 2*time.Minute)
+	g.BindDisplay(lcd.Region(0, 0, 16), lcd.Region(0, 1, 16))
+	if err := g.BindButtons(hotkey.NewButton(waterPin), hotkey.NewButton(modePin)); err != nil {
+		panic(err)
+	}
+	g.Run()
+	defer g.Close()
+
+	for ev := range g.Events() {
+		log.Print(ev)
+	}
+
+The pump never runs longer than MaxRun at once. When it does so while
+watering automatically, like with an empty tank or a probe out of the soil,
+the controller faults and stops watering automatically until it is reset.
+The relay can be guarded by an interlock.Controller too.
+*/
+package greenhouse
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/interface/hotkey"
+	"github.com/kidoman/embd/lighting"
+	"github.com/kidoman/embd/sensor"
+	"github.com/kidoman/embd/units"
+)
+
+const (
+	// DefaultDry and DefaultWet are the default moistures the pump starts
+	// and stops at.
+	DefaultDry = 0.3
+	DefaultWet = 0.5
+
+	// DefaultMaxRun is the default longest run of the pump.
+	DefaultMaxRun = 5 * time.Minute
+
+	// DefaultSoak is the default time between automatic waterings, for the
+	// water to soak in before the soil is measured again.
+	DefaultSoak = 15 * time.Minute
+
+	// DefaultManualRun is the default length of a watering started from the
+	// buttons.
+	DefaultManualRun = time.Minute
+
+	// DefaultPoll is how often the sensors are read by default.
+	DefaultPoll = 30 * time.Second
+
+	// pumpPoll is how often the soil is read while the pump runs.
+	pumpPoll = time.Second
+
+	eventBuffer = 8
+)
+
+// A MoistureSensor measures the moisture of soil, from 0 (dry) to 1 (wet).
+type MoistureSensor interface {
+	Moisture() (float64, error)
+}
+
+// MoistureFunc adapts a function to the MoistureSensor interface.
+type MoistureFunc func() (float64, error)
+
+// Moisture implements MoistureSensor.
+func (f MoistureFunc) Moisture() (float64, error) {
+	return f()
+}
+
+// AnalogProbe returns the moisture sensor reading a resistive or capacitive
+// probe on an analog pin, calibrated with its readings in dry air and in
+// water. Capacitive probes read lower as the soil gets wetter, so dry may be
+// above wet.
+func AnalogProbe(pin embd.AnalogPin, dry, wet int) MoistureSensor {
+	return MoistureFunc(func() (float64, error) {
+		v, err := pin.Read()
+		if err != nil {
+			return 0, err
+		}
+		m := float64(v-dry) / float64(wet-dry)
+		switch {
+		case m < 0:
+			m = 0
+		case m > 1:
+			m = 1
+		}
+		return m, nil
+	})
+}
+
+// Mode is the watering mode of the controller.
+type Mode int
+
+// Modes.
+const (
+	// Auto waters when the soil is dry and on the schedules.
+	Auto Mode = iota
+	// Off only waters when asked to, from the buttons or Water.
+	Off
+)
+
+func (m Mode) String() string {
+	if m == Off {
+		return "off"
+	}
+	return "auto"
+}
+
+// State is the state of the controller at a reading.
+type State struct {
+	Time time.Time
+
+	// Temperature and Humidity are those of the air, when the controller
+	// has the sensors.
+	Temperature units.Temperature
+	Humidity    float64
+
+	Moisture float64
+	Mode     Mode
+	Pumping  bool
+
+	// Fault is why automatic watering stopped until Reset, empty when the
+	// controller is not faulted.
+	Fault string
+}
+
+func (s State) String() string {
+	str := fmt.Sprintf("soil %.0f%%, %v", s.Moisture*100, s.Mode)
+	if s.Pumping {
+		str += ", pumping"
+	}
+	if s.Fault != "" {
+		str += ", fault: " + s.Fault
+	}
+	return str
+}
+
+// Event reports the pump starting or stopping.
+type Event struct {
+	Time    time.Time
+	Pumping bool
+	Reason  string
+}
+
+func (e Event) String() string {
+	if e.Pumping {
+		return "pump started: " + e.Reason
+	}
+	return "pump stopped: " + e.Reason
+}
+
+type schedule struct {
+	at   lighting.Trigger
+	run  time.Duration
+	next time.Time
+}
+
+// Controller waters the soil.
+type Controller struct {
+	Soil MoistureSensor
+	// Pump is the pin of the relay switching the pump or the valve, on at
+	// PumpOn, embd.High by default.
+	Pump   embd.DigitalPin
+	PumpOn int
+
+	// Air and Hygrometer are the optional sensors of the air, shown on
+	// the display.
+	Air        sensor.Thermometer
+	Hygrometer sensor.Hygrometer
+
+	// Dry and Wet are the moistures the pump starts and stops at.
+	Dry, Wet float64
+
+	// MaxRun is the longest the pump runs at once.
+	MaxRun time.Duration
+	// Soak is the shortest time between automatic waterings.
+	Soak time.Duration
+	// ManualRun is the length of a watering started from the buttons.
+	ManualRun time.Duration
+
+	// Poll is how often the sensors are read while the pump is off.
+	Poll time.Duration
+
+	clock clock.Clock
+
+	mu        sync.Mutex
+	state     State
+	since     time.Time // when the pump started
+	limit     time.Duration
+	auto      bool // the pump runs for dry soil
+	lastStop  time.Time
+	schedules []*schedule
+	bindings  []func(State)
+	buttons   []*hotkey.Button
+
+	events  chan Event
+	changed chan struct{}
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+// New creates a new controller watering through a pump from the moisture of
+// the soil.
+func New(soil MoistureSensor, pump embd.DigitalPin) *Controller {
+	return NewWithClock(soil, pump, clock.Real)
+}
+
+// NewWithClock creates a new controller timing the waterings and the
+// schedules with c, like a clock.Virtual in tests.
+func NewWithClock(soil MoistureSensor, pump embd.DigitalPin, c clock.Clock) *Controller {
+	return &Controller{
+		Soil:      soil,
+		Pump:      pump,
+		PumpOn:    embd.High,
+		Dry:       DefaultDry,
+		Wet:       DefaultWet,
+		MaxRun:    DefaultMaxRun,
+		Soak:      DefaultSoak,
+		ManualRun: DefaultManualRun,
+		Poll:      DefaultPoll,
+		clock:     clock.Or(c),
+		events:    make(chan Event, eventBuffer),
+		changed:   make(chan struct{}, 1),
+	}
+}
+
+func (c *Controller) notify() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Events returns the channel the pump starting and stopping is reported
+// on. Events are dropped when the channel is full.
+func (c *Controller) Events() <-chan Event {
+	return c.events
+}
+
+func (c *Controller) pumpOff() int {
+	if c.PumpOn == embd.High {
+		return embd.Low
+	}
+	return embd.High
+}
+
+// start starts the pump for run. It must be called with the lock held.
+func (c *Controller) start(now time.Time, run time.Duration, auto bool, reason string) error {
+	if run > c.MaxRun {
+		run = c.MaxRun
+	}
+	if !c.state.Pumping {
+		if err := c.Pump.Write(c.PumpOn); err != nil {
+			return err
+		}
+		c.publish(Event{Time: now, Pumping: true, Reason: reason})
+	}
+	c.state.Pumping = true
+	c.since, c.limit, c.auto = now, run, auto
+	return nil
+}
+
+// stop stops the pump. It must be called with the lock held.
+func (c *Controller) stop(now time.Time, reason string) error {
+	if !c.state.Pumping {
+		return nil
+	}
+	if err := c.Pump.Write(c.pumpOff()); err != nil {
+		return err
+	}
+	c.state.Pumping = false
+	c.lastStop = now
+	c.publish(Event{Time: now, Reason: reason})
+	return nil
+}
+
+func (c *Controller) publish(ev Event) {
+	glog.V(1).Infof("greenhouse: %v", ev)
+	select {
+	case c.events <- ev:
+	default:
+		glog.Warningf("greenhouse: event channel full, dropping %v", ev)
+	}
+}
+
+// Water runs the pump for d, or for ManualRun when d is zero, whatever the
+// mode and even when faulted. The run is cut to MaxRun.
+func (c *Controller) Water(d time.Duration) error {
+	if d <= 0 {
+		d = c.ManualRun
+	}
+	c.mu.Lock()
+	err := c.start(c.clock.Now(), d, false, "manual")
+	s := c.state
+	c.mu.Unlock()
+	c.notify()
+	c.show(s)
+	return err
+}
+
+// Stop stops the pump. Automatic watering starts again when the soil is
+// still dry after Soak.
+func (c *Controller) Stop() error {
+	c.mu.Lock()
+	err := c.stop(c.clock.Now(), "manual")
+	s := c.state
+	c.mu.Unlock()
+	c.notify()
+	c.show(s)
+	return err
+}
+
+// SetMode sets the watering mode. Turning it Off stops the pump.
+func (c *Controller) SetMode(m Mode) error {
+	c.mu.Lock()
+	c.state.Mode = m
+	var err error
+	if m == Off {
+		err = c.stop(c.clock.Now(), "off")
+	}
+	s := c.state
+	c.mu.Unlock()
+	glog.V(1).Infof("greenhouse: mode %v", m)
+	c.notify()
+	c.show(s)
+	return err
+}
+
+// Mode returns the watering mode.
+func (c *Controller) Mode() Mode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.state.Mode
+}
+
+// Reset clears a fault, once the tank is filled or the probe put back.
+func (c *Controller) Reset() {
+	c.mu.Lock()
+	c.state.Fault = ""
+	s := c.state
+	c.mu.Unlock()
+	glog.Infof("greenhouse: reset")
+	c.notify()
+	c.show(s)
+}
+
+// Schedule waters for run every time at fires, in Auto mode and unless the
+// soil is already wet.
+func (c *Controller) Schedule(at lighting.Trigger, run time.Duration) {
+	c.mu.Lock()
+	c.schedules = append(c.schedules, &schedule{at: at, run: run, next: at(c.clock.Now())})
+	c.mu.Unlock()
+	c.notify()
+}
+
+// State returns the state at the last reading.
+func (c *Controller) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.state
+}
+
+// Update reads the sensors, and starts or stops the pump.
+func (c *Controller) Update() (State, error) {
+	return c.update(c.clock.Now())
+}
+
+func (c *Controller) update(now time.Time) (State, error) {
+	moisture, merr := c.Soil.Moisture()
+	if merr != nil {
+		glog.Errorf("greenhouse: reading soil moisture: %v", merr)
+	}
+	var t units.Temperature
+	var h float64
+	if c.Air != nil {
+		var err error
+		if t, err = c.Air.Temperature(); err != nil {
+			glog.Errorf("greenhouse: reading temperature: %v", err)
+		}
+	}
+	if c.Hygrometer != nil {
+		var err error
+		if h, err = c.Hygrometer.Humidity(); err != nil {
+			glog.Errorf("greenhouse: reading humidity: %v", err)
+		}
+	}
+
+	c.mu.Lock()
+	c.state.Time, c.state.Temperature, c.state.Humidity = now, t, h
+	if merr == nil {
+		c.state.Moisture = moisture
+	}
+	var due *schedule
+	for _, s := range c.schedules {
+		if !s.next.IsZero() && !now.Before(s.next) {
+			s.next = s.at(now)
+			if due == nil {
+				due = s
+			}
+		}
+	}
+
+	var err error
+	switch {
+	case c.state.Pumping:
+		switch {
+		case now.Sub(c.since) >= c.limit:
+			if c.auto {
+				c.state.Fault = "soil still dry after " + c.limit.String()
+				glog.Errorf("greenhouse: %v, stopping automatic watering", c.state.Fault)
+				err = c.stop(now, "max runtime")
+			} else {
+				err = c.stop(now, "done")
+			}
+		case c.auto && merr != nil:
+			err = c.stop(now, "soil sensor failed")
+		case c.auto && moisture >= c.Wet:
+			err = c.stop(now, "soil wet")
+		}
+	case c.state.Mode != Auto || c.state.Fault != "":
+	case due != nil && (merr != nil || moisture < c.Wet):
+		err = c.start(now, due.run, false, "schedule")
+	case merr == nil && moisture < c.Dry && (c.lastStop.IsZero() || now.Sub(c.lastStop) >= c.Soak):
+		err = c.start(now, c.MaxRun, true, "soil dry")
+	}
+	s := c.state
+	c.mu.Unlock()
+
+	c.show(s)
+	return s, err
+}
+
+// show shows the state on the bindings.
+func (c *Controller) show(s State) {
+	c.mu.Lock()
+	bindings := append([]func(State){}, c.bindings...)
+	c.mu.Unlock()
+
+	for _, b := range bindings {
+		b(s)
+	}
+}
+
+// wait returns how long to wait before the next update.
+func (c *Controller) wait(now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wait := c.Poll
+	if c.state.Pumping {
+		wait = pumpPoll
+		if left := c.limit - now.Sub(c.since); left < wait {
+			wait = left
+		}
+	}
+	for _, s := range c.schedules {
+		if left := s.next.Sub(now); !s.next.IsZero() && left < wait {
+			wait = left
+		}
+	}
+	return wait
+}
+
+// Run starts reading the sensors and watering in the background.
+func (c *Controller) Run() {
+	c.quit = make(chan struct{})
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+
+		for {
+			if _, err := c.Update(); err != nil {
+				glog.Errorf("greenhouse: %v", err)
+			}
+			timer := c.clock.NewTimer(c.wait(c.clock.Now()))
+			select {
+			case <-timer.C():
+			case <-c.changed:
+				timer.Stop()
+			case <-c.quit:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Close stops watering, turning the pump off, and closes the bound buttons.
+func (c *Controller) Close() error {
+	if c.quit != nil {
+		close(c.quit)
+		<-c.done
+		c.quit = nil
+	}
+	c.mu.Lock()
+	buttons := c.buttons
+	c.buttons = nil
+	c.mu.Unlock()
+	for _, b := range buttons {
+		b.Close()
+	}
+	return c.Pump.Write(c.pumpOff())
+}
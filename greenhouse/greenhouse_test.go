@@ -0,0 +1,167 @@
+package greenhouse
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/interface/hotkey"
+)
+
+type fakePin struct {
+	embd.DigitalPin
+
+	val int
+}
+
+func (p *fakePin) Write(val int) error {
+	p.val = val
+	return nil
+}
+
+type fakeSoil struct {
+	moisture float64
+	err      error
+}
+
+func (f *fakeSoil) Moisture() (float64, error) {
+	return f.moisture, f.err
+}
+
+func newController() (*Controller, *fakeSoil, *fakePin, *clock.Virtual) {
+	soil, pump := &fakeSoil{moisture: 0.4}, &fakePin{}
+	c := clock.NewVirtual(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	return NewWithClock(soil, pump, c), soil, pump, c
+}
+
+func mustUpdate(t *testing.T, g *Controller) State {
+	t.Helper()
+	s, err := g.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAuto(t *testing.T) {
+	g, soil, pump, c := newController()
+
+	if s := mustUpdate(t, g); s.Pumping {
+		t.Fatalf("pumping at %.2f", s.Moisture)
+	}
+	soil.moisture = 0.2
+	if s := mustUpdate(t, g); !s.Pumping || pump.val != embd.High {
+		t.Fatal("not pumping with dry soil")
+	}
+	c.Advance(time.Minute)
+	soil.moisture = 0.55
+	if s := mustUpdate(t, g); s.Pumping || pump.val != embd.Low {
+		t.Fatal("still pumping with wet soil")
+	}
+	if ev := <-g.Events(); !ev.Pumping || ev.Reason != "soil dry" {
+		t.Errorf("got %v, want the pump started for dry soil", ev)
+	}
+	if ev := <-g.Events(); ev.Pumping || ev.Reason != "soil wet" {
+		t.Errorf("got %v, want the pump stopped for wet soil", ev)
+	}
+
+	// The water soaks in before the next watering.
+	soil.moisture = 0.2
+	c.Advance(time.Minute)
+	if s := mustUpdate(t, g); s.Pumping {
+		t.Error("watering again before Soak")
+	}
+	c.Advance(DefaultSoak)
+	if s := mustUpdate(t, g); !s.Pumping {
+		t.Error("not watering again after Soak")
+	}
+}
+
+func TestMaxRun(t *testing.T) {
+	g, soil, _, c := newController()
+	soil.moisture = 0.1
+
+	mustUpdate(t, g)
+	c.Advance(DefaultMaxRun)
+	s := mustUpdate(t, g)
+	if s.Pumping || s.Fault == "" {
+		t.Fatalf("got %v, want the pump stopped by a fault", s)
+	}
+	c.Advance(DefaultSoak)
+	if s := mustUpdate(t, g); s.Pumping {
+		t.Error("watering automatically while faulted")
+	}
+	if err := g.Water(0); err != nil || !g.State().Pumping {
+		t.Errorf("manual watering while faulted: %v", err)
+	}
+	g.Stop()
+
+	g.Reset()
+	c.Advance(DefaultSoak)
+	if s := mustUpdate(t, g); !s.Pumping {
+		t.Error("not watering after Reset")
+	}
+}
+
+func TestSensorFailure(t *testing.T) {
+	g, soil, _, _ := newController()
+	soil.moisture = 0.1
+	mustUpdate(t, g)
+
+	soil.err = errors.New("disconnected")
+	if s := mustUpdate(t, g); s.Pumping {
+		t.Error("still pumping without a soil reading")
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	g, soil, _, c := newController()
+	g.Schedule(func(after time.Time) time.Time {
+		return after.Truncate(time.Hour).Add(time.Hour)
+	}, 2*time.Minute)
+
+	mustUpdate(t, g)
+	c.Advance(time.Hour)
+	if s := mustUpdate(t, g); !s.Pumping {
+		t.Fatal("not watering on schedule")
+	}
+	c.Advance(2 * time.Minute)
+	if s := mustUpdate(t, g); s.Pumping {
+		t.Fatal("still watering after the scheduled run")
+	}
+
+	soil.moisture = 0.6
+	c.Advance(time.Hour)
+	if s := mustUpdate(t, g); s.Pumping {
+		t.Error("watering wet soil on schedule")
+	}
+}
+
+func TestButtons(t *testing.T) {
+	g, _, pump, _ := newController()
+	mustUpdate(t, g)
+
+	if err := g.waterGesture(hotkey.Click); err != nil || pump.val != embd.High {
+		t.Fatalf("water click did not start the pump: %v", err)
+	}
+	if err := g.waterGesture(hotkey.Click); err != nil || pump.val != embd.Low {
+		t.Fatalf("water click did not stop the pump: %v", err)
+	}
+
+	g.Water(0)
+	if err := g.modeGesture(hotkey.Click); err != nil || g.Mode() != Off {
+		t.Fatalf("mode click: mode %v, %v", g.Mode(), err)
+	}
+	if pump.val != embd.Low {
+		t.Error("pump still on in Off mode")
+	}
+	if got, want := wateringLine(g.State()), "Soil 40% OFF"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	g.modeGesture(hotkey.Click)
+	if g.Mode() != Auto {
+		t.Error("mode click did not switch back to Auto")
+	}
+}
@@ -0,0 +1,119 @@
+// Display and buttons.
+
+package greenhouse
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+	"github.com/kidoman/embd/interface/hotkey"
+)
+
+// climateLine returns the temperature and the humidity of the air, like
+// "23.5C 61%RH".
+func climateLine(c *Controller, s State) string {
+	line := ""
+	if c.Air != nil {
+		line = fmt.Sprintf("%.1fC", float64(s.Temperature))
+	}
+	if c.Hygrometer != nil {
+		if line != "" {
+			line += " "
+		}
+		line += fmt.Sprintf("%.0f%%RH", s.Humidity)
+	}
+	return line
+}
+
+// wateringLine returns the moisture of the soil and what the controller
+// does, like "Soil 42% PUMP".
+func wateringLine(s State) string {
+	status := "AUTO"
+	switch {
+	case s.Pumping:
+		status = "PUMP"
+	case s.Fault != "":
+		status = "FAULT"
+	case s.Mode == Off:
+		status = "OFF"
+	}
+	return fmt.Sprintf("Soil %.0f%% %v", s.Moisture*100, status)
+}
+
+// BindDisplay keeps display regions showing the climate and the watering,
+// updated at every reading and change. The climate region may be nil.
+func (c *Controller) BindDisplay(climate, watering *characterdisplay.Region) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.bindings = append(c.bindings, func(s State) {
+		if climate != nil {
+			if err := climate.Write(climateLine(c, s)); err != nil {
+				glog.Errorf("greenhouse: updating display region: %v", err)
+			}
+		}
+		if err := watering.Write(wateringLine(s)); err != nil {
+			glog.Errorf("greenhouse: updating display region: %v", err)
+		}
+	})
+}
+
+// waterGesture overrides the watering from a gesture of the water button: a
+// click waters for ManualRun or stops the pump, and a long press resets a
+// fault.
+func (c *Controller) waterGesture(g hotkey.Gesture) error {
+	switch g {
+	case hotkey.Click:
+		if c.State().Pumping {
+			return c.Stop()
+		}
+		return c.Water(0)
+	case hotkey.LongPress:
+		c.Reset()
+	}
+	return nil
+}
+
+// modeGesture switches the mode between Auto and Off on a click of the mode
+// button.
+func (c *Controller) modeGesture(g hotkey.Gesture) error {
+	if g != hotkey.Click {
+		return nil
+	}
+	if c.Mode() == Auto {
+		return c.SetMode(Off)
+	}
+	return c.SetMode(Auto)
+}
+
+// BindButtons runs the override buttons, closed with the controller. A
+// click of the water button waters for ManualRun, or stops the pump, and a
+// long press resets a fault. A click of the mode button switches between
+// Auto and Off. Either button may be nil.
+func (c *Controller) BindButtons(water, mode *hotkey.Button) error {
+	handlers := []struct {
+		b  *hotkey.Button
+		fn func(hotkey.Gesture) error
+	}{{water, c.waterGesture}, {mode, c.modeGesture}}
+	for _, h := range handlers {
+		if h.b == nil {
+			continue
+		}
+		if err := h.b.Run(); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.buttons = append(c.buttons, h.b)
+		c.mu.Unlock()
+
+		go func(b *hotkey.Button, fn func(hotkey.Gesture) error) {
+			for g := range b.Gestures() {
+				if err := fn(g); err != nil {
+					glog.Errorf("greenhouse: %v: %v", g, err)
+				}
+			}
+		}(h.b, h.fn)
+	}
+	return nil
+}
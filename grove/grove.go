@@ -0,0 +1,215 @@
+/*
+Package grove opens the modules plugged into the Grove, Qwiic and STEMMA QT
+ports of a host or of a hat, without tracking their pin numbers.
+
+The ports of a hat are registered once, and the modules are then opened by
+port:
+
+	if err := grove.RegisterBaseHat(); err != nil {
+		panic(err)
+	}
+	dev, err := grove.NewFromPort("D5", grove.Button)
+	if err != nil {
+		panic(err)
+	}
+	button := dev.(*hotkey.Button)
+
+The catalog of supported modules is in Devices.
+*/
+package grove
+
+import (
+	"fmt"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/controller/hd44780"
+	"github.com/kidoman/embd/controller/mcp4725"
+	"github.com/kidoman/embd/controller/pca9685"
+	"github.com/kidoman/embd/interface/hotkey"
+	"github.com/kidoman/embd/sensor/bh1750fvi"
+	"github.com/kidoman/embd/sensor/bmp180"
+	"github.com/kidoman/embd/sensor/watersensor"
+)
+
+// BaseHat are the digital and I²C ports of the Grove Base Hat for Raspberry
+// Pi. Its analog ports go through the ADC of the hat, which is not
+// supported.
+var BaseHat = []embd.Port{
+	{Name: "D5", Kind: embd.DigitalPort, Pins: []interface{}{5, 6}},
+	{Name: "D16", Kind: embd.DigitalPort, Pins: []interface{}{16, 17}},
+	{Name: "D18", Kind: embd.DigitalPort, Pins: []interface{}{18, 19}},
+	{Name: "D22", Kind: embd.DigitalPort, Pins: []interface{}{22, 23}},
+	{Name: "D24", Kind: embd.DigitalPort, Pins: []interface{}{24, 25}},
+	{Name: "D26", Kind: embd.DigitalPort, Pins: []interface{}{26, 27}},
+	{Name: "PWM", Kind: embd.DigitalPort, Pins: []interface{}{12, 13}},
+	{Name: "I2C", Kind: embd.I2CPort, Bus: 1},
+}
+
+// QwiicHat is the port of the Qwiic and STEMMA QT hats for Raspberry Pi.
+var QwiicHat = []embd.Port{
+	{Name: "QWIIC", Kind: embd.I2CPort, Bus: 1},
+}
+
+// RegisterBaseHat registers the ports of the Grove Base Hat.
+func RegisterBaseHat() error {
+	return embd.RegisterPorts(BaseHat...)
+}
+
+// RegisterQwiicHat registers the port of a Qwiic hat.
+func RegisterQwiicHat() error {
+	return embd.RegisterPorts(QwiicHat...)
+}
+
+// Device is a module which can be plugged into a port.
+type Device struct {
+	Name string
+	Kind embd.PortKind
+	// Addr is the address of I²C modules, which can be changed for the
+	// modules with address jumpers.
+	Addr byte
+
+	open func(p embd.Port, addr byte) (interface{}, error)
+}
+
+func (d Device) String() string {
+	return d.Name
+}
+
+// The supported modules, and the type NewFromPort returns for them.
+var (
+	// Button is a push button, returned as a *hotkey.Button to be run.
+	Button = Device{Name: "button", Kind: embd.DigitalPort, open: openButton}
+	// LED is a LED, returned as an output embd.DigitalPin.
+	LED = Device{Name: "led", Kind: embd.DigitalPort, open: openOutput}
+	// Relay is a relay, returned as an output embd.DigitalPin, off.
+	Relay = Device{Name: "relay", Kind: embd.DigitalPort, open: openOutput}
+	// Buzzer is an active buzzer, returned as an output embd.DigitalPin.
+	Buzzer = Device{Name: "buzzer", Kind: embd.DigitalPort, open: openOutput}
+	// WaterSensor is a water sensor, returned as a
+	// *watersensor.WaterSensor.
+	WaterSensor = Device{Name: "water sensor", Kind: embd.DigitalPort, open: openWaterSensor}
+	// Rotary is a rotary angle sensor, returned as an embd.AnalogPin.
+	Rotary = Device{Name: "rotary angle sensor", Kind: embd.AnalogPort, open: openAnalog}
+	// LightSensor is an analog light sensor, returned as an
+	// embd.AnalogPin.
+	LightSensor = Device{Name: "light sensor", Kind: embd.AnalogPort, open: openAnalog}
+	// Barometer is a BMP180 barometer, returned as a *bmp180.BMP180.
+	Barometer = Device{Name: "BMP180 barometer", Kind: embd.I2CPort, Addr: 0x77, open: openBarometer}
+	// DigitalLight is a BH1750 light sensor, returned as a
+	// *bh1750fvi.BH1750FVI.
+	DigitalLight = Device{Name: "BH1750 light sensor", Kind: embd.I2CPort, Addr: 0x23, open: openDigitalLight}
+	// PWMDriver is a PCA9685 16 channel PWM driver, returned as a
+	// *pca9685.PCA9685.
+	PWMDriver = Device{Name: "PCA9685 PWM driver", Kind: embd.I2CPort, Addr: 0x40, open: openPWMDriver}
+	// DAC is a MCP4725 DAC, returned as a *mcp4725.MCP4725.
+	DAC = Device{Name: "MCP4725 DAC", Kind: embd.I2CPort, Addr: 0x60, open: openDAC}
+	// LCD is a 16x2 HD44780 display with a PCF8574 backpack, returned as
+	// a *hd44780.HD44780.
+	LCD = Device{Name: "16x2 LCD", Kind: embd.I2CPort, Addr: 0x27, open: openLCD}
+)
+
+// Devices is the catalog of the supported modules.
+var Devices = []Device{
+	Button, LED, Relay, Buzzer, WaterSensor,
+	Rotary, LightSensor,
+	Barometer, DigitalLight, PWMDriver, DAC, LCD,
+}
+
+// LookupDevice returns the module of the catalog called name.
+func LookupDevice(name string) (Device, bool) {
+	for _, d := range Devices {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Device{}, false
+}
+
+// NewFromPort opens the module plugged into the port called port.
+func NewFromPort(port string, d Device) (interface{}, error) {
+	p, err := embd.LookupPort(port)
+	if err != nil {
+		return nil, err
+	}
+	if p.Kind != d.Kind {
+		return nil, fmt.Errorf("grove: %v is a %v module, port %v is %v", d.Name, d.Kind, p.Name, p.Kind)
+	}
+	if d.open == nil {
+		return nil, fmt.Errorf("grove: unknown module %v", d.Name)
+	}
+	return d.open(p, d.Addr)
+}
+
+func openButton(p embd.Port, _ byte) (interface{}, error) {
+	pin, err := p.DigitalPin(0)
+	if err != nil {
+		return nil, err
+	}
+	return hotkey.NewButton(pin), nil
+}
+
+func openOutput(p embd.Port, _ byte) (interface{}, error) {
+	pin, err := p.DigitalPin(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := pin.SetDirection(embd.Out); err != nil {
+		return nil, err
+	}
+	if err := pin.Write(embd.Low); err != nil {
+		return nil, err
+	}
+	return pin, nil
+}
+
+func openWaterSensor(p embd.Port, _ byte) (interface{}, error) {
+	pin, err := p.DigitalPin(0)
+	if err != nil {
+		return nil, err
+	}
+	return watersensor.New(pin), nil
+}
+
+func openAnalog(p embd.Port, _ byte) (interface{}, error) {
+	return p.AnalogPin(0)
+}
+
+func openBarometer(p embd.Port, _ byte) (interface{}, error) {
+	bus, err := p.I2CBus()
+	if err != nil {
+		return nil, err
+	}
+	return bmp180.New(bus), nil
+}
+
+func openDigitalLight(p embd.Port, _ byte) (interface{}, error) {
+	bus, err := p.I2CBus()
+	if err != nil {
+		return nil, err
+	}
+	return bh1750fvi.NewHighMode(bus), nil
+}
+
+func openPWMDriver(p embd.Port, addr byte) (interface{}, error) {
+	bus, err := p.I2CBus()
+	if err != nil {
+		return nil, err
+	}
+	return pca9685.New(bus, addr), nil
+}
+
+func openDAC(p embd.Port, addr byte) (interface{}, error) {
+	bus, err := p.I2CBus()
+	if err != nil {
+		return nil, err
+	}
+	return mcp4725.New(bus, addr), nil
+}
+
+func openLCD(p embd.Port, addr byte) (interface{}, error) {
+	bus, err := p.I2CBus()
+	if err != nil {
+		return nil, err
+	}
+	return hd44780.NewI2C(bus, addr, hd44780.PCF8574PinMap, hd44780.RowAddress16Col, hd44780.TwoLine)
+}
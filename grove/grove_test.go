@@ -0,0 +1,71 @@
+package grove
+
+import (
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+type fakePin struct {
+	embd.DigitalPin
+
+	dir embd.Direction
+	val int
+}
+
+func (p *fakePin) SetDirection(dir embd.Direction) error {
+	p.dir = dir
+	return nil
+}
+
+func (p *fakePin) Write(val int) error {
+	p.val = val
+	return nil
+}
+
+type fakeExpander map[int]*fakePin
+
+func (e fakeExpander) DigitalPin(n int) (embd.DigitalPin, error) {
+	if e[n] == nil {
+		e[n] = &fakePin{val: embd.High}
+	}
+	return e[n], nil
+}
+
+func TestNewFromPort(t *testing.T) {
+	e := fakeExpander{}
+	if err := embd.RegisterExpander("grovetest", e); err != nil {
+		t.Fatal(err)
+	}
+	defer embd.UnregisterExpander("grovetest")
+	if err := embd.RegisterPorts(embd.Port{Name: "D5", Kind: embd.DigitalPort, Pins: []interface{}{"grovetest.5", "grovetest.6"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer embd.UnregisterPort("D5")
+
+	dev, err := NewFromPort("D5", Relay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.(embd.DigitalPin) != e[5] || e[5].dir != embd.Out || e[5].val != embd.Low {
+		t.Errorf("relay not opened as an output off on the first pin of the port: %+v", e[5])
+	}
+
+	if _, err := NewFromPort("D5", Barometer); err == nil {
+		t.Error("no error opening an I2C module on a digital port")
+	}
+	if _, err := NewFromPort("D7", Button); err == nil {
+		t.Error("no error opening a module on an unknown port")
+	}
+}
+
+func TestLookupDevice(t *testing.T) {
+	for _, d := range Devices {
+		if got, ok := LookupDevice(d.Name); !ok || got.Name != d.Name || got.open == nil {
+			t.Errorf("LookupDevice(%q): got %v, %v", d.Name, got, ok)
+		}
+	}
+	if _, ok := LookupDevice("flux capacitor"); ok {
+		t.Error("found an unknown module")
+	}
+}
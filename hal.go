@@ -0,0 +1,86 @@
+// Hardware agnostic interfaces.
+
+package embd
+
+import (
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/hal"
+)
+
+type halPin struct {
+	pin DigitalPin
+}
+
+// HALPin returns the pin as a hal.Pin, for the drivers shared with TinyGo.
+// hal.Pin does not return errors, so they are logged.
+func HALPin(pin DigitalPin) hal.Pin {
+	return halPin{pin}
+}
+
+func (p halPin) Set(high bool) {
+	v := Low
+	if high {
+		v = High
+	}
+	if err := p.pin.Write(v); err != nil {
+		glog.Errorf("embd: writing pin %v: %v", p.pin.N(), err)
+	}
+}
+
+func (p halPin) Get() bool {
+	v, err := p.pin.Read()
+	if err != nil {
+		glog.Errorf("embd: reading pin %v: %v", p.pin.N(), err)
+	}
+	return v == High
+}
+
+type halI2C struct {
+	bus I2CBus
+}
+
+// HALI2C returns the bus as a hal.I2C, for the drivers shared with TinyGo.
+// The I2CBus reads a single byte, or the bytes of a register: reads are
+// supported after writing the register, or of one byte without a write, and
+// fail with ErrFeatureNotSupported otherwise.
+func HALI2C(bus I2CBus) hal.I2C {
+	return halI2C{bus}
+}
+
+func (b halI2C) Tx(addr uint16, w, r []byte) error {
+	a := byte(addr)
+	switch {
+	case len(r) == 0:
+		return b.bus.WriteBytes(a, w)
+	case len(w) == 1:
+		return b.bus.ReadFromReg(a, w[0], r)
+	case len(w) == 0 && len(r) == 1:
+		v, err := b.bus.ReadByte(a)
+		r[0] = v
+		return err
+	}
+	return ErrFeatureNotSupported
+}
+
+type halSPI struct {
+	bus SPIBus
+}
+
+// HALSPI returns the bus as a hal.SPI, for the drivers shared with TinyGo.
+func HALSPI(bus SPIBus) hal.SPI {
+	return halSPI{bus}
+}
+
+func (b halSPI) Tx(w, r []byte) error {
+	if len(w) == 0 {
+		data, err := b.bus.ReceiveData(len(r))
+		copy(r, data)
+		return err
+	}
+	buf := append([]byte(nil), w...)
+	if err := b.bus.TransferAndRecieveData(buf); err != nil {
+		return err
+	}
+	copy(r, buf)
+	return nil
+}
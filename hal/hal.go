@@ -0,0 +1,37 @@
+/*
+Package hal defines the pin and bus interfaces shared by embd and the
+microcontroller targets of TinyGo. They have the methods of the pins and
+buses of TinyGo's machine package, so that machine.Pin, machine.I2C and
+machine.SPI implement them as they are, and the package depends on nothing
+but the language.
+
+Drivers written against these interfaces, like the protocol encoders of
+controller/hd44780/protocol, build for both kinds of targets. On a single
+board computer, embd.HALPin, embd.HALI2C and embd.HALSPI provide them for
+the pins and buses of the host:
+
+	bus := embd.HALI2C(embd.NewI2CBus(1))
+*/
+package hal
+
+// Pin is a digital pin.
+type Pin interface {
+	// Set drives the pin high or low.
+	Set(high bool)
+	// Get returns whether the pin is high.
+	Get() bool
+}
+
+// I2C is an I²C bus.
+type I2C interface {
+	// Tx writes w to the device at addr, then reads len(r) bytes into r,
+	// in a single transaction. Either can be empty.
+	Tx(addr uint16, w, r []byte) error
+}
+
+// SPI is a SPI bus with its chip select.
+type SPI interface {
+	// Tx writes w while reading len(r) bytes into r. When both are set,
+	// they have the same length.
+	Tx(w, r []byte) error
+}
@@ -0,0 +1,47 @@
+package embd
+
+import (
+	"bytes"
+	"testing"
+)
+
+type halBus struct {
+	I2CBus
+
+	written []byte
+	reg     byte
+}
+
+func (b *halBus) WriteBytes(addr byte, value []byte) error {
+	b.written = append(b.written, value...)
+	return nil
+}
+
+func (b *halBus) ReadFromReg(addr, reg byte, value []byte) error {
+	b.reg = reg
+	for i := range value {
+		value[i] = reg + byte(i)
+	}
+	return nil
+}
+
+func TestHALI2C(t *testing.T) {
+	b := &halBus{}
+	bus := HALI2C(b)
+	if err := bus.Tx(0x27, []byte{1, 2}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.written, []byte{1, 2}) {
+		t.Errorf("wrote %v, want [1 2]", b.written)
+	}
+	r := make([]byte, 2)
+	if err := bus.Tx(0x27, []byte{0x10}, r); err != nil {
+		t.Fatal(err)
+	}
+	if b.reg != 0x10 || !bytes.Equal(r, []byte{0x10, 0x11}) {
+		t.Errorf("read %v from %#x, want [16 17] from 0x10", r, b.reg)
+	}
+	if err := bus.Tx(0x27, []byte{1, 2}, r); err != ErrFeatureNotSupported {
+		t.Errorf("reading after two bytes: got %v, want ErrFeatureNotSupported", err)
+	}
+}
@@ -0,0 +1,263 @@
+/*
+Package health collects the status of the drivers, to make the failures of
+their background goroutines visible.
+
+The drivers report the outcome of their operations under a name, and the
+registry tracks whether each driver is OK, degraded by errors, or failed
+after consecutive errors:
+
+	health.Observe("baro", err)
+
+The statuses are served as JSON and in the Prometheus text format, shown by
+"embd health", and summed up on a character display:
+
+	http.Handle("/health", health.Default)
+	http.Handle("/metrics", health.Default.Metrics())
+	lcd.Message(health.Default.Page(16, 2))
+*/
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Status is the status of a driver.
+type Status int
+
+// The statuses, from the best to the worst.
+const (
+	OK Status = iota
+	Degraded
+	Failed
+)
+
+var statusNames = [...]string{"ok", "degraded", "failed"}
+
+func (s Status) String() string {
+	if s < 0 || int(s) >= len(statusNames) {
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+	return statusNames[s]
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Status) UnmarshalText(text []byte) error {
+	for i, name := range statusNames {
+		if string(text) == name {
+			*s = Status(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("health: unknown status %q", text)
+}
+
+// DefaultFailAfter is the number of consecutive errors after which a driver
+// is failed.
+const DefaultFailAfter = 3
+
+// Report is the status of a driver.
+type Report struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	// Error is the last error, and ErrorTime when it happened.
+	Error     string    `json:"error,omitempty"`
+	ErrorTime time.Time `json:"errorTime,omitempty"`
+	// Errors is the number of consecutive errors.
+	Errors int `json:"errors"`
+	// Updated is when the driver last reported.
+	Updated time.Time `json:"updated"`
+}
+
+// Registry collects the status of drivers.
+type Registry struct {
+	// FailAfter is the number of consecutive errors after which a driver
+	// is failed.
+	FailAfter int
+
+	mu      sync.Mutex
+	reports map[string]*Report
+}
+
+// NewRegistry creates a new empty registry.
+func NewRegistry() *Registry {
+	return &Registry{FailAfter: DefaultFailAfter, reports: map[string]*Report{}}
+}
+
+// Default is the registry the drivers report to.
+var Default = NewRegistry()
+
+// Observe records the outcome of an operation of a driver on Default.
+func Observe(name string, err error) {
+	Default.Observe(name, err)
+}
+
+func (r *Registry) report(name string) *Report {
+	rep, ok := r.reports[name]
+	if !ok {
+		rep = &Report{Name: name}
+		r.reports[name] = rep
+	}
+	return rep
+}
+
+// Observe records the outcome of an operation of a driver: a success makes
+// it OK, and an error degrades it, until it is failed after FailAfter
+// consecutive errors.
+func (r *Registry) Observe(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rep := r.report(name)
+	rep.Updated = time.Now()
+	if err == nil {
+		if rep.Status != OK {
+			glog.Infof("health: %v: recovered", name)
+		}
+		rep.Status, rep.Errors = OK, 0
+		return
+	}
+	rep.Errors++
+	rep.Error, rep.ErrorTime = err.Error(), rep.Updated
+	status := Degraded
+	if rep.Errors >= r.FailAfter {
+		status = Failed
+	}
+	if status != rep.Status {
+		glog.Warningf("health: %v: %v: %v", name, status, err)
+	}
+	rep.Status = status
+}
+
+// Fail marks a driver failed at once, like after an unrecoverable error.
+func (r *Registry) Fail(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rep := r.report(name)
+	rep.Updated = time.Now()
+	rep.Status = Failed
+	rep.Errors++
+	rep.Error, rep.ErrorTime = err.Error(), rep.Updated
+	glog.Warningf("health: %v: failed: %v", name, err)
+}
+
+// Remove forgets a driver, once it is closed.
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.reports, name)
+}
+
+// Reports returns the status of the drivers, sorted by name.
+func (r *Registry) Reports() []Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reports := make([]Report, 0, len(r.reports))
+	for _, rep := range r.reports {
+		reports = append(reports, *rep)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+	return reports
+}
+
+// Overall returns the worst status of the drivers.
+func (r *Registry) Overall() Status {
+	worst := OK
+	for _, rep := range r.Reports() {
+		if rep.Status > worst {
+			worst = rep.Status
+		}
+	}
+	return worst
+}
+
+// ServeHTTP serves the reports as JSON, with the status 503 when a driver
+// failed.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Overall() == Failed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(r.Reports()); err != nil {
+		glog.Errorf("health: %v", err)
+	}
+}
+
+// WritePrometheus writes the statuses in the Prometheus text format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP embd_driver_status Status of the driver: 0 ok, 1 degraded, 2 failed.\n")
+	fmt.Fprintf(&b, "# TYPE embd_driver_status gauge\n")
+	reports := r.Reports()
+	for _, rep := range reports {
+		fmt.Fprintf(&b, "embd_driver_status{driver=%q} %d\n", rep.Name, rep.Status)
+	}
+	fmt.Fprintf(&b, "# HELP embd_driver_last_error_timestamp_seconds Time of the last error of the driver.\n")
+	fmt.Fprintf(&b, "# TYPE embd_driver_last_error_timestamp_seconds gauge\n")
+	for _, rep := range reports {
+		if !rep.ErrorTime.IsZero() {
+			fmt.Fprintf(&b, "embd_driver_last_error_timestamp_seconds{driver=%q} %d\n", rep.Name, rep.ErrorTime.Unix())
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Metrics returns the handler serving the statuses to Prometheus.
+func (r *Registry) Metrics() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := r.WritePrometheus(w); err != nil {
+			glog.Errorf("health: %v", err)
+		}
+	})
+}
+
+// Page returns a status page for a character display of cols columns and
+// rows rows: the overall status, then the drivers which are not OK, the
+// worst first.
+func (r *Registry) Page(cols, rows int) string {
+	reports := r.Reports()
+	var bad []Report
+	for _, rep := range reports {
+		if rep.Status != OK {
+			bad = append(bad, rep)
+		}
+	}
+	sort.SliceStable(bad, func(i, j int) bool { return bad[i].Status > bad[j].Status })
+
+	lines := []string{fmt.Sprintf("%d/%d drivers ok", len(reports)-len(bad), len(reports))}
+	for _, rep := range bad {
+		if len(lines) == rows {
+			break
+		}
+		status := strings.ToUpper(rep.Status.String()[:4])
+		name := rep.Name
+		if width := cols - len(status) - 1; len(name) > width && width >= 0 {
+			name = name[:width]
+		}
+		lines = append(lines, fmt.Sprintf("%-*s %s", cols-len(status)-1, name, status))
+	}
+	for i, l := range lines {
+		if len(l) > cols {
+			lines[i] = l[:cols]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,71 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestObserve(t *testing.T) {
+	r := NewRegistry()
+	errRead := errors.New("read failed")
+
+	r.Observe("baro", nil)
+	r.Observe("lcd", errRead)
+	if got := r.Overall(); got != Degraded {
+		t.Errorf("got %v, want degraded", got)
+	}
+	r.Observe("lcd", errRead)
+	r.Observe("lcd", errRead)
+	reports := r.Reports()
+	if len(reports) != 2 || reports[1].Status != Failed || reports[1].Error != "read failed" || reports[1].Errors != 3 {
+		t.Errorf("got %+v, want lcd failed after 3 errors", reports)
+	}
+
+	r.Observe("lcd", nil)
+	if got := r.Overall(); got != OK {
+		t.Errorf("got %v after recovering, want ok", got)
+	}
+	if rep := r.Reports()[1]; rep.Error != "read failed" || rep.ErrorTime.IsZero() {
+		t.Errorf("last error forgotten: %+v", rep)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.Fail("lcd", errors.New("gone"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %v, want 503", w.Code)
+	}
+	var reports []Report
+	if err := json.NewDecoder(w.Body).Decode(&reports); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].Status != Failed {
+		t.Errorf("got %+v", reports)
+	}
+
+	var b strings.Builder
+	if err := r.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `embd_driver_status{driver="lcd"} 2`) {
+		t.Errorf("missing status in:\n%v", b.String())
+	}
+}
+
+func TestPage(t *testing.T) {
+	r := NewRegistry()
+	r.Observe("baro", nil)
+	r.Observe("thermometer", errors.New("nak"))
+	want := "1/2 drivers ok\nthermomete DEGR"
+	if got := r.Page(15, 2); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
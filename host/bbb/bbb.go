@@ -21,7 +21,7 @@ import (
 )
 
 var pins = embd.PinMap{
-	&embd.PinDesc{ID: "P8_07", Aliases: []string{"66", "GPIO_66", "Caps: TIMER4"}, Caps: embd.CapDigital | embd.CapGPMC, DigitalLogical: 66},
+	&embd.PinDesc{ID: "P8_07", Aliases: []string{"66", "GPIO_66", "TIMER4"}, Caps: embd.CapDigital | embd.CapGPMC, DigitalLogical: 66},
 	&embd.PinDesc{ID: "P8_08", Aliases: []string{"67", "GPIO_67", "TIMER7"}, Caps: embd.CapDigital | embd.CapGPMC, DigitalLogical: 67},
 	&embd.PinDesc{ID: "P8_09", Aliases: []string{"69", "GPIO_69", "TIMER5"}, Caps: embd.CapDigital | embd.CapGPMC, DigitalLogical: 69},
 	&embd.PinDesc{ID: "P8_10", Aliases: []string{"68", "GPIO_68", "TIMER6"}, Caps: embd.CapDigital | embd.CapGPMC, DigitalLogical: 68},
@@ -167,6 +167,14 @@ func spiInitializer() error {
 }
 
 func init() {
+	// The GPIOs are 3.3V and the analog inputs 1.8V, none 5V tolerant.
+	for _, pd := range pins {
+		pd.Level = embd.Level3V3
+		if pd.Caps == embd.CapAnalog {
+			pd.Level = embd.Level1V8
+		}
+	}
+
 	embd.Register(embd.HostBBB, func(rev int) *embd.Descriptor {
 		return &embd.Descriptor{
 			GPIODriver: func() embd.GPIODriver {
@@ -176,7 +184,7 @@ func init() {
 				return embd.NewI2CDriver(generic.NewI2CBus)
 			},
 			LEDDriver: func() embd.LEDDriver {
-				return embd.NewLEDDriver(ledMap, generic.NewLED)
+				return embd.NewLEDDriver(ledMap, generic.NewLED, generic.LEDs)
 			},
 			SPIDriver: func() embd.SPIDriver {
 				return embd.NewSPIDriver(spiDeviceMinor, generic.NewSPIBus, spiInitializer)
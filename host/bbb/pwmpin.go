@@ -35,8 +35,12 @@ type pwmPin struct {
 	drv embd.GPIODriver
 
 	period   int
+	duty     int
 	polarity embd.Polarity
 
+	// keep skips resetting the pin on init, when restoring its state.
+	keep bool
+
 	dutyf     *os.File
 	periodf   *os.File
 	polarityf *os.File
@@ -87,6 +91,9 @@ func (p *pwmPin) init() error {
 
 	p.initialized = true
 
+	if p.keep {
+		return nil
+	}
 	if err := p.reset(); err != nil {
 		return err
 	}
@@ -187,6 +194,8 @@ func (p *pwmPin) SetDuty(ns int) error {
 		return err
 	}
 
+	p.duty = ns
+
 	return nil
 }
 
@@ -259,3 +268,55 @@ func (p *pwmPin) Close() error {
 
 	return nil
 }
+
+func (p *pwmPin) State() (embd.PinState, error) {
+	if err := p.init(); err != nil {
+		return embd.PinState{}, err
+	}
+
+	return embd.PinState{
+		Direction: embd.Out,
+		Period:    p.period,
+		Duty:      p.duty,
+		Polarity:  p.polarity,
+	}, nil
+}
+
+func (p *pwmPin) RestoreState(s embd.PinState) error {
+	if !p.initialized {
+		p.keep = true
+		err := p.init()
+		p.keep = false
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := p.SetPeriod(s.Period); err != nil {
+		return err
+	}
+	if err := p.SetPolarity(s.Polarity); err != nil {
+		return err
+	}
+	return p.SetDuty(s.Duty)
+}
+
+func (p *pwmPin) Release() error {
+	if err := p.drv.Unregister(p.n); err != nil {
+		return err
+	}
+
+	if !p.initialized {
+		return nil
+	}
+
+	for _, f := range []*os.File{p.dutyf, p.periodf, p.polarityf} {
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	p.initialized = false
+
+	return nil
+}
@@ -0,0 +1,35 @@
+/*
+Package dryrun provides a host without hardware, used in dry run mode.
+
+Every pin, I²C, SPI and LED write is logged (with glog at info level) but
+not executed, and reads return zeros. Any pin, bus or LED key is accepted,
+so programs written for a real host run end-to-end unchanged:
+
+	$ EMBD_DRY_RUN=1 go run samples/hd44780.go -logtostderr
+
+See embd.SetDryRun.
+*/
+package dryrun
+
+import (
+	"github.com/kidoman/embd"
+)
+
+func init() {
+	embd.Register(embd.HostDryRun, func(rev int) *embd.Descriptor {
+		return &embd.Descriptor{
+			GPIODriver: func() embd.GPIODriver {
+				return newGPIODriver()
+			},
+			I2CDriver: func() embd.I2CDriver {
+				return embd.NewI2CDriver(newI2CBus)
+			},
+			LEDDriver: func() embd.LEDDriver {
+				return &ledDriver{}
+			},
+			SPIDriver: func() embd.SPIDriver {
+				return embd.NewSPIDriver(0, newSPIBus, nil)
+			},
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package dryrun
+
+import (
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+func TestDescribeHost(t *testing.T) {
+	embd.SetDryRun(true)
+	defer embd.SetDryRun(false)
+
+	desc, err := embd.DescribeHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.GPIODriver == nil || desc.I2CDriver == nil || desc.SPIDriver == nil || desc.LEDDriver == nil {
+		t.Fatalf("missing drivers in %+v", desc)
+	}
+}
+
+func TestGPIO(t *testing.T) {
+	d := newGPIODriver()
+	pin, err := d.DigitalPin("P9_12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pin.SetDirection(embd.Out); err != nil {
+		t.Fatal(err)
+	}
+	if err := pin.Write(embd.High); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := pin.Read(); err != nil || v != embd.Low {
+		t.Errorf("Read() = %v, %v; want 0, nil", v, err)
+	}
+	if other, _ := d.DigitalPin("P9_12"); other != pin {
+		t.Error("DigitalPin returned a new pin for an open key")
+	}
+	if _, err := d.PWMPin(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if other, _ := d.DigitalPin("P9_12"); other == pin {
+		t.Error("DigitalPin returned a closed pin")
+	}
+}
+
+func TestBuses(t *testing.T) {
+	i2c := newI2CBus(1)
+	buf := []byte{1, 2, 3}
+	if err := i2c.ReadFromReg(0x40, 0x00, buf); err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range buf {
+		if b != 0 {
+			t.Errorf("buf[%v] = %v; want 0", i, b)
+		}
+	}
+
+	spi := newSPIBus(0, 0, 0, 1000000, 8, 0, nil)
+	data, err := spi.ReceiveData(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 4 {
+		t.Errorf("len(ReceiveData(4)) = %v; want 4", len(data))
+	}
+}
@@ -0,0 +1,248 @@
+// Dry run GPIO.
+
+package dryrun
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+type gpioDriver struct {
+	mu   sync.Mutex
+	pins map[string]interface{}
+}
+
+func newGPIODriver() embd.GPIODriver {
+	return &gpioDriver{pins: make(map[string]interface{})}
+}
+
+func key(k interface{}) string {
+	return fmt.Sprint(k)
+}
+
+func (d *gpioDriver) pin(kind string, k interface{}, create func(id string) interface{}) interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := kind + ":" + key(k)
+	if p, ok := d.pins[id]; ok {
+		return p
+	}
+	p := create(id)
+	d.pins[id] = p
+	glog.Infof("dryrun: opened %v pin %v", kind, key(k))
+	return p
+}
+
+func (d *gpioDriver) Unregister(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p, ok := d.pins[id]
+	if !ok {
+		return fmt.Errorf("gpio: pin %v is not registered yet, cannot unregister", id)
+	}
+	embd.Unclaim(p)
+	delete(d.pins, id)
+	return nil
+}
+
+func (d *gpioDriver) DigitalPin(k interface{}) (embd.DigitalPin, error) {
+	return d.pin("digital", k, func(id string) interface{} {
+		return &digitalPin{id: id, key: key(k), drv: d}
+	}).(embd.DigitalPin), nil
+}
+
+func (d *gpioDriver) AnalogPin(k interface{}) (embd.AnalogPin, error) {
+	return d.pin("analog", k, func(id string) interface{} {
+		return &analogPin{id: id, drv: d}
+	}).(embd.AnalogPin), nil
+}
+
+func (d *gpioDriver) PWMPin(k interface{}) (embd.PWMPin, error) {
+	return d.pin("pwm", k, func(id string) interface{} {
+		return &pwmPin{id: id, key: key(k), drv: d, period: 20000000}
+	}).(embd.PWMPin), nil
+}
+
+func (d *gpioDriver) DigitalBus(pins []embd.DigitalPin) (embd.DigitalBus, error) {
+	keys := make([]string, len(pins))
+	for i, pin := range pins {
+		p, ok := pin.(*digitalPin)
+		if !ok {
+			return nil, fmt.Errorf("gpio: pin %v is not a dryrun pin", pin.N())
+		}
+		keys[i] = p.key
+	}
+	return digitalBus(keys), nil
+}
+
+type digitalBus []string
+
+func (b digitalBus) Write(bits uint32) error {
+	vals := make([]string, len(b))
+	for i, key := range b {
+		vals[i] = fmt.Sprintf("%v=%v", key, (bits>>uint(i))&0x01)
+	}
+	glog.Infof("dryrun: pins write %v", strings.Join(vals, " "))
+	return nil
+}
+
+func (d *gpioDriver) Close() error {
+	d.mu.Lock()
+	pins := make([]interface{}, 0, len(d.pins))
+	for _, p := range d.pins {
+		pins = append(pins, p)
+	}
+	d.mu.Unlock()
+
+	for _, p := range pins {
+		if err := p.(interface {
+			Close() error
+		}).Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type digitalPin struct {
+	id, key string
+	drv     *gpioDriver
+}
+
+func (p *digitalPin) N() int {
+	n, _ := strconv.Atoi(p.key)
+	return n
+}
+
+func (p *digitalPin) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
+	glog.Infof("dryrun: pin %v watching %v edges", p.key, edge)
+	return nil
+}
+
+func (p *digitalPin) StopWatching() error {
+	return nil
+}
+
+func (p *digitalPin) Write(val int) error {
+	glog.Infof("dryrun: pin %v write %v", p.key, val)
+	return nil
+}
+
+func (p *digitalPin) Read() (int, error) {
+	return embd.Low, nil
+}
+
+func (p *digitalPin) TimePulse(state int) (time.Duration, error) {
+	return 0, nil
+}
+
+func (p *digitalPin) SetDirection(dir embd.Direction) error {
+	str := "in"
+	if dir == embd.Out {
+		str = "out"
+	}
+	glog.Infof("dryrun: pin %v direction %v", p.key, str)
+	return nil
+}
+
+func (p *digitalPin) ActiveLow(b bool) error {
+	glog.Infof("dryrun: pin %v active low %v", p.key, b)
+	return nil
+}
+
+func (p *digitalPin) PullUp() error {
+	glog.Infof("dryrun: pin %v pull up", p.key)
+	return nil
+}
+
+func (p *digitalPin) PullDown() error {
+	glog.Infof("dryrun: pin %v pull down", p.key)
+	return nil
+}
+
+func (p *digitalPin) SetDebounce(d time.Duration) error {
+	glog.Infof("dryrun: pin %v debounce %v", p.key, d)
+	return nil
+}
+
+func (p *digitalPin) SetOutputMode(mode embd.OutputMode) error {
+	glog.Infof("dryrun: pin %v output mode %v", p.key, mode)
+	return nil
+}
+
+func (p *digitalPin) SetDriveStrength(mA int) error {
+	glog.Infof("dryrun: pin %v drive strength %vmA", p.key, mA)
+	return nil
+}
+
+func (p *digitalPin) SetSlewRate(slow bool) error {
+	glog.Infof("dryrun: pin %v slow slew rate %v", p.key, slow)
+	return nil
+}
+
+func (p *digitalPin) Close() error {
+	return p.drv.Unregister(p.id)
+}
+
+type analogPin struct {
+	id  string
+	drv *gpioDriver
+}
+
+func (p *analogPin) N() int {
+	return 0
+}
+
+func (p *analogPin) Read() (int, error) {
+	return 0, nil
+}
+
+func (p *analogPin) Close() error {
+	return p.drv.Unregister(p.id)
+}
+
+type pwmPin struct {
+	id, key string
+	drv     *gpioDriver
+	period  int
+}
+
+func (p *pwmPin) N() string {
+	return p.key
+}
+
+func (p *pwmPin) SetPeriod(ns int) error {
+	glog.Infof("dryrun: pwm %v period %vns", p.key, ns)
+	p.period = ns
+	return nil
+}
+
+func (p *pwmPin) SetDuty(ns int) error {
+	glog.Infof("dryrun: pwm %v duty %vns", p.key, ns)
+	return nil
+}
+
+func (p *pwmPin) SetPolarity(pol embd.Polarity) error {
+	glog.Infof("dryrun: pwm %v polarity %v", p.key, pol)
+	return nil
+}
+
+func (p *pwmPin) SetMicroseconds(us int) error {
+	return p.SetDuty(us * 1000)
+}
+
+func (p *pwmPin) SetAnalog(value byte) error {
+	return p.SetDuty(int(value) * p.period / 255)
+}
+
+func (p *pwmPin) Close() error {
+	return p.drv.Unregister(p.id)
+}
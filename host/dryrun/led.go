@@ -0,0 +1,99 @@
+// Dry run LEDs.
+
+package dryrun
+
+import (
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+type ledDriver struct {
+	mu   sync.Mutex
+	leds []*led
+}
+
+func (d *ledDriver) LED(k interface{}) (embd.LED, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	l := &led{key: key(k)}
+	d.leds = append(d.leds, l)
+	return l, nil
+}
+
+func (d *ledDriver) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, l := range d.leds {
+		l.Close()
+	}
+	d.leds = nil
+	return nil
+}
+
+type led struct {
+	mu  sync.Mutex
+	key string
+	on  bool
+
+	trigger string
+}
+
+func (l *led) set(on bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.on = on
+	glog.Infof("dryrun: led %v on %v", l.key, on)
+	return nil
+}
+
+func (l *led) On() error {
+	return l.set(true)
+}
+
+func (l *led) Off() error {
+	return l.set(false)
+}
+
+func (l *led) Toggle() error {
+	l.mu.Lock()
+	on := !l.on
+	l.mu.Unlock()
+	return l.set(on)
+}
+
+func (l *led) Trigger() (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.trigger == "" {
+		return embd.LEDTriggerNone, nil
+	}
+	return l.trigger, nil
+}
+
+func (l *led) Triggers() ([]string, error) {
+	return []string{embd.LEDTriggerNone, embd.LEDTriggerHeartbeat, embd.LEDTriggerNetdev}, nil
+}
+
+func (l *led) SetTrigger(trigger string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.trigger = trigger
+	glog.Infof("dryrun: led %v trigger %v", l.key, trigger)
+	return nil
+}
+
+func (l *led) SetTriggerParam(name, value string) error {
+	glog.Infof("dryrun: led %v trigger %v = %v", l.key, name, value)
+	return nil
+}
+
+func (l *led) Close() error {
+	return nil
+}
@@ -5,16 +5,22 @@
 package generic
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/kidoman/embd"
 )
 
+// gpioClassPath is where the kernel exposes the GPIOs.
+var gpioClassPath = "/sys/class/gpio"
+
 type digitalPin struct {
 	id string
 	n  int
@@ -27,7 +33,18 @@ type digitalPin struct {
 
 	readBuf []byte
 
+	// mode is the output mode, emulated by switching the direction of the
+	// pin as the kernel does not expose it. inverted follows active_low.
+	mode     embd.OutputMode
+	inverted bool
+
+	// debounce is how long the pin must be stable for a change to be
+	// reported to the Watch handler. The edge is the one watched.
+	debounce time.Duration
+	edge     embd.Edge
+
 	initialized bool
+	closed      bool
 }
 
 func NewDigitalPin(pd *embd.PinDesc, drv embd.GPIODriver) embd.DigitalPin {
@@ -56,6 +73,11 @@ func (p *digitalPin) init() error {
 	if p.activeLow, err = p.activeLowFile(); err != nil {
 		return err
 	}
+	activeLow, err := p.readFile(p.activeLow)
+	if err != nil {
+		return err
+	}
+	p.inverted = activeLow == "1"
 
 	p.initialized = true
 
@@ -63,7 +85,11 @@ func (p *digitalPin) init() error {
 }
 
 func (p *digitalPin) export() error {
-	exporter, err := os.OpenFile("/sys/class/gpio/export", os.O_WRONLY, os.ModeExclusive)
+	// The pin stays exported when released by a previous process.
+	if _, err := os.Stat(p.basePath()); err == nil {
+		return nil
+	}
+	exporter, err := os.OpenFile(path.Join(gpioClassPath, "export"), os.O_WRONLY, os.ModeExclusive)
 	if err != nil {
 		return err
 	}
@@ -73,7 +99,7 @@ func (p *digitalPin) export() error {
 }
 
 func (p *digitalPin) unexport() error {
-	unexporter, err := os.OpenFile("/sys/class/gpio/unexport", os.O_WRONLY, os.ModeExclusive)
+	unexporter, err := os.OpenFile(path.Join(gpioClassPath, "unexport"), os.O_WRONLY, os.ModeExclusive)
 	if err != nil {
 		return err
 	}
@@ -83,7 +109,7 @@ func (p *digitalPin) unexport() error {
 }
 
 func (p *digitalPin) basePath() string {
-	return fmt.Sprintf("/sys/class/gpio/gpio%v", p.n)
+	return path.Join(gpioClassPath, fmt.Sprintf("gpio%v", p.n))
 }
 
 func (p *digitalPin) openFile(path string) (*os.File, error) {
@@ -102,11 +128,22 @@ func (p *digitalPin) activeLowFile() (*os.File, error) {
 	return p.openFile(path.Join(p.basePath(), "active_low"))
 }
 
+// SetDirection sets the direction of the pin. In the open drain and open
+// source output modes, the direction follows the written values instead:
+// setting the pin as an input releases the line, and setting it as an output
+// leaves it released until the next write.
 func (p *digitalPin) SetDirection(dir embd.Direction) error {
 	if err := p.init(); err != nil {
 		return err
 	}
 
+	if p.mode != embd.PushPull {
+		if dir == embd.Out {
+			return nil
+		}
+		return p.release()
+	}
+
 	str := "in"
 	if dir == embd.Out {
 		str = "out"
@@ -152,10 +189,70 @@ func (p *digitalPin) Write(val int) error {
 		return err
 	}
 
+	if p.mode != embd.PushPull {
+		return p.drive(val)
+	}
 	return p.write(val)
 }
 
+// drive emulates the open drain and open source output modes: the pin is
+// made an output at the level it drives, and an input to release the line
+// otherwise. Writing the level along with the direction avoids glitching
+// through the previous value of the pin.
+func (p *digitalPin) drive(val int) error {
+	high := val == embd.High
+	if p.inverted {
+		high = !high
+	}
+	switch {
+	case p.mode == embd.OpenDrain && !high:
+		_, err := p.dir.WriteString("low")
+		return err
+	case p.mode == embd.OpenSource && high:
+		_, err := p.dir.WriteString("high")
+		return err
+	}
+	return p.release()
+}
+
+func (p *digitalPin) release() error {
+	_, err := p.dir.WriteString("in")
+	return err
+}
+
+// SetOutputMode sets the output mode of the pin. The open drain and open
+// source modes release the line until the next write.
+func (p *digitalPin) SetOutputMode(mode embd.OutputMode) error {
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	p.mode = mode
+	if mode == embd.PushPull {
+		return nil
+	}
+	return p.release()
+}
+
+// SetDriveStrength is not supported, the kernel does not expose the drive
+// strength of the pins through sysfs.
+func (p *digitalPin) SetDriveStrength(mA int) error {
+	return embd.ErrFeatureNotSupported
+}
+
+// SetSlewRate is not supported, the kernel does not expose the slew rate
+// of the pins through sysfs.
+func (p *digitalPin) SetSlewRate(slow bool) error {
+	return embd.ErrFeatureNotSupported
+}
+
 func (p *digitalPin) TimePulse(state int) (time.Duration, error) {
+	return p.TimePulseContext(context.Background(), state)
+}
+
+// TimePulseContext measures the duration of a pulse, giving up once ctx is
+// done.
+func (p *digitalPin) TimePulseContext(ctx context.Context, state int) (time.Duration, error) {
 	if err := p.init(); err != nil {
 		return 0, err
 	}
@@ -165,42 +262,37 @@ func (p *digitalPin) TimePulse(state int) (time.Duration, error) {
 		aroundState = embd.High
 	}
 
-	// Wait for any previous pulse to end
-	for {
-		v, err := p.read()
-		if err != nil {
-			return 0, err
+	// wait reads the pin until it is at the level v.
+	wait := func(v int) error {
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			cur, err := p.read()
+			if err != nil {
+				return err
+			}
+			if cur == v {
+				return nil
+			}
 		}
+	}
 
-		if v == aroundState {
-			break
-		}
+	// Wait for any previous pulse to end
+	if err := wait(aroundState); err != nil {
+		return 0, err
 	}
 
 	// Wait until ECHO goes high
-	for {
-		v, err := p.read()
-		if err != nil {
-			return 0, err
-		}
-
-		if v == state {
-			break
-		}
+	if err := wait(state); err != nil {
+		return 0, err
 	}
 
 	startTime := time.Now() // Record time when ECHO goes high
 
 	// Wait until ECHO goes low
-	for {
-		v, err := p.read()
-		if err != nil {
-			return 0, err
-		}
-
-		if v == aroundState {
-			break
-		}
+	if err := wait(aroundState); err != nil {
+		return 0, err
 	}
 
 	return time.Since(startTime), nil // Calculate time lapsed for ECHO to transition from high to low
@@ -215,8 +307,11 @@ func (p *digitalPin) ActiveLow(b bool) error {
 	if b {
 		str = "1"
 	}
-	_, err := p.activeLow.WriteString(str)
-	return err
+	if _, err := p.activeLow.WriteString(str); err != nil {
+		return err
+	}
+	p.inverted = b
+	return nil
 }
 
 func (p *digitalPin) PullUp() error {
@@ -227,35 +322,120 @@ func (p *digitalPin) PullDown() error {
 	return errors.New("gpio: not implemented")
 }
 
+// Close stops watching the pin, closes its files and unexports it, carrying
+// on when a step fails. Closing it again does nothing.
 func (p *digitalPin) Close() error {
-	if err := p.StopWatching(); err != nil {
-		return err
+	if p.closed {
+		return nil
 	}
 
-	if err := p.drv.Unregister(p.id); err != nil {
-		return err
+	errs := p.closeFiles()
+	if p.initialized {
+		if err := p.unexport(); err != nil {
+			errs = append(errs, err)
+		}
+		p.initialized = false
 	}
 
+	return errors.Join(errs...)
+}
+
+// closeFiles stops watching the pin, unregisters it and closes its files,
+// carrying on when a step fails, and returns the errors.
+func (p *digitalPin) closeFiles() []error {
+	p.closed = true
+
+	var errs []error
+	if err := p.StopWatching(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := p.drv.Unregister(p.id); err != nil {
+		errs = append(errs, err)
+	}
 	if !p.initialized {
-		return nil
+		return errs
 	}
+	for _, f := range []*os.File{p.dir, p.val, p.activeLow} {
+		if err := f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
 
-	if err := p.dir.Close(); err != nil {
-		return err
+func (p *digitalPin) readFile(f *os.File) (string, error) {
+	buf := make([]byte, 16)
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return "", err
 	}
-	if err := p.val.Close(); err != nil {
-		return err
+	return strings.TrimSpace(string(buf[:n])), nil
+}
+
+func (p *digitalPin) State() (embd.PinState, error) {
+	var s embd.PinState
+	if err := p.init(); err != nil {
+		return s, err
 	}
-	if err := p.activeLow.Close(); err != nil {
-		return err
+
+	dir, err := p.readFile(p.dir)
+	if err != nil {
+		return s, err
+	}
+	if dir == "out" {
+		s.Direction = embd.Out
 	}
-	if err := p.unexport(); err != nil {
+	activeLow, err := p.readFile(p.activeLow)
+	if err != nil {
+		return s, err
+	}
+	s.ActiveLow = activeLow == "1"
+	if s.Value, err = p.read(); err != nil {
+		return s, err
+	}
+	s.OutputMode = p.mode
+
+	return s, nil
+}
+
+func (p *digitalPin) RestoreState(s embd.PinState) error {
+	if err := p.ActiveLow(s.ActiveLow); err != nil {
 		return err
 	}
+	if s.OutputMode != embd.PushPull {
+		p.mode = s.OutputMode
+		return p.drive(s.Value)
+	}
+	p.mode = embd.PushPull
+	if s.Direction != embd.Out {
+		return p.SetDirection(embd.In)
+	}
 
+	// Writing high or low sets the direction and the initial (raw) value
+	// at once.
+	high := s.Value == embd.High
+	if s.ActiveLow {
+		high = !high
+	}
+	str := "low"
+	if high {
+		str = "high"
+	}
+	_, err := p.dir.WriteString(str)
+	return err
+}
+
+// Release closes the pin, leaving it exported as it is. Releasing or
+// closing it again does nothing.
+func (p *digitalPin) Release() error {
+	if p.closed {
+		return nil
+	}
+
+	errs := p.closeFiles()
 	p.initialized = false
 
-	return nil
+	return errors.Join(errs...)
 }
 
 func (p *digitalPin) setEdge(edge embd.Edge) error {
@@ -269,13 +449,50 @@ func (p *digitalPin) setEdge(edge embd.Edge) error {
 	return err
 }
 
+// kernelEdge returns the edge the kernel reports: both edges when the pin is
+// debounced, as the stable value is compared to the previous one.
+func (p *digitalPin) kernelEdge() embd.Edge {
+	if p.debounce > 0 && p.edge != embd.EdgeNone {
+		return embd.EdgeBoth
+	}
+	return p.edge
+}
+
 func (p *digitalPin) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
-	if err := p.setEdge(edge); err != nil {
+	if err := p.init(); err != nil {
 		return err
 	}
-	return registerInterrupt(p, handler)
+
+	p.edge = edge
+	if err := p.setEdge(p.kernelEdge()); err != nil {
+		return err
+	}
+	return registerInterrupt(p, edge, handler)
 }
 
 func (p *digitalPin) StopWatching() error {
+	if !p.initialized {
+		return nil
+	}
+
+	p.edge = ""
 	return unregisterInterrupt(p)
 }
+
+// SetDebounce debounces the pin in software, the kernel sysfs interface not
+// exposing the debounce support of the GPIO controllers.
+func (p *digitalPin) SetDebounce(d time.Duration) error {
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	p.debounce = d
+	if p.edge == "" {
+		return nil
+	}
+	if err := p.setEdge(p.kernelEdge()); err != nil {
+		return err
+	}
+	debounceInterrupt(p, d)
+	return nil
+}
@@ -1,9 +1,17 @@
 package generic
 
 import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
 )
 
 func TestDigitalPinClose(t *testing.T) {
@@ -24,3 +32,218 @@ func TestDigitalPinClose(t *testing.T) {
 		t.Fatal("Looking up closed digital pin 1: but got the old instance")
 	}
 }
+
+func TestDigitalPinOpenDrain(t *testing.T) {
+	defer fakeSysfs(t)()
+
+	pinMap := embd.PinMap{
+		&embd.PinDesc{ID: "P1_11", Aliases: []string{"17"}, Caps: embd.CapDigital, DigitalLogical: 17},
+	}
+	driver := embd.NewGPIODriver(pinMap, NewDigitalPin, nil, nil)
+	pin, err := driver.DigitalPin(17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pin.(embd.StatefulPin).Release()
+
+	// The fake direction file keeps all the writes, the last one is the
+	// current direction.
+	direction := func() string {
+		s := readFile(t, "gpio/gpio17/direction")
+		for _, d := range []string{"low", "high", "in", "out"} {
+			if strings.HasSuffix(s, d) {
+				return d
+			}
+		}
+		return s
+	}
+
+	dp := pin.(embd.DrivePin)
+	if err := dp.SetOutputMode(embd.OpenDrain); err != nil {
+		t.Fatal(err)
+	}
+	if d := direction(); d != "in" {
+		t.Errorf("direction after SetOutputMode: got %q, want released", d)
+	}
+	for _, step := range []struct {
+		val  int
+		want string
+	}{{embd.Low, "low"}, {embd.High, "in"}, {embd.Low, "low"}} {
+		if err := pin.Write(step.val); err != nil {
+			t.Fatal(err)
+		}
+		if d := direction(); d != step.want {
+			t.Errorf("direction after writing %v: got %q, want %q", step.val, d, step.want)
+		}
+	}
+
+	// Active low pins drive the line low for a high value.
+	if err := pin.ActiveLow(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := pin.Write(embd.High); err != nil {
+		t.Fatal(err)
+	}
+	if d := direction(); d != "low" {
+		t.Errorf("direction after writing high active low: got %q, want low", d)
+	}
+
+	if err := dp.SetOutputMode(embd.OpenSource); err != nil {
+		t.Fatal(err)
+	}
+	if err := pin.Write(embd.Low); err != nil {
+		t.Fatal(err)
+	}
+	if d := direction(); d != "high" {
+		t.Errorf("open source direction after writing low active low: got %q, want high", d)
+	}
+
+	if err := dp.SetDriveStrength(8); err != embd.ErrFeatureNotSupported {
+		t.Errorf("SetDriveStrength: got %v, want %v", err, embd.ErrFeatureNotSupported)
+	}
+}
+
+func TestInterruptDebounce(t *testing.T) {
+	defer fakeSysfs(t)()
+
+	pinMap := embd.PinMap{
+		&embd.PinDesc{ID: "P1_11", Aliases: []string{"17"}, Caps: embd.CapDigital, DigitalLogical: 17},
+	}
+	driver := embd.NewGPIODriver(pinMap, NewDigitalPin, nil, nil)
+	pin, err := driver.DigitalPin(17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pin.(embd.StatefulPin).Release()
+	p := pin.(*digitalPin)
+	if err := p.init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The debouncing is timed by a virtual clock, settling the pin at once.
+	c := clock.NewVirtual(time.Time{})
+	defer func(saved clock.Clock) { Clock = saved }(Clock)
+	Clock = c
+
+	calls := make(chan int, 10)
+	irq := &interrupt{
+		pin:            p,
+		edge:           embd.EdgeRising,
+		initialTrigger: true,
+		handler: func(pin embd.DigitalPin) {
+			v, _ := pin.Read()
+			calls <- v
+		},
+		debounce: 20 * time.Millisecond,
+	}
+	defer irq.stop()
+
+	setValue := func(v string) {
+		if err := ioutil.WriteFile(path.Join(gpioClassPath, "gpio17/value"), []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bounce := func(final string) {
+		for _, v := range []string{"1", "0", "1", "0", final} {
+			setValue(v)
+			irq.Signal()
+			c.Advance(2 * time.Millisecond)
+		}
+	}
+	expect := func(want []int) {
+		c.Advance(60 * time.Millisecond)
+		var got []int
+		for len(calls) > 0 {
+			got = append(got, <-calls)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("handler calls: got %v, want %v", got, want)
+		}
+	}
+
+	// A bouncing press is reported once, and the release is not reported
+	// when watching the rising edge.
+	bounce("1")
+	expect([]int{1})
+	bounce("0")
+	expect(nil)
+
+	// Bounces settling back to the same value are not reported.
+	bounce("0")
+	expect(nil)
+
+	if err := pin.SetDebounce(0); err != nil {
+		t.Fatal(err)
+	}
+	irq.setDebounce(0)
+	irq.Signal()
+	expect([]int{0})
+
+	// The level is recorded without debouncing, so that a press seen then
+	// is not reported again once debounced.
+	setValue("1")
+	irq.Signal()
+	expect([]int{1})
+	irq.setDebounce(20 * time.Millisecond)
+	bounce("1")
+	expect(nil)
+
+	// A settling due as the pin stops being watched is dropped.
+	bounce("0")
+	expect(nil)
+	setValue("1")
+	irq.Signal()
+	irq.stop()
+	irq.settled()
+	irq.Signal()
+	expect(nil)
+}
+
+func TestDigitalPinClose_twice(t *testing.T) {
+	defer fakeSysfs(t)()
+
+	pinMap := embd.PinMap{
+		&embd.PinDesc{ID: "P1_11", Aliases: []string{"17"}, Caps: embd.CapDigital, DigitalLogical: 17},
+	}
+	driver := embd.NewGPIODriver(pinMap, NewDigitalPin, nil, nil)
+	pin, err := driver.DigitalPin(17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pin.SetDirection(embd.Out); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pin.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if err := pin.Close(); err != nil {
+		t.Errorf("second Close() = %v; want nil", err)
+	}
+	if err := pin.(embd.StatefulPin).Release(); err != nil {
+		t.Errorf("Release() after Close() = %v; want nil", err)
+	}
+}
+
+func TestBusClose_twice(t *testing.T) {
+	open := func() *os.File {
+		f, err := ioutil.TempFile("", "bus")
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(f.Name())
+		return f
+	}
+
+	for name, bus := range map[string]io.Closer{
+		"i2c": &i2cBus{file: open(), addr: 0x27, initialized: true},
+		"spi": &spiBus{file: open(), initialized: true},
+	} {
+		if err := bus.Close(); err != nil {
+			t.Fatalf("%v: Close() = %v", name, err)
+		}
+		if err := bus.Close(); err != nil {
+			t.Errorf("%v: second Close() = %v; want nil", name, err)
+		}
+	}
+}
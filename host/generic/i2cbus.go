@@ -18,8 +18,9 @@ import (
 const (
 	delay = 20
 
-	slaveCmd = 0x0703 // Cmd to set slave address
-	rdrwCmd  = 0x0707 // Cmd to read/write data together
+	timeoutCmd = 0x0702 // Cmd to set the transfer timeout, in 10ms
+	slaveCmd   = 0x0703 // Cmd to set slave address
+	rdrwCmd    = 0x0707 // Cmd to read/write data together
 
 	rd = 0x0001
 )
@@ -66,11 +67,17 @@ func (b *i2cBus) init() error {
 	return nil
 }
 
+// error returns err as an embd.BusError of the bus, for its kind to be
+// known.
+func (b *i2cBus) error(op string, err error) error {
+	return embd.NewBusError(fmt.Sprintf("i2c-%v", b.l), op, err)
+}
+
 func (b *i2cBus) setAddress(addr byte) error {
 	if addr != b.addr {
 		glog.V(2).Infof("i2c: setting bus %v address to %#02x", b.l, addr)
 		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), slaveCmd, uintptr(addr)); errno != 0 {
-			return syscall.Errno(errno)
+			return b.error("SetAddress", syscall.Errno(errno))
 		}
 
 		b.addr = addr
@@ -79,6 +86,23 @@ func (b *i2cBus) setAddress(addr byte) error {
 	return nil
 }
 
+// SetTimeout sets how long the kernel waits for a transfer, rounded up to
+// 10ms.
+func (b *i2cBus) SetTimeout(d time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.init(); err != nil {
+		return err
+	}
+
+	jiffies := (d + 10*time.Millisecond - 1) / (10 * time.Millisecond)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), timeoutCmd, uintptr(jiffies)); errno != 0 {
+		return b.error("SetTimeout", syscall.Errno(errno))
+	}
+	return nil
+}
+
 func (b *i2cBus) ReadByte(addr byte) (byte, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -92,7 +116,10 @@ func (b *i2cBus) ReadByte(addr byte) (byte, error) {
 	}
 
 	bytes := make([]byte, 1)
-	n, _ := b.file.Read(bytes)
+	n, err := b.file.Read(bytes)
+	if err != nil {
+		return 0, b.error("ReadByte", err)
+	}
 
 	if n != 1 {
 		return 0, fmt.Errorf("i2c: Unexpected number (%v) of bytes read", n)
@@ -114,6 +141,9 @@ func (b *i2cBus) WriteByte(addr, value byte) error {
 	}
 
 	n, err := b.file.Write([]byte{value})
+	if err != nil {
+		return b.error("WriteByte", err)
+	}
 
 	if n != 1 {
 		err = fmt.Errorf("i2c: Unexpected number (%v) of bytes written in WriteByte", n)
@@ -136,13 +166,13 @@ func (b *i2cBus) WriteBytes(addr byte, value []byte) error {
 
 	for i := range value {
 		n, err := b.file.Write([]byte{value[i]})
+		if err != nil {
+			return b.error("WriteBytes", err)
+		}
 
 		if n != 1 {
 			return fmt.Errorf("i2c: Unexpected number (%v) of bytes written in WriteBytes", n)
 		}
-		if err != nil {
-			return err
-		}
 
 		time.Sleep(delay * time.Millisecond)
 	}
@@ -150,6 +180,38 @@ func (b *i2cBus) WriteBytes(addr byte, value []byte) error {
 	return nil
 }
 
+// WriteTransfer implements embd.I2CTransferWriter, writing the bytes in a
+// single I2C_RDWR transfer without the delays of WriteBytes.
+func (b *i2cBus) WriteTransfer(addr byte, value []byte) error {
+	if len(value) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.init(); err != nil {
+		return err
+	}
+
+	var message i2c_msg
+	message.addr = uint16(addr)
+	message.flags = 0
+	message.len = uint16(len(value))
+	message.buf = uintptr(unsafe.Pointer(&value[0]))
+
+	var packets i2c_rdwr_ioctl_data
+
+	packets.msgs = uintptr(unsafe.Pointer(&message))
+	packets.nmsg = 1
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), rdrwCmd, uintptr(unsafe.Pointer(&packets))); errno != 0 {
+		return b.error("WriteTransfer", syscall.Errno(errno))
+	}
+
+	return nil
+}
+
 func (b *i2cBus) ReadFromReg(addr, reg byte, value []byte) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -181,7 +243,7 @@ func (b *i2cBus) ReadFromReg(addr, reg byte, value []byte) error {
 	packets.nmsg = 2
 
 	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), rdrwCmd, uintptr(unsafe.Pointer(&packets))); errno != 0 {
-		return syscall.Errno(errno)
+		return b.error("ReadFromReg", syscall.Errno(errno))
 	}
 
 	return nil
@@ -231,7 +293,7 @@ func (b *i2cBus) WriteToReg(addr, reg byte, value []byte) error {
 	packets.nmsg = 1
 
 	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), rdrwCmd, uintptr(unsafe.Pointer(&packets))); errno != 0 {
-		return syscall.Errno(errno)
+		return b.error("WriteToReg", syscall.Errno(errno))
 	}
 
 	return nil
@@ -266,7 +328,7 @@ func (b *i2cBus) WriteByteToReg(addr, reg, value byte) error {
 	packets.nmsg = 1
 
 	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), rdrwCmd, uintptr(unsafe.Pointer(&packets))); errno != 0 {
-		return syscall.Errno(errno)
+		return b.error("WriteByteToReg", syscall.Errno(errno))
 	}
 
 	return nil
@@ -302,12 +364,13 @@ func (b *i2cBus) WriteWordToReg(addr, reg byte, value uint16) error {
 	packets.nmsg = 1
 
 	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, b.file.Fd(), rdrwCmd, uintptr(unsafe.Pointer(&packets))); errno != 0 {
-		return syscall.Errno(errno)
+		return b.error("WriteWordToReg", syscall.Errno(errno))
 	}
 
 	return nil
 }
 
+// Close closes the device of the bus. Closing it again does nothing.
 func (b *i2cBus) Close() error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -316,5 +379,10 @@ func (b *i2cBus) Close() error {
 		return nil
 	}
 
-	return b.file.Close()
+	// The bus is opened again when it is used after being closed.
+	b.initialized = false
+	b.addr = 0
+	err := b.file.Close()
+	b.file = nil
+	return err
 }
@@ -7,8 +7,11 @@ import (
 	"fmt"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/golang/glog"
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
 )
 
 const (
@@ -17,18 +20,107 @@ const (
 
 var ErrorPinAlreadyRegistered = errors.New("pin interrupt already registered")
 
+// Clock times the software debouncing of the pins. Tests set it to a
+// clock.Virtual to settle the pins without waiting.
+var Clock = clock.Real
+
 type interrupt struct {
-	pin            embd.DigitalPin
+	pin            *digitalPin
+	edge           embd.Edge
 	initialTrigger bool
 	handler        func(embd.DigitalPin)
+
+	// The pin is debounced in software: the kernel reports both edges, and
+	// the handler is called once the pin has been stable for debounce and
+	// has changed from the last stable value as watched. last follows the
+	// pin when it is not debounced, for the debouncing to start from its
+	// current level. stopped drops a settling already due once the pin is
+	// no longer watched.
+	mu       sync.Mutex
+	debounce time.Duration
+	settle   clock.Timer
+	last     int
+	stopped  bool
 }
 
 func (i *interrupt) Signal() {
+	i.mu.Lock()
+	if i.stopped {
+		i.mu.Unlock()
+		return
+	}
+	if i.debounce <= 0 || !i.initialTrigger {
+		if v, err := i.pin.read(); err == nil {
+			i.last = v
+		}
+	}
 	if !i.initialTrigger {
 		i.initialTrigger = true
+		i.mu.Unlock()
+		return
+	}
+	if i.debounce <= 0 {
+		i.mu.Unlock()
+		i.handler(i.pin)
+		return
+	}
+	defer i.mu.Unlock()
+
+	if i.settle == nil {
+		i.settle = Clock.AfterFunc(i.debounce, i.settled)
+	} else {
+		i.settle.Reset(i.debounce)
+	}
+}
+
+func (i *interrupt) settled() {
+	v, err := i.pin.read()
+	if err != nil {
+		glog.Errorf("gpio: reading debounced pin %v: %v", i.pin.n, err)
+		return
+	}
+
+	i.mu.Lock()
+	if i.stopped {
+		i.mu.Unlock()
+		return
+	}
+	changed := v != i.last
+	i.last = v
+	i.mu.Unlock()
+
+	if !changed {
 		return
 	}
-	i.handler(i.pin)
+	switch {
+	case i.edge == embd.EdgeRising && v != embd.High:
+	case i.edge == embd.EdgeFalling && v != embd.Low:
+	default:
+		i.handler(i.pin)
+	}
+}
+
+func (i *interrupt) setDebounce(d time.Duration) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.debounce = d
+	if d <= 0 && i.settle != nil {
+		i.settle.Stop()
+		i.settle = nil
+	}
+}
+
+// stop cancels the pending settling, and drops the one already due.
+func (i *interrupt) stop() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.stopped = true
+	if i.settle != nil {
+		i.settle.Stop()
+		i.settle = nil
+	}
 }
 
 type ePollListener struct {
@@ -71,7 +163,7 @@ func initEPollListener() *ePollListener {
 	return listener
 }
 
-func registerInterrupt(pin *digitalPin, handler func(embd.DigitalPin)) error {
+func registerInterrupt(pin *digitalPin, edge embd.Edge, handler func(embd.DigitalPin)) error {
 	l := getEPollListenerInstance()
 
 	pinFd := int(pin.val.Fd())
@@ -83,6 +175,11 @@ func registerInterrupt(pin *digitalPin, handler func(embd.DigitalPin)) error {
 		return ErrorPinAlreadyRegistered
 	}
 
+	last, err := pin.read()
+	if err != nil {
+		return err
+	}
+
 	var event syscall.EpollEvent
 	event.Events = syscall.EPOLLIN | (syscall.EPOLLET & 0xffffffff) | syscall.EPOLLPRI
 
@@ -96,11 +193,24 @@ func registerInterrupt(pin *digitalPin, handler func(embd.DigitalPin)) error {
 		return err
 	}
 
-	l.interruptablePins[pinFd] = &interrupt{pin: pin, handler: handler}
+	l.interruptablePins[pinFd] = &interrupt{pin: pin, edge: edge, handler: handler, debounce: pin.debounce, last: last}
 
 	return nil
 }
 
+// debounceInterrupt changes the debounce time of the interrupt of the pin, if
+// it is being watched.
+func debounceInterrupt(pin *digitalPin, d time.Duration) {
+	l := getEPollListenerInstance()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if irq, ok := l.interruptablePins[int(pin.val.Fd())]; ok {
+		irq.setDebounce(d)
+	}
+}
+
 func unregisterInterrupt(pin *digitalPin) error {
 	l := getEPollListenerInstance()
 
@@ -109,9 +219,11 @@ func unregisterInterrupt(pin *digitalPin) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	if _, ok := l.interruptablePins[pinFd]; !ok {
+	irq, ok := l.interruptablePins[pinFd]
+	if !ok {
 		return nil
 	}
+	irq.stop()
 
 	if err := syscall.EpollCtl(l.epollFd, syscall.EPOLL_CTL_DEL, pinFd, nil); err != nil {
 		return err
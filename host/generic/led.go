@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/kidoman/embd"
 )
 
+// ledClassPath is where the kernel exposes the LEDs.
+var ledClassPath = "/sys/class/leds"
+
 type led struct {
 	id string
 
@@ -27,6 +31,9 @@ func (l *led) init() error {
 	if l.initialized {
 		return nil
 	}
+	if l.id == "" || l.id == "." || l.id == ".." || strings.Contains(l.id, "/") {
+		return fmt.Errorf("embd: invalid led %q", l.id)
+	}
 
 	var err error
 	if l.brightness, err = l.brightnessFile(); err != nil {
@@ -38,8 +45,12 @@ func (l *led) init() error {
 	return nil
 }
 
+func (l *led) filePath(name string) string {
+	return path.Join(ledClassPath, l.id, name)
+}
+
 func (l *led) brightnessFilePath() string {
-	return fmt.Sprintf("/sys/class/leds/%v/brightness", l.id)
+	return l.filePath("brightness")
 }
 
 func (l *led) openFile(path string) (*os.File, error) {
@@ -111,3 +122,56 @@ func (l *led) Close() error {
 
 	return nil
 }
+
+// triggers reads the available triggers, the active one being in brackets.
+func (l *led) triggers() ([]string, string, error) {
+	data, err := ioutil.ReadFile(l.filePath("trigger"))
+	if err != nil {
+		return nil, "", err
+	}
+	var triggers []string
+	var active string
+	for _, t := range strings.Fields(string(data)) {
+		if strings.HasPrefix(t, "[") && strings.HasSuffix(t, "]") {
+			t = t[1 : len(t)-1]
+			active = t
+		}
+		triggers = append(triggers, t)
+	}
+	return triggers, active, nil
+}
+
+func (l *led) Trigger() (string, error) {
+	_, active, err := l.triggers()
+	return active, err
+}
+
+func (l *led) Triggers() ([]string, error) {
+	triggers, _, err := l.triggers()
+	return triggers, err
+}
+
+func (l *led) SetTrigger(trigger string) error {
+	return ioutil.WriteFile(l.filePath("trigger"), []byte(trigger), 0644)
+}
+
+func (l *led) SetTriggerParam(name, value string) error {
+	if strings.ContainsRune(name, '/') {
+		return fmt.Errorf("embd: invalid led trigger parameter %q", name)
+	}
+	return ioutil.WriteFile(l.filePath(name), []byte(value), 0644)
+}
+
+// LEDs returns the names of the LEDs exposed by the kernel, which can all be
+// passed to embd.NewLED.
+func LEDs() ([]string, error) {
+	infos, err := ioutil.ReadDir(ledClassPath)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, nil
+}
@@ -0,0 +1,362 @@
+// PWM support through the sysfs PWM class.
+
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/util"
+)
+
+const (
+	// PWMDefaultPeriod is the period (500000ns, 2000 Hz) set on channels
+	// which have none yet.
+	PWMDefaultPeriod = 500000
+
+	// exportTimeout is how long to wait for the channel to appear (and its
+	// permissions to be set by udev) once exported.
+	exportTimeout = 500 * time.Millisecond
+)
+
+// pwmClassPath is where the kernel exposes the PWM chips.
+var pwmClassPath = "/sys/class/pwm"
+
+type pwmPin struct {
+	id string
+
+	chip, channel int
+
+	drv embd.GPIODriver
+
+	period   int
+	duty     int
+	polarity embd.Polarity
+	enabled  bool
+
+	periodf   *os.File
+	dutyf     *os.File
+	polarityf *os.File
+	enablef   *os.File
+
+	initialized bool
+}
+
+// NewPWMPin returns a PWM pin driving the channel of a PWM chip exposed by
+// the kernel, as located by the PWMChip and PWMChannel of the descriptor.
+func NewPWMPin(pd *embd.PinDesc, drv embd.GPIODriver) embd.PWMPin {
+	return &pwmPin{id: pd.ID, chip: pd.PWMChip, channel: pd.PWMChannel, drv: drv}
+}
+
+func (p *pwmPin) N() string {
+	return p.id
+}
+
+func (p *pwmPin) chipPath() string {
+	return path.Join(pwmClassPath, fmt.Sprintf("pwmchip%v", p.chip))
+}
+
+func (p *pwmPin) basePath() string {
+	return path.Join(p.chipPath(), fmt.Sprintf("pwm%v", p.channel))
+}
+
+func (p *pwmPin) init() error {
+	if p.initialized {
+		return nil
+	}
+
+	if err := p.export(); err != nil {
+		return err
+	}
+
+	var err error
+	if p.periodf, err = p.openFile("period"); err != nil {
+		return err
+	}
+	if p.dutyf, err = p.openFile("duty_cycle"); err != nil {
+		return err
+	}
+	if p.polarityf, err = p.openFile("polarity"); err != nil {
+		return err
+	}
+	if p.enablef, err = p.openFile("enable"); err != nil {
+		return err
+	}
+	if err := p.readState(); err != nil {
+		return err
+	}
+
+	p.initialized = true
+
+	if p.period == 0 {
+		return p.SetPeriod(PWMDefaultPeriod)
+	}
+	return nil
+}
+
+func (p *pwmPin) export() error {
+	period := path.Join(p.basePath(), "period")
+	if _, err := os.Stat(period); err == nil {
+		return nil
+	}
+
+	if err := ioutil.WriteFile(path.Join(p.chipPath(), "export"), []byte(strconv.Itoa(p.channel)), 0200); err != nil {
+		return err
+	}
+
+	timeout := time.After(exportTimeout)
+	for {
+		if f, err := os.OpenFile(period, os.O_WRONLY, 0); err == nil {
+			return f.Close()
+		}
+		select {
+		case <-timeout:
+			return errors.New("embd: pwm channel not exported before timeout")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (p *pwmPin) unexport() error {
+	return ioutil.WriteFile(path.Join(p.chipPath(), "unexport"), []byte(strconv.Itoa(p.channel)), 0200)
+}
+
+func (p *pwmPin) openFile(name string) (*os.File, error) {
+	return os.OpenFile(path.Join(p.basePath(), name), os.O_RDWR, os.ModeExclusive)
+}
+
+func readValue(f *os.File) (string, error) {
+	if _, err := f.Seek(0, 0); err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadAll(f)
+	return strings.TrimSpace(string(data)), err
+}
+
+func writeValue(f *os.File, v string) error {
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+	_, err := f.WriteString(v)
+	return err
+}
+
+// readState reads the current settings of the channel, which may have been
+// set up before.
+func (p *pwmPin) readState() error {
+	for _, v := range []struct {
+		f   *os.File
+		out *int
+	}{{p.periodf, &p.period}, {p.dutyf, &p.duty}} {
+		s, err := readValue(v.f)
+		if err != nil {
+			return err
+		}
+		if *v.out, err = strconv.Atoi(s); err != nil {
+			return err
+		}
+	}
+
+	s, err := readValue(p.polarityf)
+	if err != nil {
+		return err
+	}
+	p.polarity = embd.Positive
+	if s == "inversed" {
+		p.polarity = embd.Negative
+	}
+
+	s, err = readValue(p.enablef)
+	if err != nil {
+		return err
+	}
+	p.enabled = s == "1"
+
+	return nil
+}
+
+func (p *pwmPin) enable(on bool) error {
+	if on == p.enabled {
+		return nil
+	}
+	v := "0"
+	if on {
+		v = "1"
+	}
+	if err := writeValue(p.enablef, v); err != nil {
+		return err
+	}
+	p.enabled = on
+	return nil
+}
+
+func (p *pwmPin) SetPeriod(ns int) error {
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	if ns <= 0 {
+		return fmt.Errorf("embd: pwm period %vns for %v must be positive", ns, p.id)
+	}
+
+	// The duty cycle can never exceed the period.
+	if p.duty > ns {
+		if err := writeValue(p.dutyf, strconv.Itoa(ns)); err != nil {
+			return err
+		}
+		p.duty = ns
+	}
+	if err := writeValue(p.periodf, strconv.Itoa(ns)); err != nil {
+		return err
+	}
+	p.period = ns
+
+	return p.enable(true)
+}
+
+func (p *pwmPin) SetDuty(ns int) error {
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	if ns < 0 || ns > p.period {
+		return fmt.Errorf("embd: pwm duty %vns for %v is out of bounds (must be =< the period %vns)", ns, p.id, p.period)
+	}
+
+	if err := writeValue(p.dutyf, strconv.Itoa(ns)); err != nil {
+		return err
+	}
+	p.duty = ns
+
+	return p.enable(true)
+}
+
+func (p *pwmPin) SetMicroseconds(us int) error {
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	if p.period != 20000000 {
+		glog.Warningf("embd: pwm pin %v has freq %v hz. recommended 50 hz for servo mode", p.id, 1000000000/p.period)
+	}
+	duty := us * 1000 // in nanoseconds
+	if duty > p.period {
+		return fmt.Errorf("embd: calculated pwm duty %vns for pin %v (servo mode) is greater than the period %vns", duty, p.id, p.period)
+	}
+	return p.SetDuty(duty)
+}
+
+func (p *pwmPin) SetAnalog(value byte) error {
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	duty := util.Map(int64(value), 0, 255, 0, int64(p.period))
+	return p.SetDuty(int(duty))
+}
+
+func (p *pwmPin) SetPolarity(pol embd.Polarity) error {
+	if err := p.init(); err != nil {
+		return err
+	}
+
+	v := "normal"
+	if pol == embd.Negative {
+		v = "inversed"
+	}
+
+	// The polarity can only be changed while the channel is disabled.
+	enabled := p.enabled
+	if err := p.enable(false); err != nil {
+		return err
+	}
+	if err := writeValue(p.polarityf, v); err != nil {
+		return err
+	}
+	p.polarity = pol
+
+	return p.enable(enabled)
+}
+
+func (p *pwmPin) State() (embd.PinState, error) {
+	if err := p.init(); err != nil {
+		return embd.PinState{}, err
+	}
+
+	return embd.PinState{
+		Direction: embd.Out,
+		Period:    p.period,
+		Duty:      p.duty,
+		Polarity:  p.polarity,
+	}, nil
+}
+
+func (p *pwmPin) RestoreState(s embd.PinState) error {
+	if err := p.SetPeriod(s.Period); err != nil {
+		return err
+	}
+	if err := p.SetPolarity(s.Polarity); err != nil {
+		return err
+	}
+	return p.SetDuty(s.Duty)
+}
+
+func (p *pwmPin) closeFiles() error {
+	for _, f := range []*os.File{p.periodf, p.dutyf, p.polarityf, p.enablef} {
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Release closes the pin, leaving the channel running as it is.
+func (p *pwmPin) Release() error {
+	if err := p.drv.Unregister(p.id); err != nil {
+		return err
+	}
+
+	if !p.initialized {
+		return nil
+	}
+
+	if err := p.closeFiles(); err != nil {
+		return err
+	}
+
+	p.initialized = false
+
+	return nil
+}
+
+func (p *pwmPin) Close() error {
+	if err := p.drv.Unregister(p.id); err != nil {
+		return err
+	}
+
+	if !p.initialized {
+		return nil
+	}
+
+	if err := p.enable(false); err != nil {
+		return err
+	}
+	if err := p.closeFiles(); err != nil {
+		return err
+	}
+	if err := p.unexport(); err != nil {
+		return err
+	}
+
+	p.initialized = false
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package generic
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+// fakeSysfs creates the files of an exported GPIO, PWM channel and LED.
+func fakeSysfs(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"pwm/pwmchip0/export":          "",
+		"pwm/pwmchip0/unexport":        "",
+		"pwm/pwmchip0/pwm1/period":     "0",
+		"pwm/pwmchip0/pwm1/duty_cycle": "0",
+		"pwm/pwmchip0/pwm1/polarity":   "normal",
+		"pwm/pwmchip0/pwm1/enable":     "0",
+		"leds/led0/brightness":         "0",
+		"leds/led0/trigger":            "none [mmc0] timer heartbeat",
+		"gpio/export":                  "",
+		"gpio/unexport":                "",
+		"gpio/gpio17/direction":        "",
+		"gpio/gpio17/value":            "0",
+		"gpio/gpio17/active_low":       "0",
+	}
+	for name, content := range files {
+		p := path.Join(dir, name)
+		if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pwmPath, ledPath, gpioPath := pwmClassPath, ledClassPath, gpioClassPath
+	pwmClassPath, ledClassPath, gpioClassPath = path.Join(dir, "pwm"), path.Join(dir, "leds"), path.Join(dir, "gpio")
+	return func() {
+		pwmClassPath, ledClassPath, gpioClassPath = pwmPath, ledPath, gpioPath
+		os.RemoveAll(dir)
+	}
+}
+
+func readFile(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile(path.Join(path.Dir(pwmClassPath), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestPWMPin(t *testing.T) {
+	defer fakeSysfs(t)()
+
+	pinMap := embd.PinMap{
+		&embd.PinDesc{ID: "P1_12", Aliases: []string{"18"}, Caps: embd.CapPWM, PWMChip: 0, PWMChannel: 1},
+	}
+	driver := embd.NewGPIODriver(pinMap, nil, nil, NewPWMPin)
+	pin, err := driver.PWMPin(18)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pin.SetAnalog(128); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"pwm/pwmchip0/pwm1/period":     "500000",
+		"pwm/pwmchip0/pwm1/duty_cycle": "250980",
+		"pwm/pwmchip0/pwm1/enable":     "1",
+	} {
+		if got := readFile(t, name); got != want {
+			t.Errorf("%v = %v; want %v", name, got, want)
+		}
+	}
+
+	// Shortening the period below the duty cycle lowers it first.
+	if err := pin.SetPeriod(200000); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, "pwm/pwmchip0/pwm1/duty_cycle"); got != "200000" {
+		t.Errorf("duty cycle = %v; want 200000", got)
+	}
+	if err := pin.SetDuty(300000); err == nil {
+		t.Error("no error for a duty cycle longer than the period")
+	}
+
+	if err := pin.SetPolarity(embd.Negative); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, "pwm/pwmchip0/pwm1/polarity"); got != "inversed" {
+		t.Errorf("polarity = %v; want inversed", got)
+	}
+
+	if err := pin.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, "pwm/pwmchip0/pwm1/enable"); got != "0" {
+		t.Errorf("enable = %v after Close; want 0", got)
+	}
+	if got := readFile(t, "pwm/pwmchip0/unexport"); got != "1" {
+		t.Errorf("unexport = %q; want 1", got)
+	}
+}
+
+func TestLEDTrigger(t *testing.T) {
+	defer fakeSysfs(t)()
+
+	l := NewLED("led0").(embd.TriggerLED)
+	trigger, err := l.Trigger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trigger != "mmc0" {
+		t.Errorf("Trigger() = %v; want mmc0", trigger)
+	}
+	triggers, err := l.Triggers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(triggers, " ") != "none mmc0 timer heartbeat" {
+		t.Errorf("Triggers() = %v", triggers)
+	}
+
+	if err := l.SetTrigger(embd.LEDTriggerHeartbeat); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, "leds/led0/trigger"); got != "heartbeat" {
+		t.Errorf("trigger = %v; want heartbeat", got)
+	}
+	if err := l.SetTriggerParam("../brightness", "1"); err == nil {
+		t.Error("no error for a parameter outside the led")
+	}
+
+	if err := NewLED("../leds/led0").On(); err == nil {
+		t.Error("no error for a led outside the led class")
+	}
+
+	leds, err := LEDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leds) != 1 || leds[0] != "led0" {
+		t.Errorf("LEDs() = %v", leds)
+	}
+}
@@ -191,7 +191,7 @@ func (b *spiBus) TransferAndRecieveData(dataBuffer []uint8) error {
 	if errno != 0 {
 		err := syscall.Errno(errno)
 		glog.V(3).Infof("spi: failed to read due to %v", err.Error())
-		return err
+		return embd.NewBusError(fmt.Sprintf("spidev%v.%v", b.spiDevMinor, b.channel), "Transfer", err)
 	}
 	glog.V(3).Infof("spi: read into dataBuffer %v", dataBuffer)
 	return nil
@@ -233,6 +233,7 @@ func (b *spiBus) ReceiveByte() (byte, error) {
 	return byte(d[0]), nil
 }
 
+// Close closes the device of the bus. Closing it again does nothing.
 func (b *spiBus) Close() error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -241,5 +242,9 @@ func (b *spiBus) Close() error {
 		return nil
 	}
 
-	return b.file.Close()
+	// The bus is opened again when it is used after being closed.
+	b.initialized = false
+	err := b.file.Close()
+	b.file = nil
+	return err
 }
@@ -0,0 +1,149 @@
+// Known boards.
+
+package hat
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/controller/hd44780"
+	"github.com/kidoman/embd/controller/mcp23017"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+)
+
+// Board is a known HAT or cape, configured from its EEPROM.
+type Board struct {
+	Name string
+	// Match returns whether the EEPROM is the one of the board.
+	Match func(info *Info) bool
+	// Configure sets the board up, and returns its device.
+	Configure func(info *Info) (interface{}, error)
+}
+
+var boards = struct {
+	sync.Mutex
+	list []Board
+}{list: []Board{AdafruitLCDPlate}}
+
+// RegisterBoard adds a board to the known boards.
+func RegisterBoard(b Board) {
+	boards.Lock()
+	defer boards.Unlock()
+
+	boards.list = append(boards.list, b)
+}
+
+// Configured is a board configured from its EEPROM.
+type Configured struct {
+	Info   *Info
+	Board  string
+	Device interface{}
+}
+
+// Detect returns the HAT and the capes attached.
+func Detect() ([]*Info, error) {
+	var infos []*Info
+	info, err := ReadHAT()
+	switch err {
+	case nil:
+		infos = append(infos, info)
+	case ErrNoBoard:
+	default:
+		return nil, err
+	}
+	capes, err := ReadCapes()
+	if err != nil {
+		return nil, err
+	}
+	return append(infos, capes...), nil
+}
+
+// AutoConfigure configures the known boards attached. The unknown ones are
+// logged and skipped.
+func AutoConfigure() ([]Configured, error) {
+	infos, err := Detect()
+	if err != nil {
+		return nil, err
+	}
+
+	boards.Lock()
+	list := append([]Board(nil), boards.list...)
+	boards.Unlock()
+
+	var configured []Configured
+	for _, info := range infos {
+		known := false
+		for _, b := range list {
+			if !b.Match(info) {
+				continue
+			}
+			glog.V(1).Infof("hat: configuring %v", b.Name)
+			dev, err := b.Configure(info)
+			if err != nil {
+				return configured, fmt.Errorf("hat: configuring %v: %v", b.Name, err)
+			}
+			configured = append(configured, Configured{Info: info, Board: b.Name, Device: dev})
+			known = true
+			break
+		}
+		if !known {
+			glog.Infof("hat: no configuration for %v", info)
+		}
+	}
+	return configured, nil
+}
+
+// The pins of the MCP23017 of the Adafruit LCD plate.
+const (
+	plateBacklight = 6
+	plateRS        = 15
+	plateRW        = 14
+	plateEN        = 13
+	plateD4        = 12
+	plateD5        = 11
+	plateD6        = 10
+	plateD7        = 9
+)
+
+// AdafruitLCDPlate is the Adafruit 16x2 character LCD plate, driven by a
+// MCP23017 on I²C bus 1 at 0x20. Its pins are registered as the "lcdplate"
+// expander, and its device is a *characterdisplay.Display.
+var AdafruitLCDPlate = Board{
+	Name: "Adafruit LCD plate",
+	Match: func(info *Info) bool {
+		return strings.Contains(info.Vendor, "Adafruit") && strings.Contains(info.Product, "LCD")
+	},
+	Configure: func(*Info) (interface{}, error) {
+		if err := embd.InitI2C(); err != nil {
+			return nil, err
+		}
+		chip := mcp23017.New(embd.NewI2CBus(1), 0x20)
+		if err := embd.RegisterExpander("lcdplate", chip); err != nil {
+			return nil, err
+		}
+		// The display is only written to.
+		rw, err := chip.DigitalPin(plateRW)
+		if err != nil {
+			return nil, err
+		}
+		if err := rw.SetDirection(embd.Out); err != nil {
+			return nil, err
+		}
+		if err := rw.Write(embd.Low); err != nil {
+			return nil, err
+		}
+		pin := func(n int) string { return fmt.Sprintf("lcdplate.%v", n) }
+		hd, err := hd44780.NewGPIO(
+			pin(plateRS), pin(plateEN),
+			pin(plateD4), pin(plateD5), pin(plateD6), pin(plateD7),
+			pin(plateBacklight), hd44780.Negative,
+			hd44780.RowAddress16Col, hd44780.TwoLine)
+		if err != nil {
+			return nil, err
+		}
+		return characterdisplay.New(hd, 16, 2), nil
+	},
+}
@@ -0,0 +1,94 @@
+// BeagleBone cape EEPROMs.
+
+package hat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	capeHeader   = "\xaa\x55\x33\xee"
+	capeLen      = 244
+	capePinUsage = 88
+	capePins     = 74
+)
+
+// CapeEEPROMs are the patterns of the sysfs files of the cape EEPROMs, at
+// the addresses 0x54 to 0x57 of the cape bus.
+var CapeEEPROMs = []string{
+	"/sys/bus/i2c/devices/*-005[4-7]/eeprom",
+	"/sys/bus/nvmem/devices/*-005[4-7]*/nvmem",
+}
+
+func capeString(b []byte) string {
+	return string(bytes.Trim(b, "\x00\xff "))
+}
+
+var capeFunctions = []string{"", "in", "out", "bidir"}
+
+// ParseCape parses the contents of a BeagleBone cape EEPROM, of format A1.
+func ParseCape(data []byte) (*Info, error) {
+	if len(data) < capeLen || string(data[:4]) != capeHeader {
+		return nil, errors.New("hat: not a cape EEPROM")
+	}
+	info := &Info{
+		Product:    capeString(data[6:38]),
+		Version:    capeString(data[38:42]),
+		Vendor:     capeString(data[42:58]),
+		PartNumber: capeString(data[58:74]),
+		Serial:     capeString(data[76:88]),
+	}
+	for i := 0; i < capePins; i++ {
+		v := binary.BigEndian.Uint16(data[capePinUsage+2*i:])
+		if v&0x8000 == 0 {
+			continue
+		}
+		pin := PinConfig{
+			Pin:      i,
+			Function: capeFunctions[v>>13&0x03],
+			Mux:      int(v & 0x07),
+		}
+		if v&0x08 == 0 {
+			pin.Pull = "down"
+			if v&0x10 != 0 {
+				pin.Pull = "up"
+			}
+		}
+		info.Pins = append(info.Pins, pin)
+	}
+	return info, nil
+}
+
+// ReadCapes returns the capes attached, stacked at different addresses.
+func ReadCapes() ([]*Info, error) {
+	var paths []string
+	for _, pattern := range CapeEEPROMs {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	var capes []*Info
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := ParseCape(data)
+		if err != nil {
+			// The same EEPROM can show in both sysfs trees, and the
+			// addresses can be used by other chips.
+			continue
+		}
+		capes = append(capes, info)
+	}
+	return capes, nil
+}
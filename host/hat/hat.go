@@ -0,0 +1,234 @@
+/*
+Package hat reads the ID EEPROMs of Raspberry Pi HATs and BeagleBone capes,
+and configures the known boards from them.
+
+The vendor, the product and the pins used by a board are read from its
+EEPROM, and the known boards are set up at once:
+
+	boards, err := hat.AutoConfigure()
+	if err != nil {
+		panic(err)
+	}
+	for _, b := range boards {
+		if disp, ok := b.Device.(*characterdisplay.Display); ok {
+			disp.Message("Hello")
+		}
+	}
+*/
+package hat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kidoman/embd"
+)
+
+// Info is the identification of a HAT or of a cape.
+type Info struct {
+	Vendor  string
+	Product string
+	// ProductID and ProductVersion are the product of HATs.
+	ProductID      uint16
+	ProductVersion uint16
+	UUID           string
+	// Version, PartNumber and Serial are the product of capes.
+	Version    string
+	PartNumber string
+	Serial     string
+	// Pins are the pins the board uses, when listed in the EEPROM.
+	Pins []PinConfig
+}
+
+func (i *Info) String() string {
+	return fmt.Sprintf("%v %v", i.Vendor, i.Product)
+}
+
+// PinConfig is the configuration of a pin used by a board.
+type PinConfig struct {
+	// Pin is the GPIO number of HAT pins, and the position of cape pins
+	// in the pin usage table of the BeagleBone System Reference Manual.
+	Pin int
+	// Function is "in", "out" or "alt0" to "alt5" for HAT pins, and
+	// "in", "out" or "bidir" for cape pins.
+	Function string
+	// Mux is the mux mode of cape pins.
+	Mux int
+	// Pull is the pull resistor: "up", "down", or empty for none.
+	Pull string
+}
+
+// ErrNoBoard is returned when no HAT or cape is attached.
+var ErrNoBoard = errors.New("hat: no board attached")
+
+const (
+	hatSignature = "R-Pi"
+	hatHeaderLen = 12
+	atomHeader   = 8
+
+	atomVendorInfo = 0x0001
+	atomGPIOMap    = 0x0002
+
+	gpioPins = 28
+)
+
+// crc16 is the CRC-16/ARC of the atoms of HAT EEPROMs.
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xa001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+var hatFunctions = []string{"in", "out", "alt5", "alt4", "alt0", "alt1", "alt2", "alt3"}
+var hatPulls = []string{"", "up", "down", ""}
+
+// ParseHAT parses the contents of a HAT EEPROM: its vendor info and GPIO
+// map atoms. The other atoms, like the device tree blob, are skipped.
+func ParseHAT(data []byte) (*Info, error) {
+	if len(data) < hatHeaderLen || string(data[:4]) != hatSignature {
+		return nil, errors.New("hat: not a HAT EEPROM")
+	}
+	numAtoms := int(binary.LittleEndian.Uint16(data[6:]))
+	info := &Info{}
+	off := hatHeaderLen
+	for i := 0; i < numAtoms; i++ {
+		if off+atomHeader > len(data) {
+			return nil, fmt.Errorf("hat: atom %v truncated", i)
+		}
+		typ := binary.LittleEndian.Uint16(data[off:])
+		dlen := int(binary.LittleEndian.Uint32(data[off+4:]))
+		end := off + atomHeader + dlen
+		if dlen < 2 || end > len(data) {
+			return nil, fmt.Errorf("hat: atom %v truncated", i)
+		}
+		if crc := binary.LittleEndian.Uint16(data[end-2:]); crc != crc16(data[off:end-2]) {
+			return nil, fmt.Errorf("hat: atom %v has a bad CRC", i)
+		}
+		body := data[off+atomHeader : end-2]
+		var err error
+		switch typ {
+		case atomVendorInfo:
+			err = parseVendorInfo(info, body)
+		case atomGPIOMap:
+			err = parseGPIOMap(info, body)
+		}
+		if err != nil {
+			return nil, err
+		}
+		off = end
+	}
+	return info, nil
+}
+
+func parseVendorInfo(info *Info, body []byte) error {
+	if len(body) < 22 {
+		return errors.New("hat: vendor info truncated")
+	}
+	var uuid [4]uint32
+	for i := range uuid {
+		uuid[i] = binary.LittleEndian.Uint32(body[4*i:])
+	}
+	info.UUID = fmt.Sprintf("%08x-%04x-%04x-%04x-%04x%08x",
+		uuid[3], uuid[2]>>16, uuid[2]&0xffff, uuid[1]>>16, uuid[1]&0xffff, uuid[0])
+	info.ProductID = binary.LittleEndian.Uint16(body[16:])
+	info.ProductVersion = binary.LittleEndian.Uint16(body[18:])
+	vlen, plen := int(body[20]), int(body[21])
+	if 22+vlen+plen > len(body) {
+		return errors.New("hat: vendor info truncated")
+	}
+	info.Vendor = string(body[22 : 22+vlen])
+	info.Product = string(body[22+vlen : 22+vlen+plen])
+	return nil
+}
+
+func parseGPIOMap(info *Info, body []byte) error {
+	if len(body) < 2+gpioPins {
+		return errors.New("hat: GPIO map truncated")
+	}
+	for gpio, v := range body[2 : 2+gpioPins] {
+		if v&0x80 == 0 {
+			continue
+		}
+		info.Pins = append(info.Pins, PinConfig{
+			Pin:      gpio,
+			Function: hatFunctions[v&0x07],
+			Pull:     hatPulls[v>>5&0x03],
+		})
+	}
+	return nil
+}
+
+// DeviceTreeHAT is where the Raspberry Pi firmware publishes the vendor info
+// of the HAT it read at boot.
+var DeviceTreeHAT = "/proc/device-tree/hat"
+
+func readDTString(name string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(DeviceTreeHAT, name))
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(b, "\x00\n")), nil
+}
+
+// ReadHAT returns the vendor info of the HAT read by the firmware at boot,
+// or ErrNoBoard. The GPIO map is not published by the firmware: ParseHAT
+// reads it from the contents of the EEPROM.
+func ReadHAT() (*Info, error) {
+	if _, err := os.Stat(DeviceTreeHAT); os.IsNotExist(err) {
+		return nil, ErrNoBoard
+	}
+	info := &Info{}
+	var err error
+	if info.Vendor, err = readDTString("vendor"); err != nil {
+		return nil, err
+	}
+	if info.Product, err = readDTString("product"); err != nil {
+		return nil, err
+	}
+	info.UUID, _ = readDTString("uuid")
+	for name, v := range map[string]*uint16{"product_id": &info.ProductID, "product_ver": &info.ProductVersion} {
+		s, err := readDTString(name)
+		if err != nil {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(s), 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("hat: parsing %v %q: %v", name, s, err)
+		}
+		*v = uint16(n)
+	}
+	return info, nil
+}
+
+// ReadEEPROM reads n bytes from the start of a 24Cxx EEPROM with 16 bit
+// addresses, like the ID EEPROMs of HATs on I²C bus 0 at 0x50.
+func ReadEEPROM(bus embd.I2CBus, addr byte, n int) ([]byte, error) {
+	if err := bus.WriteBytes(addr, []byte{0, 0}); err != nil {
+		return nil, err
+	}
+	data := make([]byte, n)
+	for i := range data {
+		b, err := bus.ReadByte(addr)
+		if err != nil {
+			return nil, err
+		}
+		data[i] = b
+	}
+	return data, nil
+}
@@ -0,0 +1,132 @@
+package hat
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func atom(typ uint16, body []byte) []byte {
+	a := make([]byte, atomHeader, atomHeader+len(body)+2)
+	binary.LittleEndian.PutUint16(a, typ)
+	binary.LittleEndian.PutUint32(a[4:], uint32(len(body)+2))
+	a = append(a, body...)
+	crc := crc16(a)
+	return append(a, byte(crc), byte(crc>>8))
+}
+
+func hatEEPROM() []byte {
+	vendor := make([]byte, 22)
+	for i := 0; i < 16; i++ {
+		vendor[i] = byte(i)
+	}
+	binary.LittleEndian.PutUint16(vendor[16:], 0x0042)
+	binary.LittleEndian.PutUint16(vendor[18:], 3)
+	vendor[20], vendor[21] = 4, 3
+	vendor = append(vendor, "Acme"+"LCD"...)
+
+	gpio := make([]byte, 2+gpioPins)
+	gpio[2+4] = 0x80 | 1<<5 | 1 // GPIO 4: output, pulled up
+	gpio[2+17] = 0x80 | 4       // GPIO 17: alt0
+
+	data := []byte(hatSignature + "\x01\x00\x02\x00\x00\x00\x00\x00")
+	data = append(data, atom(atomVendorInfo, vendor)...)
+	return append(data, atom(atomGPIOMap, gpio)...)
+}
+
+func TestParseHAT(t *testing.T) {
+	info, err := ParseHAT(hatEEPROM())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Vendor != "Acme" || info.Product != "LCD" || info.ProductID != 0x42 || info.ProductVersion != 3 {
+		t.Errorf("got %+v", info)
+	}
+	if want := "0f0e0d0c-0b0a-0908-0706-050403020100"; info.UUID != want {
+		t.Errorf("UUID: got %v, want %v", info.UUID, want)
+	}
+	want := []PinConfig{{Pin: 4, Function: "out", Pull: "up"}, {Pin: 17, Function: "alt0"}}
+	if !reflect.DeepEqual(info.Pins, want) {
+		t.Errorf("Pins: got %+v, want %+v", info.Pins, want)
+	}
+
+	data := hatEEPROM()
+	data[hatHeaderLen+atomHeader+22]++
+	if _, err := ParseHAT(data); err == nil {
+		t.Error("no error for a bad CRC")
+	}
+}
+
+func capeEEPROM() []byte {
+	data := make([]byte, capeLen)
+	copy(data, capeHeader+"A1")
+	copy(data[6:], "Relay cape")
+	copy(data[38:], "00A0")
+	copy(data[42:], "Acme")
+	copy(data[58:], "BB-RELAY")
+	copy(data[76:], "1234")
+	binary.BigEndian.PutUint16(data[capePinUsage+2*3:], 0x8000|2<<13|0x08|7)
+	binary.BigEndian.PutUint16(data[capePinUsage+2*5:], 0x8000|1<<13|0x10|7)
+	return data
+}
+
+func TestParseCape(t *testing.T) {
+	info, err := ParseCape(capeEEPROM())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Product != "Relay cape" || info.Vendor != "Acme" || info.Version != "00A0" || info.PartNumber != "BB-RELAY" || info.Serial != "1234" {
+		t.Errorf("got %+v", info)
+	}
+	want := []PinConfig{{Pin: 3, Function: "out", Mux: 7}, {Pin: 5, Function: "in", Mux: 7, Pull: "up"}}
+	if !reflect.DeepEqual(info.Pins, want) {
+		t.Errorf("Pins: got %+v, want %+v", info.Pins, want)
+	}
+}
+
+func TestAutoConfigure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(hat string, capes []string) { DeviceTreeHAT, CapeEEPROMs = hat, capes }(DeviceTreeHAT, CapeEEPROMs)
+	DeviceTreeHAT = filepath.Join(dir, "hat")
+	CapeEEPROMs = []string{filepath.Join(dir, "2-005[4-7]")}
+	if err := os.Mkdir(DeviceTreeHAT, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, v := range map[string]string{"vendor": "Acme\x00", "product": "Fan HAT\x00", "product_id": "0x0007\x00", "product_ver": "0x0001\x00"} {
+		if err := ioutil.WriteFile(filepath.Join(DeviceTreeHAT, name), []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "2-0054"), capeEEPROM(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	RegisterBoard(Board{
+		Name:      "fan",
+		Match:     func(info *Info) bool { return info.Product == "Fan HAT" && info.ProductID == 7 },
+		Configure: func(info *Info) (interface{}, error) { return "fan device", nil },
+	})
+	boards, err := AutoConfigure()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boards) != 1 || boards[0].Board != "fan" || boards[0].Device != "fan device" {
+		t.Errorf("got %+v", boards)
+	}
+
+	infos, err := Detect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[1].Product != "Relay cape" {
+		t.Errorf("Detect: got %v", infos)
+	}
+}
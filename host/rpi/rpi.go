@@ -2,9 +2,12 @@
 	Package rpi provides Raspberry Pi support.
 	The following features are supported on Linux kernel 3.8+
 
-	GPIO (digital (rw))
+	GPIO (digital (rw), pwm)
 	I²C
-	LED
+	LED (with triggers)
+
+	PWM is available on GPIO_18 once enabled with the pwm device tree
+	overlay (dtoverlay=pwm in /boot/config.txt).
 */
 package rpi
 
@@ -16,13 +19,13 @@ import (
 var spiDeviceMinor = byte(0)
 
 var rev1Pins = embd.PinMap{
-	&embd.PinDesc{ID: "P1_3", Aliases: []string{"0", "GPIO_0", "SDA", "I2C0_SDA"}, Caps: embd.CapDigital | embd.CapI2C, DigitalLogical: 0},
-	&embd.PinDesc{ID: "P1_5", Aliases: []string{"1", "GPIO_1", "SCL", "I2C0_SCL"}, Caps: embd.CapDigital | embd.CapI2C, DigitalLogical: 1},
+	&embd.PinDesc{ID: "P1_3", Aliases: []string{"0", "GPIO_0", "SDA", "SDA0", "I2C0_SDA"}, Caps: embd.CapDigital | embd.CapI2C, DigitalLogical: 0},
+	&embd.PinDesc{ID: "P1_5", Aliases: []string{"1", "GPIO_1", "SCL", "SCL0", "I2C0_SCL"}, Caps: embd.CapDigital | embd.CapI2C, DigitalLogical: 1},
 	&embd.PinDesc{ID: "P1_7", Aliases: []string{"4", "GPIO_4", "GPCLK0"}, Caps: embd.CapDigital, DigitalLogical: 4},
-	&embd.PinDesc{ID: "P1_8", Aliases: []string{"14", "GPIO_14", "TXD", "UART0_TXD"}, Caps: embd.CapDigital | embd.CapUART, DigitalLogical: 14},
-	&embd.PinDesc{ID: "P1_10", Aliases: []string{"15", "GPIO_15", "RXD", "UART0_RXD"}, Caps: embd.CapDigital | embd.CapUART, DigitalLogical: 15},
+	&embd.PinDesc{ID: "P1_8", Aliases: []string{"14", "GPIO_14", "TXD", "TXD0", "UART0_TXD"}, Caps: embd.CapDigital | embd.CapUART, DigitalLogical: 14},
+	&embd.PinDesc{ID: "P1_10", Aliases: []string{"15", "GPIO_15", "RXD", "RXD0", "UART0_RXD"}, Caps: embd.CapDigital | embd.CapUART, DigitalLogical: 15},
 	&embd.PinDesc{ID: "P1_11", Aliases: []string{"17", "GPIO_17"}, Caps: embd.CapDigital, DigitalLogical: 17},
-	&embd.PinDesc{ID: "P1_12", Aliases: []string{"18", "GPIO_18", "PCM_CLK"}, Caps: embd.CapDigital, DigitalLogical: 18},
+	&embd.PinDesc{ID: "P1_12", Aliases: []string{"18", "GPIO_18", "PCM_CLK", "PWM0"}, Caps: embd.CapDigital | embd.CapPWM, DigitalLogical: 18},
 	&embd.PinDesc{ID: "P1_13", Aliases: []string{"21", "GPIO_21"}, Caps: embd.CapDigital, DigitalLogical: 21},
 	&embd.PinDesc{ID: "P1_15", Aliases: []string{"22", "GPIO_22"}, Caps: embd.CapDigital, DigitalLogical: 22},
 	&embd.PinDesc{ID: "P1_16", Aliases: []string{"23", "GPIO_23"}, Caps: embd.CapDigital, DigitalLogical: 23},
@@ -36,13 +39,13 @@ var rev1Pins = embd.PinMap{
 }
 
 var rev2Pins = embd.PinMap{
-	&embd.PinDesc{ID: "P1_3", Aliases: []string{"2", "GPIO_2", "SDA", "I2C1_SDA"}, Caps: embd.CapDigital | embd.CapI2C, DigitalLogical: 2},
-	&embd.PinDesc{ID: "P1_5", Aliases: []string{"3", "GPIO_3", "SCL", "I2C1_SCL"}, Caps: embd.CapDigital | embd.CapI2C, DigitalLogical: 3},
+	&embd.PinDesc{ID: "P1_3", Aliases: []string{"2", "GPIO_2", "SDA", "SDA1", "I2C1_SDA"}, Caps: embd.CapDigital | embd.CapI2C, DigitalLogical: 2},
+	&embd.PinDesc{ID: "P1_5", Aliases: []string{"3", "GPIO_3", "SCL", "SCL1", "I2C1_SCL"}, Caps: embd.CapDigital | embd.CapI2C, DigitalLogical: 3},
 	&embd.PinDesc{ID: "P1_7", Aliases: []string{"4", "GPIO_4", "GPCLK0"}, Caps: embd.CapDigital, DigitalLogical: 4},
-	&embd.PinDesc{ID: "P1_8", Aliases: []string{"14", "GPIO_14", "TXD", "UART0_TXD"}, Caps: embd.CapDigital | embd.CapUART, DigitalLogical: 14},
-	&embd.PinDesc{ID: "P1_10", Aliases: []string{"15", "GPIO_15", "RXD", "UART0_RXD"}, Caps: embd.CapDigital | embd.CapUART, DigitalLogical: 15},
+	&embd.PinDesc{ID: "P1_8", Aliases: []string{"14", "GPIO_14", "TXD", "TXD0", "UART0_TXD"}, Caps: embd.CapDigital | embd.CapUART, DigitalLogical: 14},
+	&embd.PinDesc{ID: "P1_10", Aliases: []string{"15", "GPIO_15", "RXD", "RXD0", "UART0_RXD"}, Caps: embd.CapDigital | embd.CapUART, DigitalLogical: 15},
 	&embd.PinDesc{ID: "P1_11", Aliases: []string{"17", "GPIO_17"}, Caps: embd.CapDigital, DigitalLogical: 17},
-	&embd.PinDesc{ID: "P1_12", Aliases: []string{"18", "GPIO_18", "PCM_CLK"}, Caps: embd.CapDigital, DigitalLogical: 18},
+	&embd.PinDesc{ID: "P1_12", Aliases: []string{"18", "GPIO_18", "PCM_CLK", "PWM0"}, Caps: embd.CapDigital | embd.CapPWM, DigitalLogical: 18},
 	&embd.PinDesc{ID: "P1_13", Aliases: []string{"27", "GPIO_27"}, Caps: embd.CapDigital, DigitalLogical: 27},
 	&embd.PinDesc{ID: "P1_15", Aliases: []string{"22", "GPIO_22"}, Caps: embd.CapDigital, DigitalLogical: 22},
 	&embd.PinDesc{ID: "P1_16", Aliases: []string{"23", "GPIO_23"}, Caps: embd.CapDigital, DigitalLogical: 23},
@@ -53,13 +56,29 @@ var rev2Pins = embd.PinMap{
 	&embd.PinDesc{ID: "P1_23", Aliases: []string{"11", "GPIO_11", "SCLK", "SPI0_SCLK"}, Caps: embd.CapDigital | embd.CapSPI, DigitalLogical: 11},
 	&embd.PinDesc{ID: "P1_24", Aliases: []string{"8", "GPIO_8", "CE0", "SPI0_CE0_N"}, Caps: embd.CapDigital | embd.CapSPI, DigitalLogical: 8},
 	&embd.PinDesc{ID: "P1_26", Aliases: []string{"7", "GPIO_7", "CE1", "SPI0_CE1_N"}, Caps: embd.CapDigital | embd.CapSPI, DigitalLogical: 7},
+
+	// The rest of the 40 pin header of the later boards.
+	&embd.PinDesc{ID: "P1_29", Aliases: []string{"5", "GPIO_5"}, Caps: embd.CapDigital, DigitalLogical: 5},
+	&embd.PinDesc{ID: "P1_31", Aliases: []string{"6", "GPIO_6"}, Caps: embd.CapDigital, DigitalLogical: 6},
+	&embd.PinDesc{ID: "P1_32", Aliases: []string{"12", "GPIO_12"}, Caps: embd.CapDigital, DigitalLogical: 12},
+	&embd.PinDesc{ID: "P1_33", Aliases: []string{"13", "GPIO_13"}, Caps: embd.CapDigital, DigitalLogical: 13},
+	&embd.PinDesc{ID: "P1_35", Aliases: []string{"19", "GPIO_19", "PCM_FS"}, Caps: embd.CapDigital, DigitalLogical: 19},
+	&embd.PinDesc{ID: "P1_36", Aliases: []string{"16", "GPIO_16"}, Caps: embd.CapDigital, DigitalLogical: 16},
+	&embd.PinDesc{ID: "P1_37", Aliases: []string{"26", "GPIO_26"}, Caps: embd.CapDigital, DigitalLogical: 26},
+	&embd.PinDesc{ID: "P1_38", Aliases: []string{"20", "GPIO_20", "PCM_DIN"}, Caps: embd.CapDigital, DigitalLogical: 20},
+	&embd.PinDesc{ID: "P1_40", Aliases: []string{"21", "GPIO_21", "PCM_DOUT"}, Caps: embd.CapDigital, DigitalLogical: 21},
 }
 
 var ledMap = embd.LEDMap{
 	"led0": []string{"0", "led0", "LED0"},
+	"led1": []string{"1", "led1", "LED1"},
 }
 
 func init() {
+	// The GPIOs are 3.3V, and not 5V tolerant.
+	rev1Pins.SetLevel(embd.Level3V3, false)
+	rev2Pins.SetLevel(embd.Level3V3, false)
+
 	embd.Register(embd.HostRPi, func(rev int) *embd.Descriptor {
 		var pins = rev2Pins
 		if rev < 4 {
@@ -68,13 +87,13 @@ func init() {
 
 		return &embd.Descriptor{
 			GPIODriver: func() embd.GPIODriver {
-				return embd.NewGPIODriver(pins, generic.NewDigitalPin, nil, nil)
+				return embd.NewGPIODriver(pins, generic.NewDigitalPin, nil, generic.NewPWMPin)
 			},
 			I2CDriver: func() embd.I2CDriver {
 				return embd.NewI2CDriver(generic.NewI2CBus)
 			},
 			LEDDriver: func() embd.LEDDriver {
-				return embd.NewLEDDriver(ledMap, generic.NewLED)
+				return embd.NewLEDDriver(ledMap, generic.NewLED, generic.LEDs)
 			},
 			SPIDriver: func() embd.SPIDriver {
 				return embd.NewSPIDriver(spiDeviceMinor, generic.NewSPIBus, nil)
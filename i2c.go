@@ -29,6 +29,17 @@ type I2CBus interface {
 	Close() error
 }
 
+// I2CTransferWriter is implemented by the I²C buses which can write several
+// bytes to a device in a single transfer, like the buses of the generic host.
+// WriteBytes may write them one transfer at a time, with delays between
+// them.
+type I2CTransferWriter interface {
+	// WriteTransfer writes the bytes to the given address in a single
+	// transfer, a start, the address, the bytes and a stop. Wrappers of
+	// buses return ErrFeatureNotSupported when the wrapped bus cannot.
+	WriteTransfer(addr byte, value []byte) error
+}
+
 // I2CDriver interface interacts with the host descriptors to allow us
 // control of I2C communication.
 type I2CDriver interface {
@@ -73,5 +84,9 @@ func NewI2CBus(l byte) I2CBus {
 		panic(err)
 	}
 
-	return i2cDriverInstance.Bus(l)
+	bus := i2cDriverInstance.Bus(l)
+	if Tracing() {
+		bus = TraceI2CBus(bus, l)
+	}
+	return bus
 }
@@ -0,0 +1,112 @@
+// ALSA playback and capture.
+
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+	"os/exec"
+	"strconv"
+)
+
+const (
+	defaultRate   = 22050
+	defaultVolume = 0.5
+)
+
+// ALSA plays sounds through an ALSA device, using aplay.
+type ALSA struct {
+	// Device is the ALSA device, like "default" or "hw:1,0".
+	Device string
+
+	Rate   int
+	Volume float64
+}
+
+// NewALSA creates a new player on the ALSA device.
+func NewALSA(device string) *ALSA {
+	return &ALSA{Device: device, Rate: defaultRate, Volume: defaultVolume}
+}
+
+func (a *ALSA) command(arg ...string) *exec.Cmd {
+	return exec.Command("aplay", append([]string{"-q", "-D", a.Device}, arg...)...)
+}
+
+// run runs cmd, killing it once stop is closed.
+func run(cmd *exec.Cmd, stop <-chan struct{}) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-stop:
+		cmd.Process.Kill()
+		<-done
+		return nil
+	}
+}
+
+// Play plays the melody.
+func (a *ALSA) Play(m Melody, stop <-chan struct{}) error {
+	return a.PlaySamples(Synthesize(m, a.Rate, a.Volume), stop)
+}
+
+// PlaySamples plays mono 16 bit samples at the sample rate of the player.
+func (a *ALSA) PlaySamples(samples []int16, stop <-chan struct{}) error {
+	cmd := a.command("-t", "raw", "-f", "S16_LE", "-c", "1", "-r", strconv.Itoa(a.Rate))
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	go func() {
+		binary.Write(w, binary.LittleEndian, samples)
+		w.Close()
+	}()
+	return run(cmd, stop)
+}
+
+// PlayFile plays a sound file, like a WAV sample.
+func (a *ALSA) PlayFile(path string, stop <-chan struct{}) error {
+	return run(a.command(path), stop)
+}
+
+// Capture captures sound from an ALSA device, using arecord.
+type Capture struct {
+	cmd *exec.Cmd
+	out io.ReadCloser
+}
+
+// Capture starts capturing mono 16 bit samples at the sample rate of the
+// player, from the ALSA device, like a USB microphone or an I²S MEMS
+// microphone.
+func (a *ALSA) Capture() (*Capture, error) {
+	cmd := exec.Command("arecord", "-q", "-D", a.Device, "-t", "raw", "-f", "S16_LE", "-c", "1", "-r", strconv.Itoa(a.Rate))
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &Capture{cmd: cmd, out: out}, nil
+}
+
+// Read reads captured samples, blocking until samples is full.
+func (c *Capture) Read(samples []int16) (int, error) {
+	if err := binary.Read(c.out, binary.LittleEndian, samples); err != nil {
+		return 0, err
+	}
+	return len(samples), nil
+}
+
+// Close stops capturing.
+func (c *Capture) Close() error {
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	return nil
+}
@@ -0,0 +1,127 @@
+/*
+Package audio plays short notification sounds, through ALSA on boards with a
+sound card or I²S DAC, or as tones on a speaker driven by a PWM pin:
+
+	speaker := audio.NewALSA("default")
+	speaker.Play(audio.Chime, nil)
+
+Alerts can be audible as well as visible:
+
+	status := indicator.Multi(lcd, audio.NewIndicator(speaker))
+	status.Error("door open")
+*/
+package audio
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/interface/indicator"
+)
+
+// Note is a tone of a melody. A Frequency of zero is a rest.
+type Note struct {
+	Frequency float64
+	Duration  time.Duration
+}
+
+// Melody is a sequence of notes.
+type Melody []Note
+
+// The melodies played by NewIndicator.
+var (
+	Chime = Melody{{880, 120 * time.Millisecond}, {1320, 180 * time.Millisecond}}
+	Alert = Melody{{988, 150 * time.Millisecond}, {0, 80 * time.Millisecond}, {988, 150 * time.Millisecond}}
+	Alarm = Melody{
+		{1760, 250 * time.Millisecond}, {880, 250 * time.Millisecond},
+		{1760, 250 * time.Millisecond}, {880, 250 * time.Millisecond},
+		{1760, 250 * time.Millisecond}, {880, 250 * time.Millisecond},
+	}
+)
+
+// Duration returns the duration of the melody.
+func (m Melody) Duration() time.Duration {
+	var d time.Duration
+	for _, n := range m {
+		d += n.Duration
+	}
+	return d
+}
+
+// A Player plays melodies.
+type Player interface {
+	// Play plays the melody, returning early once stop is closed. A nil
+	// stop plays the whole melody.
+	Play(m Melody, stop <-chan struct{}) error
+}
+
+// fadeTime is how long the notes fade in and out, to not click.
+const fadeTime = 5 * time.Millisecond
+
+// Synthesize returns the samples of the melody at the sample rate, as sine
+// waves at the given volume from 0 to 1.
+func Synthesize(m Melody, rate int, volume float64) []int16 {
+	samples := make([]int16, 0, int(m.Duration().Seconds()*float64(rate)))
+	fade := int(fadeTime.Seconds() * float64(rate))
+	for _, n := range m {
+		count := int(n.Duration.Seconds() * float64(rate))
+		for i := 0; i < count; i++ {
+			if n.Frequency <= 0 {
+				samples = append(samples, 0)
+				continue
+			}
+			a := volume
+			if i < fade {
+				a *= float64(i) / float64(fade)
+			} else if count-i < fade {
+				a *= float64(count-i) / float64(fade)
+			}
+			v := a * math.Sin(2*math.Pi*n.Frequency*float64(i)/float64(rate))
+			samples = append(samples, int16(v*math.MaxInt16))
+		}
+	}
+	return samples
+}
+
+// NewIndicator returns an indicator playing the Chime for Info, the Alert for
+// Warn and the Alarm for Error. The melodies are played in the background, a
+// new status interrupting the previous one.
+func NewIndicator(p Player) indicator.Indicator {
+	var (
+		mu         sync.Mutex
+		stop, done chan struct{}
+	)
+	return indicator.Func(func(level indicator.Level, msg string) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if stop != nil {
+			close(stop)
+			<-done
+			stop = nil
+		}
+
+		var m Melody
+		switch level {
+		case indicator.Info:
+			m = Chime
+		case indicator.Warn:
+			m = Alert
+		case indicator.Error:
+			m = Alarm
+		default:
+			return nil
+		}
+
+		stop, done = make(chan struct{}), make(chan struct{})
+		go func(stop, done chan struct{}) {
+			defer close(done)
+			if err := p.Play(m, stop); err != nil {
+				glog.Errorf("audio: playing %v: %v", level, err)
+			}
+		}(stop, done)
+		return nil
+	})
+}
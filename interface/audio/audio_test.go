@@ -0,0 +1,98 @@
+package audio
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+)
+
+func TestSynthesize(t *testing.T) {
+	m := Melody{{1000, 10 * time.Millisecond}, {0, 10 * time.Millisecond}}
+	samples := Synthesize(m, 8000, 1)
+	if len(samples) != 160 {
+		t.Fatalf("got %v samples, want 160", len(samples))
+	}
+	if samples[0] != 0 {
+		t.Errorf("first sample: got %v, want a fade in from 0", samples[0])
+	}
+	var peak int16
+	for _, s := range samples[:80] {
+		if s > peak {
+			peak = s
+		}
+	}
+	if peak < 30000 {
+		t.Errorf("tone peak: got %v, want full volume", peak)
+	}
+	for _, s := range samples[80:] {
+		if s != 0 {
+			t.Fatal("got sound during the rest")
+		}
+	}
+}
+
+type fakePWMPin struct {
+	embd.PWMPin
+	periods, duties []int
+}
+
+func (p *fakePWMPin) SetPeriod(ns int) error {
+	p.periods = append(p.periods, ns)
+	return nil
+}
+
+func (p *fakePWMPin) SetDuty(ns int) error {
+	p.duties = append(p.duties, ns)
+	return nil
+}
+
+func TestPWMPlayer(t *testing.T) {
+	pin := &fakePWMPin{}
+	m := Melody{{1000, time.Millisecond}, {0, time.Millisecond}, {500, time.Millisecond}}
+	if err := NewPWMPlayer(pin).Play(m, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1000000, 2000000}; !reflect.DeepEqual(pin.periods, want) {
+		t.Errorf("periods: got %v, want %v", pin.periods, want)
+	}
+	if want := []int{500000, 0, 1000000, 0}; !reflect.DeepEqual(pin.duties, want) {
+		t.Errorf("duties: got %v, want %v", pin.duties, want)
+	}
+}
+
+type fakePlayer chan Melody
+
+func (p fakePlayer) Play(m Melody, stop <-chan struct{}) error {
+	p <- m
+	<-stop
+	return nil
+}
+
+func TestIndicator(t *testing.T) {
+	p := make(fakePlayer, 1)
+	ind := NewIndicator(p)
+
+	if err := ind.Warn("battery low"); err != nil {
+		t.Fatal(err)
+	}
+	if m := <-p; !reflect.DeepEqual(m, Alert) {
+		t.Errorf("Warn played %v, want the alert", m)
+	}
+	// A new status stops the previous melody.
+	if err := ind.Error("door open"); err != nil {
+		t.Fatal(err)
+	}
+	if m := <-p; !reflect.DeepEqual(m, Alarm) {
+		t.Errorf("Error played %v, want the alarm", m)
+	}
+	if err := ind.Off(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-p:
+		t.Errorf("Off played %v", m)
+	default:
+	}
+}
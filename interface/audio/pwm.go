@@ -0,0 +1,45 @@
+// PWM tone playback.
+
+package audio
+
+import (
+	"time"
+
+	"github.com/kidoman/embd"
+)
+
+// PWMPlayer plays melodies as square waves on a PWM pin, driving a passive
+// buzzer or a speaker through a transistor.
+type PWMPlayer struct {
+	Pin embd.PWMPin
+}
+
+// NewPWMPlayer creates a new player on the PWM pin.
+func NewPWMPlayer(pin embd.PWMPin) *PWMPlayer {
+	return &PWMPlayer{Pin: pin}
+}
+
+// Play plays the melody, each note setting the period of the pin.
+func (p *PWMPlayer) Play(m Melody, stop <-chan struct{}) error {
+	defer p.Pin.SetDuty(0)
+
+	for _, n := range m {
+		if n.Frequency > 0 {
+			period := int(float64(time.Second) / n.Frequency)
+			if err := p.Pin.SetPeriod(period); err != nil {
+				return err
+			}
+			if err := p.Pin.SetDuty(period / 2); err != nil {
+				return err
+			}
+		} else if err := p.Pin.SetDuty(0); err != nil {
+			return err
+		}
+		select {
+		case <-time.After(n.Duration):
+		case <-stop:
+			return nil
+		}
+	}
+	return nil
+}
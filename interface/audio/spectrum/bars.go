@@ -0,0 +1,111 @@
+// Bar graphs.
+
+package spectrum
+
+import (
+	"image"
+
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+	"github.com/kidoman/embd/interface/display/graphics"
+)
+
+// fullBlock is the character of a full cell in the HD44780 character ROM.
+const fullBlock = 0xff
+
+// Bars shows the levels as vertical bars on a character display, one column
+// per band and Height rows high. The partial cells at the top of the bars
+// are custom glyphs, 8 steps per row.
+type Bars struct {
+	Display  *characterdisplay.Display
+	Col, Row int
+	Height   int
+
+	// Slots are the 7 glyph slots of the partial cells, 1 to 7 rows high.
+	Slots [7]byte
+
+	created bool
+}
+
+// NewBars creates new bars height rows high, with their top left cell at
+// col and row, using the glyph slots 0 to 6.
+func NewBars(disp *characterdisplay.Display, col, row, height int) *Bars {
+	return &Bars{
+		Display: disp,
+		Col:     col,
+		Row:     row,
+		Height:  height,
+		Slots:   [7]byte{0, 1, 2, 3, 4, 5, 6},
+	}
+}
+
+func (b *Bars) createGlyphs() error {
+	for i, slot := range b.Slots {
+		var rows [8]byte
+		for r := 8 - (i + 1); r < 8; r++ {
+			rows[r] = 0x1f
+		}
+		if err := b.Display.CreateChar(slot, rows); err != nil {
+			return err
+		}
+	}
+	b.created = true
+	return nil
+}
+
+// cell returns the character of a cell of a bar filled with eighths of the
+// cells below and above it.
+func (b *Bars) cell(eighths, cellRow int) byte {
+	switch fill := eighths - 8*cellRow; {
+	case fill >= 8:
+		return fullBlock
+	case fill <= 0:
+		return ' '
+	default:
+		return b.Slots[fill-1]
+	}
+}
+
+// Show implements Sink.
+func (b *Bars) Show(levels []float64) error {
+	if !b.created {
+		if err := b.createGlyphs(); err != nil {
+			return err
+		}
+	}
+	for r := 0; r < b.Height; r++ {
+		// The rows of the display go down, the cells of the bars up.
+		if err := b.Display.SetCursor(b.Col, b.Row+r); err != nil {
+			return err
+		}
+		for _, l := range levels {
+			eighths := int(l*float64(8*b.Height) + 0.5)
+			if err := b.Display.WriteChar(b.cell(eighths, b.Height-1-r)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GraphicBars returns the sink drawing the levels as vertical bars on a
+// graphical display through its buffer, like a LED matrix, spreading the
+// bands over its width.
+func GraphicBars(buf *graphics.Buffer) Sink {
+	return SinkFunc(func(levels []float64) error {
+		if len(levels) == 0 {
+			return nil
+		}
+		buf.Update(func(img *graphics.Mono) {
+			r := img.Bounds()
+			img.Fill(r, false)
+			w, h := r.Dx(), r.Dy()
+			for i, l := range levels {
+				x0, x1 := r.Min.X+i*w/len(levels), r.Min.X+(i+1)*w/len(levels)
+				top := r.Max.Y - int(l*float64(h)+0.5)
+				img.Fill(image.Rect(x0, top, x1, r.Max.Y), true)
+			}
+		})
+		_, err := buf.Flush()
+		return err
+	})
+}
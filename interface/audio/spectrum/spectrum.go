@@ -0,0 +1,278 @@
+/*
+Package spectrum shows the frequency spectrum of sound as bars, on a LED
+matrix, a character display or any dimmable outputs.
+
+A visualizer captures frames of samples, splits their spectrum into
+logarithmically spaced bands and shows the levels of the bands on its sinks:
+
+	mic := audio.NewALSA("hw:1,0")
+	capture, err := mic.Capture()
+	if err != nil {
+		panic(err)
+	}
+	a := spectrum.NewAnalyzer(mic.Rate, 16)
+	a.Gain = 2
+	bars := spectrum.NewBars(disp, 0, 0, 2)
+	v := spectrum.New(capture, a, bars, spectrum.GraphicBars(graphics.NewBuffer(matrix)))
+	v.Run()
+	defer v.Close()
+*/
+package spectrum
+
+import (
+	"io"
+	"math"
+	"math/cmplx"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// DefaultWindow is the default number of samples analyzed per frame.
+	DefaultWindow = 1024
+
+	// DefaultFloor is the default level shown as an empty bar, in dBFS.
+	DefaultFloor = -60
+
+	defaultMinFreq = 60
+	defaultMaxFreq = 8000
+	defaultAttack  = 1
+	defaultDecay   = 0.3
+)
+
+// FFT computes the discrete Fourier transform of x in place. The length of
+// x must be a power of two.
+func FFT(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*wk
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				wk *= w
+			}
+		}
+	}
+}
+
+// Analyzer splits the spectrum of frames of samples into bands.
+type Analyzer struct {
+	// Rate is the sample rate in Hz.
+	Rate int
+	// Bands is the number of bands, spaced logarithmically between
+	// MinFreq and MaxFreq.
+	Bands            int
+	MinFreq, MaxFreq float64
+
+	// Gain amplifies the sound before it is measured.
+	Gain float64
+	// Floor is the level of an empty band, in dBFS. A full scale sine
+	// fills its band.
+	Floor float64
+
+	// Attack and Decay smooth the levels: they are the fraction of the
+	// change of a rising and of a falling level applied every frame, from
+	// 0 to 1 (no smoothing).
+	Attack, Decay float64
+
+	mu     sync.Mutex
+	levels []float64
+}
+
+// NewAnalyzer creates a new Analyzer of sound sampled at rate.
+func NewAnalyzer(rate, bands int) *Analyzer {
+	return &Analyzer{
+		Rate:    rate,
+		Bands:   bands,
+		MinFreq: defaultMinFreq,
+		MaxFreq: defaultMaxFreq,
+		Gain:    1,
+		Floor:   DefaultFloor,
+		Attack:  defaultAttack,
+		Decay:   defaultDecay,
+	}
+}
+
+// edges returns the frequency bin starting each band, and the bin ending
+// the last one, for n samples.
+func (a *Analyzer) edges(n int) []int {
+	top := math.Min(a.MaxFreq, float64(a.Rate)/2)
+	edges := make([]int, a.Bands+1)
+	for i := range edges {
+		f := a.MinFreq * math.Pow(top/a.MinFreq, float64(i)/float64(a.Bands))
+		edges[i] = int(f*float64(n)/float64(a.Rate) + 0.5)
+		// Every band gets at least one bin.
+		if i > 0 && edges[i] <= edges[i-1] {
+			edges[i] = edges[i-1] + 1
+		}
+	}
+	if last := n / 2; edges[a.Bands] > last {
+		edges[a.Bands] = last
+	}
+	return edges
+}
+
+// Analyze returns the smoothed levels of the bands, from 0 to 1, after the
+// frame of samples. Frames are cut to the largest power of two samples.
+func (a *Analyzer) Analyze(samples []int16) []float64 {
+	n := 1
+	for n*2 <= len(samples) {
+		n *= 2
+	}
+	x := make([]complex128, n)
+	for i := range x {
+		// A Hann window, for the bands not to leak into each other.
+		w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+		x[i] = complex(float64(samples[i])/math.MaxInt16*w*a.Gain, 0)
+	}
+	FFT(x)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.levels) != a.Bands {
+		a.levels = make([]float64, a.Bands)
+	}
+	edges := a.edges(n)
+	for b := 0; b < a.Bands; b++ {
+		peak := 0.0
+		for k := edges[b]; k < edges[b+1] && k < n/2; k++ {
+			// A full scale sine peaks at n/4 through the window.
+			peak = math.Max(peak, cmplx.Abs(x[k])/float64(n/4))
+		}
+		level := 0.0
+		if peak > 0 {
+			level = 1 - 20*math.Log10(peak)/a.Floor
+		}
+		level = math.Max(0, math.Min(1, level))
+
+		rate := a.Decay
+		if level > a.levels[b] {
+			rate = a.Attack
+		}
+		a.levels[b] += (level - a.levels[b]) * rate
+	}
+	return append([]float64(nil), a.levels...)
+}
+
+// Source is a source of samples, like an audio.Capture.
+type Source interface {
+	// Read reads samples, blocking until samples is full.
+	Read(samples []int16) (int, error)
+}
+
+// Sink shows the levels of the bands.
+type Sink interface {
+	Show(levels []float64) error
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc func(levels []float64) error
+
+// Show implements Sink.
+func (f SinkFunc) Show(levels []float64) error {
+	return f(levels)
+}
+
+// Outputs returns the sink setting one dimmable output per band, like the
+// channels of a lighting zone or the LEDs of a strip. The extra bands or
+// outputs are left out.
+func Outputs(outs ...func(level float64) error) Sink {
+	return SinkFunc(func(levels []float64) error {
+		for i, out := range outs {
+			if i == len(levels) {
+				break
+			}
+			if err := out(levels[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Visualizer analyzes the sound of a source and shows it on sinks.
+type Visualizer struct {
+	Source   Source
+	Analyzer *Analyzer
+	Sinks    []Sink
+
+	// Window is the number of samples analyzed per frame.
+	Window int
+
+	quit, done chan struct{}
+}
+
+// New creates a new Visualizer.
+func New(src Source, a *Analyzer, sinks ...Sink) *Visualizer {
+	return &Visualizer{Source: src, Analyzer: a, Sinks: sinks, Window: DefaultWindow}
+}
+
+// Frame reads, analyzes and shows a frame of samples.
+func (v *Visualizer) Frame(samples []int16) error {
+	if _, err := v.Source.Read(samples); err != nil {
+		return err
+	}
+	levels := v.Analyzer.Analyze(samples)
+	for _, s := range v.Sinks {
+		if err := s.Show(levels); err != nil {
+			glog.Errorf("spectrum: showing levels: %v", err)
+		}
+	}
+	return nil
+}
+
+// Run starts showing the sound in the background, until the source fails
+// or the visualizer is closed.
+func (v *Visualizer) Run() {
+	v.quit = make(chan struct{})
+	v.done = make(chan struct{})
+
+	go func() {
+		defer close(v.done)
+
+		samples := make([]int16, v.Window)
+		for {
+			select {
+			case <-v.quit:
+				return
+			default:
+			}
+			if err := v.Frame(samples); err != nil {
+				if err != io.EOF {
+					glog.Errorf("spectrum: reading samples: %v", err)
+				}
+				return
+			}
+		}
+	}()
+}
+
+// Close stops showing the sound, closing the source if it is an io.Closer
+// so that a blocked read returns.
+func (v *Visualizer) Close() error {
+	if v.quit == nil {
+		return nil
+	}
+	close(v.quit)
+	var err error
+	if c, ok := v.Source.(io.Closer); ok {
+		err = c.Close()
+	}
+	<-v.done
+	v.quit = nil
+	return err
+}
@@ -0,0 +1,171 @@
+package spectrum
+
+import (
+	"image"
+	"io"
+	"math"
+	"math/cmplx"
+	"testing"
+
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+	"github.com/kidoman/embd/interface/display/graphics"
+)
+
+func sine(freq float64, rate, n int, amplitude float64) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(amplitude * math.MaxInt16 * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+	}
+	return samples
+}
+
+func TestFFT(t *testing.T) {
+	const n = 64
+	x := make([]complex128, n)
+	for i := range x {
+		x[i] = complex(math.Cos(2*math.Pi*5*float64(i)/n), 0)
+	}
+	FFT(x)
+	for k, v := range x {
+		want := 0.0
+		if k == 5 || k == n-5 {
+			want = n / 2
+		}
+		if math.Abs(cmplx.Abs(v)-want) > 1e-9 {
+			t.Errorf("|X[%v]| = %v; want %v", k, cmplx.Abs(v), want)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	const rate = 22050
+	a := NewAnalyzer(rate, 8)
+	a.Decay = 0.5
+	freq := 1000.0
+
+	levels := a.Analyze(sine(freq, rate, DefaultWindow, 1))
+	edges := a.edges(DefaultWindow)
+	bin := int(freq*DefaultWindow/rate + 0.5)
+	for b, l := range levels {
+		in := bin >= edges[b] && bin < edges[b+1]
+		if in && l < 0.95 {
+			t.Errorf("level of the band of the tone = %v; want about 1", l)
+		}
+		if !in && l > 0.6 {
+			t.Errorf("level of band %v = %v; want lower than the band of the tone", b, l)
+		}
+	}
+
+	// The levels fall at the decay rate.
+	silence := make([]int16, DefaultWindow)
+	prev := levels
+	levels = a.Analyze(silence)
+	for b := range levels {
+		if math.Abs(levels[b]-prev[b]/2) > 1e-9 {
+			t.Errorf("level of band %v after silence = %v; want %v", b, levels[b], prev[b]/2)
+		}
+	}
+}
+
+type fakeController struct {
+	characterdisplay.Controller
+
+	col, row int
+	cells    [2][4]byte
+	glyphs   map[byte][8]byte
+}
+
+func (c *fakeController) SetCursor(col, row int) error {
+	c.col, c.row = col, row
+	return nil
+}
+
+func (c *fakeController) WriteChar(b byte) error {
+	c.cells[c.row][c.col] = b
+	c.col++
+	return nil
+}
+
+func (c *fakeController) CreateChar(slot byte, rows [8]byte) error {
+	c.glyphs[slot] = rows
+	return nil
+}
+
+func TestBars(t *testing.T) {
+	c := &fakeController{glyphs: map[byte][8]byte{}}
+	bars := NewBars(characterdisplay.New(c, 4, 2), 0, 0, 2)
+	if err := bars.Show([]float64{0, 0.25, 0.5, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.glyphs) != 7 || c.glyphs[3] != [8]byte{0, 0, 0, 0, 0x1f, 0x1f, 0x1f, 0x1f} {
+		t.Errorf("glyphs %v; want 7 partial cells", c.glyphs)
+	}
+	want := [2][4]byte{
+		{' ', ' ', ' ', fullBlock},
+		{' ', 3, fullBlock, fullBlock},
+	}
+	if c.cells != want {
+		t.Errorf("cells %v; want %v", c.cells, want)
+	}
+}
+
+type fakeDisplay struct {
+	img *graphics.Mono
+}
+
+func (d *fakeDisplay) Bounds() image.Rectangle {
+	return image.Rect(0, 0, 8, 8)
+}
+
+func (d *fakeDisplay) Draw(img *graphics.Mono, r image.Rectangle) error {
+	d.img = img.Clone()
+	return nil
+}
+
+func TestGraphicBars(t *testing.T) {
+	d := &fakeDisplay{}
+	if err := GraphicBars(graphics.NewBuffer(d)).Show([]float64{1, 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []struct {
+		x, y int
+		on   bool
+	}{
+		{0, 0, true}, {3, 7, true}, {4, 3, false}, {4, 4, true}, {7, 7, true},
+	} {
+		if got := d.img.BitAt(p.x, p.y); got != p.on {
+			t.Errorf("pixel %v,%v = %v; want %v", p.x, p.y, got, p.on)
+		}
+	}
+}
+
+type fakeSource struct {
+	frames [][]int16
+}
+
+func (s *fakeSource) Read(samples []int16) (int, error) {
+	if len(s.frames) == 0 {
+		return 0, io.EOF
+	}
+	copy(samples, s.frames[0])
+	s.frames = s.frames[1:]
+	return len(samples), nil
+}
+
+func TestVisualizer(t *testing.T) {
+	const rate = 8000
+	src := &fakeSource{frames: [][]int16{sine(440, rate, 256, 1), sine(440, rate, 256, 1)}}
+	var shown [][]float64
+	v := New(src, NewAnalyzer(rate, 4), SinkFunc(func(levels []float64) error {
+		shown = append(shown, levels)
+		return nil
+	}))
+	v.Window = 256
+	v.Run()
+	<-v.done
+	v.Close()
+
+	if len(shown) != 2 || len(shown[0]) != 4 {
+		t.Errorf("shown %v; want 2 frames of 4 bands", shown)
+	}
+}
@@ -0,0 +1,352 @@
+/*
+Package blink plays declarative blink patterns on status LEDs.
+
+Patterns run in the background. When several are playing, the one with the
+highest priority is shown and the others resume once it finishes or is
+cancelled:
+
+	b := blink.New(blink.LED(led))
+	defer b.Close()
+
+	b.Play(blink.Heartbeat)
+	...
+	cancel := b.Play(blink.SOS.WithPriority(10))
+	...
+	cancel()
+*/
+package blink
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+)
+
+// Step is a single segment of a pattern.
+type Step struct {
+	// Level is the brightness, from 0 (off) to 1 (fully on). Outputs without
+	// dimming treat anything above 0.5 as on.
+	Level    float64
+	Duration time.Duration
+}
+
+// Pattern is a sequence of steps.
+type Pattern struct {
+	Name  string
+	Steps []Step
+
+	// Repeat plays the pattern until it is cancelled.
+	Repeat bool
+
+	// Priority decides which of the playing patterns is shown.
+	Priority int
+}
+
+// WithPriority returns a copy of the pattern with the given priority.
+func (p Pattern) WithPriority(priority int) Pattern {
+	p.Priority = priority
+	return p
+}
+
+// Once returns a copy of the pattern which plays only once.
+func (p Pattern) Once() Pattern {
+	p.Repeat = false
+	return p
+}
+
+func on(d time.Duration) Step  { return Step{1, d} }
+func off(d time.Duration) Step { return Step{0, d} }
+
+// Common patterns.
+var (
+	Solid = Pattern{Name: "solid", Steps: []Step{on(time.Hour)}, Repeat: true}
+
+	SlowBlink = Pattern{Name: "slow", Steps: []Step{on(time.Second), off(time.Second)}, Repeat: true}
+
+	FastBlink = Pattern{Name: "fast", Steps: []Step{on(100 * time.Millisecond), off(100 * time.Millisecond)}, Repeat: true}
+
+	Heartbeat = Pattern{
+		Name: "heartbeat",
+		Steps: []Step{
+			on(100 * time.Millisecond), off(100 * time.Millisecond),
+			on(100 * time.Millisecond), off(700 * time.Millisecond),
+		},
+		Repeat: true,
+	}
+
+	SOS = Morse("SOS", 150*time.Millisecond)
+)
+
+var morseCode = map[rune]string{
+	'A': ".-", 'B': "-...", 'C': "-.-.", 'D': "-..", 'E': ".", 'F': "..-.",
+	'G': "--.", 'H': "....", 'I': "..", 'J': ".---", 'K': "-.-", 'L': ".-..",
+	'M': "--", 'N': "-.", 'O': "---", 'P': ".--.", 'Q': "--.-", 'R': ".-.",
+	'S': "...", 'T': "-", 'U': "..-", 'V': "...-", 'W': ".--", 'X': "-..-",
+	'Y': "-.--", 'Z': "--..",
+	'0': "-----", '1': ".----", '2': "..---", '3': "...--", '4': "....-",
+	'5': ".....", '6': "-....", '7': "--...", '8': "---..", '9': "----.",
+}
+
+// Morse returns a repeating pattern signaling text in Morse code, with dots
+// lasting unit. Characters without a Morse code are skipped.
+func Morse(text string, unit time.Duration) Pattern {
+	var steps []Step
+	gap := func(units time.Duration) {
+		// Gaps replace the gap following the last symbol.
+		if n := len(steps); n > 0 && steps[n-1].Level == 0 {
+			steps[n-1].Duration = units * unit
+			return
+		}
+		steps = append(steps, off(units*unit))
+	}
+	for _, word := range strings.Fields(strings.ToUpper(text)) {
+		for _, r := range word {
+			code, ok := morseCode[r]
+			if !ok {
+				continue
+			}
+			for _, symbol := range code {
+				if symbol == '.' {
+					steps = append(steps, on(unit))
+				} else {
+					steps = append(steps, on(3*unit))
+				}
+				steps = append(steps, off(unit))
+			}
+			gap(3)
+		}
+		gap(7)
+	}
+	return Pattern{Name: "morse " + text, Steps: steps, Repeat: true}
+}
+
+// Breathing returns a repeating pattern fading in and out over period, for
+// outputs supporting dimming.
+func Breathing(period time.Duration) Pattern {
+	const steps = 32
+	var p Pattern
+	p.Name = "breathing"
+	p.Repeat = true
+	d := period / (2 * steps)
+	for i := 0; i <= steps; i++ {
+		l := float64(i) / steps
+		p.Steps = append(p.Steps, Step{l * l, d})
+	}
+	for i := steps - 1; i > 0; i-- {
+		l := float64(i) / steps
+		p.Steps = append(p.Steps, Step{l * l, d})
+	}
+	return p
+}
+
+// Output sets the brightness of a LED, from 0 to 1.
+type Output func(level float64) error
+
+// LED returns an output switching a LED on and off.
+func LED(led embd.LED) Output {
+	return func(level float64) error {
+		if level > 0.5 {
+			return led.On()
+		}
+		return led.Off()
+	}
+}
+
+// Pin returns an output switching a GPIO pin.
+func Pin(pin embd.DigitalPin) Output {
+	return func(level float64) error {
+		if level > 0.5 {
+			return pin.Write(embd.High)
+		}
+		return pin.Write(embd.Low)
+	}
+}
+
+// PWM returns an output dimming a LED on a PWM pin.
+func PWM(pin embd.PWMPin) Output {
+	return func(level float64) error {
+		return pin.SetAnalog(byte(level*255 + 0.5))
+	}
+}
+
+type playing struct {
+	pattern Pattern
+	seq     int
+}
+
+// Blinker plays patterns on an output.
+type Blinker struct {
+	out   Output
+	clock clock.Clock
+
+	mu      sync.Mutex
+	playing []*playing
+	seq     int
+
+	changed chan struct{}
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+// New creates a new Blinker and starts its background goroutine.
+func New(out Output) *Blinker {
+	return NewWithClock(out, clock.Real)
+}
+
+// NewWithClock creates a new Blinker timing the patterns with c, like a
+// clock.Virtual in tests.
+func NewWithClock(out Output, c clock.Clock) *Blinker {
+	b := &Blinker{
+		out:     out,
+		clock:   c,
+		changed: make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+func (b *Blinker) notify() {
+	select {
+	case b.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Play starts playing a pattern and returns a function cancelling it.
+func (b *Blinker) Play(p Pattern) (cancel func()) {
+	b.mu.Lock()
+	b.seq++
+	pl := &playing{pattern: p, seq: b.seq}
+	b.playing = append(b.playing, pl)
+	// Highest priority first; the most recent first among equals.
+	sort.SliceStable(b.playing, func(i, j int) bool {
+		if b.playing[i].pattern.Priority != b.playing[j].pattern.Priority {
+			return b.playing[i].pattern.Priority > b.playing[j].pattern.Priority
+		}
+		return b.playing[i].seq > b.playing[j].seq
+	})
+	b.mu.Unlock()
+	b.notify()
+
+	return func() {
+		if b.remove(pl) {
+			b.notify()
+		}
+	}
+}
+
+// Stop cancels all the patterns.
+func (b *Blinker) Stop() {
+	b.mu.Lock()
+	b.playing = nil
+	b.mu.Unlock()
+	b.notify()
+}
+
+func (b *Blinker) remove(pl *playing) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, other := range b.playing {
+		if other == pl {
+			b.playing = append(b.playing[:i], b.playing[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Blinker) top() *playing {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.playing) == 0 {
+		return nil
+	}
+	return b.playing[0]
+}
+
+func (b *Blinker) set(level float64) {
+	if err := b.out(level); err != nil {
+		glog.Errorf("blink: setting output: %v", err)
+	}
+}
+
+func (b *Blinker) run() {
+	defer close(b.done)
+	defer b.set(0)
+
+	for {
+		cur := b.top()
+		if cur == nil {
+			b.set(0)
+			select {
+			case <-b.changed:
+				continue
+			case <-b.quit:
+				return
+			}
+		}
+
+		if !b.play(cur) {
+			select {
+			case <-b.quit:
+				return
+			default:
+				continue
+			}
+		}
+		if !cur.pattern.Repeat || len(cur.pattern.Steps) == 0 {
+			b.remove(cur)
+		}
+	}
+}
+
+// play plays the steps of a pattern once, and returns false if it was
+// interrupted.
+func (b *Blinker) play(cur *playing) bool {
+	for _, step := range cur.pattern.Steps {
+		b.set(step.Level)
+		if !b.wait(cur, step.Duration) {
+			return false
+		}
+	}
+	return true
+}
+
+// wait waits for d, and returns false if cur stopped being the pattern to
+// show in the meantime.
+func (b *Blinker) wait(cur *playing, d time.Duration) bool {
+	timer := b.clock.NewTimer(d)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C():
+			return true
+		case <-b.changed:
+			if b.top() != cur {
+				return false
+			}
+		case <-b.quit:
+			return false
+		}
+	}
+}
+
+// Close stops the background goroutine and switches the output off.
+func (b *Blinker) Close() {
+	select {
+	case <-b.quit:
+	default:
+		close(b.quit)
+	}
+	<-b.done
+}
@@ -0,0 +1,99 @@
+package blink
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMorse(t *testing.T) {
+	unit := time.Millisecond
+	p := Morse("et", unit)
+	expected := []Step{on(unit), off(3 * unit), on(3 * unit), off(7 * unit)}
+	if len(p.Steps) != len(expected) {
+		t.Fatalf("Steps: got %v, want %v", p.Steps, expected)
+	}
+	for i := range expected {
+		if p.Steps[i] != expected[i] {
+			t.Errorf("Step %d: got %v, want %v", i, p.Steps[i], expected[i])
+		}
+	}
+}
+
+func TestBreathing(t *testing.T) {
+	p := Breathing(time.Second)
+	max := 0.0
+	for _, s := range p.Steps {
+		if s.Level > max {
+			max = s.Level
+		}
+	}
+	if max != 1 || p.Steps[0].Level != 0 {
+		t.Errorf("Levels: got first %v and max %v, want 0 and 1", p.Steps[0].Level, max)
+	}
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	levels []float64
+}
+
+func (r *recorder) set(level float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.levels = append(r.levels, level)
+	return nil
+}
+
+func (r *recorder) last() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.levels[len(r.levels)-1]
+}
+
+func TestBlinker_priority(t *testing.T) {
+	r := &recorder{}
+	b := New(r.set)
+	defer b.Close()
+
+	b.Play(Solid)
+	time.Sleep(10 * time.Millisecond)
+	if r.last() != 1 {
+		t.Fatalf("Solid: got level %v, want 1", r.last())
+	}
+
+	dark := Pattern{Name: "dark", Steps: []Step{off(time.Hour)}, Repeat: true}
+	cancel := b.Play(dark.WithPriority(1))
+	time.Sleep(10 * time.Millisecond)
+	if r.last() != 0 {
+		t.Errorf("Higher priority pattern: got level %v, want 0", r.last())
+	}
+
+	b.Play(FastBlink.WithPriority(-1))
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	if r.last() != 1 {
+		t.Errorf("After cancel: got level %v, want 1", r.last())
+	}
+
+	b.Stop()
+	time.Sleep(10 * time.Millisecond)
+	if r.last() != 0 {
+		t.Errorf("After stop: got level %v, want 0", r.last())
+	}
+}
+
+func TestBlinker_once(t *testing.T) {
+	r := &recorder{}
+	b := New(r.set)
+	defer b.Close()
+
+	b.Play(Solid)
+	b.Play(Pattern{Steps: []Step{off(5 * time.Millisecond)}, Priority: 1})
+	time.Sleep(20 * time.Millisecond)
+	if r.last() != 1 {
+		t.Errorf("After single shot pattern: got level %v, want 1", r.last())
+	}
+}
@@ -0,0 +1,58 @@
+package characterdisplay
+
+import (
+	"context"
+
+	"github.com/kidoman/embd/async"
+)
+
+// AsyncDisplay writes to a display from a bounded queue, for producers
+// which must not wait for a slow controller, like an HD44780 taking 37µs
+// per byte.
+type AsyncDisplay struct {
+	Display *Display
+	Queue   *async.Queue
+}
+
+// NewAsync creates a new AsyncDisplay writing to disp, with up to size
+// operations waiting.
+func NewAsync(disp *Display, size int) *AsyncDisplay {
+	return &AsyncDisplay{Display: disp, Queue: async.NewQueue(size)}
+}
+
+// Do queues an operation on the display, waiting for room in the queue
+// until ctx is done.
+func (a *AsyncDisplay) Do(ctx context.Context, fn func(disp *Display) error) (*async.Future, error) {
+	return a.Queue.Submit(ctx, func() error { return fn(a.Display) })
+}
+
+// TryDo queues an operation on the display, or returns async.ErrFull at
+// once when the queue is full.
+func (a *AsyncDisplay) TryDo(fn func(disp *Display) error) (*async.Future, error) {
+	return a.Queue.TrySubmit(func() error { return fn(a.Display) })
+}
+
+// Message queues writing a message at the cursor, like Display.Message.
+func (a *AsyncDisplay) Message(ctx context.Context, message string) (*async.Future, error) {
+	return a.Do(ctx, func(disp *Display) error { return disp.Message(message) })
+}
+
+// WriteAt queues writing text at a position, like a Region.
+func (a *AsyncDisplay) WriteAt(ctx context.Context, col, row int, text string) (*async.Future, error) {
+	return a.Do(ctx, func(disp *Display) error {
+		if err := disp.SetCursor(col, row); err != nil {
+			return err
+		}
+		return disp.Message(text)
+	})
+}
+
+// Clear queues clearing the display.
+func (a *AsyncDisplay) Clear(ctx context.Context) (*async.Future, error) {
+	return a.Do(ctx, func(disp *Display) error { return disp.Clear() })
+}
+
+// Close waits for the queued operations to run. The display is left open.
+func (a *AsyncDisplay) Close() error {
+	return a.Queue.Close()
+}
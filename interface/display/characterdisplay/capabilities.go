@@ -0,0 +1,184 @@
+package characterdisplay
+
+import (
+	"strings"
+
+	"github.com/kidoman/embd"
+)
+
+// CustomCharWriter is implemented by the controllers which can define
+// custom characters, like the HD44780.
+type CustomCharWriter interface {
+	// CreateChar defines the custom character of code slot (0 to 7) from
+	// the rows of its 5x8 pixels, the top row first and the leftmost
+	// pixel in bit 4.
+	CreateChar(slot byte, rows [8]byte) error
+}
+
+// BacklightDimmer is implemented by the controllers which can dim their
+// backlight.
+type BacklightDimmer interface {
+	// SetBacklight sets the backlight level, from 0 (off) to 1.
+	SetBacklight(level float64) error
+}
+
+// ContrastSetter is implemented by the controllers which can set their
+// contrast, like OLEDs.
+type ContrastSetter interface {
+	// SetContrast sets the contrast, from 0 to 1.
+	SetContrast(level float64) error
+}
+
+// Scroller is implemented by the controllers which can scroll the display
+// as characters are written.
+type Scroller interface {
+	SetAutoScroll(on bool) error
+}
+
+// DoubleHeightSetter is implemented by the controllers which can show lines
+// of double height.
+type DoubleHeightSetter interface {
+	SetDoubleHeight(on bool) error
+}
+
+// RowMapper is implemented by the controllers which do not show all their
+// rows, like the ones merging two lines into a line of double height.
+type RowMapper interface {
+	// RowOrder returns the rows of the controller in the order they show
+	// on a display of n rows, the hidden ones last.
+	RowOrder(n int) []int
+}
+
+// Capability is a set of optional features of a controller.
+type Capability int
+
+const (
+	// CapCustomChars is the capability of defining custom characters.
+	CapCustomChars Capability = 1 << iota
+	// CapBacklightDimming is the capability of dimming the backlight.
+	CapBacklightDimming
+	// CapContrast is the capability of setting the contrast.
+	CapContrast
+	// CapAutoScroll is the capability of scrolling the display.
+	CapAutoScroll
+	// CapDoubleHeight is the capability of showing lines of double height.
+	CapDoubleHeight
+)
+
+var capabilityNames = []string{"custom chars", "backlight dimming", "contrast", "auto scroll", "double height"}
+
+func (c Capability) String() string {
+	var names []string
+	for i, name := range capabilityNames {
+		if c&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// Capabilities returns the optional features of the controller.
+func (disp *Display) Capabilities() Capability {
+	var c Capability
+	if _, ok := disp.Controller.(CustomCharWriter); ok {
+		c |= CapCustomChars
+	}
+	if _, ok := disp.Controller.(BacklightDimmer); ok {
+		c |= CapBacklightDimming
+	}
+	if _, ok := disp.Controller.(ContrastSetter); ok {
+		c |= CapContrast
+	}
+	if _, ok := disp.Controller.(Scroller); ok {
+		c |= CapAutoScroll
+	}
+	if _, ok := disp.Controller.(DoubleHeightSetter); ok {
+		c |= CapDoubleHeight
+	}
+	return c
+}
+
+// Supports returns whether the controller has all the capabilities of c.
+func (disp *Display) Supports(c Capability) bool {
+	return disp.Capabilities()&c == c
+}
+
+// CreateChar defines a custom character. It returns
+// embd.ErrFeatureNotSupported when the controller cannot.
+func (disp *Display) CreateChar(slot byte, rows [8]byte) error {
+	w, ok := disp.Controller.(CustomCharWriter)
+	if !ok {
+		return embd.ErrFeatureNotSupported
+	}
+	return w.CreateChar(slot, rows)
+}
+
+// SetBacklight sets the backlight level, from 0 (off) to 1. Controllers
+// which cannot dim their backlight turn it on for any level above 0.
+func (disp *Display) SetBacklight(level float64) error {
+	if d, ok := disp.Controller.(BacklightDimmer); ok {
+		return d.SetBacklight(level)
+	}
+	if level > 0 {
+		return disp.BacklightOn()
+	}
+	return disp.BacklightOff()
+}
+
+// SetContrast sets the contrast, from 0 to 1. It returns
+// embd.ErrFeatureNotSupported when the controller cannot.
+func (disp *Display) SetContrast(level float64) error {
+	s, ok := disp.Controller.(ContrastSetter)
+	if !ok {
+		return embd.ErrFeatureNotSupported
+	}
+	return s.SetContrast(level)
+}
+
+// SetAutoScroll turns the scrolling of the display as characters are
+// written on or off. It returns embd.ErrFeatureNotSupported when the
+// controller cannot. The contents returned by Contents and restored after
+// toasts do not follow the scrolling.
+func (disp *Display) SetAutoScroll(on bool) error {
+	s, ok := disp.Controller.(Scroller)
+	if !ok {
+		return embd.ErrFeatureNotSupported
+	}
+	return s.SetAutoScroll(on)
+}
+
+// SetDoubleHeight turns lines of double height on or off. The rows of the
+// display then count a line of double height once, and the rows past the
+// visible ones are hidden. It returns embd.ErrFeatureNotSupported when the
+// controller cannot show lines of double height.
+func (disp *Display) SetDoubleHeight(on bool) error {
+	d, ok := disp.Controller.(DoubleHeightSetter)
+	if !ok {
+		return embd.ErrFeatureNotSupported
+	}
+	disp.mu.Lock()
+	defer disp.mu.Unlock()
+
+	if err := d.SetDoubleHeight(on); err != nil {
+		return err
+	}
+	if disp.toasts.showing {
+		return nil
+	}
+	return disp.redraw()
+}
+
+// controllerRow returns the row of the controller showing row.
+func (disp *Display) controllerRow(row int) int {
+	m, ok := disp.Controller.(RowMapper)
+	if !ok {
+		return row
+	}
+	if order := m.RowOrder(disp.rows); row >= 0 && row < len(order) {
+		return order[row]
+	}
+	return row
+}
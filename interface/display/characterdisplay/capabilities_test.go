@@ -0,0 +1,107 @@
+package characterdisplay
+
+import (
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+// dimmable is a controller dimming its backlight and setting its contrast.
+type dimmable struct {
+	screen
+
+	backlight, contrast float64
+}
+
+func (d *dimmable) SetBacklight(level float64) error {
+	d.backlight = level
+	return nil
+}
+
+func (d *dimmable) SetContrast(level float64) error {
+	d.contrast = level
+	return nil
+}
+
+func TestCapabilities(t *testing.T) {
+	d := &dimmable{}
+	disp := New(d, cols, rows)
+	if c := disp.Capabilities(); c != CapBacklightDimming|CapContrast {
+		t.Errorf("expected backlight dimming and contrast, got %v", c)
+	}
+	if err := disp.SetBacklight(0.5); err != nil || d.backlight != 0.5 {
+		t.Errorf("SetBacklight: got %v, %v", d.backlight, err)
+	}
+	if err := disp.SetContrast(0.25); err != nil || d.contrast != 0.25 {
+		t.Errorf("SetContrast: got %v, %v", d.contrast, err)
+	}
+	if err := disp.CreateChar(0, [8]byte{}); err != embd.ErrFeatureNotSupported {
+		t.Errorf("CreateChar: expected ErrFeatureNotSupported, got %v", err)
+	}
+	if err := disp.SetAutoScroll(true); err != embd.ErrFeatureNotSupported {
+		t.Errorf("SetAutoScroll: expected ErrFeatureNotSupported, got %v", err)
+	}
+
+	g := &glyphScreen{glyphs: map[byte][8]byte{}}
+	disp = New(g, cols, rows)
+	if !disp.Supports(CapCustomChars) || disp.Supports(CapCustomChars|CapContrast) {
+		t.Errorf("wrong capabilities %v", disp.Capabilities())
+	}
+	if err := disp.CreateChar(3, [8]byte{1}); err != nil || g.glyphs[3][0] != 1 {
+		t.Errorf("CreateChar: got %v, %v", g.glyphs[3], err)
+	}
+}
+
+func TestSetBacklightFallback(t *testing.T) {
+	mock := &mockController{calls: make(chan call, 2)}
+	disp := New(mock, cols, rows)
+	disp.SetBacklight(0.3)
+	disp.SetBacklight(0)
+	mock.testExpectedCalls([]call{noArgCall("BacklightOn"), noArgCall("BacklightOff")}, t)
+}
+
+// tallScreen merges its first two rows into a line of double height.
+type tallScreen struct {
+	screen
+
+	double bool
+}
+
+func (s *tallScreen) SetDoubleHeight(on bool) error {
+	s.double = on
+	return nil
+}
+
+func (s *tallScreen) RowOrder(n int) []int {
+	if s.double {
+		return []int{0, 2, 3, 1}
+	}
+	return []int{0, 1, 2, 3}
+}
+
+func TestDoubleHeight(t *testing.T) {
+	s := &tallScreen{}
+	disp := New(s, cols, rows)
+	if !disp.Supports(CapDoubleHeight) {
+		t.Fatalf("wrong capabilities %v", disp.Capabilities())
+	}
+	disp.Message("big\nsmall")
+	if err := disp.SetDoubleHeight(true); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.line(2); got[:5] != "small" {
+		t.Errorf("second row shown on controller row 2: got %q", got)
+	}
+	if got := s.line(1); got[:5] != "     " {
+		t.Errorf("hidden controller row 1: got %q", got)
+	}
+	disp.SetCursor(0, 2)
+	disp.Message("x")
+	if got := s.line(3); got[0] != 'x' {
+		t.Errorf("third row shown on controller row 3: got %q", got)
+	}
+
+	if err := New(&dimmable{}, cols, rows).SetDoubleHeight(true); err != embd.ErrFeatureNotSupported {
+		t.Errorf("SetDoubleHeight: expected ErrFeatureNotSupported, got %v", err)
+	}
+}
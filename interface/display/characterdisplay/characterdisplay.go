@@ -4,6 +4,15 @@ display controller.
 */
 package characterdisplay
 
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+)
+
 // Controller is an interface that describes the basic functionality of a character
 // display controller.
 type Controller interface {
@@ -28,8 +37,19 @@ type Controller interface {
 // ease-of-use layer on top of a character display controller.
 type Display struct {
 	Controller
+
+	// Clock times the toasts, clock.Real by default.
+	Clock clock.Clock
+
 	cols, rows int
 	p          *position
+
+	// mu guards the contents and the position, written by the program
+	// and by the toasts.
+	mu       sync.Mutex
+	contents [][]byte
+	toasts   toastQueue
+	closed   bool
 }
 
 type position struct {
@@ -39,29 +59,87 @@ type position struct {
 
 // New creates a new Display
 func New(controller Controller, cols, rows int) *Display {
-	return &Display{
+	disp := &Display{
 		Controller: controller,
 		cols:       cols,
 		rows:       rows,
 		p:          &position{0, 0},
+		contents:   make([][]byte, rows),
+	}
+	disp.clearContents()
+	return disp
+}
+
+func (disp *Display) clearContents() {
+	for row := range disp.contents {
+		disp.contents[row] = []byte(strings.Repeat(" ", disp.cols))
 	}
 }
 
 // Home moves the cursor and all characters to the home position.
 func (disp *Display) Home() error {
+	disp.mu.Lock()
+	defer disp.mu.Unlock()
+
 	disp.setCurrentPosition(0, 0)
+	if disp.toasts.showing {
+		return nil
+	}
 	return disp.Controller.Home()
 }
 
 // Clear clears the display, preserving the mode settings and setting the correct home.
-func (disp *Display) Clear() error {
+func (disp *Display) Clear() (err error) {
+	span := embd.StartSpan("characterdisplay.Clear")
+	defer func() { span.End(err) }()
+
+	disp.mu.Lock()
+	defer disp.mu.Unlock()
+
 	disp.setCurrentPosition(0, 0)
+	disp.clearContents()
+	if disp.toasts.showing {
+		return nil
+	}
 	return disp.Controller.Clear()
 }
 
+// WriteChar writes a character at the cursor position and advances the
+// cursor.
+func (disp *Display) WriteChar(b byte) error {
+	disp.mu.Lock()
+	defer disp.mu.Unlock()
+
+	col, row := disp.p.col, disp.p.row
+	if row >= 0 && row < disp.rows && col >= 0 && col < disp.cols {
+		disp.contents[row][col] = b
+	}
+	disp.p.col++
+	if disp.toasts.showing {
+		return nil
+	}
+	return disp.Controller.WriteChar(b)
+}
+
+// Contents returns the text written to the display, by row, without the
+// toasts.
+func (disp *Display) Contents() []string {
+	disp.mu.Lock()
+	defer disp.mu.Unlock()
+
+	rows := make([]string, disp.rows)
+	for row, text := range disp.contents {
+		rows[row] = string(text)
+	}
+	return rows
+}
+
 // Message prints the given string on the display, including interpreting newline
 // characters and wrapping at the end of lines.
-func (disp *Display) Message(message string) error {
+func (disp *Display) Message(message string) (err error) {
+	span := embd.StartSpan("characterdisplay.Message", embd.Attr{Key: "len", Value: len(message)})
+	defer func() { span.End(err) }()
+
 	bytes := []byte(message)
 	for _, b := range bytes {
 		if b == byte('\n') {
@@ -75,8 +153,10 @@ func (disp *Display) Message(message string) error {
 		if err != nil {
 			return err
 		}
-		disp.p.col++
-		if disp.p.col >= disp.cols || disp.p.col < 0 {
+		disp.mu.Lock()
+		wrap := disp.p.col >= disp.cols || disp.p.col < 0
+		disp.mu.Unlock()
+		if wrap {
 			err := disp.Newline()
 			if err != nil {
 				return err
@@ -88,19 +168,42 @@ func (disp *Display) Message(message string) error {
 
 // Newline moves the input cursor to the beginning of the next line.
 func (disp *Display) Newline() error {
-	return disp.SetCursor(0, disp.p.row+1)
+	disp.mu.Lock()
+	row := disp.p.row
+	disp.mu.Unlock()
+	return disp.SetCursor(0, row+1)
 }
 
 // SetCursor sets the input cursor to the given position.
 func (disp *Display) SetCursor(col, row int) error {
+	disp.mu.Lock()
+	defer disp.mu.Unlock()
+
 	if row >= disp.rows {
 		row = disp.rows - 1
 	}
 	disp.setCurrentPosition(col, row)
-	return disp.Controller.SetCursor(col, row)
+	if disp.toasts.showing {
+		return nil
+	}
+	return disp.Controller.SetCursor(col, disp.controllerRow(row))
 }
 
 func (disp *Display) setCurrentPosition(col, row int) {
 	disp.p.col = col
 	disp.p.row = row
 }
+
+// OpenController opens the controller of a display provider registered
+// with embd.RegisterProvider, like an out-of-tree driver.
+func OpenController(name string, params embd.Params) (Controller, error) {
+	dev, err := embd.Open(embd.DisplayProviders, name, params)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := dev.(Controller)
+	if !ok {
+		return nil, fmt.Errorf("characterdisplay: display %v is not a character display", name)
+	}
+	return c, nil
+}
@@ -127,3 +127,33 @@ func TestMessage_wrap(t *testing.T) {
 
 	mock.testExpectedCalls(expectedCalls, t)
 }
+
+func TestRegionWrite(t *testing.T) {
+	mock := newMockController()
+	disp := New(mock, cols, rows)
+	disp.Region(2, 1, 4).Write("abcdef")
+
+	expectedCalls := []call{
+		call{"SetCursor", []interface{}{2, 1}},
+		call{"WriteChar", []interface{}{byte('a')}},
+		call{"WriteChar", []interface{}{byte('b')}},
+		call{"WriteChar", []interface{}{byte('c')}},
+		call{"WriteChar", []interface{}{byte('d')}},
+	}
+
+	mock.testExpectedCalls(expectedCalls, t)
+}
+
+func TestRegionWrite_pad(t *testing.T) {
+	mock := newMockController()
+	disp := New(mock, cols, rows)
+	disp.Region(cols-2, 0, 4).Write("a")
+
+	expectedCalls := []call{
+		call{"SetCursor", []interface{}{cols - 2, 0}},
+		call{"WriteChar", []interface{}{byte('a')}},
+		call{"WriteChar", []interface{}{byte(' ')}},
+	}
+
+	mock.testExpectedCalls(expectedCalls, t)
+}
@@ -0,0 +1,230 @@
+package characterdisplay
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// HighlightStyle is how a value is highlighted when it changes.
+type HighlightStyle int
+
+const (
+	// Blink blinks the value.
+	Blink HighlightStyle = iota
+	// Invert shows the value in inverted characters, drawn as custom
+	// glyphs. It falls back to Blink when the controller cannot create
+	// glyphs, when there are not enough glyph slots for the distinct
+	// characters of the value, or for characters other than digits,
+	// signs, points and spaces.
+	Invert
+)
+
+const (
+	// DefaultHighlight is how long a changed value is highlighted.
+	DefaultHighlight = time.Second
+	// BlinkInterval is the interval between the blinks of a value.
+	BlinkInterval = 250 * time.Millisecond
+)
+
+// glyphs are the 5x8 patterns of the characters which can be inverted, as
+// in the HD44780 character ROM.
+var glyphs = map[byte][8]byte{
+	'0': {0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e},
+	'1': {0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'2': {0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f},
+	'3': {0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e},
+	'4': {0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02},
+	'5': {0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e},
+	'6': {0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e},
+	'7': {0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e},
+	'9': {0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c},
+	'-': {0x00, 0x00, 0x00, 0x1f, 0x00, 0x00, 0x00},
+	'+': {0x00, 0x04, 0x04, 0x1f, 0x04, 0x04, 0x00},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x0c, 0x0c},
+	' ': {},
+}
+
+// Value is a number shown in a region, highlighted for a while when it
+// changes by more than a threshold.
+type Value struct {
+	Region *Region
+
+	// Threshold is the change from the last highlighted value which is
+	// highlighted, so that slow drifts are eventually highlighted too.
+	Threshold float64
+	// Duration is how long the value is highlighted.
+	Duration time.Duration
+	Style    HighlightStyle
+	// Slots are the glyph slots the inverted characters are created in,
+	// for the Invert style.
+	Slots []byte
+	// Format formats the value, with the shortest representation by
+	// default.
+	Format func(v float64) string
+
+	mu      sync.Mutex
+	last    float64
+	set     bool
+	text    string
+	until   time.Time
+	visible bool
+
+	quit, done chan struct{}
+}
+
+// Value returns a value shown in the region, highlighted when it changes
+// by more than threshold.
+func (r *Region) Value(threshold float64) *Value {
+	return &Value{
+		Region:    r,
+		Threshold: threshold,
+		Duration:  DefaultHighlight,
+	}
+}
+
+func (v *Value) format(x float64) string {
+	if v.Format != nil {
+		return v.Format(x)
+	}
+	return strconv.FormatFloat(x, 'f', -1, 64)
+}
+
+// Set shows a new value. The first value is not highlighted.
+func (v *Value) Set(x float64) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.text = v.format(x)
+	changed := v.set && math.Abs(x-v.last) > v.Threshold
+	if !v.set || changed {
+		v.last, v.set = x, true
+	}
+	if changed {
+		v.until = time.Now().Add(v.Duration)
+		v.visible = true
+		if v.quit == nil {
+			v.quit = make(chan struct{})
+			v.done = make(chan struct{})
+			go v.highlight(v.quit, v.done)
+		}
+	}
+	return v.draw()
+}
+
+// Highlighted returns whether the value is highlighted.
+func (v *Value) Highlighted() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.quit != nil
+}
+
+// draw draws the value as highlighted at the moment. It must be called
+// with the lock held.
+func (v *Value) draw() error {
+	if v.quit == nil {
+		return v.Region.Write(v.text)
+	}
+	if v.Style == Invert {
+		if inverted, ok := v.invert(); ok {
+			return v.Region.Write(inverted)
+		}
+	}
+	if !v.visible {
+		return v.Region.Clear()
+	}
+	return v.Region.Write(v.text)
+}
+
+// invert creates the inverted glyphs of the characters of the value, and
+// returns the text made of them. It must be called with the lock held.
+func (v *Value) invert() (string, bool) {
+	creator, ok := v.Region.disp.Controller.(CustomCharWriter)
+	if !ok {
+		return "", false
+	}
+	text := []byte(v.text)
+	if len(text) > v.Region.width {
+		text = text[:v.Region.width]
+	}
+	for len(text) < v.Region.width {
+		text = append(text, ' ')
+	}
+	slots := map[byte]byte{}
+	for _, c := range text {
+		if _, ok := slots[c]; ok {
+			continue
+		}
+		rows, ok := glyphs[c]
+		if !ok || len(slots) == len(v.Slots) {
+			return "", false
+		}
+		slots[c] = v.Slots[len(slots)]
+		for i := range rows {
+			rows[i] = ^rows[i] & 0x1f
+		}
+		if err := creator.CreateChar(slots[c], rows); err != nil {
+			glog.Errorf("characterdisplay: creating inverted glyph: %v", err)
+			return "", false
+		}
+	}
+	for i, c := range text {
+		text[i] = slots[c]
+	}
+	return string(text), true
+}
+
+func (v *Value) highlight(quit, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(BlinkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			v.mu.Lock()
+			if !now.Before(v.until) {
+				v.quit = nil
+			}
+			v.visible = !v.visible
+			if err := v.draw(); err != nil {
+				glog.Errorf("characterdisplay: highlighting value: %v", err)
+			}
+			over := v.quit == nil
+			v.mu.Unlock()
+			if over {
+				return
+			}
+		case <-quit:
+			return
+		}
+	}
+}
+
+// Close stops highlighting the value, and shows it plainly. Closing it
+// again only draws it again.
+func (v *Value) Close() error {
+	v.mu.Lock()
+	quit, done := v.quit, v.done
+	v.quit = nil
+	v.mu.Unlock()
+
+	if quit != nil {
+		close(quit)
+		<-done
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if !v.set {
+		return nil
+	}
+	return v.draw()
+}
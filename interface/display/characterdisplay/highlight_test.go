@@ -0,0 +1,92 @@
+package characterdisplay
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// glyphScreen is a screen with custom glyphs.
+type glyphScreen struct {
+	screen
+
+	glyphs map[byte][8]byte
+}
+
+func (s *glyphScreen) CreateChar(slot byte, rows [8]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.glyphs[slot] = rows
+	return nil
+}
+
+func TestValueHighlight(t *testing.T) {
+	s := &screen{}
+	disp := New(s, cols, rows)
+	v := disp.Region(0, 0, 4).Value(1)
+	v.Duration = time.Millisecond
+
+	v.Set(20)
+	if v.Highlighted() {
+		t.Error("first value highlighted")
+	}
+	v.Set(20.5)
+	if v.Highlighted() {
+		t.Error("value highlighted below the threshold")
+	}
+	if got := s.line(0)[:4]; got != "20.5" {
+		t.Errorf("expected 20.5, got %q", got)
+	}
+	v.Set(21.5)
+	if !v.Highlighted() {
+		t.Error("value not highlighted above the threshold")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for v.Highlighted() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v.Highlighted() {
+		t.Fatal("highlight not over")
+	}
+	if got := s.line(0)[:4]; got != "21.5" {
+		t.Errorf("expected 21.5 after the highlight, got %q", got)
+	}
+	v.Close()
+}
+
+func TestValueInvert(t *testing.T) {
+	s := &glyphScreen{glyphs: map[byte][8]byte{}}
+	disp := New(s, cols, rows)
+	v := disp.Region(0, 0, 3).Value(0)
+	v.Style = Invert
+	v.Slots = []byte{4, 5, 6, 7}
+
+	v.Set(1)
+	v.Set(-1)
+	if got := s.line(0)[:3]; got != "\x04\x05\x06" {
+		t.Errorf("expected the inverted glyphs, got %q", got)
+	}
+	if s.glyphs[4] != [8]byte{0x1f, 0x1f, 0x1f, 0x00, 0x1f, 0x1f, 0x1f, 0x1f} {
+		t.Errorf("wrong inverted '-' glyph %v", s.glyphs[4])
+	}
+	if s.glyphs[6] != [8]byte{0x1f, 0x1f, 0x1f, 0x1f, 0x1f, 0x1f, 0x1f, 0x1f} {
+		t.Errorf("wrong inverted ' ' glyph %v", s.glyphs[6])
+	}
+
+	// Too many distinct characters for the slots blink instead.
+	v.Region = disp.Region(0, 0, 5)
+	v.Set(-12.5)
+	if got := s.line(0)[:5]; strings.IndexByte(got, 4) >= 0 {
+		t.Errorf("expected the plain value, got %q", got)
+	}
+
+	v.Close()
+	if got := s.line(0)[:5]; got != "-12.5" {
+		t.Errorf("expected the plain value after Close, got %q", got)
+	}
+	if err := v.Close(); err != nil || v.Highlighted() {
+		t.Errorf("closing again: got %v, highlighted %v", err, v.Highlighted())
+	}
+}
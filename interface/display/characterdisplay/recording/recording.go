@@ -0,0 +1,242 @@
+/*
+Package recording records the operations on a character display controller,
+and plays them back onto another one.
+
+Recordings are written as JSON, one operation per line with its time since
+the start of the recording:
+
+	f, _ := os.Create("demo.rec")
+	rec := recording.NewRecorder(hd, f)
+	disp := characterdisplay.New(rec, 16, 2)
+	...
+
+	f, _ = os.Open("demo.rec")
+	recording.Play(f, hd, 1)
+
+Playing a recording onto a Screen, without delays, gives the text it leaves
+on the display, to compare in regression tests:
+
+	screen := recording.NewScreen(16, 2)
+	recording.Play(f, screen, 0)
+	if screen.Lines()[0] != "Hello, world!   " {
+		...
+	}
+*/
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+)
+
+// Event is a recorded operation.
+type Event struct {
+	// Time is the time of the operation since the start of the recording.
+	Time time.Duration `json:"t"`
+	// Op is the name of the method of the controller.
+	Op string `json:"op"`
+	// Args are the arguments of the method: the character of WriteChar,
+	// and the column and row of SetCursor.
+	Args []int `json:"args,omitempty"`
+}
+
+// Recorder is a controller recording the operations on another one.
+type Recorder struct {
+	characterdisplay.Controller
+
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+	err   error
+}
+
+// NewRecorder creates a new recorder of the operations on c, written to w.
+func NewRecorder(c characterdisplay.Controller, w io.Writer) *Recorder {
+	return &Recorder{
+		Controller: c,
+		enc:        json.NewEncoder(w),
+		start:      time.Now(),
+	}
+}
+
+// record writes an operation. A failure to write does not fail the
+// operation, but stops the recording.
+func (r *Recorder) record(op string, args ...int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+	ev := Event{Time: time.Since(r.start), Op: op, Args: args}
+	if err := r.enc.Encode(ev); err != nil {
+		glog.Errorf("recording: stopping: %v", err)
+		r.err = err
+	}
+}
+
+// Err returns the error which stopped the recording, if any.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+// DisplayOff implements characterdisplay.Controller.
+func (r *Recorder) DisplayOff() error {
+	r.record("DisplayOff")
+	return r.Controller.DisplayOff()
+}
+
+// DisplayOn implements characterdisplay.Controller.
+func (r *Recorder) DisplayOn() error {
+	r.record("DisplayOn")
+	return r.Controller.DisplayOn()
+}
+
+// CursorOff implements characterdisplay.Controller.
+func (r *Recorder) CursorOff() error {
+	r.record("CursorOff")
+	return r.Controller.CursorOff()
+}
+
+// CursorOn implements characterdisplay.Controller.
+func (r *Recorder) CursorOn() error {
+	r.record("CursorOn")
+	return r.Controller.CursorOn()
+}
+
+// BlinkOff implements characterdisplay.Controller.
+func (r *Recorder) BlinkOff() error {
+	r.record("BlinkOff")
+	return r.Controller.BlinkOff()
+}
+
+// BlinkOn implements characterdisplay.Controller.
+func (r *Recorder) BlinkOn() error {
+	r.record("BlinkOn")
+	return r.Controller.BlinkOn()
+}
+
+// ShiftLeft implements characterdisplay.Controller.
+func (r *Recorder) ShiftLeft() error {
+	r.record("ShiftLeft")
+	return r.Controller.ShiftLeft()
+}
+
+// ShiftRight implements characterdisplay.Controller.
+func (r *Recorder) ShiftRight() error {
+	r.record("ShiftRight")
+	return r.Controller.ShiftRight()
+}
+
+// BacklightOff implements characterdisplay.Controller.
+func (r *Recorder) BacklightOff() error {
+	r.record("BacklightOff")
+	return r.Controller.BacklightOff()
+}
+
+// BacklightOn implements characterdisplay.Controller.
+func (r *Recorder) BacklightOn() error {
+	r.record("BacklightOn")
+	return r.Controller.BacklightOn()
+}
+
+// Home implements characterdisplay.Controller.
+func (r *Recorder) Home() error {
+	r.record("Home")
+	return r.Controller.Home()
+}
+
+// Clear implements characterdisplay.Controller.
+func (r *Recorder) Clear() error {
+	r.record("Clear")
+	return r.Controller.Clear()
+}
+
+// WriteChar implements characterdisplay.Controller.
+func (r *Recorder) WriteChar(b byte) error {
+	r.record("WriteChar", int(b))
+	return r.Controller.WriteChar(b)
+}
+
+// SetCursor implements characterdisplay.Controller.
+func (r *Recorder) SetCursor(col, row int) error {
+	r.record("SetCursor", col, row)
+	return r.Controller.SetCursor(col, row)
+}
+
+// ReadEvents reads the events of a recording.
+func ReadEvents(r io.Reader) ([]Event, error) {
+	var events []Event
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var ev Event
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			return nil, fmt.Errorf("recording: line %v: %v", n, err)
+		}
+		events = append(events, ev)
+	}
+	return events, scanner.Err()
+}
+
+// Apply applies an event to a controller.
+func Apply(c characterdisplay.Controller, ev Event) error {
+	noArgs := map[string]func() error{
+		"DisplayOff":   c.DisplayOff,
+		"DisplayOn":    c.DisplayOn,
+		"CursorOff":    c.CursorOff,
+		"CursorOn":     c.CursorOn,
+		"BlinkOff":     c.BlinkOff,
+		"BlinkOn":      c.BlinkOn,
+		"ShiftLeft":    c.ShiftLeft,
+		"ShiftRight":   c.ShiftRight,
+		"BacklightOff": c.BacklightOff,
+		"BacklightOn":  c.BacklightOn,
+		"Home":         c.Home,
+		"Clear":        c.Clear,
+	}
+	if f, ok := noArgs[ev.Op]; ok {
+		return f()
+	}
+	switch {
+	case ev.Op == "WriteChar" && len(ev.Args) == 1:
+		return c.WriteChar(byte(ev.Args[0]))
+	case ev.Op == "SetCursor" && len(ev.Args) == 2:
+		return c.SetCursor(ev.Args[0], ev.Args[1])
+	}
+	return fmt.Errorf("recording: invalid event %+v", ev)
+}
+
+// Play plays a recording onto a controller, at the speed relative to the
+// recording, or without delays for a zero speed.
+func Play(r io.Reader, c characterdisplay.Controller, speed float64) error {
+	events, err := ReadEvents(r)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	for _, ev := range events {
+		if speed > 0 {
+			at := start.Add(time.Duration(float64(ev.Time) / speed))
+			time.Sleep(at.Sub(time.Now()))
+		}
+		if err := Apply(c, ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package recording
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+)
+
+func TestRecordAndPlay(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(NewScreen(8, 2), &buf)
+	disp := characterdisplay.New(rec, 8, 2)
+	disp.Message("Hello\nworld")
+	disp.BacklightOff()
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	recorded := rec.Controller.(*Screen).Lines()
+
+	screen := NewScreen(8, 2)
+	if err := Play(bytes.NewReader(buf.Bytes()), screen, 0); err != nil {
+		t.Fatal(err)
+	}
+	if lines := screen.Lines(); !reflect.DeepEqual(lines, recorded) {
+		t.Errorf("played %q, recorded %q", lines, recorded)
+	}
+	if lines := screen.Lines(); lines[0] != "Hello   " || lines[1] != "world   " {
+		t.Errorf("unexpected lines %q", lines)
+	}
+	if screen.Backlight() {
+		t.Error("backlight on after playing")
+	}
+
+	events, err := ReadEvents(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := events[0]; ev.Op != "WriteChar" || !reflect.DeepEqual(ev.Args, []int{'H'}) {
+		t.Errorf("unexpected event %+v", ev)
+	}
+}
+
+func TestPlayTiming(t *testing.T) {
+	recording := `{"t": 0, "op": "Clear"}
+{"t": 40000000, "op": "WriteChar", "args": [65]}
+`
+	start := time.Now()
+	if err := Play(strings.NewReader(recording), NewScreen(8, 2), 2); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("played in %v, expected at least 20ms", d)
+	}
+}
+
+func TestPlayInvalid(t *testing.T) {
+	if err := Play(strings.NewReader(`{"op": "SetCursor", "args": [1]}`), NewScreen(8, 2), 0); err == nil {
+		t.Error("played an invalid event")
+	}
+	if err := Play(strings.NewReader(`{`), NewScreen(8, 2), 0); err == nil {
+		t.Error("played an invalid recording")
+	}
+}
@@ -0,0 +1,137 @@
+package recording
+
+import (
+	"strings"
+	"sync"
+)
+
+// Screen is an in-memory character display controller, keeping the text
+// and the state of the display.
+type Screen struct {
+	cols, rows int
+
+	mu        sync.Mutex
+	text      [][]byte
+	col, row  int
+	shift     int
+	on        bool
+	cursor    bool
+	blink     bool
+	backlight bool
+}
+
+// NewScreen creates a new screen of the given size, turned on.
+func NewScreen(cols, rows int) *Screen {
+	s := &Screen{cols: cols, rows: rows, on: true, backlight: true}
+	s.text = make([][]byte, rows)
+	s.clear()
+	return s
+}
+
+func (s *Screen) clear() {
+	for row := range s.text {
+		s.text[row] = []byte(strings.Repeat(" ", s.cols))
+	}
+	s.col, s.row, s.shift = 0, 0, 0
+}
+
+func (s *Screen) set(f func()) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f()
+	return nil
+}
+
+// DisplayOff implements characterdisplay.Controller.
+func (s *Screen) DisplayOff() error { return s.set(func() { s.on = false }) }
+
+// DisplayOn implements characterdisplay.Controller.
+func (s *Screen) DisplayOn() error { return s.set(func() { s.on = true }) }
+
+// CursorOff implements characterdisplay.Controller.
+func (s *Screen) CursorOff() error { return s.set(func() { s.cursor = false }) }
+
+// CursorOn implements characterdisplay.Controller.
+func (s *Screen) CursorOn() error { return s.set(func() { s.cursor = true }) }
+
+// BlinkOff implements characterdisplay.Controller.
+func (s *Screen) BlinkOff() error { return s.set(func() { s.blink = false }) }
+
+// BlinkOn implements characterdisplay.Controller.
+func (s *Screen) BlinkOn() error { return s.set(func() { s.blink = true }) }
+
+// ShiftLeft implements characterdisplay.Controller.
+func (s *Screen) ShiftLeft() error { return s.set(func() { s.shift-- }) }
+
+// ShiftRight implements characterdisplay.Controller.
+func (s *Screen) ShiftRight() error { return s.set(func() { s.shift++ }) }
+
+// BacklightOff implements characterdisplay.Controller.
+func (s *Screen) BacklightOff() error { return s.set(func() { s.backlight = false }) }
+
+// BacklightOn implements characterdisplay.Controller.
+func (s *Screen) BacklightOn() error { return s.set(func() { s.backlight = true }) }
+
+// Home implements characterdisplay.Controller.
+func (s *Screen) Home() error { return s.set(func() { s.col, s.row, s.shift = 0, 0, 0 }) }
+
+// Clear implements characterdisplay.Controller.
+func (s *Screen) Clear() error { return s.set(s.clear) }
+
+// Close implements characterdisplay.Controller.
+func (s *Screen) Close() error { return nil }
+
+// WriteChar implements characterdisplay.Controller. Characters written
+// past the end of a row are dropped.
+func (s *Screen) WriteChar(b byte) error {
+	return s.set(func() {
+		if s.row >= 0 && s.row < s.rows && s.col >= 0 && s.col < s.cols {
+			s.text[s.row][s.col] = b
+		}
+		s.col++
+	})
+}
+
+// SetCursor implements characterdisplay.Controller.
+func (s *Screen) SetCursor(col, row int) error {
+	return s.set(func() { s.col, s.row = col, row })
+}
+
+// Lines returns the text of the rows, as seen on the display: shifted, and
+// blank while the display is off.
+func (s *Screen) Lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines := make([]string, s.rows)
+	for row, text := range s.text {
+		line := []byte(strings.Repeat(" ", s.cols))
+		if s.on {
+			for col := range line {
+				if src := col - s.shift; src >= 0 && src < s.cols {
+					line[col] = text[src]
+				}
+			}
+		}
+		lines[row] = string(line)
+	}
+	return lines
+}
+
+// Cursor returns the position of the cursor, and whether it is shown and
+// blinking.
+func (s *Screen) Cursor() (col, row int, shown, blink bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.col, s.row, s.cursor, s.blink
+}
+
+// Backlight returns whether the backlight is on.
+func (s *Screen) Backlight() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.backlight
+}
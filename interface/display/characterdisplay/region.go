@@ -0,0 +1,52 @@
+package characterdisplay
+
+// Region represents a fixed-width area on a single row of a Display. Writing
+// to a Region only touches the columns it covers, which allows independent
+// parts of an application to share the same display.
+type Region struct {
+	disp     *Display
+	col, row int
+	width    int
+}
+
+// Region returns a Region of the given width starting at the given position.
+// The width is clipped to the number of columns available on the display.
+func (disp *Display) Region(col, row, width int) *Region {
+	if col+width > disp.cols {
+		width = disp.cols - col
+	}
+	if width < 0 {
+		width = 0
+	}
+	return &Region{disp: disp, col: col, row: row, width: width}
+}
+
+// Width returns the number of columns covered by the region.
+func (r *Region) Width() int {
+	return r.width
+}
+
+// Write writes the given text into the region. The text is truncated if it is
+// longer than the region and padded with spaces if it is shorter, so previous
+// contents are always fully overwritten.
+func (r *Region) Write(text string) error {
+	if err := r.disp.SetCursor(r.col, r.row); err != nil {
+		return err
+	}
+	bytes := []byte(text)
+	for i := 0; i < r.width; i++ {
+		b := byte(' ')
+		if i < len(bytes) {
+			b = bytes[i]
+		}
+		if err := r.disp.WriteChar(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear blanks the region.
+func (r *Region) Clear() error {
+	return r.Write("")
+}
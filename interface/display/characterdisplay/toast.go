@@ -0,0 +1,261 @@
+package characterdisplay
+
+import (
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/clock"
+)
+
+// Message is a message shown over the display, for components sharing it
+// to take turns rather than overwrite each other.
+type Message struct {
+	Text     string
+	Duration time.Duration
+
+	// Priority orders the waiting messages, higher first and in the order
+	// they were shown among equal priorities. A message of higher priority
+	// than the one showing interrupts it, which then shows again for the
+	// rest of its duration.
+	Priority int
+
+	// Key identifies the successive versions of a message, like a status
+	// updated repeatedly; it is the text by default. A message replaces the
+	// waiting or showing one with the same key rather than queue again,
+	// restarting its duration when showing.
+	Key string
+}
+
+type toast struct {
+	Message
+	// left is how long the toast still has to show.
+	left time.Duration
+	// updated is set when the toast is replaced while showing.
+	updated bool
+}
+
+// toastQueue holds the toasts waiting to be shown. Its fields are guarded
+// by the lock of the display.
+type toastQueue struct {
+	pending []*toast
+	current *toast
+	// showing is set while a toast covers the display, the writes then
+	// only updating the contents.
+	showing bool
+
+	// wake interrupts the wait of the toast showing, when it is replaced
+	// or a toast of higher priority arrives.
+	wake       chan struct{}
+	quit, done chan struct{}
+}
+
+// insert queues a toast after the toasts of higher or equal priority, or
+// before those of equal priority when first is set.
+func (q *toastQueue) insert(t *toast, first bool) {
+	i := 0
+	for ; i < len(q.pending); i++ {
+		p := q.pending[i].Priority
+		if p < t.Priority || first && p == t.Priority {
+			break
+		}
+	}
+	q.pending = append(q.pending, nil)
+	copy(q.pending[i+1:], q.pending[i:])
+	q.pending[i] = t
+}
+
+// ShowFor shows a message over the display for d, then restores the
+// contents of the display, including what was written meanwhile. The
+// message is wrapped at the end of the rows and at newlines. Toasts shown
+// while another is showing are queued, and shown in turn.
+func (disp *Display) ShowFor(message string, d time.Duration) {
+	disp.Show(Message{Text: message, Duration: d})
+}
+
+// Show shows a message over the display like ShowFor, taking turns with
+// the other messages by priority. The display goes back to its contents,
+// like the default layout of the application, once no message is left.
+// Messages shown once the display is closed are dropped.
+func (disp *Display) Show(m Message) {
+	if m.Key == "" {
+		m.Key = m.Text
+	}
+	disp.mu.Lock()
+	defer disp.mu.Unlock()
+
+	if disp.closed {
+		return
+	}
+
+	q := &disp.toasts
+	wake := false
+	if c := q.current; c != nil && c.Key == m.Key {
+		c.Message, c.left, c.updated = m, m.Duration, true
+		wake = true
+	} else {
+		for i, p := range q.pending {
+			if p.Key == m.Key {
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+				break
+			}
+		}
+		q.insert(&toast{Message: m, left: m.Duration}, false)
+		wake = c != nil && m.Priority > c.Priority
+	}
+	if q.quit == nil {
+		q.wake = make(chan struct{}, 1)
+		q.quit = make(chan struct{})
+		q.done = make(chan struct{})
+		go disp.showToasts(q.wake, q.quit, q.done)
+		return
+	}
+	if wake {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (disp *Display) showToasts(wake, quit, done chan struct{}) {
+	defer close(done)
+
+	q := &disp.toasts
+	for {
+		disp.mu.Lock()
+		if q.current == nil {
+			if len(q.pending) == 0 {
+				disp.endToasts()
+				disp.mu.Unlock()
+				return
+			}
+			q.current, q.pending = q.pending[0], q.pending[1:]
+		}
+		t := q.current
+		t.updated = false
+		q.showing = true
+		if err := disp.drawToast(t.Text); err != nil {
+			glog.Errorf("characterdisplay: showing toast: %v", err)
+		}
+		left := t.left
+		disp.mu.Unlock()
+
+		c := clock.Or(disp.Clock)
+		start := c.Now()
+		timer := c.NewTimer(left)
+		select {
+		case <-timer.C():
+			disp.mu.Lock()
+			if !t.updated {
+				q.current = nil
+			}
+			disp.mu.Unlock()
+		case <-wake:
+			timer.Stop()
+			disp.mu.Lock()
+			if !t.updated {
+				// Interrupted by a toast of higher priority.
+				t.left -= c.Now().Sub(start)
+				q.current = nil
+				q.insert(t, true)
+			}
+			disp.mu.Unlock()
+		case <-quit:
+			timer.Stop()
+			disp.mu.Lock()
+			q.pending, q.current = nil, nil
+			disp.endToasts()
+			disp.mu.Unlock()
+			return
+		}
+	}
+}
+
+// endToasts restores the contents once the last toast is over. It must be
+// called with the lock held.
+func (disp *Display) endToasts() {
+	disp.toasts.quit = nil
+	if !disp.toasts.showing {
+		return
+	}
+	disp.toasts.showing = false
+	if err := disp.redraw(); err != nil {
+		glog.Errorf("characterdisplay: restoring display after toast: %v", err)
+	}
+}
+
+// toastLines splits a message into the rows of the display.
+func (disp *Display) toastLines(message string) []string {
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		for len(line) > disp.cols {
+			lines = append(lines, line[:disp.cols])
+			line = line[disp.cols:]
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (disp *Display) writeRow(row int, text string) error {
+	if err := disp.Controller.SetCursor(0, disp.controllerRow(row)); err != nil {
+		return err
+	}
+	for col := 0; col < disp.cols; col++ {
+		b := byte(' ')
+		if col < len(text) {
+			b = text[col]
+		}
+		if err := disp.Controller.WriteChar(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drawToast draws a message over the whole display. It must be called with
+// the lock held.
+func (disp *Display) drawToast(message string) error {
+	lines := disp.toastLines(message)
+	for row := 0; row < disp.rows; row++ {
+		text := ""
+		if row < len(lines) {
+			text = lines[row]
+		}
+		if err := disp.writeRow(row, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redraw draws the contents and puts the cursor back. It must be called
+// with the lock held.
+func (disp *Display) redraw() error {
+	for row, text := range disp.contents {
+		if err := disp.writeRow(row, string(text)); err != nil {
+			return err
+		}
+	}
+	return disp.Controller.SetCursor(disp.p.col, disp.controllerRow(disp.p.row))
+}
+
+// Close drops the pending toasts and closes the controller. Closing it
+// again does nothing.
+func (disp *Display) Close() error {
+	disp.mu.Lock()
+	if disp.closed {
+		disp.mu.Unlock()
+		return nil
+	}
+	disp.closed = true
+	quit, done := disp.toasts.quit, disp.toasts.done
+	disp.mu.Unlock()
+
+	if quit != nil {
+		close(quit)
+		<-done
+	}
+	return disp.Controller.Close()
+}
@@ -0,0 +1,170 @@
+package characterdisplay
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// screen is a controller keeping the displayed text.
+type screen struct {
+	mockController
+
+	mu       sync.Mutex
+	text     [rows][cols]byte
+	col, row int
+	closes   int
+}
+
+func (s *screen) WriteChar(b byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.text[s.row][s.col] = b
+	s.col++
+	return nil
+}
+
+func (s *screen) SetCursor(col, row int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.col, s.row = col, row
+	return nil
+}
+
+func (s *screen) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closes++
+	return nil
+}
+
+func (s *screen) line(row int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return string(s.text[row][:])
+}
+
+func pad(text string) string {
+	for len(text) < cols {
+		text += " "
+	}
+	return text
+}
+
+func waitFor(t *testing.T, s *screen, row int, want string) {
+	deadline := time.Now().Add(time.Second)
+	for s.line(row) != pad(want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("row %v: got %q, want %q", row, s.line(row), pad(want))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestShowFor(t *testing.T) {
+	s := &screen{}
+	disp := New(s, cols, rows)
+	disp.Message("temperature 21C")
+
+	disp.ShowFor("saved", 20*time.Millisecond)
+	disp.ShowFor("first line\nsecond", 20*time.Millisecond)
+	waitFor(t, s, 0, "saved")
+
+	// Writes while a toast shows only reach the contents.
+	disp.SetCursor(0, 1)
+	disp.Message("humidity 40%")
+	if got := s.line(1); got != pad("") {
+		t.Errorf("write under toast reached the display: %q", got)
+	}
+
+	waitFor(t, s, 0, "first line")
+	if got := s.line(1); got != pad("second") {
+		t.Errorf("second toast row 1: got %q", got)
+	}
+
+	waitFor(t, s, 0, "temperature 21C")
+	waitFor(t, s, 1, "humidity 40%")
+	s.mu.Lock()
+	if s.col != 12 || s.row != 1 {
+		t.Errorf("cursor: got %v, %v, want 12, 1", s.col, s.row)
+	}
+	s.mu.Unlock()
+	if got := disp.Contents()[1]; got != pad("humidity 40%") {
+		t.Errorf("contents: got %q", got)
+	}
+}
+
+func TestCloseDropsToasts(t *testing.T) {
+	s := &screen{}
+	disp := New(s, cols, rows)
+	disp.Message("idle")
+	disp.ShowFor("long toast", time.Hour)
+	waitFor(t, s, 0, "long toast")
+	// Closing from two goroutines closes the controller once.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := disp.Close(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := s.line(0); got != pad("idle") {
+		t.Errorf("contents not restored on close: got %q", got)
+	}
+	if s.closes != 1 {
+		t.Errorf("controller closed %v times, want once", s.closes)
+	}
+
+	disp.ShowFor("late toast", time.Hour)
+	if disp.toasts.quit != nil {
+		t.Error("toast shown after Close")
+	}
+}
+
+func TestShow_priority(t *testing.T) {
+	s := &screen{}
+	disp := New(s, cols, rows)
+	disp.Message("idle")
+
+	disp.Show(Message{Text: "low", Duration: 50 * time.Millisecond})
+	waitFor(t, s, 0, "low")
+	disp.Show(Message{Text: "later", Duration: 10 * time.Millisecond})
+	disp.Show(Message{Text: "alarm", Duration: 20 * time.Millisecond, Priority: 1})
+
+	// The alarm interrupts the low message, which resumes before the later
+	// one of equal priority.
+	waitFor(t, s, 0, "alarm")
+	waitFor(t, s, 0, "low")
+	waitFor(t, s, 0, "later")
+	waitFor(t, s, 0, "idle")
+}
+
+func TestShow_coalesce(t *testing.T) {
+	s := &screen{}
+	disp := New(s, cols, rows)
+	disp.Message("idle")
+
+	disp.Show(Message{Text: "first", Duration: time.Hour})
+	waitFor(t, s, 0, "first")
+	disp.Show(Message{Text: "battery 20%", Key: "battery", Duration: time.Hour})
+	disp.Show(Message{Text: "battery 15%", Key: "battery", Duration: time.Hour})
+	disp.mu.Lock()
+	if n := len(disp.toasts.pending); n != 1 || disp.toasts.pending[0].Text != "battery 15%" {
+		t.Errorf("pending %v toasts, want the last battery one only", n)
+	}
+	disp.mu.Unlock()
+
+	// Replacing the showing message restarts it.
+	disp.Show(Message{Text: "saving", Key: "first", Duration: 10 * time.Millisecond})
+	waitFor(t, s, 0, "saving")
+	waitFor(t, s, 0, "battery 15%")
+	disp.Close()
+}
@@ -0,0 +1,96 @@
+/*
+Package charset converts text to the character sets of character displays.
+
+The displays show a byte per character from the ROM of their controller,
+so UTF-8 text shows its characters outside ASCII as several wrong glyphs,
+and is wider than it looks:
+
+	region.Write(charset.A00.Encode("21.5°C")) // "21.5\xdfC"
+
+The width of an encoded text on the display is its length in bytes.
+*/
+package charset
+
+import "unicode/utf8"
+
+// Charset maps the characters outside ASCII to their codes in the ROM of a
+// display. Encode transliterates the other characters to ASCII.
+type Charset map[rune]byte
+
+// ASCII is the character set of the displays showing ASCII only.
+var ASCII = Charset{}
+
+// A00 is the character set of the HD44780 with the A00 ROM, the most common
+// one, and of its clones. Its codes 0x5C and 0x7E show ¥ and →, rather than
+// a backslash and a tilde.
+var A00 = Charset{
+	'¥': 0x5C,
+	'→': 0x7E,
+	'←': 0x7F,
+	'°': 0xDF,
+	'α': 0xE0,
+	'ä': 0xE1,
+	'β': 0xE2,
+	'ß': 0xE2,
+	'ε': 0xE3,
+	'µ': 0xE4,
+	'μ': 0xE4,
+	'σ': 0xE5,
+	'ρ': 0xE6,
+	'√': 0xE8,
+	'¢': 0xEC,
+	'ñ': 0xEE,
+	'ö': 0xEF,
+	'θ': 0xF2,
+	'∞': 0xF3,
+	'Ω': 0xF4,
+	'ü': 0xF5,
+	'Σ': 0xF6,
+	'π': 0xF7,
+	'÷': 0xFD,
+	'█': 0xFF,
+}
+
+// transliterations are the ASCII forms of the characters missing from a
+// character set.
+var transliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'å': "a", 'ä': "ae", 'æ': "ae",
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Å': "A", 'Ä': "Ae", 'Æ': "Ae",
+	'ç': "c", 'Ç': "C",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+	'È': "E", 'É': "E", 'Ê': "E", 'Ë': "E",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I",
+	'ñ': "n", 'Ñ': "N",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ø': "o", 'ö': "oe", 'œ': "oe",
+	'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ø': "O", 'Ö': "Oe", 'Œ': "Oe",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "ue",
+	'Ù': "U", 'Ú': "U", 'Û': "U", 'Ü': "Ue",
+	'ý': "y", 'ÿ': "y", 'Ý': "Y",
+	'ß': "ss",
+	'°': "o", 'µ': "u", 'μ': "u",
+	'«': "<<", '»': ">>", '‹': "<", '›': ">",
+	'‘': "'", '’': "'", '“': "\"", '”': "\"",
+	'–': "-", '—': "-", '…': "...",
+	'→': "->", '←': "<-",
+	'\u00a0': " ",
+}
+
+// Encode converts s to the character set. The characters outside ASCII
+// which are missing from it are transliterated to ASCII, or written as '?'.
+func (c Charset) Encode(s string) string {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < utf8.RuneSelf:
+			b = append(b, byte(r))
+		case c[r] != 0:
+			b = append(b, c[r])
+		case transliterations[r] != "":
+			b = append(b, transliterations[r]...)
+		default:
+			b = append(b, '?')
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,25 @@
+package charset
+
+import "testing"
+
+func TestEncode(t *testing.T) {
+	cases := []struct {
+		c    Charset
+		s    string
+		want string
+	}{
+		{A00, "21.5°C", "21.5\xdfC"},
+		{A00, "12µA", "12\xe4A"},
+		{A00, "Mär", "M\xe1r"},
+		{A00, "Äpfel", "Aepfel"},
+		{A00, "déc", "dec"},
+		{ASCII, "21.5°C", "21.5oC"},
+		{ASCII, "Mär", "Maer"},
+		{ASCII, "日", "?"},
+	}
+	for _, c := range cases {
+		if got := c.c.Encode(c.s); got != c.want {
+			t.Errorf("Encode(%q) = %q; want %q", c.s, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+/*
+Package entry allows entering numbers and text on a character display, with
+a keypad, a rotary encoder or buttons.
+
+A Field is an editable area of a row of the display, fed with keys:
+
+	f := entry.New(disp, 5, 1, 6)
+	f.Mode = entry.Numeric
+	f.Mask = '*'
+	f.Run(entry.MatrixKeys(keys))
+	defer f.Close()
+
+	r := <-f.Done()
+	if !r.Cancelled {
+		unlock(r.Value)
+	}
+
+Keypads type characters directly. Encoders and buttons step through the
+characters of the mode with Up and Down, and move along the field with Left
+and Right.
+*/
+package entry
+
+import (
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+	"github.com/kidoman/embd/interface/hotkey"
+	"github.com/kidoman/embd/interface/keypad/matrix4x3"
+)
+
+// Key is a key fed to a field: a character, or one of the editing keys.
+type Key rune
+
+// Editing keys.
+const (
+	Enter Key = -1 - iota
+	Cancel
+	Backspace
+	Left
+	Right
+	Up
+	Down
+	None Key = 0
+)
+
+// Mode is the set of characters a field accepts.
+type Mode string
+
+const (
+	// Numeric fields accept digits.
+	Numeric Mode = "0123456789"
+	// Alphanumeric fields accept uppercase letters, digits and spaces.
+	Alphanumeric Mode = " ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+func (m Mode) index(c byte) int {
+	for i := 0; i < len(m); i++ {
+		if m[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// Result is the outcome of an entry.
+type Result struct {
+	Value     string
+	Cancelled bool
+}
+
+// Field is an editable field on a row of a character display.
+type Field struct {
+	Display         *characterdisplay.Display
+	Col, Row, Width int
+
+	// Mode is the set of accepted characters, Numeric by default.
+	Mode Mode
+	// Mask, when set, is shown instead of the characters, for PINs.
+	Mask byte
+	// MaxLen is the maximum length of the value, the width of the field
+	// by default. Values longer than the field scroll in it, and typing in
+	// a full field replaces its last character.
+	MaxLen int
+	// OnDone, when set, is called with the result of the entry.
+	OnDone func(r Result)
+
+	mu    sync.Mutex
+	value []byte
+	pos   int
+	done  chan Result
+	over  bool
+
+	quit, stopped chan struct{}
+}
+
+// New creates a new numeric field of the given width at the position.
+func New(disp *characterdisplay.Display, col, row, width int) *Field {
+	return &Field{
+		Display: disp,
+		Col:     col,
+		Row:     row,
+		Width:   width,
+		Mode:    Numeric,
+		done:    make(chan Result, 1),
+	}
+}
+
+// Done returns the channel the result of the entry is sent on.
+func (f *Field) Done() <-chan Result {
+	return f.done
+}
+
+// Value returns the value being entered.
+func (f *Field) Value() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return string(f.value)
+}
+
+// SetValue sets the initial value, with the cursor after it.
+func (f *Field) SetValue(v string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.value = []byte(v)
+	f.pos = len(f.value)
+	return f.render()
+}
+
+func (f *Field) maxLen() int {
+	if f.MaxLen > 0 {
+		return f.MaxLen
+	}
+	return f.Width
+}
+
+// render draws the field, scrolled so that the cursor is visible, and puts
+// the blinking cursor at the editing position. It must be called with the
+// lock held.
+func (f *Field) render() error {
+	start := 0
+	if f.pos >= f.Width {
+		start = f.pos - f.Width + 1
+	}
+	if err := f.Display.SetCursor(f.Col, f.Row); err != nil {
+		return err
+	}
+	for i := start; i < start+f.Width; i++ {
+		c := byte(' ')
+		if i < len(f.value) {
+			c = f.value[i]
+			if f.Mask != 0 {
+				c = f.Mask
+			}
+		}
+		if err := f.Display.WriteChar(c); err != nil {
+			return err
+		}
+	}
+	if err := f.Display.SetCursor(f.Col+f.pos-start, f.Row); err != nil {
+		return err
+	}
+	return f.Display.BlinkOn()
+}
+
+// step steps the character at the cursor through the characters of the mode.
+func (f *Field) step(d int) {
+	if f.pos == len(f.value) {
+		if len(f.value) >= f.maxLen() {
+			return
+		}
+		f.value = append(f.value, f.Mode[0])
+		if d > 0 {
+			return
+		}
+	}
+	i := f.Mode.index(f.value[f.pos])
+	n := len(f.Mode)
+	f.value[f.pos] = f.Mode[((i+d)%n+n)%n]
+}
+
+// Feed feeds a key to the field, and returns whether the entry is over.
+func (f *Field) Feed(k Key) (bool, error) {
+	f.mu.Lock()
+	if f.over {
+		f.mu.Unlock()
+		return true, nil
+	}
+	if f.Mode == "" {
+		f.Mode = Numeric
+	}
+
+	switch k {
+	case Enter, Cancel:
+		f.over = true
+		r := Result{Value: string(f.value), Cancelled: k == Cancel}
+		err := f.Display.BlinkOff()
+		f.mu.Unlock()
+		f.finish(r)
+		return true, err
+	case Backspace:
+		if f.pos > 0 {
+			f.value = append(f.value[:f.pos-1], f.value[f.pos:]...)
+			f.pos--
+		}
+	case Left:
+		if f.pos > 0 {
+			f.pos--
+		}
+	case Right:
+		if f.pos < len(f.value) && f.pos < f.maxLen()-1 {
+			f.pos++
+		}
+	case Up:
+		f.step(1)
+	case Down:
+		f.step(-1)
+	default:
+		c := byte(k)
+		if k < 0 || k > 0x7f || f.Mode.index(c) < 0 {
+			glog.V(2).Infof("entry: ignoring key %q", rune(k))
+			f.mu.Unlock()
+			return false, nil
+		}
+		switch {
+		case f.pos < len(f.value):
+			f.value[f.pos] = c
+			if f.pos < f.maxLen()-1 {
+				f.pos++
+			}
+		case len(f.value) < f.maxLen():
+			f.value = append(f.value, c)
+			if f.pos < f.maxLen()-1 {
+				f.pos++
+			}
+		}
+	}
+	err := f.render()
+	f.mu.Unlock()
+	return false, err
+}
+
+func (f *Field) finish(r Result) {
+	select {
+	case f.done <- r:
+	default:
+		glog.Warningf("entry: result channel full, dropping result")
+	}
+	if f.OnDone != nil {
+		f.OnDone(r)
+	}
+}
+
+// Run draws the field and feeds it the keys until the entry is over or the
+// field closed.
+func (f *Field) Run(keys <-chan Key) {
+	f.quit = make(chan struct{})
+	f.stopped = make(chan struct{})
+
+	f.mu.Lock()
+	if err := f.render(); err != nil {
+		glog.Errorf("entry: %v", err)
+	}
+	f.mu.Unlock()
+
+	go func() {
+		defer close(f.stopped)
+
+		for {
+			select {
+			case k, ok := <-keys:
+				if !ok {
+					return
+				}
+				over, err := f.Feed(k)
+				if err != nil {
+					glog.Errorf("entry: %v", err)
+				}
+				if over {
+					return
+				}
+			case <-f.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops feeding the keys to the field.
+func (f *Field) Close() error {
+	if f.quit != nil {
+		close(f.quit)
+		<-f.stopped
+		f.quit = nil
+	}
+	return nil
+}
+
+// MatrixKey maps the keys of a 4x3 keypad: * erases the last character and
+// # enters the value.
+func MatrixKey(k matrix4x3.Key) Key {
+	switch k {
+	case matrix4x3.KNone:
+		return None
+	case matrix4x3.KStar:
+		return Backspace
+	case matrix4x3.KHash:
+		return Enter
+	}
+	return Key(k.String()[0])
+}
+
+// GestureKey maps the gestures of a single button: a click steps the
+// character, a double click moves to the next character and a long press
+// enters the value.
+func GestureKey(g hotkey.Gesture) Key {
+	switch g {
+	case hotkey.Click:
+		return Up
+	case hotkey.DoubleClick:
+		return Right
+	case hotkey.LongPress:
+		return Enter
+	}
+	return None
+}
+
+const keysBuffer = 16
+
+func send(keys chan Key, k Key) {
+	if k == None {
+		return
+	}
+	select {
+	case keys <- k:
+	default:
+		glog.Warningf("entry: key channel full, dropping key %q", rune(k))
+	}
+}
+
+// MatrixKeys returns the keys of the keypad keys received on ch. The keys
+// are dropped while no field reads them.
+func MatrixKeys(ch <-chan matrix4x3.Key) <-chan Key {
+	keys := make(chan Key, keysBuffer)
+	go func() {
+		defer close(keys)
+		for k := range ch {
+			send(keys, MatrixKey(k))
+		}
+	}()
+	return keys
+}
+
+// GestureKeys returns the keys of the button gestures received on ch. The
+// keys are dropped while no field reads them.
+func GestureKeys(ch <-chan hotkey.Gesture) <-chan Key {
+	keys := make(chan Key, keysBuffer)
+	go func() {
+		defer close(keys)
+		for g := range ch {
+			send(keys, GestureKey(g))
+		}
+	}()
+	return keys
+}
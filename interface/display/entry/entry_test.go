@@ -0,0 +1,131 @@
+package entry
+
+import (
+	"testing"
+
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+	"github.com/kidoman/embd/interface/keypad/matrix4x3"
+)
+
+// screen is a character display controller keeping the displayed text.
+type screen struct {
+	rows     [2][16]byte
+	col, row int
+	blink    bool
+}
+
+func (s *screen) DisplayOff() error   { return nil }
+func (s *screen) DisplayOn() error    { return nil }
+func (s *screen) CursorOff() error    { return nil }
+func (s *screen) CursorOn() error     { return nil }
+func (s *screen) BlinkOff() error     { s.blink = false; return nil }
+func (s *screen) BlinkOn() error      { s.blink = true; return nil }
+func (s *screen) ShiftLeft() error    { return nil }
+func (s *screen) ShiftRight() error   { return nil }
+func (s *screen) BacklightOff() error { return nil }
+func (s *screen) BacklightOn() error  { return nil }
+func (s *screen) Home() error         { return nil }
+func (s *screen) Clear() error        { return nil }
+func (s *screen) Close() error        { return nil }
+
+func (s *screen) WriteChar(b byte) error {
+	s.rows[s.row][s.col] = b
+	s.col++
+	return nil
+}
+
+func (s *screen) SetCursor(col, row int) error {
+	s.col, s.row = col, row
+	return nil
+}
+
+func (s *screen) text(col, row, width int) string {
+	return string(s.rows[row][col : col+width])
+}
+
+func feed(t *testing.T, f *Field, keys ...Key) {
+	for _, k := range keys {
+		if _, err := f.Feed(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMaskedPIN(t *testing.T) {
+	s := &screen{}
+	f := New(characterdisplay.New(s, 16, 2), 5, 1, 6)
+	f.Mask = '*'
+
+	feed(t, f, '1', '2', 'x', '3', Backspace, '4')
+	if got := s.text(5, 1, 6); got != "***   " {
+		t.Errorf("field: got %q, want %q", got, "***   ")
+	}
+	if !s.blink || s.col != 8 {
+		t.Errorf("cursor: got column %v blinking %v, want 8 blinking", s.col, s.blink)
+	}
+	if over, _ := f.Feed(Enter); !over {
+		t.Error("entry not over after Enter")
+	}
+	r := <-f.Done()
+	if r.Value != "124" || r.Cancelled {
+		t.Errorf("result: got %+v, want 124", r)
+	}
+	if s.blink {
+		t.Error("cursor still blinking")
+	}
+}
+
+func TestEncoderEntry(t *testing.T) {
+	s := &screen{}
+	f := New(characterdisplay.New(s, 16, 2), 0, 0, 4)
+	f.Mode = Alphanumeric
+
+	// H is 8 steps up from the space, and 9 is one step down.
+	feed(t, f, Up)
+	for i := 0; i < 8; i++ {
+		feed(t, f, Up)
+	}
+	feed(t, f, Right, Down, Right, Up, Left)
+	if f.Value() != "H9 " {
+		t.Errorf("value: got %q, want %q", f.Value(), "H9 ")
+	}
+	if got := s.text(0, 0, 4); got != "H9  " {
+		t.Errorf("field: got %q", got)
+	}
+
+	var got Result
+	f.OnDone = func(r Result) { got = r }
+	feed(t, f, Cancel)
+	if !got.Cancelled {
+		t.Error("cancelled entry not reported")
+	}
+}
+
+func TestScrolling(t *testing.T) {
+	s := &screen{}
+	f := New(characterdisplay.New(s, 16, 2), 0, 0, 3)
+	f.MaxLen = 5
+	for _, k := range "1234567" {
+		feed(t, f, Key(k))
+	}
+	// Typing in a full field replaces its last character.
+	if f.Value() != "12347" {
+		t.Errorf("value: got %q, want 12347", f.Value())
+	}
+	if got := s.text(0, 0, 3); got != "347" {
+		t.Errorf("field: got %q, want the end of the value", got)
+	}
+}
+
+func TestMatrixKey(t *testing.T) {
+	for k, want := range map[matrix4x3.Key]Key{
+		matrix4x3.K0:    '0',
+		matrix4x3.K7:    '7',
+		matrix4x3.KStar: Backspace,
+		matrix4x3.KHash: Enter,
+	} {
+		if got := MatrixKey(k); got != want {
+			t.Errorf("MatrixKey(%v): got %v, want %v", k, got, want)
+		}
+	}
+}
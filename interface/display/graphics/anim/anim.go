@@ -0,0 +1,100 @@
+/*
+Package anim plays animated GIF and PNG (APNG) images on graphical displays.
+
+Frames are composited according to their disposal and blending modes, dithered
+once up front and then sent to the panel with partial redraws:
+
+	f, _ := os.Open("spinner.gif")
+	a, err := anim.Decode(f)
+	...
+	err = a.Play(ctx, panel, graphics.Ordered)
+*/
+package anim
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"time"
+
+	"github.com/kidoman/embd/interface/display/graphics"
+)
+
+// DefaultDelay is used for frames which do not specify a usable delay, in
+// line with what browsers do.
+const DefaultDelay = 100 * time.Millisecond
+
+// Frame is a fully composited frame of an animation.
+type Frame struct {
+	Image image.Image
+	Delay time.Duration
+}
+
+// Animation is a decoded animation.
+type Animation struct {
+	Frames []Frame
+
+	// Loops is the number of times the animation plays; 0 means forever.
+	Loops int
+}
+
+// ErrNoFrames is returned when an image holds no frames.
+var ErrNoFrames = errors.New("anim: no frames")
+
+// Decode decodes an animated GIF or PNG. Still images decode to a single
+// frame animation.
+func Decode(r io.Reader) (*Animation, error) {
+	br := bufio.NewReader(r)
+	sig, err := br.Peek(len(pngSignature))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(sig, []byte(pngSignature)) {
+		return DecodeAPNG(br)
+	}
+	return DecodeGIF(br)
+}
+
+func delay(d time.Duration) time.Duration {
+	if d <= 10*time.Millisecond {
+		return DefaultDelay
+	}
+	return d
+}
+
+// Play shows the animation on d, dithered with the given method, until it
+// finished looping or ctx is done, in which case ctx.Err() is returned.
+func (a *Animation) Play(ctx context.Context, d graphics.Display, m graphics.Method) error {
+	if len(a.Frames) == 0 {
+		return ErrNoFrames
+	}
+
+	frames := make([]*graphics.Mono, len(a.Frames))
+	for i, f := range a.Frames {
+		frames[i] = graphics.Prepare(f.Image, d.Bounds(), m)
+	}
+
+	var prev *graphics.Mono
+	for loop := 0; a.Loops == 0 || loop < a.Loops; loop++ {
+		for i, f := range frames {
+			start := time.Now()
+			if _, err := graphics.Redraw(d, prev, f, 8); err != nil {
+				return err
+			}
+			prev = f
+
+			wait := time.NewTimer(a.Frames[i].Delay - time.Since(start))
+			select {
+			case <-wait.C:
+			case <-ctx.Done():
+				wait.Stop()
+				return ctx.Err()
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package anim
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/interface/display/graphics"
+)
+
+var palette = color.Palette{color.Transparent, color.Black, color.White}
+
+func paletted(r image.Rectangle, idx uint8) *image.Paletted {
+	img := image.NewPaletted(r, palette)
+	for i := range img.Pix {
+		img.Pix[i] = idx
+	}
+	return img
+}
+
+func isWhite(c color.Color) bool {
+	r, g, b, a := c.RGBA()
+	return r == 0xffff && g == 0xffff && b == 0xffff && a == 0xffff
+}
+
+func TestDecodeGIF(t *testing.T) {
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			paletted(image.Rect(0, 0, 4, 4), 1),
+			paletted(image.Rect(0, 0, 2, 2), 2),
+			paletted(image.Rect(2, 2, 4, 4), 2),
+		},
+		Delay:     []int{5, 0, 1},
+		Disposal:  []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone},
+		LoopCount: 2,
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Frames) != 3 || a.Loops != 3 {
+		t.Fatalf("Decode: got %v frames and %v loops, want 3 and 3", len(a.Frames), a.Loops)
+	}
+	if a.Frames[0].Delay != 50*time.Millisecond || a.Frames[1].Delay != DefaultDelay {
+		t.Errorf("Delays: got %v and %v", a.Frames[0].Delay, a.Frames[1].Delay)
+	}
+	if !isWhite(a.Frames[1].Image.At(0, 0)) {
+		t.Error("Frame 1: top left not white")
+	}
+	if isWhite(a.Frames[2].Image.At(0, 0)) || !isWhite(a.Frames[2].Image.At(3, 3)) {
+		t.Error("Frame 2: top left not disposed")
+	}
+}
+
+// encodeAPNG builds an APNG out of full size frames.
+func encodeAPNG(t *testing.T, frames []image.Image, delayMs uint16) []byte {
+	var out bytes.Buffer
+	out.WriteString(pngSignature)
+	seq := uint32(0)
+	for i, img := range frames {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		chunks, err := readChunks(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := img.Bounds()
+		fc := make([]byte, 26)
+		binary.BigEndian.PutUint32(fc, seq)
+		binary.BigEndian.PutUint32(fc[4:], uint32(b.Dx()))
+		binary.BigEndian.PutUint32(fc[8:], uint32(b.Dy()))
+		binary.BigEndian.PutUint16(fc[20:], delayMs)
+		binary.BigEndian.PutUint16(fc[22:], 1000)
+		seq++
+		if i > 0 {
+			writeChunk(&out, "fcTL", fc)
+		}
+		for _, c := range chunks {
+			switch {
+			case c.typ == "IHDR" && i == 0:
+				writeChunk(&out, c.typ, c.data)
+				actl := make([]byte, 8)
+				binary.BigEndian.PutUint32(actl, uint32(len(frames)))
+				writeChunk(&out, "acTL", actl)
+				writeChunk(&out, "fcTL", fc)
+			case c.typ == "IDAT" && i == 0:
+				writeChunk(&out, c.typ, c.data)
+			case c.typ == "IDAT":
+				data := make([]byte, 4, 4+len(c.data))
+				binary.BigEndian.PutUint32(data, seq)
+				writeChunk(&out, "fdAT", append(data, c.data...))
+				seq++
+			}
+		}
+	}
+	writeChunk(&out, "IEND", nil)
+	return out.Bytes()
+}
+
+func TestDecodeAPNG(t *testing.T) {
+	black := image.NewGray(image.Rect(0, 0, 4, 4))
+	white := image.NewGray(image.Rect(0, 0, 4, 4))
+	for i := range white.Pix {
+		white.Pix[i] = 0xff
+	}
+	data := encodeAPNG(t, []image.Image{black, white}, 250)
+
+	a, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Frames) != 2 || a.Loops != 0 {
+		t.Fatalf("Decode: got %v frames and %v loops, want 2 and 0", len(a.Frames), a.Loops)
+	}
+	if a.Frames[0].Delay != 250*time.Millisecond {
+		t.Errorf("Delay: got %v, want 250ms", a.Frames[0].Delay)
+	}
+	if isWhite(a.Frames[0].Image.At(1, 1)) || !isWhite(a.Frames[1].Image.At(1, 1)) {
+		t.Error("Frames: got wrong colors")
+	}
+}
+
+func TestDecodeAPNG_still(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	a, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Frames) != 1 || a.Loops != 1 {
+		t.Errorf("Decode still: got %v frames and %v loops, want 1 and 1", len(a.Frames), a.Loops)
+	}
+}
+
+type mockDisplay struct {
+	draws int
+}
+
+func (d *mockDisplay) Bounds() image.Rectangle {
+	return image.Rect(0, 0, 8, 8)
+}
+
+func (d *mockDisplay) Draw(img *graphics.Mono, r image.Rectangle) error {
+	d.draws++
+	return nil
+}
+
+func TestPlay(t *testing.T) {
+	black := image.NewGray(image.Rect(0, 0, 8, 8))
+	white := image.NewGray(image.Rect(0, 0, 8, 8))
+	for i := range white.Pix {
+		white.Pix[i] = 0xff
+	}
+	a := &Animation{
+		Frames: []Frame{{black, time.Millisecond}, {white, time.Millisecond}},
+		Loops:  2,
+	}
+	d := &mockDisplay{}
+	if err := a.Play(context.Background(), d, graphics.Threshold); err != nil {
+		t.Fatal(err)
+	}
+	if d.draws != 4 {
+		t.Errorf("Draws: got %v, want 4", d.draws)
+	}
+
+	a.Loops = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := a.Play(ctx, d, graphics.Threshold); err != context.DeadlineExceeded {
+		t.Errorf("Play cancelled: got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
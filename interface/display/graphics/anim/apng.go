@@ -0,0 +1,220 @@
+// APNG decoding.
+
+package anim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// APNG dispose and blend operations.
+const (
+	disposeNone       = 0
+	disposeBackground = 1
+	disposePrevious   = 2
+
+	blendSource = 0
+	blendOver   = 1
+)
+
+type chunk struct {
+	typ  string
+	data []byte
+}
+
+type frameControl struct {
+	rect           image.Rectangle
+	delay          time.Duration
+	dispose, blend byte
+	data           []byte
+}
+
+func readChunks(data []byte) ([]chunk, error) {
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return nil, errors.New("anim: not a png image")
+	}
+	data = data[len(pngSignature):]
+
+	var chunks []chunk
+	for len(data) > 0 {
+		if len(data) < 12 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		n := binary.BigEndian.Uint32(data)
+		if uint64(n)+12 > uint64(len(data)) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		chunks = append(chunks, chunk{string(data[4:8]), data[8 : 8+n]})
+		data = data[12+n:]
+	}
+	return chunks, nil
+}
+
+func writeChunk(w *bytes.Buffer, typ string, data []byte) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(len(data)))
+	w.Write(buf[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	crc.Write(data)
+	w.WriteString(typ)
+	w.Write(data)
+	binary.BigEndian.PutUint32(buf[:], crc.Sum32())
+	w.Write(buf[:])
+}
+
+func parseFrameControl(data []byte) (*frameControl, error) {
+	if len(data) != 26 {
+		return nil, fmt.Errorf("anim: invalid fcTL length %v", len(data))
+	}
+	w := int(binary.BigEndian.Uint32(data[4:]))
+	h := int(binary.BigEndian.Uint32(data[8:]))
+	x := int(binary.BigEndian.Uint32(data[12:]))
+	y := int(binary.BigEndian.Uint32(data[16:]))
+	num := time.Duration(binary.BigEndian.Uint16(data[20:]))
+	den := time.Duration(binary.BigEndian.Uint16(data[22:]))
+	if den == 0 {
+		den = 100
+	}
+	return &frameControl{
+		rect:    image.Rect(x, y, x+w, y+h),
+		delay:   delay(num * time.Second / den),
+		dispose: data[24],
+		blend:   data[25],
+	}, nil
+}
+
+// DecodeAPNG decodes an animated PNG. PNGs without animation control decode
+// to a single frame.
+func DecodeAPNG(r io.Reader) (*Animation, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := readChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 || chunks[0].typ != "IHDR" || len(chunks[0].data) != 13 {
+		return nil, errors.New("anim: missing png header")
+	}
+	ihdr := chunks[0].data
+
+	var (
+		animated bool
+		loops    int
+		shared   []chunk // chunks (like PLTE) every frame needs
+		frames   []*frameControl
+		cur      *frameControl
+		seenData bool
+	)
+	for _, c := range chunks[1:] {
+		switch c.typ {
+		case "acTL":
+			if len(c.data) != 8 {
+				return nil, errors.New("anim: invalid acTL chunk")
+			}
+			animated = true
+			loops = int(binary.BigEndian.Uint32(c.data[4:]))
+		case "fcTL":
+			if cur, err = parseFrameControl(c.data); err != nil {
+				return nil, err
+			}
+			frames = append(frames, cur)
+		case "IDAT":
+			seenData = true
+			// The default image is only part of the animation when a
+			// fcTL precedes it.
+			if cur != nil {
+				cur.data = append(cur.data, c.data...)
+			}
+		case "fdAT":
+			if cur == nil || len(c.data) < 4 {
+				return nil, errors.New("anim: unexpected fdAT chunk")
+			}
+			cur.data = append(cur.data, c.data[4:]...)
+		case "IEND":
+		default:
+			if !seenData {
+				shared = append(shared, c)
+			}
+		}
+	}
+
+	if !animated {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return &Animation{Frames: []Frame{{Image: img, Delay: DefaultDelay}}, Loops: 1}, nil
+	}
+	if len(frames) == 0 {
+		return nil, ErrNoFrames
+	}
+
+	width := int(binary.BigEndian.Uint32(ihdr))
+	height := int(binary.BigEndian.Uint32(ihdr[4:]))
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	a := &Animation{Loops: loops}
+	for i, fc := range frames {
+		img, err := decodeFrame(ihdr, shared, fc)
+		if err != nil {
+			return nil, fmt.Errorf("anim: frame %v: %v", i, err)
+		}
+
+		dispose := fc.dispose
+		if i == 0 && dispose == disposePrevious {
+			dispose = disposeBackground
+		}
+		var saved *image.RGBA
+		if dispose == disposePrevious {
+			saved = clone(canvas)
+		}
+
+		op := draw.Over
+		if fc.blend == blendSource {
+			op = draw.Src
+		}
+		draw.Draw(canvas, fc.rect, img, image.Point{}, op)
+		a.Frames = append(a.Frames, Frame{Image: clone(canvas), Delay: fc.delay})
+
+		switch dispose {
+		case disposeBackground:
+			draw.Draw(canvas, fc.rect, image.Transparent, image.Point{}, draw.Src)
+		case disposePrevious:
+			canvas = saved
+		}
+	}
+
+	return a, nil
+}
+
+// decodeFrame decodes a frame by wrapping its data in a standalone png.
+func decodeFrame(ihdr []byte, shared []chunk, fc *frameControl) (image.Image, error) {
+	var buf bytes.Buffer
+	buf.WriteString(pngSignature)
+
+	hdr := make([]byte, len(ihdr))
+	copy(hdr, ihdr)
+	binary.BigEndian.PutUint32(hdr, uint32(fc.rect.Dx()))
+	binary.BigEndian.PutUint32(hdr[4:], uint32(fc.rect.Dy()))
+	writeChunk(&buf, "IHDR", hdr)
+	for _, c := range shared {
+		writeChunk(&buf, c.typ, c.data)
+	}
+	writeChunk(&buf, "IDAT", fc.data)
+	writeChunk(&buf, "IEND", nil)
+
+	return png.Decode(&buf)
+}
@@ -0,0 +1,67 @@
+// GIF decoding.
+
+package anim
+
+import (
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+	"time"
+)
+
+// DecodeGIF decodes an animated GIF.
+func DecodeGIF(r io.Reader) (*Animation, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Image) == 0 {
+		return nil, ErrNoFrames
+	}
+
+	a := &Animation{}
+	switch {
+	case g.LoopCount < 0:
+		a.Loops = 1
+	case g.LoopCount > 0:
+		a.Loops = g.LoopCount + 1
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = g.Image[0].Bounds()
+	}
+	canvas := image.NewRGBA(bounds)
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var saved *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			saved = clone(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		a.Frames = append(a.Frames, Frame{
+			Image: clone(canvas),
+			Delay: delay(time.Duration(g.Delay[i]) * 10 * time.Millisecond),
+		})
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = saved
+		}
+	}
+
+	return a, nil
+}
+
+func clone(img *image.RGBA) *image.RGBA {
+	c := image.NewRGBA(img.Rect)
+	copy(c.Pix, img.Pix)
+	return c
+}
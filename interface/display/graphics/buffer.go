@@ -0,0 +1,135 @@
+// Double buffering.
+
+package graphics
+
+import (
+	"image"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// Buffer double buffers a Display. Drawing happens on a back buffer which is
+// only transferred to the panel on Flush, so a frame is never shown half
+// drawn, and only the rectangle which changed since the previous frame is
+// sent over the bus.
+type Buffer struct {
+	// Align is passed to Diff when computing the region to redraw.
+	Align int
+
+	d Display
+
+	mu      sync.Mutex
+	back    *Mono
+	pending bool
+
+	flushMu sync.Mutex
+	front   *Mono // nil until the first flush
+
+	quit chan struct{}
+}
+
+// NewBuffer returns a Buffer for d.
+func NewBuffer(d Display) *Buffer {
+	return &Buffer{
+		Align: 8,
+		d:     d,
+		back:  NewMono(d.Bounds()),
+	}
+}
+
+// Update calls fn with the back buffer. The buffer must not be retained
+// after fn returns.
+func (b *Buffer) Update(fn func(img *Mono)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	fn(b.back)
+	b.pending = true
+}
+
+// Invalidate causes the next Flush to redraw the whole panel, for example
+// after it was power cycled.
+func (b *Buffer) Invalidate() {
+	b.flushMu.Lock()
+	b.front = nil
+	b.flushMu.Unlock()
+
+	b.mu.Lock()
+	b.pending = true
+	b.mu.Unlock()
+}
+
+// Flush transfers the parts of the back buffer which changed since the last
+// flush to the panel and returns the redrawn rectangle.
+func (b *Buffer) Flush() (image.Rectangle, error) {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	r := Diff(b.front, b.back, b.Align)
+	if b.front == nil {
+		b.front = b.back.Clone()
+	} else {
+		b.front.Copy(b.back, r)
+	}
+	b.pending = false
+	b.mu.Unlock()
+
+	if r.Empty() {
+		return r, nil
+	}
+	span := embd.StartSpan("graphics.Flush", embd.Attr{Key: "rect", Value: r})
+	err := b.d.Draw(b.front, r)
+	span.End(err)
+	if err != nil {
+		// Make sure the panel is redrawn on the next flush.
+		b.front = nil
+		b.mu.Lock()
+		b.pending = true
+		b.mu.Unlock()
+		return r, err
+	}
+	return r, nil
+}
+
+// Run starts flushing the buffer in the background, at most fps times a
+// second and only when it was updated.
+func (b *Buffer) Run(fps int) {
+	if fps <= 0 {
+		fps = 30
+	}
+	b.quit = make(chan struct{})
+
+	go func() {
+		timer := time.NewTicker(time.Second / time.Duration(fps))
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-timer.C:
+				b.mu.Lock()
+				pending := b.pending
+				b.mu.Unlock()
+				if !pending {
+					continue
+				}
+				if _, err := b.Flush(); err != nil {
+					glog.Errorf("graphics: flushing buffer: %v", err)
+				}
+			case <-b.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the flush loop started by Run.
+func (b *Buffer) Close() {
+	if b.quit != nil {
+		close(b.quit)
+		b.quit = nil
+	}
+}
@@ -0,0 +1,66 @@
+// Partial redraws.
+
+package graphics
+
+import "image"
+
+// Display is implemented by graphical display drivers.
+type Display interface {
+	// Bounds returns the area of the panel.
+	Bounds() image.Rectangle
+
+	// Draw transfers the pixels of img within r to the panel. Drivers for
+	// panels supporting partial updates (like most e-paper controllers)
+	// should only refresh r.
+	Draw(img *Mono, r image.Rectangle) error
+}
+
+// Diff returns the smallest rectangle enclosing all the pixels which differ
+// between prev and cur, widened to multiples of align pixels horizontally
+// (e-paper controllers address partial windows in whole bytes, so 8 is a
+// common value). It returns an empty rectangle if nothing changed.
+func Diff(prev, cur *Mono, align int) image.Rectangle {
+	b := cur.Rect
+	if prev == nil || prev.Rect != b {
+		return b
+	}
+
+	var d image.Rectangle
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := (y - b.Min.Y) * cur.Stride
+		for i := 0; i < cur.Stride; i++ {
+			changed := prev.Pix[row+i] ^ cur.Pix[row+i]
+			if changed == 0 {
+				continue
+			}
+			x0, x1 := b.Min.X+i*8, b.Min.X+i*8+8
+			for bit := uint(0); changed&(0x80>>bit) == 0; bit++ {
+				x0++
+			}
+			for bit := uint(0); changed&(1<<bit) == 0; bit++ {
+				x1--
+			}
+			d = d.Union(image.Rect(x0, y, x1, y+1))
+		}
+	}
+	if d.Empty() {
+		return d
+	}
+
+	if align > 1 {
+		d.Min.X = b.Min.X + (d.Min.X-b.Min.X)/align*align
+		d.Max.X = b.Min.X + (d.Max.X-b.Min.X+align-1)/align*align
+	}
+	return d.Intersect(b)
+}
+
+// Redraw sends the parts of cur which differ from prev to d and returns the
+// rectangle which was redrawn. If prev is nil the whole image is drawn. The
+// caller typically keeps cur as the prev of the next call.
+func Redraw(d Display, prev, cur *Mono, align int) (image.Rectangle, error) {
+	r := Diff(prev, cur, align)
+	if r.Empty() {
+		return r, nil
+	}
+	return r, d.Draw(cur, r)
+}
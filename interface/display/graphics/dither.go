@@ -0,0 +1,124 @@
+// Dithering.
+
+package graphics
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Method is the algorithm used to reduce the number of gray levels of an
+// image.
+type Method int
+
+const (
+	// Threshold rounds every pixel to the nearest level. It is the fastest
+	// method and suits line art and text.
+	Threshold Method = iota
+
+	// FloydSteinberg diffuses the quantization error of every pixel to its
+	// neighbours. It gives the best looking results for photos.
+	FloydSteinberg
+
+	// Ordered compares pixels against a 4x4 Bayer matrix. Unlike error
+	// diffusion, a change in one part of the image does not ripple through the
+	// rest of it, which keeps partial redraws small.
+	Ordered
+)
+
+// bayer4 is the 4x4 Bayer threshold matrix.
+var bayer4 = [4][4]int{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// Gray returns a grayscale copy of src.
+func Gray(src image.Image) *image.Gray {
+	if g, ok := src.(*image.Gray); ok {
+		return g
+	}
+	b := src.Bounds()
+	g := image.NewGray(b)
+	draw.Draw(g, b, src, b.Min, draw.Src)
+	return g
+}
+
+// Dither returns a copy of src reduced to the given number of evenly spaced
+// gray levels (2 for monochrome panels, 4 or 16 for grayscale e-paper) using
+// the given method.
+func Dither(src image.Image, levels int, m Method) *image.Gray {
+	if levels < 2 {
+		levels = 2
+	}
+	g := Gray(src)
+	b := g.Bounds()
+	dst := image.NewGray(b)
+	step := 255 / (levels - 1)
+
+	quantize := func(v int) int {
+		if v <= 0 {
+			return 0
+		}
+		if v >= 255 {
+			return 255
+		}
+		return (v + step/2) / step * step
+	}
+
+	switch m {
+	case FloydSteinberg:
+		// Errors for the current and next row, offset by one so that the
+		// neighbours of the edge pixels need no bounds checks.
+		cur := make([]int, b.Dx()+2)
+		next := make([]int, b.Dx()+2)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for i := range next {
+				next[i] = 0
+			}
+			for x := b.Min.X; x < b.Max.X; x++ {
+				i := x - b.Min.X + 1
+				v := int(g.GrayAt(x, y).Y) + cur[i]/16
+				q := quantize(v)
+				dst.SetGray(x, y, color.Gray{uint8(q)})
+				e := v - q
+				cur[i+1] += e * 7
+				next[i-1] += e * 3
+				next[i] += e * 5
+				next[i+1] += e
+			}
+			cur, next = next, cur
+		}
+	case Ordered:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				t := (bayer4[y&3][x&3]*2 + 1 - 16) * step / 32
+				v := int(g.GrayAt(x, y).Y) + t
+				dst.SetGray(x, y, color.Gray{uint8(quantize(v))})
+			}
+		}
+	default:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				dst.SetGray(x, y, color.Gray{uint8(quantize(int(g.GrayAt(x, y).Y)))})
+			}
+		}
+	}
+
+	return dst
+}
+
+// ToMono returns src dithered to black and white using the given method.
+func ToMono(src image.Image, m Method) *Mono {
+	g := Dither(src, 2, m)
+	b := g.Bounds()
+	mono := NewMono(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			mono.SetBit(x, y, g.GrayAt(x, y).Y >= 0x80)
+		}
+	}
+	return mono
+}
@@ -0,0 +1,254 @@
+package graphics
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestMonoSetBit(t *testing.T) {
+	m := NewMono(image.Rect(0, 0, 10, 2))
+	m.SetBit(0, 0, true)
+	m.SetBit(9, 1, true)
+	m.SetBit(10, 1, true)
+	if m.Stride != 2 {
+		t.Fatalf("Stride: got %v, want 2", m.Stride)
+	}
+	expected := []byte{0x80, 0x00, 0x00, 0x40}
+	for i, b := range expected {
+		if m.Pix[i] != b {
+			t.Errorf("Pix[%d]: got %#02x, want %#02x", i, m.Pix[i], b)
+		}
+	}
+	if !m.BitAt(9, 1) || m.BitAt(8, 1) {
+		t.Errorf("BitAt: got wrong pixels %v", m.Pix)
+	}
+	m.Set(9, 1, color.Gray{0x10})
+	if m.BitAt(9, 1) {
+		t.Error("Set dark gray: pixel still white")
+	}
+}
+
+func gradient(w, h int) *image.Gray {
+	g := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			g.SetGray(x, y, color.Gray{uint8(x * 255 / (w - 1))})
+		}
+	}
+	return g
+}
+
+func TestDither_levels(t *testing.T) {
+	for _, m := range []Method{Threshold, FloydSteinberg, Ordered} {
+		for _, levels := range []int{2, 4} {
+			step := 255 / (levels - 1)
+			d := Dither(gradient(64, 8), levels, m)
+			for _, v := range d.Pix {
+				if int(v)%step != 0 {
+					t.Errorf("Method %v, %d levels: got value %d", m, levels, v)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestDither_density(t *testing.T) {
+	g := image.NewGray(image.Rect(0, 0, 16, 16))
+	for i := range g.Pix {
+		g.Pix[i] = 0x40
+	}
+	for _, m := range []Method{FloydSteinberg, Ordered} {
+		mono := ToMono(g, m)
+		white := 0
+		for y := 0; y < 16; y++ {
+			for x := 0; x < 16; x++ {
+				if mono.BitAt(x, y) {
+					white++
+				}
+			}
+		}
+		// A quarter intensity gray should light up about a quarter of the
+		// pixels.
+		if white < 56 || white > 72 {
+			t.Errorf("Method %v: got %d white pixels, want about 64", m, white)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	var tests = []struct {
+		size image.Point
+		r    image.Rectangle
+		fit  image.Rectangle
+	}{
+		{image.Pt(100, 50), image.Rect(0, 0, 200, 200), image.Rect(0, 50, 200, 150)},
+		{image.Pt(50, 100), image.Rect(0, 0, 200, 200), image.Rect(50, 0, 150, 200)},
+		{image.Pt(10, 10), image.Rect(10, 0, 30, 20), image.Rect(10, 0, 30, 20)},
+	}
+	for _, test := range tests {
+		if fit := Fit(test.size, test.r); fit != test.fit {
+			t.Errorf("Fit %v in %v: got %v, want %v", test.size, test.r, fit, test.fit)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 4, 2))
+	copy(src.Pix, []byte{0, 255, 255, 255, 0, 255, 0, 0})
+	dst := Scale(src, image.Rect(0, 0, 2, 1))
+	expected := []byte{127, 127}
+	for i, v := range expected {
+		if dst.Pix[i] != v {
+			t.Errorf("Pix[%d]: got %d, want %d", i, dst.Pix[i], v)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	prev := NewMono(image.Rect(0, 0, 32, 8))
+	cur := prev.Clone()
+	if d := Diff(prev, cur, 1); !d.Empty() {
+		t.Errorf("Diff of equal images: got %v, want empty", d)
+	}
+	if d := Diff(nil, cur, 1); d != cur.Rect {
+		t.Errorf("Diff without previous image: got %v, want %v", d, cur.Rect)
+	}
+
+	cur.SetBit(10, 2, true)
+	cur.SetBit(13, 5, true)
+	if d, expected := Diff(prev, cur, 1), image.Rect(10, 2, 14, 6); d != expected {
+		t.Errorf("Diff: got %v, want %v", d, expected)
+	}
+	if d, expected := Diff(prev, cur, 8), image.Rect(8, 2, 16, 6); d != expected {
+		t.Errorf("Diff aligned: got %v, want %v", d, expected)
+	}
+}
+
+type mockDisplay struct {
+	drawn []image.Rectangle
+}
+
+func (d *mockDisplay) Bounds() image.Rectangle {
+	return image.Rect(0, 0, 32, 8)
+}
+
+func (d *mockDisplay) Draw(img *Mono, r image.Rectangle) error {
+	d.drawn = append(d.drawn, r)
+	return nil
+}
+
+func TestRedraw(t *testing.T) {
+	d := &mockDisplay{}
+	prev := NewMono(d.Bounds())
+	cur := prev.Clone()
+	if _, err := Redraw(d, prev, cur, 8); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.drawn) != 0 {
+		t.Errorf("Redraw unchanged: got %v, want no draws", d.drawn)
+	}
+	cur.SetBit(20, 0, true)
+	if _, err := Redraw(d, prev, cur, 8); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.drawn) != 1 || d.drawn[0] != image.Rect(16, 0, 24, 1) {
+		t.Errorf("Redraw: got %v, want [%v]", d.drawn, image.Rect(16, 0, 24, 1))
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	d := &mockDisplay{}
+	b := NewBuffer(d)
+	if _, err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	b.Update(func(img *Mono) {
+		img.SetBit(3, 4, true)
+	})
+	if _, err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []image.Rectangle{d.Bounds(), image.Rect(0, 4, 8, 5)}
+	if !reflect.DeepEqual(d.drawn, expected) {
+		t.Errorf("Flushes: got %v, want %v", d.drawn, expected)
+	}
+
+	b.Invalidate()
+	if r, _ := b.Flush(); r != d.Bounds() {
+		t.Errorf("Flush after Invalidate: got %v, want %v", r, d.Bounds())
+	}
+}
+
+func square(size int, c color.Gray) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for i := range img.Pix {
+		img.Pix[i] = c.Y
+	}
+	return img
+}
+
+func TestSceneRender(t *testing.T) {
+	img := NewMono(image.Rect(0, 0, 16, 16))
+	sc := NewScene(nil)
+	s := sc.Add(square(4, color.Gray{0xff}), image.Pt(2, 2))
+	if r, expected := sc.Render(img), image.Rect(2, 2, 6, 6); r != expected {
+		t.Errorf("Render: got %v, want %v", r, expected)
+	}
+	if !img.BitAt(2, 2) || !img.BitAt(5, 5) || img.BitAt(6, 6) {
+		t.Error("Render: sprite not drawn")
+	}
+
+	s.Move(image.Pt(4, 2))
+	if r, expected := sc.Render(img), image.Rect(2, 2, 8, 6); r != expected {
+		t.Errorf("Render after move: got %v, want %v", r, expected)
+	}
+	if img.BitAt(2, 2) || !img.BitAt(7, 5) {
+		t.Error("Render after move: sprite not moved")
+	}
+	if r := sc.Render(img); !r.Empty() {
+		t.Errorf("Render unchanged: got %v, want empty", r)
+	}
+}
+
+func TestSceneRender_layers(t *testing.T) {
+	img := NewMono(image.Rect(0, 0, 8, 8))
+	sc := NewScene(square(8, color.Gray{0xff}))
+	sc.Invalidate(img.Rect)
+
+	hole := square(4, color.Gray{0x00})
+	hole.Pix[0] = 0x80
+	top := sc.Add(hole, image.Pt(0, 0))
+	top.SetZ(1)
+	top.SetTransparent(color.Gray{0x80})
+	sc.Add(square(4, color.Gray{0x00}), image.Pt(0, 0)).SetTransparent(color.Gray{0x00})
+	sc.Render(img)
+
+	if !img.BitAt(0, 0) {
+		t.Error("Transparent pixel: got black, want background")
+	}
+	if img.BitAt(1, 1) {
+		t.Error("Top sprite pixel: got white, want black")
+	}
+	if !img.BitAt(5, 5) {
+		t.Error("Background pixel: got black, want white")
+	}
+}
+
+func TestTileMap(t *testing.T) {
+	m := NewTileMap([]image.Image{square(2, color.Gray{0x00}), square(2, color.Gray{0xff})}, image.Pt(2, 2), 3, 2)
+	if r, expected := m.SetTile(2, 1, 1), image.Rect(4, 2, 6, 4); r != expected {
+		t.Errorf("SetTile: got %v, want %v", r, expected)
+	}
+	if c := color.GrayModel.Convert(m.At(5, 3)).(color.Gray); c.Y != 0xff {
+		t.Errorf("At(5, 3): got %v, want white", c)
+	}
+	if c := color.GrayModel.Convert(m.At(1, 3)).(color.Gray); c.Y != 0x00 {
+		t.Errorf("At(1, 3): got %v, want black", c)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Package graphics provides the building blocks shared by graphical display
+drivers: a packed 1-bit image type, an imaging pipeline which scales and
+dithers standard image.Image values for monochrome and grayscale panels, and
+helpers to redraw only the regions of a display which actually changed.
+
+Show a photo on an e-paper panel:
+
+	f, _ := os.Open("photo.jpg")
+	img, _, _ := image.Decode(f)
+	...
+	mono := graphics.Prepare(img, panel.Bounds(), graphics.FloydSteinberg)
+	err := panel.Draw(mono, mono.Bounds())
+*/
+package graphics
+
+import (
+	"image"
+	"image/color"
+)
+
+// MonoModel converts colors to black or white, using a threshold at half
+// intensity.
+var MonoModel = color.ModelFunc(func(c color.Color) color.Color {
+	if on(c) {
+		return color.White
+	}
+	return color.Black
+})
+
+func on(c color.Color) bool {
+	return color.GrayModel.Convert(c).(color.Gray).Y >= 0x80
+}
+
+// Mono is an in-memory 1-bit image. Pixels are packed eight per byte, row by
+// row, most significant bit first, which is the layout used by most e-paper
+// and monochrome TFT controllers. A set bit is a white (lit) pixel.
+type Mono struct {
+	// Pix holds the image's pixels. The bit for the pixel at (x, y) is in
+	// Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)/8] at bit 7-(x-Rect.Min.X)%8.
+	Pix []byte
+
+	// Stride is the Pix stride (in bytes) between vertically adjacent pixels.
+	Stride int
+
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+}
+
+// NewMono returns a new, all black, Mono image with the given bounds.
+func NewMono(r image.Rectangle) *Mono {
+	stride := (r.Dx() + 7) / 8
+	return &Mono{
+		Pix:    make([]byte, stride*r.Dy()),
+		Stride: stride,
+		Rect:   r,
+	}
+}
+
+// ColorModel returns MonoModel.
+func (m *Mono) ColorModel() color.Model {
+	return MonoModel
+}
+
+// Bounds returns the bounds of the image.
+func (m *Mono) Bounds() image.Rectangle {
+	return m.Rect
+}
+
+func (m *Mono) offset(x, y int) (int, byte) {
+	x, y = x-m.Rect.Min.X, y-m.Rect.Min.Y
+	return y*m.Stride + x/8, 0x80 >> uint(x%8)
+}
+
+// BitAt returns true if the pixel at (x, y) is white. Pixels outside the
+// bounds are black.
+func (m *Mono) BitAt(x, y int) bool {
+	if !(image.Point{x, y}.In(m.Rect)) {
+		return false
+	}
+	i, mask := m.offset(x, y)
+	return m.Pix[i]&mask != 0
+}
+
+// SetBit sets the pixel at (x, y) to white if b is true and to black
+// otherwise.
+func (m *Mono) SetBit(x, y int, b bool) {
+	if !(image.Point{x, y}.In(m.Rect)) {
+		return
+	}
+	i, mask := m.offset(x, y)
+	if b {
+		m.Pix[i] |= mask
+	} else {
+		m.Pix[i] &^= mask
+	}
+}
+
+// At returns the color of the pixel at (x, y).
+func (m *Mono) At(x, y int) color.Color {
+	if m.BitAt(x, y) {
+		return color.White
+	}
+	return color.Black
+}
+
+// Set sets the pixel at (x, y) to the given color, converted with MonoModel.
+func (m *Mono) Set(x, y int, c color.Color) {
+	m.SetBit(x, y, on(c))
+}
+
+// Fill sets all the pixels in r to white if b is true and to black otherwise.
+func (m *Mono) Fill(r image.Rectangle, b bool) {
+	r = r.Intersect(m.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			m.SetBit(x, y, b)
+		}
+	}
+}
+
+// Copy copies the pixels of src in r into m, at the same coordinates. Only
+// the part of r within both images is copied.
+func (m *Mono) Copy(src *Mono, r image.Rectangle) {
+	r = r.Intersect(m.Rect).Intersect(src.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			m.SetBit(x, y, src.BitAt(x, y))
+		}
+	}
+}
+
+// Clone returns a copy of the image.
+func (m *Mono) Clone() *Mono {
+	c := &Mono{
+		Pix:    make([]byte, len(m.Pix)),
+		Stride: m.Stride,
+		Rect:   m.Rect,
+	}
+	copy(c.Pix, m.Pix)
+	return c
+}
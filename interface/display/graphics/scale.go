@@ -0,0 +1,90 @@
+// Scaling.
+
+package graphics
+
+import (
+	"image"
+	"image/color"
+)
+
+// Fit returns the largest rectangle with the aspect ratio of size which fits
+// centered in r.
+func Fit(size image.Point, r image.Rectangle) image.Rectangle {
+	if size.X <= 0 || size.Y <= 0 {
+		return image.Rectangle{r.Min, r.Min}
+	}
+	w, h := r.Dx(), r.Dy()
+	if w*size.Y > h*size.X {
+		w = h * size.X / size.Y
+	} else {
+		h = w * size.Y / size.X
+	}
+	min := r.Min.Add(image.Pt((r.Dx()-w)/2, (r.Dy()-h)/2))
+	return image.Rectangle{min, min.Add(image.Pt(w, h))}
+}
+
+// Scale returns a grayscale copy of src resized to r. Pixels are averaged
+// when shrinking, so that fine detail turns into gray tones the dithering
+// can reproduce, and replicated when enlarging.
+func Scale(src image.Image, r image.Rectangle) *image.Gray {
+	g := Gray(src)
+	sb := g.Bounds()
+	dst := image.NewGray(r)
+	w, h := r.Dx(), r.Dy()
+	if w <= 0 || h <= 0 || sb.Empty() {
+		return dst
+	}
+
+	for y := 0; y < h; y++ {
+		sy0 := sb.Min.Y + y*sb.Dy()/h
+		sy1 := sb.Min.Y + (y+1)*sb.Dy()/h
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for x := 0; x < w; x++ {
+			sx0 := sb.Min.X + x*sb.Dx()/w
+			sx1 := sb.Min.X + (x+1)*sb.Dx()/w
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+			sum, n := 0, 0
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					sum += int(g.GrayAt(sx, sy).Y)
+					n++
+				}
+			}
+			dst.SetGray(r.Min.X+x, r.Min.Y+y, color.Gray{uint8(sum / n)})
+		}
+	}
+
+	return dst
+}
+
+// PrepareGray scales src to fit centered in r, keeping its aspect ratio, and
+// dithers it down to the given number of gray levels. The uncovered parts of r
+// are black.
+func PrepareGray(src image.Image, r image.Rectangle, levels int, m Method) *image.Gray {
+	fit := Fit(src.Bounds().Size(), r)
+	scaled := Scale(src, fit)
+	dithered := Dither(scaled, levels, m)
+
+	dst := image.NewGray(r)
+	for y := fit.Min.Y; y < fit.Max.Y; y++ {
+		for x := fit.Min.X; x < fit.Max.X; x++ {
+			dst.SetGray(x, y, dithered.GrayAt(x, y))
+		}
+	}
+	return dst
+}
+
+// Prepare scales src to fit centered in r, keeping its aspect ratio, and
+// dithers it to black and white. The uncovered parts of r are black.
+func Prepare(src image.Image, r image.Rectangle, m Method) *Mono {
+	fit := Fit(src.Bounds().Size(), r)
+	dithered := ToMono(Scale(src, fit), m)
+
+	dst := NewMono(r)
+	dst.Copy(dithered, fit)
+	return dst
+}
@@ -0,0 +1,251 @@
+// Sprites and tiles.
+
+package graphics
+
+import (
+	"image"
+	"image/color"
+	"sort"
+	"sync"
+)
+
+// Sprite is an image positioned on a Scene.
+type Sprite struct {
+	scene *Scene
+
+	img         image.Image
+	pos         image.Point
+	z           int
+	transparent color.Color
+	hidden      bool
+}
+
+// Bounds returns the area covered by the sprite on the scene.
+func (s *Sprite) Bounds() image.Rectangle {
+	b := s.img.Bounds()
+	return b.Sub(b.Min).Add(s.pos)
+}
+
+// Move moves the top left corner of the sprite to p.
+func (s *Sprite) Move(p image.Point) {
+	s.scene.change(s, func() { s.pos = p })
+}
+
+// SetImage changes the image of the sprite, for example to the next frame of
+// an animation.
+func (s *Sprite) SetImage(img image.Image) {
+	s.scene.change(s, func() { s.img = img })
+}
+
+// SetZ changes the layer of the sprite. Sprites with a higher z are drawn on
+// top of the ones with a lower z.
+func (s *Sprite) SetZ(z int) {
+	s.scene.change(s, func() { s.z = z })
+}
+
+// SetTransparent sets the color which is not drawn, letting what is under
+// the sprite show through. Pass nil to draw every pixel.
+func (s *Sprite) SetTransparent(c color.Color) {
+	s.scene.change(s, func() { s.transparent = c })
+}
+
+// Show makes the sprite visible.
+func (s *Sprite) Show() {
+	s.scene.change(s, func() { s.hidden = false })
+}
+
+// Hide makes the sprite invisible.
+func (s *Sprite) Hide() {
+	s.scene.change(s, func() { s.hidden = true })
+}
+
+func (s *Sprite) opaque(x, y int) (color.Color, bool) {
+	b := s.img.Bounds()
+	c := s.img.At(b.Min.X+x-s.pos.X, b.Min.Y+y-s.pos.Y)
+	if s.transparent != nil {
+		r0, g0, b0, a0 := c.RGBA()
+		r1, g1, b1, a1 := s.transparent.RGBA()
+		if r0 == r1 && g0 == g1 && b0 == b1 && a0 == a1 {
+			return nil, false
+		}
+	}
+	if _, _, _, a := c.RGBA(); a < 0x8000 {
+		return nil, false
+	}
+	return c, true
+}
+
+// Scene composes layered sprites over a background and keeps track of the
+// regions which need redrawing between frames.
+type Scene struct {
+	// Background is drawn under the sprites. Pixels outside of it are black.
+	Background image.Image
+
+	mu      sync.Mutex
+	sprites []*Sprite
+	dirty   image.Rectangle
+}
+
+// NewScene returns an empty scene with the given background.
+func NewScene(background image.Image) *Scene {
+	return &Scene{Background: background}
+}
+
+// Add adds a sprite showing img at p to the scene.
+func (sc *Scene) Add(img image.Image, p image.Point) *Sprite {
+	s := &Sprite{scene: sc, img: img, pos: p}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.sprites = append(sc.sprites, s)
+	sc.dirty = sc.dirty.Union(s.Bounds())
+	return s
+}
+
+// Remove removes a sprite from the scene.
+func (sc *Scene) Remove(s *Sprite) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	for i, other := range sc.sprites {
+		if other == s {
+			sc.sprites = append(sc.sprites[:i], sc.sprites[i+1:]...)
+			sc.dirty = sc.dirty.Union(s.Bounds())
+			return
+		}
+	}
+}
+
+// Invalidate marks r as needing a redraw, for example after the background
+// changed.
+func (sc *Scene) Invalidate(r image.Rectangle) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.dirty = sc.dirty.Union(r)
+}
+
+func (sc *Scene) change(s *Sprite, fn func()) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.dirty = sc.dirty.Union(s.Bounds())
+	fn()
+	sc.dirty = sc.dirty.Union(s.Bounds())
+}
+
+// Render redraws the parts of the scene which changed since the previous
+// call into img and returns the redrawn rectangle. The first call should be
+// preceded by an Invalidate of the whole image.
+func (sc *Scene) Render(img *Mono) image.Rectangle {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	r := sc.dirty.Intersect(img.Rect)
+	sc.dirty = image.Rectangle{}
+	if r.Empty() {
+		return r
+	}
+
+	var layers []*Sprite
+	for _, s := range sc.sprites {
+		if !s.hidden && s.Bounds().Overlaps(r) {
+			layers = append(layers, s)
+		}
+	}
+	// Top most first, so that the first opaque pixel found wins.
+	sort.SliceStable(layers, func(i, j int) bool {
+		return layers[i].z > layers[j].z
+	})
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, y, sc.at(layers, x, y))
+		}
+	}
+	return r
+}
+
+func (sc *Scene) at(layers []*Sprite, x, y int) color.Color {
+	p := image.Pt(x, y)
+	for _, s := range layers {
+		if !p.In(s.Bounds()) {
+			continue
+		}
+		if c, ok := s.opaque(x, y); ok {
+			return c
+		}
+	}
+	if sc.Background != nil && p.In(sc.Background.Bounds()) {
+		return sc.Background.At(x, y)
+	}
+	return color.Black
+}
+
+// Draw renders the changed parts of the scene into the back buffer of b.
+func (sc *Scene) Draw(b *Buffer) {
+	b.Update(func(img *Mono) {
+		sc.Render(img)
+	})
+}
+
+// TileMap is an image made of a grid of equally sized tiles, typically used
+// as the background of a Scene.
+type TileMap struct {
+	// Tiles holds the tile images, all of them TileSize large.
+	Tiles    []image.Image
+	TileSize image.Point
+
+	cols, rows int
+	cells      []int
+}
+
+// NewTileMap returns a map of cols by rows cells, all showing tile 0.
+func NewTileMap(tiles []image.Image, size image.Point, cols, rows int) *TileMap {
+	return &TileMap{
+		Tiles:    tiles,
+		TileSize: size,
+		cols:     cols,
+		rows:     rows,
+		cells:    make([]int, cols*rows),
+	}
+}
+
+// SetTile sets the tile shown at the given cell, and returns the area of the
+// map which changed. A negative tile leaves the cell empty.
+func (m *TileMap) SetTile(col, row, tile int) image.Rectangle {
+	if col < 0 || col >= m.cols || row < 0 || row >= m.rows {
+		return image.Rectangle{}
+	}
+	m.cells[row*m.cols+col] = tile
+	min := image.Pt(col*m.TileSize.X, row*m.TileSize.Y)
+	return image.Rectangle{min, min.Add(m.TileSize)}
+}
+
+// ColorModel returns the color model of the first tile.
+func (m *TileMap) ColorModel() color.Model {
+	if len(m.Tiles) == 0 {
+		return color.GrayModel
+	}
+	return m.Tiles[0].ColorModel()
+}
+
+// Bounds returns the area covered by the map.
+func (m *TileMap) Bounds() image.Rectangle {
+	return image.Rect(0, 0, m.cols*m.TileSize.X, m.rows*m.TileSize.Y)
+}
+
+// At returns the color of the pixel at (x, y).
+func (m *TileMap) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(m.Bounds())) {
+		return color.Black
+	}
+	tile := m.cells[y/m.TileSize.Y*m.cols+x/m.TileSize.X]
+	if tile < 0 || tile >= len(m.Tiles) {
+		return color.Black
+	}
+	img := m.Tiles[tile]
+	b := img.Bounds()
+	return img.At(b.Min.X+x%m.TileSize.X, b.Min.Y+y%m.TileSize.Y)
+}
@@ -0,0 +1,200 @@
+/*
+Package text draws text on graphical displays using TrueType and OpenType
+fonts, or any other golang.org/x/image/font face.
+
+Rendered glyphs are cached, so redrawing the same labels over and over (as
+status screens tend to do) only pays for rasterization once:
+
+	face, err := text.Open("/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf", 12)
+	...
+	face.Draw(screen, image.Pt(0, face.Ascent()), "Hello, world!", color.White)
+*/
+package text
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// DPI is the resolution used by Open and Parse. At 72 dpi, one point is one
+// pixel.
+const DPI = 72
+
+type glyph struct {
+	dr      image.Rectangle // relative to the dot
+	mask    *image.Alpha
+	advance fixed.Int26_6
+	ok      bool
+}
+
+// Face is a font.Face which caches rendered glyphs. It is safe for
+// concurrent use.
+type Face struct {
+	mu     sync.Mutex
+	face   font.Face
+	glyphs map[rune]*glyph
+}
+
+// NewFace returns a caching Face wrapping f. Glyphs are always rendered at
+// whole pixel positions.
+func NewFace(f font.Face) *Face {
+	return &Face{
+		face:   f,
+		glyphs: make(map[rune]*glyph),
+	}
+}
+
+// Parse parses a TrueType or OpenType font and returns a face of the given
+// size (in points, which equal pixels at DPI).
+func Parse(data []byte, size float64) (*Face, error) {
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     DPI,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewFace(face), nil
+}
+
+// Open reads a TrueType or OpenType font file and returns a face of the given
+// size.
+func Open(path string, size float64) (*Face, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, size)
+}
+
+// Close satisfies the font.Face interface.
+func (f *Face) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.glyphs = make(map[rune]*glyph)
+	return f.face.Close()
+}
+
+// Glyph satisfies the font.Face interface. The returned mask stays valid
+// after further calls.
+func (f *Face) Glyph(dot fixed.Point26_6, r rune) (dr image.Rectangle, mask image.Image, maskp image.Point, advance fixed.Int26_6, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	g, cached := f.glyphs[r]
+	if !cached {
+		g = f.render(r)
+		f.glyphs[r] = g
+	}
+	if !g.ok {
+		return image.Rectangle{}, nil, image.Point{}, g.advance, false
+	}
+
+	p := image.Pt(dot.X.Round(), dot.Y.Round())
+	return g.dr.Add(p), g.mask, g.mask.Rect.Min, g.advance, true
+}
+
+// render rasterizes r at the origin. The mask returned by the wrapped face
+// may be reused by its next call, so it is copied.
+func (f *Face) render(r rune) *glyph {
+	dr, mask, maskp, advance, ok := f.face.Glyph(fixed.Point26_6{}, r)
+	g := &glyph{dr: dr, advance: advance, ok: ok}
+	if !ok {
+		return g
+	}
+	g.mask = image.NewAlpha(image.Rect(0, 0, dr.Dx(), dr.Dy()))
+	if mask != nil {
+		draw.Draw(g.mask, g.mask.Rect, mask, maskp, draw.Src)
+	}
+	return g
+}
+
+// GlyphBounds satisfies the font.Face interface.
+func (f *Face) GlyphBounds(r rune) (bounds fixed.Rectangle26_6, advance fixed.Int26_6, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.face.GlyphBounds(r)
+}
+
+// GlyphAdvance satisfies the font.Face interface.
+func (f *Face) GlyphAdvance(r rune) (advance fixed.Int26_6, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.face.GlyphAdvance(r)
+}
+
+// Kern satisfies the font.Face interface.
+func (f *Face) Kern(r0, r1 rune) fixed.Int26_6 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.face.Kern(r0, r1)
+}
+
+// Metrics satisfies the font.Face interface.
+func (f *Face) Metrics() font.Metrics {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.face.Metrics()
+}
+
+// Ascent returns the distance (in pixels) from the top of a line to its
+// baseline.
+func (f *Face) Ascent() int {
+	return f.Metrics().Ascent.Ceil()
+}
+
+// Height returns the recommended distance (in pixels) between two baselines.
+func (f *Face) Height() int {
+	return f.Metrics().Height.Ceil()
+}
+
+// Measure returns the width (in pixels) of the widest line of s, including
+// kerning.
+func (f *Face) Measure(s string) int {
+	width := 0
+	for _, line := range strings.Split(s, "\n") {
+		if w := font.MeasureString(f, line).Ceil(); w > width {
+			width = w
+		}
+	}
+	return width
+}
+
+// Draw draws s on dst in the given color with the baseline of the first line
+// starting at pt, and returns the position following the text. Newlines start
+// a new line at pt.X, Height pixels further down.
+func (f *Face) Draw(dst draw.Image, pt image.Point, s string, c color.Color) image.Point {
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: f,
+	}
+	height := f.Metrics().Height
+	d.Dot = fixed.P(pt.X, pt.Y)
+	for i, line := range strings.Split(s, "\n") {
+		if i > 0 {
+			d.Dot.X = fixed.I(pt.X)
+			d.Dot.Y += height
+		}
+		d.DrawString(line)
+	}
+	return image.Pt(d.Dot.X.Round(), d.Dot.Y.Round())
+}
@@ -0,0 +1,87 @@
+package text
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/kidoman/embd/interface/display/graphics"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestParse(t *testing.T) {
+	face, err := Parse(goregular.TTF, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if face.Ascent() <= 0 || face.Height() < face.Ascent() {
+		t.Errorf("Metrics: got ascent %v, height %v", face.Ascent(), face.Height())
+	}
+	if w := face.Measure("ab"); w != face.Measure("a")+face.Measure("b") {
+		t.Errorf("Measure: got %v, want the sum of the glyphs", w)
+	}
+}
+
+type kerningFace struct {
+	font.Face
+}
+
+func (f kerningFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	if r0 == 'A' && r1 == 'V' {
+		return -fixed.I(2)
+	}
+	return 0
+}
+
+func TestMeasure_kerning(t *testing.T) {
+	face := NewFace(kerningFace{basicfont.Face7x13})
+	if w := face.Measure("AV"); w != 12 {
+		t.Errorf("Measure with kerning: got %v, want 12", w)
+	}
+	if w := face.Measure("VA"); w != 14 {
+		t.Errorf("Measure without kerning: got %v, want 14", w)
+	}
+}
+
+func TestGlyph_cached(t *testing.T) {
+	face, err := Parse(goregular.TTF, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, m1, _, _, ok := face.Glyph(fixed.P(0, 16), 'a')
+	if !ok {
+		t.Fatal("Glyph 'a': not found")
+	}
+	face.Glyph(fixed.P(0, 16), 'b')
+	_, m2, _, _, _ := face.Glyph(fixed.P(10, 16), 'a')
+	if m1 != m2 {
+		t.Error("Glyph 'a' twice: got different masks, want cached mask")
+	}
+}
+
+func TestDraw(t *testing.T) {
+	face := NewFace(basicfont.Face7x13)
+	img := graphics.NewMono(image.Rect(0, 0, 32, 32))
+	end := face.Draw(img, image.Pt(0, face.Ascent()), "ab\nc", color.White)
+	if expected := image.Pt(7, face.Ascent()+face.Height()); end != expected {
+		t.Errorf("Draw end: got %v, want %v", end, expected)
+	}
+
+	lit := 0
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			if img.BitAt(x, y) {
+				lit++
+			}
+		}
+	}
+	if lit == 0 {
+		t.Error("Draw: no pixels lit")
+	}
+	if w := face.Measure("ab\nc"); w != 14 {
+		t.Errorf("Measure: got %v, want 14", w)
+	}
+}
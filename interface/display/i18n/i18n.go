@@ -0,0 +1,260 @@
+/*
+Package i18n translates the text shown on displays, so that a device can
+switch the language of its interface at runtime.
+
+The messages of each locale are kept in a catalog. Messages are templates
+with numbered parameters, so that translations can reorder them, and are fit
+to the width of the display by abbreviating words, then truncating. The
+fitted messages are encoded in the character set of the display, Charset:
+
+	t := i18n.New("en")
+	t.Add("en", &i18n.Catalog{
+		Messages: map[string]string{"temp": "Temperature {0}C"},
+		Abbreviations: map[string]string{"Temperature": "Temp"},
+	})
+	t.Add("de", &i18n.Catalog{
+		Messages: map[string]string{"temp": "Temperatur {0}C"},
+	})
+	t.SetLocale("de")
+	region.Write(t.Fit("temp", region.Width(), 21.5))
+*/
+package i18n
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/interface/display/charset"
+)
+
+// Charset is the character set of the display the messages are fit to, a
+// byte per character.
+var Charset = charset.A00
+
+// Catalog is the messages of a locale.
+type Catalog struct {
+	// Messages are the templates of the messages, by key. {0}, {1}, ... are
+	// replaced by the parameters, and {{ by {.
+	Messages map[string]string `json:"messages"`
+	// Abbreviations are the short forms of words, used when a message does
+	// not fit the display.
+	Abbreviations map[string]string `json:"abbreviations,omitempty"`
+	// Ellipsis ends the truncated messages, none by default.
+	Ellipsis string `json:"ellipsis,omitempty"`
+}
+
+// LoadCatalog reads a catalog encoded in JSON.
+func LoadCatalog(r io.Reader) (*Catalog, error) {
+	var c Catalog
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, fmt.Errorf("i18n: loading catalog: %v", err)
+	}
+	return &c, nil
+}
+
+// Translator translates the messages in the current locale.
+type Translator struct {
+	// Fallback is the locale of the messages missing from the current one.
+	Fallback string
+
+	mu       sync.RWMutex
+	locale   string
+	catalogs map[string]*Catalog
+}
+
+// New creates a new translator, with the locale as current and fallback
+// locale.
+func New(locale string) *Translator {
+	return &Translator{
+		Fallback: locale,
+		locale:   locale,
+		catalogs: map[string]*Catalog{},
+	}
+}
+
+// Add adds the catalog of a locale, replacing any previous one.
+func (t *Translator) Add(locale string, c *Catalog) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.catalogs[locale] = c
+}
+
+// Locales returns the sorted locales with a catalog.
+func (t *Translator) Locales() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	locales := make([]string, 0, len(t.catalogs))
+	for l := range t.catalogs {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Locale returns the current locale.
+func (t *Translator) Locale() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.locale
+}
+
+// SetLocale switches the current locale. The text already on the display
+// has to be redrawn.
+func (t *Translator) SetLocale(locale string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.catalogs[locale]; !ok {
+		return fmt.Errorf("i18n: no catalog for locale %v", locale)
+	}
+	t.locale = locale
+	return nil
+}
+
+// lookup returns the template of a message and the catalog it is from, in
+// the current locale or else in the fallback one. It must be called with the
+// lock held.
+func (t *Translator) lookup(key string) (string, *Catalog) {
+	for _, l := range []string{t.locale, t.Fallback} {
+		if c, ok := t.catalogs[l]; ok {
+			if tmpl, ok := c.Messages[key]; ok {
+				return tmpl, c
+			}
+		}
+	}
+	glog.V(1).Infof("i18n: no message %q in locale %v", key, t.locale)
+	return key, nil
+}
+
+// T returns the message in the current locale, with its parameters. A
+// missing message is looked up in the fallback locale, and then is the key
+// itself. The message is left in UTF-8; Fit encodes it for the display.
+func (t *Translator) T(key string, args ...interface{}) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	tmpl, _ := t.lookup(key)
+	return expand(tmpl, args)
+}
+
+// Fit returns the message in the current locale, fit to width characters of
+// the display and encoded in Charset.
+func (t *Translator) Fit(key string, width int, args ...interface{}) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	tmpl, c := t.lookup(key)
+	if c == nil {
+		c = &Catalog{}
+	}
+	return c.Fit(expand(tmpl, args), width)
+}
+
+// expand replaces the parameters of a template.
+func expand(tmpl string, args []interface{}) string {
+	var b bytes.Buffer
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '{' {
+			b.WriteByte(tmpl[i])
+			continue
+		}
+		if strings.HasPrefix(tmpl[i:], "{{") {
+			b.WriteByte('{')
+			i++
+			continue
+		}
+		end := strings.IndexByte(tmpl[i:], '}')
+		if end < 0 {
+			b.WriteString(tmpl[i:])
+			break
+		}
+		n, err := strconv.Atoi(tmpl[i+1 : i+end])
+		if err != nil || n < 0 || n >= len(args) {
+			// Unknown parameters are left in the message, to be noticed.
+			b.WriteString(tmpl[i : i+end+1])
+		} else {
+			fmt.Fprint(&b, args[n])
+		}
+		i += end
+	}
+	return b.String()
+}
+
+// Fit fits the text to width characters of the display and encodes it in
+// Charset: the words are abbreviated, the longest first, until it fits, and
+// it is truncated otherwise.
+func (c *Catalog) Fit(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if s := Charset.Encode(text); len(s) <= width {
+		return s
+	}
+
+	words := make([]string, 0, len(c.Abbreviations))
+	for w := range c.Abbreviations {
+		words = append(words, w)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if len(words[i]) != len(words[j]) {
+			return len(words[i]) > len(words[j])
+		}
+		return words[i] < words[j]
+	})
+	for _, w := range words {
+		text = replaceWord(text, w, c.Abbreviations[w])
+		if s := Charset.Encode(text); len(s) <= width {
+			return s
+		}
+	}
+
+	return truncate(Charset.Encode(text), width, Charset.Encode(c.Ellipsis))
+}
+
+// replaceWord replaces the whole words w of s.
+func replaceWord(s, w, abbr string) string {
+	var b bytes.Buffer
+	for {
+		i := strings.Index(s, w)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end := i + len(w)
+		if boundary(s, i-1) && boundary(s, end) {
+			b.WriteString(s[:i])
+			b.WriteString(abbr)
+		} else {
+			b.WriteString(s[:end])
+		}
+		s = s[end:]
+	}
+}
+
+// boundary returns whether the byte at i of s is outside of a word.
+func boundary(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return true
+	}
+	c := s[i]
+	return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80)
+}
+
+// truncate truncates s to width bytes, a character each once encoded,
+// ending it with the ellipsis.
+func truncate(s string, width int, ellipsis string) string {
+	if len(ellipsis) >= width {
+		return s[:width]
+	}
+	return strings.TrimRight(s[:width-len(ellipsis)], " ") + ellipsis
+}
@@ -0,0 +1,108 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func translator() *Translator {
+	t := New("en")
+	t.Add("en", &Catalog{
+		Messages: map[string]string{
+			"temp":  "Temperature {0}C",
+			"range": "From {0} to {1}",
+			"brace": "{{0} {2}",
+		},
+		Abbreviations: map[string]string{"Temperature": "Temp."},
+		Ellipsis:      "~",
+	})
+	t.Add("fr", &Catalog{
+		Messages: map[string]string{
+			"range": "Jusqu'à {1} depuis {0}",
+		},
+	})
+	return t
+}
+
+func TestT(t *testing.T) {
+	tr := translator()
+	cases := []struct {
+		locale, key string
+		args        []interface{}
+		expected    string
+	}{
+		{"en", "temp", []interface{}{21.5}, "Temperature 21.5C"},
+		{"en", "range", []interface{}{1, 2}, "From 1 to 2"},
+		{"en", "brace", []interface{}{1}, "{0} {2}"},
+		{"fr", "range", []interface{}{1, 2}, "Jusqu'à 2 depuis 1"},
+		{"fr", "temp", []interface{}{3}, "Temperature 3C"},
+		{"fr", "missing", nil, "missing"},
+	}
+	for _, c := range cases {
+		if err := tr.SetLocale(c.locale); err != nil {
+			t.Fatal(err)
+		}
+		if got := tr.T(c.key, c.args...); got != c.expected {
+			t.Errorf("%v %v: expected %q, got %q", c.locale, c.key, c.expected, got)
+		}
+	}
+	if err := tr.SetLocale("de"); err == nil {
+		t.Error("switched to a locale without a catalog")
+	}
+	if tr.Locale() != "fr" {
+		t.Errorf("locale: expected fr, got %v", tr.Locale())
+	}
+}
+
+func TestFit(t *testing.T) {
+	tr := translator()
+	cases := []struct {
+		width    int
+		expected string
+	}{
+		{20, "Temperature 21.5C"},
+		{12, "Temp. 21.5C"},
+		{8, "Temp. 2~"},
+		{1, "T"},
+		{0, ""},
+	}
+	for _, c := range cases {
+		if got := tr.Fit("temp", c.width, 21.5); got != c.expected {
+			t.Errorf("width %v: expected %q, got %q", c.width, c.expected, got)
+		}
+	}
+
+	// The message is measured and truncated once encoded for the display.
+	tr.SetLocale("fr")
+	if got := tr.Fit("range", 10, 1, 2); got != "Jusqu'a 2" {
+		t.Errorf("expected the display characters to be counted, got %q", got)
+	}
+	tr.Add("de", &Catalog{Messages: map[string]string{"temp": "Außen {0}°C"}})
+	tr.SetLocale("de")
+	if got := tr.Fit("temp", 12, 21.5); got != "Au\xe2en 21.5\xdfC" || len(got) != 12 {
+		t.Errorf("expected the A00 codes, got %q", got)
+	}
+	if got := tr.Fit("temp", 3, 21.5); got != "Au\xe2" {
+		t.Errorf("expected whole characters truncated, got %q", got)
+	}
+}
+
+func TestReplaceWord(t *testing.T) {
+	got := replaceWord("Set SetPoint Set", "Set", "S")
+	if got != "S SetPoint S" {
+		t.Errorf("expected whole words replaced, got %q", got)
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	c, err := LoadCatalog(strings.NewReader(`{"messages": {"ok": "OK"}, "ellipsis": "."}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Messages["ok"] != "OK" || c.Ellipsis != "." {
+		t.Errorf("unexpected catalog %+v", c)
+	}
+	if _, err := LoadCatalog(strings.NewReader("{")); err == nil {
+		t.Error("loaded an invalid catalog")
+	}
+}
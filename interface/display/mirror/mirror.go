@@ -0,0 +1,482 @@
+/*
+Package mirror mirrors the displays of a device to browsers, and drives its
+user interface from them, for remote support and demos.
+
+The mirror is an http.Handler. Browsers get a viewer page, which connects
+back with a WebSocket to receive the changes of the displays and send the
+presses of the virtual buttons and the steps of the virtual encoders:
+
+	m := mirror.New()
+	m.Text = disp
+	buf := graphics.NewBuffer(m.Tap(oled))
+	page := m.AddButton("page")
+	volume := m.AddEncoder("volume")
+	http.Handle("/panel/", m)
+	go http.ListenAndServe(":8080", nil)
+
+Only the rows of the character display and the pixels of the graphical
+display which changed are sent.
+*/
+package mirror
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"image"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+	"github.com/kidoman/embd/interface/display/graphics"
+	"github.com/kidoman/embd/interface/hotkey"
+)
+
+const (
+	// DefaultPoll is how often the character display is checked for
+	// changes.
+	DefaultPoll = 100 * time.Millisecond
+
+	eventsBuffer = 16
+)
+
+// Mirror mirrors displays to browsers.
+type Mirror struct {
+	// Text is the character display mirrored, if any.
+	Text *characterdisplay.Display
+	// Poll is how often the character display is checked for changes.
+	Poll time.Duration
+	// AllowedOrigins are the origins of the pages, other than the viewer
+	// served by the mirror, allowed to connect, like
+	// "https://dashboard.local", or "*" for any. The WebSocket drives the
+	// buttons and encoders, so pages of other origins are refused by
+	// default.
+	AllowedOrigins []string
+
+	mu       sync.Mutex
+	pixels   *graphics.Mono
+	clients  map[*client]struct{}
+	buttons  map[string]chan hotkey.Gesture
+	encoders map[string]chan int
+}
+
+type client struct {
+	conn   net.Conn
+	w      *bufio.Writer
+	wmu    sync.Mutex
+	dirty  image.Rectangle
+	notify chan struct{}
+	quit   chan struct{}
+	once   sync.Once
+}
+
+func (c *client) end() {
+	c.once.Do(func() { close(c.quit) })
+}
+
+func (c *client) write(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	return writeFrame(c.w, op, payload)
+}
+
+func (c *client) send(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.write(opText, data)
+}
+
+// New creates a new mirror.
+func New() *Mirror {
+	return &Mirror{
+		Poll:     DefaultPoll,
+		clients:  map[*client]struct{}{},
+		buttons:  map[string]chan hotkey.Gesture{},
+		encoders: map[string]chan int{},
+	}
+}
+
+type tap struct {
+	graphics.Display
+
+	m *Mirror
+}
+
+// Tap returns a graphical display drawing on d, and mirroring what is drawn.
+func (m *Mirror) Tap(d graphics.Display) graphics.Display {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pixels = graphics.NewMono(d.Bounds())
+	return &tap{Display: d, m: m}
+}
+
+func (t *tap) Draw(img *graphics.Mono, r image.Rectangle) error {
+	t.m.drawn(img, r)
+	return t.Display.Draw(img, r)
+}
+
+// drawn records the pixels drawn in r, and notifies the clients.
+func (m *Mirror) drawn(img *graphics.Mono, r image.Rectangle) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r = r.Intersect(m.pixels.Bounds())
+	if r.Empty() {
+		return
+	}
+	m.pixels.Copy(img, r)
+	for c := range m.clients {
+		c.dirty = c.dirty.Union(r)
+		select {
+		case c.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// AddButton adds a virtual button, and returns the channel its gestures are
+// sent on. Gestures are dropped when the channel is full.
+func (m *Mirror) AddButton(name string) <-chan hotkey.Gesture {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch := make(chan hotkey.Gesture, eventsBuffer)
+	m.buttons[name] = ch
+	return ch
+}
+
+// AddEncoder adds a virtual rotary encoder, and returns the channel its
+// steps are sent on, positive clockwise. Steps are dropped when the channel
+// is full.
+func (m *Mirror) AddEncoder(name string) <-chan int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch := make(chan int, eventsBuffer)
+	m.encoders[name] = ch
+	return ch
+}
+
+// Input is an event sent by a browser.
+type Input struct {
+	Button  string `json:"button,omitempty"`
+	Gesture string `json:"gesture,omitempty"`
+	Encoder string `json:"encoder,omitempty"`
+	Steps   int    `json:"steps,omitempty"`
+}
+
+// Feed feeds an input event to the virtual button or encoder it is for.
+func (m *Mirror) Feed(in Input) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case in.Button != "":
+		ch, ok := m.buttons[in.Button]
+		if !ok {
+			return fmt.Errorf("mirror: unknown button %q", in.Button)
+		}
+		g, err := hotkey.ParseGesture(in.Gesture)
+		if err != nil {
+			return err
+		}
+		select {
+		case ch <- g:
+		default:
+			glog.Warningf("mirror: dropping %v of %v, gestures are not being read", g, in.Button)
+		}
+	case in.Encoder != "":
+		ch, ok := m.encoders[in.Encoder]
+		if !ok {
+			return fmt.Errorf("mirror: unknown encoder %q", in.Encoder)
+		}
+		select {
+		case ch <- in.Steps:
+		default:
+			glog.Warningf("mirror: dropping %v steps of %v, steps are not being read", in.Steps, in.Encoder)
+		}
+	default:
+		return fmt.Errorf("mirror: invalid input %+v", in)
+	}
+	return nil
+}
+
+// Messages sent to the browsers.
+type (
+	setupMessage struct {
+		Type     string   `json:"type"`
+		Cols     int      `json:"cols,omitempty"`
+		Rows     int      `json:"rows,omitempty"`
+		Width    int      `json:"width,omitempty"`
+		Height   int      `json:"height,omitempty"`
+		Buttons  []string `json:"buttons"`
+		Encoders []string `json:"encoders"`
+	}
+	textMessage struct {
+		Type string `json:"type"`
+		Row  int    `json:"row"`
+		Text string `json:"text"`
+	}
+	// pixelsMessage carries the pixels of a rectangle, packed eight per
+	// byte, row by row, most significant bit first.
+	pixelsMessage struct {
+		Type string `json:"type"`
+		X    int    `json:"x"`
+		Y    int    `json:"y"`
+		W    int    `json:"w"`
+		H    int    `json:"h"`
+		Bits []byte `json:"bits"`
+	}
+)
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// setup returns the description of the displays and the inputs. It must be
+// called with the lock held.
+func (m *Mirror) setup() setupMessage {
+	msg := setupMessage{Type: "setup"}
+	if m.Text != nil {
+		rows := m.Text.Contents()
+		msg.Rows = len(rows)
+		if len(rows) > 0 {
+			msg.Cols = len(rows[0])
+		}
+	}
+	if m.pixels != nil {
+		msg.Width, msg.Height = m.pixels.Bounds().Dx(), m.pixels.Bounds().Dy()
+	}
+	names := map[string]struct{}{}
+	for name := range m.buttons {
+		names[name] = struct{}{}
+	}
+	msg.Buttons = sortedKeys(names)
+	names = map[string]struct{}{}
+	for name := range m.encoders {
+		names[name] = struct{}{}
+	}
+	msg.Encoders = sortedKeys(names)
+	return msg
+}
+
+// pack packs the pixels of r. It must be called with the lock held.
+func (m *Mirror) pack(r image.Rectangle) pixelsMessage {
+	stride := (r.Dx() + 7) / 8
+	bits := make([]byte, stride*r.Dy())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if m.pixels.BitAt(x, y) {
+				i := (y-r.Min.Y)*stride + (x-r.Min.X)/8
+				bits[i] |= 0x80 >> uint((x-r.Min.X)%8)
+			}
+		}
+	}
+	b := m.pixels.Bounds()
+	return pixelsMessage{
+		Type: "pixels",
+		X:    r.Min.X - b.Min.X,
+		Y:    r.Min.Y - b.Min.Y,
+		W:    r.Dx(),
+		H:    r.Dy(),
+		Bits: bits,
+	}
+}
+
+// ServeHTTP serves the viewer page, and the WebSocket it connects to.
+func (m *Mirror) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isWebSocket(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, viewer)
+		return
+	}
+	if !sameOrigin(r, m.AllowedOrigins) {
+		glog.Warningf("mirror: refusing websocket from origin %q", r.Header.Get("Origin"))
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	conn, rw, err := upgrade(w, r)
+	if err != nil {
+		glog.V(1).Infof("mirror: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	c := &client{
+		conn:   conn,
+		w:      rw.Writer,
+		notify: make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+	}
+	m.mu.Lock()
+	if m.pixels != nil {
+		c.dirty = m.pixels.Bounds()
+	}
+	setup := m.setup()
+	m.clients[c] = struct{}{}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.clients, c)
+		m.mu.Unlock()
+	}()
+
+	glog.V(1).Infof("mirror: client %v connected", r.RemoteAddr)
+	go m.read(c, rw.Reader)
+	if err := c.send(setup); err != nil {
+		return
+	}
+	if err := m.stream(c); err != nil {
+		glog.V(1).Infof("mirror: client %v: %v", r.RemoteAddr, err)
+	}
+}
+
+// stream sends the changes of the displays to a client until it leaves.
+func (m *Mirror) stream(c *client) error {
+	ticker := time.NewTicker(m.Poll)
+	defer ticker.Stop()
+
+	var rows []string
+	for {
+		if m.Text != nil {
+			cur := m.Text.Contents()
+			for row, text := range cur {
+				if row < len(rows) && rows[row] == text {
+					continue
+				}
+				if err := c.send(textMessage{Type: "text", Row: row, Text: text}); err != nil {
+					return err
+				}
+			}
+			rows = cur
+		}
+
+		m.mu.Lock()
+		var msg *pixelsMessage
+		if !c.dirty.Empty() {
+			p := m.pack(c.dirty)
+			msg = &p
+			c.dirty = image.Rectangle{}
+		}
+		m.mu.Unlock()
+		if msg != nil {
+			if err := c.send(msg); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-c.notify:
+		case <-c.quit:
+			return nil
+		}
+	}
+}
+
+// read feeds the input events of a client.
+func (m *Mirror) read(c *client, r *bufio.Reader) {
+	defer c.end()
+
+	for {
+		msg, err := readMessage(r, c.write)
+		if err != nil {
+			return
+		}
+		var in Input
+		if err := json.Unmarshal(msg, &in); err != nil {
+			glog.V(1).Infof("mirror: invalid input %q: %v", msg, err)
+			continue
+		}
+		if err := m.Feed(in); err != nil {
+			glog.V(1).Infof("mirror: %v", err)
+		}
+	}
+}
+
+// Close disconnects the clients.
+func (m *Mirror) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for c := range m.clients {
+		c.end()
+		c.conn.Close()
+	}
+	return nil
+}
+
+var viewer = strings.TrimSpace(`
+<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Display mirror</title>
+<style>
+body { font-family: sans-serif; background: #222; color: #eee; }
+pre { background: #3a6; color: #111; font-size: 24px; padding: 8px; display: inline-block; }
+canvas { background: #000; image-rendering: pixelated; display: block; margin: 8px 0; }
+button { margin: 4px; }
+</style>
+</head>
+<body>
+<pre id="text"></pre>
+<canvas id="pixels"></canvas>
+<div id="inputs"></div>
+<p>Shift-click a button to long press it.</p>
+<script>
+var scale = 4, rows = [], ws = new WebSocket((location.protocol == "https:" ? "wss://" : "ws://") + location.host + location.pathname);
+var canvas = document.getElementById("pixels"), ctx = canvas.getContext("2d");
+function send(input) { ws.send(JSON.stringify(input)); }
+function add(label, fn) {
+	var b = document.createElement("button");
+	b.textContent = label;
+	b.onclick = fn;
+	document.getElementById("inputs").appendChild(b);
+}
+ws.onmessage = function(e) {
+	var m = JSON.parse(e.data);
+	if (m.type == "setup") {
+		document.getElementById("text").style.display = m.rows ? "" : "none";
+		canvas.style.display = m.width ? "" : "none";
+		canvas.width = m.width * scale;
+		canvas.height = m.height * scale;
+		m.buttons.forEach(function(name) {
+			add(name, function(ev) { send({button: name, gesture: ev.shiftKey ? "long-press" : "click"}); });
+		});
+		m.encoders.forEach(function(name) {
+			add(name + " -", function() { send({encoder: name, steps: -1}); });
+			add(name + " +", function() { send({encoder: name, steps: 1}); });
+		});
+	} else if (m.type == "text") {
+		rows[m.row] = m.text;
+		document.getElementById("text").textContent = rows.join("\n");
+	} else if (m.type == "pixels") {
+		var bits = atob(m.bits), stride = Math.ceil(m.w / 8);
+		for (var y = 0; y < m.h; y++) {
+			for (var x = 0; x < m.w; x++) {
+				var on = bits.charCodeAt(y * stride + (x >> 3)) & (0x80 >> (x & 7));
+				ctx.fillStyle = on ? "#fff" : "#000";
+				ctx.fillRect((m.x + x) * scale, (m.y + y) * scale, scale, scale);
+			}
+		}
+	}
+};
+</script>
+</body>
+</html>
+`)
@@ -0,0 +1,199 @@
+package mirror
+
+import (
+	"bufio"
+	"encoding/json"
+	"image"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+	"github.com/kidoman/embd/interface/display/characterdisplay/recording"
+	"github.com/kidoman/embd/interface/display/graphics"
+	"github.com/kidoman/embd/interface/hotkey"
+)
+
+type panel struct {
+	img *graphics.Mono
+}
+
+func (p *panel) Bounds() image.Rectangle { return p.img.Bounds() }
+
+func (p *panel) Draw(img *graphics.Mono, r image.Rectangle) error {
+	p.img.Copy(img, r)
+	return nil
+}
+
+// dial connects a WebSocket client to the server.
+func dial(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: mirror\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake: %v", resp.Status)
+	}
+	if got := resp.Header.Get("Sec-Websocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("wrong accept key %q", got)
+	}
+	return conn, r
+}
+
+// next reads the next message of a type.
+func next(t *testing.T, r *bufio.Reader, typ string, v interface{}) {
+	for {
+		_, _, payload, err := readFrame(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg struct{ Type string }
+		json.Unmarshal(payload, &msg)
+		if msg.Type == typ {
+			if err := json.Unmarshal(payload, v); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+	}
+}
+
+func TestMirror(t *testing.T) {
+	disp := characterdisplay.New(recording.NewScreen(8, 2), 8, 2)
+	disp.Message("Hi")
+	m := New()
+	m.Poll = 5 * time.Millisecond
+	m.Text = disp
+	d := m.Tap(&panel{graphics.NewMono(image.Rect(0, 0, 16, 8))})
+	page := m.AddButton("page")
+	volume := m.AddEncoder("volume")
+
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+	defer m.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "WebSocket") {
+		t.Error("viewer page not served")
+	}
+
+	conn, r := dial(t, srv.URL)
+	defer conn.Close()
+
+	var setup setupMessage
+	next(t, r, "setup", &setup)
+	if setup.Cols != 8 || setup.Rows != 2 || setup.Width != 16 || setup.Height != 8 {
+		t.Errorf("unexpected setup %+v", setup)
+	}
+	var text textMessage
+	next(t, r, "text", &text)
+	if text.Row != 0 || text.Text != "Hi      " {
+		t.Errorf("unexpected text %+v", text)
+	}
+	var pixels pixelsMessage
+	next(t, r, "pixels", &pixels)
+	if pixels.W != 16 || pixels.H != 8 || len(pixels.Bits) != 16 {
+		t.Errorf("unexpected full frame %+v", pixels)
+	}
+
+	img := graphics.NewMono(d.Bounds())
+	img.SetBit(9, 3, true)
+	d.Draw(img, image.Rect(8, 2, 12, 4))
+	next(t, r, "pixels", &pixels)
+	if pixels.X != 8 || pixels.Y != 2 || pixels.W != 4 || pixels.H != 2 || pixels.Bits[1] != 0x40 {
+		t.Errorf("unexpected diff %+v", pixels)
+	}
+
+	disp.SetCursor(0, 1)
+	disp.Message("there")
+	next(t, r, "text", &text)
+	if text.Row != 1 || text.Text != "there   " {
+		t.Errorf("unexpected text %+v", text)
+	}
+
+	w := bufio.NewWriter(conn)
+	for _, in := range []string{`{"button": "page", "gesture": "long-press"}`, `{"encoder": "volume", "steps": -2}`} {
+		frame := []byte{0x81, 0x80 | byte(len(in)), 1, 2, 3, 4}
+		for i := 0; i < len(in); i++ {
+			frame = append(frame, in[i]^byte(i%4+1))
+		}
+		w.Write(frame)
+	}
+	w.Flush()
+	select {
+	case g := <-page:
+		if g != hotkey.LongPress {
+			t.Errorf("expected a long press, got %v", g)
+		}
+	case <-time.After(time.Second):
+		t.Error("button not pressed")
+	}
+	select {
+	case steps := <-volume:
+		if steps != -2 {
+			t.Errorf("expected -2 steps, got %v", steps)
+		}
+	case <-time.After(time.Second):
+		t.Error("encoder not turned")
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	m := New()
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+	defer m.Close()
+
+	handshake := func(origin string) int {
+		req, _ := http.NewRequest("GET", srv.URL, nil)
+		req.Host = "mirror.local"
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for _, test := range []struct {
+		origin string
+		want   int
+	}{
+		{"", http.StatusSwitchingProtocols},
+		{"http://mirror.local", http.StatusSwitchingProtocols},
+		{"http://evil.example", http.StatusForbidden},
+	} {
+		if got := handshake(test.origin); got != test.want {
+			t.Errorf("origin %q: got status %v, want %v", test.origin, got, test.want)
+		}
+	}
+
+	m.AllowedOrigins = []string{"http://dashboard.local"}
+	if got := handshake("http://dashboard.local"); got != http.StatusSwitchingProtocols {
+		t.Errorf("allowed origin: got status %v, want 101", got)
+	}
+}
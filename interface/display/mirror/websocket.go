@@ -0,0 +1,195 @@
+// WebSocket protocol (RFC 6455), as much of it as the mirror needs.
+
+package mirror
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	// maxMessage bounds the messages read from the clients, which are
+	// small input events.
+	maxMessage = 4096
+)
+
+var errMessageTooLarge = errors.New("mirror: websocket message too large")
+
+// isWebSocket returns whether the request asks to upgrade to a WebSocket.
+func isWebSocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	io.WriteString(h, key+wsGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// sameOrigin returns whether a browser sent the request from a page of the
+// server itself, or of one of the allowed origins, like
+// "https://dashboard.local". Clients other than browsers send no origin.
+func sameOrigin(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(a, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// upgrade completes the WebSocket handshake, and returns the hijacked
+// connection.
+func upgrade(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	key := r.Header.Get("Sec-Websocket-Key")
+	if key == "" || r.Header.Get("Sec-Websocket-Version") != "13" {
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return nil, nil, errors.New("mirror: bad websocket handshake")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, nil, errors.New("mirror: connection cannot be hijacked")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw, nil
+}
+
+// writeFrame writes an unfragmented, unmasked frame, as sent by servers.
+func writeFrame(w *bufio.Writer, op byte, payload []byte) error {
+	header := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(n))
+		header = append(header, size[:]...)
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// readFrame reads a frame, unmasking its payload.
+func readFrame(r *bufio.Reader) (fin bool, op byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(r, header[:]); err != nil {
+		return
+	}
+	fin, op = header[0]&0x80 != 0, header[0]&0x0f
+	masked := header[1]&0x80 != 0
+	n := uint64(header[1] & 0x7f)
+	switch n {
+	case 126:
+		var size [2]byte
+		if _, err = io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(size[:]))
+	case 127:
+		var size [8]byte
+		if _, err = io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(size[:])
+	}
+	if n > maxMessage {
+		err = errMessageTooLarge
+		return
+	}
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(r, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// readMessage reads a text or binary message, answering the pings on w.
+// It returns io.EOF once the client closes the connection.
+func readMessage(r *bufio.Reader, w func(op byte, payload []byte) error) ([]byte, error) {
+	var msg []byte
+	for {
+		fin, op, payload, err := readFrame(r)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case opPing:
+			if err := w(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			w(opClose, nil)
+			return nil, io.EOF
+		case opText, opBinary, opContinuation:
+		default:
+			return nil, errors.New("mirror: unknown websocket opcode")
+		}
+		if len(msg)+len(payload) > maxMessage {
+			return nil, errMessageTooLarge
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
@@ -0,0 +1,171 @@
+/*
+Package numfmt formats numbers for displays, always to the same width so
+that values do not jump around on the display as they change.
+
+Values are right aligned, and written with the most decimals which fit:
+
+	region.Write(numfmt.SI(0.0123, "A", 6))             // "12.3mA"
+	region.Write(numfmt.Fixed(3.14159, 2, 6))           // "  3.14"
+	region.Write(numfmt.Temperature(temp, 1, 7))        // " 21.5\xdfC"
+	region.Write(numfmt.Voltage(units.Millivolt*330, 7)) // "330.0mV"
+
+The units are encoded in the character set of the display, Charset, so
+that the width is in bytes: " 21.5°C" is 7 characters on an HD44780 with
+the A00 ROM, where ° is 0xDF.
+
+A value which does not fit is written as #s, rather than a misleading
+truncated number.
+*/
+package numfmt
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kidoman/embd/interface/display/charset"
+	"github.com/kidoman/embd/units"
+)
+
+// Charset is the character set of the display the units are encoded in.
+// Displays without a µ or a ° glyph can use charset.ASCII, writing them as
+// "u" and "o".
+var Charset = charset.A00
+
+// Micro is the prefix of the millionths, before it is encoded.
+var Micro = "µ"
+
+// MaxDecimals is the largest number of decimals written by SI and Eng.
+const MaxDecimals = 3
+
+var prefixes = map[int]string{
+	-12: "p",
+	-9:  "n",
+	-6:  "",
+	-3:  "m",
+	0:   "",
+	3:   "k",
+	6:   "M",
+	9:   "G",
+	12:  "T",
+}
+
+const minExp, maxExp = -12, 12
+
+func prefix(exp int) string {
+	if exp == -6 {
+		return Micro
+	}
+	return prefixes[exp]
+}
+
+// pad right aligns s to width, or returns the overflow marker when it does
+// not fit.
+func pad(s string, width int) string {
+	n := len(s)
+	if n > width {
+		return overflow(width)
+	}
+	return strings.Repeat(" ", width-n) + s
+}
+
+func overflow(width int) string {
+	if width <= 0 {
+		return ""
+	}
+	return strings.Repeat("#", width)
+}
+
+// exponent returns the multiple of 3 of the engineering notation of v.
+func exponent(v float64) int {
+	if v == 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0
+	}
+	return int(math.Floor(math.Log10(math.Abs(v))/3)) * 3
+}
+
+// mantissa formats v/10^exp with the most decimals fitting in avail
+// columns. It returns false when v rounds to the next exponent, or does not
+// fit.
+func mantissa(v float64, exp, avail int) (string, bool) {
+	m := v / math.Pow10(exp)
+	for d := MaxDecimals; d >= 0; d-- {
+		s := strconv.FormatFloat(m, 'f', d, 64)
+		if len(s) > avail {
+			continue
+		}
+		r, _ := strconv.ParseFloat(s, 64)
+		if math.Abs(r) >= 1000 {
+			return "", false
+		}
+		return s, true
+	}
+	return "", false
+}
+
+// SI formats v with an SI prefix and a unit, like "12.3mA".
+func SI(v float64, unit string, width int) string {
+	exp := exponent(v)
+	if exp < minExp {
+		exp = minExp
+	}
+	if exp > maxExp {
+		exp = maxExp
+	}
+	for ; exp <= maxExp; exp += 3 {
+		suffix := Charset.Encode(prefix(exp) + unit)
+		if s, ok := mantissa(v, exp, width-len(suffix)); ok {
+			return pad(s+suffix, width)
+		}
+		if exp == maxExp {
+			break
+		}
+	}
+	return overflow(width)
+}
+
+// Eng formats v in engineering notation, like "12.3e-3".
+func Eng(v float64, width int) string {
+	exp := exponent(v)
+	for i := 0; i < 2; i, exp = i+1, exp+3 {
+		suffix := ""
+		if exp != 0 {
+			suffix = "e" + strconv.Itoa(exp)
+		}
+		if s, ok := mantissa(v, exp, width-len(suffix)); ok {
+			return pad(s+suffix, width)
+		}
+	}
+	return overflow(width)
+}
+
+// Fixed formats v with a fixed number of decimals, so that the decimal
+// points of successive values are aligned.
+func Fixed(v float64, decimals, width int) string {
+	return pad(strconv.FormatFloat(v, 'f', decimals, 64), width)
+}
+
+// FixedUnit formats v with a fixed number of decimals and a unit.
+func FixedUnit(v float64, decimals int, unit string, width int) string {
+	return pad(strconv.FormatFloat(v, 'f', decimals, 64)+Charset.Encode(unit), width)
+}
+
+// Temperature formats a temperature in degrees Celsius.
+func Temperature(t units.Temperature, decimals, width int) string {
+	return FixedUnit(t.Celsius(), decimals, "°C", width)
+}
+
+// Pressure formats a pressure in hectopascals.
+func Pressure(p units.Pressure, decimals, width int) string {
+	return FixedUnit(p.Hectopascals(), decimals, "hPa", width)
+}
+
+// Voltage formats a voltage with an SI prefix.
+func Voltage(v units.Voltage, width int) string {
+	return SI(v.Volts(), "V", width)
+}
+
+// Distance formats a distance with an SI prefix.
+func Distance(d units.Distance, width int) string {
+	return SI(d.Meters(), "m", width)
+}
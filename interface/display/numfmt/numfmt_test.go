@@ -0,0 +1,82 @@
+package numfmt
+
+import (
+	"testing"
+
+	"github.com/kidoman/embd/interface/display/charset"
+	"github.com/kidoman/embd/units"
+)
+
+func TestSI(t *testing.T) {
+	var tests = []struct {
+		v     float64
+		unit  string
+		width int
+		str   string
+	}{
+		{0.0123, "A", 6, "12.3mA"},
+		{0.0123, "A", 8, "12.300mA"},
+		{0, "V", 4, "0.0V"},
+		{1234567, "Hz", 7, "1.23MHz"},
+		{-0.5, "V", 7, " -500mV"},
+		{-0.5, "V", 8, "-500.0mV"},
+		{999.96, "V", 6, "1.00kV"},
+		{2.5e-6, "A", 6, "2.50\xe4A"},
+		{1e15, "W", 6, "######"},
+		{1e18, "W", 4, "####"},
+	}
+	for _, test := range tests {
+		if str := SI(test.v, test.unit, test.width); str != test.str {
+			t.Errorf("SI(%v, %q, %v) = %q; want %q", test.v, test.unit, test.width, str, test.str)
+		}
+	}
+}
+
+func TestEng(t *testing.T) {
+	var tests = []struct {
+		v     float64
+		width int
+		str   string
+	}{
+		{0.0123, 7, "12.3e-3"},
+		{12, 5, "12.00"},
+		{123456, 6, " 123e3"},
+		{999999, 5, "1.0e6"},
+	}
+	for _, test := range tests {
+		if str := Eng(test.v, test.width); str != test.str {
+			t.Errorf("Eng(%v, %v) = %q; want %q", test.v, test.width, str, test.str)
+		}
+	}
+}
+
+func TestFixed(t *testing.T) {
+	var tests = []struct {
+		str, want string
+	}{
+		{Fixed(3.14159, 2, 6), "  3.14"},
+		{Fixed(-3.14159, 2, 6), " -3.14"},
+		{Fixed(12345, 2, 6), "######"},
+		{Temperature(21.46, 1, 7), " 21.5\xdfC"},
+		{Pressure(units.StandardSeaLevel, 0, 7), "1013hPa"},
+		{Voltage(330*units.Millivolt, 7), "330.0mV"},
+		{Distance(1500*units.Millimeter, 6), "1.500m"},
+	}
+	for _, test := range tests {
+		if test.str != test.want {
+			t.Errorf("got %q; want %q", test.str, test.want)
+		}
+	}
+}
+
+func TestCharset(t *testing.T) {
+	defer func(c charset.Charset) { Charset = c }(Charset)
+	Charset = charset.ASCII
+
+	if str := Temperature(21.46, 1, 7); str != " 21.5oC" {
+		t.Errorf("Temperature() = %q; want %q", str, " 21.5oC")
+	}
+	if str := SI(2.5e-6, "A", 6); str != "2.50uA" {
+		t.Errorf("SI() = %q; want %q", str, "2.50uA")
+	}
+}
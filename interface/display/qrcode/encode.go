@@ -0,0 +1,563 @@
+// QR code data encoding, error correction and module placement.
+
+package qrcode
+
+import "strings"
+
+const alphanumericChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+type mode int
+
+const (
+	modeNumeric mode = iota
+	modeAlphanumeric
+	modeByte
+)
+
+var (
+	modeIndicators = [...]uint{modeNumeric: 0x1, modeAlphanumeric: 0x2, modeByte: 0x4}
+
+	// Character count bits for versions 1-9, 10-26 and 27-40.
+	charCountBits = [...][3]int{
+		modeNumeric:      {10, 12, 14},
+		modeAlphanumeric: {9, 11, 13},
+		modeByte:         {8, 16, 16},
+	}
+)
+
+// Error correction codewords per block, indexed by level and version.
+var eccCodewordsPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+// Error correction blocks, indexed by level and version.
+var numErrorCorrectionBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// Format information level bits, indexed by level.
+var formatLevelBits = [4]uint{L: 1, M: 0, Q: 3, H: 2}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(val uint, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (val>>uint(i))&1 != 0)
+	}
+}
+
+type segment struct {
+	mode mode
+	text string
+}
+
+// newSegment picks the most compact mode able to represent the whole text.
+func newSegment(text string) segment {
+	numeric, alphanumeric := true, true
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c < '0' || c > '9' {
+			numeric = false
+		}
+		if strings.IndexByte(alphanumericChars, c) < 0 {
+			alphanumeric = false
+		}
+	}
+	switch {
+	case numeric:
+		return segment{modeNumeric, text}
+	case alphanumeric:
+		return segment{modeAlphanumeric, text}
+	default:
+		return segment{modeByte, text}
+	}
+}
+
+func (s segment) countBits(version int) int {
+	switch {
+	case version <= 9:
+		return charCountBits[s.mode][0]
+	case version <= 26:
+		return charCountBits[s.mode][1]
+	default:
+		return charCountBits[s.mode][2]
+	}
+}
+
+func (s segment) bitLen(version int) int {
+	n := len(s.text)
+	var data int
+	switch s.mode {
+	case modeNumeric:
+		data = n/3*10 + [...]int{0, 4, 7}[n%3]
+	case modeAlphanumeric:
+		data = n/2*11 + n%2*6
+	default:
+		data = n * 8
+	}
+	return 4 + s.countBits(version) + data
+}
+
+func (s segment) encode(b *bitBuffer, version int) {
+	b.append(modeIndicators[s.mode], 4)
+	b.append(uint(len(s.text)), s.countBits(version))
+
+	switch s.mode {
+	case modeNumeric:
+		for i := 0; i < len(s.text); i += 3 {
+			end := i + 3
+			if end > len(s.text) {
+				end = len(s.text)
+			}
+			var val uint
+			for _, c := range s.text[i:end] {
+				val = val*10 + uint(c-'0')
+			}
+			b.append(val, (end-i)*3+1)
+		}
+	case modeAlphanumeric:
+		for i := 0; i < len(s.text); i += 2 {
+			val := uint(strings.IndexByte(alphanumericChars, s.text[i]))
+			if i+1 < len(s.text) {
+				val = val*45 + uint(strings.IndexByte(alphanumericChars, s.text[i+1]))
+				b.append(val, 11)
+			} else {
+				b.append(val, 6)
+			}
+		}
+	default:
+		for i := 0; i < len(s.text); i++ {
+			b.append(uint(s.text[i]), 8)
+		}
+	}
+}
+
+// numRawDataModules returns the number of modules available for data and
+// error correction codewords in the given version.
+func numRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func numDataCodewords(version int, level Level) int {
+	return numRawDataModules(version)/8 - eccCodewordsPerBlock[level][version]*numErrorCorrectionBlocks[level][version]
+}
+
+// dataCodewords encodes the segment and pads it to the data capacity of the
+// given version and level.
+func dataCodewords(seg segment, version int, level Level) []byte {
+	capacity := numDataCodewords(version, level) * 8
+
+	var b bitBuffer
+	seg.encode(&b, version)
+	terminator := capacity - len(b)
+	if terminator > 4 {
+		terminator = 4
+	}
+	b.append(0, terminator)
+	b.append(0, (8-len(b)%8)%8)
+	for pad := uint(0xEC); len(b) < capacity; pad ^= 0xEC ^ 0x11 {
+		b.append(pad, 8)
+	}
+
+	data := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			data[i>>3] |= 1 << uint(7-i&7)
+		}
+	}
+	return data
+}
+
+// interleave splits the data into blocks, appends the error correction
+// codewords of each block and interleaves the result.
+func interleave(data []byte, version int, level Level) []byte {
+	numBlocks := numErrorCorrectionBlocks[level][version]
+	blockEccLen := eccCodewordsPerBlock[level][version]
+	rawCodewords := numRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := rsDivisor(blockEccLen)
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range blocks {
+		datLen := shortBlockLen - blockEccLen
+		if i >= numShortBlocks {
+			datLen++
+		}
+		block := make([]byte, 0, shortBlockLen+1)
+		block = append(block, data[k:k+datLen]...)
+		ecc := rsRemainder(data[k:k+datLen], divisor)
+		k += datLen
+		if i < numShortBlocks {
+			// Placeholder to align the error correction codewords with
+			// those of the long blocks, skipped while interleaving.
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := 0; i < len(blocks[0]); i++ {
+		for j, block := range blocks {
+			if i != shortBlockLen-blockEccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// gfMul multiplies two elements of GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of the given degree,
+// highest coefficient first and the leading 1 omitted.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMul(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+type matrix struct {
+	size       int
+	modules    []bool
+	isFunction []bool
+}
+
+func (m *matrix) get(x, y int) bool {
+	return m.modules[y*m.size+x]
+}
+
+func (m *matrix) setFunction(x, y int, dark bool) {
+	m.modules[y*m.size+x] = dark
+	m.isFunction[y*m.size+x] = true
+}
+
+// encode builds the code for the given segment, version and level. A negative
+// mask selects the mask with the lowest penalty score.
+func encode(seg segment, version int, level Level, mask int) *Code {
+	size := version*4 + 17
+	m := &matrix{
+		size:       size,
+		modules:    make([]bool, size*size),
+		isFunction: make([]bool, size*size),
+	}
+	m.drawFunctionPatterns(version, level)
+	m.drawCodewords(interleave(dataCodewords(seg, version, level), version, level))
+
+	if mask < 0 {
+		minPenalty := -1
+		for i := 0; i < 8; i++ {
+			m.applyMask(i)
+			m.drawFormatBits(level, i)
+			penalty := m.penalty()
+			if minPenalty < 0 || penalty < minPenalty {
+				mask, minPenalty = i, penalty
+			}
+			// Masks are XORs, applying one again undoes it.
+			m.applyMask(i)
+		}
+	}
+	m.applyMask(mask)
+	m.drawFormatBits(level, mask)
+
+	return &Code{
+		Size:    size,
+		Version: version,
+		Level:   level,
+		Mask:    mask,
+		modules: m.modules,
+	}
+}
+
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	numAlign := version/7 + 2
+	step := (version*4 + numAlign*2 + 1) / (numAlign*2 - 2) * 2
+	if version == 32 {
+		step = 26
+	}
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+func (m *matrix) drawFunctionPatterns(version int, level Level) {
+	for i := 0; i < m.size; i++ {
+		m.setFunction(6, i, i%2 == 0)
+		m.setFunction(i, 6, i%2 == 0)
+	}
+
+	m.drawFinderPattern(3, 3)
+	m.drawFinderPattern(m.size-4, 3)
+	m.drawFinderPattern(3, m.size-4)
+
+	positions := alignmentPositions(version)
+	n := len(positions)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i == 0 && j == 0 || i == 0 && j == n-1 || i == n-1 && j == 0 {
+				continue
+			}
+			m.drawAlignmentPattern(positions[i], positions[j])
+		}
+	}
+
+	// Reserve the format areas, they are drawn once the mask is known.
+	m.drawFormatBits(level, 0)
+	m.drawVersion(version)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (m *matrix) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if 0 <= xx && xx < m.size && 0 <= yy && yy < m.size {
+				dist := maxInt(abs(dx), abs(dy))
+				m.setFunction(xx, yy, dist != 2 && dist != 4)
+			}
+		}
+	}
+}
+
+func (m *matrix) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			m.setFunction(x+dx, y+dy, maxInt(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+func (m *matrix) drawFormatBits(level Level, mask int) {
+	data := formatLevelBits[level]<<3 | uint(mask)
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>uint(i))&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		m.setFunction(8, i, bit(i))
+	}
+	m.setFunction(8, 7, bit(6))
+	m.setFunction(8, 8, bit(7))
+	m.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		m.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		m.setFunction(m.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		m.setFunction(8, m.size-15+i, bit(i))
+	}
+	m.setFunction(8, m.size-8, true)
+}
+
+func (m *matrix) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+	rem := uint(version)
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := uint(version)<<12 | rem
+	for i := 0; i < 18; i++ {
+		dark := (bits>>uint(i))&1 != 0
+		a, b := m.size-11+i%3, i/3
+		m.setFunction(a, b, dark)
+		m.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords places the codewords in the zig-zag pattern, two columns at a
+// time from the bottom right corner, skipping the function patterns.
+func (m *matrix) drawCodewords(data []byte) {
+	i := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < m.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = m.size - 1 - vert
+				}
+				if !m.isFunction[y*m.size+x] && i < len(data)*8 {
+					m.modules[y*m.size+x] = (data[i>>3]>>uint(7-i&7))&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+var masks = [8]func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+func (m *matrix) applyMask(mask int) {
+	f := masks[mask]
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if !m.isFunction[y*m.size+x] && f(x, y) {
+				m.modules[y*m.size+x] = !m.modules[y*m.size+x]
+			}
+		}
+	}
+}
+
+var (
+	finderLike1 = []bool{true, false, true, true, true, false, true, false, false, false, false}
+	finderLike2 = []bool{false, false, false, false, true, false, true, true, true, false, true}
+)
+
+// penalty computes the mask penalty score of the matrix.
+func (m *matrix) penalty() int {
+	result := 0
+	line := make([]bool, m.size)
+	for pass := 0; pass < 2; pass++ {
+		for i := 0; i < m.size; i++ {
+			for j := 0; j < m.size; j++ {
+				if pass == 0 {
+					line[j] = m.get(j, i)
+				} else {
+					line[j] = m.get(i, j)
+				}
+			}
+			result += linePenalty(line)
+		}
+	}
+
+	// 2x2 blocks of the same color.
+	for y := 0; y < m.size-1; y++ {
+		for x := 0; x < m.size-1; x++ {
+			c := m.get(x, y)
+			if c == m.get(x+1, y) && c == m.get(x, y+1) && c == m.get(x+1, y+1) {
+				result += 3
+			}
+		}
+	}
+
+	// Balance of dark and light modules.
+	dark := 0
+	for _, d := range m.modules {
+		if d {
+			dark++
+		}
+	}
+	total := m.size * m.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	result += k * 10
+
+	return result
+}
+
+// linePenalty computes the penalties for runs of the same color and finder
+// like patterns in a row or column.
+func linePenalty(line []bool) int {
+	result := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			result += 3 + run - 5
+		}
+		run = 1
+	}
+
+	for i := 0; i+len(finderLike1) <= len(line); i++ {
+		if matches(line[i:], finderLike1) || matches(line[i:], finderLike2) {
+			result += 40
+		}
+	}
+	return result
+}
+
+func matches(line, pattern []bool) bool {
+	for i, p := range pattern {
+		if line[i] != p {
+			return false
+		}
+	}
+	return true
+}
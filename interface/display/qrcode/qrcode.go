@@ -0,0 +1,148 @@
+/*
+Package qrcode allows rendering QR codes on graphical displays. It can be used
+to show pairing URLs, Wi-Fi credentials or device IDs on monochrome panels like
+OLEDs, e-paper and TFT displays.
+
+Codes are drawn onto any draw.Image, automatically sizing the modules to fit
+the given bounds:
+
+	code, err := qrcode.Encode("WIFI:S:embd;T:WPA;P:secret;;", qrcode.M)
+	...
+	err = code.Draw(screen, screen.Bounds())
+
+The encoder picks the most compact of the numeric, alphanumeric and byte modes
+for the whole text, the smallest version that fits and the mask with the lowest
+penalty score, as described in ISO/IEC 18004.
+*/
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Level represents the error correction level of a QR code.
+type Level int
+
+const (
+	// L recovers from 7% of damaged data.
+	L Level = iota
+
+	// M recovers from 15% of damaged data.
+	M
+
+	// Q recovers from 25% of damaged data.
+	Q
+
+	// H recovers from 30% of damaged data.
+	H
+)
+
+func (l Level) String() string {
+	if L <= l && l <= H {
+		return "LMQH"[l : l+1]
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+const (
+	minVersion = 1
+	maxVersion = 40
+
+	// QuietZone is the number of light modules recommended around a code.
+	QuietZone = 4
+)
+
+// ErrTooLong is returned when the text does not fit in the largest QR code.
+var ErrTooLong = errors.New("qrcode: text too long to encode")
+
+// ErrTooSmall is returned when a code does not fit in the requested bounds.
+var ErrTooSmall = errors.New("qrcode: bounds too small to fit code")
+
+// Code represents an encoded QR code.
+type Code struct {
+	// Size is the number of modules on a side.
+	Size int
+
+	Version int
+	Level   Level
+	Mask    int
+
+	modules []bool
+}
+
+// Encode encodes the given text as a QR code with the given error correction
+// level.
+func Encode(text string, level Level) (*Code, error) {
+	if level < L || level > H {
+		return nil, fmt.Errorf("qrcode: invalid level %v", level)
+	}
+
+	seg := newSegment(text)
+	version := minVersion
+	for ; ; version++ {
+		if version > maxVersion {
+			return nil, ErrTooLong
+		}
+		if seg.bitLen(version) <= numDataCodewords(version, level)*8 {
+			break
+		}
+	}
+
+	return encode(seg, version, level, -1), nil
+}
+
+// Black returns true if the module at the given position is dark. Positions
+// outside the code are light.
+func (c *Code) Black(x, y int) bool {
+	return 0 <= x && x < c.Size && 0 <= y && y < c.Size && c.modules[y*c.Size+x]
+}
+
+// Scale returns the largest module size (in pixels) at which the code fits in
+// the given bounds with the given quiet zone (in modules) around it.
+func (c *Code) Scale(r image.Rectangle, quiet int) int {
+	side := r.Dx()
+	if r.Dy() < side {
+		side = r.Dy()
+	}
+	return side / (c.Size + 2*quiet)
+}
+
+// Draw draws the code centered in r on dst, dark modules black and light
+// modules white. The modules are sized as large as possible; the quiet zone is
+// shrunk down to a single module if the code would otherwise not fit.
+func (c *Code) Draw(dst draw.Image, r image.Rectangle) error {
+	quiet := QuietZone
+	scale := c.Scale(r, quiet)
+	for scale < 1 && quiet > 1 {
+		quiet--
+		scale = c.Scale(r, quiet)
+	}
+	if scale < 1 {
+		return ErrTooSmall
+	}
+
+	side := (c.Size + 2*quiet) * scale
+	origin := image.Pt(r.Min.X+(r.Dx()-side)/2, r.Min.Y+(r.Dy()-side)/2)
+	draw.Draw(dst, image.Rect(origin.X, origin.Y, origin.X+side, origin.Y+side), image.White, image.Point{}, draw.Src)
+
+	origin = origin.Add(image.Pt(quiet*scale, quiet*scale))
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.Black(x, y) {
+				continue
+			}
+			px, py := origin.X+x*scale, origin.Y+y*scale
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					dst.Set(px+dx, py+dy, color.Black)
+				}
+			}
+		}
+	}
+
+	return nil
+}
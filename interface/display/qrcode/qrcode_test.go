@@ -0,0 +1,115 @@
+package qrcode
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDataCodewords(t *testing.T) {
+	// Worked example from ISO/IEC 18004: HELLO WORLD as 1-M.
+	data := dataCodewords(newSegment("HELLO WORLD"), 1, M)
+	expected := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("Data codewords: got %v, want %v", data, expected)
+	}
+	ecc := rsRemainder(data, rsDivisor(eccCodewordsPerBlock[M][1]))
+	expected = []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if !reflect.DeepEqual(ecc, expected) {
+		t.Errorf("Error correction codewords: got %v, want %v", ecc, expected)
+	}
+}
+
+func TestNewSegment(t *testing.T) {
+	var tests = []struct {
+		text string
+		mode mode
+	}{
+		{"0123456789", modeNumeric},
+		{"HTTP://EMBD.IO", modeAlphanumeric},
+		{"http://embd.io", modeByte},
+	}
+	for _, test := range tests {
+		if seg := newSegment(test.text); seg.mode != test.mode {
+			t.Errorf("Mode of %q: got %v, want %v", test.text, seg.mode, test.mode)
+		}
+	}
+}
+
+func TestEncodeVersion(t *testing.T) {
+	var tests = []struct {
+		text    string
+		level   Level
+		version int
+	}{
+		{"HELLO WORLD", M, 1},
+		{strings.Repeat("a", 17), L, 1},
+		{strings.Repeat("a", 18), L, 2},
+		{strings.Repeat("a", 2953), L, 40},
+	}
+	for _, test := range tests {
+		code, err := Encode(test.text, test.level)
+		if err != nil {
+			t.Errorf("Encoding %d bytes at level %v: got %v", len(test.text), test.level, err)
+			continue
+		}
+		if code.Version != test.version {
+			t.Errorf("Encoding %d bytes at level %v: got version %v, want %v", len(test.text), test.level, code.Version, test.version)
+		}
+		if code.Size != test.version*4+17 {
+			t.Errorf("Encoding %d bytes at level %v: got size %v", len(test.text), test.level, code.Size)
+		}
+	}
+	if _, err := Encode(strings.Repeat("a", 2954), L); err != ErrTooLong {
+		t.Errorf("Encoding 2954 bytes: got %v, want %v", err, ErrTooLong)
+	}
+}
+
+func TestEncodeFinderPatterns(t *testing.T) {
+	code, err := Encode("embd", H)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corners := []image.Point{{0, 0}, {code.Size - 7, 0}, {0, code.Size - 7}}
+	for _, c := range corners {
+		for i := 0; i < 7; i++ {
+			if !code.Black(c.X+i, c.Y) || !code.Black(c.X, c.Y+i) {
+				t.Fatalf("Finder pattern at %v is not dark along its edges", c)
+			}
+		}
+		if code.Black(c.X+1, c.Y+1) || !code.Black(c.X+3, c.Y+3) {
+			t.Errorf("Finder pattern at %v has a wrong center", c)
+		}
+	}
+}
+
+func TestDraw(t *testing.T) {
+	code, err := Encode("embd", M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 21 modules with a quiet zone of 4 modules need 29 pixels at scale 1.
+	img := image.NewGray(image.Rect(0, 0, 128, 64))
+	if err := code.Draw(img, img.Bounds()); err != nil {
+		t.Fatalf("Drawing on 128x64: got %v", err)
+	}
+	if scale := code.Scale(img.Bounds(), QuietZone); scale != 2 {
+		t.Errorf("Scale on 128x64: got %v, want 2", scale)
+	}
+	// Top left finder pattern, centered horizontally.
+	ox, oy := (128-58)/2+8, (64-58)/2+8
+	if img.GrayAt(ox, oy) != (color.Gray{0}) || img.GrayAt(ox+2, oy+2) != (color.Gray{0xff}) {
+		t.Error("Finder pattern not drawn at the expected position")
+	}
+
+	small := image.NewGray(image.Rect(0, 0, 23, 23))
+	if err := code.Draw(small, small.Bounds()); err != nil {
+		t.Errorf("Drawing on 23x23 with a reduced quiet zone: got %v", err)
+	}
+	tiny := image.NewGray(image.Rect(0, 0, 22, 22))
+	if err := code.Draw(tiny, tiny.Bounds()); err != ErrTooSmall {
+		t.Errorf("Drawing on 22x22: got %v, want %v", err, ErrTooSmall)
+	}
+}
@@ -0,0 +1,269 @@
+/*
+Package screensaver dims and blanks idle displays, and shifts the contents of
+OLED panels around to spread the wear of their pixels.
+
+The saver is woken up by the input handlers of the program, like the ones of
+a keypad or of the buttons, and restores the display at once:
+
+	shifter := screensaver.NewShifter(oled)
+	buf := graphics.NewBuffer(shifter)
+	s := screensaver.New(screensaver.Actions{
+		Dim:   func(dim bool) error { return oled.SetContrast(...) },
+		Blank: oled.Blank,
+		Shift: screensaver.ShiftBuffer(shifter, buf),
+	})
+	s.Run()
+	defer s.Close()
+
+	for g := range button.Gestures() {
+		s.Wake()
+		...
+	}
+*/
+package screensaver
+
+import (
+	"image"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+	"github.com/kidoman/embd/interface/display/graphics"
+)
+
+const (
+	// DefaultDimAfter is the default idle time after which the display is
+	// dimmed.
+	DefaultDimAfter = time.Minute
+	// DefaultBlankAfter is the default idle time after which the display is
+	// blanked.
+	DefaultBlankAfter = 5 * time.Minute
+	// DefaultShiftEvery is the default interval between shifts of the
+	// contents.
+	DefaultShiftEvery = 2 * time.Minute
+
+	defaultPoll = time.Second
+)
+
+// Offsets are the offsets the contents are shifted through, in turn. They
+// stay within one pixel of the origin, so that the layout is not visibly
+// moved.
+var Offsets = []image.Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+
+// Actions are how the saver acts on the display. A nil action is skipped.
+type Actions struct {
+	Dim   func(dim bool) error
+	Blank func(blank bool) error
+	Shift func(offset image.Point) error
+}
+
+// CharacterDisplay returns the actions of a character display: it is dimmed
+// by turning its backlight off, and blanked by turning it off. LCDs do not
+// suffer from burn-in, so it is not shifted.
+func CharacterDisplay(disp *characterdisplay.Display) Actions {
+	return Actions{
+		Dim: func(dim bool) error {
+			if dim {
+				return disp.BacklightOff()
+			}
+			return disp.BacklightOn()
+		},
+		Blank: func(blank bool) error {
+			if blank {
+				return disp.DisplayOff()
+			}
+			return disp.DisplayOn()
+		},
+	}
+}
+
+// Saver is a screen saver.
+type Saver struct {
+	Actions Actions
+
+	// DimAfter and BlankAfter are the idle times after which the display
+	// is dimmed and blanked, zero to never do so.
+	DimAfter, BlankAfter time.Duration
+	// ShiftEvery is the interval between shifts of the contents, zero to
+	// never shift them.
+	ShiftEvery time.Duration
+	// Poll is how often the idle time is checked.
+	Poll time.Duration
+
+	mu      sync.Mutex
+	active  time.Time
+	shifted time.Time
+	dimmed  bool
+	blanked bool
+	offset  int
+
+	quit, done chan struct{}
+}
+
+// New creates a new screen saver acting on a display.
+func New(actions Actions) *Saver {
+	now := time.Now()
+	return &Saver{
+		Actions:    actions,
+		DimAfter:   DefaultDimAfter,
+		BlankAfter: DefaultBlankAfter,
+		ShiftEvery: DefaultShiftEvery,
+		Poll:       defaultPoll,
+		active:     now,
+		shifted:    now,
+	}
+}
+
+// Wake records activity, restoring the display at once if it was dimmed or
+// blanked. It returns whether the display was idle, so that an input waking
+// the display can be ignored otherwise.
+func (s *Saver) Wake() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.active = time.Now()
+	idle := s.dimmed || s.blanked
+	if s.blanked {
+		s.act("unblanking", s.Actions.Blank, false)
+		s.blanked = false
+	}
+	if s.dimmed {
+		s.act("undimming", s.Actions.Dim, false)
+		s.dimmed = false
+	}
+	return idle
+}
+
+// Idle returns whether the display is dimmed or blanked.
+func (s *Saver) Idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.dimmed || s.blanked
+}
+
+func (s *Saver) act(what string, action func(bool) error, on bool) {
+	if action == nil {
+		return
+	}
+	glog.V(2).Infof("screensaver: %v", what)
+	if err := action(on); err != nil {
+		glog.Errorf("screensaver: %v: %v", what, err)
+	}
+}
+
+// update dims, blanks or shifts the display as needed at now.
+func (s *Saver) update(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idle := now.Sub(s.active)
+	if s.DimAfter > 0 && idle >= s.DimAfter && !s.dimmed {
+		s.act("dimming", s.Actions.Dim, true)
+		s.dimmed = true
+	}
+	if s.BlankAfter > 0 && idle >= s.BlankAfter && !s.blanked {
+		s.act("blanking", s.Actions.Blank, true)
+		s.blanked = true
+	}
+	if s.ShiftEvery > 0 && s.Actions.Shift != nil && !s.blanked && now.Sub(s.shifted) >= s.ShiftEvery {
+		s.offset = (s.offset + 1) % len(Offsets)
+		s.shifted = now
+		if err := s.Actions.Shift(Offsets[s.offset]); err != nil {
+			glog.Errorf("screensaver: shifting: %v", err)
+		}
+	}
+}
+
+// Run starts watching the idle time.
+func (s *Saver) Run() {
+	s.quit = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.Poll)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				s.update(now)
+			case <-s.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops watching the idle time, and restores the display.
+func (s *Saver) Close() error {
+	if s.quit != nil {
+		close(s.quit)
+		<-s.done
+		s.quit = nil
+	}
+	s.Wake()
+	return nil
+}
+
+// Shifter is a graphical display drawing its contents at an offset.
+type Shifter struct {
+	graphics.Display
+
+	mu     sync.Mutex
+	offset image.Point
+}
+
+// NewShifter returns a display drawing on d at an offset.
+func NewShifter(d graphics.Display) *Shifter {
+	return &Shifter{Display: d}
+}
+
+// SetOffset sets the offset the contents are drawn at. The whole display
+// has to be redrawn for it to take effect.
+func (sh *Shifter) SetOffset(offset image.Point) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	sh.offset = offset
+}
+
+// Draw draws the pixels of img within r at the offset. The pixels shifted
+// out of the panel are dropped, and the ones shifted in are black.
+func (sh *Shifter) Draw(img *graphics.Mono, r image.Rectangle) error {
+	sh.mu.Lock()
+	offset := sh.offset
+	sh.mu.Unlock()
+
+	if offset == (image.Point{}) {
+		return sh.Display.Draw(img, r)
+	}
+	// The area of r and of its shifted copy are redrawn, from the whole
+	// image.
+	bounds := sh.Display.Bounds()
+	shifted := graphics.NewMono(bounds)
+	src := img.Bounds()
+	for y := src.Min.Y; y < src.Max.Y; y++ {
+		for x := src.Min.X; x < src.Max.X; x++ {
+			p := image.Pt(x, y).Add(offset)
+			if p.In(bounds) {
+				shifted.SetBit(p.X, p.Y, img.BitAt(x, y))
+			}
+		}
+	}
+	return sh.Display.Draw(shifted, r.Add(offset).Union(r).Intersect(bounds))
+}
+
+// ShiftBuffer returns the shift action of a shifter, redrawing the buffer
+// drawing on it.
+func ShiftBuffer(sh *Shifter, buf *graphics.Buffer) func(offset image.Point) error {
+	return func(offset image.Point) error {
+		sh.SetOffset(offset)
+		buf.Invalidate()
+		_, err := buf.Flush()
+		return err
+	}
+}
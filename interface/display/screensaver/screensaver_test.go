@@ -0,0 +1,106 @@
+package screensaver
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/interface/display/graphics"
+)
+
+type panel struct {
+	dimmed, blanked bool
+	offsets         []image.Point
+}
+
+func (p *panel) actions() Actions {
+	return Actions{
+		Dim:   func(dim bool) error { p.dimmed = dim; return nil },
+		Blank: func(blank bool) error { p.blanked = blank; return nil },
+		Shift: func(offset image.Point) error {
+			p.offsets = append(p.offsets, offset)
+			return nil
+		},
+	}
+}
+
+func TestIdle(t *testing.T) {
+	p := &panel{}
+	s := New(p.actions())
+	s.DimAfter, s.BlankAfter, s.ShiftEvery = time.Minute, 5*time.Minute, 2*time.Minute
+	start := s.active
+
+	s.update(start.Add(30 * time.Second))
+	if p.dimmed || p.blanked || s.Idle() {
+		t.Error("display idle after 30s")
+	}
+	s.update(start.Add(time.Minute))
+	if !p.dimmed || p.blanked {
+		t.Error("display not dimmed after 1 minute")
+	}
+	s.update(start.Add(2 * time.Minute))
+	if len(p.offsets) != 1 || p.offsets[0] != Offsets[1] {
+		t.Errorf("offsets after 2 minutes: got %v", p.offsets)
+	}
+	s.update(start.Add(5 * time.Minute))
+	if !p.blanked {
+		t.Error("display not blanked after 5 minutes")
+	}
+	s.update(start.Add(6 * time.Minute))
+	if len(p.offsets) != 1 {
+		t.Error("blanked display shifted")
+	}
+
+	if !s.Wake() {
+		t.Error("Wake of an idle display returned false")
+	}
+	if p.dimmed || p.blanked {
+		t.Error("display not restored by Wake")
+	}
+	if s.Wake() {
+		t.Error("Wake of an active display returned true")
+	}
+}
+
+type mono struct {
+	img *graphics.Mono
+}
+
+func (m *mono) Bounds() image.Rectangle { return m.img.Bounds() }
+
+func (m *mono) Draw(img *graphics.Mono, r image.Rectangle) error {
+	m.img.Copy(img, r)
+	return nil
+}
+
+func TestShifter(t *testing.T) {
+	m := &mono{graphics.NewMono(image.Rect(0, 0, 16, 8))}
+	sh := NewShifter(m)
+	buf := graphics.NewBuffer(sh)
+	buf.Update(func(img *graphics.Mono) {
+		img.SetBit(3, 3, true)
+		img.SetBit(15, 7, true)
+	})
+	if _, err := buf.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ShiftBuffer(sh, buf)(image.Pt(1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if m.img.BitAt(3, 3) || !m.img.BitAt(4, 4) {
+		t.Error("pixel not shifted")
+	}
+	if m.img.BitAt(15, 7) {
+		t.Error("pixel shifted out of the panel still drawn")
+	}
+
+	// Partial updates are drawn at the offset.
+	buf.Update(func(img *graphics.Mono) { img.SetBit(8, 2, true) })
+	if _, err := buf.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !m.img.BitAt(9, 3) || !m.img.BitAt(4, 4) {
+		t.Error("partial update not drawn at the offset")
+	}
+}
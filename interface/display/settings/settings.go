@@ -0,0 +1,383 @@
+/*
+Package settings keeps the settings of a display, like its backlight level
+and contrast, across restarts of the program.
+
+The settings are grouped in named profiles, saved to a file or to a key-value
+store in flash, and applied through the controls of the display when they
+are loaded or changed:
+
+	controls := settings.DisplayControls(hd)
+	controls.Contrast = settings.PWMLevel(contrastPin)
+	m, err := settings.Open(settings.File("/var/lib/panel/display.json"), controls)
+	if err != nil {
+		panic(err)
+	}
+	m.SetBacklight(0.8)
+	m.SetProfile("night")
+
+After the display is reinitialized, for example once it is plugged back in,
+Apply applies the settings again.
+*/
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+)
+
+// DefaultProfile is the profile used when none was chosen.
+const DefaultProfile = "default"
+
+// Glyph is a custom character of a character display.
+type Glyph struct {
+	// Slot is the character code of the glyph, 0 to 7.
+	Slot byte `json:"slot"`
+	// Rows are the 5x8 pixels of the glyph, the top row first and the
+	// leftmost pixel in bit 4.
+	Rows [8]byte `json:"rows"`
+}
+
+// Settings are the settings of a display.
+type Settings struct {
+	// Backlight is the backlight level, from 0 (off) to 1.
+	Backlight float64 `json:"backlight"`
+	// Contrast is the contrast, from 0 to 1.
+	Contrast float64 `json:"contrast"`
+	// RightToLeft writes the text from right to left.
+	RightToLeft bool `json:"rightToLeft,omitempty"`
+	// Glyphs are the custom characters.
+	Glyphs []Glyph `json:"glyphs,omitempty"`
+}
+
+// Defaults are the settings of a new profile.
+var Defaults = Settings{Backlight: 1, Contrast: 0.5}
+
+// Controls apply the settings to a display. A nil control leaves the
+// matching setting unapplied.
+type Controls struct {
+	Backlight func(level float64) error
+	Contrast  func(level float64) error
+	Direction func(rightToLeft bool) error
+	Glyph     func(slot byte, rows [8]byte) error
+}
+
+// DisplayControls returns the controls of a character display controller:
+// the backlight is dimmed where the controller supports it, and otherwise
+// switched on for any level above zero. The contrast, the direction and the
+// glyphs are set where the controller supports it, like the HD44780.
+func DisplayControls(c characterdisplay.Controller) Controls {
+	if d, ok := c.(*characterdisplay.Display); ok {
+		c = d.Controller
+	}
+	controls := Controls{
+		Backlight: func(level float64) error {
+			if level > 0 {
+				return c.BacklightOn()
+			}
+			return c.BacklightOff()
+		},
+	}
+	if d, ok := c.(characterdisplay.BacklightDimmer); ok {
+		controls.Backlight = d.SetBacklight
+	}
+	if s, ok := c.(characterdisplay.ContrastSetter); ok {
+		controls.Contrast = s.SetContrast
+	}
+	if d, ok := c.(interface {
+		SetRightToLeft(rtl bool) error
+	}); ok {
+		controls.Direction = d.SetRightToLeft
+	}
+	if g, ok := c.(characterdisplay.CustomCharWriter); ok {
+		controls.Glyph = g.CreateChar
+	}
+	return controls
+}
+
+// PWMLevel returns a control setting a level through the duty cycle of a
+// PWM pin, like a dimmed backlight or the contrast voltage of a character
+// display through a RC filter.
+func PWMLevel(pin embd.PWMPin) func(level float64) error {
+	return func(level float64) error {
+		return pin.SetAnalog(byte(clamp(level)*255 + 0.5))
+	}
+}
+
+func clamp(level float64) float64 {
+	switch {
+	case level < 0:
+		return 0
+	case level > 1:
+		return 1
+	}
+	return level
+}
+
+// Storage is where the settings are saved.
+type Storage interface {
+	// Load returns the saved data, or nil when nothing was saved.
+	Load() ([]byte, error)
+	Save(data []byte) error
+}
+
+type file string
+
+// File returns the storage saving the settings to a file.
+func File(path string) Storage {
+	return file(path)
+}
+
+func (f file) Load() ([]byte, error) {
+	data, err := ioutil.ReadFile(string(f))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Save replaces the file atomically, so that a power loss does not leave
+// it truncated.
+func (f file) Save(data []byte) error {
+	tmp := string(f) + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, string(f))
+}
+
+// A KeyValue store, like the w25q flash store.
+type KeyValue interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte) error
+}
+
+type kvStorage struct {
+	kv  KeyValue
+	key string
+}
+
+// KV returns the storage saving the settings to a key of a key-value store.
+func KV(kv KeyValue, key string) Storage {
+	return kvStorage{kv, key}
+}
+
+func (s kvStorage) Load() ([]byte, error) {
+	data, _ := s.kv.Get(s.key)
+	return data, nil
+}
+
+func (s kvStorage) Save(data []byte) error {
+	return s.kv.Set(s.key, data)
+}
+
+// saved is the saved form of the settings.
+type saved struct {
+	Profile  string              `json:"profile"`
+	Profiles map[string]Settings `json:"profiles"`
+}
+
+// Manager applies and saves the settings of a display.
+type Manager struct {
+	Storage  Storage
+	Controls Controls
+
+	mu    sync.Mutex
+	state saved
+}
+
+// Open loads the settings saved in the storage, and applies the settings of
+// the current profile.
+func Open(storage Storage, controls Controls) (*Manager, error) {
+	m := &Manager{
+		Storage:  storage,
+		Controls: controls,
+		state: saved{
+			Profile:  DefaultProfile,
+			Profiles: map[string]Settings{},
+		},
+	}
+	data, err := storage.Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &m.state); err != nil {
+			return nil, fmt.Errorf("settings: loading: %v", err)
+		}
+	}
+	return m, m.Apply()
+}
+
+// current returns the settings of the current profile. It must be called
+// with the lock held.
+func (m *Manager) current() Settings {
+	if s, ok := m.state.Profiles[m.state.Profile]; ok {
+		return s
+	}
+	return Defaults
+}
+
+// Settings returns the settings of the current profile.
+func (m *Manager) Settings() Settings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.current()
+}
+
+// Profile returns the name of the current profile.
+func (m *Manager) Profile() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.state.Profile
+}
+
+// Profiles returns the sorted names of the saved profiles.
+func (m *Manager) Profiles() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.state.Profiles))
+	for name := range m.state.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply applies the settings of the current profile to the display.
+func (m *Manager) Apply() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.apply(m.current())
+}
+
+func (m *Manager) apply(s Settings) error {
+	glog.V(2).Infof("settings: applying %+v", s)
+
+	c := m.Controls
+	if c.Backlight != nil {
+		if err := c.Backlight(clamp(s.Backlight)); err != nil {
+			return err
+		}
+	}
+	if c.Contrast != nil {
+		if err := c.Contrast(clamp(s.Contrast)); err != nil {
+			return err
+		}
+	}
+	if c.Direction != nil {
+		if err := c.Direction(s.RightToLeft); err != nil {
+			return err
+		}
+	}
+	if c.Glyph != nil {
+		for _, g := range s.Glyphs {
+			if err := c.Glyph(g.Slot, g.Rows); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Manager) save() error {
+	data, err := json.MarshalIndent(m.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return m.Storage.Save(data)
+}
+
+// update changes, applies and saves the settings of the current profile.
+func (m *Manager) update(f func(s *Settings)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.current()
+	f(&s)
+	if err := m.apply(s); err != nil {
+		return err
+	}
+	m.state.Profiles[m.state.Profile] = s
+	return m.save()
+}
+
+// Set applies and saves the settings of the current profile.
+func (m *Manager) Set(s Settings) error {
+	return m.update(func(cur *Settings) { *cur = s })
+}
+
+// SetBacklight sets the backlight level of the current profile.
+func (m *Manager) SetBacklight(level float64) error {
+	return m.update(func(s *Settings) { s.Backlight = clamp(level) })
+}
+
+// SetContrast sets the contrast of the current profile.
+func (m *Manager) SetContrast(level float64) error {
+	return m.update(func(s *Settings) { s.Contrast = clamp(level) })
+}
+
+// SetRightToLeft sets the text direction of the current profile.
+func (m *Manager) SetRightToLeft(rtl bool) error {
+	return m.update(func(s *Settings) { s.RightToLeft = rtl })
+}
+
+// SetGlyph sets a custom character of the current profile.
+func (m *Manager) SetGlyph(slot byte, rows [8]byte) error {
+	if slot > 7 {
+		return fmt.Errorf("settings: glyph slot %v out of range [0, 7]", slot)
+	}
+	return m.update(func(s *Settings) {
+		glyphs := make([]Glyph, 0, len(s.Glyphs)+1)
+		for _, g := range s.Glyphs {
+			if g.Slot != slot {
+				glyphs = append(glyphs, g)
+			}
+		}
+		s.Glyphs = append(glyphs, Glyph{slot, rows})
+	})
+}
+
+// SetProfile switches to a profile, applying and saving it. A new profile
+// starts from the settings of the current one.
+func (m *Manager) SetProfile(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.state.Profiles[name]
+	if !ok {
+		s = m.current()
+	}
+	if err := m.apply(s); err != nil {
+		return err
+	}
+	m.state.Profile = name
+	m.state.Profiles[name] = s
+	return m.save()
+}
+
+// DeleteProfile deletes a profile other than the current one.
+func (m *Manager) DeleteProfile(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if name == m.state.Profile {
+		return fmt.Errorf("settings: cannot delete the current profile %v", name)
+	}
+	if _, ok := m.state.Profiles[name]; !ok {
+		return nil
+	}
+	delete(m.state.Profiles, name)
+	return m.save()
+}
@@ -0,0 +1,126 @@
+package settings
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type display struct {
+	backlight, contrast float64
+	rtl                 bool
+	glyphs              map[byte][8]byte
+}
+
+func (d *display) controls() Controls {
+	return Controls{
+		Backlight: func(level float64) error { d.backlight = level; return nil },
+		Contrast:  func(level float64) error { d.contrast = level; return nil },
+		Direction: func(rtl bool) error { d.rtl = rtl; return nil },
+		Glyph: func(slot byte, rows [8]byte) error {
+			d.glyphs[slot] = rows
+			return nil
+		},
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "display.json")
+
+	d := &display{glyphs: map[byte][8]byte{}}
+	m, err := Open(File(path), d.controls())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.backlight != Defaults.Backlight || d.contrast != Defaults.Contrast {
+		t.Errorf("defaults not applied: got %v, %v", d.backlight, d.contrast)
+	}
+
+	heart := [8]byte{0x00, 0x0a, 0x1f, 0x1f, 0x0e, 0x04, 0x00, 0x00}
+	if err := m.SetContrast(0.7); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetGlyph(2, heart); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetProfile("night"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetBacklight(1.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetRightToLeft(true); err != nil {
+		t.Fatal(err)
+	}
+	if d.backlight != 1 || !d.rtl {
+		t.Errorf("settings not applied: got %+v", d)
+	}
+
+	// A restart applies the saved profile.
+	d = &display{glyphs: map[byte][8]byte{}}
+	m, err = Open(File(path), d.controls())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Profile() != "night" {
+		t.Errorf("profile: got %v, want night", m.Profile())
+	}
+	if got, want := m.Profiles(), []string{"default", "night"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("profiles: got %v, want %v", got, want)
+	}
+	if d.contrast != 0.7 || !d.rtl || d.glyphs[2] != heart {
+		t.Errorf("saved settings not applied: got %+v", d)
+	}
+
+	if err := m.SetProfile(DefaultProfile); err != nil {
+		t.Fatal(err)
+	}
+	if d.rtl {
+		t.Error("default profile writing right to left")
+	}
+	if err := m.DeleteProfile(DefaultProfile); err == nil {
+		t.Error("no error deleting the current profile")
+	}
+}
+
+type kv map[string][]byte
+
+func (s kv) Get(key string) ([]byte, bool) {
+	v, ok := s[key]
+	return v, ok
+}
+
+func (s kv) Set(key string, value []byte) error {
+	s[key] = value
+	return nil
+}
+
+func TestKV(t *testing.T) {
+	store := kv{}
+	d := &display{glyphs: map[byte][8]byte{}}
+	m, err := Open(KV(store, "display"), d.controls())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetBacklight(0.25); err != nil {
+		t.Fatal(err)
+	}
+	if len(store["display"]) == 0 {
+		t.Fatal("settings not saved")
+	}
+
+	d = &display{glyphs: map[byte][8]byte{}}
+	if _, err := Open(KV(store, "display"), d.controls()); err != nil {
+		t.Fatal(err)
+	}
+	if d.backlight != 0.25 {
+		t.Errorf("backlight: got %v, want 0.25", d.backlight)
+	}
+}
@@ -0,0 +1,282 @@
+/*
+Package timefmt formats times, durations and relative times within the
+columns available on small displays.
+
+Each value is rendered with the most detail which fits the width, so that a
+time shows its seconds on a 20 column line but not in a 5 column region.
+The values are encoded in the character set of the display, so that their
+width is their length in bytes:
+
+	f := timefmt.Formatter{Locale: timefmt.Locales["de"]}
+	region.Write(f.Time(time.Now(), region.Width()))
+	region.Write(f.Relative(lastSeen, time.Now(), 8)) // "vor 3m"
+*/
+package timefmt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kidoman/embd/interface/display/charset"
+)
+
+// DateOrder is the order of the day, month and year of numeric dates.
+type DateOrder int
+
+// Date orders.
+const (
+	DMY DateOrder = iota
+	MDY
+	YMD
+)
+
+// Locale is how times are written in a language and region.
+type Locale struct {
+	// Days are the short names of the days, from Sunday.
+	Days [7]string
+	// Months are the short names of the months, from January.
+	Months [12]string
+	// AM and PM are the suffixes of the 12-hour times.
+	AM, PM string
+
+	Order DateOrder
+	// DateSep separates the fields of numeric dates.
+	DateSep string
+
+	// Units are the short units of durations, of days, hours, minutes
+	// and seconds.
+	Units [4]string
+	// Now is the relative time of the present.
+	Now string
+	// Ago and In are the templates of the past and future relative
+	// times, %v being the duration.
+	Ago, In string
+}
+
+// English is the US English locale.
+var English = &Locale{
+	Days:    [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+	Months:  [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	AM:      "am",
+	PM:      "pm",
+	Order:   MDY,
+	DateSep: "/",
+	Units:   [4]string{"d", "h", "m", "s"},
+	Now:     "now",
+	Ago:     "%v ago",
+	In:      "in %v",
+}
+
+// German is the German locale.
+var German = &Locale{
+	Days:    [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+	Months:  [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+	Order:   DMY,
+	DateSep: ".",
+	Units:   [4]string{"T", "h", "m", "s"},
+	Now:     "jetzt",
+	Ago:     "vor %v",
+	In:      "in %v",
+}
+
+// French is the French locale.
+var French = &Locale{
+	Days:    [7]string{"dim", "lun", "mar", "mer", "jeu", "ven", "sam"},
+	Months:  [12]string{"jan", "fév", "mar", "avr", "mai", "jun", "jul", "aoû", "sep", "oct", "nov", "déc"},
+	Order:   DMY,
+	DateSep: "/",
+	Units:   [4]string{"j", "h", "m", "s"},
+	Now:     "maint.",
+	Ago:     "il y a %v",
+	In:      "dans %v",
+}
+
+// Locales are the known locales, by name, to follow the locale of the
+// interface.
+var Locales = map[string]*Locale{
+	"en": English,
+	"de": German,
+	"fr": French,
+}
+
+// Formatter formats times in a locale.
+type Formatter struct {
+	// Locale is English when nil.
+	Locale *Locale
+	// Hour12 writes the hours from 1 to 12, with AM and PM.
+	Hour12 bool
+	// Charset is the character set of the display, charset.A00 when nil.
+	Charset charset.Charset
+}
+
+func (f Formatter) locale() *Locale {
+	if f.Locale == nil {
+		return English
+	}
+	return f.Locale
+}
+
+// fit returns the first candidate no wider than width once encoded, or the
+// last one truncated.
+func (f Formatter) fit(width int, candidates ...string) string {
+	if width <= 0 {
+		return ""
+	}
+	cs := f.Charset
+	if cs == nil {
+		cs = charset.A00
+	}
+	var s string
+	for _, c := range candidates {
+		if s = cs.Encode(c); len(s) <= width {
+			return s
+		}
+	}
+	return s[:width]
+}
+
+// clock returns the time of day, with or without the seconds.
+func (f Formatter) clock(t time.Time, seconds bool) string {
+	l := f.locale()
+	h, suffix := t.Hour(), ""
+	if f.Hour12 {
+		suffix = l.AM
+		if h >= 12 {
+			suffix = l.PM
+		}
+		if h = h % 12; h == 0 {
+			h = 12
+		}
+	}
+	s := fmt.Sprintf("%02d:%02d", h, t.Minute())
+	if f.Hour12 {
+		s = fmt.Sprintf("%d:%02d", h, t.Minute())
+	}
+	if seconds {
+		s += fmt.Sprintf(":%02d", t.Second())
+	}
+	return s + suffix
+}
+
+// Clock formats the time of day: 15:04:05, or 15:04 when narrower.
+func (f Formatter) Clock(t time.Time, width int) string {
+	return f.fit(width, f.clock(t, true), f.clock(t, false))
+}
+
+// numeric returns the numeric date, with or without the year.
+func (f Formatter) numeric(t time.Time, year bool) string {
+	l := f.locale()
+	d, m := fmt.Sprintf("%02d", t.Day()), fmt.Sprintf("%02d", int(t.Month()))
+	y := fmt.Sprintf("%02d", t.Year()%100)
+	var fields []string
+	switch l.Order {
+	case MDY:
+		fields = []string{m, d, y}
+	case YMD:
+		fields = []string{y, m, d}
+	default:
+		fields = []string{d, m, y}
+	}
+	if !year {
+		if l.Order == YMD {
+			fields = fields[1:]
+		} else {
+			fields = fields[:2]
+		}
+	}
+	return strings.Join(fields, l.DateSep)
+}
+
+// named returns the date with the name of the month, with or without the
+// day of the week and the year.
+func (f Formatter) named(t time.Time, weekday, year bool) string {
+	l := f.locale()
+	month := l.Months[t.Month()-1]
+	var s string
+	if l.Order == MDY {
+		s = fmt.Sprintf("%v %d", month, t.Day())
+		if year {
+			s += fmt.Sprintf(", %d", t.Year())
+		}
+	} else {
+		s = fmt.Sprintf("%d %v", t.Day(), month)
+		if year {
+			s += fmt.Sprintf(" %d", t.Year())
+		}
+	}
+	if weekday {
+		s = l.Days[t.Weekday()] + " " + s
+	}
+	return s
+}
+
+// Date formats the date: from "Mon Jan 2, 2006" down to "01/02".
+func (f Formatter) Date(t time.Time, width int) string {
+	return f.fit(width,
+		f.named(t, true, true),
+		f.named(t, false, true),
+		f.named(t, true, false),
+		f.numeric(t, true),
+		f.named(t, false, false),
+		f.numeric(t, false))
+}
+
+// Time formats the date and time: from "Mon Jan 2 15:04:05" down to the
+// time of day alone.
+func (f Formatter) Time(t time.Time, width int) string {
+	return f.fit(width,
+		f.named(t, true, false)+" "+f.clock(t, true),
+		f.named(t, false, false)+" "+f.clock(t, true),
+		f.named(t, false, false)+" "+f.clock(t, false),
+		f.numeric(t, false)+" "+f.clock(t, false),
+		f.clock(t, true),
+		f.clock(t, false))
+}
+
+// duration writes d with at most n units: 1d2h, 2h3m or 3m4s.
+func (f Formatter) duration(d time.Duration, n int) string {
+	l := f.locale()
+	if d < 0 {
+		d = -d
+	}
+	sizes := [4]time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var parts []string
+	for i, size := range sizes {
+		v := d / size
+		d -= v * size
+		if v == 0 && len(parts) == 0 && i < len(sizes)-1 {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%d%v", v, l.Units[i]))
+		if len(parts) == n {
+			break
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// Duration formats a duration: from "1d2h3m4s" down to its largest unit,
+// like "1d". The duration is rounded down.
+func (f Formatter) Duration(d time.Duration, width int) string {
+	return f.fit(width, f.duration(d, 4), f.duration(d, 3), f.duration(d, 2), f.duration(d, 1))
+}
+
+// Relative formats the time t relative to now, like "3m ago" or "in 2h".
+// Times within a second of now are "now". The words are left out when
+// narrower.
+func (f Formatter) Relative(t, now time.Time, width int) string {
+	l := f.locale()
+	d := now.Sub(t)
+	if d > -time.Second && d < time.Second {
+		return f.fit(width, l.Now)
+	}
+	tmpl := l.Ago
+	if d < 0 {
+		tmpl = l.In
+	}
+	return f.fit(width,
+		fmt.Sprintf(tmpl, f.duration(d, 2)),
+		fmt.Sprintf(tmpl, f.duration(d, 1)),
+		f.duration(d, 1))
+}
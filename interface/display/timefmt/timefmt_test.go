@@ -0,0 +1,112 @@
+package timefmt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/interface/display/charset"
+)
+
+var when = time.Date(2016, time.March, 7, 15, 4, 5, 0, time.UTC)
+
+func TestTime(t *testing.T) {
+	cases := []struct {
+		f        Formatter
+		width    int
+		expected string
+	}{
+		{Formatter{}, 20, "Mon Mar 7 15:04:05"},
+		{Formatter{}, 16, "Mar 7 15:04:05"},
+		{Formatter{}, 12, "Mar 7 15:04"},
+		{Formatter{}, 8, "15:04:05"},
+		{Formatter{}, 5, "15:04"},
+		{Formatter{}, 3, "15:"},
+		{Formatter{Hour12: true}, 8, "3:04pm"},
+		{Formatter{Locale: German}, 16, "7 M\xe1r 15:04:05"},
+		{Formatter{Locale: German}, 11, "7 M\xe1r 15:04"},
+		{Formatter{Locale: German}, 10, "15:04:05"},
+		{Formatter{Locale: German, Charset: charset.ASCII}, 12, "7 Maer 15:04"},
+		{Formatter{Locale: German, Charset: charset.ASCII}, 11, "07.03 15:04"},
+	}
+	for _, c := range cases {
+		if got := c.f.Time(when, c.width); got != c.expected {
+			t.Errorf("%+v width %v: expected %q, got %q", c.f, c.width, c.expected, got)
+		}
+	}
+}
+
+func TestDate(t *testing.T) {
+	cases := []struct {
+		f        Formatter
+		width    int
+		expected string
+	}{
+		{Formatter{}, 16, "Mon Mar 7, 2016"},
+		{Formatter{}, 12, "Mar 7, 2016"},
+		{Formatter{}, 8, "03/07/16"},
+		{Formatter{}, 5, "Mar 7"},
+		{Formatter{Locale: German}, 8, "Mo 7 M\xe1r"},
+		{Formatter{Locale: German}, 7, "7 M\xe1r"},
+		{Formatter{Locale: French}, 16, "lun 7 mar 2016"},
+	}
+	for _, c := range cases {
+		if got := c.f.Date(when, c.width); got != c.expected {
+			t.Errorf("%+v width %v: expected %q, got %q", c.f, c.width, c.expected, got)
+		}
+	}
+
+	dec := time.Date(2016, time.December, 7, 0, 0, 0, 0, time.UTC)
+	if got := (Formatter{Locale: French}).Date(dec, 14); got != "mer 7 dec 2016" {
+		t.Errorf("expected the month transliterated, got %q", got)
+	}
+
+	ymd := Formatter{Locale: &Locale{Order: YMD, DateSep: "-"}}
+	if got := ymd.numeric(when, true); got != "16-03-07" {
+		t.Errorf("YMD: expected 16-03-07, got %q", got)
+	}
+	if got := ymd.numeric(when, false); got != "03-07" {
+		t.Errorf("YMD without year: expected 03-07, got %q", got)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	d := 26*time.Hour + 3*time.Minute + 4*time.Second
+	cases := []struct {
+		d        time.Duration
+		width    int
+		expected string
+	}{
+		{d, 10, "1d2h3m4s"},
+		{d, 6, "1d2h3m"},
+		{d, 4, "1d2h"},
+		{d, 2, "1d"},
+		{90 * time.Second, 8, "1m30s"},
+		{0, 8, "0s"},
+	}
+	for _, c := range cases {
+		if got := (Formatter{}).Duration(c.d, c.width); got != c.expected {
+			t.Errorf("%v width %v: expected %q, got %q", c.d, c.width, c.expected, got)
+		}
+	}
+}
+
+func TestRelative(t *testing.T) {
+	cases := []struct {
+		f        Formatter
+		d        time.Duration
+		width    int
+		expected string
+	}{
+		{Formatter{}, 3*time.Minute + 10*time.Second, 10, "3m10s ago"},
+		{Formatter{}, 3*time.Minute + 10*time.Second, 6, "3m ago"},
+		{Formatter{}, 3*time.Minute + 10*time.Second, 3, "3m"},
+		{Formatter{}, -2 * time.Hour, 8, "in 2h0m"},
+		{Formatter{}, 0, 8, "now"},
+		{Formatter{Locale: German}, time.Hour, 8, "vor 1h0m"},
+	}
+	for _, c := range cases {
+		if got := c.f.Relative(when.Add(-c.d), when, c.width); got != c.expected {
+			t.Errorf("%v width %v: expected %q, got %q", c.d, c.width, c.expected, got)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+/*
+Package frontpanel presents the front panel of a device, its display, LEDs
+and buttons, on remote terminals, so that support staff can see and use it
+without being in front of it.
+
+The panel is drawn with ANSI escape sequences, and the keys typed on the
+terminal press its virtual buttons:
+
+	p := frontpanel.New(disp)
+	p.AddLED("power", func() bool { return true })
+	status := blink.New(p.Mirror("status", blink.Pin(statusPin)))
+	page := p.AddButton("page", 'p')
+	go func() {
+		for g := range page {
+			...
+		}
+	}()
+	go p.ListenTelnet(":2323")
+	defer p.Close()
+
+Typing a button key clicks the button, and typing it in uppercase long
+presses it. Ctrl-C, Ctrl-D or q end the session.
+
+SSH sessions, served for example with golang.org/x/crypto/ssh, are
+presented by passing their channels to Serve.
+*/
+package frontpanel
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/interface/blink"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+	"github.com/kidoman/embd/interface/hotkey"
+)
+
+const (
+	// DefaultRefresh is how often the sessions are redrawn, when the
+	// panel changed.
+	DefaultRefresh = 200 * time.Millisecond
+
+	gesturesBuffer = 16
+)
+
+type led struct {
+	name  string
+	state func() bool
+}
+
+type button struct {
+	name     string
+	key      byte
+	gestures chan hotkey.Gesture
+}
+
+// Panel is the front panel of a device.
+type Panel struct {
+	Display *characterdisplay.Display
+	// Refresh is how often the sessions are redrawn.
+	Refresh time.Duration
+
+	mu       sync.Mutex
+	leds     []led
+	buttons  []*button
+	listener net.Listener
+	sessions map[*session]struct{}
+	closed   bool
+}
+
+// New creates a new front panel showing the display, which may be nil.
+func New(disp *characterdisplay.Display) *Panel {
+	return &Panel{
+		Display:  disp,
+		Refresh:  DefaultRefresh,
+		sessions: map[*session]struct{}{},
+	}
+}
+
+// AddLED adds an LED, whose state is read from state.
+func (p *Panel) AddLED(name string, state func() bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.leds = append(p.leds, led{name, state})
+}
+
+// Mirror adds an LED mirroring a blink output, and returns the output
+// driving both.
+func (p *Panel) Mirror(name string, out blink.Output) blink.Output {
+	var mu sync.Mutex
+	var on bool
+	p.AddLED(name, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return on
+	})
+	return func(level float64) error {
+		mu.Lock()
+		on = level > 0
+		mu.Unlock()
+		if out == nil {
+			return nil
+		}
+		return out(level)
+	}
+}
+
+// AddButton adds a virtual button pressed with a key, and returns the
+// channel its gestures are sent on. Gestures are dropped when the channel
+// is full.
+func (p *Panel) AddButton(name string, key byte) <-chan hotkey.Gesture {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b := &button{name: name, key: lower(key), gestures: make(chan hotkey.Gesture, gesturesBuffer)}
+	p.buttons = append(p.buttons, b)
+	return b.gestures
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// press presses the button of a key, and returns whether there was one.
+func (p *Panel) press(c byte) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	g := hotkey.Click
+	if c >= 'A' && c <= 'Z' {
+		g = hotkey.LongPress
+	}
+	for _, b := range p.buttons {
+		if b.key != lower(c) {
+			continue
+		}
+		glog.V(2).Infof("frontpanel: %v %v", b.name, g)
+		select {
+		case b.gestures <- g:
+		default:
+			glog.Warningf("frontpanel: dropping %v of %v, gestures are not being read", g, b.name)
+		}
+		return true
+	}
+	return false
+}
+
+// frame draws the panel.
+func (p *Panel) frame() string {
+	var buf bytes.Buffer
+	if p.Display != nil {
+		lines := p.Display.Contents()
+		width := 0
+		for _, line := range lines {
+			if len(line) > width {
+				width = len(line)
+			}
+		}
+		border := "+" + strings.Repeat("-", width) + "+"
+		fmt.Fprintf(&buf, "%v\r\n", border)
+		for _, line := range lines {
+			fmt.Fprintf(&buf, "|%v|\r\n", printable(line))
+		}
+		fmt.Fprintf(&buf, "%v\r\n", border)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.leds) > 0 {
+		for _, l := range p.leds {
+			state := " "
+			if l.state() {
+				state = "\x1b[1;32m*\x1b[0m"
+			}
+			fmt.Fprintf(&buf, "(%v) %v  ", state, l.name)
+		}
+		buf.WriteString("\r\n")
+	}
+	if len(p.buttons) > 0 {
+		buttons := make([]string, len(p.buttons))
+		for i, b := range p.buttons {
+			buttons[i] = fmt.Sprintf("[%c] %v", b.key, b.name)
+		}
+		sort.Strings(buttons)
+		fmt.Fprintf(&buf, "%v\r\n", strings.Join(buttons, "  "))
+		buf.WriteString("Uppercase keys long press, q quits.\r\n")
+	}
+	return buf.String()
+}
+
+// printable replaces the characters a terminal cannot show, like the custom
+// characters of the display.
+func printable(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c < ' ' || c > '~' {
+			b[i] = '?'
+		}
+	}
+	return string(b)
+}
+
+type session struct {
+	rw   io.ReadWriter
+	quit chan struct{}
+	once sync.Once
+}
+
+func (s *session) end() {
+	s.once.Do(func() { close(s.quit) })
+}
+
+// Serve presents the panel on a terminal until the session ends or the
+// panel is closed.
+func (p *Panel) Serve(rw io.ReadWriter) error {
+	s := &session{rw: rw, quit: make(chan struct{})}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return io.ErrClosedPipe
+	}
+	p.sessions[s] = struct{}{}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.sessions, s)
+		p.mu.Unlock()
+	}()
+
+	go p.read(s)
+
+	// Clear the screen, and hide the cursor.
+	if _, err := io.WriteString(rw, "\x1b[2J\x1b[?25l"); err != nil {
+		return err
+	}
+	defer io.WriteString(rw, "\x1b[?25h\r\n")
+
+	ticker := time.NewTicker(p.Refresh)
+	defer ticker.Stop()
+
+	last := ""
+	for {
+		if f := p.frame(); f != last {
+			if _, err := io.WriteString(rw, "\x1b[H"+strings.Replace(f, "\r\n", "\x1b[K\r\n", -1)); err != nil {
+				return err
+			}
+			last = f
+		}
+		select {
+		case <-ticker.C:
+		case <-s.quit:
+			return nil
+		}
+	}
+}
+
+// read presses the buttons of the keys typed in a session.
+func (p *Panel) read(s *session) {
+	defer s.end()
+
+	buf := make([]byte, 64)
+	for {
+		n, err := s.rw.Read(buf)
+		for _, c := range buf[:n] {
+			switch c {
+			case 3, 4, 'q':
+				return
+			}
+			p.press(c)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// ServeTelnet presents the panel to the telnet clients connecting to the
+// listener, until it is closed.
+func (p *Panel) ServeTelnet(l net.Listener) error {
+	p.mu.Lock()
+	p.listener = l
+	p.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			p.mu.Lock()
+			closed := p.closed
+			p.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		glog.V(1).Infof("frontpanel: session from %v", conn.RemoteAddr())
+		go func() {
+			defer conn.Close()
+			if err := p.Serve(newTelnet(conn)); err != nil {
+				glog.V(1).Infof("frontpanel: session from %v: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// ListenTelnet listens on the TCP address, and presents the panel to the
+// telnet clients connecting to it.
+func (p *Panel) ListenTelnet(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return p.ServeTelnet(l)
+}
+
+// Close stops listening, and ends the sessions.
+func (p *Panel) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	for s := range p.sessions {
+		s.end()
+	}
+	if p.listener != nil {
+		return p.listener.Close()
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package frontpanel
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+	"github.com/kidoman/embd/interface/display/characterdisplay/recording"
+	"github.com/kidoman/embd/interface/hotkey"
+)
+
+func TestServe(t *testing.T) {
+	disp := characterdisplay.New(recording.NewScreen(8, 2), 8, 2)
+	disp.Message("Hello")
+	p := New(disp)
+	p.Refresh = time.Millisecond
+	p.AddLED("power", func() bool { return true })
+	page := p.AddButton("page", 'p')
+
+	server, client := net.Pipe()
+	defer client.Close()
+	done := make(chan error, 1)
+	go func() { done <- p.Serve(server) }()
+
+	r := bufio.NewReader(client)
+	var out bytes.Buffer
+	for !strings.Contains(out.String(), "[p] page") {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.WriteString(line)
+	}
+	for _, want := range []string{"|Hello   |", "+--------+", "power"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("panel %q does not show %q", out.String(), want)
+		}
+	}
+	go io.Copy(ioutil.Discard, r)
+
+	client.Write([]byte("pPx"))
+	for _, want := range []hotkey.Gesture{hotkey.Click, hotkey.LongPress} {
+		select {
+		case g := <-page:
+			if g != want {
+				t.Errorf("expected %v, got %v", want, g)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %v", want)
+		}
+	}
+
+	client.Write([]byte("q"))
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("session not ended by q")
+	}
+}
+
+func TestTelnet(t *testing.T) {
+	var out bytes.Buffer
+	in := []byte{'a', telnetIAC, telnetDo, telnetEcho, 'b', telnetIAC, telnetSB, 31, 0, 80, telnetIAC, telnetSE, '\r', 0, telnetIAC, telnetIAC}
+	tn := newTelnet(struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(in), &out})
+
+	got, err := ioutil.ReadAll(tn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{'a', 'b', '\r', telnetIAC}; !bytes.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if out.Len() == 0 {
+		t.Error("no options negotiated")
+	}
+}
@@ -0,0 +1,89 @@
+package frontpanel
+
+import "io"
+
+// Telnet commands and options.
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWill = 251
+	telnetWont = 252
+	telnetDo   = 253
+	telnetDont = 254
+	telnetIAC  = 255
+
+	telnetEcho            = 1
+	telnetSuppressGoAhead = 3
+)
+
+// telnet is a telnet connection in character mode, its reads stripped of
+// the telnet commands.
+type telnet struct {
+	io.ReadWriter
+
+	// state is the position in the command being stripped: 0 outside of
+	// a command, 1 after IAC, 2 before an option, 3 in a subnegotiation
+	// and 4 after IAC in a subnegotiation.
+	state int
+}
+
+func newTelnet(rw io.ReadWriter) *telnet {
+	// The server echoes, that is not at all, and the client sends the
+	// characters as they are typed.
+	rw.Write([]byte{
+		telnetIAC, telnetWill, telnetEcho,
+		telnetIAC, telnetWill, telnetSuppressGoAhead,
+		telnetIAC, telnetDont, telnetEcho,
+	})
+	return &telnet{ReadWriter: rw}
+}
+
+func (t *telnet) Read(b []byte) (int, error) {
+	for {
+		n, err := t.ReadWriter.Read(b)
+		m := 0
+		for _, c := range b[:n] {
+			switch t.state {
+			case 0:
+				if c == telnetIAC {
+					t.state = 1
+					continue
+				}
+				if c == 0 {
+					// Telnet sends CR NUL for a lone CR.
+					continue
+				}
+				b[m] = c
+				m++
+			case 1:
+				switch c {
+				case telnetWill, telnetWont, telnetDo, telnetDont:
+					t.state = 2
+				case telnetSB:
+					t.state = 3
+				case telnetIAC:
+					b[m] = c
+					m++
+					t.state = 0
+				default:
+					t.state = 0
+				}
+			case 2:
+				t.state = 0
+			case 3:
+				if c == telnetIAC {
+					t.state = 4
+				}
+			case 4:
+				if c == telnetSE {
+					t.state = 0
+				} else {
+					t.state = 3
+				}
+			}
+		}
+		if m > 0 || err != nil {
+			return m, err
+		}
+	}
+}
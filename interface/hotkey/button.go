@@ -0,0 +1,242 @@
+// Button gestures.
+
+package hotkey
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+)
+
+const (
+	defaultDebounce    = 20 * time.Millisecond
+	defaultLongPress   = time.Second
+	defaultDoubleClick = 300 * time.Millisecond
+
+	gesturesBuffer = 16
+)
+
+// Gesture is something done with a button.
+type Gesture int
+
+// Gestures.
+const (
+	Press Gesture = iota
+	Release
+	Click
+	DoubleClick
+	LongPress
+)
+
+var gestureNames = []string{"press", "release", "click", "double-click", "long-press"}
+
+func (g Gesture) String() string {
+	if g < 0 || int(g) >= len(gestureNames) {
+		return fmt.Sprintf("Gesture(%d)", int(g))
+	}
+	return gestureNames[g]
+}
+
+// ParseGesture parses the name of a gesture, as returned by String.
+func ParseGesture(s string) (Gesture, error) {
+	for i, name := range gestureNames {
+		if strings.EqualFold(s, name) {
+			return Gesture(i), nil
+		}
+	}
+	return 0, fmt.Errorf("hotkey: unknown gesture %q", s)
+}
+
+// Button recognizes gestures on a push button wired to a GPIO pin.
+//
+// Every button press sends Press and Release. A press held for LongPress
+// sends LongPress instead of Click once released. Two clicks within
+// DoubleClick send a single DoubleClick; a Click is then only sent once it is
+// clear no second one follows, so set DoubleClick to zero for snappier clicks
+// when double clicks are not needed.
+type Button struct {
+	Pin embd.DigitalPin
+
+	// ActiveLow is set for buttons pulling the pin low when pressed.
+	ActiveLow bool
+
+	// Debounce is how long the pin must be stable for a change to count.
+	Debounce time.Duration
+
+	LongPress   time.Duration
+	DoubleClick time.Duration
+
+	// Clock times the gestures, clock.Real by default.
+	Clock clock.Clock
+
+	mu       sync.Mutex
+	pressed  bool
+	long     bool // the current press was a long press
+	second   bool // the current press is the second click of a double click
+	settle   clock.Timer
+	held     clock.Timer
+	click    clock.Timer
+	gestures chan Gesture
+	closed   bool
+}
+
+// NewButton creates a new Button on a pin.
+func NewButton(pin embd.DigitalPin) *Button {
+	return &Button{
+		Pin:         pin,
+		Debounce:    defaultDebounce,
+		LongPress:   defaultLongPress,
+		DoubleClick: defaultDoubleClick,
+		gestures:    make(chan Gesture, gesturesBuffer),
+	}
+}
+
+// Gestures returns the channel receiving the gestures, once Run is called.
+// It is closed by Close.
+func (b *Button) Gestures() <-chan Gesture {
+	return b.gestures
+}
+
+// Run starts watching the pin.
+func (b *Button) Run() error {
+	if err := b.Pin.SetDirection(embd.In); err != nil {
+		return err
+	}
+	level, err := b.Pin.Read()
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.pressed = b.isPressed(level)
+	b.mu.Unlock()
+
+	return b.Pin.Watch(embd.EdgeBoth, func(embd.DigitalPin) {
+		b.edge()
+	})
+}
+
+func (b *Button) isPressed(level int) bool {
+	return (level == embd.High) != b.ActiveLow
+}
+
+// edge restarts the debounce timer: the pin is only read once it has been
+// quiet for Debounce.
+func (b *Button) edge() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	if b.settle == nil {
+		b.settle = clock.Or(b.Clock).AfterFunc(b.Debounce, b.settled)
+	} else {
+		b.settle.Reset(b.Debounce)
+	}
+}
+
+func (b *Button) settled() {
+	level, err := b.Pin.Read()
+	if err != nil {
+		glog.Errorf("hotkey: reading button: %v", err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	if pressed := b.isPressed(level); pressed != b.pressed {
+		b.change(pressed)
+	}
+}
+
+// change handles a debounced change of the button state. It must be called
+// with the lock held.
+func (b *Button) change(pressed bool) {
+	b.pressed = pressed
+	if pressed {
+		b.send(Press)
+		b.long = false
+		if b.click != nil && b.click.Stop() {
+			b.second = true
+		}
+		if b.LongPress > 0 {
+			b.held = clock.Or(b.Clock).AfterFunc(b.LongPress, b.longPress)
+		}
+		return
+	}
+
+	b.send(Release)
+	if b.held != nil {
+		b.held.Stop()
+	}
+	switch {
+	case b.long:
+	case b.second:
+		b.second = false
+		b.send(DoubleClick)
+	case b.DoubleClick > 0:
+		b.click = clock.Or(b.Clock).AfterFunc(b.DoubleClick, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			if !b.closed {
+				b.send(Click)
+			}
+		})
+	default:
+		b.send(Click)
+	}
+}
+
+func (b *Button) longPress() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed || !b.pressed {
+		return
+	}
+	b.long = true
+	// A long press is not the second click of a double click, so the
+	// first one is a click on its own.
+	if b.second {
+		b.second = false
+		b.send(Click)
+	}
+	b.send(LongPress)
+}
+
+func (b *Button) send(g Gesture) {
+	glog.V(2).Infof("hotkey: %v", g)
+	select {
+	case b.gestures <- g:
+	default:
+		glog.Warningf("hotkey: dropping %v, gestures are not being read", g)
+	}
+}
+
+// Close stops watching the pin and closes the Gestures channel.
+func (b *Button) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for _, t := range []clock.Timer{b.settle, b.held, b.click} {
+		if t != nil {
+			t.Stop()
+		}
+	}
+	close(b.gestures)
+	return b.Pin.StopWatching()
+}
@@ -0,0 +1,371 @@
+/*
+Package hotkey maps button gestures to shell commands and Go callbacks, to
+get things like a shutdown button or a display page button without writing
+a program for it.
+
+The mappings are usually loaded from a YAML file:
+
+	shell: /bin/sh
+	buttons:
+	  - name: power
+	    pin: GPIO_17
+	    active_low: true
+	    pull: up
+	    actions:
+	      - gesture: long-press
+	        command: sudo shutdown -h now
+	  - name: page
+	    pin: GPIO_27
+	    active_low: true
+	    pull: up
+	    actions:
+	      - gesture: click
+	        callback: next-page
+	        every: 200ms
+	      - gesture: double-click
+	        callback: previous-page
+
+Callbacks are registered with Handle before loading the mappings:
+
+	d := hotkey.New()
+	d.Handle("next-page", pages.Next)
+	d.Handle("previous-page", pages.Previous)
+	cfg, err := hotkey.LoadConfig("/etc/hotkey.yaml")
+	if err != nil {
+		panic(err)
+	}
+	if err := d.Load(cfg); err != nil {
+		panic(err)
+	}
+	if err := d.Run(); err != nil {
+		panic(err)
+	}
+	defer d.Close()
+
+The embd command line tool runs the same mappings with "embd hotkey", for
+the commands only.
+*/
+package hotkey
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"gopkg.in/yaml.v2"
+)
+
+const defaultShell = "/bin/sh"
+
+// Action is run when a button gesture is recognized.
+type Action struct {
+	Gesture Gesture
+
+	// Command is run with the shell of the daemon, if set. The name of the
+	// button and the gesture are passed in the HOTKEY_BUTTON and
+	// HOTKEY_GESTURE environment variables.
+	Command string
+
+	// Func is called, if set.
+	Func func()
+
+	// Every limits the action to one run per interval. Gestures are also
+	// ignored while the action is still running.
+	Every time.Duration
+
+	mu      sync.Mutex
+	running bool
+	last    time.Time
+}
+
+// start returns whether the action may run now, and marks it running.
+func (a *Action) start(now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.running || (!a.last.IsZero() && now.Sub(a.last) < a.Every) {
+		return false
+	}
+	a.running = true
+	a.last = now
+	return true
+}
+
+func (a *Action) finish() {
+	a.mu.Lock()
+	a.running = false
+	a.mu.Unlock()
+}
+
+type binding struct {
+	name    string
+	button  *Button
+	actions []*Action
+
+	// pin is set when the pin was opened by Load, to be closed with the
+	// daemon.
+	pin embd.DigitalPin
+}
+
+// Daemon runs actions on button gestures.
+type Daemon struct {
+	// Shell runs the commands, with the -c option.
+	Shell string
+
+	mu        sync.Mutex
+	callbacks map[string]func()
+	bindings  []*binding
+
+	wg sync.WaitGroup
+}
+
+// New creates a new Daemon.
+func New() *Daemon {
+	return &Daemon{Shell: defaultShell, callbacks: map[string]func(){}}
+}
+
+// Handle registers a callback which the loaded mappings can refer to by
+// name.
+func (d *Daemon) Handle(name string, fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.callbacks[name] = fn
+}
+
+// Bind runs the actions on the gestures of a button. The name identifies
+// the button in the logs and the commands.
+func (d *Daemon) Bind(name string, b *Button, actions ...*Action) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.bindings = append(d.bindings, &binding{name: name, button: b, actions: actions})
+}
+
+// Config is the configuration of a Daemon.
+type Config struct {
+	// Shell overrides the shell running the commands.
+	Shell   string         `yaml:"shell"`
+	Buttons []ButtonConfig `yaml:"buttons"`
+}
+
+// ButtonConfig configures a button and its actions.
+type ButtonConfig struct {
+	Name string `yaml:"name"`
+
+	// Pin is the key of the GPIO pin, as passed to embd.NewDigitalPin.
+	Pin       string `yaml:"pin"`
+	ActiveLow bool   `yaml:"active_low"`
+
+	// Pull enables the internal "up" or "down" resistor.
+	Pull string `yaml:"pull"`
+
+	// The timings default to those of NewButton, except DoubleClick which
+	// defaults to zero when no action is bound to double clicks.
+	Debounce    time.Duration `yaml:"debounce"`
+	LongPress   time.Duration `yaml:"long_press"`
+	DoubleClick time.Duration `yaml:"double_click"`
+
+	Actions []ActionConfig `yaml:"actions"`
+}
+
+// ActionConfig configures an action, which runs a command, a callback
+// registered with Handle, or both.
+type ActionConfig struct {
+	// Gesture defaults to "click".
+	Gesture  string        `yaml:"gesture"`
+	Command  string        `yaml:"command"`
+	Callback string        `yaml:"callback"`
+	Every    time.Duration `yaml:"every"`
+}
+
+// LoadConfig reads a YAML configuration file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig parses a YAML configuration.
+func ParseConfig(data []byte) (*Config, error) {
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, fmt.Errorf("hotkey: %v", err)
+	}
+	return c, nil
+}
+
+// actions resolves the actions of a button.
+func (d *Daemon) actions(bc *ButtonConfig) ([]*Action, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var actions []*Action
+	for _, ac := range bc.Actions {
+		a := &Action{Gesture: Click, Command: ac.Command, Every: ac.Every}
+		if ac.Gesture != "" {
+			g, err := ParseGesture(ac.Gesture)
+			if err != nil {
+				return nil, err
+			}
+			a.Gesture = g
+		}
+		if ac.Callback != "" {
+			fn, ok := d.callbacks[ac.Callback]
+			if !ok {
+				return nil, fmt.Errorf("hotkey: %v: unknown callback %q", bc.Name, ac.Callback)
+			}
+			a.Func = fn
+		}
+		if a.Command == "" && a.Func == nil {
+			return nil, fmt.Errorf("hotkey: %v: %v action without a command or callback", bc.Name, a.Gesture)
+		}
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
+
+// Load opens the pins of the configured buttons and binds their actions.
+// GPIO must be initialized.
+func (d *Daemon) Load(c *Config) error {
+	if c.Shell != "" {
+		d.Shell = c.Shell
+	}
+	for i := range c.Buttons {
+		bc := &c.Buttons[i]
+		if bc.Name == "" {
+			bc.Name = bc.Pin
+		}
+		actions, err := d.actions(bc)
+		if err != nil {
+			return err
+		}
+
+		pin, err := embd.NewDigitalPin(bc.Pin)
+		if err != nil {
+			return err
+		}
+		switch bc.Pull {
+		case "":
+		case "up":
+			err = pin.PullUp()
+		case "down":
+			err = pin.PullDown()
+		default:
+			err = fmt.Errorf("hotkey: %v: unknown pull %q", bc.Name, bc.Pull)
+		}
+		if err != nil {
+			pin.Close()
+			return err
+		}
+
+		b := NewButton(pin)
+		b.ActiveLow = bc.ActiveLow
+		if bc.Debounce > 0 {
+			b.Debounce = bc.Debounce
+		}
+		if bc.LongPress > 0 {
+			b.LongPress = bc.LongPress
+		}
+		b.DoubleClick = bc.DoubleClick
+		if b.DoubleClick == 0 {
+			for _, a := range actions {
+				if a.Gesture == DoubleClick {
+					b.DoubleClick = defaultDoubleClick
+				}
+			}
+		}
+
+		d.mu.Lock()
+		d.bindings = append(d.bindings, &binding{name: bc.Name, button: b, actions: actions, pin: pin})
+		d.mu.Unlock()
+	}
+	return nil
+}
+
+// Run starts watching the buttons.
+func (d *Daemon) Run() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, bd := range d.bindings {
+		if err := bd.button.Run(); err != nil {
+			return err
+		}
+		d.wg.Add(1)
+		go d.dispatch(bd)
+	}
+	return nil
+}
+
+func (d *Daemon) dispatch(bd *binding) {
+	defer d.wg.Done()
+
+	for g := range bd.button.Gestures() {
+		glog.V(1).Infof("hotkey: %v: %v", bd.name, g)
+		for _, a := range bd.actions {
+			if a.Gesture != g {
+				continue
+			}
+			if !a.start(time.Now()) {
+				glog.V(1).Infof("hotkey: %v: ignoring %v, rate limited", bd.name, g)
+				continue
+			}
+			d.wg.Add(1)
+			go d.run(bd.name, a)
+		}
+	}
+}
+
+func (d *Daemon) run(name string, a *Action) {
+	defer d.wg.Done()
+	defer a.finish()
+
+	if a.Func != nil {
+		a.Func()
+	}
+	if a.Command == "" {
+		return
+	}
+	cmd := exec.Command(d.Shell, "-c", a.Command)
+	cmd.Env = append(os.Environ(), "HOTKEY_BUTTON="+name, "HOTKEY_GESTURE="+a.Gesture.String())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		glog.Errorf("hotkey: %v: %q: %v: %s", name, a.Command, err, out)
+		return
+	}
+	glog.V(1).Infof("hotkey: %v: %q: %s", name, a.Command, out)
+}
+
+// Close stops watching the buttons, waits for the running actions and
+// closes the pins opened by Load.
+func (d *Daemon) Close() error {
+	d.mu.Lock()
+	bindings := d.bindings
+	d.bindings = nil
+	d.mu.Unlock()
+
+	var first error
+	for _, bd := range bindings {
+		if err := bd.button.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	d.wg.Wait()
+	for _, bd := range bindings {
+		if bd.pin == nil {
+			continue
+		}
+		if err := bd.pin.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,231 @@
+package hotkey
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+)
+
+type fakePin struct {
+	embd.DigitalPin
+
+	mu      sync.Mutex
+	val     int
+	handler func(embd.DigitalPin)
+}
+
+func (p *fakePin) SetDirection(embd.Direction) error { return nil }
+func (p *fakePin) StopWatching() error               { return nil }
+
+func (p *fakePin) Read() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.val, nil
+}
+
+func (p *fakePin) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
+	p.handler = handler
+	return nil
+}
+
+// set changes the level with some bounces.
+func (p *fakePin) set(val int) {
+	for _, v := range []int{val, 1 - val, val} {
+		p.mu.Lock()
+		p.val = v
+		p.mu.Unlock()
+		p.handler(p)
+	}
+}
+
+func newButton() (*Button, *fakePin) {
+	pin := &fakePin{val: embd.High}
+	b := NewButton(pin)
+	b.ActiveLow = true
+	b.Debounce = 5 * time.Millisecond
+	b.LongPress = 100 * time.Millisecond
+	b.DoubleClick = 50 * time.Millisecond
+	return b, pin
+}
+
+func expect(t *testing.T, b *Button, want ...Gesture) {
+	for _, w := range want {
+		select {
+		case g := <-b.Gestures():
+			if g != w {
+				t.Fatalf("got %v; want %v", g, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %v", w)
+		}
+	}
+	select {
+	case g := <-b.Gestures():
+		t.Fatalf("unexpected %v", g)
+	case <-time.After(80 * time.Millisecond):
+	}
+}
+
+func TestButton(t *testing.T) {
+	b, pin := newButton()
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	pin.set(embd.Low)
+	time.Sleep(20 * time.Millisecond)
+	pin.set(embd.High)
+	expect(t, b, Press, Release, Click)
+
+	pin.set(embd.Low)
+	time.Sleep(20 * time.Millisecond)
+	pin.set(embd.High)
+	time.Sleep(20 * time.Millisecond)
+	pin.set(embd.Low)
+	time.Sleep(20 * time.Millisecond)
+	pin.set(embd.High)
+	expect(t, b, Press, Release, Press, Release, DoubleClick)
+
+	pin.set(embd.Low)
+	time.Sleep(150 * time.Millisecond)
+	pin.set(embd.High)
+	expect(t, b, Press, LongPress, Release)
+}
+
+func TestButton_noDoubleClick(t *testing.T) {
+	b, pin := newButton()
+	b.DoubleClick = 0
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	pin.set(embd.Low)
+	time.Sleep(20 * time.Millisecond)
+	pin.set(embd.High)
+	time.Sleep(20 * time.Millisecond)
+	pin.set(embd.Low)
+	time.Sleep(20 * time.Millisecond)
+	pin.set(embd.High)
+	expect(t, b, Press, Release, Click, Press, Release, Click)
+}
+
+// received returns the gestures sent so far, without waiting.
+func received(b *Button) []Gesture {
+	var gs []Gesture
+	for {
+		select {
+		case g := <-b.Gestures():
+			gs = append(gs, g)
+		default:
+			return gs
+		}
+	}
+}
+
+func TestButton_virtualClock(t *testing.T) {
+	c := clock.NewVirtual(time.Time{})
+	b, pin := newButton()
+	b.Clock = c
+	if err := b.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	pin.set(embd.Low)
+	c.Advance(4 * time.Millisecond)
+	if gs := received(b); len(gs) != 0 {
+		t.Fatalf("got %v before the debounce time", gs)
+	}
+	c.Advance(time.Millisecond)
+	c.Advance(99 * time.Millisecond)
+	if gs := received(b); len(gs) != 1 || gs[0] != Press {
+		t.Fatalf("got %v; want [Press]", gs)
+	}
+	c.Advance(time.Millisecond)
+	pin.set(embd.High)
+	c.Advance(5 * time.Millisecond)
+	want := []Gesture{LongPress, Release}
+	gs := received(b)
+	if len(gs) != len(want) {
+		t.Fatalf("got %v; want %v", gs, want)
+	}
+	for i := range want {
+		if gs[i] != want[i] {
+			t.Fatalf("got %v; want %v", gs, want)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	c, err := ParseConfig([]byte(`
+buttons:
+  - name: power
+    pin: GPIO_17
+    active_low: true
+    pull: up
+    long_press: 3s
+    actions:
+      - gesture: long-press
+        command: shutdown -h now
+      - callback: page
+        every: 200ms
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := c.Buttons[0]
+	if b.Pin != "GPIO_17" || !b.ActiveLow || b.Pull != "up" || b.LongPress != 3*time.Second {
+		t.Errorf("button = %+v", b)
+	}
+	if len(b.Actions) != 2 || b.Actions[1].Every != 200*time.Millisecond {
+		t.Errorf("actions = %+v", b.Actions)
+	}
+
+	d := New()
+	if _, err := d.actions(&b); err == nil {
+		t.Error("no error for an unknown callback")
+	}
+	d.Handle("page", func() {})
+	actions, err := d.actions(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actions[0].Gesture != LongPress || actions[1].Gesture != Click || actions[1].Func == nil {
+		t.Errorf("actions = %+v", actions)
+	}
+
+	if _, err := ParseConfig([]byte("buttons:\n  - pins: 1\n")); err == nil {
+		t.Error("no error for an unknown field")
+	}
+}
+
+func TestDaemon(t *testing.T) {
+	b, pin := newButton()
+	b.DoubleClick = 0
+
+	ran := make(chan bool, 4)
+	d := New()
+	d.Bind("test", b, &Action{Gesture: Click, Every: time.Hour, Func: func() { ran <- true }})
+	if err := d.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		pin.set(embd.Low)
+		time.Sleep(20 * time.Millisecond)
+		pin.set(embd.High)
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(ran); n != 1 {
+		t.Errorf("action ran %v times; want 1", n)
+	}
+}
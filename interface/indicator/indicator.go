@@ -0,0 +1,203 @@
+/*
+Package indicator allows signaling status without knowing which output
+hardware is present. Libraries accept an Indicator and applications decide
+whether it is backed by a RGB LED, a buzzer, a display or all of them:
+
+	led := indicator.NewRGB(red, green, blue)
+	lcd := indicator.NewRegion(display.Region(0, 1, 16))
+	status := indicator.Multi(led, lcd)
+
+	status.Warn("battery low")
+*/
+package indicator
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+)
+
+// Level is the severity of a status.
+type Level int
+
+const (
+	// None clears the indicator.
+	None Level = iota
+	Info
+	Warn
+	Error
+)
+
+func (l Level) String() string {
+	switch l {
+	case None:
+		return "none"
+	case Info:
+		return "info"
+	case Warn:
+		return "warn"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// An Indicator signals status to the user.
+type Indicator interface {
+	Info(msg string) error
+	Warn(msg string) error
+	Error(msg string) error
+	Off() error
+}
+
+// Func adapts a function to the Indicator interface.
+type Func func(level Level, msg string) error
+
+// Info signals an informational status.
+func (f Func) Info(msg string) error {
+	return f(Info, msg)
+}
+
+// Warn signals a warning.
+func (f Func) Warn(msg string) error {
+	return f(Warn, msg)
+}
+
+// Error signals an error.
+func (f Func) Error(msg string) error {
+	return f(Error, msg)
+}
+
+// Off clears the indicator.
+func (f Func) Off() error {
+	return f(None, "")
+}
+
+// Show signals msg on ind with the given level.
+func Show(ind Indicator, level Level, msg string) error {
+	switch level {
+	case Info:
+		return ind.Info(msg)
+	case Warn:
+		return ind.Warn(msg)
+	case Error:
+		return ind.Error(msg)
+	}
+	return ind.Off()
+}
+
+// Multi returns an indicator signaling on all of inds. All of them are
+// updated even if some fail; the first error is returned.
+func Multi(inds ...Indicator) Indicator {
+	return Func(func(level Level, msg string) error {
+		var first error
+		for _, ind := range inds {
+			if err := Show(ind, level, msg); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	})
+}
+
+// NewRGB returns an indicator lighting a RGB LED green for Info, yellow for
+// Warn and red for Error. Use ActiveLow on the pins of common anode LEDs.
+func NewRGB(red, green, blue embd.DigitalPin) Indicator {
+	return Func(func(level Level, msg string) error {
+		r, g, b := embd.Low, embd.Low, embd.Low
+		switch level {
+		case Info:
+			g = embd.High
+		case Warn:
+			r, g = embd.High, embd.High
+		case Error:
+			r = embd.High
+		}
+		for _, w := range []struct {
+			pin embd.DigitalPin
+			val int
+		}{{red, r}, {green, g}, {blue, b}} {
+			if w.pin == nil {
+				continue
+			}
+			if err := w.pin.Write(w.val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Beep is the duration of a buzzer beep.
+const Beep = 100 * time.Millisecond
+
+// NewBuzzer returns an indicator beeping on an active buzzer: once for Info,
+// twice for Warn and three long beeps for Error. The beeps are played in the
+// background.
+func NewBuzzer(pin embd.DigitalPin) Indicator {
+	var (
+		mu         sync.Mutex
+		stop, done chan struct{}
+	)
+	return Func(func(level Level, msg string) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if stop != nil {
+			// Wait for the previous pattern to release the pin.
+			close(stop)
+			<-done
+			stop = nil
+		}
+		if level == None {
+			return pin.Write(embd.Low)
+		}
+
+		beeps, length := int(level), Beep
+		if level == Error {
+			length *= 3
+		}
+		stop, done = make(chan struct{}), make(chan struct{})
+		go beep(pin, beeps, length, stop, done)
+		return nil
+	})
+}
+
+func beep(pin embd.DigitalPin, beeps int, length time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	defer pin.Write(embd.Low)
+	for i := 0; i < beeps; i++ {
+		pin.Write(embd.High)
+		select {
+		case <-time.After(length):
+		case <-stop:
+			return
+		}
+		pin.Write(embd.Low)
+		select {
+		case <-time.After(Beep):
+		case <-stop:
+			return
+		}
+	}
+}
+
+// NewRegion returns an indicator writing messages to a region of a character
+// display, prefixed with "!" for warnings and "E:" for errors.
+func NewRegion(r *characterdisplay.Region) Indicator {
+	return Func(func(level Level, msg string) error {
+		switch level {
+		case Warn:
+			msg = "!" + msg
+		case Error:
+			msg = "E:" + msg
+		case None:
+			return r.Clear()
+		}
+		return r.Write(strings.Replace(msg, "\n", " ", -1))
+	})
+}
@@ -0,0 +1,60 @@
+package indicator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+type mockPin struct {
+	embd.DigitalPin
+	val int
+}
+
+func (p *mockPin) Write(val int) error {
+	p.val = val
+	return nil
+}
+
+func TestRGB(t *testing.T) {
+	r, g, b := &mockPin{}, &mockPin{}, &mockPin{}
+	ind := NewRGB(r, g, b)
+	var tests = []struct {
+		level   Level
+		r, g, b int
+	}{
+		{Info, embd.Low, embd.High, embd.Low},
+		{Warn, embd.High, embd.High, embd.Low},
+		{Error, embd.High, embd.Low, embd.Low},
+		{None, embd.Low, embd.Low, embd.Low},
+	}
+	for _, test := range tests {
+		if err := Show(ind, test.level, "msg"); err != nil {
+			t.Fatal(err)
+		}
+		if r.val != test.r || g.val != test.g || b.val != test.b {
+			t.Errorf("Level %v: got (%v, %v, %v), want (%v, %v, %v)", test.level, r.val, g.val, b.val, test.r, test.g, test.b)
+		}
+	}
+}
+
+func TestMulti(t *testing.T) {
+	var levels []Level
+	failing := Func(func(level Level, msg string) error {
+		return errors.New("failed")
+	})
+	recording := Func(func(level Level, msg string) error {
+		levels = append(levels, level)
+		return nil
+	})
+	ind := Multi(failing, recording)
+	if err := ind.Warn("low battery"); err == nil {
+		t.Error("Warn: did not get error")
+	}
+	ind.Off()
+	if expected := []Level{Warn, None}; !reflect.DeepEqual(levels, expected) {
+		t.Errorf("Levels: got %v, want %v", levels, expected)
+	}
+}
@@ -0,0 +1,226 @@
+// Package wiegand allows interfacing with Wiegand badge readers and keypads.
+package wiegand
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+)
+
+const (
+	// Bits are sent every 1 to 2ms, so a frame is complete once the lines
+	// stay quiet for longer.
+	frameTimeout = 25 * time.Millisecond
+
+	codesBuffer = 16
+)
+
+// Code is a badge or key read from the reader.
+type Code struct {
+	// Bits is the length of the frame: 26 or 34 for badges, 4 or 8 for
+	// keys.
+	Bits int
+
+	// Facility and Card are set for badges.
+	Facility, Card uint32
+
+	// Key is set for keypad presses: '0' to '9', '*' or '#'.
+	Key byte
+}
+
+func (c Code) String() string {
+	if c.Key != 0 {
+		return fmt.Sprintf("key %c", c.Key)
+	}
+	return fmt.Sprintf("card %v:%v", c.Facility, c.Card)
+}
+
+// Wiegand represents a Wiegand reader. The reader pulls the D0 line low to
+// send a 0 and the D1 line low to send a 1. The lines are usually 5V, so
+// they need a level shifter.
+type Wiegand struct {
+	D0, D1 embd.DigitalPin
+
+	// Timeout is the silence after which a frame is complete.
+	Timeout time.Duration
+	// Clock times the frames, clock.Real by default.
+	Clock clock.Clock
+
+	mu     sync.Mutex
+	bits   []byte
+	timer  clock.Timer
+	codes  chan Code
+	closed bool
+}
+
+// New creates a new Wiegand reader on the D0 and D1 pins.
+func New(d0, d1 embd.DigitalPin) *Wiegand {
+	return &Wiegand{D0: d0, D1: d1, Timeout: frameTimeout, codes: make(chan Code, codesBuffer)}
+}
+
+// Codes returns the channel receiving the codes read, once Run is called.
+// It is closed by Close.
+func (d *Wiegand) Codes() <-chan Code {
+	return d.codes
+}
+
+// Run starts watching the lines.
+func (d *Wiegand) Run() error {
+	for i, pin := range []embd.DigitalPin{d.D0, d.D1} {
+		bit := byte(i)
+		if err := pin.SetDirection(embd.In); err != nil {
+			return err
+		}
+		if err := pin.Watch(embd.EdgeFalling, func(embd.DigitalPin) {
+			d.bit(bit)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Wiegand) bit(b byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+	d.bits = append(d.bits, b)
+	if d.timer == nil {
+		d.timer = clock.Or(d.Clock).AfterFunc(d.Timeout, d.flush)
+	} else {
+		d.timer.Reset(d.Timeout)
+	}
+}
+
+func (d *Wiegand) flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed || len(d.bits) == 0 {
+		return
+	}
+	bits := d.bits
+	d.bits = nil
+
+	code, err := decode(bits)
+	if err != nil {
+		glog.Warningf("wiegand: %v", err)
+		return
+	}
+	glog.V(1).Infof("wiegand: read %v", code)
+
+	select {
+	case d.codes <- code:
+	default:
+		glog.Warningf("wiegand: dropping %v, codes are not being read", code)
+	}
+}
+
+// ParityError is returned for frames whose parity bits do not match, which
+// happens when bits are lost.
+type ParityError struct {
+	Bits []byte
+}
+
+func (e *ParityError) Error() string {
+	return fmt.Sprintf("parity error in %v bit frame %v", len(e.Bits), e.Bits)
+}
+
+func value(bits []byte) uint32 {
+	var v uint32
+	for _, b := range bits {
+		v = v<<1 | uint32(b)
+	}
+	return v
+}
+
+func ones(bits []byte) int {
+	var n int
+	for _, b := range bits {
+		n += int(b)
+	}
+	return n
+}
+
+var keys = []byte("0123456789*#")
+
+func key(v uint32) (byte, bool) {
+	if int(v) >= len(keys) {
+		return 0, false
+	}
+	return keys[v], true
+}
+
+func decode(bits []byte) (Code, error) {
+	n := len(bits)
+	code := Code{Bits: n}
+
+	switch n {
+	case 4:
+		k, ok := key(value(bits))
+		if !ok {
+			return code, fmt.Errorf("unknown key code %v", bits)
+		}
+		code.Key = k
+		return code, nil
+	case 8:
+		// The complement of the key code followed by the code, key 1 being
+		// 0xE1.
+		hi, lo := value(bits[:4]), value(bits[4:])
+		if hi^lo != 0xF {
+			return code, &ParityError{bits}
+		}
+		k, ok := key(lo)
+		if !ok {
+			return code, fmt.Errorf("unknown key code %v", bits)
+		}
+		code.Key = k
+		return code, nil
+	case 26, 34:
+		// Even parity over the first half, odd parity over the second.
+		half := n / 2
+		if ones(bits[:half])%2 != 0 || ones(bits[half:])%2 != 1 {
+			return code, &ParityError{bits}
+		}
+		data := bits[1 : n-1]
+		facility := (n - 2) - 16
+		code.Facility = value(data[:facility])
+		code.Card = value(data[facility:])
+		return code, nil
+	default:
+		return code, fmt.Errorf("unsupported %v bit frame", n)
+	}
+}
+
+// Close stops watching both lines, even when one fails, and closes the
+// codes channel. Closing it again does nothing.
+func (d *Wiegand) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	close(d.codes)
+	d.mu.Unlock()
+
+	var errs []error
+	for _, pin := range []embd.DigitalPin{d.D0, d.D1} {
+		if err := pin.StopWatching(); err != nil {
+			glog.Errorf("wiegand: error stopping watching pin %v: %v", pin.N(), err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
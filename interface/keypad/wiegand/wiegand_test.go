@@ -0,0 +1,133 @@
+package wiegand
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+)
+
+// frame builds a 26 bit frame with valid parity.
+func frame(facility, card uint32) []byte {
+	var data []byte
+	for i := 7; i >= 0; i-- {
+		data = append(data, byte(facility>>uint(i)&1))
+	}
+	for i := 15; i >= 0; i-- {
+		data = append(data, byte(card>>uint(i)&1))
+	}
+	even := byte(ones(data[:12]) % 2)
+	odd := byte(1 - ones(data[12:])%2)
+	return append(append([]byte{even}, data...), odd)
+}
+
+func TestDecode(t *testing.T) {
+	code, err := decode(frame(123, 45678))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code.Facility != 123 || code.Card != 45678 || code.Bits != 26 {
+		t.Errorf("code = %+v; want card 123:45678", code)
+	}
+
+	bits := frame(123, 45678)
+	bits[5] ^= 1
+	if _, err := decode(bits); err == nil {
+		t.Error("no error for a corrupted frame")
+	}
+
+	tests := []struct {
+		bits []byte
+		key  byte
+	}{
+		{[]byte{0, 1, 0, 1}, '5'},
+		{[]byte{1, 0, 1, 1}, '#'},
+		{[]byte{1, 0, 1, 0, 0, 1, 0, 1}, '5'},
+		{[]byte{1, 1, 1, 0, 0, 0, 0, 1}, '1'},
+	}
+	for _, test := range tests {
+		code, err := decode(test.bits)
+		if err != nil {
+			t.Errorf("decode(%v): %v", test.bits, err)
+			continue
+		}
+		if code.Key != test.key {
+			t.Errorf("decode(%v) = %v; want key %c", test.bits, code, test.key)
+		}
+	}
+}
+
+type fakePin struct {
+	embd.DigitalPin
+	handler func(embd.DigitalPin)
+	stopErr error
+	stops   int
+}
+
+func (p *fakePin) N() int                            { return 0 }
+func (p *fakePin) SetDirection(embd.Direction) error { return nil }
+
+func (p *fakePin) StopWatching() error {
+	p.stops++
+	return p.stopErr
+}
+
+func (p *fakePin) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
+	p.handler = handler
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	d0, d1 := &fakePin{}, &fakePin{}
+	d := New(d0, d1)
+	d.Timeout = time.Millisecond
+	if err := d.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range frame(1, 2) {
+		if b == 0 {
+			d0.handler(d0)
+		} else {
+			d1.handler(d1)
+		}
+	}
+	select {
+	case code := <-d.Codes():
+		if code.Facility != 1 || code.Card != 2 {
+			t.Errorf("code = %v; want card 1:2", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no code read")
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-d.Codes(); ok {
+		t.Error("codes channel not closed")
+	}
+}
+
+func TestClose(t *testing.T) {
+	errFailed := errors.New("failed")
+	d0, d1 := &fakePin{stopErr: errFailed}, &fakePin{}
+	d := New(d0, d1)
+	if err := d.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Close(); !errors.Is(err, errFailed) {
+		t.Errorf("Close() = %v; want %v", err, errFailed)
+	}
+	if d1.stops != 1 {
+		t.Errorf("D1 stopped watching %v times; want once after D0 failed", d1.stops)
+	}
+	if err := d.Close(); err != nil {
+		t.Errorf("second Close() = %v; want nil", err)
+	}
+	if d0.stops != 1 || d1.stops != 1 {
+		t.Errorf("lines stopped watching %v and %v times; want once", d0.stops, d1.stops)
+	}
+}
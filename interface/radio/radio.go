@@ -0,0 +1,52 @@
+/*
+Package radio defines the datagram interface shared by the packet radio
+drivers, so that applications can swap radio backends without changes:
+
+	var r radio.Radio = xbee.New(port)
+
+	if err := r.Send(gateway, []byte("t=21.5")); err != nil {
+		panic(err)
+	}
+	for {
+		d, err := r.Receive()
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("%v from %v (%v dBm)\n", d.Payload, d.From, d.RSSI)
+	}
+*/
+package radio
+
+import "fmt"
+
+// Addr is the address of a radio. Radios with shorter addresses use the low
+// order bits.
+type Addr uint64
+
+func (a Addr) String() string {
+	return fmt.Sprintf("%016X", uint64(a))
+}
+
+// Datagram is a received packet.
+type Datagram struct {
+	From    Addr
+	Payload []byte
+
+	// RSSI is the received signal strength in dBm, 0 when unknown.
+	RSSI int
+}
+
+// A Radio sends and receives datagrams.
+type Radio interface {
+	// Send sends a datagram to an address. Radios with acknowledgments
+	// return an error when the datagram was not delivered.
+	Send(to Addr, payload []byte) error
+
+	// Receive waits for the next datagram.
+	Receive() (*Datagram, error)
+
+	// MaxPayload is the largest payload a datagram can hold.
+	MaxPayload() int
+
+	Close() error
+}
@@ -0,0 +1,89 @@
+// PPM sum signal decoding.
+
+package rc
+
+import (
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+)
+
+const (
+	// The channels are sent as pulses 1 to 2ms apart, separated by a gap
+	// of at least 2.7ms between frames.
+	ppmMin     = 1000 * time.Microsecond
+	ppmMax     = 2000 * time.Microsecond
+	ppmSync    = 2700 * time.Microsecond
+	ppmGlitch  = 500 * time.Microsecond
+	ppmMinChan = 4
+	ppmMaxChan = 16
+)
+
+// PPM decodes the PPM sum signal of a receiver on a digital pin. The pulses
+// are timed by watching the pin, which suits the 50Hz frames of PPM.
+type PPM struct {
+	Pin embd.DigitalPin
+
+	receiver
+
+	// The edges are handled by the interrupt goroutine only.
+	lastEdge time.Time
+	synced   bool
+	widths   []time.Duration
+}
+
+// NewPPM creates a new PPM decoder on the pin.
+func NewPPM(pin embd.DigitalPin) *PPM {
+	return &PPM{Pin: pin, receiver: newReceiver("ppm")}
+}
+
+// Run starts watching the pin.
+func (p *PPM) Run() error {
+	if err := p.Pin.SetDirection(embd.In); err != nil {
+		return err
+	}
+	// The channels are the same between rising and between falling edges,
+	// so the polarity of the signal does not matter.
+	return p.Pin.Watch(embd.EdgeRising, func(embd.DigitalPin) {
+		p.edge(clock.Or(p.Clock).Now())
+	})
+}
+
+func (p *PPM) edge(t time.Time) {
+	d := t.Sub(p.lastEdge)
+	first := p.lastEdge.IsZero()
+	p.lastEdge = t
+	if first {
+		return
+	}
+
+	switch {
+	case d >= ppmSync:
+		if p.synced && len(p.widths) >= ppmMinChan {
+			p.publish(p.frame(t))
+		}
+		p.synced = true
+		p.widths = p.widths[:0]
+	case !p.synced:
+	case d < ppmGlitch || len(p.widths) == ppmMaxChan:
+		// Drop the frame on glitches, until the next gap.
+		p.synced = false
+	default:
+		p.widths = append(p.widths, d)
+	}
+}
+
+func (p *PPM) frame(t time.Time) Frame {
+	f := Frame{Time: t, Channels: make([]float64, len(p.widths))}
+	for i, w := range p.widths {
+		f.Channels[i] = normalize(float64(w), float64(ppmMin), float64(ppmMax))
+	}
+	return f
+}
+
+// Close stops watching the pin and closes the frames channel.
+func (p *PPM) Close() error {
+	p.close()
+	return p.Pin.StopWatching()
+}
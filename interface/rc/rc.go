@@ -0,0 +1,171 @@
+/*
+Package rc decodes the signals of RC receivers, to drive rovers and drones.
+
+A PPM receiver sends all its channels as a sum signal on a single pin:
+
+	ppm := rc.NewPPM(pin)
+	if err := ppm.Run(); err != nil {
+		panic(err)
+	}
+	defer ppm.Close()
+
+	for f := range ppm.Frames() {
+		if f.Failsafe {
+			stop()
+			continue
+		}
+		steer(f.Channels[0])
+	}
+
+An SBUS receiver sends frames on an inverted 100000 baud 8E2 serial line,
+which needs an inverter (or a UART supporting inversion) in front of the
+serial port:
+
+	sbus := rc.NewSBUS(port)
+	sbus.Run()
+	defer sbus.Close()
+
+The channel values are normalized from -1 to 1, with 0 at the center of the
+sticks.
+*/
+package rc
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/clock"
+)
+
+const (
+	// Receivers send frames every 7 to 30ms, so a receiver sending none
+	// for longer has lost the signal or stopped in failsafe.
+	failsafeTimeout = 250 * time.Millisecond
+
+	framesBuffer = 4
+)
+
+// Frame is the state of the channels of a receiver.
+type Frame struct {
+	Time time.Time
+
+	// Channels are the values of the channels, from -1 to 1.
+	Channels []float64
+
+	// Failsafe is set when the receiver has lost the signal of the
+	// transmitter: either it reports it, or it stopped sending frames. The
+	// channels are then the last ones received.
+	Failsafe bool
+}
+
+func (f Frame) String() string {
+	if f.Failsafe {
+		return "failsafe"
+	}
+	return fmt.Sprintf("%.2f", f.Channels)
+}
+
+// normalize maps v from [min, max] to [-1, 1].
+func normalize(v, min, max float64) float64 {
+	n := 2*(v-min)/(max-min) - 1
+	switch {
+	case n < -1:
+		return -1
+	case n > 1:
+		return 1
+	}
+	return n
+}
+
+// receiver publishes the frames of a receiver, and a failsafe frame when
+// they stop for timeout.
+type receiver struct {
+	// Clock times the frames and the failsafe, clock.Real by default. Set
+	// it before Run.
+	Clock clock.Clock
+
+	name    string
+	timeout time.Duration
+
+	mu       sync.Mutex
+	last     Frame
+	watchdog clock.Timer
+	frames   chan Frame
+	closed   bool
+}
+
+func newReceiver(name string) receiver {
+	return receiver{name: name, timeout: failsafeTimeout, frames: make(chan Frame, framesBuffer)}
+}
+
+// Frames returns the channel receiving the frames, once Run is called. It
+// is closed by Close.
+func (r *receiver) Frames() <-chan Frame {
+	return r.frames
+}
+
+// Frame returns the last frame received, which is a failsafe frame when
+// none was received recently.
+func (r *receiver) Frame() Frame {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := r.last
+	if clock.Or(r.Clock).Now().Sub(f.Time) > r.timeout {
+		f.Failsafe = true
+	}
+	return f
+}
+
+func (r *receiver) publish(f Frame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+	if f.Failsafe && !r.last.Failsafe {
+		glog.Warningf("%v: failsafe", r.name)
+	}
+	r.last = f
+	if r.watchdog == nil {
+		r.watchdog = clock.Or(r.Clock).AfterFunc(r.timeout, r.lost)
+	} else {
+		r.watchdog.Reset(r.timeout)
+	}
+
+	select {
+	case r.frames <- f:
+	default:
+		glog.Warningf("%v: dropping frame, frames are not being read", r.name)
+	}
+}
+
+// lost publishes a failsafe frame when the frames stop.
+func (r *receiver) lost() {
+	r.mu.Lock()
+	f := r.last
+	r.mu.Unlock()
+
+	if f.Failsafe {
+		return
+	}
+	f.Time, f.Failsafe = clock.Or(r.Clock).Now(), true
+	r.publish(f)
+}
+
+func (r *receiver) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+	r.closed = true
+	if r.watchdog != nil {
+		r.watchdog.Stop()
+	}
+	close(r.frames)
+}
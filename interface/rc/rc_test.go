@@ -0,0 +1,157 @@
+package rc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
+
+func TestPPM(t *testing.T) {
+	p := NewPPM(nil)
+	now := time.Unix(0, 0)
+	edge := func(d time.Duration) {
+		now = now.Add(d)
+		p.edge(now)
+	}
+	frame := func(widths ...time.Duration) {
+		for _, w := range widths {
+			edge(w)
+		}
+		edge(10 * time.Millisecond)
+	}
+
+	// The first frame is only used to synchronize.
+	frame(1500*time.Microsecond, 1500*time.Microsecond, 1500*time.Microsecond, 1500*time.Microsecond)
+	edge(10 * time.Millisecond)
+	frame(1000*time.Microsecond, 1500*time.Microsecond, 2000*time.Microsecond, 1250*time.Microsecond, 2200*time.Microsecond)
+	// A glitch drops the frame.
+	frame(1500*time.Microsecond, 100*time.Microsecond, 1500*time.Microsecond, 1500*time.Microsecond, 1500*time.Microsecond)
+
+	if n := len(p.Frames()); n != 1 {
+		t.Fatalf("got %v frames, want 1", n)
+	}
+	f := <-p.Frames()
+	want := []float64{-1, 0, 1, -0.5, 1}
+	if len(f.Channels) != len(want) {
+		t.Fatalf("got channels %v, want %v", f.Channels, want)
+	}
+	for i := range want {
+		if !near(f.Channels[i], want[i]) {
+			t.Errorf("got channels %v, want %v", f.Channels, want)
+			break
+		}
+	}
+	p.close()
+}
+
+// sbusFrame encodes the channel values in an SBUS frame.
+func sbusFrame(values []uint16, flags byte) []byte {
+	frame := make([]byte, SBUSFrameLen)
+	frame[0] = sbusHeader
+	for i, v := range values {
+		for b := 0; b < 11; b++ {
+			if v&(1<<uint(b)) != 0 {
+				bit := i*11 + b
+				frame[1+bit/8] |= 1 << uint(bit%8)
+			}
+		}
+	}
+	frame[SBUSFrameLen-2] = flags
+	return frame
+}
+
+func TestDecodeSBUS(t *testing.T) {
+	values := make([]uint16, sbusChannels)
+	for i := range values {
+		values[i] = 992
+	}
+	values[0], values[1], values[15] = 172, 1811, 1811
+
+	f, err := DecodeSBUS(sbusFrame(values, 0x01))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Failsafe {
+		t.Error("got failsafe, want none")
+	}
+	for i, want := range map[int]float64{0: -1, 1: 1, 2: 0, 15: 1, 16: 1, 17: -1} {
+		if !near(f.Channels[i], want) {
+			t.Errorf("channel %v: got %v, want %v", i, f.Channels[i], want)
+		}
+	}
+
+	if f, err = DecodeSBUS(sbusFrame(values, sbusFailsafe|sbusFrameLost)); err != nil {
+		t.Fatal(err)
+	}
+	if !f.Failsafe {
+		t.Error("got no failsafe, want failsafe")
+	}
+
+	bad := sbusFrame(values, 0)
+	bad[SBUSFrameLen-1] = 0x55
+	if _, err := DecodeSBUS(bad); err == nil {
+		t.Error("no error for an invalid footer")
+	}
+}
+
+func TestSBUSRead(t *testing.T) {
+	values := make([]uint16, sbusChannels)
+	for i := range values {
+		values[i] = 1811
+	}
+	// Garbage, including a header byte, before the frames.
+	var data []byte
+	data = append(data, 0x00, sbusHeader, 0x12)
+	data = append(data, sbusFrame(values, 0)...)
+	data = append(data, sbusFrame(values, sbusFailsafe)...)
+
+	s := NewSBUS(nil)
+	if err := s.read(bufio.NewReader(bytes.NewReader(data))); err != io.EOF {
+		t.Fatalf("read: got %v, want EOF", err)
+	}
+	if n := len(s.Frames()); n != 2 {
+		t.Fatalf("got %v frames, want 2", n)
+	}
+	if f := <-s.Frames(); f.Failsafe || !near(f.Channels[3], 1) {
+		t.Errorf("first frame: got %v", f)
+	}
+	if f := <-s.Frames(); !f.Failsafe {
+		t.Errorf("second frame: got %v, want failsafe", f)
+	}
+	s.close()
+}
+
+func TestFailsafeTimeout(t *testing.T) {
+	r := newReceiver("test")
+	r.timeout = 20 * time.Millisecond
+
+	if f := r.Frame(); !f.Failsafe {
+		t.Error("got no failsafe before any frame")
+	}
+
+	r.publish(Frame{Time: time.Now(), Channels: []float64{0.5}})
+	<-r.Frames()
+	if f := r.Frame(); f.Failsafe {
+		t.Error("got failsafe after a frame")
+	}
+
+	select {
+	case f := <-r.Frames():
+		if !f.Failsafe || f.Channels[0] != 0.5 {
+			t.Errorf("got %v, want failsafe with the last channels", f)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no failsafe frame after the timeout")
+	}
+	if f := r.Frame(); !f.Failsafe {
+		t.Error("got no failsafe after the timeout")
+	}
+	r.close()
+}
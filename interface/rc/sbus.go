@@ -0,0 +1,128 @@
+// SBUS frame decoding.
+
+package rc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/clock"
+)
+
+const (
+	// SBUSFrameLen is the length of an SBUS frame: a header, 16 channels
+	// of 11 bits, a flags byte and a footer.
+	SBUSFrameLen = 25
+
+	sbusHeader   = 0x0f
+	sbusChannels = 16
+
+	sbusFrameLost = 1 << 2
+	sbusFailsafe  = 1 << 3
+
+	// The channel values of the common transmitters go from 172 to 1811.
+	sbusMin = 172
+	sbusMax = 1811
+)
+
+// SBUS decodes the frames of an SBUS receiver read from a serial port.
+type SBUS struct {
+	r io.Reader
+
+	receiver
+}
+
+// NewSBUS creates a new SBUS decoder reading r.
+func NewSBUS(r io.Reader) *SBUS {
+	return &SBUS{r: r, receiver: newReceiver("sbus")}
+}
+
+// Run starts reading the frames in the background.
+func (s *SBUS) Run() {
+	go func() {
+		if err := s.read(bufio.NewReader(s.r)); err != nil && err != io.EOF {
+			glog.Errorf("sbus: %v", err)
+		}
+	}()
+}
+
+func (s *SBUS) read(r *bufio.Reader) error {
+	buf := make([]byte, SBUSFrameLen)
+	for {
+		// Synchronize on the header, and look for the next one when the
+		// frame is invalid.
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != sbusHeader {
+			continue
+		}
+		frame, err := r.Peek(SBUSFrameLen - 1)
+		if err != nil {
+			return err
+		}
+		buf[0] = b
+		copy(buf[1:], frame)
+		f, err := DecodeSBUS(buf)
+		if err != nil {
+			glog.V(2).Infof("sbus: %v", err)
+			continue
+		}
+		r.Discard(len(frame))
+		f.Time = clock.Or(s.Clock).Now()
+		s.publish(f)
+	}
+}
+
+// DecodeSBUS decodes an SBUS frame. The channels are the 16 proportional
+// channels followed by the 2 digital channels.
+func DecodeSBUS(frame []byte) (Frame, error) {
+	if len(frame) != SBUSFrameLen || frame[0] != sbusHeader {
+		return Frame{}, fmt.Errorf("invalid frame % x", frame)
+	}
+	// The footer is 0 for SBUS, and carries a counter in the high bits
+	// for SBUS2.
+	if footer := frame[SBUSFrameLen-1]; footer != 0 && footer&0x0f != 0x04 {
+		return Frame{}, fmt.Errorf("invalid frame footer %#x", footer)
+	}
+
+	f := Frame{Channels: make([]float64, sbusChannels+2)}
+	data := frame[1 : SBUSFrameLen-2]
+	for i := 0; i < sbusChannels; i++ {
+		// The channels are packed from the least significant bits.
+		bit := i * 11
+		v := uint32(data[bit/8]) | uint32(data[bit/8+1])<<8
+		if bit/8+2 < len(data) {
+			v |= uint32(data[bit/8+2]) << 16
+		}
+		v = v >> uint(bit%8) & 0x7ff
+		f.Channels[i] = normalize(float64(v), sbusMin, sbusMax)
+	}
+
+	flags := frame[SBUSFrameLen-2]
+	for i := 0; i < 2; i++ {
+		f.Channels[sbusChannels+i] = -1
+		if flags&(1<<uint(i)) != 0 {
+			f.Channels[sbusChannels+i] = 1
+		}
+	}
+	if flags&sbusFrameLost != 0 {
+		glog.V(2).Infof("sbus: receiver lost a frame")
+	}
+	f.Failsafe = flags&sbusFailsafe != 0
+
+	return f, nil
+}
+
+// Close closes the frames channel, and the serial port when it is an
+// io.Closer to stop the reading.
+func (s *SBUS) Close() error {
+	s.close()
+	if c, ok := s.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
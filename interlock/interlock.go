@@ -0,0 +1,393 @@
+/*
+Package interlock stops all the actuators of a machine from an emergency stop
+input.
+
+The actuators register with a controller bound to the e-stop pin. Tripping
+the e-stop, or a software trip, forces all of them to their safe state, and
+they stay there until the e-stop is released and the controller explicitly
+reset:
+
+	c := interlock.New(estop)
+	c.Indicator = indicator.Multi(led, indicator.NewRegion(lcd.Region(0, 1, 16)))
+	c.Register("spindle", interlock.ESC(spindle))
+	pump, _ := c.Relay("pump", pumpPin, embd.Low)
+	if err := c.Run(); err != nil {
+		panic(err)
+	}
+	defer c.Close()
+
+	for ev := range c.Events() {
+		log.Print(ev)
+	}
+
+The e-stop is expected to be a normally closed contact pulling the pin low,
+so that pressing it and a broken wire both trip the controller.
+*/
+package interlock
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/health"
+	"github.com/kidoman/embd/interface/indicator"
+	"github.com/kidoman/embd/motion/esc"
+	"github.com/kidoman/embd/motion/servo"
+)
+
+const (
+	// DefaultPoll is how often the e-stop pin is read, in case an edge is
+	// missed or the pin cannot be watched.
+	DefaultPoll = 50 * time.Millisecond
+
+	eventBuffer = 8
+)
+
+// ErrTripped is returned when commanding an actuator while the controller
+// is tripped.
+var ErrTripped = errors.New("interlock: tripped")
+
+// An Actuator is forced to its safe state when the interlock trips.
+type Actuator interface {
+	SafeState() error
+}
+
+// ActuatorFunc adapts a function to the Actuator interface.
+type ActuatorFunc func() error
+
+// SafeState implements Actuator.
+func (f ActuatorFunc) SafeState() error {
+	return f()
+}
+
+// ESC returns the actuator disarming an ESC, which then has to be armed
+// again after a reset.
+func ESC(e *esc.ESC) Actuator {
+	return ActuatorFunc(e.Disarm)
+}
+
+// Servo returns the actuator moving a servo to a safe angle.
+func Servo(s *servo.Servo, angle int) Actuator {
+	return ActuatorFunc(func() error {
+		return s.SetAngle(angle)
+	})
+}
+
+// Output returns the actuator writing the safe value to a digital pin, like
+// the one of a relay.
+func Output(pin embd.DigitalPin, safe int) Actuator {
+	return ActuatorFunc(func() error {
+		return pin.Write(safe)
+	})
+}
+
+// Event reports a trip or a reset of the controller.
+type Event struct {
+	Time    time.Time
+	Tripped bool
+	// Reason is why the controller tripped.
+	Reason string
+	// Errors are the errors of the actuators which could not be forced to
+	// their safe state, by name.
+	Errors map[string]error
+}
+
+func (e Event) String() string {
+	if !e.Tripped {
+		return "interlock reset"
+	}
+	s := "interlock tripped: " + e.Reason
+	if len(e.Errors) > 0 {
+		s += fmt.Sprintf(" (%v actuators failed)", len(e.Errors))
+	}
+	return s
+}
+
+// Controller is the safety controller the actuators register with.
+type Controller struct {
+	// EStop is the e-stop pin, nil for software trips only.
+	EStop embd.DigitalPin
+	// TripLevel is the level of the e-stop pin when it is pressed, High by
+	// default.
+	TripLevel int
+	// Poll is how often the e-stop pin is read.
+	Poll time.Duration
+	// Indicator, when set, shows the trips.
+	Indicator indicator.Indicator
+	// Clock times the polling and the events, clock.Real by default.
+	Clock clock.Clock
+	// HealthName is the name the reads of the e-stop are reported under to
+	// the health registry, "interlock" when empty.
+	HealthName string
+
+	mu        sync.Mutex
+	actuators map[string]Actuator
+	tripped   bool
+	reason    string
+	events    chan Event
+
+	quit, done chan struct{}
+}
+
+// New creates a new controller bound to the e-stop pin.
+func New(estop embd.DigitalPin) *Controller {
+	return &Controller{
+		EStop:     estop,
+		TripLevel: embd.High,
+		Poll:      DefaultPoll,
+		actuators: map[string]Actuator{},
+		events:    make(chan Event, eventBuffer),
+	}
+}
+
+// Register registers an actuator under a name. An actuator registered while
+// the controller is tripped is forced to its safe state right away.
+func (c *Controller) Register(name string, a Actuator) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.actuators[name]; ok {
+		return fmt.Errorf("interlock: actuator %v already registered", name)
+	}
+	c.actuators[name] = a
+	if c.tripped {
+		return a.SafeState()
+	}
+	return nil
+}
+
+// Unregister removes the actuator registered under name.
+func (c *Controller) Unregister(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.actuators, name)
+}
+
+// Actuators returns the sorted names of the registered actuators.
+func (c *Controller) Actuators() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.actuators))
+	for name := range c.actuators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Relay registers the digital pin of a relay, and returns the pin to drive
+// it with: writing it fails with ErrTripped while the controller is tripped.
+func (c *Controller) Relay(name string, pin embd.DigitalPin, safe int) (embd.DigitalPin, error) {
+	if err := c.Register(name, Output(pin, safe)); err != nil {
+		return nil, err
+	}
+	return &guardedPin{DigitalPin: pin, c: c}, nil
+}
+
+// Check returns ErrTripped while the controller is tripped, for the drivers
+// of actuators to check before each command.
+func (c *Controller) Check() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tripped {
+		return ErrTripped
+	}
+	return nil
+}
+
+// Tripped returns whether the controller is tripped, and why.
+func (c *Controller) Tripped() (bool, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.tripped, c.reason
+}
+
+// Events returns the channel the trips and resets are reported on. Events
+// are dropped when the channel is full.
+func (c *Controller) Events() <-chan Event {
+	return c.events
+}
+
+func (c *Controller) publish(ev Event) {
+	select {
+	case c.events <- ev:
+	default:
+		glog.Warningf("interlock: event channel full, dropping %v", ev)
+	}
+}
+
+// Trip forces all the actuators to their safe state. Tripping a tripped
+// controller forces them again, but is reported once.
+func (c *Controller) Trip(reason string) {
+	c.mu.Lock()
+	first := !c.tripped
+	if first {
+		c.tripped, c.reason = true, reason
+	}
+	errs := map[string]error{}
+	for name, a := range c.actuators {
+		if err := a.SafeState(); err != nil {
+			glog.Errorf("interlock: forcing %v to its safe state: %v", name, err)
+			errs[name] = err
+		}
+	}
+	c.mu.Unlock()
+
+	if !first {
+		return
+	}
+	glog.Warningf("interlock: tripped: %v", reason)
+	ev := Event{Time: clock.Or(c.Clock).Now(), Tripped: true, Reason: reason}
+	if len(errs) > 0 {
+		ev.Errors = errs
+	}
+	if c.Indicator != nil {
+		if err := c.Indicator.Error("E-STOP: " + reason); err != nil {
+			glog.Errorf("interlock: %v", err)
+		}
+	}
+	c.publish(ev)
+}
+
+// pressed returns whether the e-stop is pressed.
+func (c *Controller) pressed() (bool, error) {
+	if c.EStop == nil {
+		return false, nil
+	}
+	v, err := c.EStop.Read()
+	if err != nil {
+		return false, err
+	}
+	return v == c.TripLevel, nil
+}
+
+// healthName returns the name of the controller in the health registry.
+func (c *Controller) healthName() string {
+	if c.HealthName == "" {
+		return "interlock"
+	}
+	return c.HealthName
+}
+
+// check trips the controller when the e-stop is pressed, or cannot be read,
+// and reports the read to the health registry.
+func (c *Controller) check() {
+	pressed, err := c.pressed()
+	health.Observe(c.healthName(), err)
+	switch {
+	case err != nil:
+		c.Trip(fmt.Sprintf("reading e-stop: %v", err))
+	case pressed:
+		c.Trip("e-stop pressed")
+	}
+}
+
+// Reset clears a trip once the e-stop is released. The actuators stay in
+// their safe state until commanded again, and ESCs have to be armed again.
+func (c *Controller) Reset() error {
+	pressed, err := c.pressed()
+	if err != nil {
+		return err
+	}
+	if pressed {
+		return errors.New("interlock: e-stop still pressed")
+	}
+
+	c.mu.Lock()
+	if !c.tripped {
+		c.mu.Unlock()
+		return nil
+	}
+	c.tripped, c.reason = false, ""
+	c.mu.Unlock()
+
+	glog.Infof("interlock: reset")
+	if c.Indicator != nil {
+		if err := c.Indicator.Off(); err != nil {
+			glog.Errorf("interlock: %v", err)
+		}
+	}
+	c.publish(Event{Time: clock.Or(c.Clock).Now()})
+	return nil
+}
+
+// Run starts monitoring the e-stop pin. It is checked once before Run
+// returns, so a pressed e-stop trips the controller before the machine
+// starts.
+func (c *Controller) Run() error {
+	if c.EStop != nil {
+		if err := c.EStop.SetDirection(embd.In); err != nil {
+			return err
+		}
+		if err := c.EStop.Watch(embd.EdgeBoth, func(embd.DigitalPin) {
+			c.check()
+		}); err != nil {
+			glog.V(1).Infof("interlock: polling the e-stop, it cannot be watched: %v", err)
+		}
+	}
+	c.check()
+
+	c.quit = make(chan struct{})
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+
+		ticker := clock.Or(c.Clock).NewTicker(c.Poll)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				c.check()
+			case <-c.quit:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops monitoring the e-stop, and forces the actuators to their safe
+// state as the machine is no longer protected.
+func (c *Controller) Close() error {
+	if c.quit != nil {
+		close(c.quit)
+		<-c.done
+		c.quit = nil
+		if c.EStop != nil {
+			c.EStop.StopWatching()
+		}
+		health.Default.Remove(c.healthName())
+	}
+	c.Trip("interlock closed")
+	return nil
+}
+
+type guardedPin struct {
+	embd.DigitalPin
+
+	c *Controller
+}
+
+// Write holds the lock of the controller, so a trip cannot happen between
+// the check and the write.
+func (p *guardedPin) Write(val int) error {
+	p.c.mu.Lock()
+	defer p.c.mu.Unlock()
+
+	if p.c.tripped {
+		return ErrTripped
+	}
+	return p.DigitalPin.Write(val)
+}
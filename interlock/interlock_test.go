@@ -0,0 +1,169 @@
+package interlock
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/health"
+	"github.com/kidoman/embd/interface/indicator"
+)
+
+type fakePin struct {
+	embd.DigitalPin
+
+	mu      sync.Mutex
+	val     int
+	err     error
+	handler func(embd.DigitalPin)
+}
+
+func (p *fakePin) SetDirection(embd.Direction) error { return nil }
+func (p *fakePin) StopWatching() error               { return nil }
+
+func (p *fakePin) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
+	p.handler = handler
+	return nil
+}
+
+func (p *fakePin) Read() (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.val, p.err
+}
+
+func (p *fakePin) Write(val int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.val = val
+	return nil
+}
+
+func (p *fakePin) set(val int) {
+	p.Write(val)
+	if p.handler != nil {
+		p.handler(p)
+	}
+}
+
+func TestTripAndReset(t *testing.T) {
+	estop := &fakePin{}
+	c := New(estop)
+	var shown []string
+	c.Indicator = indicator.Func(func(level indicator.Level, msg string) error {
+		shown = append(shown, level.String()+" "+msg)
+		return nil
+	})
+
+	var motorStops int32
+	if err := c.Register("motor", ActuatorFunc(func() error {
+		atomic.AddInt32(&motorStops, 1)
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+	relayPin := &fakePin{}
+	relay, err := c.Relay("pump", relayPin, embd.Low)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Register("pump", ActuatorFunc(nil)); err == nil {
+		t.Error("no error registering an actuator twice")
+	}
+
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := relay.Write(embd.High); err != nil {
+		t.Fatal(err)
+	}
+
+	estop.set(embd.High)
+	ev := <-c.Events()
+	if !ev.Tripped || ev.Reason != "e-stop pressed" {
+		t.Errorf("event: got %v", ev)
+	}
+	if v, _ := relayPin.Read(); atomic.LoadInt32(&motorStops) == 0 || v != embd.Low {
+		t.Error("actuators not forced to their safe state")
+	}
+	if err := relay.Write(embd.High); err != ErrTripped {
+		t.Errorf("relay write while tripped: got %v, want ErrTripped", err)
+	}
+	if len(shown) != 1 || shown[0] != "error E-STOP: e-stop pressed" {
+		t.Errorf("indicator: got %q", shown)
+	}
+
+	if err := c.Reset(); err == nil {
+		t.Error("no error resetting with the e-stop pressed")
+	}
+	estop.set(embd.Low)
+	if tripped, _ := c.Tripped(); !tripped {
+		t.Error("released e-stop reset the controller")
+	}
+	if err := c.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if ev := <-c.Events(); ev.Tripped {
+		t.Errorf("event: got %v, want reset", ev)
+	}
+	if err := relay.Write(embd.High); err != nil {
+		t.Errorf("relay write after reset: %v", err)
+	}
+	if err := c.Check(); err != nil {
+		t.Errorf("Check after reset: %v", err)
+	}
+}
+
+func TestTrippedAtStart(t *testing.T) {
+	c := New(&fakePin{val: embd.High})
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if tripped, _ := c.Tripped(); !tripped {
+		t.Error("pressed e-stop did not trip the controller at start")
+	}
+	stopped := false
+	c.Register("late", ActuatorFunc(func() error {
+		stopped = true
+		return nil
+	}))
+	if !stopped {
+		t.Error("actuator registered while tripped not forced to its safe state")
+	}
+}
+
+func TestEStopReadError(t *testing.T) {
+	c := New(&fakePin{err: errors.New("gpio gone")})
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tripped, _ := c.Tripped(); !tripped {
+		t.Error("unreadable e-stop did not trip the controller")
+	}
+	if s := status("interlock"); s != health.Degraded {
+		t.Errorf("status = %v; want degraded", s)
+	}
+	c.Close()
+	if s := status("interlock"); s != -1 {
+		t.Errorf("status = %v after Close; want the controller removed", s)
+	}
+}
+
+// status returns the status of a driver in the health registry, or -1.
+func status(name string) health.Status {
+	for _, rep := range health.Default.Reports() {
+		if rep.Name == name {
+			return rep.Status
+		}
+	}
+	return -1
+}
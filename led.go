@@ -17,6 +17,40 @@ type LED interface {
 	Close() error
 }
 
+// Common LED triggers. The triggers available depend on the kernel, and are
+// listed by TriggerLED.Triggers.
+const (
+	// LEDTriggerNone leaves the LED under the control of the program.
+	LEDTriggerNone = "none"
+
+	// LEDTriggerHeartbeat blinks the LED like a heartbeat, faster as the
+	// load increases.
+	LEDTriggerHeartbeat = "heartbeat"
+
+	// LEDTriggerNetdev shows the state and activity of a network interface,
+	// configured with SetNetdevTrigger.
+	LEDTriggerNetdev = "netdev"
+)
+
+// TriggerLED is implemented by LEDs which the kernel can drive on its own,
+// like the LEDs of the sysfs LED class.
+type TriggerLED interface {
+	LED
+
+	// Trigger returns the active trigger.
+	Trigger() (string, error)
+
+	// Triggers returns the available triggers.
+	Triggers() ([]string, error)
+
+	// SetTrigger activates a trigger.
+	SetTrigger(trigger string) error
+
+	// SetTriggerParam sets a parameter of the active trigger, like the
+	// delay_on and delay_off of the timer trigger.
+	SetTriggerParam(name, value string) error
+}
+
 // LEDDriver interface interacts with the host descriptors to allow us
 // control of the LEDs.
 type LEDDriver interface {
@@ -92,3 +126,32 @@ func LEDToggle(key interface{}) error {
 
 	return led.Toggle()
 }
+
+// SetLEDTrigger activates a trigger of the LED.
+func SetLEDTrigger(key interface{}, trigger string) error {
+	led, err := NewLED(key)
+	if err != nil {
+		return err
+	}
+
+	tl, ok := led.(TriggerLED)
+	if !ok {
+		return ErrFeatureNotSupported
+	}
+	return tl.SetTrigger(trigger)
+}
+
+// SetNetdevTrigger makes the LED show the state of a network interface: on
+// while its link is up, blinking as it transmits and receives.
+func SetNetdevTrigger(led TriggerLED, device string) error {
+	if err := led.SetTrigger(LEDTriggerNetdev); err != nil {
+		return err
+	}
+	params := [][2]string{{"device_name", device}, {"link", "1"}, {"tx", "1"}, {"rx", "1"}}
+	for _, p := range params {
+		if err := led.SetTriggerParam(p[0], p[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 // LEDMap type represents a LED mapping for a host.
@@ -13,20 +14,26 @@ type LEDMap map[string][]string
 
 type ledFactory func(string) LED
 
+// ledLister lists the names of the LEDs of the host.
+type ledLister func() ([]string, error)
+
 type ledDriver struct {
 	ledMap LEDMap
 
-	lf ledFactory
+	lf    ledFactory
+	names ledLister
 
 	initializedLEDs map[string]LED
 }
 
 // NewLEDDriver returns a LEDDriver interface which allows control
-// over the LED subsystem.
-func NewLEDDriver(ledMap LEDMap, lf ledFactory) LEDDriver {
+// over the LED subsystem. The LEDs of the host listed by names, if not nil,
+// can also be used by their name.
+func NewLEDDriver(ledMap LEDMap, lf ledFactory, names ledLister) LEDDriver {
 	return &ledDriver{
 		ledMap: ledMap,
 		lf:     lf,
+		names:  names,
 
 		initializedLEDs: map[string]LED{},
 	}
@@ -53,7 +60,22 @@ func (d *ledDriver) lookup(k interface{}) (string, error) {
 		}
 	}
 
-	return "", fmt.Errorf("led: no match found for %q", k)
+	// The other LEDs of the host can be used by their name.
+	if d.names == nil {
+		return "", fmt.Errorf("led: no match found for %q", k)
+	}
+	names, err := d.names()
+	if err != nil {
+		return "", err
+	}
+	if ks != "" && ks != "." && ks != ".." && !strings.Contains(ks, "/") {
+		for _, name := range names {
+			if name == ks {
+				return ks, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("led: no match found for %q, available LEDs: %v", k, strings.Join(names, ", "))
 }
 
 func (d *ledDriver) LED(k interface{}) (LED, error) {
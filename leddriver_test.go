@@ -0,0 +1,50 @@
+package embd
+
+import (
+	"strings"
+	"testing"
+)
+
+type fakeLED struct {
+	LED
+	id string
+}
+
+func TestLEDDriverLookup(t *testing.T) {
+	ledMap := LEDMap{"led0": {"0", "ACT"}}
+	names := func() ([]string, error) { return []string{"led0", "led1"}, nil }
+	d := NewLEDDriver(ledMap, func(id string) LED { return &fakeLED{id: id} }, names)
+
+	for _, c := range []struct {
+		key interface{}
+		id  string
+	}{
+		{0, "led0"},
+		{"ACT", "led0"},
+		{"led1", "led1"},
+	} {
+		l, err := d.LED(c.key)
+		if err != nil {
+			t.Errorf("LED(%v): %v", c.key, err)
+			continue
+		}
+		if id := l.(*fakeLED).id; id != c.id {
+			t.Errorf("LED(%v) = %v; want %v", c.key, id, c.id)
+		}
+	}
+	for _, k := range []interface{}{"led2", "", "..", "../led0", "led0/brightness"} {
+		_, err := d.LED(k)
+		if err == nil {
+			t.Errorf("LED(%q): no error", k)
+			continue
+		}
+		if !strings.Contains(err.Error(), "led0, led1") {
+			t.Errorf("LED(%q) = %v; want the available LEDs listed", k, err)
+		}
+	}
+
+	d = NewLEDDriver(ledMap, func(id string) LED { return &fakeLED{id: id} }, nil)
+	if _, err := d.LED("led1"); err == nil {
+		t.Error("LED(led1): no error without the LEDs of the host")
+	}
+}
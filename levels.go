@@ -0,0 +1,161 @@
+// Pin logic levels.
+
+package embd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// Logic levels, in millivolts.
+const (
+	Level1V8 = 1800
+	Level3V3 = 3300
+	Level5V  = 5000
+)
+
+// SetLevel sets the logic level of all the pins of the map, for the hosts
+// whose pins share one.
+func (m PinMap) SetLevel(mV int, fiveVoltTolerant bool) {
+	for _, pd := range m {
+		pd.Level, pd.FiveVoltTolerant = mV, fiveVoltTolerant
+	}
+}
+
+// LevelShiftedEnv is the environment variable listing the pins wired
+// through a level shifter, separated by commas, loaded by InitGPIO. See
+// DeclareLevelShifter.
+const LevelShiftedEnv = "EMBD_LEVEL_SHIFTED"
+
+// StrictLevelsEnv is the environment variable which makes CheckLevel fail
+// when set to a true value (like 1 or true).
+const StrictLevelsEnv = "EMBD_STRICT_LEVELS"
+
+var levelShifted = struct {
+	sync.RWMutex
+	keys []string
+}{}
+
+var strictLevels bool
+
+// DeclareLevelShifter declares that a pin is wired to its device through a
+// level shifter, so that CheckLevel accepts devices of any level on it.
+func DeclareLevelShifter(key interface{}) {
+	levelShifted.Lock()
+	defer levelShifted.Unlock()
+
+	levelShifted.keys = append(levelShifted.keys, fmt.Sprint(key))
+}
+
+func loadLevelShifters() {
+	for _, key := range strings.Split(os.Getenv(LevelShiftedEnv), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			DeclareLevelShifter(key)
+		}
+	}
+}
+
+// SetStrictLevels makes CheckLevel return an error, rather than log a
+// warning, for devices whose level does not match the pin they are on.
+func SetStrictLevels(b bool) {
+	strictLevels = b
+}
+
+// StrictLevels returns true if CheckLevel fails on level mismatches, either
+// by SetStrictLevels or by the StrictLevelsEnv environment variable.
+func StrictLevels() bool {
+	if strictLevels {
+		return true
+	}
+	b, _ := strconv.ParseBool(os.Getenv(StrictLevelsEnv))
+	return b
+}
+
+// LevelError is returned by CheckLevel when a device has a higher logic
+// level than the pin it is on.
+type LevelError struct {
+	Pin    string
+	Level  int
+	Device int
+	Owner  string
+}
+
+func (e *LevelError) Error() string {
+	return fmt.Sprintf("embd: %v needs a level shifter on pin %v: its %.1fV signal exceeds the %.1fV of the pin",
+		e.Owner, e.Pin, float64(e.Device)/1000, float64(e.Level)/1000)
+}
+
+// pinDescriber is implemented by the GPIO drivers which can describe their
+// pins.
+type pinDescriber interface {
+	PinDesc(pin interface{}) (*PinDesc, bool)
+}
+
+// PinDesc returns the descriptor of a pin, given either its key or the pin
+// itself.
+func (io *gpioDriver) PinDesc(pin interface{}) (*PinDesc, bool) {
+	for id, p := range io.initializedPins {
+		if p == pin {
+			pin = id
+			break
+		}
+	}
+	return io.pinMap.Lookup(pin, CapDigital|CapAnalog|CapPWM)
+}
+
+// DescribePin returns the descriptor of a host pin, given either its key or
+// the pin itself. It returns false for the pins of expanders and the pins
+// of hosts which cannot describe them.
+func DescribePin(pin interface{}) (*PinDesc, bool) {
+	if !gpioDriverInitialized {
+		return nil, false
+	}
+	d, ok := gpioDriverInstance.(pinDescriber)
+	if !ok {
+		return nil, false
+	}
+	return d.PinDesc(pin)
+}
+
+func isLevelShifted(pd *PinDesc) bool {
+	levelShifted.RLock()
+	defer levelShifted.RUnlock()
+
+	for _, key := range levelShifted.keys {
+		if other, ok := DescribePin(key); ok && other == pd {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckLevel checks that a device whose signals are at level millivolts can
+// be wired to the pin, which is given by key or as the pin itself. Drivers
+// of 5V devices call it when starting, with the owner as in Claim. When the
+// device level is higher than the level of the pin, and the pin is neither
+// tolerant to it nor declared level shifted (see DeclareLevelShifter), a
+// warning is logged, or a *LevelError returned in strict mode (see
+// SetStrictLevels). Pins of unknown levels are accepted.
+func CheckLevel(pin interface{}, mV int, owner string) error {
+	pd, ok := DescribePin(pin)
+	if !ok || pd.Level == 0 || mV <= pd.Level {
+		return nil
+	}
+	if pd.FiveVoltTolerant && mV <= Level5V {
+		return nil
+	}
+	if isLevelShifted(pd) {
+		return nil
+	}
+	err := &LevelError{Pin: pd.ID, Level: pd.Level, Device: mV, Owner: owner}
+	if StrictLevels() {
+		return err
+	}
+	glog.Warningf("%v. Declare the level shifter with %v=%v if there is one", err, LevelShiftedEnv, pd.ID)
+	return nil
+}
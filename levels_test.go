@@ -0,0 +1,66 @@
+package embd
+
+import "testing"
+
+func TestCheckLevel(t *testing.T) {
+	pinMap := PinMap{
+		&PinDesc{ID: "P1_1", Aliases: []string{"1"}, Caps: CapDigital, DigitalLogical: 1},
+		&PinDesc{ID: "P1_2", Aliases: []string{"2"}, Caps: CapDigital, DigitalLogical: 2},
+		&PinDesc{ID: "P1_3", Aliases: []string{"3"}, Caps: CapDigital, DigitalLogical: 3},
+	}
+	pinMap.SetLevel(Level3V3, false)
+	pinMap[1].FiveVoltTolerant = true
+	pinMap[2].Level = 0
+
+	defer func(drv GPIODriver, initialized bool) {
+		gpioDriverInstance, gpioDriverInitialized = drv, initialized
+	}(gpioDriverInstance, gpioDriverInitialized)
+	gpioDriverInstance = NewGPIODriver(pinMap, newFakeDigitalPin, nil, nil)
+	gpioDriverInitialized = true
+	defer SetStrictLevels(false)
+	SetStrictLevels(true)
+
+	pin, err := NewDigitalPin(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pd, ok := DescribePin(pin); !ok || pd.ID != "P1_1" {
+		t.Errorf("DescribePin: got %+v, %v", pd, ok)
+	}
+
+	var tests = []struct {
+		pin interface{}
+		mV  int
+		ok  bool
+	}{
+		{pin, Level5V, false},
+		{pin, Level3V3, true},
+		{pin, Level1V8, true},
+		{"P1_2", Level5V, true},
+		{"P1_3", Level5V, true},
+		{"P1_9", Level5V, true},
+	}
+	for _, test := range tests {
+		err := CheckLevel(test.pin, test.mV, "test")
+		if ok := err == nil; ok != test.ok {
+			t.Errorf("CheckLevel(%v, %v): got %v", test.pin, test.mV, err)
+		}
+		if err != nil {
+			if _, ok := err.(*LevelError); !ok {
+				t.Errorf("CheckLevel(%v, %v): got a %T", test.pin, test.mV, err)
+			}
+		}
+	}
+
+	SetStrictLevels(false)
+	if err := CheckLevel(pin, Level5V, "test"); err != nil {
+		t.Errorf("CheckLevel failed outside of strict mode: %v", err)
+	}
+	SetStrictLevels(true)
+
+	defer func(keys []string) { levelShifted.keys = keys }(levelShifted.keys)
+	DeclareLevelShifter("1")
+	if err := CheckLevel(pin, Level5V, "test"); err != nil {
+		t.Errorf("CheckLevel failed on a level shifted pin: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+// HTTP and MQTT control.
+
+package lighting
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseLevels parses comma separated levels.
+func parseLevels(s string) ([]float64, error) {
+	var levels []float64
+	for _, f := range strings.Split(s, ",") {
+		l, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil, fmt.Errorf("lighting: invalid level %q", f)
+		}
+		levels = append(levels, l)
+	}
+	return levels, nil
+}
+
+// parseCommand splits a command payload into its argument and the optional
+// fade duration following it, like "evening 2s" or "1,0.5,0".
+func parseCommand(payload string) (string, time.Duration, error) {
+	fields := strings.Fields(payload)
+	switch len(fields) {
+	case 1:
+		return fields[0], 0, nil
+	case 2:
+		d, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return "", 0, fmt.Errorf("lighting: invalid fade %q", fields[1])
+		}
+		return fields[0], d, nil
+	}
+	return "", 0, fmt.Errorf("lighting: invalid command %q", payload)
+}
+
+// HandleMessage handles a message received over MQTT on the topics below
+// Topic:
+//
+//	Topic/scene/set        "evening" or "evening 10s" recalls a scene
+//	Topic/zone/<name>/set  "0.5", "1,0.5,0" or "0.5 2s" sets a zone
+//	Topic/off/set          "" or "2s" turns all the zones off
+//
+// The optional duration is the length of the fade.
+func (c *Controller) HandleMessage(topic string, payload []byte) error {
+	rest := strings.TrimPrefix(topic, c.Topic+"/")
+	if rest == topic || !strings.HasSuffix(rest, "/set") {
+		return fmt.Errorf("lighting: unknown topic %v", topic)
+	}
+	rest = strings.TrimSuffix(rest, "/set")
+	msg := string(payload)
+
+	switch {
+	case rest == "off":
+		var d time.Duration
+		if s := strings.TrimSpace(msg); s != "" {
+			var err error
+			if d, err = time.ParseDuration(s); err != nil {
+				return fmt.Errorf("lighting: invalid fade %q", s)
+			}
+		}
+		return c.Off(d)
+	case rest == "scene":
+		scene, d, err := parseCommand(msg)
+		if err != nil {
+			return err
+		}
+		return c.Recall(scene, d)
+	case strings.HasPrefix(rest, "zone/"):
+		arg, d, err := parseCommand(msg)
+		if err != nil {
+			return err
+		}
+		levels, err := parseLevels(arg)
+		if err != nil {
+			return err
+		}
+		return c.Set(strings.TrimPrefix(rest, "zone/"), d, levels...)
+	}
+	return fmt.Errorf("lighting: unknown topic %v", topic)
+}
+
+// state is the JSON state of the controller.
+type state struct {
+	Zones  map[string][]float64 `json:"zones"`
+	Scenes []string             `json:"scenes"`
+}
+
+// ServeHTTP serves the state of the zones and the scenes as JSON on GET /,
+// and controls them with POST requests, fading over the optional fade
+// parameter:
+//
+//	POST /scene/evening?fade=10s
+//	POST /zone/shelf?fade=2s   with the levels as body, like "1,0.5,0"
+//	POST /off
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" && (r.URL.Path == "/" || r.URL.Path == "") {
+		s := state{Zones: map[string][]float64{}, Scenes: c.Scenes()}
+		for _, name := range c.Zones() {
+			s.Zones[name], _ = c.Levels(name)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var d time.Duration
+	if f := r.URL.Query().Get("fade"); f != "" {
+		var err error
+		if d, err = time.ParseDuration(f); err != nil {
+			http.Error(w, "invalid fade", http.StatusBadRequest)
+			return
+		}
+	}
+	path := strings.Trim(r.URL.Path, "/")
+	var err error
+	switch {
+	case path == "off":
+		err = c.Off(d)
+	case strings.HasPrefix(path, "scene/"):
+		err = c.Recall(strings.TrimPrefix(path, "scene/"), d)
+	case strings.HasPrefix(path, "zone/"):
+		body, rerr := ioutil.ReadAll(r.Body)
+		if rerr != nil {
+			http.Error(w, rerr.Error(), http.StatusBadRequest)
+			return
+		}
+		levels, perr := parseLevels(string(body))
+		if perr != nil {
+			http.Error(w, perr.Error(), http.StatusBadRequest)
+			return
+		}
+		err = c.Set(strings.TrimPrefix(path, "zone/"), d, levels...)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,465 @@
+/*
+Package lighting drives PWM dimmed lights as named zones, with scenes, timed
+fades and daily schedules.
+
+A zone groups the channels lit together, like the white channel of a strip
+of LEDs or the red, green and blue channels of a RGB strip. A scene sets the
+levels of several zones at once:
+
+	c := lighting.New()
+	c.AddZone("kitchen", lighting.PWM(kitchen))
+	c.AddZone("shelf", lighting.PWM(pca.AnalogChannel(0)), lighting.PWM(pca.AnalogChannel(1)), lighting.PWM(pca.AnalogChannel(2)))
+	c.AddScene("evening", lighting.Scene{"kitchen": {0.4}, "shelf": {1, 0.5, 0}})
+	c.Schedule(lighting.Daily(19, 30), "evening", 10*time.Minute)
+	c.Run()
+	defer c.Close()
+
+	c.Recall("evening", 2*time.Second)
+	http.Handle("/lights/", http.StripPrefix("/lights", c))
+
+The lights are controlled over MQTT through any client, by passing the
+messages of its subscription to HandleMessage and adapting it to the
+Publisher interface for the state to be published back.
+*/
+package lighting
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/clock"
+)
+
+const (
+	// DefaultGamma is the default gamma correction of the levels, so that
+	// fades look even to the eye.
+	DefaultGamma = 2.2
+
+	// DefaultStep is the default interval between the updates of a fade.
+	DefaultStep = 20 * time.Millisecond
+)
+
+// Channel sets the output of a light, from 0 (off) to 1 (fully on).
+type Channel func(output float64) error
+
+// Analog is a PWM output, like an embd.PWMPin or a PCA9685 channel.
+type Analog interface {
+	SetAnalog(value byte) error
+}
+
+// PWM returns the channel driving a light through the duty cycle of a PWM
+// output.
+func PWM(out Analog) Channel {
+	return func(output float64) error {
+		return out.SetAnalog(byte(output*255 + 0.5))
+	}
+}
+
+// Scene is the levels of zones, by name. A single level sets all the
+// channels of its zone.
+type Scene map[string][]float64
+
+// Publisher publishes the state of the zones, to an MQTT broker for
+// instance.
+type Publisher interface {
+	Publish(topic string, payload []byte) error
+}
+
+// Trigger returns the next time a schedule fires after a time. It returns
+// the zero time when it never fires again. Astronomical events like sunset
+// are triggers too.
+type Trigger func(after time.Time) time.Time
+
+// Daily returns the trigger firing every day at the local time of day.
+func Daily(hour, min int) Trigger {
+	return func(after time.Time) time.Time {
+		t := time.Date(after.Year(), after.Month(), after.Day(), hour, min, 0, 0, after.Location())
+		if !t.After(after) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t
+	}
+}
+
+type fade struct {
+	from, to []float64
+	start    time.Time
+	duration time.Duration
+}
+
+type zone struct {
+	channels []Channel
+	levels   []float64
+	fade     *fade
+}
+
+type schedule struct {
+	at    Trigger
+	scene string
+	fade  time.Duration
+	next  time.Time
+}
+
+// Controller drives the zones.
+type Controller struct {
+	// Gamma is the gamma correction applied to the levels.
+	Gamma float64
+	// Step is the interval between the updates of the fades.
+	Step time.Duration
+
+	// Publisher, if set, receives the levels of a zone on Topic/zone/<name>
+	// when they change, as comma separated values.
+	Publisher Publisher
+	Topic     string
+
+	clock clock.Clock
+
+	mu        sync.Mutex
+	zones     map[string]*zone
+	scenes    map[string]Scene
+	schedules []*schedule
+
+	changed chan struct{}
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+// New creates a new lighting controller.
+func New() *Controller {
+	return NewWithClock(clock.Real)
+}
+
+// NewWithClock creates a new lighting controller timing the fades and the
+// schedules with c, like a clock.Virtual in tests.
+func NewWithClock(c clock.Clock) *Controller {
+	return &Controller{
+		Gamma:   DefaultGamma,
+		Step:    DefaultStep,
+		clock:   clock.Or(c),
+		zones:   map[string]*zone{},
+		scenes:  map[string]Scene{},
+		changed: make(chan struct{}, 1),
+	}
+}
+
+func (c *Controller) notify() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// AddZone adds a zone of channels, off.
+func (c *Controller) AddZone(name string, channels ...Channel) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.zones[name]; ok {
+		return fmt.Errorf("lighting: zone %v already added", name)
+	}
+	if len(channels) == 0 {
+		return fmt.Errorf("lighting: zone %v has no channels", name)
+	}
+	c.zones[name] = &zone{channels: channels, levels: make([]float64, len(channels))}
+	return nil
+}
+
+// Zones returns the sorted names of the zones.
+func (c *Controller) Zones() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.zones))
+	for name := range c.zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Levels returns the current levels of the channels of a zone, part way
+// through a fade.
+func (c *Controller) Levels(name string) ([]float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	z, ok := c.zones[name]
+	if !ok {
+		return nil, fmt.Errorf("lighting: unknown zone %v", name)
+	}
+	return append([]float64(nil), z.levels...), nil
+}
+
+func clamp(level float64) float64 {
+	switch {
+	case level < 0:
+		return 0
+	case level > 1:
+		return 1
+	}
+	return level
+}
+
+// targets returns the levels of the channels of z set by levels.
+func targets(name string, z *zone, levels []float64) ([]float64, error) {
+	to := make([]float64, len(z.channels))
+	switch len(levels) {
+	case 1:
+		for i := range to {
+			to[i] = clamp(levels[0])
+		}
+	case len(to):
+		for i, l := range levels {
+			to[i] = clamp(l)
+		}
+	default:
+		return nil, fmt.Errorf("lighting: %v levels for zone %v of %v channels", len(levels), name, len(to))
+	}
+	return to, nil
+}
+
+// write sets the channels of a zone to its levels. It must be called with
+// the lock held.
+func (c *Controller) write(name string, z *zone) error {
+	for i, ch := range z.channels {
+		if err := ch(math.Pow(z.levels[i], c.Gamma)); err != nil {
+			return fmt.Errorf("lighting: zone %v: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// set starts fading a zone to levels, or sets them at once without a fade.
+// It must be called with the lock held.
+func (c *Controller) set(name string, d time.Duration, levels []float64) error {
+	z, ok := c.zones[name]
+	if !ok {
+		return fmt.Errorf("lighting: unknown zone %v", name)
+	}
+	to, err := targets(name, z, levels)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		z.fade = nil
+		z.levels = to
+		c.publish(name, z)
+		return c.write(name, z)
+	}
+	glog.V(2).Infof("lighting: fading %v to %v over %v", name, to, d)
+	z.fade = &fade{
+		from:     append([]float64(nil), z.levels...),
+		to:       to,
+		start:    c.clock.Now(),
+		duration: d,
+	}
+	return nil
+}
+
+// Set fades a zone to levels over d, or sets them at once when d is zero.
+// Fades only progress while the controller is running.
+func (c *Controller) Set(name string, d time.Duration, levels ...float64) error {
+	c.mu.Lock()
+	err := c.set(name, d, levels)
+	c.mu.Unlock()
+	c.notify()
+	return err
+}
+
+// AddScene adds or replaces a scene.
+func (c *Controller) AddScene(name string, s Scene) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for zn, levels := range s {
+		z, ok := c.zones[zn]
+		if !ok {
+			return fmt.Errorf("lighting: scene %v: unknown zone %v", name, zn)
+		}
+		if _, err := targets(zn, z, levels); err != nil {
+			return fmt.Errorf("lighting: scene %v: %v", name, err)
+		}
+	}
+	c.scenes[name] = s
+	return nil
+}
+
+// Scenes returns the sorted names of the scenes.
+func (c *Controller) Scenes() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.scenes))
+	for name := range c.scenes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Recall fades the zones of a scene to its levels over d. The zones not in
+// the scene are left alone.
+func (c *Controller) Recall(name string, d time.Duration) error {
+	c.mu.Lock()
+	s, ok := c.scenes[name]
+	if !ok {
+		c.mu.Unlock()
+		return fmt.Errorf("lighting: unknown scene %v", name)
+	}
+	glog.V(1).Infof("lighting: recalling %v", name)
+	var err error
+	for zn, levels := range s {
+		if serr := c.set(zn, d, levels); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	c.mu.Unlock()
+	c.notify()
+	return err
+}
+
+// Off fades all the zones off over d.
+func (c *Controller) Off(d time.Duration) error {
+	c.mu.Lock()
+	var err error
+	for name := range c.zones {
+		if serr := c.set(name, d, []float64{0}); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	c.mu.Unlock()
+	c.notify()
+	return err
+}
+
+// Schedule recalls a scene, fading over d, every time at fires.
+func (c *Controller) Schedule(at Trigger, scene string, d time.Duration) {
+	c.mu.Lock()
+	c.schedules = append(c.schedules, &schedule{
+		at:    at,
+		scene: scene,
+		fade:  d,
+		next:  at(c.clock.Now()),
+	})
+	c.mu.Unlock()
+	c.notify()
+}
+
+func (c *Controller) publish(name string, z *zone) {
+	if c.Publisher == nil {
+		return
+	}
+	vals := make([]string, len(z.levels))
+	for i, l := range z.levels {
+		vals[i] = strconv.FormatFloat(l, 'f', 3, 64)
+	}
+	if err := c.Publisher.Publish(c.Topic+"/zone/"+name, []byte(strings.Join(vals, ","))); err != nil {
+		glog.Errorf("lighting: publishing %v: %v", name, err)
+	}
+}
+
+// step advances the fades and fires the schedules due at now, and returns
+// whether a fade is still in progress.
+func (c *Controller) step(now time.Time) bool {
+	c.mu.Lock()
+	var due []*schedule
+	for _, s := range c.schedules {
+		if !s.next.IsZero() && !now.Before(s.next) {
+			due = append(due, s)
+			s.next = s.at(now)
+		}
+	}
+	c.mu.Unlock()
+	for _, s := range due {
+		if err := c.Recall(s.scene, s.fade); err != nil {
+			glog.Errorf("%v", err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fading := false
+	for name, z := range c.zones {
+		f := z.fade
+		if f == nil {
+			continue
+		}
+		p := float64(now.Sub(f.start)) / float64(f.duration)
+		if p >= 1 {
+			p, z.fade = 1, nil
+		} else {
+			fading = true
+		}
+		for i := range z.levels {
+			z.levels[i] = f.from[i] + (f.to[i]-f.from[i])*p
+		}
+		if err := c.write(name, z); err != nil {
+			glog.Errorf("%v", err)
+		}
+		if z.fade == nil {
+			c.publish(name, z)
+		}
+	}
+	return fading
+}
+
+// next returns the earliest time a schedule fires.
+func (c *Controller) next() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var next time.Time
+	for _, s := range c.schedules {
+		if !s.next.IsZero() && (next.IsZero() || s.next.Before(next)) {
+			next = s.next
+		}
+	}
+	return next
+}
+
+// Run starts fading the zones and firing the schedules in the background.
+func (c *Controller) Run() {
+	c.quit = make(chan struct{})
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+
+		for {
+			wait := c.Step
+			if !c.step(c.clock.Now()) {
+				wait = time.Hour
+				if next := c.next(); !next.IsZero() {
+					wait = next.Sub(c.clock.Now())
+				}
+			}
+			timer := c.clock.NewTimer(wait)
+			select {
+			case <-timer.C():
+			case <-c.changed:
+				timer.Stop()
+			case <-c.quit:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Close stops fading the zones and firing the schedules. The lights are
+// left as they are.
+func (c *Controller) Close() error {
+	if c.quit != nil {
+		close(c.quit)
+		<-c.done
+		c.quit = nil
+	}
+	return nil
+}
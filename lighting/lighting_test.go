@@ -0,0 +1,192 @@
+package lighting
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/clock"
+)
+
+type fakeChannel struct {
+	mu  sync.Mutex
+	out float64
+}
+
+func (ch *fakeChannel) set(out float64) error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	ch.out = out
+	return nil
+}
+
+func (ch *fakeChannel) get() float64 {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	return ch.out
+}
+
+type fakePublisher map[string]string
+
+func (p fakePublisher) Publish(topic string, payload []byte) error {
+	p[topic] = string(payload)
+	return nil
+}
+
+func newController(t *testing.T) (*Controller, *clock.Virtual, []*fakeChannel) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	vc := clock.NewVirtual(start)
+	c := NewWithClock(vc)
+	c.Gamma = 1
+	chs := []*fakeChannel{{}, {}, {}, {}}
+	if err := c.AddZone("kitchen", chs[0].set); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddZone("shelf", chs[1].set, chs[2].set, chs[3].set); err != nil {
+		t.Fatal(err)
+	}
+	return c, vc, chs
+}
+
+func TestFade(t *testing.T) {
+	c, vc, chs := newController(t)
+	pub := fakePublisher{}
+	c.Publisher, c.Topic = pub, "home/lights"
+
+	if err := c.Set("shelf", time.Second, 1, 0.5, 0); err != nil {
+		t.Fatal(err)
+	}
+	c.step(vc.Now().Add(500 * time.Millisecond))
+	if got := chs[1].get(); math.Abs(got-0.5) > 1e-9 {
+		t.Errorf("output half way through the fade = %v; want 0.5", got)
+	}
+	if _, ok := pub["home/lights/zone/shelf"]; ok {
+		t.Error("levels published before the end of the fade")
+	}
+	if c.step(vc.Now().Add(2 * time.Second)) {
+		t.Error("fade still in progress after its duration")
+	}
+	if levels, _ := c.Levels("shelf"); levels[0] != 1 || levels[1] != 0.5 || levels[2] != 0 {
+		t.Errorf("levels after the fade = %v; want [1 0.5 0]", levels)
+	}
+	if got := pub["home/lights/zone/shelf"]; got != "1.000,0.500,0.000" {
+		t.Errorf("published %q; want 1.000,0.500,0.000", got)
+	}
+
+	if err := c.Set("shelf", 0, 0, 1); err == nil {
+		t.Error("setting 2 levels of a zone of 3 channels succeeded")
+	}
+	c.Gamma = 2
+	if err := c.Set("kitchen", 0, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if got := chs[0].get(); got != 0.25 {
+		t.Errorf("gamma corrected output = %v; want 0.25", got)
+	}
+}
+
+func TestScenes(t *testing.T) {
+	c, vc, chs := newController(t)
+
+	if err := c.AddScene("bad", Scene{"hall": {1}}); err == nil {
+		t.Error("adding a scene of an unknown zone succeeded")
+	}
+	if err := c.AddScene("evening", Scene{"kitchen": {0.4}, "shelf": {1}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Scenes(); len(got) != 1 || got[0] != "evening" {
+		t.Errorf("Scenes() = %v; want [evening]", got)
+	}
+
+	c.Schedule(Daily(19, 30), "evening", 0)
+	c.step(vc.Now())
+	if got := chs[0].get(); got != 0 {
+		t.Errorf("output before the schedule = %v; want 0", got)
+	}
+	c.step(time.Date(2026, 3, 1, 19, 30, 0, 0, time.UTC))
+	if chs[0].get() != 0.4 || chs[3].get() != 1 {
+		t.Errorf("outputs after the schedule = %v, %v; want 0.4, 1", chs[0].get(), chs[3].get())
+	}
+	if next := c.next(); !next.Equal(time.Date(2026, 3, 2, 19, 30, 0, 0, time.UTC)) {
+		t.Errorf("next schedule at %v; want the next day", next)
+	}
+
+	if err := c.Off(0); err != nil {
+		t.Fatal(err)
+	}
+	if chs[0].get() != 0 || chs[3].get() != 0 {
+		t.Error("lights on after Off")
+	}
+}
+
+func TestRun(t *testing.T) {
+	c := New()
+	c.Gamma = 1
+	ch := &fakeChannel{}
+	c.AddZone("kitchen", ch.set)
+	c.Run()
+	defer c.Close()
+
+	if err := c.Set("kitchen", 30*time.Millisecond, 1); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for ch.get() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("output %v after the fade; want 1", ch.get())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHandleMessage(t *testing.T) {
+	c, _, chs := newController(t)
+	c.Topic = "home/lights"
+	c.AddScene("evening", Scene{"kitchen": {0.4}})
+
+	for _, m := range []struct {
+		topic, payload string
+		ok             bool
+	}{
+		{"home/lights/scene/set", "evening", true},
+		{"home/lights/zone/shelf/set", "1,0.5,0", true},
+		{"home/lights/zone/shelf/set", "1 soon", false},
+		{"home/lights/scene/set", "night", false},
+		{"home/other/scene/set", "evening", false},
+	} {
+		if err := c.HandleMessage(m.topic, []byte(m.payload)); (err == nil) != m.ok {
+			t.Errorf("HandleMessage(%v, %q) = %v; want ok %v", m.topic, m.payload, err, m.ok)
+		}
+	}
+	if chs[0].get() != 0.4 || chs[2].get() != 0.5 {
+		t.Errorf("outputs %v, %v; want 0.4, 0.5", chs[0].get(), chs[2].get())
+	}
+	if err := c.HandleMessage("home/lights/off/set", nil); err != nil || chs[0].get() != 0 {
+		t.Errorf("off message: %v, output %v", err, chs[0].get())
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	c, _, chs := newController(t)
+
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest("POST", "/zone/kitchen", strings.NewReader("0.75")))
+	if w.Code != 204 || chs[0].get() != 0.75 {
+		t.Errorf("POST /zone/kitchen: %v, output %v", w.Code, chs[0].get())
+	}
+	w = httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest("POST", "/scene/night", nil))
+	if w.Code != 400 {
+		t.Errorf("POST of an unknown scene: %v; want 400", w.Code)
+	}
+	w = httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if body := w.Body.String(); !strings.Contains(body, `"kitchen":[0.75]`) {
+		t.Errorf("state does not hold the kitchen level:\n%v", body)
+	}
+}
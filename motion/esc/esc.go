@@ -0,0 +1,228 @@
+// Package esc allows control of brushless motors through electronic speed
+// controllers (ESCs), using a PWM controller like the PCA9685 or a hardware
+// PWM pin.
+package esc
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// A PWM interface implements access to a pwm controller.
+type PWM interface {
+	SetMicroseconds(us int) error
+}
+
+// Protocol describes the pulse widths (in microseconds) an ESC expects for
+// zero and full throttle.
+type Protocol struct {
+	Name         string
+	MinUs, MaxUs int
+}
+
+var (
+	// Standard is the classic 1000-2000µs servo style protocol, sent at
+	// 50-400Hz.
+	Standard = Protocol{"standard", 1000, 2000}
+
+	// OneShot125 uses 125-250µs pulses, which need a PWM controller capable
+	// of a matching frequency and resolution.
+	OneShot125 = Protocol{"oneshot125", 125, 250}
+)
+
+// Curve maps the requested throttle (0 to 1) to the throttle sent to the ESC
+// (0 to 1).
+type Curve func(throttle float64) float64
+
+// Linear sends the requested throttle unchanged.
+func Linear(throttle float64) float64 {
+	return throttle
+}
+
+// Expo returns a curve softening the response around low throttle, k ranging
+// from 0 (linear) to 1 (cubic).
+func Expo(k float64) Curve {
+	return func(t float64) float64 {
+		return (1-k)*t + k*t*t*t
+	}
+}
+
+const (
+	// DefaultArmTime is how long zero throttle is held while arming.
+	DefaultArmTime = 2 * time.Second
+
+	// DefaultFailsafe is the default failsafe timeout.
+	DefaultFailsafe = 500 * time.Millisecond
+)
+
+// ErrNotArmed is returned when setting the throttle of an ESC which was not
+// armed.
+var ErrNotArmed = errors.New("esc: not armed")
+
+// ESC represents an electronic speed controller.
+type ESC struct {
+	PWM      PWM
+	Protocol Protocol
+	Curve    Curve
+
+	// ArmTime is how long zero throttle is held by Arm.
+	ArmTime time.Duration
+
+	// Failsafe is the time after which the throttle is cut when neither
+	// SetThrottle nor Feed were called, so that a crashed or hung program
+	// does not leave a motor running. Zero disables it.
+	Failsafe time.Duration
+
+	mu       sync.Mutex
+	armed    bool
+	throttle float64
+	watchdog *time.Timer
+}
+
+// New creates a new ESC using the Standard protocol.
+func New(pwm PWM) *ESC {
+	return &ESC{
+		PWM:      pwm,
+		Protocol: Standard,
+		Curve:    Linear,
+		ArmTime:  DefaultArmTime,
+		Failsafe: DefaultFailsafe,
+	}
+}
+
+func (e *ESC) send(throttle float64) error {
+	p := e.Protocol
+	us := p.MinUs + int(math.Floor(throttle*float64(p.MaxUs-p.MinUs)+0.5))
+	return e.PWM.SetMicroseconds(us)
+}
+
+// Arm sends zero throttle for ArmTime, which most ESCs require before they
+// accept any other command. Arm blocks until the ESC is armed.
+func (e *ESC) Arm() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	glog.V(1).Infof("esc: arming (%v)", e.Protocol.Name)
+	if err := e.send(0); err != nil {
+		return err
+	}
+	time.Sleep(e.ArmTime)
+	e.armed = true
+	e.throttle = 0
+	e.feed()
+	return nil
+}
+
+// Calibrate teaches the ESC the pulse width range of the protocol, for ESCs
+// supporting it: full throttle is sent for hold (the ESC is usually powered
+// on during that time and beeps), then zero throttle for hold. The motor
+// must not be able to spin freely while calibrating. The ESC is left armed.
+func (e *ESC) Calibrate(hold time.Duration) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.send(1); err != nil {
+		return err
+	}
+	time.Sleep(hold)
+	if err := e.send(0); err != nil {
+		return err
+	}
+	time.Sleep(hold)
+	e.armed = true
+	e.throttle = 0
+	e.feed()
+	return nil
+}
+
+// SetThrottle sets the throttle, from 0 to 1, after applying the curve.
+func (e *ESC) SetThrottle(throttle float64) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.armed {
+		return ErrNotArmed
+	}
+	if throttle < 0 {
+		throttle = 0
+	}
+	if throttle > 1 {
+		throttle = 1
+	}
+	curve := e.Curve
+	if curve == nil {
+		curve = Linear
+	}
+	if err := e.send(curve(throttle)); err != nil {
+		return err
+	}
+	e.throttle = throttle
+	e.feed()
+	return nil
+}
+
+// Throttle returns the last throttle set, before applying the curve.
+func (e *ESC) Throttle() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.throttle
+}
+
+// Feed resets the failsafe timeout without changing the throttle.
+func (e *ESC) Feed() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.feed()
+}
+
+func (e *ESC) feed() {
+	if e.Failsafe <= 0 || !e.armed {
+		return
+	}
+	if e.watchdog == nil {
+		e.watchdog = time.AfterFunc(e.Failsafe, e.cutoff)
+		return
+	}
+	e.watchdog.Reset(e.Failsafe)
+}
+
+func (e *ESC) cutoff() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.armed || e.throttle == 0 {
+		return
+	}
+	glog.Warningf("esc: no throttle update for %v, cutting throttle", e.Failsafe)
+	if err := e.send(0); err != nil {
+		glog.Errorf("esc: cutting throttle: %v", err)
+		return
+	}
+	e.throttle = 0
+}
+
+// Disarm cuts the throttle and stops accepting throttle commands until the
+// next Arm.
+func (e *ESC) Disarm() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.watchdog != nil {
+		e.watchdog.Stop()
+		e.watchdog = nil
+	}
+	e.armed = false
+	e.throttle = 0
+	return e.send(0)
+}
+
+// Close disarms the ESC.
+func (e *ESC) Close() error {
+	return e.Disarm()
+}
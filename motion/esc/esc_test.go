@@ -0,0 +1,75 @@
+package esc
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockPWM struct {
+	mu  sync.Mutex
+	uss []int
+}
+
+func (p *mockPWM) SetMicroseconds(us int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.uss = append(p.uss, us)
+	return nil
+}
+
+func (p *mockPWM) sent() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]int(nil), p.uss...)
+}
+
+func TestSetThrottle(t *testing.T) {
+	pwm := &mockPWM{}
+	e := New(pwm)
+	e.ArmTime = 0
+	e.Failsafe = 0
+	if err := e.SetThrottle(0.5); err != ErrNotArmed {
+		t.Errorf("SetThrottle before arming: got %v, want %v", err, ErrNotArmed)
+	}
+	if err := e.Arm(); err != nil {
+		t.Fatal(err)
+	}
+	for _, throttle := range []float64{0.5, 2, -1} {
+		if err := e.SetThrottle(throttle); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.Protocol = OneShot125
+	e.Curve = Expo(1)
+	if err := e.SetThrottle(0.5); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{1000, 1500, 2000, 1000, 141}; !reflect.DeepEqual(pwm.sent(), expected) {
+		t.Errorf("Pulses: got %v, want %v", pwm.sent(), expected)
+	}
+}
+
+func TestFailsafe(t *testing.T) {
+	pwm := &mockPWM{}
+	e := New(pwm)
+	e.ArmTime = 0
+	e.Failsafe = 10 * time.Millisecond
+	if err := e.Arm(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetThrottle(0.5); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if e.Throttle() != 0 {
+		t.Errorf("Throttle after failsafe: got %v, want 0", e.Throttle())
+	}
+	if expected := []int{1000, 1500, 1000}; !reflect.DeepEqual(pwm.sent(), expected) {
+		t.Errorf("Pulses: got %v, want %v", pwm.sent(), expected)
+	}
+	e.Close()
+}
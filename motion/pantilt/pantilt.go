@@ -0,0 +1,244 @@
+// Package pantilt allows control of two axis pan/tilt gimbals built out of
+// servos, for example to point a camera or a distance sensor.
+package pantilt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// An Axis interface implements positioning of one axis of the gimbal.
+// *servo.Servo satisfies it.
+type Axis interface {
+	SetAngle(angle int) error
+}
+
+// Position is the angle (in degrees) of both axes.
+type Position struct {
+	Pan  int `json:"pan"`
+	Tilt int `json:"tilt"`
+}
+
+// Limits are the soft limits (in degrees) of an axis, protecting the gimbal
+// and what is mounted on it from running into the end stops.
+type Limits struct {
+	Min, Max int
+}
+
+func (l Limits) clamp(angle int) int {
+	if angle < l.Min {
+		return l.Min
+	}
+	if angle > l.Max {
+		return l.Max
+	}
+	return angle
+}
+
+const (
+	// DefaultStep is the interval between intermediate positions of speed
+	// limited moves; servos are refreshed every 20ms anyway.
+	DefaultStep = 20 * time.Millisecond
+
+	// DefaultFOV is the field of view (in degrees) assumed by Track.
+	DefaultFOV = 60
+)
+
+// PanTilt is a two axis gimbal.
+type PanTilt struct {
+	Pan, Tilt Axis
+
+	PanLimits, TiltLimits Limits
+
+	// Speed is the maximum speed (in degrees per second) of the axes. Zero
+	// means moves are not speed limited.
+	Speed float64
+
+	// Step is the interval between intermediate positions of speed limited
+	// moves.
+	Step time.Duration
+
+	// HFOV and VFOV are the horizontal and vertical field of view (in
+	// degrees) of the camera or sensor being pointed, used by Track.
+	HFOV, VFOV float64
+
+	// Gain is the fraction of the offset corrected by every call to Track.
+	// Values below 1 avoid overshooting when tracking from a video feed.
+	Gain float64
+
+	mu      sync.Mutex
+	pos     Position
+	known   bool
+	presets map[string]Position
+}
+
+// New creates a new gimbal. As servos do not report their position, the
+// first move is never speed limited.
+func New(pan, tilt Axis) *PanTilt {
+	return &PanTilt{
+		Pan:        pan,
+		Tilt:       tilt,
+		PanLimits:  Limits{0, 180},
+		TiltLimits: Limits{0, 180},
+		Step:       DefaultStep,
+		HFOV:       DefaultFOV,
+		VFOV:       DefaultFOV,
+		Gain:       0.5,
+		pos:        Position{90, 90},
+		presets:    make(map[string]Position),
+	}
+}
+
+// Position returns the last commanded position.
+func (p *PanTilt) Position() Position {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.pos
+}
+
+func (p *PanTilt) set(pos Position) error {
+	if err := p.Pan.SetAngle(pos.Pan); err != nil {
+		return err
+	}
+	if err := p.Tilt.SetAngle(pos.Tilt); err != nil {
+		return err
+	}
+	p.pos = pos
+	return nil
+}
+
+// MoveTo moves both axes to pos, clamped to the soft limits. When Speed is
+// set the axes are moved in steps so that they arrive at the same time, with
+// the axis travelling furthest moving at Speed. MoveTo returns once the move
+// is complete.
+func (p *PanTilt) MoveTo(pos Position) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pos.Pan = p.PanLimits.clamp(pos.Pan)
+	pos.Tilt = p.TiltLimits.clamp(pos.Tilt)
+	glog.V(1).Infof("pantilt: moving from %+v to %+v", p.pos, pos)
+
+	if p.Speed <= 0 || !p.known {
+		p.known = true
+		return p.set(pos)
+	}
+
+	step := p.Step
+	if step <= 0 {
+		step = DefaultStep
+	}
+	from := p.pos
+	dpan, dtilt := float64(pos.Pan-from.Pan), float64(pos.Tilt-from.Tilt)
+	distance := math.Max(math.Abs(dpan), math.Abs(dtilt))
+	steps := int(math.Ceil(distance / (p.Speed * step.Seconds())))
+
+	for i := 1; i <= steps; i++ {
+		f := float64(i) / float64(steps)
+		next := Position{
+			Pan:  from.Pan + int(math.Floor(dpan*f+0.5)),
+			Tilt: from.Tilt + int(math.Floor(dtilt*f+0.5)),
+		}
+		if err := p.set(next); err != nil {
+			return err
+		}
+		if i < steps {
+			time.Sleep(step)
+		}
+	}
+
+	return nil
+}
+
+// MoveBy moves the axes relative to the current position.
+func (p *PanTilt) MoveBy(pan, tilt int) error {
+	pos := p.Position()
+	return p.MoveTo(Position{pos.Pan + pan, pos.Tilt + tilt})
+}
+
+// Center moves both axes to the middle of their soft limits.
+func (p *PanTilt) Center() error {
+	return p.MoveTo(Position{
+		Pan:  (p.PanLimits.Min + p.PanLimits.Max) / 2,
+		Tilt: (p.TiltLimits.Min + p.TiltLimits.Max) / 2,
+	})
+}
+
+// Track points the gimbal towards a target seen at (x, y), where both range
+// from -1 to 1 relative to the center of the field of view, x growing to the
+// right and y growing downwards.
+func (p *PanTilt) Track(x, y float64) error {
+	pan := int(math.Floor(x*p.HFOV/2*p.Gain + 0.5))
+	tilt := int(math.Floor(y*p.VFOV/2*p.Gain + 0.5))
+	if pan == 0 && tilt == 0 {
+		return nil
+	}
+	return p.MoveBy(pan, tilt)
+}
+
+// SavePreset stores the current position under the given name.
+func (p *PanTilt) SavePreset(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.presets[name] = p.pos
+}
+
+// SetPreset stores pos under the given name.
+func (p *PanTilt) SetPreset(name string, pos Position) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.presets[name] = pos
+}
+
+// GoTo moves to a preset position.
+func (p *PanTilt) GoTo(name string) error {
+	p.mu.Lock()
+	pos, ok := p.presets[name]
+	p.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("pantilt: unknown preset %q", name)
+	}
+	return p.MoveTo(pos)
+}
+
+// LoadPresets reads preset positions from a JSON file mapping names to
+// positions, like {"door": {"pan": 40, "tilt": 95}}. Presets already set
+// with the same names are replaced.
+func (p *PanTilt) LoadPresets(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var presets map[string]Position
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return fmt.Errorf("pantilt: parsing presets %v: %v", path, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for name, pos := range presets {
+		p.presets[name] = pos
+	}
+	return nil
+}
+
+// SavePresets writes the preset positions to a JSON file.
+func (p *PanTilt) SavePresets(path string) error {
+	p.mu.Lock()
+	data, err := json.MarshalIndent(p.presets, "", "  ")
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,92 @@
+package pantilt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type mockAxis struct {
+	angles []int
+}
+
+func (a *mockAxis) SetAngle(angle int) error {
+	a.angles = append(a.angles, angle)
+	return nil
+}
+
+func TestMoveTo_limits(t *testing.T) {
+	pan, tilt := &mockAxis{}, &mockAxis{}
+	p := New(pan, tilt)
+	p.TiltLimits = Limits{30, 150}
+	if err := p.MoveTo(Position{200, 10}); err != nil {
+		t.Fatal(err)
+	}
+	if pos, expected := p.Position(), (Position{180, 30}); pos != expected {
+		t.Errorf("Position: got %+v, want %+v", pos, expected)
+	}
+}
+
+func TestMoveTo_speed(t *testing.T) {
+	pan, tilt := &mockAxis{}, &mockAxis{}
+	p := New(pan, tilt)
+	p.Speed = 1000
+	p.Step = time.Millisecond
+	if err := p.MoveTo(Position{90, 90}); err != nil {
+		t.Fatal(err)
+	}
+	pan.angles, tilt.angles = nil, nil
+
+	if err := p.MoveTo(Position{94, 88}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{91, 92, 93, 94}; !reflect.DeepEqual(pan.angles, expected) {
+		t.Errorf("Pan angles: got %v, want %v", pan.angles, expected)
+	}
+	if expected := []int{90, 89, 89, 88}; !reflect.DeepEqual(tilt.angles, expected) {
+		t.Errorf("Tilt angles: got %v, want %v", tilt.angles, expected)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	p := New(&mockAxis{}, &mockAxis{})
+	p.Gain = 1
+	if err := p.Track(0.5, -1); err != nil {
+		t.Fatal(err)
+	}
+	if pos, expected := p.Position(), (Position{105, 60}); pos != expected {
+		t.Errorf("Position: got %+v, want %+v", pos, expected)
+	}
+}
+
+func TestPresets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pantilt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "presets.json")
+
+	p := New(&mockAxis{}, &mockAxis{})
+	p.SetPreset("door", Position{40, 95})
+	if err := p.SavePresets(path); err != nil {
+		t.Fatal(err)
+	}
+
+	q := New(&mockAxis{}, &mockAxis{})
+	if err := q.LoadPresets(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.GoTo("door"); err != nil {
+		t.Fatal(err)
+	}
+	if pos, expected := q.Position(), (Position{40, 95}); pos != expected {
+		t.Errorf("Position: got %+v, want %+v", pos, expected)
+	}
+	if err := q.GoTo("window"); err == nil {
+		t.Error("GoTo unknown preset: did not get error")
+	}
+}
@@ -0,0 +1,66 @@
+// DHCP lease parsing.
+
+package netwatch
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const leaseTimeLayout = "2006/01/02 15:04:05"
+
+// never is used as the expiry of infinite leases.
+var never = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+func leaseExpiry(path string) (time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	return parseLeaseExpiry(f)
+}
+
+// parseLeaseExpiry returns the expiry of the last lease in a dhclient lease
+// file. Entries look like:
+//
+//	expire 2 2014/07/22 13:00:01;
+//
+// where the times are in UTC.
+func parseLeaseExpiry(r io.Reader) (time.Time, error) {
+	var expiry time.Time
+	found := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(scanner.Text()), ";"))
+		if len(fields) < 2 || fields[0] != "expire" {
+			continue
+		}
+		if fields[1] == "never" {
+			expiry, found = never, true
+			continue
+		}
+		if len(fields) < 4 {
+			continue
+		}
+		t, err := time.Parse(leaseTimeLayout, fields[2]+" "+fields[3])
+		if err != nil {
+			return time.Time{}, err
+		}
+		expiry, found = t, true
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+	if !found {
+		return time.Time{}, errors.New("netwatch: no lease found")
+	}
+
+	return expiry, nil
+}
@@ -0,0 +1,417 @@
+/*
+Package netwatch monitors the network status of a host: interface up/down,
+addressing, DHCP lease state, reachability of a well known host and captive
+portal detection. It is meant to make headless device bring-up easier by
+emitting status changes as events and by binding the current status to a
+character display region or a status LED.
+
+The watcher is polled, so it works uniformly on every supported host without
+requiring netlink or D-Bus access:
+
+	w := netwatch.New("wlan0")
+	w.BindRegion(display.Region(0, 0, 16), netwatch.IPText)
+	w.BindLED(led)
+	w.Run()
+	defer w.Close()
+
+	for e := range w.Events() {
+		fmt.Println(e)
+	}
+*/
+package netwatch
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+)
+
+const (
+	// DefaultPingHost is the host probed for reachability.
+	DefaultPingHost = "8.8.8.8"
+
+	// DefaultPortalURL is a URL which answers with "204 No Content" when there
+	// is direct access to the internet. Any other answer indicates the presence
+	// of a captive portal.
+	DefaultPortalURL = "http://connectivitycheck.gstatic.com/generate_204"
+
+	pollDelay = 5 * time.Second
+
+	eventBuffer = 16
+)
+
+// EventType identifies the kind of change reported by an Event.
+type EventType int
+
+const (
+	// InterfaceUp is emitted when the interface comes up.
+	InterfaceUp EventType = iota
+
+	// InterfaceDown is emitted when the interface goes down or disappears.
+	InterfaceDown
+
+	// AddressChanged is emitted when the IPv4 address of the interface changes.
+	AddressChanged
+
+	// LeaseAcquired is emitted when a valid DHCP lease is found.
+	LeaseAcquired
+
+	// LeaseExpired is emitted when the DHCP lease expires or disappears.
+	LeaseExpired
+
+	// Reachable is emitted when the ping host becomes reachable.
+	Reachable
+
+	// Unreachable is emitted when the ping host stops answering.
+	Unreachable
+
+	// PortalDetected is emitted when a captive portal is detected.
+	PortalDetected
+
+	// PortalCleared is emitted when the captive portal is no longer in the way.
+	PortalCleared
+
+	// SSIDChanged is emitted when the wireless network changes.
+	SSIDChanged
+)
+
+var eventNames = map[EventType]string{
+	InterfaceUp:    "interface up",
+	InterfaceDown:  "interface down",
+	AddressChanged: "address changed",
+	LeaseAcquired:  "lease acquired",
+	LeaseExpired:   "lease expired",
+	Reachable:      "reachable",
+	Unreachable:    "unreachable",
+	PortalDetected: "captive portal detected",
+	PortalCleared:  "captive portal cleared",
+	SSIDChanged:    "ssid changed",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Status is a snapshot of the network status of an interface.
+type Status struct {
+	Interface string
+	Up        bool
+	IP        net.IP
+	SSID      string
+
+	// LeaseExpiry is the expiry of the current DHCP lease. It is the zero
+	// time if no lease information is available.
+	LeaseExpiry time.Time
+
+	Reachable     bool
+	CaptivePortal bool
+}
+
+// Online returns true if the interface is up, addressed, and the ping host
+// is reachable without a captive portal in the way.
+func (s Status) Online() bool {
+	return s.Up && s.IP != nil && s.Reachable && !s.CaptivePortal
+}
+
+// Event represents a change in the network status.
+type Event struct {
+	Type   EventType
+	Status Status
+	Time   time.Time
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%v: %v", e.Status.Interface, e.Type)
+}
+
+// Watcher monitors the network status of an interface.
+type Watcher struct {
+	Interface string
+	PingHost  string
+	PortalURL string
+
+	// LeaseFile is the dhclient lease file of the interface. Lease tracking
+	// is disabled if it is empty.
+	LeaseFile string
+
+	// Poll is the delay between two status checks.
+	Poll time.Duration
+
+	mu       sync.RWMutex
+	status   Status
+	bindings []func(Status)
+
+	events chan Event
+	quit   chan struct{}
+}
+
+// New creates a new Watcher for the given interface.
+func New(iface string) *Watcher {
+	return &Watcher{
+		Interface: iface,
+		PingHost:  DefaultPingHost,
+		PortalURL: DefaultPortalURL,
+		LeaseFile: fmt.Sprintf("/var/lib/dhcp/dhclient.%v.leases", iface),
+		Poll:      pollDelay,
+		events:    make(chan Event, eventBuffer),
+	}
+}
+
+// Status checks and returns the current network status.
+func (w *Watcher) Status() (Status, error) {
+	s := Status{Interface: w.Interface}
+
+	iface, err := net.InterfaceByName(w.Interface)
+	if err != nil {
+		// A missing interface simply means it is down.
+		glog.V(2).Infof("netwatch: interface %v: %v", w.Interface, err)
+		return s, nil
+	}
+	s.Up = iface.Flags&net.FlagUp != 0
+	if !s.Up {
+		return s, nil
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return s, err
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+			s.IP = ipnet.IP
+			break
+		}
+	}
+
+	s.SSID = ssid(w.Interface)
+
+	if w.LeaseFile != "" {
+		if expiry, err := leaseExpiry(w.LeaseFile); err == nil {
+			s.LeaseExpiry = expiry
+		} else {
+			glog.V(2).Infof("netwatch: reading lease file %v: %v", w.LeaseFile, err)
+		}
+	}
+
+	if s.IP == nil {
+		return s, nil
+	}
+
+	if w.PingHost != "" {
+		s.Reachable = ping(w.PingHost)
+	}
+	if w.PortalURL != "" && s.Reachable {
+		s.CaptivePortal = captivePortal(w.PortalURL)
+	}
+
+	return s, nil
+}
+
+// Events returns the channel on which status changes are delivered.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// BindRegion keeps the given display region updated with the text returned
+// by format for the current status.
+func (w *Watcher) BindRegion(r *characterdisplay.Region, format func(Status) string) {
+	w.bind(func(s Status) {
+		if err := r.Write(format(s)); err != nil {
+			glog.Errorf("netwatch: updating display region: %v", err)
+		}
+	})
+}
+
+// BindLED switches the given LED on while the host is online and off
+// otherwise.
+func (w *Watcher) BindLED(led embd.LED) {
+	w.bind(func(s Status) {
+		var err error
+		if s.Online() {
+			err = led.On()
+		} else {
+			err = led.Off()
+		}
+		if err != nil {
+			glog.Errorf("netwatch: updating led: %v", err)
+		}
+	})
+}
+
+func (w *Watcher) bind(f func(Status)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.bindings = append(w.bindings, f)
+}
+
+// IPText formats the status as the IP address of the interface.
+func IPText(s Status) string {
+	switch {
+	case !s.Up:
+		return "no network"
+	case s.IP == nil:
+		return "no address"
+	default:
+		return s.IP.String()
+	}
+}
+
+// SSIDText formats the status as the SSID of the wireless network, including
+// an indication of the connectivity state.
+func SSIDText(s Status) string {
+	name := s.SSID
+	if name == "" {
+		name = s.Interface
+	}
+	switch {
+	case s.CaptivePortal:
+		return name + " (portal)"
+	case !s.Reachable:
+		return name + " (offline)"
+	default:
+		return name
+	}
+}
+
+// Run starts the status polling loop.
+func (w *Watcher) Run() {
+	w.quit = make(chan struct{})
+
+	go func() {
+		timer := time.NewTicker(w.Poll)
+		defer timer.Stop()
+
+		w.check()
+		for {
+			select {
+			case <-timer.C:
+				w.check()
+			case <-w.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (w *Watcher) check() {
+	s, err := w.Status()
+	if err != nil {
+		glog.Errorf("netwatch: checking status of %v: %v", w.Interface, err)
+		return
+	}
+
+	w.mu.Lock()
+	prev := w.status
+	w.status = s
+	bindings := w.bindings
+	w.mu.Unlock()
+
+	now := time.Now()
+	events := diff(prev, s, now)
+	for _, e := range events {
+		select {
+		case w.events <- e:
+		default:
+			glog.Warningf("netwatch: dropping event %v, nobody is listening", e)
+		}
+	}
+	if len(events) > 0 || prev.Interface == "" {
+		for _, f := range bindings {
+			f(s)
+		}
+	}
+}
+
+func diff(prev, cur Status, now time.Time) []Event {
+	var events []Event
+	add := func(t EventType) {
+		events = append(events, Event{Type: t, Status: cur, Time: now})
+	}
+
+	if prev.Up != cur.Up {
+		if cur.Up {
+			add(InterfaceUp)
+		} else {
+			add(InterfaceDown)
+		}
+	}
+	if !prev.IP.Equal(cur.IP) {
+		add(AddressChanged)
+	}
+	prevLease := prev.LeaseExpiry.After(now)
+	curLease := cur.LeaseExpiry.After(now)
+	if !prevLease && curLease {
+		add(LeaseAcquired)
+	}
+	if prevLease && !curLease {
+		add(LeaseExpired)
+	}
+	if prev.Reachable != cur.Reachable {
+		if cur.Reachable {
+			add(Reachable)
+		} else {
+			add(Unreachable)
+		}
+	}
+	if prev.CaptivePortal != cur.CaptivePortal {
+		if cur.CaptivePortal {
+			add(PortalDetected)
+		} else {
+			add(PortalCleared)
+		}
+	}
+	if prev.SSID != cur.SSID {
+		add(SSIDChanged)
+	}
+
+	return events
+}
+
+// Close stops the polling loop.
+func (w *Watcher) Close() {
+	if w.quit != nil {
+		close(w.quit)
+		w.quit = nil
+	}
+}
+
+func ssid(iface string) string {
+	out, err := exec.Command("iwgetid", "-r", iface).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func ping(host string) bool {
+	return exec.Command("ping", "-c", "1", "-W", "2", host).Run() == nil
+}
+
+var portalClient = &http.Client{
+	Timeout: 5 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func captivePortal(url string) bool {
+	resp, err := portalClient.Get(url)
+	if err != nil {
+		glog.V(2).Infof("netwatch: portal check: %v", err)
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode != http.StatusNoContent
+}
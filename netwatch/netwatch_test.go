@@ -0,0 +1,102 @@
+package netwatch
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLeaseExpiry(t *testing.T) {
+	leases := `lease {
+  interface "wlan0";
+  fixed-address 192.168.1.10;
+  expire 2 2014/07/22 13:00:01;
+}
+lease {
+  interface "wlan0";
+  fixed-address 192.168.1.11;
+  renew 3 2014/07/23 10:49:27;
+  expire 3 2014/07/23 13:00:01;
+}
+`
+	expiry, err := parseLeaseExpiry(strings.NewReader(leases))
+	if err != nil {
+		t.Fatalf("Parsing leases: got %v", err)
+	}
+	expected := time.Date(2014, 7, 23, 13, 0, 1, 0, time.UTC)
+	if !expiry.Equal(expected) {
+		t.Errorf("Parsing leases: got %v, want %v", expiry, expected)
+	}
+}
+
+func TestParseLeaseExpiry_never(t *testing.T) {
+	expiry, err := parseLeaseExpiry(strings.NewReader("lease {\n  expire never;\n}\n"))
+	if err != nil {
+		t.Fatalf("Parsing leases: got %v", err)
+	}
+	if !expiry.Equal(never) {
+		t.Errorf("Parsing leases: got %v, want %v", expiry, never)
+	}
+}
+
+func TestParseLeaseExpiry_empty(t *testing.T) {
+	if _, err := parseLeaseExpiry(strings.NewReader("")); err == nil {
+		t.Error("Parsing empty leases: did not get error")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	now := time.Now()
+	var tests = []struct {
+		prev, cur Status
+		events    []EventType
+	}{
+		{
+			Status{},
+			Status{Up: true, IP: net.IPv4(10, 0, 0, 2), Reachable: true},
+			[]EventType{InterfaceUp, AddressChanged, Reachable},
+		},
+		{
+			Status{Up: true, IP: net.IPv4(10, 0, 0, 2), Reachable: true},
+			Status{Up: true, IP: net.IPv4(10, 0, 0, 2), Reachable: true},
+			nil,
+		},
+		{
+			Status{Up: true, Reachable: true, LeaseExpiry: now.Add(time.Hour)},
+			Status{Up: true, Reachable: true, CaptivePortal: true, LeaseExpiry: now.Add(-time.Hour)},
+			[]EventType{LeaseExpired, PortalDetected},
+		},
+		{
+			Status{Up: true, SSID: "home"},
+			Status{},
+			[]EventType{InterfaceDown, SSIDChanged},
+		},
+	}
+	for idx, test := range tests {
+		var types []EventType
+		for _, e := range diff(test.prev, test.cur, now) {
+			types = append(types, e.Type)
+		}
+		if !reflect.DeepEqual(types, test.events) {
+			t.Errorf("Case %d: got %v, want %v", idx+1, types, test.events)
+		}
+	}
+}
+
+func TestIPText(t *testing.T) {
+	var tests = []struct {
+		status Status
+		text   string
+	}{
+		{Status{}, "no network"},
+		{Status{Up: true}, "no address"},
+		{Status{Up: true, IP: net.IPv4(192, 168, 1, 10)}, "192.168.1.10"},
+	}
+	for _, test := range tests {
+		if text := IPText(test.status); text != test.text {
+			t.Errorf("IPText of %+v: got %q, want %q", test.status, text, test.text)
+		}
+	}
+}
@@ -3,8 +3,13 @@
 package embd
 
 import (
+	"bufio"
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"unicode"
 )
 
 const (
@@ -41,6 +46,15 @@ type PinDesc struct {
 
 	DigitalLogical int
 	AnalogLogical  int
+
+	// PWMChip and PWMChannel locate the PWM channel of the pin in the
+	// sysfs PWM class, as /sys/class/pwm/pwmchip<PWMChip>/pwm<PWMChannel>.
+	PWMChip, PWMChannel int
+
+	// Level is the logic level of the pin in millivolts, 0 when unknown.
+	// FiveVoltTolerant pins accept 5V signals regardless. See CheckLevel.
+	Level            int
+	FiveVoltTolerant bool
 }
 
 // PinMap type represents a collection of pin descriptors.
@@ -55,6 +69,10 @@ type PinMap []*PinDesc
 //
 // Searching for 10 with CapDigital will return pin1 and searching for
 // 10 with CapAnalog will return pin2. This makes for a very pleasant to use API.
+//
+// Keys also match regardless of case and of the underscore between a name
+// and a number, so "gpio17" and "GPIO17" find the pin with the "GPIO_17"
+// alias, and user defined aliases (see SetPinAlias) are resolved first.
 func (m PinMap) Lookup(k interface{}, cap int) (*PinDesc, bool) {
 	var ks string
 	switch key := k.(type) {
@@ -67,16 +85,24 @@ func (m PinMap) Lookup(k interface{}, cap int) (*PinDesc, bool) {
 	default:
 		return nil, false
 	}
+	ks = resolvePinAlias(ks)
+
+	if pd, ok := m.lookup(ks, cap, func(s string) string { return s }); ok {
+		return pd, true
+	}
+	return m.lookup(normalizePinKey(ks), cap, normalizePinKey)
+}
 
+func (m PinMap) lookup(ks string, cap int, normalize func(string) string) (*PinDesc, bool) {
 	for i := range m {
 		pd := m[i]
 
-		if pd.ID == ks {
+		if normalize(pd.ID) == ks {
 			return pd, true
 		}
 
 		for j := range pd.Aliases {
-			if pd.Aliases[j] == ks && pd.Caps&cap != 0 {
+			if normalize(pd.Aliases[j]) == ks && pd.Caps&cap != 0 {
 				return pd, true
 			}
 		}
@@ -84,3 +110,90 @@ func (m PinMap) Lookup(k interface{}, cap int) (*PinDesc, bool) {
 
 	return nil, false
 }
+
+// normalizePinKey upper cases a key and removes the underscores and dashes
+// between a letter and a digit: "gpio_17" becomes "GPIO17", while "P1_11"
+// is unchanged.
+func normalizePinKey(s string) string {
+	r := []rune(strings.ToUpper(s))
+	out := make([]rune, 0, len(r))
+	for i, c := range r {
+		if (c == '_' || c == '-') && i > 0 && i < len(r)-1 && unicode.IsLetter(r[i-1]) && unicode.IsDigit(r[i+1]) {
+			continue
+		}
+		out = append(out, c)
+	}
+	return string(out)
+}
+
+// PinAliasesEnv is the environment variable naming a file of pin aliases
+// loaded by InitGPIO. See LoadPinAliases.
+const PinAliasesEnv = "EMBD_PIN_ALIASES"
+
+var pinAliases = struct {
+	sync.RWMutex
+	m map[string]string
+}{m: map[string]string{}}
+
+// SetPinAlias defines a name for a pin, which can then be passed to
+// NewDigitalPin and the other pin functions on any host. This allows giving
+// pins names from the application, like "DOOR", and mapping them to each
+// board once.
+func SetPinAlias(alias string, key interface{}) {
+	pinAliases.Lock()
+	defer pinAliases.Unlock()
+
+	pinAliases.m[alias] = fmt.Sprint(key)
+}
+
+// resolvePinAlias resolves the user defined aliases of a key, which can
+// refer to other aliases.
+func resolvePinAlias(ks string) string {
+	pinAliases.RLock()
+	defer pinAliases.RUnlock()
+
+	// Bound the resolution to not loop on cycles.
+	for i := 0; i < 8; i++ {
+		target, ok := pinAliases.m[ks]
+		if !ok {
+			break
+		}
+		ks = target
+	}
+	return ks
+}
+
+// LoadPinAliases reads pin aliases from a file with one "alias: key" line
+// per alias, like:
+//
+//	# Door controller wiring.
+//	DOOR: GPIO_17
+//	BUZZER: P1_12
+func LoadPinAliases(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		alias, key := strings.TrimSpace(parts[0]), ""
+		if len(parts) == 2 {
+			key = strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+		}
+		if alias == "" || key == "" {
+			return fmt.Errorf("embd: %v:%v: invalid pin alias %q", path, n, line)
+		}
+		SetPinAlias(alias, key)
+	}
+	return scanner.Err()
+}
@@ -1,6 +1,10 @@
 package embd
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
 
 func TestPinMapLookup(t *testing.T) {
 	var tests = []struct {
@@ -16,12 +20,22 @@ func TestPinMapLookup(t *testing.T) {
 		{"P1_2", CapDigital, "P1_2", true},
 		{"P1_2", CapAnalog, "P1_2", true},
 		{"GPIO10", CapDigital, "P1_2", true},
+		{"gpio_10", CapDigital, "P1_2", true},
+		{"GPIO17", CapDigital, "P1_11", true},
+		{"p1_11", CapDigital, "P1_11", true},
+		{"P111", CapDigital, "", false},
+		{"DOOR", CapDigital, "P1_11", true},
 		{key: "NOTTHERE", found: false},
 	}
 	var pinMap = PinMap{
 		&PinDesc{ID: "P1_1", Aliases: []string{"AN1", "10"}, Caps: CapAnalog},
 		&PinDesc{ID: "P1_2", Aliases: []string{"10", "GPIO10"}, Caps: CapDigital},
+		&PinDesc{ID: "P1_11", Aliases: []string{"17", "GPIO_17"}, Caps: CapDigital},
 	}
+	SetPinAlias("ENTRANCE", "DOOR")
+	SetPinAlias("DOOR", 17)
+	defer delete(pinAliases.m, "DOOR")
+	defer delete(pinAliases.m, "ENTRANCE")
 	for _, test := range tests {
 		pd, found := pinMap.Lookup(test.key, test.cap)
 		if found != test.found {
@@ -55,3 +69,30 @@ func BenchmarkPinMapLookup(b *testing.B) {
 		pinMap.Lookup("GPIO10", CapDigital)
 	}
 }
+
+func TestLoadPinAliases(t *testing.T) {
+	f, err := ioutil.TempFile("", "aliases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("# wiring\nBUZZER: P1_12 # on the header\n\nLED: \"GPIO_27\"\n")
+	f.Close()
+
+	if err := LoadPinAliases(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(pinAliases.m, "BUZZER")
+	defer delete(pinAliases.m, "LED")
+	if got := resolvePinAlias("BUZZER"); got != "P1_12" {
+		t.Errorf("BUZZER = %q; want P1_12", got)
+	}
+	if got := resolvePinAlias("LED"); got != "GPIO_27" {
+		t.Errorf("LED = %q; want GPIO_27", got)
+	}
+
+	ioutil.WriteFile(f.Name(), []byte("BROKEN\n"), 0644)
+	if err := LoadPinAliases(f.Name()); err == nil {
+		t.Error("no error for a line without a key")
+	}
+}
@@ -0,0 +1,174 @@
+// Pin state persistence.
+
+package embd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/golang/glog"
+)
+
+// PinState is the saved configuration of a digital or PWM pin.
+type PinState struct {
+	ID  string `json:"id"`
+	PWM bool   `json:"pwm,omitempty"`
+
+	Direction Direction `json:"direction"`
+	Value     int       `json:"value"`
+	ActiveLow bool      `json:"activeLow,omitempty"`
+
+	OutputMode OutputMode `json:"outputMode,omitempty"`
+
+	Period   int      `json:"period,omitempty"`
+	Duty     int      `json:"duty,omitempty"`
+	Polarity Polarity `json:"polarity,omitempty"`
+}
+
+// A StatefulPin is a pin which can be saved and restored across restarts of
+// the controlling process. Hosts implement it on their pins where supported.
+type StatefulPin interface {
+	// State returns the current configuration of the pin.
+	State() (PinState, error)
+
+	// RestoreState applies a saved configuration. Outputs are driven to the
+	// saved value directly, without glitching through a different one.
+	RestoreState(s PinState) error
+
+	// Release releases the resources associated with the pin like Close,
+	// but leaves the pin configured as it is.
+	Release() error
+}
+
+func (io *gpioDriver) sortedPins() []string {
+	ids := make([]string, 0, len(io.initializedPins))
+	for id := range io.initializedPins {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (io *gpioDriver) pinStates() ([]PinState, error) {
+	var states []PinState
+	for _, id := range io.sortedPins() {
+		p := io.initializedPins[id]
+		sp, ok := p.(StatefulPin)
+		if !ok {
+			continue
+		}
+		s, err := sp.State()
+		if err != nil {
+			return nil, fmt.Errorf("gpio: reading state of pin %v: %v", id, err)
+		}
+		s.ID = id
+		_, s.PWM = p.(PWMPin)
+		states = append(states, s)
+	}
+	return states, nil
+}
+
+func (io *gpioDriver) release() error {
+	for _, id := range io.sortedPins() {
+		p := io.initializedPins[id]
+		if sp, ok := p.(StatefulPin); ok {
+			if err := sp.Release(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := p.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type statefulDriver interface {
+	pinStates() ([]PinState, error)
+	release() error
+}
+
+// SaveGPIOState saves the configuration of the open digital and PWM pins
+// to a file, for RestoreGPIOState to apply after a restart.
+func SaveGPIOState(path string) error {
+	if !gpioDriverInitialized {
+		return nil
+	}
+	drv, ok := gpioDriverInstance.(statefulDriver)
+	if !ok {
+		return ErrFeatureNotSupported
+	}
+	states, err := drv.pinStates()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a crash never leaves a
+	// truncated state behind.
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// SaveAndCloseGPIO saves the pin configuration like SaveGPIOState, then
+// releases the GPIO driver leaving the pins configured, so that outputs keep
+// their state while the process restarts.
+func SaveAndCloseGPIO(path string) error {
+	if err := SaveGPIOState(path); err != nil {
+		return err
+	}
+	if drv, ok := gpioDriverInstance.(statefulDriver); ok {
+		return drv.release()
+	}
+	return CloseGPIO()
+}
+
+// RestoreGPIOState opens the pins saved by SaveGPIOState and applies their
+// configuration. A missing file is not an error.
+func RestoreGPIOState(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var states []PinState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return fmt.Errorf("gpio: parsing pin state %v: %v", path, err)
+	}
+
+	for _, s := range states {
+		var p interface{}
+		if s.PWM {
+			p, err = NewPWMPin(s.ID)
+		} else {
+			p, err = NewDigitalPin(s.ID)
+		}
+		if err != nil {
+			return err
+		}
+		sp, ok := p.(StatefulPin)
+		if !ok {
+			glog.Warningf("gpio: pin %v does not support restoring its state", s.ID)
+			continue
+		}
+		glog.V(1).Infof("gpio: restoring pin %v to %+v", s.ID, s)
+		if err := sp.RestoreState(s); err != nil {
+			return fmt.Errorf("gpio: restoring state of pin %v: %v", s.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package embd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeStatefulPin struct {
+	fakeDigitalPin
+
+	state    PinState
+	released bool
+}
+
+func (p *fakeStatefulPin) State() (PinState, error) {
+	return p.state, nil
+}
+
+func (p *fakeStatefulPin) RestoreState(s PinState) error {
+	p.state = s
+	return nil
+}
+
+func (p *fakeStatefulPin) Release() error {
+	p.released = true
+	return p.drv.Unregister(p.id)
+}
+
+func newFakeStatefulPin(pd *PinDesc, drv GPIODriver) DigitalPin {
+	return &fakeStatefulPin{fakeDigitalPin: fakeDigitalPin{id: pd.ID, n: pd.DigitalLogical, drv: drv}}
+}
+
+func TestSaveAndRestoreGPIOState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "embd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "pins.json")
+
+	pinMap := PinMap{
+		&PinDesc{ID: "P1_1", Aliases: []string{"1"}, Caps: CapDigital, DigitalLogical: 1},
+		&PinDesc{ID: "P1_2", Aliases: []string{"2"}, Caps: CapDigital, DigitalLogical: 2},
+	}
+	defer func(drv GPIODriver, initialized bool) {
+		gpioDriverInstance, gpioDriverInitialized = drv, initialized
+	}(gpioDriverInstance, gpioDriverInitialized)
+	gpioDriverInstance = NewGPIODriver(pinMap, newFakeStatefulPin, nil, nil)
+	gpioDriverInitialized = true
+
+	pin, err := NewDigitalPin(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := PinState{Direction: Out, Value: High, ActiveLow: true}
+	pin.(*fakeStatefulPin).state = saved
+	if err := SaveAndCloseGPIO(path); err != nil {
+		t.Fatal(err)
+	}
+	if !pin.(*fakeStatefulPin).released {
+		t.Error("SaveAndCloseGPIO: pin not released")
+	}
+
+	gpioDriverInstance = NewGPIODriver(pinMap, newFakeStatefulPin, nil, nil)
+	if err := RestoreGPIOState(path); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := NewDigitalPin("P1_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved.ID = "P1_1"
+	if s := restored.(*fakeStatefulPin).state; s != saved {
+		t.Errorf("Restored state: got %+v, want %+v", s, saved)
+	}
+}
+
+func TestRestoreGPIOState_missing(t *testing.T) {
+	if err := RestoreGPIOState(filepath.Join(os.TempDir(), "embd-missing-pin-state.json")); err != nil {
+		t.Errorf("Restoring missing state: got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+// Connector port support.
+
+package embd
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PortKind is the kind of signals of a connector port.
+type PortKind int
+
+const (
+	// DigitalPort ports carry one or two digital pins.
+	DigitalPort PortKind = iota
+	// AnalogPort ports carry one or two analog pins.
+	AnalogPort
+	// I2CPort ports carry an I²C bus, like the Qwiic and STEMMA QT ports.
+	I2CPort
+)
+
+var portKindNames = []string{"digital", "analog", "I2C"}
+
+func (k PortKind) String() string {
+	if k < 0 || int(k) >= len(portKindNames) {
+		return fmt.Sprintf("PortKind(%d)", int(k))
+	}
+	return portKindNames[k]
+}
+
+// Port is a connector of a host or of a hat, like a Grove, Qwiic or STEMMA
+// port, which modules are plugged into.
+type Port struct {
+	Name string
+	Kind PortKind
+	// Pins are the keys of the pins of digital and analog ports, the
+	// primary signal first.
+	Pins []interface{}
+	// Bus is the bus number of I²C ports.
+	Bus byte
+}
+
+// DigitalPin returns the pin i of a digital port.
+func (p Port) DigitalPin(i int) (DigitalPin, error) {
+	if p.Kind != DigitalPort {
+		return nil, fmt.Errorf("embd: port %v is not a digital port", p.Name)
+	}
+	if i < 0 || i >= len(p.Pins) {
+		return nil, fmt.Errorf("embd: port %v has no pin %v", p.Name, i)
+	}
+	return NewDigitalPin(p.Pins[i])
+}
+
+// AnalogPin returns the pin i of an analog port.
+func (p Port) AnalogPin(i int) (AnalogPin, error) {
+	if p.Kind != AnalogPort {
+		return nil, fmt.Errorf("embd: port %v is not an analog port", p.Name)
+	}
+	if i < 0 || i >= len(p.Pins) {
+		return nil, fmt.Errorf("embd: port %v has no pin %v", p.Name, i)
+	}
+	return NewAnalogPin(p.Pins[i])
+}
+
+// I2CBus returns the bus of an I²C port.
+func (p Port) I2CBus() (I2CBus, error) {
+	if p.Kind != I2CPort {
+		return nil, fmt.Errorf("embd: port %v is not an I2C port", p.Name)
+	}
+	if err := InitI2C(); err != nil {
+		return nil, err
+	}
+	return NewI2CBus(p.Bus), nil
+}
+
+var ports = struct {
+	sync.RWMutex
+	m map[string]Port
+}{m: map[string]Port{}}
+
+// RegisterPorts makes the ports of a hat available by name. The ports of the
+// host descriptor do not need to be registered.
+func RegisterPorts(ps ...Port) error {
+	ports.Lock()
+	defer ports.Unlock()
+
+	for _, p := range ps {
+		if p.Name == "" {
+			return fmt.Errorf("embd: port without a name")
+		}
+		if _, ok := ports.m[p.Name]; ok {
+			return fmt.Errorf("embd: port %v already registered", p.Name)
+		}
+	}
+	for _, p := range ps {
+		ports.m[p.Name] = p
+	}
+	return nil
+}
+
+// UnregisterPort removes the port registered under name.
+func UnregisterPort(name string) {
+	ports.Lock()
+	defer ports.Unlock()
+
+	delete(ports.m, name)
+}
+
+// hostPorts returns the ports of the host descriptor.
+func hostPorts() []Port {
+	desc, err := DescribeHost()
+	if err != nil {
+		return nil
+	}
+	return desc.Ports
+}
+
+// Ports returns the sorted names of the registered ports and of the ports
+// of the host.
+func Ports() []string {
+	ports.RLock()
+	names := make([]string, 0, len(ports.m))
+	for name := range ports.m {
+		names = append(names, name)
+	}
+	ports.RUnlock()
+
+	for _, p := range hostPorts() {
+		if _, err := lookupRegisteredPort(p.Name); err != nil {
+			names = append(names, p.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupRegisteredPort(name string) (Port, error) {
+	ports.RLock()
+	defer ports.RUnlock()
+
+	if p, ok := ports.m[name]; ok {
+		return p, nil
+	}
+	return Port{}, fmt.Errorf("embd: unknown port %v", name)
+}
+
+// LookupPort returns the port called name, among the registered ports and
+// then the ports of the host.
+func LookupPort(name string) (Port, error) {
+	p, err := lookupRegisteredPort(name)
+	if err == nil {
+		return p, nil
+	}
+	for _, p := range hostPorts() {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Port{}, err
+}
@@ -0,0 +1,55 @@
+package embd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPorts(t *testing.T) {
+	e := &fakeExpander{pins: map[int]DigitalPin{}}
+	if err := RegisterExpander("portexp", e); err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterExpander("portexp")
+
+	err := RegisterPorts(
+		Port{Name: "D1", Kind: DigitalPort, Pins: []interface{}{"portexp.1", "portexp.2"}},
+		Port{Name: "I2C", Kind: I2CPort, Bus: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterPort("D1")
+	defer UnregisterPort("I2C")
+
+	if err := RegisterPorts(Port{Name: "D2"}, Port{Name: "D1"}); err == nil {
+		t.Error("no error registering a port twice")
+	}
+	if _, err := LookupPort("D2"); err == nil {
+		t.Error("port D2 registered along a duplicate")
+	}
+	if got, want := Ports(), []string{"D1", "I2C"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Ports: got %v, want %v", got, want)
+	}
+
+	p, err := LookupPort("D1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pin, err := p.DigitalPin(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin != e.pins[2] {
+		t.Errorf("DigitalPin(1): got %v, want the expander pin 2", pin)
+	}
+	if _, err := p.DigitalPin(2); err == nil {
+		t.Error("no error for a pin out of range")
+	}
+	if _, err := p.I2CBus(); err == nil {
+		t.Error("no error for the I2C bus of a digital port")
+	}
+	if _, err := LookupPort("A0"); err == nil {
+		t.Error("no error looking up an unknown port")
+	}
+}
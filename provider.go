@@ -0,0 +1,196 @@
+// Driver provider support.
+
+package embd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Params are the parameters a driver is opened with, like the entries of a
+// configuration file.
+type Params map[string]string
+
+// ParseParams parses parameters written as "key=value" pairs separated by
+// commas, like on a command line.
+func ParseParams(s string) (Params, error) {
+	p := Params{}
+	for _, kv := range strings.Split(s, ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		i := strings.Index(kv, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("embd: invalid parameter %q, want key=value", kv)
+		}
+		p[strings.TrimSpace(kv[:i])] = strings.TrimSpace(kv[i+1:])
+	}
+	return p, nil
+}
+
+// String returns the parameter key, or def when it is not set.
+func (p Params) String(key, def string) string {
+	if v, ok := p[key]; ok {
+		return v
+	}
+	return def
+}
+
+// Int returns the parameter key, in decimal or with a 0x prefix in
+// hexadecimal, or def when it is not set.
+func (p Params) Int(key string, def int) (int, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 0, 0)
+	if err != nil {
+		return 0, fmt.Errorf("embd: invalid integer parameter %v=%q", key, v)
+	}
+	return int(n), nil
+}
+
+// ProviderKind is the kind of drivers a provider adds.
+type ProviderKind string
+
+const (
+	// HostProviders add host backends.
+	HostProviders ProviderKind = "host"
+	// BusProviders add kinds of buses, opened as an I2CBus, a SPIBus, a
+	// DigitalBus or an io.ReadWriter.
+	BusProviders ProviderKind = "bus"
+	// DisplayProviders add display controllers, opened as a
+	// characterdisplay.Controller or a graphics.Display.
+	DisplayProviders ProviderKind = "display"
+)
+
+// A Provider adds drivers from another package, without modifying this
+// one. Providers register in the init function of their package, so that
+// importing the package for its side effects is enough, like the hosts of
+// host/all:
+//
+//	import _ "example.com/embd-pinecone"
+type Provider interface {
+	Name() string
+	Kind() ProviderKind
+}
+
+// HostProvider is a Provider adding a host backend, found by DetectHost when
+// the built-in hosts do not match.
+type HostProvider interface {
+	Provider
+	// Detect returns whether the program runs on the host, and its
+	// revision.
+	Detect() (rev int, ok bool)
+	// Describe returns the descriptor of the host revision.
+	Describe(rev int) *Descriptor
+}
+
+// OpenProvider is a Provider adding a kind of bus or a display controller,
+// opened from parameters.
+type OpenProvider interface {
+	Provider
+	Open(params Params) (interface{}, error)
+}
+
+// Sampler is implemented by the providers which can be opened without
+// hardware, for their conformance to be tested.
+type Sampler interface {
+	// SampleParams returns the parameters opening an instance working
+	// without hardware, like in dry run mode.
+	SampleParams() Params
+}
+
+var providers = struct {
+	sync.RWMutex
+	m map[ProviderKind]map[string]Provider
+}{m: map[ProviderKind]map[string]Provider{}}
+
+// RegisterProvider makes the drivers of a provider available. Host providers
+// are registered as hosts with Register.
+func RegisterProvider(p Provider) error {
+	name, kind := p.Name(), p.Kind()
+	if name == "" {
+		return fmt.Errorf("embd: %v provider without a name", kind)
+	}
+	switch kind {
+	case HostProviders:
+		if _, ok := p.(HostProvider); !ok {
+			return fmt.Errorf("embd: host provider %v is not a HostProvider", name)
+		}
+	case BusProviders, DisplayProviders:
+		if _, ok := p.(OpenProvider); !ok {
+			return fmt.Errorf("embd: %v provider %v is not an OpenProvider", kind, name)
+		}
+	default:
+		return fmt.Errorf("embd: provider %v of unknown kind %q", name, kind)
+	}
+
+	providers.Lock()
+	defer providers.Unlock()
+
+	if _, ok := providers.m[kind][name]; ok {
+		return fmt.Errorf("embd: %v provider %v already registered", kind, name)
+	}
+	if hp, ok := p.(HostProvider); ok {
+		if _, dup := describers[Host(name)]; dup {
+			return fmt.Errorf("embd: host %v already registered", name)
+		}
+		Register(Host(name), hp.Describe)
+	}
+	if providers.m[kind] == nil {
+		providers.m[kind] = map[string]Provider{}
+	}
+	providers.m[kind][name] = p
+	return nil
+}
+
+// Providers returns the sorted names of the registered providers of a
+// kind.
+func Providers(kind ProviderKind) []string {
+	providers.RLock()
+	defer providers.RUnlock()
+
+	names := make([]string, 0, len(providers.m[kind]))
+	for name := range providers.m[kind] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupProvider returns the provider of a kind registered under name.
+func LookupProvider(kind ProviderKind, name string) (Provider, bool) {
+	providers.RLock()
+	defer providers.RUnlock()
+
+	p, ok := providers.m[kind][name]
+	return p, ok
+}
+
+// Open opens a bus or a display controller of a registered provider.
+func Open(kind ProviderKind, name string, params Params) (interface{}, error) {
+	p, ok := LookupProvider(kind, name)
+	if !ok {
+		return nil, fmt.Errorf("embd: unknown %v provider %v. registered providers are: %v", kind, name, strings.Join(Providers(kind), ", "))
+	}
+	op, ok := p.(OpenProvider)
+	if !ok {
+		return nil, fmt.Errorf("embd: %v provider %v cannot be opened", kind, name)
+	}
+	return op.Open(params)
+}
+
+// detectProvidedHost returns the first host provider detecting its host.
+func detectProvidedHost() (Host, int, bool) {
+	for _, name := range Providers(HostProviders) {
+		p, _ := LookupProvider(HostProviders, name)
+		if rev, ok := p.(HostProvider).Detect(); ok {
+			return Host(name), rev, true
+		}
+	}
+	return HostNull, 0, false
+}
@@ -0,0 +1,90 @@
+package embd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseParams(t *testing.T) {
+	p, err := ParseParams("bus=1, addr=0x27,pinmap=mjkdz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Params{"bus": "1", "addr": "0x27", "pinmap": "mjkdz"}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("got %v, want %v", p, want)
+	}
+	if n, err := p.Int("addr", 0); err != nil || n != 0x27 {
+		t.Errorf("addr: got %v, %v, want 0x27", n, err)
+	}
+	if n, err := p.Int("cols", 20); err != nil || n != 20 {
+		t.Errorf("cols: got %v, %v, want the default 20", n, err)
+	}
+	if _, err := p.Int("pinmap", 0); err == nil {
+		t.Error("pinmap: parsed as an integer")
+	}
+	if _, err := ParseParams("bus"); err == nil {
+		t.Error("parsed a parameter without a value")
+	}
+}
+
+type fakeHostProvider struct{ detected bool }
+
+func (fakeHostProvider) Name() string       { return "fakehost" }
+func (fakeHostProvider) Kind() ProviderKind { return HostProviders }
+
+func (p fakeHostProvider) Detect() (int, bool) { return 3, p.detected }
+
+func (fakeHostProvider) Describe(rev int) *Descriptor {
+	return &Descriptor{}
+}
+
+type fakeDisplayProvider struct{}
+
+func (fakeDisplayProvider) Name() string       { return "fakedisplay" }
+func (fakeDisplayProvider) Kind() ProviderKind { return DisplayProviders }
+
+func (fakeDisplayProvider) Open(params Params) (interface{}, error) {
+	return params.String("name", "display"), nil
+}
+
+func unregisterProviders() {
+	providers.Lock()
+	defer providers.Unlock()
+
+	providers.m = map[ProviderKind]map[string]Provider{}
+	delete(describers, "fakehost")
+}
+
+func TestRegisterProvider(t *testing.T) {
+	defer unregisterProviders()
+
+	if err := RegisterProvider(fakeHostProvider{detected: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterProvider(fakeDisplayProvider{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterProvider(fakeDisplayProvider{}); err == nil {
+		t.Error("registered a provider twice")
+	}
+
+	if got := Providers(DisplayProviders); !reflect.DeepEqual(got, []string{"fakedisplay"}) {
+		t.Errorf("display providers: got %v", got)
+	}
+	dev, err := Open(DisplayProviders, "fakedisplay", Params{"name": "lcd"})
+	if err != nil || dev != "lcd" {
+		t.Errorf("opening: got %v, %v, want lcd", dev, err)
+	}
+	if _, err := Open(DisplayProviders, "nodisplay", nil); err == nil {
+		t.Error("opened an unknown provider")
+	}
+
+	host, rev, ok := detectProvidedHost()
+	if !ok || host != "fakehost" || rev != 3 {
+		t.Errorf("detecting: got %v, %v, %v, want fakehost, 3, true", host, rev, ok)
+	}
+	if host, _, err := parseHost("FakeHost"); err != nil || host != "fakehost" {
+		t.Errorf("parsing: got %v, %v, want fakehost", host, err)
+	}
+}
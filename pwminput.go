@@ -0,0 +1,155 @@
+// PWM input support.
+
+package embd
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// PWMInputTimeout is how long a PWM input goes without edges before the
+// signal is considered a steady level.
+var PWMInputTimeout = 500 * time.Millisecond
+
+// PWMMeasurement is a measurement of a PWM signal.
+type PWMMeasurement struct {
+	// Period and High are the durations of the last full cycle and of its
+	// high pulse. Both are zero without a signal.
+	Period, High time.Duration
+
+	// Level is the last level of the pin.
+	Level int
+}
+
+// Frequency returns the frequency of the signal in Hz, or zero without a
+// signal.
+func (m PWMMeasurement) Frequency() float64 {
+	if m.Period <= 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(m.Period)
+}
+
+// Duty returns the duty cycle of the signal from 0 to 1, which is the level of
+// the pin without a signal.
+func (m PWMMeasurement) Duty() float64 {
+	if m.Period <= 0 {
+		return float64(m.Level)
+	}
+	return float64(m.High) / float64(m.Period)
+}
+
+// PWMInput implements the measurement of an incoming PWM signal, like the
+// channels of an RC receiver or the PWM output of a fan.
+type PWMInput interface {
+	// Measure returns the last measurement of the signal.
+	Measure() (PWMMeasurement, error)
+
+	// Close releases the resources associated with the input.
+	Close() error
+}
+
+// A PWMInputDriver is a GPIO driver which can measure PWM signals in
+// hardware, like with timer captures.
+type PWMInputDriver interface {
+	// PWMInput returns an input measuring the signal on the pin.
+	PWMInput(key interface{}) (PWMInput, error)
+}
+
+// NewPWMInput returns a PWMInput measuring the signal on the pin. It uses
+// the capture hardware when the GPIO driver of the host supports it, and
+// times the edges of the pin otherwise.
+func NewPWMInput(key interface{}) (PWMInput, error) {
+	if err := InitGPIO(); err != nil {
+		return nil, err
+	}
+
+	if drv, ok := gpioDriverInstance.(PWMInputDriver); ok {
+		return drv.PWMInput(key)
+	}
+
+	pin, err := NewDigitalPin(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := pin.SetDirection(In); err != nil {
+		return nil, err
+	}
+	return NewEdgePWMInput(pin)
+}
+
+// edgePWMInput measures a PWM signal by timing the edges of a digital pin.
+type edgePWMInput struct {
+	pin DigitalPin
+	now func() time.Time
+
+	mu           sync.Mutex
+	rise, fall   time.Time
+	last         time.Time
+	period, high time.Duration
+	level        int
+}
+
+// NewEdgePWMInput returns a PWMInput timing the edges of the pin, which
+// works on any pin supporting Watch. The precision is limited by the
+// interrupt latency, which suits signals up to a few kHz. Closing the input
+// closes the pin.
+func NewEdgePWMInput(pin DigitalPin) (PWMInput, error) {
+	in := &edgePWMInput{pin: pin, now: time.Now}
+	if err := pin.Watch(EdgeBoth, in.edge); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+func (in *edgePWMInput) edge(pin DigitalPin) {
+	level, err := pin.Read()
+	if err != nil {
+		glog.Errorf("embd: reading pwm input %v: %v", pin.N(), err)
+		return
+	}
+	in.update(in.now(), level)
+}
+
+// update records an edge to level at t. A cycle is measured at each rising
+// edge following a falling one.
+func (in *edgePWMInput) update(t time.Time, level int) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.last, in.level = t, level
+	if level != High {
+		in.fall = t
+		return
+	}
+	if !in.rise.IsZero() && in.fall.After(in.rise) {
+		in.period, in.high = t.Sub(in.rise), in.fall.Sub(in.rise)
+	}
+	in.rise = t
+}
+
+func (in *edgePWMInput) Measure() (PWMMeasurement, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if in.last.IsZero() || in.now().Sub(in.last) > PWMInputTimeout {
+		// Without edges, the signal is a steady level.
+		level, err := in.pin.Read()
+		if err != nil {
+			return PWMMeasurement{}, err
+		}
+		in.rise, in.fall = time.Time{}, time.Time{}
+		in.period, in.high = 0, 0
+		return PWMMeasurement{Level: level}, nil
+	}
+	return PWMMeasurement{Period: in.period, High: in.high, Level: in.level}, nil
+}
+
+func (in *edgePWMInput) Close() error {
+	if err := in.pin.StopWatching(); err != nil {
+		return err
+	}
+	return in.pin.Close()
+}
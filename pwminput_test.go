@@ -0,0 +1,55 @@
+package embd
+
+import (
+	"testing"
+	"time"
+)
+
+type levelPin struct {
+	fakeDigitalPin
+	level int
+}
+
+func (p *levelPin) Read() (int, error) {
+	return p.level, nil
+}
+
+func TestEdgePWMInput(t *testing.T) {
+	pin := &levelPin{}
+	now := time.Unix(0, 0)
+	in := &edgePWMInput{pin: pin, now: func() time.Time { return now }}
+
+	m, err := in.Measure()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Frequency() != 0 || m.Duty() != 0 {
+		t.Errorf("without edges: got %v Hz, duty %v, want no signal", m.Frequency(), m.Duty())
+	}
+
+	// A 50 Hz RC signal with 1.5 ms pulses.
+	for i := 0; i < 3; i++ {
+		in.update(now, High)
+		now = now.Add(1500 * time.Microsecond)
+		in.update(now, Low)
+		now = now.Add(18500 * time.Microsecond)
+	}
+	m, err = in.Measure()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.High != 1500*time.Microsecond || m.Frequency() != 50 || m.Duty() != 0.075 {
+		t.Errorf("50 Hz signal: got pulse %v, %v Hz, duty %v", m.High, m.Frequency(), m.Duty())
+	}
+
+	// The signal stops high.
+	in.update(now, High)
+	pin.level = High
+	now = now.Add(time.Second)
+	if m, err = in.Measure(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Frequency() != 0 || m.Duty() != 1 {
+		t.Errorf("steady high: got %v Hz, duty %v, want no signal at full duty", m.Frequency(), m.Duty())
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Package realtime runs timing-critical loops, like software PWM, WS2812
+encoding or DHT22 reads, on an OS thread of their own, optionally with a
+real-time priority and pinned to CPUs, so that the scheduler preempts them
+less often:
+
+	err := realtime.Run(realtime.Hints{Priority: 50, CPUs: []int{3}}, func() error {
+		return readDHT22(pin)
+	})
+
+The priority and the affinity are hints: the real-time priority needs
+CAP_SYS_NICE, or an RLIMIT_RTPRIO, and hints the process is not permitted
+to apply are logged and skipped. A real-time loop spinning without ever
+blocking starves the other threads of its CPU; keep such loops short, or
+pin them to a CPU of their own (see isolcpus).
+*/
+package realtime
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"github.com/golang/glog"
+)
+
+// Linux scheduling policy of sched_setscheduler(2).
+const schedFIFO = 1
+
+// MaxPriority is the highest SCHED_FIFO priority.
+const MaxPriority = 99
+
+// maxCPUs is the number of CPUs of the affinity masks.
+const maxCPUs = 1024
+
+// Hints are the scheduling hints of a timing-critical thread.
+type Hints struct {
+	// Priority is the SCHED_FIFO priority, from 1 to MaxPriority. Zero
+	// leaves the thread in the default policy.
+	Priority int
+
+	// CPUs are the CPUs the thread runs on. Empty leaves the affinity of
+	// the process.
+	CPUs []int
+}
+
+// validate returns an error when the hints are out of range.
+func (h Hints) validate() error {
+	if h.Priority < 0 || h.Priority > MaxPriority {
+		return fmt.Errorf("realtime: priority %v out of range 0 to %v", h.Priority, MaxPriority)
+	}
+	for _, cpu := range h.CPUs {
+		if cpu < 0 || cpu >= maxCPUs {
+			return fmt.Errorf("realtime: invalid CPU %v", cpu)
+		}
+	}
+	return nil
+}
+
+type schedParam struct {
+	priority int32
+}
+
+// Apply applies the hints to the OS thread of the calling goroutine, which
+// must have locked it with runtime.LockOSThread. It returns the errors of
+// the hints which could not be applied, joined.
+func Apply(h Hints) error {
+	if err := h.validate(); err != nil {
+		return err
+	}
+	tid := uintptr(syscall.Gettid())
+	var errs []error
+	if h.Priority > 0 {
+		param := schedParam{int32(h.Priority)}
+		_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETSCHEDULER, tid, schedFIFO, uintptr(unsafe.Pointer(&param)))
+		if errno != 0 {
+			errs = append(errs, fmt.Errorf("realtime: setting priority %v: %w", h.Priority, errno))
+		}
+	}
+	if len(h.CPUs) > 0 {
+		var mask [maxCPUs / 64]uint64
+		for _, cpu := range h.CPUs {
+			mask[cpu/64] |= 1 << uint(cpu%64)
+		}
+		_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, tid, unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+		if errno != 0 {
+			errs = append(errs, fmt.Errorf("realtime: setting affinity to CPUs %v: %w", h.CPUs, errno))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run runs fn on an OS thread of its own with the hints applied, and
+// returns its error. The hints which cannot be applied are logged, and fn
+// runs anyway. The thread exits with fn, so that the hints do not outlive
+// it on the threads of other goroutines.
+func Run(h Hints, fn func() error) error {
+	if err := h.validate(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		// The goroutine exits without unlocking the thread, which ends the
+		// thread with it.
+		runtime.LockOSThread()
+		if err := Apply(h); err != nil {
+			glog.Warningf("%v, running without", err)
+		}
+		done <- fn()
+	}()
+	return <-done
+}
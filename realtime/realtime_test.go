@@ -0,0 +1,50 @@
+package realtime
+
+import (
+	"errors"
+	"runtime"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func TestHints_validate(t *testing.T) {
+	for _, h := range []Hints{{Priority: -1}, {Priority: MaxPriority + 1}, {CPUs: []int{-1}}, {CPUs: []int{maxCPUs}}} {
+		if err := Run(h, func() error { return nil }); err == nil {
+			t.Errorf("Run with %+v: got no error", h)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	want := errors.New("done")
+	if err := Run(Hints{CPUs: []int{0}}, func() error { return want }); err != want {
+		t.Errorf("Run: got %v, want the error of fn", err)
+	}
+}
+
+func TestApply_affinity(t *testing.T) {
+	var mask [maxCPUs / 64]uint64
+	errs := make(chan error, 1)
+	go func() {
+		// The thread ends with the goroutine.
+		runtime.LockOSThread()
+		if err := Apply(Hints{CPUs: []int{0}}); err != nil {
+			errs <- err
+			return
+		}
+		_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, 0, unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask)))
+		if errno != 0 {
+			errs <- errno
+			return
+		}
+		errs <- nil
+	}()
+	if err := <-errs; err != nil {
+		t.Skipf("setting the affinity: %v", err)
+	}
+	want := [maxCPUs / 64]uint64{1}
+	if mask != want {
+		t.Errorf("affinity: got %#x, want CPU 0", mask[0])
+	}
+}
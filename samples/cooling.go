@@ -0,0 +1,94 @@
+// +build ignore
+
+// Raspberry Pi 4 fan control: the fan, switched by a transistor on the PWM
+// pin, follows the SoC temperature. Set -relay for a fan switched on and off
+// by a relay instead, and -tach to detect when the fan stalls.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/cooling"
+	"github.com/kidoman/embd/sensor/tachometer"
+	"github.com/kidoman/embd/sensor/thermalzone"
+
+	_ "github.com/kidoman/embd/host/all"
+)
+
+func main() {
+	curve := flag.String("curve", "50:0,50:30,70:100", "fan curve, as temperature:percent points")
+	relay := flag.Bool("relay", false, "switch the fan on and off with a relay")
+	tach := flag.String("tach", "", "pin of the tachometer output of the fan, if connected")
+	flag.Parse()
+
+	if err := embd.InitGPIO(); err != nil {
+		panic(err)
+	}
+	defer embd.CloseGPIO()
+
+	c, err := cooling.ParseCurve(*curve)
+	if err != nil {
+		panic(err)
+	}
+
+	var fan cooling.Fan
+	if *relay {
+		pin, err := embd.NewDigitalPin("GPIO_18")
+		if err != nil {
+			panic(err)
+		}
+		defer pin.Close()
+		if err := pin.SetDirection(embd.Out); err != nil {
+			panic(err)
+		}
+		fan = cooling.RelayFan(pin)
+	} else {
+		pin, err := embd.NewPWMPin("PWM0")
+		if err != nil {
+			panic(err)
+		}
+		defer pin.Close()
+		fan = cooling.PWMFan(pin)
+	}
+
+	ctrl := cooling.New(thermalzone.New(0), fan)
+	ctrl.Curve = c
+	if *tach != "" {
+		pin, err := embd.NewDigitalPin(*tach)
+		if err != nil {
+			panic(err)
+		}
+		defer pin.Close()
+		if err := pin.PullUp(); err != nil {
+			panic(err)
+		}
+		t := tachometer.NewFan(pin)
+		defer t.Close()
+		ctrl.Tachometer = t
+	}
+	ctrl.Run()
+	defer ctrl.Close()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if s, err := ctrl.State(); err == nil {
+				fmt.Printf("%v (%.0f rpm)\n", s, s.RPM)
+			}
+		case s := <-ctrl.Alerts():
+			fmt.Println("ALERT:", s)
+		case <-quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// +build ignore
+
+// hd44780bench measures how many characters per second an HD44780 display
+// is written at over a bus, to compare the backends on real hardware:
+//
+//	go run hd44780bench.go -bus i2c -batch
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/controller/hd44780"
+
+	_ "github.com/kidoman/embd/host/all"
+)
+
+func main() {
+	bus := flag.String("bus", "i2c", "bus of the display: gpio, i2c or spi")
+	pins := flag.String("pins", "P1_15,P1_16,P1_18,P1_22,P1_24,P1_26,P1_11", "GPIO pins: rs,en,d4,d5,d6,d7,backlight")
+	addr := flag.Uint("addr", 0x27, "I²C address of the backpack")
+	batch := flag.Bool("batch", false, "write each line in a single bus transaction where the bus supports it, like I2C_RDWR")
+	lines := flag.Int("lines", 200, "number of 20 character lines to write")
+	flag.Parse()
+
+	var hd *hd44780.HD44780
+	var err error
+	switch *bus {
+	case "gpio":
+		if err := embd.InitGPIO(); err != nil {
+			panic(err)
+		}
+		defer embd.CloseGPIO()
+		p := strings.Split(*pins, ",")
+		if len(p) != 7 {
+			panic("7 pins are needed")
+		}
+		hd, err = hd44780.NewGPIO(p[0], p[1], p[2], p[3], p[4], p[5], p[6], hd44780.Positive, hd44780.RowAddress20Col, hd44780.TwoLine)
+	case "i2c":
+		if err := embd.InitI2C(); err != nil {
+			panic(err)
+		}
+		defer embd.CloseI2C()
+		hd, err = hd44780.NewI2C(embd.NewI2CBus(1), byte(*addr), hd44780.PCF8574PinMap, hd44780.RowAddress20Col, hd44780.TwoLine)
+	case "spi":
+		if err := embd.InitSPI(); err != nil {
+			panic(err)
+		}
+		defer embd.CloseSPI()
+		hd, err = hd44780.NewSPI(embd.NewSPIBus(embd.SPIMode0, 0, 1000000, 8, 0), hd44780.PCF8574PinMap, hd44780.RowAddress20Col, hd44780.TwoLine)
+	default:
+		panic("unknown bus " + *bus)
+	}
+	if err != nil {
+		panic(err)
+	}
+	defer hd.Close()
+
+	line := []byte("The quick brown fox ")
+	start := time.Now()
+	for i := 0; i < *lines; i++ {
+		if err := hd.SetCursor(0, i%2); err != nil {
+			panic(err)
+		}
+		if *batch {
+			err = hd.WriteChars(line)
+		} else {
+			for _, v := range line {
+				if err = hd.WriteChar(v); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			panic(err)
+		}
+	}
+	elapsed := time.Since(start)
+
+	n := *lines * len(line)
+	fmt.Printf("%v: %v characters in %v, %.0f chars/s\n", *bus, n, elapsed, float64(n)/elapsed.Seconds())
+}
@@ -0,0 +1,106 @@
+// +build ignore
+
+// Line follower using a QTR-8A array read through a MCP3008 and two motors
+// driven with PWM (through a H-bridge), steered by a PD controller. Works on a
+// BBB.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/convertors/mcp3008"
+	"github.com/kidoman/embd/sensor/qtr"
+
+	_ "github.com/kidoman/embd/host/all"
+)
+
+const (
+	baseSpeed = 150
+	kp        = 0.08
+	kd        = 0.5
+)
+
+func clamp(v int) byte {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return byte(v)
+}
+
+func main() {
+	flag.Parse()
+
+	if err := embd.InitSPI(); err != nil {
+		panic(err)
+	}
+	defer embd.CloseSPI()
+
+	if err := embd.InitGPIO(); err != nil {
+		panic(err)
+	}
+	defer embd.CloseGPIO()
+
+	spiBus := embd.NewSPIBus(embd.SPIMode0, 0, 1000000, 8, 0)
+	defer spiBus.Close()
+
+	adc := mcp3008.New(mcp3008.SingleMode, spiBus)
+	sensors := qtr.NewAnalog(adc, 0, 1, 2, 3, 4, 5, 6, 7)
+
+	left, err := embd.NewPWMPin("P9_14")
+	if err != nil {
+		panic(err)
+	}
+	defer left.Close()
+	right, err := embd.NewPWMPin("P9_16")
+	if err != nil {
+		panic(err)
+	}
+	defer right.Close()
+
+	fmt.Println("calibrating, sweep the sensors over the line")
+	for i := 0; i < 200; i++ {
+		if err := sensors.Calibrate(); err != nil {
+			panic(err)
+		}
+		time.Sleep(25 * time.Millisecond)
+	}
+
+	defer func() {
+		left.SetAnalog(0)
+		right.SetAnalog(0)
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, os.Kill)
+
+	center := (sensors.Len() - 1) * qtr.MaxValue / 2
+	lastErr := 0
+	timer := time.Tick(10 * time.Millisecond)
+
+	for {
+		select {
+		case <-timer:
+			pos, err := sensors.Position()
+			if err != nil {
+				panic(err)
+			}
+			e := pos - center
+			correction := int(kp*float64(e) + kd*float64(e-lastErr))
+			lastErr = e
+
+			left.SetAnalog(clamp(baseSpeed + correction))
+			right.SetAnalog(clamp(baseSpeed - correction))
+		case <-quit:
+			return
+		}
+	}
+}
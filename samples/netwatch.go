@@ -0,0 +1,76 @@
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/controller/hd44780"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+	"github.com/kidoman/embd/netwatch"
+
+	_ "github.com/kidoman/embd/host/all"
+)
+
+func main() {
+	iface := flag.String("iface", "wlan0", "network interface to watch")
+	flag.Parse()
+
+	if err := embd.InitI2C(); err != nil {
+		panic(err)
+	}
+	defer embd.CloseI2C()
+
+	if err := embd.InitLED(); err != nil {
+		panic(err)
+	}
+	defer embd.CloseLED()
+
+	bus := embd.NewI2CBus(1)
+
+	controller, err := hd44780.NewI2C(
+		bus,
+		0x20,
+		hd44780.PCF8574PinMap,
+		hd44780.RowAddress16Col,
+		hd44780.TwoLine,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	display := characterdisplay.New(controller, 16, 2)
+	defer display.Close()
+
+	display.Clear()
+	display.BacklightOn()
+
+	led, err := embd.NewLED(0)
+	if err != nil {
+		panic(err)
+	}
+
+	w := netwatch.New(*iface)
+	w.BindRegion(display.Region(0, 0, 16), netwatch.IPText)
+	w.BindRegion(display.Region(0, 1, 16), netwatch.SSIDText)
+	w.BindLED(led)
+	w.Run()
+	defer w.Close()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, os.Kill)
+	defer signal.Stop(quit)
+
+	for {
+		select {
+		case e := <-w.Events():
+			fmt.Println(e)
+		case <-quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// +build ignore
+
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/controller/pca9685"
+	"github.com/kidoman/embd/motion/pantilt"
+	"github.com/kidoman/embd/motion/servo"
+
+	_ "github.com/kidoman/embd/host/all"
+)
+
+func main() {
+	flag.Parse()
+
+	if err := embd.InitI2C(); err != nil {
+		panic(err)
+	}
+	defer embd.CloseI2C()
+
+	bus := embd.NewI2CBus(1)
+
+	d := pca9685.New(bus, 0x41)
+	d.Freq = 50
+	defer d.Close()
+
+	gimbal := pantilt.New(servo.New(d.ServoChannel(0)), servo.New(d.ServoChannel(1)))
+	gimbal.TiltLimits = pantilt.Limits{Min: 45, Max: 135}
+	gimbal.Speed = 60
+
+	if err := gimbal.Center(); err != nil {
+		panic(err)
+	}
+	defer gimbal.Center()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, os.Kill)
+
+	sweepTimer := time.Tick(3 * time.Second)
+	left := true
+
+	for {
+		select {
+		case <-sweepTimer:
+			left = !left
+			switch left {
+			case true:
+				gimbal.MoveTo(pantilt.Position{Pan: 45, Tilt: 70})
+			case false:
+				gimbal.MoveTo(pantilt.Position{Pan: 135, Tilt: 110})
+			}
+		case <-c:
+			return
+		}
+	}
+}
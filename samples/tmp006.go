@@ -40,9 +40,9 @@ func main() {
 	for {
 		select {
 		case temp := <-sensor.ObjTemps():
-			fmt.Printf("tmp006: got obj temp %.2f\n", temp)
+			fmt.Printf("tmp006: got obj temp %v\n", temp)
 		case temp := <-sensor.RawDieTemps():
-			fmt.Printf("tmp006: got die temp %.2f\n", temp)
+			fmt.Printf("tmp006: got die temp %v\n", temp)
 		case <-stop:
 			return
 		}
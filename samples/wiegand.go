@@ -0,0 +1,109 @@
+// +build ignore
+
+// Door access control: badges read on a Wiegand reader are checked against a
+// list of allowed cards, the decision is shown on a character display, and a
+// relay on the door strike is energized to open the door.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/controller/hd44780"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+	"github.com/kidoman/embd/interface/keypad/wiegand"
+
+	_ "github.com/kidoman/embd/host/all"
+)
+
+var allowed = map[uint32]string{
+	45678: "Alice",
+	12345: "Bob",
+}
+
+func main() {
+	openFor := flag.Duration("open", 3*time.Second, "time to keep the door open for")
+	flag.Parse()
+
+	if err := embd.InitGPIO(); err != nil {
+		panic(err)
+	}
+	defer embd.CloseGPIO()
+
+	if err := embd.InitI2C(); err != nil {
+		panic(err)
+	}
+	defer embd.CloseI2C()
+
+	d0, err := embd.NewDigitalPin(17)
+	if err != nil {
+		panic(err)
+	}
+	defer d0.Close()
+	d1, err := embd.NewDigitalPin(27)
+	if err != nil {
+		panic(err)
+	}
+	defer d1.Close()
+
+	strike, err := embd.NewDigitalPin(22)
+	if err != nil {
+		panic(err)
+	}
+	defer strike.Close()
+	if err := strike.SetDirection(embd.Out); err != nil {
+		panic(err)
+	}
+	if err := strike.Write(embd.Low); err != nil {
+		panic(err)
+	}
+
+	controller, err := hd44780.NewI2C(
+		embd.NewI2CBus(1),
+		0x20,
+		hd44780.PCF8574PinMap,
+		hd44780.RowAddress16Col,
+		hd44780.TwoLine,
+	)
+	if err != nil {
+		panic(err)
+	}
+	display := characterdisplay.New(controller, 16, 2)
+	defer display.Close()
+
+	reader := wiegand.New(d0, d1)
+	if err := reader.Run(); err != nil {
+		panic(err)
+	}
+	defer reader.Close()
+
+	display.Clear()
+	display.Message("Present badge")
+
+	for code := range reader.Codes() {
+		if code.Key != 0 {
+			continue
+		}
+		name, ok := allowed[code.Card]
+		display.Clear()
+		if !ok {
+			fmt.Printf("denied %v\n", code)
+			display.Message(fmt.Sprintf("Access denied\n%v", code.Card))
+			time.Sleep(*openFor)
+		} else {
+			fmt.Printf("granted %v (%v)\n", code, name)
+			display.Message(fmt.Sprintf("Welcome\n%v", name))
+			if err := strike.Write(embd.High); err != nil {
+				panic(err)
+			}
+			time.Sleep(*openFor)
+			if err := strike.Write(embd.Low); err != nil {
+				panic(err)
+			}
+		}
+		display.Clear()
+		display.Message("Present badge")
+	}
+}
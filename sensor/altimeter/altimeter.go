@@ -0,0 +1,296 @@
+/*
+Package altimeter computes the altitude and vertical speed from any
+barometer, and detects pressure trends for weather stations.
+
+The altitude is relative to a sea level reference pressure (QNH), which is
+either set from a weather report or derived from a known altitude:
+
+	alt := altimeter.New(bmp)
+	alt.SetQNH(1013.2 * units.Hectopascal)
+	alt.Run()
+	defer alt.Close()
+
+	for e := range alt.Trends() {
+		lcd.Message(fmt.Sprintf("%v\n%v", e.Pressure, e.Trend))
+	}
+*/
+package altimeter
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/filter"
+	"github.com/kidoman/embd/sensor"
+	"github.com/kidoman/embd/units"
+)
+
+const (
+	pollDelay = time.Second
+
+	defaultTrendWindow    = 3 * time.Hour
+	defaultTrendThreshold = 1 * units.Hectopascal
+)
+
+// Trend is the tendency of the pressure.
+type Trend int
+
+// The pressure trends.
+const (
+	Steady Trend = iota
+	Rising
+	Falling
+)
+
+func (t Trend) String() string {
+	switch t {
+	case Rising:
+		return "rising"
+	case Falling:
+		return "falling"
+	default:
+		return "steady"
+	}
+}
+
+// TrendEvent is emitted when the pressure trend changes.
+type TrendEvent struct {
+	Time     time.Time
+	Trend    Trend
+	Pressure units.Pressure
+
+	// Change is the pressure change over the trend window.
+	Change units.Pressure
+}
+
+// Reading is a filtered altimeter reading.
+type Reading struct {
+	Time     time.Time
+	Pressure units.Pressure
+	Altitude units.Distance
+
+	// VerticalSpeed is in meters per second, positive when climbing.
+	VerticalSpeed float64
+}
+
+type sample struct {
+	t time.Time
+	p units.Pressure
+}
+
+// Altimeter computes the altitude from a barometer.
+type Altimeter struct {
+	Baro sensor.Barometer
+	Poll time.Duration
+
+	// AltitudeFilter and SpeedFilter smooth the altitude and the vertical
+	// speed. They default to Kalman filters.
+	AltitudeFilter, SpeedFilter filter.Filter
+
+	// The trend is rising or falling when the pressure changed by more
+	// than TrendThreshold over TrendWindow. They default to 1 hPa over 3
+	// hours.
+	TrendWindow    time.Duration
+	TrendThreshold units.Pressure
+
+	mu      sync.Mutex
+	qnh     units.Pressure
+	last    Reading
+	history []sample
+	trend   Trend
+
+	trends chan TrendEvent
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+// New creates a new altimeter reading pressure from baro, referenced to the
+// standard sea level pressure.
+func New(baro sensor.Barometer) *Altimeter {
+	return &Altimeter{
+		Baro:           baro,
+		Poll:           pollDelay,
+		AltitudeFilter: filter.NewKalman(0.05, 1),
+		SpeedFilter:    filter.NewKalman(0.01, 0.5),
+		TrendWindow:    defaultTrendWindow,
+		TrendThreshold: defaultTrendThreshold,
+		qnh:            units.StandardSeaLevel,
+		trends:         make(chan TrendEvent, 1),
+	}
+}
+
+// Altitude returns the altitude at which the pressure is p, given the sea
+// level pressure qnh, using the international barometric formula.
+func Altitude(p, qnh units.Pressure) units.Distance {
+	return units.Distance(44330 * (1 - math.Pow(float64(p/qnh), 0.190295)))
+}
+
+// QNH returns the sea level pressure for which the pressure at altitude h
+// is p.
+func QNH(p units.Pressure, h units.Distance) units.Pressure {
+	return p / units.Pressure(math.Pow(1-float64(h)/44330, 1/0.190295))
+}
+
+// SetQNH sets the sea level reference pressure.
+func (a *Altimeter) SetQNH(qnh units.Pressure) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.setQNH(qnh)
+}
+
+func (a *Altimeter) setQNH(qnh units.Pressure) {
+	glog.V(1).Infof("altimeter: qnh set to %v", qnh)
+	a.qnh = qnh
+	// The altitude jumps with the reference, restart the filters so the
+	// jump is not smoothed into a vertical speed.
+	a.AltitudeFilter.Reset()
+	a.SpeedFilter.Reset()
+	a.last.Time = time.Time{}
+}
+
+// QNH returns the sea level reference pressure.
+func (a *Altimeter) QNH() units.Pressure {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.qnh
+}
+
+// SetAltitude sets the sea level reference pressure from the known current
+// altitude h.
+func (a *Altimeter) SetAltitude(h units.Distance) error {
+	p, err := a.Baro.Pressure()
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.setQNH(QNH(p, h))
+	return nil
+}
+
+// Measure reads the barometer and returns the updated reading.
+func (a *Altimeter) Measure() (Reading, error) {
+	p, err := a.Baro.Pressure()
+	if err != nil {
+		return Reading{}, err
+	}
+	return a.update(p, time.Now()), nil
+}
+
+// Last returns the latest reading.
+func (a *Altimeter) Last() Reading {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.last
+}
+
+// Trend returns the current pressure trend.
+func (a *Altimeter) Trend() Trend {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.trend
+}
+
+// Trends returns a channel receiving an event whenever the pressure trend
+// changes. Events are dropped when the previous one was not received yet.
+func (a *Altimeter) Trends() <-chan TrendEvent {
+	return a.trends
+}
+
+func (a *Altimeter) update(p units.Pressure, t time.Time) Reading {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	r := Reading{
+		Time:     t,
+		Pressure: p,
+		Altitude: units.Distance(a.AltitudeFilter.Update(float64(Altitude(p, a.qnh)))),
+	}
+	if !a.last.Time.IsZero() {
+		if dt := t.Sub(a.last.Time).Seconds(); dt > 0 {
+			r.VerticalSpeed = a.SpeedFilter.Update(float64(r.Altitude-a.last.Altitude) / dt)
+		}
+	}
+	a.last = r
+
+	a.updateTrend(p, t)
+
+	return r
+}
+
+func (a *Altimeter) updateTrend(p units.Pressure, t time.Time) {
+	a.history = append(a.history, sample{t, p})
+	cutoff := t.Add(-a.TrendWindow)
+	i := 0
+	for i < len(a.history)-1 && a.history[i+1].t.Before(cutoff) {
+		i++
+	}
+	a.history = a.history[i:]
+
+	// Wait until the history covers the whole window.
+	oldest := a.history[0]
+	if oldest.t.After(cutoff) {
+		return
+	}
+
+	change := p - oldest.p
+	trend := Steady
+	switch {
+	case change >= a.TrendThreshold:
+		trend = Rising
+	case change <= -a.TrendThreshold:
+		trend = Falling
+	}
+	if trend == a.trend {
+		return
+	}
+	glog.V(1).Infof("altimeter: pressure %v (%v over %v)", trend, change, a.TrendWindow)
+	a.trend = trend
+
+	select {
+	case a.trends <- TrendEvent{Time: t, Trend: trend, Pressure: p, Change: change}:
+	default:
+	}
+}
+
+// Run starts measuring in the background, every Poll.
+func (a *Altimeter) Run() {
+	a.quit = make(chan struct{})
+	a.done = make(chan struct{})
+
+	go func() {
+		defer close(a.done)
+
+		ticker := time.NewTicker(a.Poll)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := a.Measure(); err != nil {
+					glog.Errorf("altimeter: %v", err)
+				}
+			case <-a.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops measuring.
+func (a *Altimeter) Close() {
+	if a.quit == nil {
+		return
+	}
+	close(a.quit)
+	<-a.done
+	a.quit = nil
+}
@@ -0,0 +1,94 @@
+package altimeter
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/filter"
+	"github.com/kidoman/embd/units"
+)
+
+type fakeBaro units.Pressure
+
+func (b *fakeBaro) Pressure() (units.Pressure, error) {
+	return units.Pressure(*b), nil
+}
+
+func TestAltitudeQNH(t *testing.T) {
+	if h := Altitude(units.StandardSeaLevel, units.StandardSeaLevel); h != 0 {
+		t.Errorf("altitude at sea level = %v, want 0", h)
+	}
+	// About 111m at 1000 hPa in the standard atmosphere.
+	h := Altitude(1000*units.Hectopascal, units.StandardSeaLevel)
+	if math.Abs(float64(h)-110.9) > 0.5 {
+		t.Errorf("altitude at 1000 hPa = %v, want about 110.9m", h)
+	}
+	if qnh := QNH(1000*units.Hectopascal, h); math.Abs(float64(qnh-units.StandardSeaLevel)) > 1e-6 {
+		t.Errorf("qnh = %v, want %v", qnh, units.StandardSeaLevel)
+	}
+}
+
+func TestSetAltitude(t *testing.T) {
+	baro := fakeBaro(950 * units.Hectopascal)
+	a := New(&baro)
+	if err := a.SetAltitude(500 * units.Meter); err != nil {
+		t.Fatal(err)
+	}
+	r, err := a.Measure()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(float64(r.Altitude)-500) > 1e-6 {
+		t.Errorf("altitude = %v, want 500m", r.Altitude)
+	}
+}
+
+func TestVerticalSpeed(t *testing.T) {
+	baro := fakeBaro(units.StandardSeaLevel)
+	a := New(&baro)
+	a.AltitudeFilter = filter.Func(func(x float64) float64 { return x })
+	a.SpeedFilter = filter.Func(func(x float64) float64 { return x })
+
+	start := time.Now()
+	a.update(units.StandardSeaLevel, start)
+	p := units.StandardSeaLevel / units.Pressure(math.Pow(1-10.0/44330, -1/0.190295))
+	r := a.update(p, start.Add(2*time.Second))
+	if math.Abs(r.VerticalSpeed-5) > 1e-6 {
+		t.Errorf("vertical speed = %v, want 5 m/s", r.VerticalSpeed)
+	}
+}
+
+func TestTrend(t *testing.T) {
+	baro := fakeBaro(units.StandardSeaLevel)
+	a := New(&baro)
+	a.TrendWindow = time.Hour
+
+	start := time.Now()
+	p := units.StandardSeaLevel
+	for i := 0; i <= 60; i++ {
+		a.update(p, start.Add(time.Duration(i)*time.Minute))
+	}
+	if a.Trend() != Steady {
+		t.Errorf("trend = %v, want steady", a.Trend())
+	}
+
+	// Falls by 3 hPa in the next hour.
+	for i := 1; i <= 60; i++ {
+		a.update(p-units.Pressure(i)*5*units.Pascal, start.Add(time.Duration(60+i)*time.Minute))
+	}
+	if a.Trend() != Falling {
+		t.Errorf("trend = %v, want falling", a.Trend())
+	}
+	select {
+	case e := <-a.Trends():
+		if e.Trend != Falling || e.Change > -units.Hectopascal {
+			t.Errorf("event = %+v, want falling by at least 1 hPa", e)
+		}
+	default:
+		t.Error("no trend event")
+	}
+	if n := len(a.history); n > 62 {
+		t.Errorf("history holds %v samples, want at most 62", n)
+	}
+}
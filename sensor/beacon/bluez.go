@@ -0,0 +1,187 @@
+// Scanning through BlueZ.
+
+package beacon
+
+import (
+	"strings"
+
+	"github.com/godbus/dbus"
+	"github.com/golang/glog"
+)
+
+const (
+	bluez            = "org.bluez"
+	adapterInterface = "org.bluez.Adapter1"
+	deviceInterface  = "org.bluez.Device1"
+
+	interfacesAdded   = "org.freedesktop.DBus.ObjectManager.InterfacesAdded"
+	propertiesChanged = "org.freedesktop.DBus.Properties.PropertiesChanged"
+
+	readingsBuffer = 32
+)
+
+// Scanner scans for sensor and beacon advertisements with the BlueZ
+// Bluetooth daemon, over D-Bus. The program needs access to the system bus.
+type Scanner struct {
+	// Adapter is the Bluetooth adapter to use, hci0 by default.
+	Adapter string
+
+	conn     *dbus.Conn
+	signals  chan *dbus.Signal
+	readings chan *Reading
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+// NewScanner creates a new Scanner.
+func NewScanner() *Scanner {
+	return &Scanner{Adapter: "hci0", readings: make(chan *Reading, readingsBuffer)}
+}
+
+// Readings returns the channel receiving the decoded advertisements, once
+// Run is called. Readings are dropped when they are not received. It is
+// closed by Close.
+func (s *Scanner) Readings() <-chan *Reading {
+	return s.readings
+}
+
+func (s *Scanner) adapter() dbus.BusObject {
+	return s.conn.Object(bluez, dbus.ObjectPath("/org/bluez/"+s.Adapter))
+}
+
+// Run starts scanning.
+func (s *Scanner) Run() error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+
+	for _, rule := range []string{
+		"type='signal',sender='org.bluez',interface='org.freedesktop.DBus.ObjectManager',member='InterfacesAdded'",
+		"type='signal',sender='org.bluez',interface='org.freedesktop.DBus.Properties',member='PropertiesChanged',arg0='org.bluez.Device1'",
+	} {
+		if err := conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, rule).Err; err != nil {
+			return err
+		}
+	}
+	s.signals = make(chan *dbus.Signal, readingsBuffer)
+	conn.Signal(s.signals)
+
+	// Sensors repeat the same advertisement with new readings, report
+	// them all instead of only the changes.
+	filter := map[string]dbus.Variant{
+		"Transport":     dbus.MakeVariant("le"),
+		"DuplicateData": dbus.MakeVariant(true),
+	}
+	if err := s.adapter().Call(adapterInterface+".SetDiscoveryFilter", 0, filter).Err; err != nil {
+		return err
+	}
+	if err := s.adapter().Call(adapterInterface+".StartDiscovery", 0).Err; err != nil {
+		return err
+	}
+
+	s.quit = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run()
+
+	return nil
+}
+
+func (s *Scanner) run() {
+	defer close(s.done)
+
+	for {
+		select {
+		case sig := <-s.signals:
+			props := deviceProperties(sig)
+			if props == nil {
+				continue
+			}
+			adv := advertisement(sig.Path, props)
+			r, ok := Decode(adv)
+			if !ok {
+				continue
+			}
+			glog.V(2).Infof("beacon: %v", r)
+			select {
+			case s.readings <- r:
+			default:
+				glog.V(1).Infof("beacon: dropping reading from %v", r.Addr)
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// deviceProperties returns the device properties carried by a signal.
+func deviceProperties(sig *dbus.Signal) map[string]dbus.Variant {
+	switch sig.Name {
+	case interfacesAdded:
+		if len(sig.Body) < 2 {
+			return nil
+		}
+		ifaces, _ := sig.Body[1].(map[string]map[string]dbus.Variant)
+		return ifaces[deviceInterface]
+	case propertiesChanged:
+		if len(sig.Body) < 2 {
+			return nil
+		}
+		if iface, _ := sig.Body[0].(string); iface != deviceInterface {
+			return nil
+		}
+		props, _ := sig.Body[1].(map[string]dbus.Variant)
+		return props
+	}
+	return nil
+}
+
+// advertisement builds an advertisement from the properties of the device
+// at path (/org/bluez/hci0/dev_A4_C1_38_00_11_22).
+func advertisement(path dbus.ObjectPath, props map[string]dbus.Variant) *Advertisement {
+	adv := &Advertisement{}
+	if i := strings.LastIndex(string(path), "/dev_"); i >= 0 {
+		adv.Addr = strings.Replace(string(path)[i+len("/dev_"):], "_", ":", -1)
+	}
+	if v, ok := props["Address"].Value().(string); ok {
+		adv.Addr = v
+	}
+	if v, ok := props["Name"].Value().(string); ok {
+		adv.Name = v
+	}
+	if v, ok := props["RSSI"].Value().(int16); ok {
+		adv.RSSI = int(v)
+	}
+	if data, ok := props["ServiceData"].Value().(map[string]dbus.Variant); ok {
+		adv.ServiceData = make(map[string][]byte)
+		for uuid, v := range data {
+			if b, ok := v.Value().([]byte); ok {
+				adv.ServiceData[strings.ToLower(uuid)] = b
+			}
+		}
+	}
+	if data, ok := props["ManufacturerData"].Value().(map[uint16]dbus.Variant); ok {
+		adv.ManufacturerData = make(map[uint16][]byte)
+		for id, v := range data {
+			if b, ok := v.Value().([]byte); ok {
+				adv.ManufacturerData[id] = b
+			}
+		}
+	}
+	return adv
+}
+
+// Close stops scanning and closes the readings channel.
+func (s *Scanner) Close() error {
+	if s.quit == nil {
+		return nil
+	}
+	close(s.quit)
+	<-s.done
+	s.quit = nil
+
+	s.conn.RemoveSignal(s.signals)
+	close(s.readings)
+	return s.adapter().Call(adapterInterface+".StopDiscovery", 0).Err
+}
@@ -0,0 +1,301 @@
+// Advertisement decoding.
+
+package beacon
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/kidoman/embd/units"
+)
+
+// Advertisement is the data broadcast by a device.
+type Advertisement struct {
+	Addr string
+	Name string
+	RSSI int
+
+	// ServiceData is keyed by the 128 bit service UUID, lower case.
+	ServiceData map[string][]byte
+
+	// ManufacturerData is keyed by the company id.
+	ManufacturerData map[uint16][]byte
+}
+
+// Format is the format of a decoded advertisement.
+type Format int
+
+// The supported formats.
+const (
+	// ATC is the custom firmware for Xiaomi thermometers by atc1441.
+	ATC Format = iota
+
+	// PVVX is the extended format of the custom firmware by pvvx.
+	PVVX
+
+	// MiBeacon is the stock Xiaomi format. Encrypted advertisements are
+	// not supported.
+	MiBeacon
+
+	IBeacon
+	Eddystone
+)
+
+func (f Format) String() string {
+	switch f {
+	case ATC:
+		return "atc"
+	case PVVX:
+		return "pvvx"
+	case MiBeacon:
+		return "mibeacon"
+	case IBeacon:
+		return "ibeacon"
+	case Eddystone:
+		return "eddystone"
+	default:
+		return "unknown"
+	}
+}
+
+// Field is a measurement held by a Reading.
+type Field int
+
+// The measurements.
+const (
+	Temperature Field = 1 << iota
+	Humidity
+	Battery
+	BatteryVoltage
+)
+
+// Reading is a decoded advertisement.
+type Reading struct {
+	Time   time.Time
+	Addr   string
+	Name   string
+	RSSI   int
+	Format Format
+
+	// Fields tells which measurements are set.
+	Fields Field
+
+	Temperature units.Temperature
+
+	// Humidity is the relative humidity in percent.
+	Humidity float64
+
+	// Battery is the battery level in percent.
+	Battery int
+
+	BatteryVoltage units.Voltage
+
+	// ID identifies iBeacons (uuid/major/minor) and Eddystone UID beacons
+	// (namespace/instance).
+	ID string
+
+	// TxPower is the calibrated power at 1m of iBeacons and Eddystone
+	// beacons, in dBm.
+	TxPower int
+}
+
+// Has reports whether the reading holds the measurement f.
+func (r *Reading) Has(f Field) bool {
+	return r.Fields&f != 0
+}
+
+func (r *Reading) String() string {
+	s := fmt.Sprintf("%v %v", r.Addr, r.Format)
+	if r.ID != "" {
+		s += " " + r.ID
+	}
+	if r.Has(Temperature) {
+		s += fmt.Sprintf(" %v", r.Temperature)
+	}
+	if r.Has(Humidity) {
+		s += fmt.Sprintf(" %.1f%%", r.Humidity)
+	}
+	if r.Has(Battery) {
+		s += fmt.Sprintf(" battery %v%%", r.Battery)
+	}
+	return s
+}
+
+// uuid16 returns the 128 bit UUID of a 16 bit service UUID.
+func uuid16(u uint16) string {
+	return fmt.Sprintf("%08x-0000-1000-8000-00805f9b34fb", u)
+}
+
+var (
+	environmentalSensing = uuid16(0x181A)
+	xiaomi               = uuid16(0xFE95)
+	eddystone            = uuid16(0xFEAA)
+)
+
+const apple = 0x004C
+
+// Decode decodes the sensor readings or beacon of an advertisement. It
+// returns false for unsupported advertisements.
+func Decode(adv *Advertisement) (*Reading, bool) {
+	r := &Reading{Time: time.Now(), Addr: adv.Addr, Name: adv.Name, RSSI: adv.RSSI}
+
+	var ok bool
+	if data, found := adv.ServiceData[environmentalSensing]; found {
+		ok = decodeCustom(r, data)
+	} else if data, found := adv.ServiceData[xiaomi]; found {
+		ok = decodeMiBeacon(r, data)
+	} else if data, found := adv.ServiceData[eddystone]; found {
+		ok = decodeEddystone(r, data)
+	} else if data, found := adv.ManufacturerData[apple]; found {
+		ok = decodeIBeacon(r, data)
+	}
+	if !ok {
+		return nil, false
+	}
+	return r, true
+}
+
+func decodeCustom(r *Reading, data []byte) bool {
+	switch len(data) {
+	case 13:
+		r.Format = ATC
+		r.Temperature = units.Temperature(int16(binary.BigEndian.Uint16(data[6:]))) / 10
+		r.Humidity = float64(data[8])
+		r.Battery = int(data[9])
+		r.BatteryVoltage = units.Voltage(binary.BigEndian.Uint16(data[10:])) * units.Millivolt
+	case 15:
+		r.Format = PVVX
+		r.Temperature = units.Temperature(int16(binary.LittleEndian.Uint16(data[6:]))) / 100
+		r.Humidity = float64(binary.LittleEndian.Uint16(data[8:])) / 100
+		r.BatteryVoltage = units.Voltage(binary.LittleEndian.Uint16(data[10:])) * units.Millivolt
+		r.Battery = int(data[12])
+	default:
+		return false
+	}
+	r.Fields = Temperature | Humidity | Battery | BatteryVoltage
+	return true
+}
+
+// MiBeacon frame control bits.
+const (
+	miEncrypted  = 0x0008
+	miMAC        = 0x0010
+	miCapability = 0x0020
+	miObject     = 0x0040
+
+	miIOCapability = 0x20
+)
+
+// MiBeacon object types.
+const (
+	miTemperature         = 0x1004
+	miHumidity            = 0x1006
+	miBattery             = 0x100A
+	miTemperatureHumidity = 0x100D
+)
+
+func decodeMiBeacon(r *Reading, data []byte) bool {
+	if len(data) < 5 {
+		return false
+	}
+	fc := binary.LittleEndian.Uint16(data)
+	if fc&miEncrypted != 0 || fc&miObject == 0 {
+		return false
+	}
+	i := 5
+	if fc&miMAC != 0 {
+		i += 6
+	}
+	if fc&miCapability != 0 {
+		if i >= len(data) {
+			return false
+		}
+		if data[i]&miIOCapability != 0 {
+			i += 2
+		}
+		i++
+	}
+
+	r.Format = MiBeacon
+	for i+3 <= len(data) {
+		typ := binary.LittleEndian.Uint16(data[i:])
+		n := int(data[i+2])
+		i += 3
+		if i+n > len(data) {
+			return false
+		}
+		v := data[i : i+n]
+		i += n
+
+		switch {
+		case typ == miTemperature && n == 2:
+			r.Temperature = units.Temperature(int16(binary.LittleEndian.Uint16(v))) / 10
+			r.Fields |= Temperature
+		case typ == miHumidity && n == 2:
+			r.Humidity = float64(binary.LittleEndian.Uint16(v)) / 10
+			r.Fields |= Humidity
+		case typ == miBattery && n == 1:
+			r.Battery = int(v[0])
+			r.Fields |= Battery
+		case typ == miTemperatureHumidity && n == 4:
+			r.Temperature = units.Temperature(int16(binary.LittleEndian.Uint16(v))) / 10
+			r.Humidity = float64(binary.LittleEndian.Uint16(v[2:])) / 10
+			r.Fields |= Temperature | Humidity
+		}
+	}
+	return r.Fields != 0
+}
+
+func decodeIBeacon(r *Reading, data []byte) bool {
+	if len(data) < 23 || data[0] != 0x02 || data[1] != 0x15 {
+		return false
+	}
+	u := hex.EncodeToString(data[2:18])
+	r.Format = IBeacon
+	r.ID = fmt.Sprintf("%v-%v-%v-%v-%v/%v/%v", u[:8], u[8:12], u[12:16], u[16:20], u[20:],
+		binary.BigEndian.Uint16(data[18:]), binary.BigEndian.Uint16(data[20:]))
+	r.TxPower = int(int8(data[22]))
+	return true
+}
+
+// Eddystone frame types.
+const (
+	eddystoneUID = 0x00
+	eddystoneTLM = 0x20
+
+	// tlmNoTemperature is the temperature of beacons without a sensor.
+	tlmNoTemperature = 0x8000
+)
+
+func decodeEddystone(r *Reading, data []byte) bool {
+	if len(data) < 1 {
+		return false
+	}
+	r.Format = Eddystone
+	switch data[0] {
+	case eddystoneUID:
+		if len(data) < 18 {
+			return false
+		}
+		r.TxPower = int(int8(data[1]))
+		r.ID = hex.EncodeToString(data[2:12]) + "/" + hex.EncodeToString(data[12:18])
+		return true
+	case eddystoneTLM:
+		if len(data) < 6 || data[1] != 0 {
+			return false
+		}
+		if mv := binary.BigEndian.Uint16(data[2:]); mv != 0 {
+			r.BatteryVoltage = units.Voltage(mv) * units.Millivolt
+			r.Fields |= BatteryVoltage
+		}
+		// Signed 8.8 fixed point.
+		if t := binary.BigEndian.Uint16(data[4:]); t != tlmNoTemperature {
+			r.Temperature = units.Temperature(int16(t)) / 256
+			r.Fields |= Temperature
+		}
+		return true
+	}
+	return false
+}
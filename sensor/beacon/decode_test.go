@@ -0,0 +1,110 @@
+package beacon
+
+import (
+	"math"
+	"testing"
+
+	"github.com/godbus/dbus"
+	"github.com/kidoman/embd/units"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestDecodeThermometers(t *testing.T) {
+	mac := []byte{0xA4, 0xC1, 0x38, 0x00, 0x11, 0x22}
+	tests := []struct {
+		name   string
+		adv    Advertisement
+		format Format
+		fields Field
+	}{
+		{
+			"atc",
+			Advertisement{ServiceData: map[string][]byte{
+				environmentalSensing: append(append([]byte{}, mac...), 0x00, 0xE1, 0x32, 0x5A, 0x0B, 0x54, 0x01),
+			}},
+			ATC, Temperature | Humidity | Battery | BatteryVoltage,
+		},
+		{
+			"pvvx",
+			Advertisement{ServiceData: map[string][]byte{
+				environmentalSensing: {0x22, 0x11, 0x00, 0x38, 0xC1, 0xA4, 0xCA, 0x08, 0x88, 0x13, 0x54, 0x0B, 0x5A, 0x01, 0x00},
+			}},
+			PVVX, Temperature | Humidity | Battery | BatteryVoltage,
+		},
+		{
+			"mibeacon",
+			Advertisement{ServiceData: map[string][]byte{
+				xiaomi: append(append([]byte{0x50, 0x50, 0x5B, 0x05, 0x01}, mac...), 0x0D, 0x10, 0x04, 0xE1, 0x00, 0xF4, 0x01),
+			}},
+			MiBeacon, Temperature | Humidity,
+		},
+	}
+	for _, test := range tests {
+		r, ok := Decode(&test.adv)
+		if !ok {
+			t.Errorf("%v: not decoded", test.name)
+			continue
+		}
+		if r.Format != test.format || r.Fields != test.fields {
+			t.Errorf("%v: format %v with fields %b; want %v with %b", test.name, r.Format, r.Fields, test.format, test.fields)
+		}
+		if !near(float64(r.Temperature), 22.5) || !near(r.Humidity, 50) {
+			t.Errorf("%v: %v", test.name, r)
+		}
+		if r.Has(Battery) && (r.Battery != 90 || !near(float64(r.BatteryVoltage), float64(2900*units.Millivolt))) {
+			t.Errorf("%v: battery %v%% %v", test.name, r.Battery, r.BatteryVoltage)
+		}
+	}
+}
+
+func TestDecodeEncryptedMiBeacon(t *testing.T) {
+	adv := &Advertisement{ServiceData: map[string][]byte{xiaomi: {0x58, 0x58, 0x5B, 0x05, 0x01, 0, 0, 0, 0, 0, 0, 0x0D, 0x10}}}
+	if _, ok := Decode(adv); ok {
+		t.Error("encrypted advertisement decoded")
+	}
+}
+
+func TestDecodeBeacons(t *testing.T) {
+	uuid := []byte{0xE2, 0xC5, 0x6D, 0xB5, 0xDF, 0xFB, 0x48, 0xD2, 0xB0, 0x60, 0xD0, 0xF5, 0xA7, 0x10, 0x96, 0xE0}
+	adv := &Advertisement{ManufacturerData: map[uint16][]byte{
+		apple: append(append([]byte{0x02, 0x15}, uuid...), 0x00, 0x01, 0x00, 0x02, 0xC5),
+	}}
+	r, ok := Decode(adv)
+	if !ok {
+		t.Fatal("ibeacon not decoded")
+	}
+	if r.ID != "e2c56db5-dffb-48d2-b060-d0f5a71096e0/1/2" || r.TxPower != -59 {
+		t.Errorf("ibeacon %v, tx power %v", r.ID, r.TxPower)
+	}
+
+	adv = &Advertisement{ServiceData: map[string][]byte{
+		eddystone: {0x20, 0x00, 0x0B, 0xB8, 0x16, 0x80, 0, 0, 0, 1, 0, 0, 0, 2},
+	}}
+	r, ok = Decode(adv)
+	if !ok {
+		t.Fatal("eddystone tlm not decoded")
+	}
+	if r.Fields != Temperature|BatteryVoltage || !near(float64(r.Temperature), 22.5) || !near(float64(r.BatteryVoltage), 3) {
+		t.Errorf("eddystone tlm %v, %v", r.Temperature, r.BatteryVoltage)
+	}
+}
+
+func TestAdvertisement(t *testing.T) {
+	props := map[string]dbus.Variant{
+		"Name": dbus.MakeVariant("ATC_001122"),
+		"RSSI": dbus.MakeVariant(int16(-70)),
+		"ServiceData": dbus.MakeVariant(map[string]dbus.Variant{
+			"0000181A-0000-1000-8000-00805F9B34FB": dbus.MakeVariant([]byte{1, 2}),
+		}),
+	}
+	adv := advertisement("/org/bluez/hci0/dev_A4_C1_38_00_11_22", props)
+	if adv.Addr != "A4:C1:38:00:11:22" || adv.Name != "ATC_001122" || adv.RSSI != -70 {
+		t.Errorf("advertisement = %+v", adv)
+	}
+	if len(adv.ServiceData[environmentalSensing]) != 2 {
+		t.Errorf("service data = %v", adv.ServiceData)
+	}
+}
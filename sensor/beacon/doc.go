@@ -0,0 +1,20 @@
+/*
+Package beacon decodes the advertisements of Bluetooth LE sensors and
+beacons: Xiaomi thermometers with the stock (MiBeacon) or custom (ATC, pvvx)
+firmware, iBeacons and Eddystone beacons.
+
+The Scanner receives the advertisements from the BlueZ daemon:
+
+	s := beacon.NewScanner()
+	if err := s.Run(); err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	for r := range s.Readings() {
+		if r.Has(beacon.Temperature) {
+			fmt.Printf("%v: %v\n", r.Name, r.Temperature)
+		}
+	}
+*/
+package beacon
@@ -9,11 +9,16 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/sensor"
+	"github.com/kidoman/embd/units"
 )
 
 const (
 	address = 0x77
 
+	chipIDReg = 0xD0
+	chipID    = 0x55
+
 	calAc1          = 0xAA
 	calAc2          = 0xAC
 	calAc3          = 0xAE
@@ -63,6 +68,59 @@ func New(bus embd.I2CBus) *BMP180 {
 	return &BMP180{Bus: bus, Poll: pollDelay}
 }
 
+// Identify verifies that a BMP180 is answering on the bus.
+func (d *BMP180) Identify() error {
+	return sensor.VerifyID(d.Bus, "bmp180", address, chipIDReg, chipID)
+}
+
+// SelfTest verifies the chip id and the calibration coefficients, and
+// checks that the temperature and pressure readings are within the range of
+// the sensor.
+func (d *BMP180) SelfTest() (*sensor.Diagnostics, error) {
+	diag := &sensor.Diagnostics{Chip: "bmp180"}
+
+	err := d.Identify()
+	if _, ok := err.(*sensor.ChipIDError); err != nil && !ok {
+		return nil, err
+	}
+	diag.AddErr("chip id", err)
+	if err != nil {
+		return diag, nil
+	}
+
+	// None of the calibration words may be 0x0000 or 0xFFFF.
+	cal := make([]byte, calMD+2-calAc1)
+	if err := d.Bus.ReadFromReg(address, calAc1, cal); err != nil {
+		return nil, err
+	}
+	valid := true
+	for i := 0; i < len(cal); i += 2 {
+		if w := uint16(cal[i])<<8 | uint16(cal[i+1]); w == 0x0000 || w == 0xFFFF {
+			diag.Add("calibration", false, "coefficient at %#02x is %#04x", calAc1+i, w)
+			valid = false
+			break
+		}
+	}
+	if !valid {
+		return diag, nil
+	}
+	diag.Add("calibration", true, "")
+
+	temp, err := d.Temperature()
+	if err != nil {
+		return nil, err
+	}
+	diag.Add("temperature", temp >= -40 && temp <= 85, "%v", temp)
+
+	pressure, err := d.Pressure()
+	if err != nil {
+		return nil, err
+	}
+	diag.Add("pressure", pressure >= 300*units.Hectopascal && pressure <= 1100*units.Hectopascal, "%v", pressure)
+
+	return diag, nil
+}
+
 func (d *BMP180) calibrate() error {
 	d.cmu.RLock()
 	if d.calibrated {
@@ -74,6 +132,10 @@ func (d *BMP180) calibrate() error {
 	d.cmu.Lock()
 	defer d.cmu.Unlock()
 
+	if err := d.Identify(); err != nil {
+		return err
+	}
+
 	readInt16 := func(reg byte) (int16, error) {
 		v, err := d.Bus.ReadWordFromReg(address, reg)
 		if err != nil {
@@ -195,10 +257,10 @@ func (d *BMP180) measureTemp() (uint16, error) {
 }
 
 // Temperature returns the current temperature reading.
-func (d *BMP180) Temperature() (float64, error) {
+func (d *BMP180) Temperature() (units.Temperature, error) {
 	select {
 	case t := <-d.temps:
-		temp := float64(t) / 10
+		temp := units.Temperature(t) / 10
 		return temp, nil
 	default:
 		glog.V(1).Infof("bcm085: no temps available... measuring")
@@ -206,7 +268,7 @@ func (d *BMP180) Temperature() (float64, error) {
 		if err != nil {
 			return 0, err
 		}
-		temp := float64(t) / 10
+		temp := units.Temperature(t) / 10
 		return temp, nil
 	}
 }
@@ -304,40 +366,40 @@ func (d *BMP180) measurePressureAndAltitude() (int32, float64, error) {
 }
 
 // Pressure returns the current pressure reading.
-func (d *BMP180) Pressure() (int, error) {
+func (d *BMP180) Pressure() (units.Pressure, error) {
 	if err := d.calibrate(); err != nil {
 		return 0, err
 	}
 
 	select {
 	case p := <-d.pressures:
-		return int(p), nil
+		return units.Pressure(p), nil
 	default:
 		glog.V(1).Infof("bcm085: no pressures available... measuring")
 		p, _, err := d.measurePressureAndAltitude()
 		if err != nil {
 			return 0, err
 		}
-		return int(p), nil
+		return units.Pressure(p), nil
 	}
 }
 
 // Altitude returns the current altitude reading.
-func (d *BMP180) Altitude() (float64, error) {
+func (d *BMP180) Altitude() (units.Distance, error) {
 	if err := d.calibrate(); err != nil {
 		return 0, err
 	}
 
 	select {
 	case altitude := <-d.altitudes:
-		return altitude, nil
+		return units.Distance(altitude), nil
 	default:
 		glog.V(1).Info("bcm085: no altitudes available... measuring")
 		_, altitude, err := d.measurePressureAndAltitude()
 		if err != nil {
 			return 0, err
 		}
-		return altitude, nil
+		return units.Distance(altitude), nil
 	}
 }
 
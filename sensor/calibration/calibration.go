@@ -0,0 +1,239 @@
+/*
+Package calibration provides interactive calibration routines for
+magnetometers and accelerometers.
+
+The routines collect samples while guiding the user through the required
+movements with a Progress hook, compute the corrections, which can be saved
+and loaded, and handed to the drivers which apply them transparently:
+
+	lcd := characterdisplay.New(hd, 16, 2)
+	progress := calibration.DisplayProgress(lcd.Region(0, 0, 16), lcd.Region(0, 1, 16))
+
+	cal, err := calibration.CalibrateMagnetometer(mag.MagneticField, 500, 20*time.Millisecond, progress)
+	if err != nil {
+		panic(err)
+	}
+	calibration.Save("mag.json", cal)
+	mag.Calibration = cal
+*/
+package calibration
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"time"
+
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+)
+
+// Vector is a three axis reading.
+type Vector [3]float64
+
+// Step describes the progress of a calibration.
+type Step struct {
+	// Instruction tells the user what to do.
+	Instruction string
+
+	// Done out of Total samples were collected for the instruction.
+	Done, Total int
+}
+
+// Progress is called as a calibration proceeds.
+type Progress func(s Step)
+
+// DisplayProgress returns a Progress hook writing the instructions to a
+// region of a character display and the number of collected samples to
+// another.
+func DisplayProgress(instruction, status *characterdisplay.Region) Progress {
+	return func(s Step) {
+		instruction.Write(s.Instruction)
+		status.Write(fmt.Sprintf("%v/%v", s.Done, s.Total))
+	}
+}
+
+// Confirm is called when the user must put the sensor in a given position,
+// and returns once it is in place (after a key press, for instance).
+type Confirm func(instruction string) error
+
+// Delay returns a Confirm hook which gives the user d to comply.
+func Delay(d time.Duration) Confirm {
+	return func(string) error {
+		time.Sleep(d)
+		return nil
+	}
+}
+
+// Mag is a magnetometer calibration. Readings are corrected by removing
+// the hard iron offset, then applying the soft iron matrix.
+type Mag struct {
+	Offset Vector        `json:"offset"`
+	Matrix [3][3]float64 `json:"matrix"`
+}
+
+// Apply corrects a reading.
+func (c *Mag) Apply(v Vector) Vector {
+	var d, out Vector
+	for i := range v {
+		d[i] = v[i] - c.Offset[i]
+	}
+	for i := range out {
+		for j := range d {
+			out[i] += c.Matrix[i][j] * d[j]
+		}
+	}
+	return out
+}
+
+// ErrTooFewSamples is returned when a fit can not be computed.
+var ErrTooFewSamples = errors.New("calibration: too few samples or no movement")
+
+// FitMag computes a magnetometer calibration from readings taken while
+// rotating the sensor in all directions. The hard iron offset is the center
+// of the readings, and the soft iron correction scales each axis to the same
+// average radius.
+func FitMag(samples []Vector) (*Mag, error) {
+	if len(samples) < 6 {
+		return nil, ErrTooFewSamples
+	}
+	min, max := samples[0], samples[0]
+	for _, s := range samples[1:] {
+		for i := range s {
+			min[i] = math.Min(min[i], s[i])
+			max[i] = math.Max(max[i], s[i])
+		}
+	}
+
+	c := &Mag{}
+	var radius Vector
+	var avg float64
+	for i := range c.Offset {
+		c.Offset[i] = (max[i] + min[i]) / 2
+		radius[i] = (max[i] - min[i]) / 2
+		if radius[i] == 0 {
+			return nil, ErrTooFewSamples
+		}
+		avg += radius[i] / 3
+	}
+	for i := range c.Matrix {
+		c.Matrix[i][i] = avg / radius[i]
+	}
+	return c, nil
+}
+
+// CalibrateMagnetometer collects n readings, one every interval, while the
+// user rotates the sensor in all directions, and fits a calibration to them.
+func CalibrateMagnetometer(read func() (Vector, error), n int, interval time.Duration, progress Progress) (*Mag, error) {
+	const instruction = "Rotate slowly in all directions"
+	samples := make([]Vector, 0, n)
+	for i := 0; i < n; i++ {
+		if progress != nil {
+			progress(Step{Instruction: instruction, Done: i, Total: n})
+		}
+		v, err := read()
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, v)
+		time.Sleep(interval)
+	}
+	if progress != nil {
+		progress(Step{Instruction: "Done", Done: n, Total: n})
+	}
+	return FitMag(samples)
+}
+
+// Accel is an accelerometer calibration. Readings are corrected by removing
+// the offset, then applying the scale, giving values in g.
+type Accel struct {
+	Offset Vector `json:"offset"`
+	Scale  Vector `json:"scale"`
+}
+
+// Apply corrects a reading.
+func (c *Accel) Apply(v Vector) Vector {
+	var out Vector
+	for i := range v {
+		out[i] = (v[i] - c.Offset[i]) * c.Scale[i]
+	}
+	return out
+}
+
+// Positions are the six positions of the accelerometer calibration, in
+// the order of the readings passed to FitAccel.
+var Positions = [6]string{
+	"X axis up", "X axis down",
+	"Y axis up", "Y axis down",
+	"Z axis up", "Z axis down",
+}
+
+// FitAccel computes an accelerometer calibration from the average readings
+// in each of the six Positions, where gravity is measured as +1g and -1g on
+// each axis in turn.
+func FitAccel(readings [6]Vector) (*Accel, error) {
+	c := &Accel{}
+	for i := range c.Offset {
+		up, down := readings[2*i][i], readings[2*i+1][i]
+		if up <= down {
+			return nil, fmt.Errorf("calibration: %v reads %v, not above %v reading %v", Positions[2*i], up, Positions[2*i+1], down)
+		}
+		c.Offset[i] = (up + down) / 2
+		c.Scale[i] = 2 / (up - down)
+	}
+	return c, nil
+}
+
+// CalibrateAccelerometer asks the user to hold the sensor still in each of
+// the six Positions in turn, averages n readings in each, and fits a
+// calibration to them.
+func CalibrateAccelerometer(read func() (Vector, error), n int, confirm Confirm, progress Progress) (*Accel, error) {
+	var readings [6]Vector
+	for p, position := range Positions {
+		instruction := "Hold still, " + position
+		if progress != nil {
+			progress(Step{Instruction: instruction, Done: 0, Total: n})
+		}
+		if confirm != nil {
+			if err := confirm(instruction); err != nil {
+				return nil, err
+			}
+		}
+		var sum Vector
+		for i := 0; i < n; i++ {
+			v, err := read()
+			if err != nil {
+				return nil, err
+			}
+			for j := range v {
+				sum[j] += v[j]
+			}
+			if progress != nil {
+				progress(Step{Instruction: instruction, Done: i + 1, Total: n})
+			}
+		}
+		for j := range sum {
+			readings[p][j] = sum[j] / float64(n)
+		}
+	}
+	return FitAccel(readings)
+}
+
+// Save saves a calibration as JSON.
+func Save(path string, cal interface{}) error {
+	data, err := json.MarshalIndent(cal, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Load loads a calibration saved by Save into cal.
+func Load(path string, cal interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, cal)
+}
@@ -0,0 +1,133 @@
+package calibration
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func near(a, b Vector, tol float64) bool {
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFitMag(t *testing.T) {
+	// Readings on an ellipsoid centered on (100, -50, 20) with radii
+	// (300, 200, 250).
+	offset := Vector{100, -50, 20}
+	radius := Vector{300, 200, 250}
+	var samples []Vector
+	for i := range radius {
+		for _, sign := range []float64{1, -1} {
+			s := offset
+			s[i] += sign * radius[i]
+			samples = append(samples, s)
+		}
+	}
+
+	c, err := FitMag(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Offset != offset {
+		t.Errorf("offset = %v, want %v", c.Offset, offset)
+	}
+	for i, s := range samples {
+		got := c.Apply(s)
+		var r float64
+		for _, v := range got {
+			r += v * v
+		}
+		if math.Abs(math.Sqrt(r)-250) > 1e-9 {
+			t.Errorf("sample %v: corrected to %v, radius %v, want 250", i, got, math.Sqrt(r))
+		}
+	}
+}
+
+func TestFitMagNoMovement(t *testing.T) {
+	samples := make([]Vector, 10)
+	if _, err := FitMag(samples); err != ErrTooFewSamples {
+		t.Errorf("err = %v, want %v", err, ErrTooFewSamples)
+	}
+}
+
+func TestCalibrateAccelerometer(t *testing.T) {
+	offset := Vector{0.05, -0.02, 0.1}
+	scale := Vector{1.1, 0.9, 1.05}
+
+	var position int
+	confirm := func(string) error {
+		position++
+		return nil
+	}
+	read := func() (Vector, error) {
+		var v Vector
+		i := (position - 1) / 2
+		v[i] = 1
+		if (position-1)%2 == 1 {
+			v[i] = -1
+		}
+		for j := range v {
+			v[j] = v[j]/scale[j] + offset[j]
+		}
+		return v, nil
+	}
+	var steps int
+	progress := func(s Step) {
+		steps++
+		if s.Done > s.Total {
+			t.Errorf("progress %v/%v", s.Done, s.Total)
+		}
+	}
+
+	c, err := CalibrateAccelerometer(read, 4, confirm, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(c.Offset, offset, 1e-9) || !near(c.Scale, scale, 1e-9) {
+		t.Errorf("calibration = %+v, want offset %v and scale %v", c, offset, scale)
+	}
+	if steps != 6*5 {
+		t.Errorf("progress called %v times, want %v", steps, 6*5)
+	}
+	if got := c.Apply(Vector{offset[0], 1/scale[1] + offset[1], offset[2]}); !near(got, Vector{0, 1, 0}, 1e-9) {
+		t.Errorf("Apply = %v, want [0 1 0]", got)
+	}
+}
+
+func TestFitAccelSwapped(t *testing.T) {
+	var readings [6]Vector
+	for i := range readings {
+		readings[i][i/2] = 1
+	}
+	if _, err := FitAccel(readings); err == nil {
+		t.Error("expected an error for positions reading the same")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "calibration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mag.json")
+
+	want := &Mag{Offset: Vector{1, 2, 3}, Matrix: [3][3]float64{{1, 0, 0}, {0, 2, 0}, {0, 0, 3}}}
+	if err := Save(path, want); err != nil {
+		t.Fatal(err)
+	}
+	got := &Mag{}
+	if err := Load(path, got); err != nil {
+		t.Fatal(err)
+	}
+	if *got != *want {
+		t.Errorf("loaded %+v, want %+v", got, want)
+	}
+}
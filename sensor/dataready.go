@@ -0,0 +1,133 @@
+// Data ready signaling.
+
+package sensor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// DataReady signals when a sensor has a new sample, from its data ready
+// (DRDY or INT) pin when one is wired, or from a timer otherwise. Drivers
+// read a sample for every value received on C:
+//
+//	dr, err := sensor.NewDataReady(d.DataReady, embd.EdgeRising, pollDelay)
+//	if err != nil {
+//		return err
+//	}
+//	defer dr.Close()
+//
+//	for t := range dr.C {
+//		// read the sample taken at t
+//	}
+type DataReady struct {
+	// C receives the time at which a sample became ready. It is closed by
+	// Close.
+	C <-chan time.Time
+
+	c      chan time.Time
+	pin    embd.DigitalPin
+	ticker *time.Ticker
+
+	mu     sync.Mutex
+	missed int
+	closed bool
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+// NewDataReady watches pin for the given edge. If pin is nil, a sample is
+// signaled every interval instead.
+func NewDataReady(pin embd.DigitalPin, edge embd.Edge, interval time.Duration) (*DataReady, error) {
+	c := make(chan time.Time, 1)
+	d := &DataReady{C: c, c: c, pin: pin}
+
+	if pin == nil {
+		d.ticker = time.NewTicker(interval)
+		d.quit = make(chan struct{})
+		d.done = make(chan struct{})
+		go d.tick()
+		return d, nil
+	}
+
+	if err := pin.SetDirection(embd.In); err != nil {
+		return nil, err
+	}
+	if err := pin.Watch(edge, func(embd.DigitalPin) {
+		d.signal(time.Now())
+	}); err != nil {
+		return nil, err
+	}
+
+	// A sample which became ready before the watch started leaves the pin
+	// asserted without an edge to come until it is read.
+	active := embd.High
+	if edge == embd.EdgeFalling {
+		active = embd.Low
+	}
+	if v, err := pin.Read(); err == nil && v == active {
+		d.signal(time.Now())
+	}
+
+	return d, nil
+}
+
+func (d *DataReady) tick() {
+	defer close(d.done)
+	for {
+		select {
+		case t := <-d.ticker.C:
+			d.signal(t)
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+func (d *DataReady) signal(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+	select {
+	case d.c <- t:
+	default:
+		// The previous sample was not read yet.
+		d.missed++
+		glog.V(2).Infof("sensor: data ready signal missed (%v so far)", d.missed)
+	}
+}
+
+// Missed returns the number of samples signaled while the previous one was
+// still waiting to be read.
+func (d *DataReady) Missed() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.missed
+}
+
+// Close stops signaling and closes C.
+func (d *DataReady) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	close(d.c)
+	d.mu.Unlock()
+
+	if d.ticker != nil {
+		d.ticker.Stop()
+		close(d.quit)
+		<-d.done
+		return nil
+	}
+	return d.pin.StopWatching()
+}
@@ -0,0 +1,91 @@
+package sensor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+)
+
+type fakePin struct {
+	embd.DigitalPin
+	val     int
+	handler func(embd.DigitalPin)
+	stopped bool
+}
+
+func (p *fakePin) SetDirection(embd.Direction) error { return nil }
+func (p *fakePin) Read() (int, error)                { return p.val, nil }
+func (p *fakePin) StopWatching() error               { p.stopped = true; return nil }
+
+func (p *fakePin) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
+	p.handler = handler
+	return nil
+}
+
+func TestDataReady_pin(t *testing.T) {
+	pin := &fakePin{}
+	dr, err := NewDataReady(pin, embd.EdgeRising, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-dr.C:
+		t.Fatal("got a sample before the pin was asserted")
+	default:
+	}
+
+	pin.handler(pin)
+	pin.handler(pin)
+	select {
+	case <-dr.C:
+	default:
+		t.Fatal("no sample after an edge")
+	}
+	if n := dr.Missed(); n != 1 {
+		t.Errorf("Missed() = %v; want 1", n)
+	}
+
+	if err := dr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !pin.stopped {
+		t.Error("Close did not stop watching the pin")
+	}
+	if _, ok := <-dr.C; ok {
+		t.Error("C is not closed")
+	}
+	pin.handler(pin)
+}
+
+func TestDataReady_alreadyAsserted(t *testing.T) {
+	dr, err := NewDataReady(&fakePin{val: embd.High}, embd.EdgeRising, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dr.Close()
+
+	select {
+	case <-dr.C:
+	default:
+		t.Fatal("no sample for a pin asserted before watching")
+	}
+}
+
+func TestDataReady_timer(t *testing.T) {
+	dr, err := NewDataReady(nil, embd.EdgeRising, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-dr.C:
+	case <-time.After(time.Second):
+		t.Fatal("no sample from the timer")
+	}
+	if err := dr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dr.Close()
+}
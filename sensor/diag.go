@@ -0,0 +1,123 @@
+// Chip identification and self-test diagnostics.
+
+package sensor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// ChipIDError is returned when the identification registers of a chip do not
+// hold the expected value, which means that a different chip, or none, is
+// answering at the address.
+type ChipIDError struct {
+	Chip      string
+	Addr, Reg byte
+	Want, Got []byte
+}
+
+func (e *ChipIDError) Error() string {
+	return fmt.Sprintf("%v: unexpected chip id %#x in register %#02x at address %#02x, want %#x (wrong chip or address?)", e.Chip, e.Got, e.Reg, e.Addr, e.Want)
+}
+
+// An Identifier is a driver which can verify the chip it talks to, by
+// reading its chip id or WHO_AM_I registers. Drivers also do this when
+// initializing the chip.
+type Identifier interface {
+	Identify() error
+}
+
+// VerifyID reads len(want) bytes from reg and returns a *ChipIDError if they
+// differ from want. The check is skipped in dry run mode.
+func VerifyID(bus embd.I2CBus, chip string, addr, reg byte, want ...byte) error {
+	if embd.DryRun() {
+		return nil
+	}
+	got := make([]byte, len(want))
+	if err := bus.ReadFromReg(addr, reg, got); err != nil {
+		return err
+	}
+	glog.V(1).Infof("%v: got chip id %#x", chip, got)
+	if !bytes.Equal(got, want) {
+		return &ChipIDError{Chip: chip, Addr: addr, Reg: reg, Want: want, Got: got}
+	}
+	return nil
+}
+
+// Check is the outcome of a single self-test check.
+type Check struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// Diagnostics is the report of a self-test.
+type Diagnostics struct {
+	Chip   string
+	Checks []Check
+}
+
+// Add records the outcome of a check.
+func (d *Diagnostics) Add(name string, passed bool, format string, args ...interface{}) {
+	d.Checks = append(d.Checks, Check{Name: name, Passed: passed, Detail: fmt.Sprintf(format, args...)})
+}
+
+// AddErr records the outcome of a check which passed if err is nil.
+func (d *Diagnostics) AddErr(name string, err error) {
+	c := Check{Name: name, Passed: err == nil}
+	if err != nil {
+		c.Detail = err.Error()
+	}
+	d.Checks = append(d.Checks, c)
+}
+
+// Passed returns true if all the checks passed.
+func (d *Diagnostics) Passed() bool {
+	for _, c := range d.Checks {
+		if !c.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// Err returns an error listing the failed checks, or nil if all of them
+// passed.
+func (d *Diagnostics) Err() error {
+	var failed []string
+	for _, c := range d.Checks {
+		if !c.Passed {
+			failed = append(failed, fmt.Sprintf("%v (%v)", c.Name, c.Detail))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%v: self-test failed: %v", d.Chip, strings.Join(failed, ", "))
+}
+
+func (d *Diagnostics) String() string {
+	s := d.Chip + ":"
+	for _, c := range d.Checks {
+		status := "ok"
+		if !c.Passed {
+			status = "FAILED"
+		}
+		s += fmt.Sprintf("\n  %v: %v", c.Name, status)
+		if c.Detail != "" {
+			s += " (" + c.Detail + ")"
+		}
+	}
+	return s
+}
+
+// A SelfTester is a driver which can run the self-test sequence of its chip.
+// SelfTest returns an error only if the test could not be run; the outcome
+// of the checks is in the diagnostics.
+type SelfTester interface {
+	SelfTest() (*Diagnostics, error)
+}
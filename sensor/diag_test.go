@@ -0,0 +1,60 @@
+package sensor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+type fakeBus struct {
+	embd.I2CBus
+	regs map[byte][]byte
+}
+
+func (b *fakeBus) ReadFromReg(addr, reg byte, value []byte) error {
+	copy(value, b.regs[reg])
+	return nil
+}
+
+func TestVerifyID(t *testing.T) {
+	bus := &fakeBus{regs: map[byte][]byte{0x0A: []byte("H43")}}
+	if err := VerifyID(bus, "lsm303", 0x1E, 0x0A, 'H', '4', '3'); err != nil {
+		t.Errorf("VerifyID() = %v; want nil", err)
+	}
+
+	err := VerifyID(bus, "bmp180", 0x77, 0xD0, 0x55)
+	e, ok := err.(*ChipIDError)
+	if !ok {
+		t.Fatalf("VerifyID() = %v; want a *ChipIDError", err)
+	}
+	if e.Got[0] != 0 || e.Want[0] != 0x55 || e.Reg != 0xD0 || e.Addr != 0x77 {
+		t.Errorf("VerifyID() = %+v", e)
+	}
+}
+
+func TestVerifyID_dryRun(t *testing.T) {
+	embd.SetDryRun(true)
+	defer embd.SetDryRun(false)
+
+	if err := VerifyID(&fakeBus{}, "bmp180", 0x77, 0xD0, 0x55); err != nil {
+		t.Errorf("VerifyID() = %v in dry run mode; want nil", err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	diag := &Diagnostics{Chip: "test"}
+	diag.AddErr("chip id", nil)
+	diag.Add("temperature", true, "%v", 21)
+	if !diag.Passed() || diag.Err() != nil {
+		t.Fatalf("Passed() = %v, Err() = %v; want true, nil", diag.Passed(), diag.Err())
+	}
+
+	diag.Add("pressure", false, "%v hPa", 12)
+	if diag.Passed() {
+		t.Error("Passed() = true with a failed check")
+	}
+	if err := diag.Err(); err == nil || !strings.Contains(err.Error(), "pressure (12 hPa)") {
+		t.Errorf("Err() = %v; want the failed check", err)
+	}
+}
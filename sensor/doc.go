@@ -1,2 +1,12 @@
 // Package sensor contains the various sensors modules for use on your platform.
+//
+// Drivers verify the chip id of their sensor when initializing it, returning
+// a *ChipIDError when a different chip answers. Drivers implementing
+// SelfTester also run the self-test sequence of the chip:
+//
+//	diag, err := baro.SelfTest()
+//	if err != nil {
+//		panic(err)
+//	}
+//	fmt.Println(diag)
 package sensor
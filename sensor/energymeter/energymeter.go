@@ -0,0 +1,297 @@
+/*
+Package energymeter allows reading energy meters from their S0 impulse
+output, or from the LED which blinks for every impulse on their front.
+
+	meter := energymeter.New(pin, 1000)
+	meter.TotalPath = "/var/lib/meter/total"
+	meter.Run()
+	defer meter.Close()
+
+	http.Handle("/metrics", meter)
+
+The readings can also be published to MQTT through any client, by adapting it
+to the Publisher interface:
+
+	type pahoPublisher struct{ mqtt.Client }
+
+	func (p pahoPublisher) Publish(topic string, payload []byte) error {
+		t := p.Client.Publish(topic, 0, true, payload)
+		t.Wait()
+		return t.Error()
+	}
+
+	meter.Publisher = pahoPublisher{client}
+	meter.Topic = "home/meter"
+*/
+package energymeter
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/health"
+	"github.com/kidoman/embd/sensor"
+)
+
+const (
+	pollDelay = 10 * time.Second
+
+	// The LED of a meter blinks for a few milliseconds.
+	opticalPollDelay = 2 * time.Millisecond
+)
+
+// Publisher publishes readings, to an MQTT broker for instance.
+type Publisher interface {
+	Publish(topic string, payload []byte) error
+}
+
+// EnergyMeter represents an energy meter.
+type EnergyMeter struct {
+	// ImpulsesPerKWh is the meter constant, printed on its front (often
+	// 1000 or 800 imp/kWh).
+	ImpulsesPerKWh float64
+
+	// Name labels the exported metrics, and the meter in the health
+	// registry as "energymeter/" followed by the name.
+	Name string
+
+	// TotalPath, if set, is the file in which the total energy is persisted
+	// across restarts. It is loaded on first use and written every
+	// FlushEvery while running, and by Close.
+	TotalPath string
+
+	// FlushEvery is the interval between writes of the total energy while
+	// running, sensor.DefaultFlushEvery by default.
+	FlushEvery time.Duration
+
+	// Poll is the interval between power measurements while running.
+	Poll time.Duration
+
+	// Publisher, if set, receives the power in W on Topic/power and the
+	// energy in kWh on Topic/energy every Poll while running.
+	Publisher Publisher
+	Topic     string
+
+	newCounter func() (*sensor.PulseCounter, error)
+
+	initialized bool
+	mu          sync.RWMutex
+
+	counter *sensor.PulseCounter
+	totals  *sensor.Totalizer
+	last    uint64
+	power   float64
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// New creates a new energy meter reading the S0 output wired to pin. The S0
+// output is an open collector, so the pin needs a pull-up.
+func New(pin embd.DigitalPin, impulsesPerKWh float64) *EnergyMeter {
+	return newMeter(impulsesPerKWh, func() (*sensor.PulseCounter, error) {
+		// The S0 output pulls the pin low for every impulse.
+		return sensor.NewPulseCounter(pin, embd.EdgeFalling)
+	})
+}
+
+// NewOptical creates a new energy meter reading the impulse LED of the meter
+// through a photodiode wired to an analog pin. The LED is on when the value
+// rises above high, and off when it falls back below low.
+func NewOptical(pin embd.AnalogPin, low, high int, impulsesPerKWh float64) *EnergyMeter {
+	return newMeter(impulsesPerKWh, func() (*sensor.PulseCounter, error) {
+		return sensor.NewAnalogPulseCounter(pin, low, high, embd.EdgeRising, opticalPollDelay)
+	})
+}
+
+func newMeter(impulsesPerKWh float64, newCounter func() (*sensor.PulseCounter, error)) *EnergyMeter {
+	return &EnergyMeter{
+		ImpulsesPerKWh: impulsesPerKWh,
+		Name:           "meter",
+		Poll:           pollDelay,
+		newCounter:     newCounter,
+	}
+}
+
+func (d *EnergyMeter) setup() error {
+	d.mu.RLock()
+	if d.initialized {
+		d.mu.RUnlock()
+		return nil
+	}
+	d.mu.RUnlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	totals, err := sensor.OpenTotalizer(d.TotalPath)
+	if err != nil {
+		return err
+	}
+	glog.V(1).Infof("energymeter: loaded total of %v kWh", totals)
+
+	counter, err := d.newCounter()
+	if err != nil {
+		return err
+	}
+	d.counter = counter
+	d.last = counter.Count()
+
+	totals.Sync = func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		d.sync()
+	}
+	if d.FlushEvery > 0 {
+		totals.FlushEvery = d.FlushEvery
+	}
+	totals.HealthName = d.healthName() + "/total"
+	d.totals = totals
+
+	d.initialized = true
+
+	return nil
+}
+
+// Power returns the power in watts, averaged since the previous call.
+func (d *EnergyMeter) Power() (float64, error) {
+	if err := d.setup(); err != nil {
+		return 0, err
+	}
+
+	m := d.counter.Measure()
+	power := m.Frequency * 3600 * 1000 / d.ImpulsesPerKWh
+
+	d.mu.Lock()
+	d.power = power
+	d.mu.Unlock()
+
+	return power, nil
+}
+
+// Energy returns the total energy in kWh.
+func (d *EnergyMeter) Energy() (float64, error) {
+	if err := d.setup(); err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sync()
+	return d.totals.Total(), nil
+}
+
+// sync adds the energy of the impulses counted since the previous call to
+// the total.
+func (d *EnergyMeter) sync() {
+	count := d.counter.Count()
+	d.totals.Add(float64(count-d.last) / d.ImpulsesPerKWh)
+	d.last = count
+}
+
+// Rate implements sensor.Counter, returning the power in watts.
+func (d *EnergyMeter) Rate() (float64, error) {
+	return d.Power()
+}
+
+// Total implements sensor.Counter, returning the energy in kWh.
+func (d *EnergyMeter) Total() (float64, error) {
+	return d.Energy()
+}
+
+// Save writes the total energy to TotalPath.
+func (d *EnergyMeter) Save() error {
+	if d.TotalPath == "" {
+		return nil
+	}
+	if _, err := d.Energy(); err != nil {
+		return err
+	}
+	return d.totals.Flush()
+}
+
+func (d *EnergyMeter) publish(power, energy float64) error {
+	if d.Publisher == nil {
+		return nil
+	}
+	if err := d.Publisher.Publish(d.Topic+"/power", []byte(strconv.FormatFloat(power, 'f', 1, 64))); err != nil {
+		return err
+	}
+	return d.Publisher.Publish(d.Topic+"/energy", []byte(strconv.FormatFloat(energy, 'f', 3, 64)))
+}
+
+// healthName returns the name of the meter in the health registry.
+func (d *EnergyMeter) healthName() string {
+	return "energymeter/" + d.Name
+}
+
+// poll measures the power and publishes the readings, reporting the outcome
+// to the health registry.
+func (d *EnergyMeter) poll() {
+	power, err := d.Power()
+	if err != nil {
+		glog.Errorf("energymeter: %v", err)
+		health.Observe(d.healthName(), err)
+		return
+	}
+	energy, _ := d.Energy()
+	glog.V(1).Infof("energymeter: %.1f W, %.3f kWh", power, energy)
+
+	err = d.publish(power, energy)
+	if err != nil {
+		glog.Errorf("energymeter: publishing: %v", err)
+	}
+	health.Observe(d.healthName(), err)
+}
+
+// Run starts measuring the power every Poll, and saving the total energy
+// every FlushEvery, in the background.
+func (d *EnergyMeter) Run() error {
+	if err := d.setup(); err != nil {
+		return err
+	}
+	d.totals.Run()
+
+	d.quit = make(chan struct{})
+	d.done = make(chan struct{})
+
+	go func() {
+		defer close(d.done)
+
+		ticker := time.NewTicker(d.Poll)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				d.poll()
+			case <-d.quit:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close stops measuring, saves the total energy and stops counting.
+func (d *EnergyMeter) Close() error {
+	if d.quit != nil {
+		close(d.quit)
+		<-d.done
+		d.quit = nil
+		health.Default.Remove(d.healthName())
+	}
+	if !d.initialized {
+		return nil
+	}
+	if err := d.totals.Close(); err != nil {
+		return err
+	}
+	return d.counter.Close()
+}
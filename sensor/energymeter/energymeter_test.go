@@ -0,0 +1,74 @@
+package energymeter
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/sensor"
+)
+
+var _ sensor.Counter = &EnergyMeter{}
+
+type fakePin struct {
+	embd.DigitalPin
+	val     int
+	handler func(embd.DigitalPin)
+}
+
+func (p *fakePin) SetDirection(embd.Direction) error { return nil }
+func (p *fakePin) Read() (int, error)                { return p.val, nil }
+func (p *fakePin) StopWatching() error               { return nil }
+
+func (p *fakePin) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
+	p.handler = handler
+	return nil
+}
+
+func (p *fakePin) impulse(n int) {
+	for i := 0; i < n; i++ {
+		p.val = embd.Low
+		p.handler(p)
+		p.val = embd.High
+		p.handler(p)
+	}
+}
+
+type fakePublisher map[string]string
+
+func (p fakePublisher) Publish(topic string, payload []byte) error {
+	p[topic] = string(payload)
+	return nil
+}
+
+func TestEnergyMeter(t *testing.T) {
+	pin := &fakePin{val: embd.High}
+	d := New(pin, 1000)
+	pub := fakePublisher{}
+	d.Publisher = pub
+	d.Topic = "home/meter"
+
+	if _, err := d.Energy(); err != nil {
+		t.Fatal(err)
+	}
+	pin.impulse(1500)
+	if e, _ := d.Energy(); math.Abs(e-1.5) > 1e-9 {
+		t.Errorf("Energy() = %v; want 1.5", e)
+	}
+
+	d.poll()
+	if pub["home/meter/energy"] != "1.500" {
+		t.Errorf("published energy %q; want 1.500", pub["home/meter/energy"])
+	}
+	if _, ok := pub["home/meter/power"]; !ok {
+		t.Error("power not published")
+	}
+
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if body := w.Body.String(); !strings.Contains(body, `energymeter_energy_kwh_total{meter="meter"} 1.5`) {
+		t.Errorf("metrics do not hold the total energy:\n%v", body)
+	}
+}
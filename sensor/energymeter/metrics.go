@@ -0,0 +1,48 @@
+// Prometheus metrics.
+
+package energymeter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/kidoman/embd"
+)
+
+// ServeHTTP exports the latest power measured while running and the total
+// energy in the Prometheus text format.
+func (d *EnergyMeter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	energy, err := d.Energy()
+	if err != nil {
+		http.Error(w, err.Error(), httpStatus(err))
+		return
+	}
+	d.mu.RLock()
+	power := d.power
+	d.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# HELP energymeter_power_watts Power averaged over the last measurement.\n")
+	fmt.Fprintf(w, "# TYPE energymeter_power_watts gauge\n")
+	fmt.Fprintf(w, "energymeter_power_watts{meter=%q} %v\n", d.Name, power)
+	fmt.Fprintf(w, "# HELP energymeter_energy_kwh_total Total energy.\n")
+	fmt.Fprintf(w, "# TYPE energymeter_energy_kwh_total counter\n")
+	fmt.Fprintf(w, "energymeter_energy_kwh_total{meter=%q} %v\n", d.Name, energy)
+}
+
+// httpStatus returns the status of the responses failing with err: the
+// meter is a gateway to the device.
+func httpStatus(err error) int {
+	switch {
+	case errors.Is(err, embd.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, embd.ErrNAK):
+		return http.StatusBadGateway
+	case errors.Is(err, embd.ErrBusBusy), errors.Is(err, embd.ErrClosed):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, embd.ErrNotSupported):
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,159 @@
+// Package flowmeter allows interfacing with hall effect flow sensors like
+// the YF-S201.
+package flowmeter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/sensor"
+)
+
+// YFS201 is the K-factor of the YF-S201 flow sensor.
+const YFS201 = 7.5
+
+// FlowMeter represents a hall effect flow sensor.
+type FlowMeter struct {
+	Pin embd.DigitalPin
+
+	// KFactor is the pulse frequency in Hz for a flow of 1 l/min. It is
+	// printed on the sensor datasheet, and can be calibrated by measuring a
+	// known volume: KFactor = pulses / (60 * liters).
+	KFactor float64
+
+	// TotalPath, if set, is the file in which the total volume is persisted
+	// across restarts. It is loaded on first use and written by Save and
+	// Close.
+	TotalPath string
+
+	// FlushEvery, if set, also writes the total volume to TotalPath at this
+	// interval, so that a crash loses at most that much of it.
+	FlushEvery time.Duration
+
+	initialized bool
+	mu          sync.RWMutex
+
+	counter *sensor.PulseCounter
+	totals  *sensor.Totalizer
+	last    uint64
+}
+
+// New creates a new flow meter on pin with the given K-factor.
+func New(pin embd.DigitalPin, kFactor float64) *FlowMeter {
+	return &FlowMeter{Pin: pin, KFactor: kFactor}
+}
+
+func (d *FlowMeter) setup() error {
+	d.mu.RLock()
+	if d.initialized {
+		d.mu.RUnlock()
+		return nil
+	}
+	d.mu.RUnlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	totals, err := sensor.OpenTotalizer(d.TotalPath)
+	if err != nil {
+		return err
+	}
+	glog.V(1).Infof("flowmeter: loaded total of %v l", totals)
+
+	counter, err := sensor.NewPulseCounter(d.Pin, embd.EdgeFalling)
+	if err != nil {
+		return err
+	}
+	d.counter = counter
+	d.last = counter.Count()
+
+	totals.Sync = func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		d.sync()
+	}
+	if d.FlushEvery > 0 {
+		totals.FlushEvery = d.FlushEvery
+		totals.Run()
+	}
+	d.totals = totals
+
+	d.initialized = true
+
+	return nil
+}
+
+// Rate returns the flow rate in liters per minute, averaged since the
+// previous call.
+func (d *FlowMeter) Rate() (float64, error) {
+	if err := d.setup(); err != nil {
+		return 0, err
+	}
+
+	m := d.counter.Measure()
+	return m.Frequency / d.KFactor, nil
+}
+
+// Total returns the total volume in liters.
+func (d *FlowMeter) Total() (float64, error) {
+	if err := d.setup(); err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sync()
+	return d.totals.Total(), nil
+}
+
+// sync adds the volume of the pulses counted since the previous call to the
+// total.
+func (d *FlowMeter) sync() {
+	count := d.counter.Count()
+	d.totals.Add(float64(count-d.last) / (60 * d.KFactor))
+	d.last = count
+}
+
+// Reset sets the total volume back to zero.
+func (d *FlowMeter) Reset() error {
+	if err := d.setup(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.last = d.counter.Count()
+	return d.totals.Reset()
+}
+
+// Save writes the total volume to TotalPath.
+func (d *FlowMeter) Save() error {
+	if d.TotalPath == "" {
+		return nil
+	}
+	if err := d.setup(); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.sync()
+	d.mu.Unlock()
+
+	return d.totals.Flush()
+}
+
+// Close saves the total volume and stops counting.
+func (d *FlowMeter) Close() error {
+	if !d.initialized {
+		return nil
+	}
+	if err := d.totals.Close(); err != nil {
+		return err
+	}
+	return d.counter.Close()
+}
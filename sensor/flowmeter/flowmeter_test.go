@@ -0,0 +1,83 @@
+package flowmeter
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/sensor"
+)
+
+var _ sensor.Counter = &FlowMeter{}
+
+type fakePin struct {
+	embd.DigitalPin
+	val     int
+	handler func(embd.DigitalPin)
+}
+
+func (p *fakePin) SetDirection(embd.Direction) error { return nil }
+func (p *fakePin) Read() (int, error)                { return p.val, nil }
+func (p *fakePin) StopWatching() error               { return nil }
+
+func (p *fakePin) Watch(edge embd.Edge, handler func(embd.DigitalPin)) error {
+	p.handler = handler
+	return nil
+}
+
+func (p *fakePin) pulse(n int) {
+	for i := 0; i < n; i++ {
+		p.val = embd.High
+		p.handler(p)
+		p.val = embd.Low
+		p.handler(p)
+	}
+}
+
+func TestTotalPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "flowmeter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "total")
+
+	pin := &fakePin{}
+	d := New(pin, YFS201)
+	d.TotalPath = path
+	if total, err := d.Total(); err != nil || total != 0 {
+		t.Fatalf("Total() = %v, %v; want 0", total, err)
+	}
+
+	// One liter.
+	pin.pulse(450)
+	if total, _ := d.Total(); math.Abs(total-1) > 1e-9 {
+		t.Errorf("Total() = %v; want 1", total)
+	}
+	if rate, _ := d.Rate(); rate <= 0 {
+		t.Errorf("Rate() = %v; want > 0", rate)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The total carries over to the next run.
+	pin = &fakePin{}
+	d = New(pin, YFS201)
+	d.TotalPath = path
+	d.Total()
+	pin.pulse(225)
+	if total, _ := d.Total(); math.Abs(total-1.5) > 1e-9 {
+		t.Errorf("Total() after restart = %v; want 1.5", total)
+	}
+
+	if err := d.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if total, _ := d.Total(); total != 0 {
+		t.Errorf("Total() after Reset = %v; want 0", total)
+	}
+}
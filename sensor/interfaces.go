@@ -0,0 +1,40 @@
+// Common sensor interfaces.
+
+package sensor
+
+import "github.com/kidoman/embd/units"
+
+// A Barometer measures the atmospheric pressure. It is implemented by the
+// bmp085 and bmp180 drivers.
+type Barometer interface {
+	Pressure() (units.Pressure, error)
+}
+
+// A Thermometer measures a temperature. It is implemented by the bmp085,
+// bmp180, l3gd20 and thermalzone drivers.
+type Thermometer interface {
+	Temperature() (units.Temperature, error)
+}
+
+// A Hygrometer measures the relative humidity of the air, in percent.
+type Hygrometer interface {
+	Humidity() (float64, error)
+}
+
+// A Counter measures the rate of a flow and accumulates its total, like
+// the liters per minute and liters of a flow meter or the RPM and
+// revolutions of a tachometer. It is implemented by the flowmeter and
+// tachometer drivers.
+type Counter interface {
+	// Rate returns the rate since the previous call.
+	Rate() (float64, error)
+
+	// Total returns the total so far.
+	Total() (float64, error)
+}
+
+// A Ranger measures the distance to the closest obstacle. It is implemented
+// by the us020 driver.
+type Ranger interface {
+	Distance() (units.Distance, error)
+}
@@ -0,0 +1,111 @@
+// FIFO support.
+
+package l3gd20
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	fifoCtrlReg = 0x2E
+	fifoSrcReg  = 0x2F
+
+	fifoEnable = 0x40 // CTRL_REG5 FIFO_EN
+	fifoBypass = 0x00 // FIFO_CTRL_REG bypass mode
+	fifoStream = 0x40 // FIFO_CTRL_REG stream mode
+
+	fifoOverrun = 0x40 // FIFO_SRC_REG OVRN
+	fifoLevel   = 0x1F // FIFO_SRC_REG FSS4-0
+
+	fifoDepth = 32
+
+	// Setting the MSB of the register address auto-increments it during
+	// multiple byte reads. In FIFO mode, the address rolls back to OUT_X_L
+	// after OUT_Z_H, so all the samples are read in a single transaction.
+	autoIncrement = 0x80
+)
+
+// Sample is a calibrated angular rate sample, in degrees per second.
+type Sample struct {
+	Time    time.Time
+	X, Y, Z float64
+}
+
+// Batch is a set of samples read from the FIFO at once, oldest first.
+type Batch struct {
+	Samples []Sample
+
+	// Overrun is set when the FIFO filled up since the previous read, so
+	// older samples were lost.
+	Overrun bool
+}
+
+// EnableFIFO switches the FIFO on in stream mode. The gyroscope then buffers
+// up to 32 samples, which ReadFIFO reads in a single bus transaction.
+func (d *L3GD20) EnableFIFO() error {
+	if err := d.setup(); err != nil {
+		return err
+	}
+	if err := d.Bus.WriteByteToReg(address, ctrlReg5, fifoEnable); err != nil {
+		return err
+	}
+	return d.Bus.WriteByteToReg(address, fifoCtrlReg, fifoStream)
+}
+
+// DisableFIFO switches the FIFO off.
+func (d *L3GD20) DisableFIFO() error {
+	if err := d.Bus.WriteByteToReg(address, fifoCtrlReg, fifoBypass); err != nil {
+		return err
+	}
+	return d.Bus.WriteByteToReg(address, ctrlReg5, 0)
+}
+
+// ReadFIFO reads all the samples waiting in the FIFO. Samples are
+// timestamped from the time of the read, going back one period of the
+// output data rate per sample. Call it at least every 32 samples (about
+// every 300ms) to avoid overruns.
+func (d *L3GD20) ReadFIFO() (*Batch, error) {
+	if err := d.setup(); err != nil {
+		return nil, err
+	}
+
+	src, err := d.Bus.ReadByteFromReg(address, fifoSrcReg)
+	if err != nil {
+		return nil, err
+	}
+	batch := &Batch{Overrun: src&fifoOverrun != 0}
+	n := int(src & fifoLevel)
+	if batch.Overrun {
+		glog.V(1).Infof("l3gd20: fifo overrun")
+		n = fifoDepth
+	}
+	if n == 0 {
+		return batch, nil
+	}
+
+	now := time.Now()
+	data := make([]byte, 6*n)
+	if err := d.Bus.ReadFromReg(address, xlReg|autoIncrement, data); err != nil {
+		return nil, err
+	}
+
+	period := time.Second / odr
+	batch.Samples = make([]Sample, n)
+	for i := range batch.Samples {
+		b := data[6*i:]
+		value := func(j int, ac axisCalibration) float64 {
+			v := float64(int16(b[j+1])<<8|int16(b[j])) * d.Range.sensitivity
+			return ac.adjust(v)
+		}
+		batch.Samples[i] = Sample{
+			Time: now.Add(-time.Duration(n-1-i) * period),
+			X:    value(0, d.xac),
+			Y:    value(2, d.yac),
+			Z:    value(4, d.zac),
+		}
+	}
+
+	return batch, nil
+}
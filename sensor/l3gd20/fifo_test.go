@@ -0,0 +1,73 @@
+package l3gd20
+
+import (
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+type fakeBus struct {
+	embd.I2CBus
+	regs map[byte][]byte
+}
+
+func (b *fakeBus) ReadByteFromReg(addr, reg byte) (byte, error) {
+	return b.regs[reg][0], nil
+}
+
+func (b *fakeBus) ReadFromReg(addr, reg byte, value []byte) error {
+	copy(value, b.regs[reg])
+	return nil
+}
+
+func TestReadFIFO(t *testing.T) {
+	bus := &fakeBus{regs: map[byte][]byte{
+		fifoSrcReg: {2},
+		xlReg | autoIncrement: {
+			0x10, 0x00, 0xF0, 0xFF, 0x00, 0x01,
+			0x20, 0x00, 0x00, 0x00, 0x00, 0x80,
+		},
+	}}
+	d := &L3GD20{Bus: bus, Range: R250DPS, initialized: true}
+
+	batch, err := d.ReadFIFO()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Overrun {
+		t.Error("Overrun = true; want false")
+	}
+	if len(batch.Samples) != 2 {
+		t.Fatalf("got %v samples; want 2", len(batch.Samples))
+	}
+
+	s := R250DPS.sensitivity
+	want := []Sample{
+		{X: 16 * s, Y: -16 * s, Z: 256 * s},
+		{X: 32 * s, Y: 0, Z: -32768 * s},
+	}
+	for i, w := range want {
+		got := batch.Samples[i]
+		if got.X != w.X || got.Y != w.Y || got.Z != w.Z {
+			t.Errorf("sample %v = %+v; want %+v", i, got, w)
+		}
+	}
+	if d := batch.Samples[1].Time.Sub(batch.Samples[0].Time); d <= 0 {
+		t.Errorf("samples are %v apart; want increasing timestamps", d)
+	}
+}
+
+func TestReadFIFO_overrun(t *testing.T) {
+	bus := &fakeBus{regs: map[byte][]byte{
+		fifoSrcReg: {fifoOverrun | 0x1F},
+	}}
+	d := &L3GD20{Bus: bus, Range: R250DPS, initialized: true}
+
+	batch, err := d.ReadFIFO()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !batch.Overrun || len(batch.Samples) != fifoDepth {
+		t.Errorf("got overrun %v with %v samples; want true with %v", batch.Overrun, len(batch.Samples), fifoDepth)
+	}
+}
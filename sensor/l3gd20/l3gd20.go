@@ -9,11 +9,14 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/sensor"
+	"github.com/kidoman/embd/units"
 )
 
 const (
 	address = 0x6B
 	id      = 0xD4
+	idH     = 0xD7 // L3GD20H
 
 	dpsToRps = 0.017453293
 
@@ -50,6 +53,8 @@ const (
 
 	zyxAvailable = 0x08
 
+	i2DRDY = 0x08 // data ready on DRDY/INT2
+
 	odr       = 95
 	mult      = 1.0 / odr
 	pollDelay = mult * 1000 * 1000
@@ -115,6 +120,10 @@ type L3GD20 struct {
 	Bus   embd.I2CBus
 	Range *Range
 
+	// DataReady is the pin wired to DRDY/INT2, if any. When set, Start reads
+	// the samples as soon as they are ready instead of polling.
+	DataReady embd.DigitalPin
+
 	initialized bool
 	mu          sync.RWMutex
 
@@ -186,6 +195,52 @@ func (d *L3GD20) calibrate(a *axis) (axisCalibration, error) {
 	return ac, nil
 }
 
+// Identify verifies that a L3GD20 (or L3GD20H) is answering on the bus.
+func (d *L3GD20) Identify() error {
+	err := sensor.VerifyID(d.Bus, "l3gd20", address, whoAmI, id)
+	if e, ok := err.(*sensor.ChipIDError); ok && e.Got[0] == idH {
+		return nil
+	}
+	return err
+}
+
+// SelfTest verifies the chip id, and checks that data becomes available on
+// all the axes and that the temperature can be read. The datasheet does not
+// specify limits for the built-in self-test, so it is not run.
+func (d *L3GD20) SelfTest() (*sensor.Diagnostics, error) {
+	diag := &sensor.Diagnostics{Chip: "l3gd20"}
+
+	err := d.Identify()
+	if _, ok := err.(*sensor.ChipIDError); err != nil && !ok {
+		return nil, err
+	}
+	diag.AddErr("chip id", err)
+	if err != nil {
+		return diag, nil
+	}
+
+	if err := d.setup(); err != nil {
+		return nil, err
+	}
+	for _, a := range []*axis{ax, ay, az} {
+		var available bool
+		for i := 0; i < 100 && !available; i++ {
+			if available, err = d.axisStatus(a); err != nil {
+				return nil, err
+			}
+			if !available {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		diag.Add(fmt.Sprintf("%v axis data", a), available, "")
+	}
+
+	_, err = d.Temperature()
+	diag.AddErr("temperature", err)
+
+	return diag, nil
+}
+
 func (d *L3GD20) setup() error {
 	d.mu.RLock()
 	if d.initialized {
@@ -197,6 +252,10 @@ func (d *L3GD20) setup() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	if err := d.Identify(); err != nil {
+		return err
+	}
+
 	d.orientations = make(chan Orientation)
 
 	if err := d.Bus.WriteByteToReg(address, ctrlReg1, ctrlReg1Default); err != nil {
@@ -205,6 +264,11 @@ func (d *L3GD20) setup() error {
 	if err := d.Bus.WriteByteToReg(address, ctrlReg4, d.Range.value); err != nil {
 		return err
 	}
+	if d.DataReady != nil {
+		if err := d.Bus.WriteByteToReg(address, ctrlReg3, i2DRDY); err != nil {
+			return err
+		}
+	}
 
 	// Calibrate
 	var err error
@@ -300,7 +364,7 @@ func (d *L3GD20) OrientationDelta() (float64, float64, float64, error) {
 }
 
 // Temperature returns the current temperature reading.
-func (d *L3GD20) Temperature() (int, error) {
+func (d *L3GD20) Temperature() (units.Temperature, error) {
 	if err := d.setup(); err != nil {
 		return 0, err
 	}
@@ -310,7 +374,7 @@ func (d *L3GD20) Temperature() (int, error) {
 		return 0, err
 	}
 
-	temp := int(int8(data))
+	temp := units.Temperature(int8(data))
 
 	return temp, nil
 }
@@ -330,17 +394,22 @@ func (d *L3GD20) Start() error {
 		return err
 	}
 
+	dr, err := sensor.NewDataReady(d.DataReady, embd.EdgeRising, time.Duration(math.Floor(pollDelay))*time.Microsecond)
+	if err != nil {
+		return err
+	}
+
 	d.closing = make(chan chan struct{})
 
 	go func() {
+		defer dr.Close()
+
 		var x, y, z float64
 		var orientations chan Orientation
 
-		timer := time.Tick(time.Duration(math.Floor(pollDelay)) * time.Microsecond)
-
 		for {
 			select {
-			case <-timer:
+			case <-dr.C:
 				dx, dy, dz, err := d.measureOrientationDelta()
 				if err != nil {
 					glog.Errorf("l3gd20: %v", err)
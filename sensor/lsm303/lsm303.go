@@ -2,17 +2,22 @@
 package lsm303
 
 import (
+	"fmt"
 	"math"
 	"sync"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/sensor"
+	"github.com/kidoman/embd/sensor/calibration"
 )
 
 const (
 	magAddress = 0x1E
 
+	magIDReg = 0x0A
+
 	magConfigRegA = 0x00
 
 	MagHz75         = 0x00 // ODR = 0.75 Hz
@@ -42,11 +47,17 @@ const (
 	pollDelay = 250
 )
 
+var magID = []byte("H43")
+
 // LSM303 represents a LSM303 magnetometer.
 type LSM303 struct {
 	Bus  embd.I2CBus
 	Poll int
 
+	// Calibration, if set, corrects the magnetic field readings for hard
+	// and soft iron distortion.
+	Calibration *calibration.Mag
+
 	initialized bool
 	mu          sync.RWMutex
 
@@ -73,6 +84,9 @@ func (d *LSM303) setup() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	if err := d.Identify(); err != nil {
+		return err
+	}
 	if err := d.Bus.WriteByteToReg(magAddress, magConfigRegA, MagCRADefault); err != nil {
 		return err
 	}
@@ -85,24 +99,99 @@ func (d *LSM303) setup() error {
 	return nil
 }
 
-func (d *LSM303) measureHeading() (float64, error) {
+// Identify verifies that a LSM303 magnetometer is answering on the bus.
+func (d *LSM303) Identify() error {
+	return sensor.VerifyID(d.Bus, "lsm303", magAddress, magIDReg, magID...)
+}
+
+// SelfTest verifies the chip id and runs the positive bias self-test of the
+// magnetometer: with the bias field applied, all the outputs must read
+// higher than without it.
+func (d *LSM303) SelfTest() (*sensor.Diagnostics, error) {
+	diag := &sensor.Diagnostics{Chip: "lsm303"}
+
+	err := d.Identify()
+	if _, ok := err.(*sensor.ChipIDError); err != nil && !ok {
+		return nil, err
+	}
+	diag.AddErr("chip id", err)
+	if err != nil {
+		return diag, nil
+	}
+
 	if err := d.setup(); err != nil {
-		return 0, err
+		return nil, err
+	}
+	// Wait for two measurements at 15 Hz before each read.
+	const settle = 150 * time.Millisecond
+	time.Sleep(settle)
+	normal, err := d.readRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.Bus.WriteByteToReg(magAddress, magConfigRegA, Mag15Hz|MagPositiveBias); err != nil {
+		return nil, err
+	}
+	time.Sleep(settle)
+	biased, err := d.readRaw()
+	if err := d.Bus.WriteByteToReg(magAddress, magConfigRegA, MagCRADefault); err != nil {
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
 	}
 
+	for i := range normal {
+		diag.Add(fmt.Sprintf("bias output %v", i+1), biased[i] > normal[i], "%v -> %v", normal[i], biased[i])
+	}
+
+	return diag, nil
+}
+
+// readRaw reads the three magnetometer outputs, in register order.
+func (d *LSM303) readRaw() ([3]int16, error) {
+	var out [3]int16
+
 	if _, err := d.Bus.ReadByteFromReg(magAddress, magDataSignal); err != nil {
-		return 0, err
+		return out, err
 	}
 
 	data := make([]byte, 6)
 	if err := d.Bus.ReadFromReg(magAddress, magData, data); err != nil {
-		return 0, err
+		return out, err
+	}
+	for i := range out {
+		out[i] = int16(data[2*i])<<8 | int16(data[2*i+1])
 	}
+	return out, nil
+}
 
-	x := int16(data[0])<<8 | int16(data[1])
-	y := int16(data[2])<<8 | int16(data[3])
+// MagneticField returns the raw magnetic field reading, uncalibrated. It is
+// meant to be passed to calibration.CalibrateMagnetometer.
+func (d *LSM303) MagneticField() (calibration.Vector, error) {
+	if err := d.setup(); err != nil {
+		return calibration.Vector{}, err
+	}
+
+	raw, err := d.readRaw()
+	if err != nil {
+		return calibration.Vector{}, err
+	}
+	return calibration.Vector{float64(raw[0]), float64(raw[1]), float64(raw[2])}, nil
+}
+
+func (d *LSM303) measureHeading() (float64, error) {
+	field, err := d.MagneticField()
+	if err != nil {
+		return 0, err
+	}
+	if d.Calibration != nil {
+		field = d.Calibration.Apply(field)
+	}
+	x, y := field[0], field[1]
 
-	heading := math.Atan2(float64(y), float64(x)) / math.Pi * 180
+	heading := math.Atan2(y, x) / math.Pi * 180
 	if heading < 0 {
 		heading += 360
 	}
@@ -0,0 +1,323 @@
+/*
+Package proximity fuses the readings of several distance sensors into the
+occupancy of zones, like the bays of a garage or the levels of a tank.
+
+Ultrasonic sensors hear the echoes of each other, so the sensors of an array
+are triggered one at a time, spread over the period of a scheduler:
+
+	a := proximity.NewArray()
+	a.Add("left", us020.New(echoL, trigL, nil))
+	a.Add("right", us020.New(echoR, trigR, nil))
+	a.AddZone(proximity.Zone{Name: "bay", Sensors: []string{"left", "right"}, Threshold: 1.5 * units.Meter})
+	a.BindBar("bay", lcd.Region(0, 1, 16), 3*units.Meter)
+
+	s := sensor.NewScheduler()
+	a.Schedule(s, 200*time.Millisecond)
+	s.Run()
+	defer s.Close()
+
+	for o := range a.Changes() {
+		fmt.Println(o)
+	}
+*/
+package proximity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/interface/display/characterdisplay"
+	"github.com/kidoman/embd/sensor"
+	"github.com/kidoman/embd/units"
+)
+
+const (
+	// DefaultWindow is the default number of readings of a sensor the
+	// median of which is used, to reject spurious echoes.
+	DefaultWindow = 3
+
+	// fullBlock is the character of a full cell in the HD44780 character
+	// ROM.
+	fullBlock = 0xff
+
+	changeBuffer = 8
+)
+
+// Zone is an area watched by some of the sensors of an array.
+type Zone struct {
+	Name    string
+	Sensors []string
+
+	// Threshold is the distance under which the zone is occupied.
+	Threshold units.Distance
+	// Hysteresis is how much farther than Threshold an obstacle has to be
+	// for the zone to be free again.
+	Hysteresis units.Distance
+}
+
+// Occupancy is the estimated occupancy of a zone.
+type Occupancy struct {
+	Zone     string
+	Occupied bool
+	// Nearest is the distance to the nearest obstacle seen by the sensors
+	// of the zone, zero when none of them has a reading.
+	Nearest units.Distance
+	// Confidence is the fraction of the sensors of the zone agreeing with
+	// the estimate, from 0 to 1.
+	Confidence float64
+}
+
+func (o Occupancy) String() string {
+	state := "free"
+	if o.Occupied {
+		state = "occupied"
+	}
+	return fmt.Sprintf("%v: %v (%.0fcm, %.0f%%)", o.Zone, state, o.Nearest.Centimeters(), o.Confidence*100)
+}
+
+type ranger struct {
+	r        sensor.Ranger
+	readings []units.Distance
+	valid    bool
+}
+
+// median returns the median of the last readings. It must be called with
+// the lock held.
+func (r *ranger) median() units.Distance {
+	sorted := append([]units.Distance(nil), r.readings...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+type zone struct {
+	Zone
+	occupancy Occupancy
+	bindings  []func(Occupancy)
+}
+
+// Array is an array of distance sensors watching zones.
+type Array struct {
+	// Window is the number of readings of a sensor the median of which
+	// is used.
+	Window int
+
+	mu      sync.Mutex
+	sensors map[string]*ranger
+	names   []string
+	zones   []*zone
+	changes chan Occupancy
+}
+
+// NewArray creates a new empty array.
+func NewArray() *Array {
+	return &Array{
+		Window:  DefaultWindow,
+		sensors: map[string]*ranger{},
+		changes: make(chan Occupancy, changeBuffer),
+	}
+}
+
+// Add adds a sensor to the array.
+func (a *Array) Add(name string, r sensor.Ranger) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.sensors[name]; ok {
+		return fmt.Errorf("proximity: sensor %v already added", name)
+	}
+	a.sensors[name] = &ranger{r: r}
+	a.names = append(a.names, name)
+	return nil
+}
+
+// AddZone adds a zone watched by sensors of the array.
+func (a *Array) AddZone(z Zone) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(z.Sensors) == 0 {
+		return fmt.Errorf("proximity: zone %v has no sensors", z.Name)
+	}
+	for _, name := range z.Sensors {
+		if _, ok := a.sensors[name]; !ok {
+			return fmt.Errorf("proximity: zone %v: unknown sensor %v", z.Name, name)
+		}
+	}
+	a.zones = append(a.zones, &zone{Zone: z, occupancy: Occupancy{Zone: z.Name}})
+	return nil
+}
+
+// Read triggers a sensor, and updates the occupancy of its zones. A failed
+// reading, like an echo which never came back, leaves the sensor out of the
+// estimates until it reads again.
+func (a *Array) Read(name string) error {
+	a.mu.Lock()
+	r, ok := a.sensors[name]
+	a.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("proximity: unknown sensor %v", name)
+	}
+
+	d, err := r.r.Distance()
+
+	a.mu.Lock()
+	if err != nil {
+		r.valid, r.readings = false, nil
+	} else {
+		r.readings = append(r.readings, d)
+		if window := a.Window; window > 0 && len(r.readings) > window {
+			r.readings = r.readings[len(r.readings)-window:]
+		}
+		r.valid = true
+	}
+	var changed []Occupancy
+	var notify []func()
+	for _, z := range a.zones {
+		if !z.watches(name) {
+			continue
+		}
+		o := a.estimate(z)
+		if o.Occupied != z.occupancy.Occupied {
+			changed = append(changed, o)
+		}
+		z.occupancy = o
+		for _, b := range z.bindings {
+			b := b
+			notify = append(notify, func() { b(o) })
+		}
+	}
+	a.mu.Unlock()
+
+	for _, o := range changed {
+		glog.V(1).Infof("proximity: %v", o)
+		select {
+		case a.changes <- o:
+		default:
+			glog.Warningf("proximity: change channel full, dropping %v", o)
+		}
+	}
+	for _, n := range notify {
+		n()
+	}
+	if err != nil {
+		return fmt.Errorf("proximity: %v: %v", name, err)
+	}
+	return nil
+}
+
+func (z *zone) watches(name string) bool {
+	for _, s := range z.Sensors {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// estimate fuses the readings of the sensors of a zone. It must be called
+// with the lock held.
+func (a *Array) estimate(z *zone) Occupancy {
+	o := Occupancy{Zone: z.Name, Occupied: z.occupancy.Occupied}
+	threshold := z.Threshold
+	if o.Occupied {
+		threshold += z.Hysteresis
+	}
+	var medians []units.Distance
+	for _, name := range z.Sensors {
+		if r := a.sensors[name]; r.valid {
+			m := r.median()
+			medians = append(medians, m)
+			if o.Nearest == 0 || m < o.Nearest {
+				o.Nearest = m
+			}
+		}
+	}
+	if len(medians) == 0 {
+		return o
+	}
+	o.Occupied = o.Nearest < threshold
+	agree := 0
+	for _, m := range medians {
+		if (m < threshold) == o.Occupied {
+			agree++
+		}
+	}
+	o.Confidence = float64(agree) / float64(len(z.Sensors))
+	return o
+}
+
+// Occupancy returns the occupancy of the zones, in the order they were
+// added.
+func (a *Array) Occupancy() []Occupancy {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	occ := make([]Occupancy, len(a.zones))
+	for i, z := range a.zones {
+		occ[i] = z.occupancy
+	}
+	return occ
+}
+
+// Changes returns the channel the zones becoming occupied or free are
+// reported on. Changes are dropped when the channel is full.
+func (a *Array) Changes() <-chan Occupancy {
+	return a.changes
+}
+
+// Schedule reads every sensor every period on s, staggered evenly over the
+// period so that no two sensors are triggered together.
+func (a *Array) Schedule(s *sensor.Scheduler, period time.Duration) []*sensor.Task {
+	a.mu.Lock()
+	names := append([]string(nil), a.names...)
+	a.mu.Unlock()
+
+	tasks := make([]*sensor.Task, len(names))
+	for i, name := range names {
+		name := name
+		phase := period * time.Duration(i) / time.Duration(len(names))
+		tasks[i] = s.Add("proximity/"+name, period, phase, func(time.Time) error {
+			return a.Read(name)
+		})
+	}
+	return tasks
+}
+
+// Bar returns a bar of width characters, the fuller the nearer the obstacle
+// within far. A zone without readings shows an empty bar.
+func Bar(o Occupancy, far units.Distance, width int) string {
+	n := 0
+	if o.Nearest > 0 && far > 0 {
+		n = int(float64(width)*(1-float64(o.Nearest/far)) + 0.5)
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > width {
+		n = width
+	}
+	return strings.Repeat(string([]byte{fullBlock}), n) + strings.Repeat(" ", width-n)
+}
+
+// BindBar keeps a display region showing the proximity bar of a zone within
+// far, updated after every reading of its sensors.
+func (a *Array) BindBar(zoneName string, r *characterdisplay.Region, far units.Distance) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, z := range a.zones {
+		if z.Name == zoneName {
+			z.bindings = append(z.bindings, func(o Occupancy) {
+				if err := r.Write(Bar(o, far, r.Width())); err != nil {
+					glog.Errorf("proximity: updating display region: %v", err)
+				}
+			})
+			return nil
+		}
+	}
+	return fmt.Errorf("proximity: unknown zone %v", zoneName)
+}
@@ -0,0 +1,136 @@
+package proximity
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/sensor"
+	"github.com/kidoman/embd/units"
+)
+
+type fakeRanger struct {
+	d   units.Distance
+	err error
+	n   int
+}
+
+func (r *fakeRanger) Distance() (units.Distance, error) {
+	r.n++
+	return r.d, r.err
+}
+
+func TestOccupancy(t *testing.T) {
+	a := NewArray()
+	left, right := &fakeRanger{d: 3 * units.Meter}, &fakeRanger{d: 3 * units.Meter}
+	a.Add("left", left)
+	a.Add("right", right)
+	if err := a.AddZone(Zone{Name: "bay", Sensors: []string{"left", "nowhere"}}); err == nil {
+		t.Error("adding a zone of an unknown sensor succeeded")
+	}
+	if err := a.AddZone(Zone{Name: "bay", Sensors: []string{"left", "right"}, Threshold: units.Meter, Hysteresis: 20 * units.Centimeter}); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() {
+		a.Read("left")
+		a.Read("right")
+	}
+	read()
+	if o := a.Occupancy()[0]; o.Occupied || o.Confidence != 1 {
+		t.Errorf("occupancy %v; want free with full confidence", o)
+	}
+
+	// A single spurious echo is rejected by the median.
+	left.d = 50 * units.Centimeter
+	read()
+	left.d = 3 * units.Meter
+	read()
+	if o := a.Occupancy()[0]; o.Occupied {
+		t.Errorf("occupancy %v after a spurious echo; want free", o)
+	}
+
+	left.d, right.d = 80*units.Centimeter, 90*units.Centimeter
+	read()
+	read()
+	select {
+	case o := <-a.Changes():
+		if !o.Occupied || o.Nearest != 80*units.Centimeter {
+			t.Errorf("change %v; want occupied at 80cm", o)
+		}
+	default:
+		t.Error("occupied zone not reported")
+	}
+
+	// Within the hysteresis, the zone stays occupied.
+	left.d, right.d = 110*units.Centimeter, 110*units.Centimeter
+	read()
+	read()
+	if o := a.Occupancy()[0]; !o.Occupied {
+		t.Errorf("occupancy %v within the hysteresis; want occupied", o)
+	}
+
+	// A failing sensor is left out of the estimates.
+	right.err = errors.New("no echo")
+	if err := a.Read("right"); err == nil {
+		t.Error("failed reading not reported")
+	}
+	if o := a.Occupancy()[0]; o.Confidence != 0.5 {
+		t.Errorf("confidence %v with a failing sensor; want 0.5", o.Confidence)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	c := clock.NewVirtual(time.Time{})
+	s := sensor.NewSchedulerWithClock(c)
+	a := NewArray()
+	rs := []*fakeRanger{{d: units.Meter}, {d: units.Meter}, {d: units.Meter}, {d: units.Meter}}
+	for i, r := range rs {
+		a.Add(string(rune('a'+i)), r)
+	}
+	tasks := a.Schedule(s, 100*time.Millisecond)
+	if len(tasks) != 4 {
+		t.Fatalf("%v tasks; want 4", len(tasks))
+	}
+	s.Run()
+	defer s.Close()
+
+	// The sensors are triggered 25ms apart, from the start of the period.
+	for i := 0; i < 4; i++ {
+		if i > 0 {
+			c.BlockUntil(1)
+			c.Advance(25 * time.Millisecond)
+		}
+		deadline := time.Now().Add(time.Second)
+		for tasks[i].Stats().Runs != 1 {
+			if time.Now().After(deadline) {
+				t.Fatalf("sensor %v not read after %vms", i, 25*i)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		for j := i + 1; j < 4; j++ {
+			if n := tasks[j].Stats().Runs; n != 0 {
+				t.Errorf("sensor %v read %v times before its turn", j, n)
+			}
+		}
+	}
+}
+
+func TestBar(t *testing.T) {
+	for _, c := range []struct {
+		nearest units.Distance
+		full    int
+	}{
+		{0, 0},
+		{2 * units.Meter, 0},
+		{units.Meter, 4},
+		{5 * units.Centimeter, 8},
+	} {
+		bar := Bar(Occupancy{Nearest: c.nearest}, 2*units.Meter, 8)
+		if len(bar) != 8 || strings.Count(bar, "\xff") != c.full {
+			t.Errorf("Bar at %v = %q; want %v full cells of 8", c.nearest, bar, c.full)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Pulse counting.
+
+package sensor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// PulseMeasurement is the pulse activity over a measurement window.
+type PulseMeasurement struct {
+	// Window is the duration of the measurement.
+	Window time.Duration
+
+	// Count is the number of pulses counted in the window.
+	Count uint64
+
+	// Frequency is the pulse frequency in Hz. When at least two pulses were
+	// counted, it is computed from the time between the first and the last
+	// of them, which is precise even for slow pulse trains.
+	Frequency float64
+
+	// Duty is the fraction of the window during which the pin was high.
+	Duty float64
+}
+
+// PulseCounter counts the pulses on a digital pin, for flow meters,
+// anemometers, tachometers or the S0 outputs of energy meters:
+//
+//	pc, err := sensor.NewPulseCounter(pin, embd.EdgeFalling)
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer pc.Close()
+//
+//	for range time.Tick(time.Second) {
+//		m := pc.Measure()
+//		fmt.Printf("%.1f l/min\n", m.Frequency/7.5)
+//	}
+type PulseCounter struct {
+	edge embd.Edge
+	stop func() error
+
+	mu    sync.Mutex
+	count uint64
+
+	level    int
+	lastEdge time.Time
+	high     time.Duration
+	first    time.Time
+	last     time.Time
+	inWindow uint64
+	start    time.Time
+}
+
+// NewPulseCounter counts the given edges of pin (both edges count as one
+// pulse each with embd.EdgeBoth). The pin is watched for both edges so that
+// the duty cycle can be estimated.
+func NewPulseCounter(pin embd.DigitalPin, edge embd.Edge) (*PulseCounter, error) {
+	if err := pin.SetDirection(embd.In); err != nil {
+		return nil, err
+	}
+	level, err := pin.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	c := newPulseCounter(edge, level, pin.StopWatching)
+	if err := pin.Watch(embd.EdgeBoth, func(p embd.DigitalPin) {
+		// The edges can come faster than they are handled, so the level is
+		// read back instead of toggled.
+		level, err := p.Read()
+		if err != nil {
+			return
+		}
+		c.transition(level, time.Now())
+	}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func newPulseCounter(edge embd.Edge, level int, stop func() error) *PulseCounter {
+	now := time.Now()
+	return &PulseCounter{edge: edge, stop: stop, level: level, lastEdge: now, start: now}
+}
+
+// NewAnalogPulseCounter counts the pulses of an analog signal, like the
+// output of a photodiode watching the LED of an energy meter. The pin is
+// read every interval, and goes high when the value rises above high and
+// low when it falls below low.
+func NewAnalogPulseCounter(pin embd.AnalogPin, low, high int, edge embd.Edge, interval time.Duration) (*PulseCounter, error) {
+	level := embd.Low
+	v, err := pin.Read()
+	if err != nil {
+		return nil, err
+	}
+	if v > high {
+		level = embd.High
+	}
+
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	c := newPulseCounter(edge, level, func() error {
+		close(quit)
+		<-done
+		return nil
+	})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case t := <-ticker.C:
+				v, err := pin.Read()
+				if err != nil {
+					glog.Errorf("sensor: reading analog pulses: %v", err)
+					continue
+				}
+				switch {
+				case v > high:
+					c.transition(embd.High, t)
+				case v < low:
+					c.transition(embd.Low, t)
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+func (c *PulseCounter) transition(level int, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if level == c.level {
+		// A short pulse whose edges were both missed.
+		return
+	}
+	if c.level == embd.High {
+		c.high += t.Sub(c.lastEdge)
+	}
+	c.level, c.lastEdge = level, t
+
+	switch {
+	case c.edge == embd.EdgeBoth,
+		c.edge == embd.EdgeRising && level == embd.High,
+		c.edge == embd.EdgeFalling && level == embd.Low:
+	default:
+		return
+	}
+	// The count wraps around after 2^64 pulses. Differences between two
+	// counts computed with unsigned arithmetic stay correct across the
+	// wrap.
+	c.count++
+	c.inWindow++
+	if c.first.IsZero() {
+		c.first = t
+	}
+	c.last = t
+}
+
+// Count returns the total number of pulses counted.
+func (c *PulseCounter) Count() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.count
+}
+
+// Measure returns the pulse activity since the previous call (or since the
+// counter was created) and starts a new measurement window.
+func (c *PulseCounter) Measure() PulseMeasurement {
+	return c.measure(time.Now())
+}
+
+func (c *PulseCounter) measure(now time.Time) PulseMeasurement {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m := PulseMeasurement{Window: now.Sub(c.start), Count: c.inWindow}
+
+	high := c.high
+	if c.level == embd.High {
+		high += now.Sub(c.lastEdge)
+	}
+	if m.Window > 0 {
+		m.Duty = float64(high) / float64(m.Window)
+		m.Frequency = float64(m.Count) / m.Window.Seconds()
+	}
+	if m.Count >= 2 && c.last.After(c.first) {
+		m.Frequency = float64(m.Count-1) / c.last.Sub(c.first).Seconds()
+	}
+
+	c.start = now
+	c.lastEdge = now
+	c.high = 0
+	c.inWindow = 0
+	c.first, c.last = time.Time{}, time.Time{}
+
+	return m
+}
+
+// Close stops counting.
+func (c *PulseCounter) Close() error {
+	return c.stop()
+}
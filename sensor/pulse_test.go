@@ -0,0 +1,105 @@
+package sensor
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd"
+)
+
+func TestPulseCounter(t *testing.T) {
+	pin := &fakePin{}
+	c, err := NewPulseCounter(pin, embd.EdgeRising)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := c.start
+
+	// 10 pulses at 10 Hz with a 25% duty cycle.
+	for i := 0; i < 10; i++ {
+		t0 := start.Add(time.Duration(i) * 100 * time.Millisecond)
+		c.transition(embd.High, t0)
+		c.transition(embd.Low, t0.Add(25*time.Millisecond))
+	}
+	// A missed edge must not count.
+	c.transition(embd.Low, start.Add(990*time.Millisecond))
+
+	m := c.measure(start.Add(time.Second))
+	if m.Count != 10 {
+		t.Errorf("Count = %v; want 10", m.Count)
+	}
+	if math.Abs(m.Frequency-10) > 1e-9 {
+		t.Errorf("Frequency = %v; want 10", m.Frequency)
+	}
+	if math.Abs(m.Duty-0.25) > 1e-9 {
+		t.Errorf("Duty = %v; want 0.25", m.Duty)
+	}
+
+	// The next window starts empty, but the total count carries on.
+	c.transition(embd.High, start.Add(1500*time.Millisecond))
+	m = c.measure(start.Add(2 * time.Second))
+	if m.Count != 1 || m.Frequency != 1 || m.Duty != 0.5 {
+		t.Errorf("second window = %+v; want 1 pulse at 1 Hz, 50%% duty", m)
+	}
+	if n := c.Count(); n != 11 {
+		t.Errorf("Count() = %v; want 11", n)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !pin.stopped {
+		t.Error("Close did not stop watching the pin")
+	}
+}
+
+func TestPulseCounter_wrap(t *testing.T) {
+	c, err := NewPulseCounter(&fakePin{}, embd.EdgeBoth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.count = math.MaxUint64
+	before := c.Count()
+	c.transition(embd.High, time.Now())
+	c.transition(embd.Low, time.Now())
+	if d := c.Count() - before; d != 2 {
+		t.Errorf("difference across the wrap = %v; want 2", d)
+	}
+}
+
+type fakeAnalogPin struct {
+	embd.AnalogPin
+	vals chan int
+}
+
+func (p *fakeAnalogPin) Read() (int, error) {
+	select {
+	case v := <-p.vals:
+		return v, nil
+	default:
+		return 100, nil
+	}
+}
+
+func TestAnalogPulseCounter(t *testing.T) {
+	// Crossing the middle without reaching the thresholds is noise.
+	vals := []int{100, 900, 500, 900, 100, 500, 900, 100}
+	pin := &fakeAnalogPin{vals: make(chan int, len(vals))}
+	for _, v := range vals {
+		pin.vals <- v
+	}
+	c, err := NewAnalogPulseCounter(pin, 300, 700, embd.EdgeRising, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for len(pin.vals) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.Count(); n != 2 {
+		t.Errorf("Count() = %v; want 2", n)
+	}
+}
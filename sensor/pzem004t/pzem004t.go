@@ -0,0 +1,261 @@
+/*
+Package pzem004t allows interfacing with the PZEM-004T (v3.0) AC energy
+monitor over its Modbus-RTU serial interface.
+
+The monitor talks at 9600 baud, 8N1. The serial port is opened by the caller
+(any io.ReadWriter will do), and should have a read timeout so that an
+unplugged monitor does not hang the driver:
+
+	port, err := serial.OpenPort(&serial.Config{Name: "/dev/ttyAMA0", Baud: 9600, ReadTimeout: time.Second})
+	if err != nil {
+		panic(err)
+	}
+	d := pzem004t.New(port, pzem004t.DefaultAddress)
+	r, err := d.Read()
+*/
+package pzem004t
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/units"
+)
+
+const (
+	// DefaultAddress is the factory slave address.
+	DefaultAddress = 0x01
+
+	// GeneralAddress is answered by any monitor. It can only be used with a
+	// single monitor on the bus, to find or change its address.
+	GeneralAddress = 0xF8
+
+	maxAddress = 0xF7
+
+	readHolding     = 0x03
+	readInput       = 0x04
+	writeSingle     = 0x06
+	resetEnergy     = 0x42
+	errorFunction   = 0x80
+	measurementRegs = 10
+
+	alarmThresholdReg = 0x0001
+	addressReg        = 0x0002
+)
+
+// ErrChecksum is returned when a response is corrupted.
+var ErrChecksum = errors.New("pzem004t: bad response checksum")
+
+// ModbusError is returned when the monitor rejects a request.
+type ModbusError struct {
+	Function byte
+	Code     byte
+}
+
+func (e *ModbusError) Error() string {
+	var reason string
+	switch e.Code {
+	case 0x01:
+		reason = "illegal function"
+	case 0x02:
+		reason = "illegal address"
+	case 0x03:
+		reason = "illegal data"
+	case 0x04:
+		reason = "slave error"
+	default:
+		reason = fmt.Sprintf("error %#02x", e.Code)
+	}
+	return fmt.Sprintf("pzem004t: function %#02x: %v", e.Function, reason)
+}
+
+// Reading is a measurement of the monitor.
+type Reading struct {
+	Voltage units.Voltage
+
+	// Current is in amperes.
+	Current float64
+
+	// Power is the active power in watts.
+	Power float64
+
+	// Energy is the active energy in watt hours since the last reset.
+	Energy float64
+
+	// Frequency is in Hz.
+	Frequency float64
+
+	PowerFactor float64
+
+	// Alarm is set when the power is above the alarm threshold.
+	Alarm bool
+}
+
+// PZEM004T represents a PZEM-004T energy monitor.
+type PZEM004T struct {
+	Port io.ReadWriter
+	Addr byte
+
+	mu sync.Mutex
+}
+
+// New creates a new PZEM-004T interface. The port variable is the serial
+// port used to communicate with the device, and addr its slave address.
+func New(port io.ReadWriter, addr byte) *PZEM004T {
+	return &PZEM004T{Port: port, Addr: addr}
+}
+
+// crc computes the Modbus CRC-16 of data.
+func crc(data []byte) uint16 {
+	c := uint16(0xFFFF)
+	for _, b := range data {
+		c ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if c&1 != 0 {
+				c = c>>1 ^ 0xA001
+			} else {
+				c >>= 1
+			}
+		}
+	}
+	return c
+}
+
+// appendCRC appends the CRC of frame, low byte first.
+func appendCRC(frame []byte) []byte {
+	c := crc(frame)
+	return append(frame, byte(c), byte(c>>8))
+}
+
+// transact sends a request and reads a response of n bytes (CRC included).
+func (d *PZEM004T) transact(request []byte, n int) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	request = appendCRC(append([]byte{d.Addr}, request...))
+	glog.V(2).Infof("pzem004t: sending % x", request)
+	if _, err := d.Port.Write(request); err != nil {
+		return nil, err
+	}
+
+	// Error responses are 5 bytes long, whatever the request.
+	const errorLen = 5
+	// Short responses, like the one of ResetEnergy, have room for an error.
+	size := n
+	if size < errorLen {
+		size = errorLen
+	}
+	resp := make([]byte, n, size)
+	if _, err := io.ReadFull(d.Port, resp[:2]); err != nil {
+		return nil, err
+	}
+	if resp[1]&errorFunction != 0 {
+		resp = resp[:errorLen]
+	}
+	if _, err := io.ReadFull(d.Port, resp[2:]); err != nil {
+		return nil, err
+	}
+	glog.V(2).Infof("pzem004t: received % x", resp)
+
+	l := len(resp)
+	if crc(resp[:l-2]) != uint16(resp[l-1])<<8|uint16(resp[l-2]) {
+		return nil, ErrChecksum
+	}
+	if resp[1]&errorFunction != 0 {
+		return nil, &ModbusError{Function: resp[1] &^ errorFunction, Code: resp[2]}
+	}
+	if resp[1] != request[1] {
+		return nil, fmt.Errorf("pzem004t: response to function %#02x, want %#02x", resp[1], request[1])
+	}
+	return resp[:l-2], nil
+}
+
+func (d *PZEM004T) readRegisters(function byte, reg, count uint16) ([]uint16, error) {
+	resp, err := d.transact([]byte{function, byte(reg >> 8), byte(reg), byte(count >> 8), byte(count)}, 5+2*int(count))
+	if err != nil {
+		return nil, err
+	}
+	data := resp[3:]
+	regs := make([]uint16, count)
+	for i := range regs {
+		regs[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+	}
+	return regs, nil
+}
+
+func (d *PZEM004T) writeRegister(reg, value uint16) error {
+	_, err := d.transact([]byte{writeSingle, byte(reg >> 8), byte(reg), byte(value >> 8), byte(value)}, 8)
+	return err
+}
+
+// Read reads all the measurements.
+func (d *PZEM004T) Read() (*Reading, error) {
+	regs, err := d.readRegisters(readInput, 0, measurementRegs)
+	if err != nil {
+		return nil, err
+	}
+	// 32 bit values are sent low word first.
+	long := func(i int) float64 {
+		return float64(uint32(regs[i+1])<<16 | uint32(regs[i]))
+	}
+	return &Reading{
+		Voltage:     units.Voltage(regs[0]) * units.Volt / 10,
+		Current:     long(1) / 1000,
+		Power:       long(3) / 10,
+		Energy:      long(5),
+		Frequency:   float64(regs[7]) / 10,
+		PowerFactor: float64(regs[8]) / 100,
+		Alarm:       regs[9] == 0xFFFF,
+	}, nil
+}
+
+// Address reads the slave address of the monitor. Use it with a driver
+// created for GeneralAddress to find the address of a lone monitor.
+func (d *PZEM004T) Address() (byte, error) {
+	regs, err := d.readRegisters(readHolding, addressReg, 1)
+	if err != nil {
+		return 0, err
+	}
+	return byte(regs[0]), nil
+}
+
+// SetAddress changes the slave address of the monitor, so that several
+// monitors can share a bus. The driver then talks to the new address.
+func (d *PZEM004T) SetAddress(addr byte) error {
+	if addr == 0 || addr > maxAddress {
+		return fmt.Errorf("pzem004t: invalid address %#02x", addr)
+	}
+	if err := d.writeRegister(addressReg, uint16(addr)); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.Addr = addr
+	d.mu.Unlock()
+	return nil
+}
+
+// AlarmThreshold reads the power alarm threshold in watts.
+func (d *PZEM004T) AlarmThreshold() (int, error) {
+	regs, err := d.readRegisters(readHolding, alarmThresholdReg, 1)
+	if err != nil {
+		return 0, err
+	}
+	return int(regs[0]), nil
+}
+
+// SetAlarmThreshold sets the power alarm threshold in watts.
+func (d *PZEM004T) SetAlarmThreshold(watts int) error {
+	if watts < 0 || watts > 0xFFFF {
+		return fmt.Errorf("pzem004t: invalid alarm threshold %v", watts)
+	}
+	return d.writeRegister(alarmThresholdReg, uint16(watts))
+}
+
+// ResetEnergy resets the energy counter to zero.
+func (d *PZEM004T) ResetEnergy() error {
+	_, err := d.transact([]byte{resetEnergy}, 4)
+	return err
+}
@@ -0,0 +1,97 @@
+package pzem004t
+
+import (
+	"bytes"
+	"testing"
+)
+
+type fakePort struct {
+	written  bytes.Buffer
+	response bytes.Buffer
+}
+
+func (p *fakePort) Write(b []byte) (int, error) { return p.written.Write(b) }
+func (p *fakePort) Read(b []byte) (int, error)  { return p.response.Read(b) }
+
+func TestCRC(t *testing.T) {
+	// The read command from the datasheet.
+	got := appendCRC([]byte{0x01, 0x04, 0x00, 0x00, 0x00, 0x0A})
+	want := []byte{0x01, 0x04, 0x00, 0x00, 0x00, 0x0A, 0x70, 0x0D}
+	if !bytes.Equal(got, want) {
+		t.Errorf("frame = % x; want % x", got, want)
+	}
+}
+
+func TestRead(t *testing.T) {
+	port := &fakePort{}
+	port.response.Write(appendCRC([]byte{
+		0x01, 0x04, 20,
+		0x08, 0xFC, // 230.0 V
+		0x03, 0xE8, 0x00, 0x00, // 1.000 A
+		0x08, 0xFC, 0x00, 0x00, // 230.0 W
+		0x86, 0xA0, 0x00, 0x01, // 100000 Wh
+		0x01, 0xF4, // 50.0 Hz
+		0x00, 0x64, // 1.00
+		0x00, 0x00, // no alarm
+	}))
+	d := New(port, DefaultAddress)
+
+	r, err := d.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Reading{Voltage: 230, Current: 1, Power: 230, Energy: 100000, Frequency: 50, PowerFactor: 1}
+	if *r != want {
+		t.Errorf("reading = %+v; want %+v", *r, want)
+	}
+	if got := port.written.Bytes(); !bytes.Equal(got, []byte{0x01, 0x04, 0x00, 0x00, 0x00, 0x0A, 0x70, 0x0D}) {
+		t.Errorf("sent % x", got)
+	}
+}
+
+func TestResetEnergy(t *testing.T) {
+	port := &fakePort{}
+	port.response.Write(appendCRC([]byte{0x01, 0x42}))
+	d := New(port, DefaultAddress)
+	if err := d.ResetEnergy(); err != nil {
+		t.Fatal(err)
+	}
+	if got := port.written.Bytes(); !bytes.Equal(got, []byte{0x01, 0x42, 0x80, 0x11}) {
+		t.Errorf("sent % x", got)
+	}
+}
+
+func TestResetEnergy_error(t *testing.T) {
+	port := &fakePort{}
+	port.response.Write(appendCRC([]byte{0x01, 0xC2, 0x04}))
+	d := New(port, DefaultAddress)
+	err := d.ResetEnergy()
+	if e, ok := err.(*ModbusError); !ok || e.Function != 0x42 || e.Code != 0x04 {
+		t.Errorf("err = %v; want a device failure error", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	port := &fakePort{}
+	port.response.Write(appendCRC([]byte{0x01, 0x86, 0x02}))
+	d := New(port, DefaultAddress)
+	err := d.SetAddress(0x05)
+	if e, ok := err.(*ModbusError); !ok || e.Function != 0x06 || e.Code != 0x02 {
+		t.Errorf("err = %v; want an illegal address error", err)
+	}
+	if d.Addr != DefaultAddress {
+		t.Errorf("address changed to %#x after an error", d.Addr)
+	}
+
+	port.response.Reset()
+	resp := appendCRC([]byte{0x01, 0x06, 0x00, 0x02, 0x00, 0x05})
+	resp[len(resp)-1] ^= 0xFF
+	port.response.Write(resp)
+	if err := d.SetAddress(0x05); err != ErrChecksum {
+		t.Errorf("err = %v; want %v", err, ErrChecksum)
+	}
+
+	if err := d.SetAddress(0xF8); err == nil {
+		t.Error("no error for an out of range address")
+	}
+}
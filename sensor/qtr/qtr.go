@@ -0,0 +1,301 @@
+// Package qtr allows interfacing with QTR style reflectance sensor arrays,
+// like the Pololu QTR-8A and QTR-8RC, typically used by line following robots.
+package qtr
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+// An ADC interface implements access to analog to digital convertors, like
+// the MCP3008.
+type ADC interface {
+	AnalogValueAt(chanNum int) (int, error)
+}
+
+// MaxValue is the value of a calibrated reading over a fully dark surface.
+const MaxValue = 1000
+
+// DefaultTimeout is the default discharge timeout of RC sensors.
+const DefaultTimeout = 2500 * time.Microsecond
+
+// ErrNotCalibrated is returned when reading calibrated values before any
+// calibration samples were taken.
+var ErrNotCalibrated = errors.New("qtr: not calibrated")
+
+type source interface {
+	read(values []int) error
+	max() int
+}
+
+type analogSource struct {
+	adc      ADC
+	channels []int
+}
+
+func (s *analogSource) read(values []int) error {
+	for i, ch := range s.channels {
+		v, err := s.adc.AnalogValueAt(ch)
+		if err != nil {
+			return err
+		}
+		// Analog outputs are high over light surfaces; invert them so that
+		// both sensor types read high over the line.
+		values[i] = 1023 - v
+	}
+	return nil
+}
+
+func (s *analogSource) max() int {
+	return 1023
+}
+
+type rcSource struct {
+	pins    []embd.DigitalPin
+	timeout time.Duration
+}
+
+// read charges the sensor capacitors and times how long each output takes
+// to discharge; darker surfaces reflect less light and discharge slower.
+func (s *rcSource) read(values []int) error {
+	for _, pin := range s.pins {
+		if err := pin.SetDirection(embd.Out); err != nil {
+			return err
+		}
+		if err := pin.Write(embd.High); err != nil {
+			return err
+		}
+	}
+	time.Sleep(10 * time.Microsecond)
+
+	for i, pin := range s.pins {
+		if err := pin.SetDirection(embd.In); err != nil {
+			return err
+		}
+		values[i] = int(s.timeout / time.Microsecond)
+	}
+
+	start := time.Now()
+	pending := len(s.pins)
+	for pending > 0 {
+		elapsed := time.Since(start)
+		if elapsed >= s.timeout {
+			break
+		}
+		for i, pin := range s.pins {
+			if values[i] < int(s.timeout/time.Microsecond) {
+				continue
+			}
+			v, err := pin.Read()
+			if err != nil {
+				return err
+			}
+			if v == embd.Low {
+				values[i] = int(elapsed / time.Microsecond)
+				pending--
+			}
+		}
+	}
+	return nil
+}
+
+func (s *rcSource) max() int {
+	return int(s.timeout / time.Microsecond)
+}
+
+// QTR represents a reflectance sensor array.
+type QTR struct {
+	// WhiteLine should be set when following a light line on a dark surface.
+	WhiteLine bool
+
+	// Threshold is the calibrated value above which a sensor is considered to
+	// see the line.
+	Threshold int
+
+	src source
+	n   int
+
+	mu       sync.Mutex
+	min, max []int
+	last     int
+}
+
+func newQTR(src source, n int) *QTR {
+	return &QTR{
+		Threshold: 200,
+		src:       src,
+		n:         n,
+	}
+}
+
+// NewAnalog creates a new array of analog sensors (like the QTR-8A) read
+// through an ADC, one channel per sensor from left to right.
+func NewAnalog(adc ADC, channels ...int) *QTR {
+	return newQTR(&analogSource{adc: adc, channels: channels}, len(channels))
+}
+
+// NewRC creates a new array of RC sensors (like the QTR-8RC), one GPIO pin
+// per sensor from left to right. Readings over timeout are capped.
+func NewRC(timeout time.Duration, pins ...embd.DigitalPin) *QTR {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return newQTR(&rcSource{pins: pins, timeout: timeout}, len(pins))
+}
+
+// Len returns the number of sensors.
+func (q *QTR) Len() int {
+	return q.n
+}
+
+// Raw returns uncalibrated readings, higher values meaning darker surfaces.
+func (q *QTR) Raw() ([]int, error) {
+	values := make([]int, q.n)
+	if err := q.src.read(values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Calibrate takes a calibration sample, updating the minimum and maximum
+// reading of every sensor. Call it repeatedly while sweeping the array over
+// the line and the surface around it.
+func (q *QTR) Calibrate() error {
+	values, err := q.Raw()
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.min == nil {
+		q.min = append([]int(nil), values...)
+		q.max = append([]int(nil), values...)
+		return nil
+	}
+	for i, v := range values {
+		if v < q.min[i] {
+			q.min[i] = v
+		}
+		if v > q.max[i] {
+			q.max[i] = v
+		}
+	}
+	return nil
+}
+
+// ResetCalibration discards the calibration samples.
+func (q *QTR) ResetCalibration() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.min, q.max = nil, nil
+}
+
+// SetCalibration sets the minimum and maximum readings of every sensor, for
+// example restored from a previous run.
+func (q *QTR) SetCalibration(min, max []int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.min = append([]int(nil), min...)
+	q.max = append([]int(nil), max...)
+}
+
+// Calibration returns the minimum and maximum readings of every sensor.
+func (q *QTR) Calibration() (min, max []int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]int(nil), q.min...), append([]int(nil), q.max...)
+}
+
+// Calibrated returns readings scaled between 0 (lightest seen during
+// calibration) and MaxValue (darkest seen).
+func (q *QTR) Calibrated() ([]int, error) {
+	values, err := q.Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.min == nil {
+		return nil, ErrNotCalibrated
+	}
+	for i, v := range values {
+		values[i] = scale(v, q.min[i], q.max[i])
+		if q.WhiteLine {
+			values[i] = MaxValue - values[i]
+		}
+	}
+	return values, nil
+}
+
+func scale(v, min, max int) int {
+	if max <= min {
+		return 0
+	}
+	v = (v - min) * MaxValue / (max - min)
+	if v < 0 {
+		return 0
+	}
+	if v > MaxValue {
+		return MaxValue
+	}
+	return v
+}
+
+// Position returns the position of the line under the array, from 0 (under
+// the first sensor) to (Len()-1)*MaxValue (under the last one), estimated as
+// the average of the sensor positions weighted by their calibrated readings.
+// When no sensor sees the line, the position is reported as being past the
+// side where the line was last seen.
+func (q *QTR) Position() (int, error) {
+	values, err := q.Calibrated()
+	if err != nil {
+		return 0, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pos, ok := position(values, q.Threshold)
+	if !ok {
+		if q.last < (q.n-1)*MaxValue/2 {
+			pos = 0
+		} else {
+			pos = (q.n - 1) * MaxValue
+		}
+		glog.V(2).Infof("qtr: line lost, assuming position %v", pos)
+	}
+	q.last = pos
+	return pos, nil
+}
+
+// position returns the weighted average of the sensor positions, and false
+// if no reading is above threshold.
+func position(values []int, threshold int) (int, bool) {
+	var sum, weights int
+	seen := false
+	for i, v := range values {
+		if v > threshold {
+			seen = true
+		}
+		// Ignore noise from sensors away from the line.
+		if v > threshold/4 {
+			sum += v * i * MaxValue
+			weights += v
+		}
+	}
+	if !seen || weights == 0 {
+		return 0, false
+	}
+	return sum / weights, true
+}
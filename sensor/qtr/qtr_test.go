@@ -0,0 +1,84 @@
+package qtr
+
+import (
+	"testing"
+)
+
+type mockADC struct {
+	values []int
+}
+
+func (a *mockADC) AnalogValueAt(chanNum int) (int, error) {
+	return a.values[chanNum], nil
+}
+
+func calibrated(t *testing.T) (*QTR, *mockADC) {
+	adc := &mockADC{}
+	q := NewAnalog(adc, 0, 1, 2, 3)
+	// Sweep: all light, then all dark.
+	for _, v := range []int{1000, 100} {
+		adc.values = []int{v, v, v, v}
+		if err := q.Calibrate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return q, adc
+}
+
+func TestCalibrated(t *testing.T) {
+	q := NewAnalog(&mockADC{values: []int{0}}, 0)
+	if _, err := q.Calibrated(); err != ErrNotCalibrated {
+		t.Errorf("Calibrated before calibration: got %v, want %v", err, ErrNotCalibrated)
+	}
+
+	q, adc := calibrated(t)
+	adc.values = []int{1000, 550, 100, 1023}
+	values, err := q.Calibrated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{0, 500, 1000, 0}
+	for i := range expected {
+		if values[i] != expected[i] {
+			t.Errorf("Calibrated: got %v, want %v", values, expected)
+			break
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	q, adc := calibrated(t)
+	var tests = []struct {
+		values []int
+		pos    int
+	}{
+		{[]int{100, 1000, 1000, 1000}, 0},
+		{[]int{1000, 100, 100, 1000}, 1500},
+		{[]int{1000, 1000, 550, 100}, 2666},
+		// Line lost after being seen on the right.
+		{[]int{1000, 1000, 1000, 1000}, 3000},
+	}
+	for _, test := range tests {
+		adc.values = test.values
+		pos, err := q.Position()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos != test.pos {
+			t.Errorf("Position of %v: got %v, want %v", test.values, pos, test.pos)
+		}
+	}
+}
+
+func TestPosition_whiteLine(t *testing.T) {
+	q, adc := calibrated(t)
+	q.WhiteLine = true
+	adc.values = []int{100, 100, 100, 1000}
+	pos, err := q.Position()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != 3000 {
+		t.Errorf("Position: got %v, want 3000", pos)
+	}
+}
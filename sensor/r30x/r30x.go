@@ -0,0 +1,470 @@
+/*
+Package r30x allows interfacing with the R30x (R305, R307) and FPM10A optical
+fingerprint modules over their serial interface.
+
+The modules talk at 57600 baud, 8N1. The serial port is opened by the caller
+(any io.ReadWriter will do), and should have a read timeout so that an
+unplugged module does not hang the driver. The high level Enroll and
+Identify routines take care of the capture and matching sequences, and
+report the instructions for the user through a prompt callback, which can
+write them to a character display:
+
+	d := r30x.New(port)
+	d.Prompt = func(msg string) {
+		display.Clear()
+		display.Message(msg)
+	}
+	if err := d.Enroll(1); err != nil {
+		panic(err)
+	}
+	m, err := d.Identify()
+*/
+package r30x
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+const (
+	// DefaultAddress is the factory module address.
+	DefaultAddress = 0xFFFFFFFF
+
+	// DefaultCapacity is the number of templates of the R307 and FPM10A.
+	DefaultCapacity = 1000
+
+	header = 0xEF01
+
+	commandPacket = 0x01
+	dataPacket    = 0x02
+	ackPacket     = 0x07
+	endPacket     = 0x08
+
+	genImg      = 0x01
+	img2Tz      = 0x02
+	match       = 0x03
+	search      = 0x04
+	regModel    = 0x05
+	store       = 0x06
+	loadChar    = 0x07
+	upChar      = 0x08
+	downChar    = 0x09
+	deleteChar  = 0x0C
+	empty       = 0x0D
+	verifyPwd   = 0x13
+	templateNum = 0x1D
+
+	dataPacketSize = 128
+
+	fingerPoll    = 100 * time.Millisecond
+	fingerTimeout = 10 * time.Second
+)
+
+// Error is a confirmation code returned by the module.
+type Error byte
+
+// Confirmation codes.
+const (
+	ErrPacket        Error = 0x01
+	ErrNoFinger      Error = 0x02
+	ErrEnrollImage   Error = 0x03
+	ErrMessyImage    Error = 0x06
+	ErrFewFeatures   Error = 0x07
+	ErrNoMatch       Error = 0x08
+	ErrNotFound      Error = 0x09
+	ErrCombine       Error = 0x0A
+	ErrBadID         Error = 0x0B
+	ErrReadTemplate  Error = 0x0C
+	ErrUpload        Error = 0x0D
+	ErrReceive       Error = 0x0E
+	ErrDelete        Error = 0x10
+	ErrClear         Error = 0x11
+	ErrWrongPassword Error = 0x13
+	ErrNoImage       Error = 0x15
+	ErrFlash         Error = 0x18
+)
+
+var errorMessages = map[Error]string{
+	ErrPacket:        "error receiving packet",
+	ErrNoFinger:      "no finger on the sensor",
+	ErrEnrollImage:   "failed to capture image",
+	ErrMessyImage:    "image too messy",
+	ErrFewFeatures:   "too few feature points",
+	ErrNoMatch:       "fingerprints do not match",
+	ErrNotFound:      "no matching fingerprint found",
+	ErrCombine:       "failed to combine the fingerprints",
+	ErrBadID:         "template id out of range",
+	ErrReadTemplate:  "error reading template",
+	ErrUpload:        "error uploading template",
+	ErrReceive:       "error receiving data packets",
+	ErrDelete:        "failed to delete template",
+	ErrClear:         "failed to clear the library",
+	ErrWrongPassword: "wrong password",
+	ErrNoImage:       "no valid image in the buffer",
+	ErrFlash:         "error writing to flash",
+}
+
+func (e Error) Error() string {
+	if msg, ok := errorMessages[e]; ok {
+		return "r30x: " + msg
+	}
+	return fmt.Sprintf("r30x: error %#02x", byte(e))
+}
+
+// ErrChecksum is returned when a packet is corrupted.
+var ErrChecksum = errors.New("r30x: bad packet checksum")
+
+// ErrTimeout is returned when the user did not place or remove their finger
+// in time.
+var ErrTimeout = embd.NewError(embd.ErrTimeout, "r30x: timed out waiting for the finger")
+
+// Match is the result of a fingerprint search.
+type Match struct {
+	ID uint16
+
+	// Score is the confidence of the match, higher is better.
+	Score uint16
+}
+
+// R30X represents a R30x or FPM10A fingerprint module.
+type R30X struct {
+	Port io.ReadWriter
+	Addr uint32
+
+	// Capacity is the number of templates the module holds.
+	Capacity uint16
+
+	// Prompt, if set, receives the instructions for the user during Enroll
+	// and Identify.
+	Prompt func(msg string)
+
+	// FingerTimeout is how long Enroll and Identify wait for the user to
+	// place or remove their finger.
+	FingerTimeout time.Duration
+
+	mu sync.Mutex
+}
+
+// New creates a new fingerprint module interface. The port variable is the
+// serial port used to communicate with the device.
+func New(port io.ReadWriter) *R30X {
+	return &R30X{Port: port, Addr: DefaultAddress, Capacity: DefaultCapacity, FingerTimeout: fingerTimeout}
+}
+
+func (d *R30X) writePacket(pid byte, payload []byte) error {
+	n := len(payload) + 2
+	p := []byte{
+		header >> 8, header & 0xFF,
+		byte(d.Addr >> 24), byte(d.Addr >> 16), byte(d.Addr >> 8), byte(d.Addr),
+		pid, byte(n >> 8), byte(n),
+	}
+	p = append(p, payload...)
+	sum := checksum(p[6:])
+	p = append(p, byte(sum>>8), byte(sum))
+	glog.V(2).Infof("r30x: sending % x", p)
+	_, err := d.Port.Write(p)
+	return err
+}
+
+func (d *R30X) readPacket() (byte, []byte, error) {
+	head := make([]byte, 9)
+	if _, err := io.ReadFull(d.Port, head); err != nil {
+		return 0, nil, err
+	}
+	if head[0] != header>>8 || head[1] != header&0xFF {
+		return 0, nil, fmt.Errorf("r30x: bad packet header % x", head[:2])
+	}
+	n := int(head[7])<<8 | int(head[8])
+	if n < 2 {
+		return 0, nil, fmt.Errorf("r30x: bad packet length %v", n)
+	}
+	rest := make([]byte, n)
+	if _, err := io.ReadFull(d.Port, rest); err != nil {
+		return 0, nil, err
+	}
+	glog.V(2).Infof("r30x: received % x % x", head, rest)
+
+	sum := checksum(append(head[6:], rest[:n-2]...))
+	if sum != uint16(rest[n-2])<<8|uint16(rest[n-1]) {
+		return 0, nil, ErrChecksum
+	}
+	return head[6], rest[:n-2], nil
+}
+
+func checksum(b []byte) uint16 {
+	var sum uint16
+	for _, v := range b {
+		sum += uint16(v)
+	}
+	return sum
+}
+
+// command sends an instruction and returns the parameters of the ack.
+func (d *R30X) command(payload ...byte) ([]byte, error) {
+	if err := d.writePacket(commandPacket, payload); err != nil {
+		return nil, err
+	}
+	pid, ack, err := d.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if pid != ackPacket || len(ack) < 1 {
+		return nil, fmt.Errorf("r30x: unexpected packet %#02x in response to %#02x", pid, payload[0])
+	}
+	if ack[0] != 0 {
+		return nil, Error(ack[0])
+	}
+	return ack[1:], nil
+}
+
+// VerifyPassword unlocks a module protected by a password.
+func (d *R30X) VerifyPassword(pwd uint32) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.command(verifyPwd, byte(pwd>>24), byte(pwd>>16), byte(pwd>>8), byte(pwd))
+	return err
+}
+
+// CaptureImage captures a fingerprint image. It returns ErrNoFinger when no
+// finger is on the sensor.
+func (d *R30X) CaptureImage() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.command(genImg)
+	return err
+}
+
+// Convert extracts the features of the captured image into the character
+// buffer 1 or 2.
+func (d *R30X) Convert(buffer byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.command(img2Tz, buffer)
+	return err
+}
+
+// CreateModel combines the features of both character buffers into a
+// template, which is placed in both buffers.
+func (d *R30X) CreateModel() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.command(regModel)
+	return err
+}
+
+// Compare compares the features of both character buffers, returning the
+// score of the match.
+func (d *R30X) Compare() (uint16, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	resp, err := d.command(match)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp) < 2 {
+		return 0, fmt.Errorf("r30x: short match response")
+	}
+	return uint16(resp[0])<<8 | uint16(resp[1]), nil
+}
+
+// Store stores the template in a character buffer to the library at id.
+func (d *R30X) Store(buffer byte, id uint16) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.command(store, buffer, byte(id>>8), byte(id))
+	return err
+}
+
+// Load loads the template at id in the library to a character buffer.
+func (d *R30X) Load(buffer byte, id uint16) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.command(loadChar, buffer, byte(id>>8), byte(id))
+	return err
+}
+
+// Search searches count templates of the library, from start, for the
+// features in a character buffer. It returns ErrNotFound when none match.
+func (d *R30X) Search(buffer byte, start, count uint16) (Match, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	resp, err := d.command(search, buffer, byte(start>>8), byte(start), byte(count>>8), byte(count))
+	if err != nil {
+		return Match{}, err
+	}
+	if len(resp) < 4 {
+		return Match{}, fmt.Errorf("r30x: short search response")
+	}
+	return Match{
+		ID:    uint16(resp[0])<<8 | uint16(resp[1]),
+		Score: uint16(resp[2])<<8 | uint16(resp[3]),
+	}, nil
+}
+
+// Delete deletes count templates from the library, from id.
+func (d *R30X) Delete(id, count uint16) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.command(deleteChar, byte(id>>8), byte(id), byte(count>>8), byte(count))
+	return err
+}
+
+// Empty deletes all the templates from the library.
+func (d *R30X) Empty() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.command(empty)
+	return err
+}
+
+// TemplateCount returns the number of templates in the library.
+func (d *R30X) TemplateCount() (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	resp, err := d.command(templateNum)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp) < 2 {
+		return 0, fmt.Errorf("r30x: short template count response")
+	}
+	return int(resp[0])<<8 | int(resp[1]), nil
+}
+
+// DownloadTemplate downloads the template in a character buffer from the
+// module, to back it up or copy it to another module.
+func (d *R30X) DownloadTemplate(buffer byte) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.command(upChar, buffer); err != nil {
+		return nil, err
+	}
+	var template []byte
+	for {
+		pid, data, err := d.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		if pid != dataPacket && pid != endPacket {
+			return nil, fmt.Errorf("r30x: unexpected packet %#02x in template", pid)
+		}
+		template = append(template, data...)
+		if pid == endPacket {
+			return template, nil
+		}
+	}
+}
+
+// UploadTemplate uploads a template to a character buffer of the module.
+// Use Store to save it to the library.
+func (d *R30X) UploadTemplate(buffer byte, template []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.command(downChar, buffer); err != nil {
+		return err
+	}
+	for len(template) > 0 {
+		pid := byte(dataPacket)
+		n := dataPacketSize
+		if len(template) <= n {
+			pid, n = endPacket, len(template)
+		}
+		if err := d.writePacket(pid, template[:n]); err != nil {
+			return err
+		}
+		template = template[n:]
+	}
+	return nil
+}
+
+func (d *R30X) prompt(msg string) {
+	glog.V(1).Infof("r30x: %v", msg)
+	if d.Prompt != nil {
+		d.Prompt(msg)
+	}
+}
+
+// waitFinger polls the sensor until a finger is placed (or removed) and
+// captures its image.
+func (d *R30X) waitFinger(present bool) error {
+	deadline := time.Now().Add(d.FingerTimeout)
+	for time.Now().Before(deadline) {
+		err := d.CaptureImage()
+		switch {
+		case err == nil && present:
+			return nil
+		case err == ErrNoFinger && !present:
+			return nil
+		case err != nil && err != ErrNoFinger:
+			return err
+		}
+		time.Sleep(fingerPoll)
+	}
+	return ErrTimeout
+}
+
+// Enroll guides the user through enrolling a finger, which is read twice,
+// and stores its template to the library at id.
+func (d *R30X) Enroll(id uint16) error {
+	d.prompt("Place finger")
+	if err := d.waitFinger(true); err != nil {
+		return err
+	}
+	if err := d.Convert(1); err != nil {
+		return err
+	}
+
+	d.prompt("Remove finger")
+	if err := d.waitFinger(false); err != nil {
+		return err
+	}
+
+	d.prompt("Place same finger again")
+	if err := d.waitFinger(true); err != nil {
+		return err
+	}
+	if err := d.Convert(2); err != nil {
+		return err
+	}
+
+	if err := d.CreateModel(); err != nil {
+		return err
+	}
+	if err := d.Store(1, id); err != nil {
+		return err
+	}
+	d.prompt("Enrolled")
+	return nil
+}
+
+// Identify waits for a finger and searches the library for it. It returns
+// ErrNotFound when the finger is not enrolled.
+func (d *R30X) Identify() (Match, error) {
+	d.prompt("Place finger")
+	if err := d.waitFinger(true); err != nil {
+		return Match{}, err
+	}
+	if err := d.Convert(1); err != nil {
+		return Match{}, err
+	}
+	return d.Search(1, 0, d.Capacity)
+}
@@ -0,0 +1,129 @@
+package r30x
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type fakePort struct {
+	written  bytes.Buffer
+	response bytes.Buffer
+}
+
+func (p *fakePort) Write(b []byte) (int, error) { return p.written.Write(b) }
+func (p *fakePort) Read(b []byte) (int, error)  { return p.response.Read(b) }
+
+func (p *fakePort) respond(pid byte, payload ...byte) {
+	d := New(&p.response)
+	d.writePacket(pid, payload)
+}
+
+func TestCaptureImage(t *testing.T) {
+	port := &fakePort{}
+	port.respond(ackPacket, 0x02)
+	d := New(port)
+
+	if err := d.CaptureImage(); err != ErrNoFinger {
+		t.Errorf("err = %v; want %v", err, ErrNoFinger)
+	}
+	want := []byte{0xEF, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0x01, 0x00, 0x03, 0x01, 0x00, 0x05}
+	if got := port.written.Bytes(); !bytes.Equal(got, want) {
+		t.Errorf("sent % x; want % x", got, want)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	port := &fakePort{}
+	port.respond(ackPacket, 0x00, 0x00, 0x07, 0x00, 0x9C)
+	d := New(port)
+
+	m, err := d.Search(1, 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != (Match{ID: 7, Score: 156}) {
+		t.Errorf("match = %+v; want id 7 with score 156", m)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	port := &fakePort{}
+	port.respond(ackPacket, 0x00)
+	b := port.response.Bytes()
+	b[len(b)-1]++
+	if err := New(port).Empty(); err != ErrChecksum {
+		t.Errorf("err = %v; want %v", err, ErrChecksum)
+	}
+}
+
+func TestTemplateTransfer(t *testing.T) {
+	template := make([]byte, 300)
+	for i := range template {
+		template[i] = byte(i)
+	}
+
+	port := &fakePort{}
+	port.respond(ackPacket, 0x00)
+	port.respond(dataPacket, template[:128]...)
+	port.respond(dataPacket, template[128:256]...)
+	port.respond(endPacket, template[256:]...)
+	d := New(port)
+	got, err := d.DownloadTemplate(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, template) {
+		t.Errorf("downloaded % x; want % x", got, template)
+	}
+
+	port = &fakePort{}
+	port.respond(ackPacket, 0x00)
+	d = New(port)
+	if err := d.UploadTemplate(2, template); err != nil {
+		t.Fatal(err)
+	}
+	// Read back the command and the data packets.
+	port.response = port.written
+	var sent []byte
+	for {
+		pid, data, err := d.readPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pid == commandPacket {
+			continue
+		}
+		sent = append(sent, data...)
+		if pid == endPacket {
+			break
+		}
+	}
+	if !bytes.Equal(sent, template) {
+		t.Errorf("uploaded % x; want % x", sent, template)
+	}
+}
+
+func TestEnroll(t *testing.T) {
+	port := &fakePort{}
+	for _, code := range []byte{
+		0x02, 0x00, // no finger, then captured
+		0x00,       // converted
+		0x00, 0x02, // still there, then removed
+		0x00, 0x00, // captured, converted
+		0x00, 0x00, // model created, stored
+	} {
+		port.respond(ackPacket, code)
+	}
+	d := New(port)
+	d.FingerTimeout = time.Second
+	var prompts []string
+	d.Prompt = func(msg string) { prompts = append(prompts, msg) }
+
+	if err := d.Enroll(3); err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 4 {
+		t.Errorf("prompts = %q; want 4", prompts)
+	}
+}
@@ -0,0 +1,219 @@
+// Sampling scheduler.
+
+package sensor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/health"
+)
+
+// TaskStats are the statistics of a scheduled task.
+type TaskStats struct {
+	Runs   int
+	Errors int
+
+	// Missed is the number of deadlines skipped because the previous run
+	// finished too late.
+	Missed int
+
+	// MaxLateness is the longest delay between a deadline and the start of
+	// its run.
+	MaxLateness time.Duration
+
+	LastErr error
+}
+
+// Task is a periodic sensor read registered with a Scheduler.
+type Task struct {
+	Name string
+
+	period, phase time.Duration
+	read          func(deadline time.Time) error
+
+	mu    sync.Mutex
+	next  time.Time
+	stats TaskStats
+}
+
+// Stats returns the statistics of the task.
+func (t *Task) Stats() TaskStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.stats
+}
+
+// Scheduler runs periodic sensor reads. Deadlines are computed from a fixed
+// epoch on the monotonic clock rather than from the previous run, so they do
+// not drift however late the runs are. Tasks run one at a time on a single
+// goroutine, and tasks sharing a period can be given different phases to
+// spread their bus traffic over the period:
+//
+//	s := sensor.NewScheduler()
+//	s.Add("baro", 100*time.Millisecond, 0, readBaro)
+//	s.Add("gyro", 100*time.Millisecond, 50*time.Millisecond, readGyro)
+//	s.Run()
+//	defer s.Close()
+type Scheduler struct {
+	clock clock.Clock
+	epoch time.Time
+
+	mu    sync.Mutex
+	tasks []*Task
+
+	changed chan struct{}
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+// NewScheduler creates a new Scheduler.
+func NewScheduler() *Scheduler {
+	return NewSchedulerWithClock(clock.Real)
+}
+
+// NewSchedulerWithClock creates a new Scheduler timing the tasks with c, like
+// a clock.Virtual in tests.
+func NewSchedulerWithClock(c clock.Clock) *Scheduler {
+	return &Scheduler{
+		clock:   c,
+		epoch:   c.Now(),
+		changed: make(chan struct{}, 1),
+	}
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Add schedules read to run every period, offset by phase from the start of
+// the period. read is passed the deadline of the run.
+func (s *Scheduler) Add(name string, period, phase time.Duration, read func(deadline time.Time) error) *Task {
+	if period <= 0 {
+		panic("sensor: non-positive scheduling period")
+	}
+	t := &Task{Name: name, period: period, phase: phase % period, read: read}
+
+	// The first deadline is the next one after now.
+	first := s.epoch.Add(t.phase)
+	if since := s.clock.Now().Sub(first); since > 0 {
+		first = first.Add((since/period + 1) * period)
+	}
+	t.next = first
+
+	s.mu.Lock()
+	s.tasks = append(s.tasks, t)
+	s.mu.Unlock()
+	s.notify()
+
+	return t
+}
+
+// Remove unschedules a task.
+func (s *Scheduler) Remove(t *Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, other := range s.tasks {
+		if other == t {
+			s.tasks = append(s.tasks[:i], s.tasks[i+1:]...)
+			break
+		}
+	}
+}
+
+// earliest returns the task with the earliest deadline.
+func (s *Scheduler) earliest() (*Task, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var first *Task
+	var deadline time.Time
+	for _, t := range s.tasks {
+		t.mu.Lock()
+		next := t.next
+		t.mu.Unlock()
+		if first == nil || next.Before(deadline) {
+			first, deadline = t, next
+		}
+	}
+	return first, deadline
+}
+
+func (s *Scheduler) run(t *Task, deadline time.Time) {
+	lateness := s.clock.Now().Sub(deadline)
+	err := t.read(deadline)
+	end := s.clock.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stats.Runs++
+	if lateness > t.stats.MaxLateness {
+		t.stats.MaxLateness = lateness
+	}
+	if err != nil {
+		t.stats.Errors++
+		t.stats.LastErr = err
+		glog.Errorf("sensor: %v: %v", t.Name, err)
+	}
+	health.Observe("sensor/"+t.Name, err)
+
+	next := deadline.Add(t.period)
+	if !next.After(end) {
+		skipped := end.Sub(next)/t.period + 1
+		t.stats.Missed += int(skipped)
+		next = next.Add(skipped * t.period)
+		glog.V(1).Infof("sensor: %v: missed %v deadlines", t.Name, skipped)
+	}
+	t.next = next
+}
+
+// Run starts running the tasks in the background.
+func (s *Scheduler) Run() {
+	s.quit = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		for {
+			t, deadline := s.earliest()
+			if t == nil {
+				select {
+				case <-s.changed:
+					continue
+				case <-s.quit:
+					return
+				}
+			}
+
+			timer := s.clock.NewTimer(deadline.Sub(s.clock.Now()))
+			select {
+			case <-timer.C():
+				s.run(t, deadline)
+			case <-s.changed:
+				timer.Stop()
+			case <-s.quit:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Close stops running the tasks, waiting for the current one to finish.
+func (s *Scheduler) Close() {
+	if s.quit == nil {
+		return
+	}
+	close(s.quit)
+	<-s.done
+	s.quit = nil
+}
@@ -0,0 +1,100 @@
+package sensor
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/clock"
+)
+
+func TestScheduler(t *testing.T) {
+	s := NewScheduler()
+
+	const period = 10 * time.Millisecond
+	var mu sync.Mutex
+	deadlines := make(map[string][]time.Time)
+	record := func(name string) func(time.Time) error {
+		return func(deadline time.Time) error {
+			mu.Lock()
+			defer mu.Unlock()
+			deadlines[name] = append(deadlines[name], deadline)
+			return nil
+		}
+	}
+	a := s.Add("a", period, 0, record("a"))
+	b := s.Add("b", period, period/2, record("b"))
+
+	s.Run()
+	time.Sleep(10 * period)
+	s.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for name, phase := range map[string]time.Duration{"a": 0, "b": period / 2} {
+		ds := deadlines[name]
+		if len(ds) < 5 {
+			t.Fatalf("%v ran %v times; want about 10", name, len(ds))
+		}
+		for i, d := range ds {
+			if off := d.Sub(s.epoch) % period; off != phase {
+				t.Errorf("%v deadline %v has phase %v; want %v", name, i, off, phase)
+			}
+			if i > 0 && d.Sub(ds[i-1]) != period {
+				t.Errorf("%v deadlines %v and %v are %v apart; want %v", name, i-1, i, d.Sub(ds[i-1]), period)
+			}
+		}
+	}
+	if n := a.Stats().Runs; n != len(deadlines["a"]) {
+		t.Errorf("a.Stats().Runs = %v; want %v", n, len(deadlines["a"]))
+	}
+	if n := b.Stats().Runs; n != len(deadlines["b"]) {
+		t.Errorf("b.Stats().Runs = %v; want %v", n, len(deadlines["b"]))
+	}
+}
+
+func TestScheduler_missed(t *testing.T) {
+	s := NewScheduler()
+
+	const period = 5 * time.Millisecond
+	task := s.Add("slow", period, 0, func(time.Time) error {
+		time.Sleep(3 * period)
+		return nil
+	})
+
+	s.Run()
+	time.Sleep(10 * period)
+	s.Close()
+
+	stats := task.Stats()
+	if stats.Runs == 0 || stats.Missed < stats.Runs {
+		t.Errorf("got %v runs and %v missed deadlines; want at least 2 missed per run", stats.Runs, stats.Missed)
+	}
+}
+
+func TestScheduler_virtualClock(t *testing.T) {
+	c := clock.NewVirtual(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := NewSchedulerWithClock(c)
+
+	const period = 10 * time.Millisecond
+	ran := make(chan time.Time)
+	task := s.Add("a", period, period/2, func(deadline time.Time) error {
+		ran <- deadline
+		return nil
+	})
+	s.Run()
+	defer s.Close()
+
+	for i := 0; i < 5; i++ {
+		c.BlockUntil(1)
+		c.Advance(period)
+		want := s.epoch.Add(period/2 + time.Duration(i)*period)
+		if got := <-ran; !got.Equal(want) {
+			t.Fatalf("run %v: got deadline %v; want %v", i, got, want)
+		}
+	}
+	if stats := task.Stats(); stats.MaxLateness != period/2 {
+		t.Errorf("MaxLateness = %v; want %v", stats.MaxLateness, period/2)
+	}
+}
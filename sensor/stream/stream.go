@@ -0,0 +1,152 @@
+/*
+Package stream composes typed streams of sensor readings, like the
+temperatures of a thermometer or the orientations of a gyroscope, with the
+filters of the filter package and aggregations over windows of readings:
+
+	temps := stream.Poll(clock.Real, time.Second, bmp.Temperature, quit)
+	smooth := stream.Apply(stream.Float[units.Temperature](filter.NewMedian(5)), temps)
+	for t := range stream.Mean(smooth, 60) {
+		fmt.Println(t)
+	}
+
+The channels returned by the drivers are streams as they are:
+
+	yaws := stream.Map(stream.Stream[l3gd20.Orientation](orientations), func(o l3gd20.Orientation) float64 {
+		return o.Z
+	})
+
+Each stage closes its stream once its input is closed.
+*/
+package stream
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/filter"
+)
+
+// Stream is a stream of readings of type T.
+type Stream[T any] <-chan T
+
+// A Filter processes a stream of readings, returning the filtered value for
+// each new reading, like filter.Filter for float64 readings.
+type Filter[T any] interface {
+	Update(x T) T
+
+	// Reset forgets the previous readings.
+	Reset()
+}
+
+type float[T ~float64] struct {
+	filter.Filter
+}
+
+// Float adapts a filter of the filter package to the readings of a float
+// type, like units.Temperature or units.Pressure.
+func Float[T ~float64](f filter.Filter) Filter[T] {
+	return float[T]{f}
+}
+
+func (f float[T]) Update(x T) T {
+	return T(f.Filter.Update(float64(x)))
+}
+
+// Apply filters the readings of s.
+func Apply[T any](f Filter[T], s Stream[T]) Stream[T] {
+	return Map(s, f.Update)
+}
+
+// Map converts the readings of s with fn.
+func Map[T, U any](s Stream[T], fn func(T) U) Stream[U] {
+	out := make(chan U)
+	go func() {
+		defer close(out)
+		for x := range s {
+			out <- fn(x)
+		}
+	}()
+	return out
+}
+
+// Where passes the readings of s for which keep returns true.
+func Where[T any](s Stream[T], keep func(T) bool) Stream[T] {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for x := range s {
+			if keep(x) {
+				out <- x
+			}
+		}
+	}()
+	return out
+}
+
+// Window groups the readings of s by n, dropping the last incomplete
+// group.
+func Window[T any](s Stream[T], n int) Stream[[]T] {
+	out := make(chan []T)
+	go func() {
+		defer close(out)
+		w := make([]T, 0, n)
+		for x := range s {
+			w = append(w, x)
+			if len(w) == n {
+				out <- w
+				w = make([]T, 0, n)
+			}
+		}
+	}()
+	return out
+}
+
+// Reduce aggregates the readings of s by n with fn.
+func Reduce[T, U any](s Stream[T], n int, fn func(w []T) U) Stream[U] {
+	return Map(Window(s, n), fn)
+}
+
+// Mean averages the readings of s by n.
+func Mean[T ~float64](s Stream[T], n int) Stream[T] {
+	return Reduce(s, n, func(w []T) T {
+		var sum T
+		for _, x := range w {
+			sum += x
+		}
+		return sum / T(len(w))
+	})
+}
+
+// Poll reads a sensor at the period until stop is closed, like the
+// Temperature method of a sensor.Thermometer. The failed readings are
+// logged and skipped.
+func Poll[T any](c clock.Clock, period time.Duration, read func() (T, error), stop <-chan struct{}) Stream[T] {
+	c = clock.Or(c)
+	out := make(chan T)
+	go func() {
+		defer close(out)
+
+		ticker := c.NewTicker(period)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				x, err := read()
+				if err != nil {
+					glog.Errorf("stream: reading: %v", err)
+					continue
+				}
+				select {
+				case out <- x:
+				case <-stop:
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return out
+}
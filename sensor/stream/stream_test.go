@@ -0,0 +1,74 @@
+package stream
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/filter"
+	"github.com/kidoman/embd/units"
+)
+
+func from[T any](xs ...T) Stream[T] {
+	ch := make(chan T, len(xs))
+	for _, x := range xs {
+		ch <- x
+	}
+	close(ch)
+	return ch
+}
+
+func collect[T any](s Stream[T]) []T {
+	var xs []T
+	for x := range s {
+		xs = append(xs, x)
+	}
+	return xs
+}
+
+func TestCompose(t *testing.T) {
+	temps := from[units.Temperature](10, 20, -300, 30, 40, 50)
+	valid := Where(temps, func(t units.Temperature) bool { return t > -273 })
+	double := Apply(Float[units.Temperature](filter.Func(func(x float64) float64 { return 2 * x })), valid)
+	got := collect(Mean(double, 2))
+	want := []units.Temperature{30, 70}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	v := clock.NewVirtual(time.Time{})
+	stop := make(chan struct{})
+	n := 0
+	s := Poll(v, time.Second, func() (int, error) {
+		n++
+		if n == 2 {
+			return 0, errors.New("failed")
+		}
+		return n, nil
+	}, stop)
+
+	for _, want := range []int{1, 3} {
+		v.BlockUntil(1)
+		var got int
+	poll:
+		for {
+			v.Advance(time.Second)
+			select {
+			case got = <-s:
+				break poll
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		if got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	close(stop)
+	if _, ok := <-s; ok {
+		t.Error("stream not closed")
+	}
+}
@@ -0,0 +1,98 @@
+// Package tachometer allows measuring the speed of fans and shafts from the
+// pulses of a tachometer output or hall effect sensor.
+package tachometer
+
+import (
+	"sync"
+
+	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/sensor"
+)
+
+// PC fans output two pulses per revolution.
+const pcFanPulses = 2
+
+// Tachometer represents a tachometer.
+type Tachometer struct {
+	Pin embd.DigitalPin
+
+	// PulsesPerRevolution is the number of pulses per revolution, 2 for PC
+	// fans, or the number of magnets on the shaft.
+	PulsesPerRevolution int
+
+	initialized bool
+	mu          sync.RWMutex
+
+	counter *sensor.PulseCounter
+}
+
+// New creates a new tachometer on pin.
+func New(pin embd.DigitalPin, pulsesPerRevolution int) *Tachometer {
+	return &Tachometer{Pin: pin, PulsesPerRevolution: pulsesPerRevolution}
+}
+
+// NewFan creates a new tachometer on the tachometer output of a PC fan. The
+// output is open collector, so the pin needs a pull-up.
+func NewFan(pin embd.DigitalPin) *Tachometer {
+	return New(pin, pcFanPulses)
+}
+
+func (d *Tachometer) setup() error {
+	d.mu.RLock()
+	if d.initialized {
+		d.mu.RUnlock()
+		return nil
+	}
+	d.mu.RUnlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	counter, err := sensor.NewPulseCounter(d.Pin, embd.EdgeFalling)
+	if err != nil {
+		return err
+	}
+	d.counter = counter
+
+	d.initialized = true
+
+	return nil
+}
+
+// RPM returns the speed in revolutions per minute, averaged since the
+// previous call.
+func (d *Tachometer) RPM() (float64, error) {
+	if err := d.setup(); err != nil {
+		return 0, err
+	}
+
+	m := d.counter.Measure()
+	return m.Frequency * 60 / float64(d.PulsesPerRevolution), nil
+}
+
+// Revolutions returns the number of revolutions counted.
+func (d *Tachometer) Revolutions() (float64, error) {
+	if err := d.setup(); err != nil {
+		return 0, err
+	}
+
+	return float64(d.counter.Count()) / float64(d.PulsesPerRevolution), nil
+}
+
+// Rate implements sensor.Counter, returning the RPM.
+func (d *Tachometer) Rate() (float64, error) {
+	return d.RPM()
+}
+
+// Total implements sensor.Counter, returning the revolutions.
+func (d *Tachometer) Total() (float64, error) {
+	return d.Revolutions()
+}
+
+// Close stops counting.
+func (d *Tachometer) Close() error {
+	if !d.initialized {
+		return nil
+	}
+	return d.counter.Close()
+}
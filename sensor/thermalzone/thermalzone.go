@@ -0,0 +1,82 @@
+// Package thermalzone allows reading the temperature of the thermal zones
+// the kernel exposes for the SoC and other chips.
+package thermalzone
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kidoman/embd/units"
+)
+
+// classPath is where the kernel exposes the thermal zones.
+var classPath = "/sys/class/thermal"
+
+// Zone represents a thermal zone.
+type Zone struct {
+	// Path is the sysfs directory of the zone.
+	Path string
+}
+
+// New returns the thermal zone n. Zone 0 is the SoC on most boards,
+// including the Raspberry Pi.
+func New(n int) *Zone {
+	return &Zone{Path: path.Join(classPath, fmt.Sprintf("thermal_zone%v", n))}
+}
+
+// Zones returns all the thermal zones.
+func Zones() ([]*Zone, error) {
+	paths, err := filepath.Glob(path.Join(classPath, "thermal_zone*"))
+	if err != nil {
+		return nil, err
+	}
+	zones := make([]*Zone, len(paths))
+	for i, p := range paths {
+		zones[i] = &Zone{Path: p}
+	}
+	return zones, nil
+}
+
+// Find returns the thermal zone of the given type, like "cpu-thermal".
+func Find(typ string) (*Zone, error) {
+	zones, err := Zones()
+	if err != nil {
+		return nil, err
+	}
+	for _, z := range zones {
+		if t, err := z.Type(); err == nil && t == typ {
+			return z, nil
+		}
+	}
+	return nil, fmt.Errorf("thermalzone: no %q zone", typ)
+}
+
+func (z *Zone) read(name string) (string, error) {
+	data, err := ioutil.ReadFile(path.Join(z.Path, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Type returns the type of the zone, which identifies the chip it measures.
+func (z *Zone) Type() (string, error) {
+	return z.read("type")
+}
+
+// Temperature returns the current temperature of the zone.
+func (z *Zone) Temperature() (units.Temperature, error) {
+	s, err := z.read("temp")
+	if err != nil {
+		return 0, err
+	}
+	millis, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("thermalzone: invalid temperature %q", s)
+	}
+	return units.Temperature(millis) / 1000, nil
+}
@@ -0,0 +1,52 @@
+package thermalzone
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/kidoman/embd/sensor"
+)
+
+var _ sensor.Thermometer = &Zone{}
+
+func TestZones(t *testing.T) {
+	dir, err := ioutil.TempDir("", "thermal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"thermal_zone0/type": "cpu-thermal\n",
+		"thermal_zone0/temp": "48686\n",
+		"thermal_zone1/type": "gpu-thermal\n",
+		"thermal_zone1/temp": "-1500\n",
+	} {
+		p := path.Join(dir, name)
+		os.MkdirAll(path.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(p string) { classPath = p }(classPath)
+	classPath = dir
+
+	z, err := Find("gpu-thermal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	temp, err := z.Temperature()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if temp != -1.5 {
+		t.Errorf("gpu temperature = %v; want -1.5", temp)
+	}
+	if temp, _ := New(0).Temperature(); temp != 48.686 {
+		t.Errorf("cpu temperature = %v; want 48.686", temp)
+	}
+	if _, err := Find("battery"); err == nil {
+		t.Error("no error for a missing zone")
+	}
+}
@@ -10,6 +10,8 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/sensor"
+	"github.com/kidoman/embd/units"
 )
 
 const (
@@ -63,8 +65,8 @@ type TMP006 struct {
 	initialized bool
 	mu          sync.RWMutex
 
-	rawDieTemps chan float64
-	objTemps    chan float64
+	rawDieTemps chan units.Temperature
+	objTemps    chan units.Temperature
 	closing     chan chan struct{}
 }
 
@@ -105,27 +107,45 @@ func (d *TMP006) Close() error {
 
 // Present checks if the device is present at the given address.
 func (d *TMP006) Present() (bool, error) {
-	if err := d.validate(); err != nil {
+	if err := d.Identify(); err != nil {
 		return false, err
 	}
-	mid, err := d.Bus.ReadWordFromReg(d.Addr, manIdReg)
-	if err != nil {
-		return false, err
+	return true, nil
+}
+
+// Identify verifies the manufacturer and device ids of the TMP006 at the
+// given address.
+func (d *TMP006) Identify() error {
+	if err := d.validate(); err != nil {
+		return err
 	}
-	glog.V(1).Infof("tmp006: got manufacturer id %#04x", mid)
-	if mid != manId {
-		return false, fmt.Errorf("tmp006: not found at %#02x, manufacturer id mismatch", d.Addr)
+	if err := sensor.VerifyID(d.Bus, "tmp006", d.Addr, manIdReg, manId>>8, manId&0xFF); err != nil {
+		return err
 	}
-	did, err := d.Bus.ReadWordFromReg(d.Addr, devIdReg)
+	return sensor.VerifyID(d.Bus, "tmp006", d.Addr, devIdReg, devId>>8, devId&0xFF)
+}
+
+// SelfTest verifies the chip ids and checks that the die temperature is
+// within the operating range of the sensor.
+func (d *TMP006) SelfTest() (*sensor.Diagnostics, error) {
+	diag := &sensor.Diagnostics{Chip: "tmp006"}
+
+	err := d.Identify()
+	if _, ok := err.(*sensor.ChipIDError); err != nil && !ok {
+		return nil, err
+	}
+	diag.AddErr("chip id", err)
 	if err != nil {
-		return false, err
+		return diag, nil
 	}
-	glog.V(1).Infof("tmp006: got device id %#04x", did)
-	if did != devId {
-		return false, fmt.Errorf("tmp006: not found at %#02x, device id mismatch", d.Addr)
+
+	temp, err := d.measureRawDieTemp()
+	if err != nil {
+		return nil, err
 	}
+	diag.Add("die temperature", temp >= -40 && temp <= 125, "%v", temp)
 
-	return true, nil
+	return diag, nil
 }
 
 func (d *TMP006) setup() error {
@@ -139,7 +159,7 @@ func (d *TMP006) setup() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	if err := d.validate(); err != nil {
+	if err := d.Identify(); err != nil {
 		return err
 	}
 	if d.SampleRate == nil {
@@ -156,7 +176,7 @@ func (d *TMP006) setup() error {
 	return nil
 }
 
-func (d *TMP006) measureRawDieTemp() (float64, error) {
+func (d *TMP006) measureRawDieTemp() (units.Temperature, error) {
 	if err := d.setup(); err != nil {
 		return 0, err
 	}
@@ -167,7 +187,7 @@ func (d *TMP006) measureRawDieTemp() (float64, error) {
 	raw >>= 2
 	glog.V(2).Infof("tmp006: raw die temp %#04x", raw)
 
-	temp := float64(int16(raw)) * 0.03125
+	temp := units.Temperature(int16(raw)) * 0.03125
 
 	return temp, nil
 }
@@ -185,16 +205,16 @@ func (d *TMP006) measureRawVoltage() (int16, error) {
 	return volt, nil
 }
 
-func (d *TMP006) measureObjTemp() (float64, error) {
+func (d *TMP006) measureObjTemp() (units.Temperature, error) {
 	if err := d.setup(); err != nil {
 		return 0, err
 	}
-	tDie, err := d.measureRawDieTemp()
+	die, err := d.measureRawDieTemp()
 	if err != nil {
 		return 0, err
 	}
-	glog.V(2).Infof("tmp006: tdie = %.2f C", tDie)
-	tDie += 273.15 // Convert to K
+	glog.V(2).Infof("tmp006: tdie = %v", die)
+	tDie := die.Kelvin()
 	vo, err := d.measureRawVoltage()
 	if err != nil {
 		return 0, err
@@ -216,13 +236,12 @@ func (d *TMP006) measureObjTemp() (float64, error) {
 	fVobj := (vObj - Vos) + c2*(vObj-Vos)*(vObj-Vos)
 
 	temp := math.Sqrt(math.Sqrt(tDie*tDie*tDie*tDie + fVobj/s))
-	temp -= 273.15
 
-	return temp, nil
+	return units.Kelvin(temp), nil
 }
 
 // RawDieTemp returns the current raw die temp reading.
-func (d *TMP006) RawDieTemp() (float64, error) {
+func (d *TMP006) RawDieTemp() (units.Temperature, error) {
 	select {
 	case temp := <-d.rawDieTemps:
 		return temp, nil
@@ -232,12 +251,12 @@ func (d *TMP006) RawDieTemp() (float64, error) {
 }
 
 // RawDieTemps returns a channel to get future raw die temps from.
-func (d *TMP006) RawDieTemps() <-chan float64 {
+func (d *TMP006) RawDieTemps() <-chan units.Temperature {
 	return d.rawDieTemps
 }
 
 // ObjTemp returns the current obj temp reading.
-func (d *TMP006) ObjTemp() (float64, error) {
+func (d *TMP006) ObjTemp() (units.Temperature, error) {
 	select {
 	case temp := <-d.objTemps:
 		return temp, nil
@@ -247,7 +266,7 @@ func (d *TMP006) ObjTemp() (float64, error) {
 }
 
 // ObjTemps returns a channel to fetch obj temps from.
-func (d *TMP006) ObjTemps() <-chan float64 {
+func (d *TMP006) ObjTemps() <-chan units.Temperature {
 	return d.objTemps
 }
 
@@ -257,11 +276,11 @@ func (d *TMP006) Start() error {
 		return err
 	}
 
-	d.rawDieTemps = make(chan float64)
-	d.objTemps = make(chan float64)
+	d.rawDieTemps = make(chan units.Temperature)
+	d.objTemps = make(chan units.Temperature)
 
 	go func() {
-		var rawDieTemp, objTemp float64
+		var rawDieTemp, objTemp units.Temperature
 		var rdtAvlb, otAvlb bool
 		var err error
 		var timer <-chan time.Time
@@ -271,7 +290,7 @@ func (d *TMP006) Start() error {
 		resetTimer()
 
 		for {
-			var rawDieTemps, objTemps chan float64
+			var rawDieTemps, objTemps chan units.Temperature
 
 			if rdtAvlb {
 				rawDieTemps = d.rawDieTemps
@@ -282,14 +301,14 @@ func (d *TMP006) Start() error {
 
 			select {
 			case <-timer:
-				var rdt float64
+				var rdt units.Temperature
 				if rdt, err = d.measureRawDieTemp(); err != nil {
 					glog.Errorf("tmp006: %v", err)
 				} else {
 					rawDieTemp = rdt
 					rdtAvlb = true
 				}
-				var ot float64
+				var ot units.Temperature
 				if ot, err = d.measureObjTemp(); err != nil {
 					glog.Errorf("tmp006: %v", err)
 				} else {
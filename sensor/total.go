@@ -0,0 +1,242 @@
+// Persistent totals.
+
+package sensor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/health"
+)
+
+// DefaultFlushEvery is the default interval between saves of a running
+// Totalizer.
+const DefaultFlushEvery = time.Minute
+
+// LoadTotal reads a total saved by SaveTotal, like the volume through a
+// flow meter. A missing file holds a total of 0.
+func LoadTotal(path string) (float64, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(data)), 64)
+}
+
+// SaveTotal writes total to path. The file is replaced atomically, and
+// synced to the storage before and after, so a power cut never leaves a
+// truncated total behind.
+func SaveTotal(path string, total float64) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(strconv.FormatFloat(total, 'f', -1, 64) + "\n")
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	// The rename itself is only durable once the directory is synced.
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// Totalizer keeps a monotonically growing total, like the energy through an
+// energy meter, the volume through a flow meter or the running hours of a
+// pump. While running, it is saved every FlushEvery, so that a crash loses
+// at most that much of it:
+//
+//	hours, err := sensor.OpenTotalizer("/var/lib/pump/hours")
+//	if err != nil {
+//		panic(err)
+//	}
+//	hours.Run()
+//	defer hours.Close()
+//
+//	hours.Add(elapsed.Hours())
+//	region.Write(numfmt.Fixed(hours.Total(), 1, 7))
+//
+// Read adapts it to the streams and the scheduler, and it formats itself
+// for logging.
+type Totalizer struct {
+	// Path is the file the total is saved to, empty to keep it in memory
+	// only.
+	Path string
+
+	// FlushEvery is the interval between saves while running.
+	FlushEvery time.Duration
+
+	// Sync, if set, is called before each save, for the drivers to add what
+	// they counted since the previous one.
+	Sync func()
+
+	// HealthName is the name the saves while running are reported under to
+	// the health registry, "total:" followed by Path when empty.
+	HealthName string
+
+	clock clock.Clock
+
+	mu    sync.Mutex
+	total float64
+	saved float64
+
+	quit, done chan struct{}
+}
+
+// OpenTotalizer creates a new Totalizer saved to path, starting from the
+// total saved there.
+func OpenTotalizer(path string) (*Totalizer, error) {
+	return OpenTotalizerWithClock(path, clock.Real)
+}
+
+// OpenTotalizerWithClock creates a new Totalizer saved to path, timing its
+// saves with c, like a clock.Virtual in tests.
+func OpenTotalizerWithClock(path string, c clock.Clock) (*Totalizer, error) {
+	t := &Totalizer{Path: path, FlushEvery: DefaultFlushEvery, clock: clock.Or(c)}
+	if path == "" {
+		return t, nil
+	}
+	total, err := LoadTotal(path)
+	if err != nil {
+		return nil, fmt.Errorf("sensor: loading total %v: %v", path, err)
+	}
+	t.total, t.saved = total, total
+	return t, nil
+}
+
+// Add adds delta to the total. The total only grows, so negative deltas are
+// dropped.
+func (t *Totalizer) Add(delta float64) {
+	if delta < 0 {
+		glog.Warningf("sensor: dropping negative delta %v of total %v", delta, t.Path)
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total += delta
+}
+
+// Total returns the total.
+func (t *Totalizer) Total() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.total
+}
+
+// Read returns the total. It never fails.
+func (t *Totalizer) Read() (float64, error) {
+	return t.Total(), nil
+}
+
+func (t *Totalizer) String() string {
+	return strconv.FormatFloat(t.Total(), 'f', -1, 64)
+}
+
+// Reset sets the total back to zero, and saves it at once.
+func (t *Totalizer) Reset() error {
+	t.mu.Lock()
+	t.total = 0
+	t.mu.Unlock()
+
+	return t.Flush()
+}
+
+// Flush saves the total if it changed since it was last saved.
+func (t *Totalizer) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.Path == "" || t.total == t.saved {
+		return nil
+	}
+	if err := SaveTotal(t.Path, t.total); err != nil {
+		return err
+	}
+	t.saved = t.total
+	return nil
+}
+
+func (t *Totalizer) flush() error {
+	if t.Sync != nil {
+		t.Sync()
+	}
+	return t.Flush()
+}
+
+// Run starts saving the total every FlushEvery in the background.
+func (t *Totalizer) Run() {
+	every := t.FlushEvery
+	if every <= 0 {
+		every = DefaultFlushEvery
+	}
+	t.quit = make(chan struct{})
+	t.done = make(chan struct{})
+
+	go func() {
+		defer close(t.done)
+
+		ticker := clock.Or(t.clock).NewTicker(every)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				err := t.flush()
+				if err != nil {
+					glog.Errorf("sensor: saving total %v: %v", t.Path, err)
+				}
+				if t.Path != "" {
+					health.Observe(t.healthName(), err)
+				}
+			case <-t.quit:
+				return
+			}
+		}
+	}()
+}
+
+// healthName returns the name of the total in the health registry.
+func (t *Totalizer) healthName() string {
+	if t.HealthName == "" {
+		return "total:" + t.Path
+	}
+	return t.HealthName
+}
+
+// Close stops saving the total in the background, and saves it a last time.
+func (t *Totalizer) Close() error {
+	if t.quit != nil {
+		close(t.quit)
+		<-t.done
+		t.quit = nil
+		health.Default.Remove(t.healthName())
+	}
+	return t.flush()
+}
@@ -0,0 +1,110 @@
+package sensor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/health"
+)
+
+func TestTotalizer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "total")
+
+	tot, err := OpenTotalizer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tot.Add(1.5)
+	tot.Add(-1)
+	if got := tot.Total(); got != 1.5 {
+		t.Errorf("Total() = %v; want 1.5, negative deltas dropped", got)
+	}
+	if err := tot.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	tot, err = OpenTotalizer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tot.String(); got != "1.5" {
+		t.Errorf("reopened total = %v; want 1.5", got)
+	}
+	if err := tot.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := LoadTotal(path); got != 0 {
+		t.Errorf("saved total after Reset = %v; want 0", got)
+	}
+}
+
+func TestTotalizer_flush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "total")
+
+	c := clock.NewVirtual(time.Time{})
+	tot, err := OpenTotalizerWithClock(path, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	synced := make(chan struct{}, 1)
+	tot.Sync = func() {
+		tot.Add(2)
+		select {
+		case synced <- struct{}{}:
+		default:
+		}
+	}
+	tot.Run()
+	defer tot.Close()
+
+	c.BlockUntil(1)
+	c.Advance(DefaultFlushEvery)
+	select {
+	case <-synced:
+	case <-time.After(time.Second):
+		t.Fatal("total not synced before the periodic save")
+	}
+	// The save follows the sync on the same goroutine, and is reported to
+	// the health registry.
+	deadline := time.Now().Add(time.Second)
+	for {
+		if got, _ := LoadTotal(path); got == 2 && reported(tot.healthName()) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("total not saved periodically")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	tot.Close()
+	if reported(tot.healthName()) {
+		t.Error("total still in the health registry after Close")
+	}
+}
+
+// reported returns whether a driver is in the health registry, and OK.
+func reported(name string) bool {
+	for _, rep := range health.Default.Reports() {
+		if rep.Name == name {
+			return rep.Status == health.OK
+		}
+	}
+	return false
+}
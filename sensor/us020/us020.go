@@ -7,6 +7,7 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/kidoman/embd"
+	"github.com/kidoman/embd/units"
 )
 
 const (
@@ -14,14 +15,16 @@ const (
 	defaultTemp = 25
 )
 
+// Thermometer is the interface of the thermometer used to compensate for the
+// speed of sound.
 type Thermometer interface {
-	Temperature() (float64, error)
+	Temperature() (units.Temperature, error)
 }
 
 type nullThermometer struct {
 }
 
-func (*nullThermometer) Temperature() (float64, error) {
+func (*nullThermometer) Temperature() (units.Temperature, error) {
 	return defaultTemp, nil
 }
 
@@ -56,6 +59,11 @@ func (d *US020) setup() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
+	// The echo of the US020 is a 5V signal.
+	if err := embd.CheckLevel(d.EchoPin, embd.Level5V, "us020 echo"); err != nil {
+		return err
+	}
+
 	d.TriggerPin.SetDirection(embd.Out)
 	d.EchoPin.SetDirection(embd.In)
 
@@ -64,7 +72,7 @@ func (d *US020) setup() error {
 	}
 
 	if temp, err := d.Thermometer.Temperature(); err == nil {
-		d.speedSound = 331.3 + 0.606*temp
+		d.speedSound = 331.3 + 0.606*temp.Celsius()
 
 		glog.V(1).Infof("us020: read a temperature of %v, so speed of sound = %v", temp, d.speedSound)
 	} else {
@@ -77,7 +85,7 @@ func (d *US020) setup() error {
 }
 
 // Distance computes the distance of the bot from the closest obstruction.
-func (d *US020) Distance() (float64, error) {
+func (d *US020) Distance() (units.Distance, error) {
 	if err := d.setup(); err != nil {
 		return 0, err
 	}
@@ -97,7 +105,7 @@ func (d *US020) Distance() (float64, error) {
 	}
 
 	// Calculate the distance based on the time computed
-	distance := float64(duration.Nanoseconds()) / 10000000 * (d.speedSound / 2)
+	distance := units.Distance(duration.Seconds() * d.speedSound / 2)
 
 	return distance, nil
 }
@@ -0,0 +1,376 @@
+/*
+Package sim simulates faulty buses and lines, to test how drivers recover
+from errors.
+
+The decorators wrap real or fake buses and pins, and inject the faults of an
+Injector: NAKs, delayed writes, stuck lines and flipped bits. The injector
+draws the faults from a seeded source, so a failing test fails the same way
+every time:
+
+	inj := sim.NewInjector(sim.Faults{NAK: 0.1, BitFlip: 0.01, Seed: 1})
+	bus := sim.I2CBus(fakeBus, inj)
+	hd, _ := hd44780.NewI2C(bus, 0x20, hd44780.PCF8574PinMap, hd44780.RowAddress20Col)
+	...
+	inj.FailNext(3)
+	...
+	t.Logf("%+v", inj.Stats())
+*/
+package sim
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd"
+)
+
+var (
+	// ErrNAK is returned by the transfers failed by the injector.
+	ErrNAK = embd.NewError(embd.ErrNAK, "sim: injected NAK")
+	// ErrStuck is returned by the transfers on a stuck bus.
+	ErrStuck = embd.NewError(embd.ErrBusBusy, "sim: bus stuck")
+)
+
+// Faults are the faults injected, as the probabilities of each operation
+// to be faulty.
+type Faults struct {
+	// NAK fails bus transfers with ErrNAK.
+	NAK float64
+	// BitFlip flips a random bit of each byte transferred, and inverts
+	// the values read from pins.
+	BitFlip float64
+	// Delay delays writes by DelayBy.
+	Delay   float64
+	DelayBy time.Duration
+	// Seed seeds the source the faults are drawn from.
+	Seed int64
+}
+
+// Stats count the faults injected.
+type Stats struct {
+	Ops, NAKs, BitFlips, Delays, Stuck int
+}
+
+// Injector injects faults in the operations of the buses and pins it
+// decorates.
+type Injector struct {
+	Faults Faults
+
+	mu    sync.Mutex
+	rnd   *rand.Rand
+	fail  int
+	stuck bool
+	stats Stats
+}
+
+// NewInjector creates a new injector of faults.
+func NewInjector(f Faults) *Injector {
+	return &Injector{Faults: f, rnd: rand.New(rand.NewSource(f.Seed))}
+}
+
+// FailNext fails the next n bus transfers with ErrNAK.
+func (inj *Injector) FailNext(n int) {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+
+	inj.fail = n
+}
+
+// Stick makes the buses stuck, failing all their transfers with ErrStuck,
+// until Unstick is called.
+func (inj *Injector) Stick() {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+
+	inj.stuck = true
+}
+
+// Unstick releases the buses.
+func (inj *Injector) Unstick() {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+
+	inj.stuck = false
+}
+
+// Stats returns the faults injected so far.
+func (inj *Injector) Stats() Stats {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+
+	return inj.stats
+}
+
+func (inj *Injector) chance(p float64) bool {
+	return p > 0 && inj.rnd.Float64() < p
+}
+
+// transfer returns the error injected in a bus transfer, and delays it if
+// it is a write.
+func (inj *Injector) transfer(write bool) error {
+	inj.mu.Lock()
+	inj.stats.Ops++
+	var err error
+	switch {
+	case inj.stuck:
+		inj.stats.Stuck++
+		err = ErrStuck
+	case inj.fail > 0:
+		inj.fail--
+		inj.stats.NAKs++
+		err = ErrNAK
+	case inj.chance(inj.Faults.NAK):
+		inj.stats.NAKs++
+		err = ErrNAK
+	}
+	delay := err == nil && write && inj.chance(inj.Faults.Delay)
+	if delay {
+		inj.stats.Delays++
+	}
+	inj.mu.Unlock()
+
+	if err != nil {
+		glog.V(2).Infof("sim: injecting %v", err)
+	}
+	if delay {
+		time.Sleep(inj.Faults.DelayBy)
+	}
+	return err
+}
+
+// flip flips a random bit of the bytes, each with the BitFlip probability.
+func (inj *Injector) flip(b []byte) {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+
+	for i := range b {
+		if inj.chance(inj.Faults.BitFlip) {
+			inj.stats.BitFlips++
+			b[i] ^= 1 << uint(inj.rnd.Intn(8))
+		}
+	}
+}
+
+func (inj *Injector) flipByte(b byte) byte {
+	v := []byte{b}
+	inj.flip(v)
+	return v[0]
+}
+
+type i2cBus struct {
+	embd.I2CBus
+
+	inj *Injector
+}
+
+// I2CBus returns a bus injecting faults in the transfers on bus.
+func I2CBus(bus embd.I2CBus, inj *Injector) embd.I2CBus {
+	return &i2cBus{I2CBus: bus, inj: inj}
+}
+
+func (b *i2cBus) ReadByte(addr byte) (byte, error) {
+	if err := b.inj.transfer(false); err != nil {
+		return 0, err
+	}
+	v, err := b.I2CBus.ReadByte(addr)
+	return b.inj.flipByte(v), err
+}
+
+func (b *i2cBus) WriteByte(addr, value byte) error {
+	if err := b.inj.transfer(true); err != nil {
+		return err
+	}
+	return b.I2CBus.WriteByte(addr, b.inj.flipByte(value))
+}
+
+func (b *i2cBus) WriteBytes(addr byte, value []byte) error {
+	if err := b.inj.transfer(true); err != nil {
+		return err
+	}
+	v := append([]byte(nil), value...)
+	b.inj.flip(v)
+	return b.I2CBus.WriteBytes(addr, v)
+}
+
+func (b *i2cBus) ReadFromReg(addr, reg byte, value []byte) error {
+	if err := b.inj.transfer(false); err != nil {
+		return err
+	}
+	err := b.I2CBus.ReadFromReg(addr, reg, value)
+	b.inj.flip(value)
+	return err
+}
+
+func (b *i2cBus) ReadByteFromReg(addr, reg byte) (byte, error) {
+	if err := b.inj.transfer(false); err != nil {
+		return 0, err
+	}
+	v, err := b.I2CBus.ReadByteFromReg(addr, reg)
+	return b.inj.flipByte(v), err
+}
+
+func (b *i2cBus) ReadWordFromReg(addr, reg byte) (uint16, error) {
+	if err := b.inj.transfer(false); err != nil {
+		return 0, err
+	}
+	v, err := b.I2CBus.ReadWordFromReg(addr, reg)
+	return uint16(b.inj.flipByte(byte(v>>8)))<<8 | uint16(b.inj.flipByte(byte(v))), err
+}
+
+func (b *i2cBus) WriteToReg(addr, reg byte, value []byte) error {
+	if err := b.inj.transfer(true); err != nil {
+		return err
+	}
+	v := append([]byte(nil), value...)
+	b.inj.flip(v)
+	return b.I2CBus.WriteToReg(addr, reg, v)
+}
+
+func (b *i2cBus) WriteByteToReg(addr, reg, value byte) error {
+	if err := b.inj.transfer(true); err != nil {
+		return err
+	}
+	return b.I2CBus.WriteByteToReg(addr, reg, b.inj.flipByte(value))
+}
+
+func (b *i2cBus) WriteWordToReg(addr, reg byte, value uint16) error {
+	if err := b.inj.transfer(true); err != nil {
+		return err
+	}
+	value = uint16(b.inj.flipByte(byte(value>>8)))<<8 | uint16(b.inj.flipByte(byte(value)))
+	return b.I2CBus.WriteWordToReg(addr, reg, value)
+}
+
+type spiBus struct {
+	embd.SPIBus
+
+	inj *Injector
+}
+
+// SPIBus returns a bus injecting faults in the transfers on bus. SPI has no
+// acknowledgement, so the NAKs stand for the failures of the transfers.
+func SPIBus(bus embd.SPIBus, inj *Injector) embd.SPIBus {
+	return &spiBus{SPIBus: bus, inj: inj}
+}
+
+func (b *spiBus) TransferAndRecieveData(data []uint8) error {
+	if err := b.inj.transfer(true); err != nil {
+		return err
+	}
+	b.inj.flip(data)
+	err := b.SPIBus.TransferAndRecieveData(data)
+	b.inj.flip(data)
+	return err
+}
+
+func (b *spiBus) ReceiveData(n int) ([]uint8, error) {
+	if err := b.inj.transfer(false); err != nil {
+		return nil, err
+	}
+	data, err := b.SPIBus.ReceiveData(n)
+	b.inj.flip(data)
+	return data, err
+}
+
+func (b *spiBus) TransferAndReceiveByte(data byte) (byte, error) {
+	if err := b.inj.transfer(true); err != nil {
+		return 0, err
+	}
+	v, err := b.SPIBus.TransferAndReceiveByte(b.inj.flipByte(data))
+	return b.inj.flipByte(v), err
+}
+
+func (b *spiBus) ReceiveByte() (byte, error) {
+	if err := b.inj.transfer(false); err != nil {
+		return 0, err
+	}
+	v, err := b.SPIBus.ReceiveByte()
+	return b.inj.flipByte(v), err
+}
+
+// Pin is a digital pin injecting faults: its reads are inverted with the
+// BitFlip probability, its writes are delayed, and it can be stuck at a
+// level.
+type Pin struct {
+	embd.DigitalPin
+
+	inj *Injector
+
+	mu    sync.Mutex
+	stuck bool
+	level int
+}
+
+// DigitalPin returns a pin injecting faults in the operations on pin.
+func DigitalPin(pin embd.DigitalPin, inj *Injector) *Pin {
+	return &Pin{DigitalPin: pin, inj: inj}
+}
+
+// StickAt sticks the line at a level: reads return it, and writes are lost.
+func (p *Pin) StickAt(level int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stuck, p.level = true, level
+}
+
+// Unstick releases the line.
+func (p *Pin) Unstick() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stuck = false
+}
+
+func (p *Pin) stuckAt() (bool, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.stuck, p.level
+}
+
+// Read reads the pin, or the level it is stuck at.
+func (p *Pin) Read() (int, error) {
+	if stuck, level := p.stuckAt(); stuck {
+		p.inj.mu.Lock()
+		p.inj.stats.Ops++
+		p.inj.stats.Stuck++
+		p.inj.mu.Unlock()
+		return level, nil
+	}
+	v, err := p.DigitalPin.Read()
+	if err != nil {
+		return v, err
+	}
+	p.inj.mu.Lock()
+	defer p.inj.mu.Unlock()
+
+	p.inj.stats.Ops++
+	if p.inj.chance(p.inj.Faults.BitFlip) {
+		p.inj.stats.BitFlips++
+		v ^= 1
+	}
+	return v, nil
+}
+
+// Write writes the pin, after a delay with the Delay probability. The
+// writes to a stuck pin are lost.
+func (p *Pin) Write(val int) error {
+	p.inj.mu.Lock()
+	p.inj.stats.Ops++
+	delay := p.inj.chance(p.inj.Faults.Delay)
+	if delay {
+		p.inj.stats.Delays++
+	}
+	p.inj.mu.Unlock()
+
+	if delay {
+		time.Sleep(p.inj.Faults.DelayBy)
+	}
+	if stuck, _ := p.stuckAt(); stuck {
+		return nil
+	}
+	return p.DigitalPin.Write(val)
+}
@@ -0,0 +1,114 @@
+package sim
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kidoman/embd"
+)
+
+// memBus is an I2C bus of registers in memory.
+type memBus struct {
+	embd.I2CBus
+
+	regs [256]byte
+}
+
+func (b *memBus) ReadByteFromReg(addr, reg byte) (byte, error) {
+	return b.regs[reg], nil
+}
+
+func (b *memBus) WriteByteToReg(addr, reg, value byte) error {
+	b.regs[reg] = value
+	return nil
+}
+
+// run writes and reads back the registers, and returns the errors and
+// the mismatches.
+func run(bus embd.I2CBus) []int {
+	var faults []int
+	for i := 0; i < 100; i++ {
+		if err := bus.WriteByteToReg(0x20, byte(i), byte(i)); err != nil {
+			faults = append(faults, -i)
+			continue
+		}
+		v, err := bus.ReadByteFromReg(0x20, byte(i))
+		if err != nil || v != byte(i) {
+			faults = append(faults, i)
+		}
+	}
+	return faults
+}
+
+func TestDeterministic(t *testing.T) {
+	f := Faults{NAK: 0.1, BitFlip: 0.05, Seed: 42}
+	first := run(I2CBus(&memBus{}, NewInjector(f)))
+	second := run(I2CBus(&memBus{}, NewInjector(f)))
+	if len(first) == 0 {
+		t.Fatal("no faults injected")
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("faults differ for the same seed: %v and %v", first, second)
+	}
+}
+
+func TestFailNextAndStick(t *testing.T) {
+	inj := NewInjector(Faults{})
+	bus := I2CBus(&memBus{}, inj)
+
+	inj.FailNext(2)
+	for i := 0; i < 2; i++ {
+		if err := bus.WriteByteToReg(0x20, 0, 1); err != ErrNAK {
+			t.Errorf("write %v: expected ErrNAK, got %v", i, err)
+		}
+	}
+	if err := bus.WriteByteToReg(0x20, 0, 1); err != nil {
+		t.Errorf("write after the failures: %v", err)
+	}
+
+	inj.Stick()
+	if _, err := bus.ReadByteFromReg(0x20, 0); err != ErrStuck {
+		t.Errorf("expected ErrStuck, got %v", err)
+	}
+	inj.Unstick()
+	if v, err := bus.ReadByteFromReg(0x20, 0); err != nil || v != 1 {
+		t.Errorf("read after unsticking: got %v, %v", v, err)
+	}
+
+	if s := inj.Stats(); s != (Stats{Ops: 5, NAKs: 2, Stuck: 1}) {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+type memPin struct {
+	embd.DigitalPin
+
+	val int
+}
+
+func (p *memPin) Read() (int, error)  { return p.val, nil }
+func (p *memPin) Write(val int) error { p.val = val; return nil }
+
+func TestPin(t *testing.T) {
+	mem := &memPin{}
+	pin := DigitalPin(mem, NewInjector(Faults{}))
+
+	pin.Write(embd.High)
+	pin.StickAt(embd.Low)
+	if v, _ := pin.Read(); v != embd.Low {
+		t.Errorf("stuck pin read %v", v)
+	}
+	pin.Write(embd.Low)
+	if mem.val != embd.High {
+		t.Error("write to a stuck pin not lost")
+	}
+	pin.Unstick()
+	if v, _ := pin.Read(); v != embd.High {
+		t.Errorf("released pin read %v", v)
+	}
+
+	flipping := DigitalPin(mem, NewInjector(Faults{BitFlip: 1}))
+	if v, _ := flipping.Read(); v != embd.Low {
+		t.Errorf("expected the read inverted, got %v", v)
+	}
+}
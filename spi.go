@@ -82,5 +82,9 @@ func NewSPIBus(mode, channel byte, speed, bpw, delay int) SPIBus {
 		panic(err)
 	}
 
-	return spiDriverInstance.Bus(mode, channel, speed, bpw, delay)
+	bus := spiDriverInstance.Bus(mode, channel, speed, bpw, delay)
+	if Tracing() {
+		bus = TraceSPIBus(bus, channel)
+	}
+	return bus
 }
@@ -0,0 +1,360 @@
+/*
+Package timesync keeps the time of a device right without a reliable network,
+for loggers whose records are graphed later.
+
+At boot, the keeper picks the best available time source: the reference
+sources, like NTP and GPS, in order of preference, and otherwise the real
+time clock. It steps the system clock to it, and keeps the real time clock
+disciplined from the references while they are available:
+
+	k := timesync.New(ds3231.New(bus))
+	k.AddReference("ntp", timesync.NTP)
+	k.AddReference("gps", timesync.SourceFunc(gps.Time))
+	if err := k.Run(); err != nil {
+		glog.Warning(err)
+	}
+	defer k.Close()
+
+	for ev := range k.Events() {
+		log.Print(ev)
+	}
+
+Every step of the system clock is reported as an event, so that the records
+taken before it can be told apart.
+*/
+package timesync
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/health"
+)
+
+const (
+	// DefaultMaxOffset is the largest offset from a source tolerated before
+	// the system clock or the real time clock is set.
+	DefaultMaxOffset = 2 * time.Second
+
+	// DefaultPoll is the interval between synchronizations while running.
+	DefaultPoll = 10 * time.Minute
+
+	// RTCName is the name of the real time clock in the events.
+	RTCName = "rtc"
+
+	// timeError is the adjtimex state of an unsynchronized clock.
+	timeError = 5
+
+	eventBuffer = 8
+)
+
+var (
+	// ErrUnsynchronized is returned by NTP while the kernel clock is not
+	// synchronized.
+	ErrUnsynchronized = errors.New("timesync: clock not synchronized")
+
+	// ErrNoSource is returned when no time source is available.
+	ErrNoSource = errors.New("timesync: no time source available")
+)
+
+// Source is a source of time.
+type Source interface {
+	// Time returns the time of the source, or an error while it is not
+	// available, like a GPS without a fix.
+	Time() (time.Time, error)
+}
+
+// SourceFunc adapts a function to the Source interface.
+type SourceFunc func() (time.Time, error)
+
+// Time implements Source.
+func (f SourceFunc) Time() (time.Time, error) {
+	return f()
+}
+
+// RTC is a real time clock, like the DS3231.
+type RTC interface {
+	Source
+	SetTime(t time.Time) error
+}
+
+// NTP is the system clock while the kernel reports it synchronized, by an
+// NTP daemon like ntpd, chronyd or systemd-timesyncd.
+var NTP Source = SourceFunc(func() (time.Time, error) {
+	var tx syscall.Timex
+	state, err := syscall.Adjtimex(&tx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if state == timeError {
+		return time.Time{}, ErrUnsynchronized
+	}
+	return time.Now(), nil
+})
+
+// SetSystemTime sets the system clock to t. It needs CAP_SYS_TIME.
+func SetSystemTime(t time.Time) error {
+	tv := syscall.NsecToTimeval(t.UnixNano())
+	return syscall.Settimeofday(&tv)
+}
+
+// EventType identifies the kind of change reported by an Event.
+type EventType int
+
+const (
+	// SourceChanged is emitted when the keeper switches to another source.
+	SourceChanged EventType = iota
+
+	// Stepped is emitted when the system clock is stepped to the source.
+	Stepped
+
+	// RTCSet is emitted when the real time clock is set from a reference.
+	RTCSet
+)
+
+var eventNames = map[EventType]string{
+	SourceChanged: "source changed",
+	Stepped:       "clock stepped",
+	RTCSet:        "rtc set",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event reports a change of the time source or of a clock.
+type Event struct {
+	Type EventType
+	// Source is the name of the source in use, empty when none is.
+	Source string
+	// Offset is how far the clock was stepped or set, forward when
+	// positive.
+	Offset time.Duration
+	// Time is the time of the source at the event.
+	Time time.Time
+}
+
+func (e Event) String() string {
+	switch e.Type {
+	case SourceChanged:
+		if e.Source == "" {
+			return "timesync: no time source"
+		}
+		return fmt.Sprintf("timesync: using %v", e.Source)
+	}
+	return fmt.Sprintf("timesync: %v by %v from %v", e.Type, e.Offset, e.Source)
+}
+
+type reference struct {
+	name string
+	src  Source
+}
+
+// Keeper picks the best available time source, and keeps the system clock
+// and the real time clock set from it.
+type Keeper struct {
+	// RTC is the real time clock, nil if there is none.
+	RTC RTC
+
+	// MaxOffset is the largest offset tolerated before a clock is set.
+	MaxOffset time.Duration
+
+	// Poll is the interval between synchronizations while running.
+	Poll time.Duration
+
+	// SetSystemTime sets the system clock, SetSystemTime by default.
+	SetSystemTime func(t time.Time) error
+
+	// HealthName is the name the synchronizations while running are
+	// reported under to the health registry, "timesync" when empty.
+	HealthName string
+
+	clock clock.Clock
+
+	mu     sync.Mutex
+	refs   []reference
+	source string
+	events chan Event
+
+	quit, done chan struct{}
+}
+
+// New creates a new Keeper disciplining rtc, which can be nil.
+func New(rtc RTC) *Keeper {
+	return NewWithClock(rtc, clock.Real)
+}
+
+// NewWithClock creates a new Keeper with c standing for the system clock,
+// like a clock.Virtual in tests.
+func NewWithClock(rtc RTC, c clock.Clock) *Keeper {
+	return &Keeper{
+		RTC:           rtc,
+		MaxOffset:     DefaultMaxOffset,
+		Poll:          DefaultPoll,
+		SetSystemTime: SetSystemTime,
+		clock:         clock.Or(c),
+		events:        make(chan Event, eventBuffer),
+	}
+}
+
+// AddReference adds a reference source, preferred to the ones added after
+// it and to the real time clock.
+func (k *Keeper) AddReference(name string, src Source) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.refs = append(k.refs, reference{name, src})
+}
+
+// Source returns the name of the source in use, empty when none is.
+func (k *Keeper) Source() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.source
+}
+
+// Events returns the channel the changes are reported on. Events are
+// dropped when the channel is full.
+func (k *Keeper) Events() <-chan Event {
+	return k.events
+}
+
+func (k *Keeper) publish(ev Event) {
+	glog.V(1).Info(ev)
+	select {
+	case k.events <- ev:
+	default:
+		glog.Warningf("timesync: event channel full, dropping %v", ev)
+	}
+}
+
+// best returns the time of the best available source and its name.
+func (k *Keeper) best() (time.Time, string, error) {
+	k.mu.Lock()
+	refs := k.refs
+	k.mu.Unlock()
+
+	for _, r := range refs {
+		t, err := r.src.Time()
+		if err == nil {
+			return t, r.name, nil
+		}
+		glog.V(2).Infof("timesync: %v unavailable: %v", r.name, err)
+	}
+	if k.RTC == nil {
+		return time.Time{}, "", ErrNoSource
+	}
+	t, err := k.RTC.Time()
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("timesync: reading rtc: %v", err)
+	}
+	return t, RTCName, nil
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// Sync picks the best available source, steps the system clock to it, and
+// sets the real time clock from it when it is a reference.
+func (k *Keeper) Sync() error {
+	t, name, err := k.best()
+
+	k.mu.Lock()
+	changed := name != k.source
+	k.source = name
+	k.mu.Unlock()
+	if changed {
+		k.publish(Event{Type: SourceChanged, Source: name, Time: t})
+	}
+	if err != nil {
+		return err
+	}
+
+	if offset := t.Sub(k.clock.Now()); abs(offset) > k.MaxOffset {
+		if err := k.SetSystemTime(t); err != nil {
+			return fmt.Errorf("timesync: setting system clock: %v", err)
+		}
+		k.publish(Event{Type: Stepped, Source: name, Offset: offset, Time: t})
+	}
+
+	if name == RTCName || k.RTC == nil {
+		return nil
+	}
+	// An RTC which lost the time cannot be read, and is set too, with no
+	// offset reported.
+	var offset time.Duration
+	if rt, err := k.RTC.Time(); err == nil {
+		if offset = t.Sub(rt); abs(offset) <= k.MaxOffset {
+			return nil
+		}
+	}
+	if err := k.RTC.SetTime(t); err != nil {
+		return fmt.Errorf("timesync: setting rtc: %v", err)
+	}
+	k.publish(Event{Type: RTCSet, Source: name, Offset: offset, Time: t})
+	return nil
+}
+
+// Run synchronizes the clocks once, and then every Poll in the background.
+// The error of the first synchronization is returned, but the keeper keeps
+// running, so a reference which becomes available later is still used.
+func (k *Keeper) Run() error {
+	err := k.Sync()
+	health.Observe(k.healthName(), err)
+
+	k.quit = make(chan struct{})
+	k.done = make(chan struct{})
+
+	go func() {
+		defer close(k.done)
+
+		ticker := k.clock.NewTicker(k.Poll)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				err := k.Sync()
+				if err != nil {
+					glog.Errorf("%v", err)
+				}
+				health.Observe(k.healthName(), err)
+			case <-k.quit:
+				return
+			}
+		}
+	}()
+	return err
+}
+
+// healthName returns the name of the keeper in the health registry.
+func (k *Keeper) healthName() string {
+	if k.HealthName == "" {
+		return "timesync"
+	}
+	return k.HealthName
+}
+
+// Close stops synchronizing the clocks.
+func (k *Keeper) Close() error {
+	if k.quit != nil {
+		close(k.quit)
+		<-k.done
+		k.quit = nil
+		health.Default.Remove(k.healthName())
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package timesync
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kidoman/embd/clock"
+	"github.com/kidoman/embd/health"
+)
+
+type fakeRTC struct {
+	t   time.Time
+	err error
+}
+
+func (r *fakeRTC) Time() (time.Time, error) { return r.t, r.err }
+
+func (r *fakeRTC) SetTime(t time.Time) error {
+	r.t, r.err = t.Truncate(time.Second), nil
+	return nil
+}
+
+func drain(k *Keeper) []Event {
+	var evs []Event
+	for {
+		select {
+		case ev := <-k.events:
+			evs = append(evs, ev)
+		default:
+			return evs
+		}
+	}
+}
+
+func TestKeeper(t *testing.T) {
+	boot := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := clock.NewVirtual(boot)
+	rtc := &fakeRTC{t: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	k := NewWithClock(rtc, c)
+	k.SetSystemTime = func(t time.Time) error {
+		c.Set(t)
+		return nil
+	}
+	gps := time.Time{}
+	k.AddReference("gps", SourceFunc(func() (time.Time, error) {
+		if gps.IsZero() {
+			return time.Time{}, errors.New("no fix")
+		}
+		return gps, nil
+	}))
+
+	// Without a GPS fix, the clock is set from the RTC at boot.
+	if err := k.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if k.Source() != RTCName || !c.Now().Equal(rtc.t) {
+		t.Errorf("source %q at %v; want rtc at %v", k.Source(), c.Now(), rtc.t)
+	}
+	evs := drain(k)
+	if len(evs) != 2 || evs[0].Type != SourceChanged || evs[1].Type != Stepped || evs[1].Offset != rtc.t.Sub(boot) {
+		t.Errorf("boot events %v; want the source change and the step", evs)
+	}
+
+	// The GPS gets a fix, 30s ahead of the drifted RTC.
+	gps = rtc.t.Add(30 * time.Second)
+	if err := k.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if k.Source() != "gps" || !c.Now().Equal(gps) || !rtc.t.Equal(gps) {
+		t.Errorf("source %q at %v, rtc at %v; want gps at %v", k.Source(), c.Now(), rtc.t, gps)
+	}
+	evs = drain(k)
+	if len(evs) != 3 || evs[1].Type != Stepped || evs[2].Type != RTCSet || evs[2].Offset != 30*time.Second {
+		t.Errorf("fix events %v; want the source change, the step and the rtc set", evs)
+	}
+
+	// Within the tolerated offset, nothing is set.
+	gps = gps.Add(time.Second)
+	if err := k.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if evs := drain(k); len(evs) != 0 {
+		t.Errorf("events %v within the tolerated offset; want none", evs)
+	}
+}
+
+func TestKeeper_noSource(t *testing.T) {
+	k := NewWithClock(&fakeRTC{err: errors.New("oscillator stopped")}, clock.NewVirtual(time.Time{}))
+	if err := k.Sync(); err == nil {
+		t.Error("Sync() with a stopped RTC succeeded")
+	}
+	k.RTC = nil
+	if err := k.Sync(); err != ErrNoSource {
+		t.Errorf("Sync() = %v; want ErrNoSource", err)
+	}
+	if k.Source() != "" {
+		t.Errorf("Source() = %q; want none", k.Source())
+	}
+
+	if err := k.Run(); err != ErrNoSource {
+		t.Errorf("Run() = %v; want ErrNoSource", err)
+	}
+	if s := status(k.healthName()); s != health.Degraded {
+		t.Errorf("status = %v; want degraded", s)
+	}
+	k.Close()
+	if s := status(k.healthName()); s != -1 {
+		t.Errorf("status = %v after Close; want the keeper removed", s)
+	}
+}
+
+// status returns the status of a driver in the health registry, or -1.
+func status(name string) health.Status {
+	for _, rep := range health.Default.Reports() {
+		if rep.Name == name {
+			return rep.Status
+		}
+	}
+	return -1
+}
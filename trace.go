@@ -0,0 +1,255 @@
+// Tracing support.
+
+package embd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Attr is a key/value attribute of a span.
+type Attr struct {
+	Key   string
+	Value interface{}
+}
+
+// A Span is a traced operation, like a bus transaction.
+type Span interface {
+	// End finishes the span, recording err if it is not nil.
+	End(err error)
+}
+
+// A Tracer starts spans. Implement it to forward spans to a tracing system
+// like OpenTelemetry:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(name string, attrs ...embd.Attr) embd.Span {
+//		_, span := t.Tracer.Start(context.Background(), name)
+//		for _, a := range attrs {
+//			span.SetAttributes(attribute.String(a.Key, fmt.Sprint(a.Value)))
+//		}
+//		return otelSpan{span}
+//	}
+type Tracer interface {
+	Start(name string, attrs ...Attr) Span
+}
+
+var (
+	tracerLock sync.RWMutex
+	tracer     Tracer
+)
+
+// SetTracer sets the tracer receiving the spans of I²C and SPI transactions
+// and of the drivers' high level operations. Buses are only traced if the
+// tracer is set before creating them. Passing nil disables tracing.
+func SetTracer(t Tracer) {
+	tracerLock.Lock()
+	defer tracerLock.Unlock()
+
+	tracer = t
+}
+
+// Tracing returns true if a tracer is set.
+func Tracing() bool {
+	tracerLock.RLock()
+	defer tracerLock.RUnlock()
+
+	return tracer != nil
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(error) {}
+
+// StartSpan starts a span with the tracer set by SetTracer. Drivers use it to
+// trace their high level operations:
+//
+//	func (d *Device) Message(s string) (err error) {
+//		span := embd.StartSpan("device.Message", embd.Attr{Key: "len", Value: len(s)})
+//		defer func() { span.End(err) }()
+//		...
+//	}
+func StartSpan(name string, attrs ...Attr) Span {
+	tracerLock.RLock()
+	t := tracer
+	tracerLock.RUnlock()
+
+	if t == nil {
+		return noopSpan{}
+	}
+	return t.Start(name, attrs...)
+}
+
+// LogTracer is a Tracer logging the duration of every span with glog, at
+// verbosity level 2.
+type LogTracer struct{}
+
+type logSpan struct {
+	name  string
+	attrs []Attr
+	start time.Time
+}
+
+// Start implements Tracer.
+func (LogTracer) Start(name string, attrs ...Attr) Span {
+	return &logSpan{name: name, attrs: attrs, start: time.Now()}
+}
+
+func (s *logSpan) End(err error) {
+	if !glog.V(2) {
+		return
+	}
+	msg := s.name
+	for _, a := range s.attrs {
+		msg += fmt.Sprintf(" %v=%v", a.Key, a.Value)
+	}
+	if err != nil {
+		msg += fmt.Sprintf(" error=%q", err)
+	}
+	glog.Infof("trace: %v took %v", msg, time.Since(s.start))
+}
+
+type tracedI2CBus struct {
+	bus I2CBus
+	l   byte
+}
+
+// TraceI2CBus returns an I2CBus starting a span for every transaction on
+// bus, which is on line l. NewI2CBus does this when a tracer is set.
+func TraceI2CBus(bus I2CBus, l byte) I2CBus {
+	return &tracedI2CBus{bus: bus, l: l}
+}
+
+func (b *tracedI2CBus) start(op string, addr byte, attrs ...Attr) Span {
+	attrs = append([]Attr{{"bus", b.l}, {"addr", fmt.Sprintf("0x%02x", addr)}}, attrs...)
+	return StartSpan("i2c."+op, attrs...)
+}
+
+func regAttr(r byte) Attr {
+	return Attr{"reg", fmt.Sprintf("0x%02x", r)}
+}
+
+func (b *tracedI2CBus) ReadByte(addr byte) (value byte, err error) {
+	span := b.start("ReadByte", addr)
+	defer func() { span.End(err) }()
+	return b.bus.ReadByte(addr)
+}
+
+func (b *tracedI2CBus) WriteByte(addr, value byte) (err error) {
+	span := b.start("WriteByte", addr)
+	defer func() { span.End(err) }()
+	return b.bus.WriteByte(addr, value)
+}
+
+func (b *tracedI2CBus) WriteBytes(addr byte, value []byte) (err error) {
+	span := b.start("WriteBytes", addr, Attr{"len", len(value)})
+	defer func() { span.End(err) }()
+	return b.bus.WriteBytes(addr, value)
+}
+
+func (b *tracedI2CBus) ReadFromReg(addr, r byte, value []byte) (err error) {
+	span := b.start("ReadFromReg", addr, regAttr(r), Attr{"len", len(value)})
+	defer func() { span.End(err) }()
+	return b.bus.ReadFromReg(addr, r, value)
+}
+
+func (b *tracedI2CBus) ReadByteFromReg(addr, r byte) (value byte, err error) {
+	span := b.start("ReadByteFromReg", addr, regAttr(r))
+	defer func() { span.End(err) }()
+	return b.bus.ReadByteFromReg(addr, r)
+}
+
+func (b *tracedI2CBus) ReadWordFromReg(addr, r byte) (value uint16, err error) {
+	span := b.start("ReadWordFromReg", addr, regAttr(r))
+	defer func() { span.End(err) }()
+	return b.bus.ReadWordFromReg(addr, r)
+}
+
+func (b *tracedI2CBus) WriteToReg(addr, r byte, value []byte) (err error) {
+	span := b.start("WriteToReg", addr, regAttr(r), Attr{"len", len(value)})
+	defer func() { span.End(err) }()
+	return b.bus.WriteToReg(addr, r, value)
+}
+
+func (b *tracedI2CBus) WriteByteToReg(addr, r, value byte) (err error) {
+	span := b.start("WriteByteToReg", addr, regAttr(r))
+	defer func() { span.End(err) }()
+	return b.bus.WriteByteToReg(addr, r, value)
+}
+
+func (b *tracedI2CBus) WriteWordToReg(addr, r byte, value uint16) (err error) {
+	span := b.start("WriteWordToReg", addr, regAttr(r))
+	defer func() { span.End(err) }()
+	return b.bus.WriteWordToReg(addr, r, value)
+}
+
+// SetTimeout implements I2CTimeoutSetter when the traced bus does.
+func (b *tracedI2CBus) SetTimeout(d time.Duration) error {
+	if s, ok := b.bus.(I2CTimeoutSetter); ok {
+		return s.SetTimeout(d)
+	}
+	return ErrFeatureNotSupported
+}
+
+// WriteTransfer implements I2CTransferWriter when the traced bus does.
+func (b *tracedI2CBus) WriteTransfer(addr byte, value []byte) (err error) {
+	tw, ok := b.bus.(I2CTransferWriter)
+	if !ok {
+		return ErrFeatureNotSupported
+	}
+	span := b.start("WriteTransfer", addr, Attr{"len", len(value)})
+	defer func() { span.End(err) }()
+	return tw.WriteTransfer(addr, value)
+}
+
+func (b *tracedI2CBus) Close() error {
+	return b.bus.Close()
+}
+
+type tracedSPIBus struct {
+	bus     SPIBus
+	channel byte
+}
+
+// TraceSPIBus returns a SPIBus starting a span for every transaction on
+// bus, which is on the given channel. NewSPIBus does this when a tracer is
+// set.
+func TraceSPIBus(bus SPIBus, channel byte) SPIBus {
+	return &tracedSPIBus{bus: bus, channel: channel}
+}
+
+func (b *tracedSPIBus) start(op string, n int) Span {
+	return StartSpan("spi."+op, Attr{"channel", b.channel}, Attr{"len", n})
+}
+
+func (b *tracedSPIBus) TransferAndRecieveData(dataBuffer []uint8) (err error) {
+	span := b.start("TransferAndRecieveData", len(dataBuffer))
+	defer func() { span.End(err) }()
+	return b.bus.TransferAndRecieveData(dataBuffer)
+}
+
+func (b *tracedSPIBus) ReceiveData(len int) (data []uint8, err error) {
+	span := b.start("ReceiveData", len)
+	defer func() { span.End(err) }()
+	return b.bus.ReceiveData(len)
+}
+
+func (b *tracedSPIBus) TransferAndReceiveByte(data byte) (value byte, err error) {
+	span := b.start("TransferAndReceiveByte", 1)
+	defer func() { span.End(err) }()
+	return b.bus.TransferAndReceiveByte(data)
+}
+
+func (b *tracedSPIBus) ReceiveByte() (value byte, err error) {
+	span := b.start("ReceiveByte", 1)
+	defer func() { span.End(err) }()
+	return b.bus.ReceiveByte()
+}
+
+func (b *tracedSPIBus) Close() error {
+	return b.bus.Close()
+}
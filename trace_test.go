@@ -0,0 +1,89 @@
+package embd
+
+import (
+	"errors"
+	"testing"
+)
+
+type recordedSpan struct {
+	name  string
+	attrs []Attr
+	ended bool
+	err   error
+}
+
+func (s *recordedSpan) End(err error) {
+	s.ended = true
+	s.err = err
+}
+
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(name string, attrs ...Attr) Span {
+	s := &recordedSpan{name: name, attrs: attrs}
+	t.spans = append(t.spans, s)
+	return s
+}
+
+type fakeI2CBus struct {
+	I2CBus
+	err error
+}
+
+func (b *fakeI2CBus) WriteByteToReg(addr, reg, value byte) error {
+	return b.err
+}
+
+func TestTraceI2CBus(t *testing.T) {
+	tr := &recordingTracer{}
+	SetTracer(tr)
+	defer SetTracer(nil)
+
+	errFailed := errors.New("failed")
+	bus := TraceI2CBus(&fakeI2CBus{err: errFailed}, 1)
+	if err := bus.WriteByteToReg(0x40, 0x01, 0xff); err != errFailed {
+		t.Fatalf("WriteByteToReg() = %v; want %v", err, errFailed)
+	}
+
+	if len(tr.spans) != 1 {
+		t.Fatalf("got %v spans; want 1", len(tr.spans))
+	}
+	s := tr.spans[0]
+	if s.name != "i2c.WriteByteToReg" {
+		t.Errorf("span name = %q; want %q", s.name, "i2c.WriteByteToReg")
+	}
+	if !s.ended || s.err != errFailed {
+		t.Errorf("span ended = %v with %v; want true with %v", s.ended, s.err, errFailed)
+	}
+	want := []Attr{{"bus", byte(1)}, {"addr", "0x40"}, {"reg", "0x01"}}
+	if len(s.attrs) != len(want) {
+		t.Fatalf("span attrs = %v; want %v", s.attrs, want)
+	}
+	for i := range want {
+		if s.attrs[i] != want[i] {
+			t.Errorf("span attrs = %v; want %v", s.attrs, want)
+			break
+		}
+	}
+}
+
+func TestTraceI2CBus_writeTransfer(t *testing.T) {
+	bus := TraceI2CBus(&fakeI2CBus{}, 1)
+	tw, ok := bus.(I2CTransferWriter)
+	if !ok {
+		t.Fatal("traced bus is not an I2CTransferWriter")
+	}
+	if err := tw.WriteTransfer(0x27, []byte{1, 2}); err != ErrFeatureNotSupported {
+		t.Errorf("WriteTransfer() = %v; want ErrFeatureNotSupported for a bus without single transfers", err)
+	}
+}
+
+func TestStartSpan_noTracer(t *testing.T) {
+	SetTracer(nil)
+	if Tracing() {
+		t.Fatal("Tracing() = true without a tracer")
+	}
+	StartSpan("noop").End(nil)
+}
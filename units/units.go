@@ -0,0 +1,191 @@
+/*
+Package units provides typed physical quantities, so that it is always clear
+whether a reading is in Celsius or Fahrenheit, Pa or hPa.
+
+Quantities are stored in SI units and are converted on output:
+
+	temp, _ := baro.Temperature()
+	fmt.Println(temp)              // 21.5°C
+	fmt.Println(temp.Fahrenheit()) // 70.7
+
+	d := 12 * units.Inch
+	fmt.Println(d.Millimeters()) // 304.8
+
+The String methods format the quantities with their unit, so readings can be
+written to displays as they are.
+*/
+package units
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func format(v float64, unit string) string {
+	return strconv.FormatFloat(v, 'f', -1, 64) + unit
+}
+
+// Temperature is a temperature in degrees Celsius.
+type Temperature float64
+
+// Common temperatures.
+const (
+	AbsoluteZero Temperature = -273.15
+	Freezing     Temperature = 0
+)
+
+// Fahrenheit returns a temperature given in degrees Fahrenheit.
+func Fahrenheit(f float64) Temperature {
+	return Temperature((f - 32) * 5 / 9)
+}
+
+// Kelvin returns a temperature given in kelvins.
+func Kelvin(k float64) Temperature {
+	return Temperature(k) + AbsoluteZero
+}
+
+// Celsius returns the temperature in degrees Celsius.
+func (t Temperature) Celsius() float64 {
+	return float64(t)
+}
+
+// Fahrenheit returns the temperature in degrees Fahrenheit.
+func (t Temperature) Fahrenheit() float64 {
+	return float64(t)*9/5 + 32
+}
+
+// Kelvin returns the temperature in kelvins.
+func (t Temperature) Kelvin() float64 {
+	return float64(t - AbsoluteZero)
+}
+
+func (t Temperature) String() string {
+	return format(round(float64(t), 2), "°C")
+}
+
+// Pressure is a pressure in pascals.
+type Pressure float64
+
+// Common pressures.
+const (
+	Pascal           Pressure = 1
+	Hectopascal               = 100 * Pascal
+	Kilopascal                = 1000 * Pascal
+	InchOfMercury             = 3386.389 * Pascal
+	Millibar                  = Hectopascal
+	StandardSeaLevel          = 101325 * Pascal
+)
+
+// Pascals returns the pressure in pascals.
+func (p Pressure) Pascals() float64 {
+	return float64(p)
+}
+
+// Hectopascals returns the pressure in hectopascals (millibars).
+func (p Pressure) Hectopascals() float64 {
+	return float64(p / Hectopascal)
+}
+
+// InchesOfMercury returns the pressure in inches of mercury.
+func (p Pressure) InchesOfMercury() float64 {
+	return float64(p / InchOfMercury)
+}
+
+func (p Pressure) String() string {
+	return format(round(p.Hectopascals(), 2), " hPa")
+}
+
+// Distance is a distance in meters.
+type Distance float64
+
+// Common distances.
+const (
+	Millimeter Distance = 0.001
+	Centimeter          = 10 * Millimeter
+	Meter               = 1000 * Millimeter
+	Kilometer           = 1000 * Meter
+	Inch                = 25.4 * Millimeter
+	Foot                = 12 * Inch
+)
+
+// Meters returns the distance in meters.
+func (d Distance) Meters() float64 {
+	return float64(d)
+}
+
+// Centimeters returns the distance in centimeters.
+func (d Distance) Centimeters() float64 {
+	return float64(d / Centimeter)
+}
+
+// Millimeters returns the distance in millimeters.
+func (d Distance) Millimeters() float64 {
+	return float64(d / Millimeter)
+}
+
+// Inches returns the distance in inches.
+func (d Distance) Inches() float64 {
+	return float64(d / Inch)
+}
+
+// Feet returns the distance in feet.
+func (d Distance) Feet() float64 {
+	return float64(d / Foot)
+}
+
+func (d Distance) String() string {
+	abs := d
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs >= Kilometer:
+		return format(round(float64(d/Kilometer), 3), " km")
+	case abs >= Meter:
+		return format(round(d.Meters(), 3), " m")
+	case abs >= Centimeter:
+		return format(round(d.Centimeters(), 2), " cm")
+	}
+	return format(round(d.Millimeters(), 2), " mm")
+}
+
+// Voltage is an electric potential in volts.
+type Voltage float64
+
+// Common voltages.
+const (
+	Microvolt Voltage = 1e-6
+	Millivolt         = 1000 * Microvolt
+	Volt              = 1000 * Millivolt
+)
+
+// Volts returns the voltage in volts.
+func (v Voltage) Volts() float64 {
+	return float64(v)
+}
+
+// Millivolts returns the voltage in millivolts.
+func (v Voltage) Millivolts() float64 {
+	return float64(v / Millivolt)
+}
+
+func (v Voltage) String() string {
+	abs := v
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs == 0 || abs >= Volt:
+		return format(round(v.Volts(), 3), " V")
+	case abs >= Millivolt:
+		return format(round(v.Millivolts(), 3), " mV")
+	}
+	return format(round(float64(v/Microvolt), 3), " µV")
+}
+
+// round rounds v to the given number of decimals, so that readings are
+// printed without floating point noise.
+func round(v float64, decimals int) float64 {
+	f, _ := strconv.ParseFloat(fmt.Sprintf("%.*f", decimals, v), 64)
+	return f
+}
@@ -0,0 +1,95 @@
+package units
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestTemperature(t *testing.T) {
+	var tests = []struct {
+		t       Temperature
+		c, f, k float64
+		str     string
+	}{
+		{0, 0, 32, 273.15, "0°C"},
+		{100, 100, 212, 373.15, "100°C"},
+		{Fahrenheit(-40), -40, -40, 233.15, "-40°C"},
+		{Kelvin(0), -273.15, -459.67, 0, "-273.15°C"},
+		{21.456, 21.456, 70.6208, 294.606, "21.46°C"},
+	}
+	for _, test := range tests {
+		if c := test.t.Celsius(); !near(c, test.c) {
+			t.Errorf("%v: Celsius() = %v; want %v", test.t, c, test.c)
+		}
+		if f := test.t.Fahrenheit(); !near(f, test.f) {
+			t.Errorf("%v: Fahrenheit() = %v; want %v", test.t, f, test.f)
+		}
+		if k := test.t.Kelvin(); !near(k, test.k) {
+			t.Errorf("%v: Kelvin() = %v; want %v", test.t, k, test.k)
+		}
+		if str := test.t.String(); str != test.str {
+			t.Errorf("String() = %q; want %q", str, test.str)
+		}
+	}
+}
+
+func TestPressure(t *testing.T) {
+	p := StandardSeaLevel
+	if hpa := p.Hectopascals(); !near(hpa, 1013.25) {
+		t.Errorf("Hectopascals() = %v; want 1013.25", hpa)
+	}
+	if inhg := p.InchesOfMercury(); math.Abs(inhg-29.921) > 0.001 {
+		t.Errorf("InchesOfMercury() = %v; want 29.921", inhg)
+	}
+	if str := p.String(); str != "1013.25 hPa" {
+		t.Errorf("String() = %q; want %q", str, "1013.25 hPa")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	if mm := (12 * Inch).Millimeters(); !near(mm, 304.8) {
+		t.Errorf("Millimeters() = %v; want 304.8", mm)
+	}
+	if in := (254 * Millimeter).Inches(); !near(in, 10) {
+		t.Errorf("Inches() = %v; want 10", in)
+	}
+	var tests = []struct {
+		d   Distance
+		str string
+	}{
+		{0, "0 mm"},
+		{3 * Millimeter, "3 mm"},
+		{12.345 * Centimeter, "12.35 cm"},
+		{-2 * Meter, "-2 m"},
+		{1500 * Meter, "1.5 km"},
+	}
+	for _, test := range tests {
+		if str := test.d.String(); str != test.str {
+			t.Errorf("String() = %q; want %q", str, test.str)
+		}
+	}
+}
+
+func TestVoltage(t *testing.T) {
+	var tests = []struct {
+		v   Voltage
+		str string
+	}{
+		{0, "0 V"},
+		{3.3 * Volt, "3.3 V"},
+		{250 * Millivolt, "250 mV"},
+		{12 * Microvolt, "12 µV"},
+	}
+	for _, test := range tests {
+		if str := test.v.String(); str != test.str {
+			t.Errorf("String() = %q; want %q", str, test.str)
+		}
+	}
+	if mv := (1.5 * Volt).Millivolts(); !near(mv, 1500) {
+		t.Errorf("Millivolts() = %v; want 1500", mv)
+	}
+}
@@ -0,0 +1,319 @@
+/*
+Package regmap provides named access to the registers of I²C and SPI chips,
+with bitfield helpers, read-modify-write with caching and dumps for
+debugging.
+
+	m := regmap.New(regmap.I2C(bus, 0x68),
+		regmap.Register{Name: "PWR_MGMT_1", Addr: 0x6B},
+		regmap.Register{Name: "ACCEL_X", Addr: 0x3B, Width: 2, Volatile: true},
+	)
+	sleep := m.Field("PWR_MGMT_1", 6, 1)
+	if err := sleep.Set(0); err != nil {
+		return err
+	}
+	x, err := m.Read("ACCEL_X")
+*/
+package regmap
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/kidoman/embd"
+)
+
+// Bus transfers register contents to and from a chip.
+type Bus interface {
+	ReadReg(reg byte, value []byte) error
+	WriteReg(reg byte, value []byte) error
+}
+
+type i2cBus struct {
+	bus  embd.I2CBus
+	addr byte
+}
+
+// I2C returns a Bus accessing the registers of the chip at addr.
+func I2C(bus embd.I2CBus, addr byte) Bus {
+	return &i2cBus{bus: bus, addr: addr}
+}
+
+func (b *i2cBus) ReadReg(reg byte, value []byte) error {
+	return b.bus.ReadFromReg(b.addr, reg, value)
+}
+
+func (b *i2cBus) WriteReg(reg byte, value []byte) error {
+	return b.bus.WriteToReg(b.addr, reg, value)
+}
+
+type spiBus struct {
+	bus     embd.SPIBus
+	readBit byte
+}
+
+// SPI returns a Bus accessing the registers of a SPI chip which is sent the
+// register address followed by the data, with readBit set in the address for
+// reads (usually 0x80).
+func SPI(bus embd.SPIBus, readBit byte) Bus {
+	return &spiBus{bus: bus, readBit: readBit}
+}
+
+func (b *spiBus) ReadReg(reg byte, value []byte) error {
+	buf := make([]byte, len(value)+1)
+	buf[0] = reg | b.readBit
+	if err := b.bus.TransferAndRecieveData(buf); err != nil {
+		return err
+	}
+	copy(value, buf[1:])
+	return nil
+}
+
+func (b *spiBus) WriteReg(reg byte, value []byte) error {
+	buf := append([]byte{reg &^ b.readBit}, value...)
+	return b.bus.TransferAndRecieveData(buf)
+}
+
+// Register describes a register of a chip.
+type Register struct {
+	Name string
+	Addr byte
+
+	// Width is the size of the register in bytes, from 1 (the default) to 4.
+	Width int
+
+	// LittleEndian registers have their least significant byte first.
+	LittleEndian bool
+
+	// Volatile registers are changed by the chip (like measurements and
+	// status flags), so they are always read from the chip.
+	Volatile bool
+
+	// ReadOnly registers can not be written.
+	ReadOnly bool
+}
+
+func (r *Register) width() int {
+	if r.Width <= 0 {
+		return 1
+	}
+	return r.Width
+}
+
+func (r *Register) decode(b []byte) uint32 {
+	var v uint32
+	for i := range b {
+		if r.LittleEndian {
+			v |= uint32(b[i]) << (8 * uint(i))
+		} else {
+			v = v<<8 | uint32(b[i])
+		}
+	}
+	return v
+}
+
+func (r *Register) encode(v uint32) []byte {
+	b := make([]byte, r.width())
+	for i := range b {
+		shift := 8 * uint(i)
+		if !r.LittleEndian {
+			shift = 8 * uint(len(b)-1-i)
+		}
+		b[i] = byte(v >> shift)
+	}
+	return b
+}
+
+// Map is the register map of a chip. It is safe for concurrent use.
+type Map struct {
+	bus Bus
+
+	mu    sync.Mutex
+	regs  map[string]*Register
+	cache map[string]uint32
+}
+
+// New returns the register map of the chip on bus.
+func New(bus Bus, regs ...Register) *Map {
+	m := &Map{
+		bus:   bus,
+		regs:  make(map[string]*Register),
+		cache: make(map[string]uint32),
+	}
+	for i := range regs {
+		r := regs[i]
+		if r.width() > 4 {
+			panic(fmt.Sprintf("regmap: register %v is wider than 4 bytes", r.Name))
+		}
+		m.regs[r.Name] = &r
+	}
+	return m
+}
+
+func (m *Map) register(name string) (*Register, error) {
+	r, ok := m.regs[name]
+	if !ok {
+		return nil, fmt.Errorf("regmap: unknown register %q", name)
+	}
+	return r, nil
+}
+
+func (m *Map) read(r *Register) (uint32, error) {
+	if v, ok := m.cache[r.Name]; ok && !r.Volatile {
+		return v, nil
+	}
+	buf := make([]byte, r.width())
+	if err := m.bus.ReadReg(r.Addr, buf); err != nil {
+		return 0, err
+	}
+	v := r.decode(buf)
+	if !r.Volatile {
+		m.cache[r.Name] = v
+	}
+	return v, nil
+}
+
+func (m *Map) write(r *Register, v uint32) error {
+	if r.ReadOnly {
+		return fmt.Errorf("regmap: register %v is read only", r.Name)
+	}
+	if err := m.bus.WriteReg(r.Addr, r.encode(v)); err != nil {
+		// The register content is unknown now.
+		delete(m.cache, r.Name)
+		return err
+	}
+	if !r.Volatile {
+		m.cache[r.Name] = v
+	}
+	return nil
+}
+
+// Read returns the value of a register. Non volatile registers are only read
+// from the chip the first time.
+func (m *Map) Read(name string) (uint32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, err := m.register(name)
+	if err != nil {
+		return 0, err
+	}
+	return m.read(r)
+}
+
+// Write writes the value of a register.
+func (m *Map) Write(name string, v uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, err := m.register(name)
+	if err != nil {
+		return err
+	}
+	return m.write(r, v)
+}
+
+// Update sets the bits of a register selected by mask to v, leaving the
+// other bits unchanged. Nothing is written if the bits already have the
+// value.
+func (m *Map) Update(name string, mask, v uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, err := m.register(name)
+	if err != nil {
+		return err
+	}
+	old, err := m.read(r)
+	if err != nil {
+		return err
+	}
+	nv := old&^mask | v&mask
+	if nv == old && !r.Volatile {
+		return nil
+	}
+	return m.write(r, nv)
+}
+
+// Invalidate drops the cached register values, so that they are read again
+// from the chip (after a reset, for instance).
+func (m *Map) Invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cache = make(map[string]uint32)
+}
+
+// Dump reads all the registers from the chip and writes their values to w,
+// in address order.
+func (m *Map) Dump(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	regs := make([]*Register, 0, len(m.regs))
+	for _, r := range m.regs {
+		regs = append(regs, r)
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		return regs[i].Addr < regs[j].Addr
+	})
+
+	for _, r := range regs {
+		buf := make([]byte, r.width())
+		if err := m.bus.ReadReg(r.Addr, buf); err != nil {
+			return err
+		}
+		v := r.decode(buf)
+		if _, err := fmt.Fprintf(w, "%#02x %-16v %#0*x\n", r.Addr, r.Name, 2*r.width(), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Field is a bitfield of a register.
+type Field struct {
+	m     *Map
+	reg   string
+	shift uint
+	mask  uint32
+}
+
+// Field returns the bitfield of a register made of width bits starting at
+// bit shift.
+func (m *Map) Field(reg string, shift, width uint) Field {
+	return Field{m: m, reg: reg, shift: shift, mask: (1<<width - 1) << shift}
+}
+
+// Get returns the value of the bitfield.
+func (f Field) Get() (uint32, error) {
+	v, err := f.m.Read(f.reg)
+	if err != nil {
+		return 0, err
+	}
+	return (v & f.mask) >> f.shift, nil
+}
+
+// Set sets the value of the bitfield with a read-modify-write of the
+// register.
+func (f Field) Set(v uint32) error {
+	if v<<f.shift&^f.mask != 0 {
+		return fmt.Errorf("regmap: value %#x overflows bitfield of %v", v, f.reg)
+	}
+	return f.m.Update(f.reg, f.mask, v<<f.shift)
+}
+
+// Flag returns the value of a single bit field as a bool.
+func (f Field) Flag() (bool, error) {
+	v, err := f.Get()
+	return v != 0, err
+}
+
+// SetFlag sets a single bit field.
+func (f Field) SetFlag(b bool) error {
+	if b {
+		return f.Set(1)
+	}
+	return f.Set(0)
+}
@@ -0,0 +1,130 @@
+package regmap
+
+import (
+	"bytes"
+	"testing"
+)
+
+type fakeBus struct {
+	regs          map[byte]byte
+	reads, writes int
+}
+
+func newFakeBus() *fakeBus {
+	return &fakeBus{regs: make(map[byte]byte)}
+}
+
+func (b *fakeBus) ReadReg(reg byte, value []byte) error {
+	b.reads++
+	for i := range value {
+		value[i] = b.regs[reg+byte(i)]
+	}
+	return nil
+}
+
+func (b *fakeBus) WriteReg(reg byte, value []byte) error {
+	b.writes++
+	for i, v := range value {
+		b.regs[reg+byte(i)] = v
+	}
+	return nil
+}
+
+func TestReadWrite(t *testing.T) {
+	bus := newFakeBus()
+	bus.regs[0x10], bus.regs[0x11] = 0x12, 0x34
+	m := New(bus,
+		Register{Name: "BE", Addr: 0x10, Width: 2},
+		Register{Name: "LE", Addr: 0x10, Width: 2, LittleEndian: true, Volatile: true},
+	)
+
+	if v, err := m.Read("BE"); err != nil || v != 0x1234 {
+		t.Errorf("Read(BE) = %#x, %v; want 0x1234, nil", v, err)
+	}
+	if v, err := m.Read("LE"); err != nil || v != 0x3412 {
+		t.Errorf("Read(LE) = %#x, %v; want 0x3412, nil", v, err)
+	}
+	if err := m.Write("BE", 0xABCD); err != nil {
+		t.Fatal(err)
+	}
+	if bus.regs[0x10] != 0xAB || bus.regs[0x11] != 0xCD {
+		t.Errorf("Write(BE) wrote % x; want ab cd", []byte{bus.regs[0x10], bus.regs[0x11]})
+	}
+	if _, err := m.Read("nope"); err == nil {
+		t.Error("Read of an unknown register succeeded")
+	}
+}
+
+func TestCaching(t *testing.T) {
+	bus := newFakeBus()
+	m := New(bus,
+		Register{Name: "CTRL", Addr: 0x20},
+		Register{Name: "STATUS", Addr: 0x21, Volatile: true, ReadOnly: true},
+	)
+
+	m.Read("CTRL")
+	m.Read("CTRL")
+	m.Read("STATUS")
+	m.Read("STATUS")
+	if bus.reads != 3 {
+		t.Errorf("got %v reads; want 3", bus.reads)
+	}
+
+	m.Invalidate()
+	m.Read("CTRL")
+	if bus.reads != 4 {
+		t.Errorf("got %v reads after Invalidate; want 4", bus.reads)
+	}
+
+	if err := m.Write("STATUS", 1); err == nil {
+		t.Error("Write of a read only register succeeded")
+	}
+}
+
+func TestField(t *testing.T) {
+	bus := newFakeBus()
+	bus.regs[0x20] = 0x81
+	m := New(bus, Register{Name: "CTRL", Addr: 0x20})
+
+	odr := m.Field("CTRL", 4, 3)
+	if err := odr.Set(5); err != nil {
+		t.Fatal(err)
+	}
+	if bus.regs[0x20] != 0xD1 {
+		t.Errorf("CTRL = %#02x; want 0xd1", bus.regs[0x20])
+	}
+	if v, err := odr.Get(); err != nil || v != 5 {
+		t.Errorf("Get() = %v, %v; want 5, nil", v, err)
+	}
+	if err := odr.Set(8); err == nil {
+		t.Error("Set of an overflowing value succeeded")
+	}
+
+	// Setting a field to its current value is not written.
+	writes := bus.writes
+	if err := m.Field("CTRL", 0, 1).SetFlag(true); err != nil {
+		t.Fatal(err)
+	}
+	if bus.writes != writes {
+		t.Error("unchanged field was written")
+	}
+}
+
+func TestDump(t *testing.T) {
+	bus := newFakeBus()
+	bus.regs[0x0F] = 0xD4
+	bus.regs[0x28], bus.regs[0x29] = 0x01, 0x02
+	m := New(bus,
+		Register{Name: "OUT_X", Addr: 0x28, Width: 2, LittleEndian: true},
+		Register{Name: "WHO_AM_I", Addr: 0x0F},
+	)
+
+	var buf bytes.Buffer
+	if err := m.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "0x0f WHO_AM_I         0xd4\n0x28 OUT_X            0x0201\n"
+	if buf.String() != want {
+		t.Errorf("Dump() = %q; want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,301 @@
+/*
+Regmapgen generates typed register accessors for a chip driver from a YAML
+description of its registers, on top of package regmap.
+
+Add a go:generate directive to the driver:
+
+	//go:generate go run github.com/kidoman/embd/util/regmap/regmapgen -in l3gd20.yaml -out regs.go
+
+The description lists the registers with their bitfields and enumerated
+values:
+
+	package: l3gd20
+	registers:
+	  - name: WHO_AM_I
+	    addr: 0x0F
+	    readonly: true
+	  - name: CTRL_REG1
+	    addr: 0x20
+	    fields:
+	      - name: DR
+	        shift: 6
+	        width: 2
+	        enum:
+	          - {name: 95Hz, value: 0}
+	          - {name: 190Hz, value: 1}
+	      - name: PD
+	        shift: 3
+	        width: 1
+
+For it, regmapgen generates a Regs type wrapping a *regmap.Map with the
+methods WhoAmI, CtrlReg1, SetCtrlReg1, CtrlReg1Dr, SetCtrlReg1Dr, CtrlReg1Pd
+and SetCtrlReg1Pd, the CtrlReg1Dr type with its CtrlReg1Dr95Hz and
+CtrlReg1Dr190Hz values and the RegWhoAmI and RegCtrlReg1 address constants.
+Single bit fields are accessed as bools. All caps names are converted to
+camel case, other names are kept as they are.
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Chip is the YAML description of the registers of a chip.
+type Chip struct {
+	Package   string     `yaml:"package"`
+	Type      string     `yaml:"type"`
+	Registers []Register `yaml:"registers"`
+}
+
+// Register is the description of a register.
+type Register struct {
+	Name         string  `yaml:"name"`
+	Doc          string  `yaml:"doc"`
+	Addr         byte    `yaml:"addr"`
+	Width        int     `yaml:"width"`
+	LittleEndian bool    `yaml:"littleEndian"`
+	Volatile     bool    `yaml:"volatile"`
+	ReadOnly     bool    `yaml:"readonly"`
+	Fields       []Field `yaml:"fields"`
+}
+
+// Field is the description of a bitfield.
+type Field struct {
+	Name  string `yaml:"name"`
+	Doc   string `yaml:"doc"`
+	Shift uint   `yaml:"shift"`
+	Width uint   `yaml:"width"`
+	Enum  []Enum `yaml:"enum"`
+}
+
+// Enum is a named value of a bitfield.
+type Enum struct {
+	Name  string `yaml:"name"`
+	Value uint32 `yaml:"value"`
+}
+
+// camel converts register and field names like CTRL_REG1 to CtrlReg1.
+// Names which are already mixed case are kept as they are.
+func camel(s string) string {
+	if strings.ToUpper(s) != s {
+		return strings.Replace(s, "_", "", -1)
+	}
+	var out []rune
+	upper := true
+	for _, r := range s {
+		switch {
+		case r == '_' || r == '-' || r == ' ':
+			upper = true
+			continue
+		case upper:
+			out = append(out, unicode.ToUpper(r))
+		default:
+			out = append(out, unicode.ToLower(r))
+		}
+		upper = unicode.IsDigit(r)
+	}
+	return string(out)
+}
+
+func (c *Chip) validate() error {
+	if c.Package == "" {
+		return fmt.Errorf("missing package")
+	}
+	if c.Type == "" {
+		c.Type = "Regs"
+	}
+	names := make(map[string]bool)
+	for i := range c.Registers {
+		r := &c.Registers[i]
+		if r.Name == "" {
+			return fmt.Errorf("register %v: missing name", i)
+		}
+		if r.Width == 0 {
+			r.Width = 1
+		}
+		if r.Width < 0 || r.Width > 4 {
+			return fmt.Errorf("register %v: width must be between 1 and 4 bytes", r.Name)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("register %v: duplicate name", r.Name)
+		}
+		names[r.Name] = true
+		for _, f := range r.Fields {
+			if f.Width == 0 || f.Shift+f.Width > uint(8*r.Width) {
+				return fmt.Errorf("register %v: field %v does not fit in the register", r.Name, f.Name)
+			}
+			for _, e := range f.Enum {
+				if e.Value >= 1<<f.Width {
+					return fmt.Errorf("register %v: value %v of field %v does not fit in the field", r.Name, e.Name, f.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+var funcs = template.FuncMap{
+	"camel": camel,
+	"hex":   func(b byte) string { return fmt.Sprintf("%#02x", b) },
+}
+
+var tmpl = template.Must(template.New("regs").Funcs(funcs).Parse(`// Code generated by regmapgen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import "github.com/kidoman/embd/util/regmap"
+
+// Register addresses.
+const (
+{{- range .Registers}}
+	Reg{{camel .Name}} = {{hex .Addr}}
+{{- end}}
+)
+
+var registers = []regmap.Register{
+{{- range .Registers}}
+	{Name: "{{.Name}}", Addr: {{hex .Addr}}, Width: {{.Width}}
+		{{- if .LittleEndian}}, LittleEndian: true{{end}}
+		{{- if .Volatile}}, Volatile: true{{end}}
+		{{- if .ReadOnly}}, ReadOnly: true{{end}}},
+{{- end}}
+}
+
+// {{.Type}} gives typed access to the registers of the chip.
+type {{.Type}} struct {
+	*regmap.Map
+}
+
+// New{{.Type}} returns the registers of the chip on bus.
+func New{{.Type}}(bus regmap.Bus) *{{.Type}} {
+	return &{{.Type}}{regmap.New(bus, registers...)}
+}
+{{- $t := .Type}}
+{{range $r := .Registers}}
+{{- $reg := camel $r.Name}}
+// {{$reg}} reads the {{$r.Name}} register.{{if $r.Doc}} {{$r.Doc}}{{end}}
+func (r *{{$t}}) {{$reg}}() (uint32, error) {
+	return r.Read("{{$r.Name}}")
+}
+{{if not $r.ReadOnly}}
+// Set{{$reg}} writes the {{$r.Name}} register.
+func (r *{{$t}}) Set{{$reg}}(v uint32) error {
+	return r.Write("{{$r.Name}}", v)
+}
+{{end}}
+{{- range $f := $r.Fields}}
+{{- $name := printf "%s%s" $reg (camel $f.Name)}}
+{{- if $f.Enum}}
+// {{$name}} is the {{$f.Name}} field of the {{$r.Name}} register.{{if $f.Doc}} {{$f.Doc}}{{end}}
+type {{$name}} uint32
+
+// Values of {{$name}}.
+const (
+{{- range $f.Enum}}
+	{{$name}}{{camel .Name}} {{$name}} = {{.Value}}
+{{- end}}
+)
+
+// {{$name}} reads the {{$f.Name}} field of the {{$r.Name}} register.
+func (r *{{$t}}) {{$name}}() ({{$name}}, error) {
+	v, err := r.Field("{{$r.Name}}", {{$f.Shift}}, {{$f.Width}}).Get()
+	return {{$name}}(v), err
+}
+{{if not $r.ReadOnly}}
+// Set{{$name}} sets the {{$f.Name}} field of the {{$r.Name}} register.
+func (r *{{$t}}) Set{{$name}}(v {{$name}}) error {
+	return r.Field("{{$r.Name}}", {{$f.Shift}}, {{$f.Width}}).Set(uint32(v))
+}
+{{end}}
+{{- else if eq $f.Width 1}}
+// {{$name}} reads the {{$f.Name}} bit of the {{$r.Name}} register.{{if $f.Doc}} {{$f.Doc}}{{end}}
+func (r *{{$t}}) {{$name}}() (bool, error) {
+	return r.Field("{{$r.Name}}", {{$f.Shift}}, 1).Flag()
+}
+{{if not $r.ReadOnly}}
+// Set{{$name}} sets the {{$f.Name}} bit of the {{$r.Name}} register.
+func (r *{{$t}}) Set{{$name}}(b bool) error {
+	return r.Field("{{$r.Name}}", {{$f.Shift}}, 1).SetFlag(b)
+}
+{{end}}
+{{- else}}
+// {{$name}} reads the {{$f.Name}} field of the {{$r.Name}} register.{{if $f.Doc}} {{$f.Doc}}{{end}}
+func (r *{{$t}}) {{$name}}() (uint32, error) {
+	return r.Field("{{$r.Name}}", {{$f.Shift}}, {{$f.Width}}).Get()
+}
+{{if not $r.ReadOnly}}
+// Set{{$name}} sets the {{$f.Name}} field of the {{$r.Name}} register.
+func (r *{{$t}}) Set{{$name}}(v uint32) error {
+	return r.Field("{{$r.Name}}", {{$f.Shift}}, {{$f.Width}}).Set(v)
+}
+{{end}}
+{{- end}}
+{{- end}}
+{{- end}}
+`))
+
+func generate(source string, data []byte) ([]byte, error) {
+	var chip Chip
+	if err := yaml.UnmarshalStrict(data, &chip); err != nil {
+		return nil, err
+	}
+	if err := chip.validate(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, struct {
+		Chip
+		Source string
+	}{chip, source})
+	if err != nil {
+		return nil, err
+	}
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return out, nil
+}
+
+func main() {
+	in := flag.String("in", "", "YAML register description")
+	out := flag.String("out", "", "generated Go file (default: standard output)")
+	flag.Parse()
+
+	if *in == "" {
+		fmt.Fprintln(os.Stderr, "usage: regmapgen -in chip.yaml [-out regs.go]")
+		os.Exit(2)
+	}
+
+	data, err := ioutil.ReadFile(*in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "regmapgen:", err)
+		os.Exit(1)
+	}
+	code, err := generate(filepath.Base(*in), data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "regmapgen: %v: %v\n", *in, err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(code)
+		return
+	}
+	if err := ioutil.WriteFile(*out, code, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "regmapgen:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/l3gd20.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile("testdata/l3gd20.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := generate("l3gd20.yaml", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("generated code differs from testdata/l3gd20.golden:\n%s", got)
+	}
+}
+
+func TestGenerate_invalid(t *testing.T) {
+	var tests = []string{
+		"registers: [{name: A, addr: 1}]",
+		"package: p\nregisters: [{name: A, addr: 1}, {name: A, addr: 2}]",
+		"package: p\nregisters: [{name: A, addr: 1, fields: [{name: F, shift: 6, width: 4}]}]",
+		"package: p\nregisters: [{name: A, addr: 1, fields: [{name: F, shift: 0, width: 1, enum: [{name: X, value: 2}]}]}]",
+		"package: p\nregisters: [{name: A, addr: 1, unknown: true}]",
+	}
+	for _, test := range tests {
+		if _, err := generate("test.yaml", []byte(test)); err == nil {
+			t.Errorf("generate(%q) succeeded; want an error", test)
+		}
+	}
+}
+
+func TestCamel(t *testing.T) {
+	var tests = []struct {
+		in, out string
+	}{
+		{"WHO_AM_I", "WhoAmI"},
+		{"CTRL_REG1", "CtrlReg1"},
+		{"OUT_X_L", "OutXL"},
+		{"95Hz", "95Hz"},
+		{"PowerMode", "PowerMode"},
+	}
+	for _, test := range tests {
+		if out := camel(test.in); out != test.out {
+			t.Errorf("camel(%q) = %q; want %q", test.in, out, test.out)
+		}
+	}
+}